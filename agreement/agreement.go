@@ -139,6 +139,13 @@ func (w *AgreementWorker) NewEvent(incoming events.Message) {
 		msg, _ := incoming.(*events.EdgeRegisteredExchangeMessage)
 		w.Commands <- NewDeviceRegisteredCommand(msg)
 
+	case *events.NodeTokenRotatedMessage:
+		msg, _ := incoming.(*events.NodeTokenRotatedMessage)
+		if w.EC != nil {
+			w.EC = w.EC.WithToken(msg.Token())
+			w.limitedRetryEC = newLimitedRetryExchangeContext(w.EC)
+		}
+
 	case *events.PolicyCreatedMessage:
 		msg, _ := incoming.(*events.PolicyCreatedMessage)
 
@@ -32,6 +32,7 @@ const GOVERN_ARCHIVED_AGREEMENTS = "AgBotGovernArchivedAgreements"
 const POLICY_WATCHER = "AgBotPolicyWatcher"
 const STALE_PARTITIONS = "AgbotStaleDatabasePartition"
 const MESSAGE_KEY_CHECK = "AgbotMessageKeyCheck"
+const CLIENT_CERT_RENEWAL = "AgbotClientCertRenewal"
 
 // Agreement governance timing state. Used in the GovernAgreements subworker.
 type DVState struct {
@@ -380,6 +381,11 @@ func (w *AgreementBotWorker) Initialize() bool {
 	//w.DispatchSubworker(GOVERN_BC_NEEDS, w.GovernBlockchainNeeds, 60, false)
 	w.DispatchSubworker(MESSAGE_KEY_CHECK, w.messageKeyCheck, w.BaseWorker.Manager.Config.AgreementBot.MessageKeyCheck, false)
 
+	// renew the exchange client certificate before it expires
+	if w.Config.AgreementBot.ExchangeClientCert.IsConfigured() && w.Config.Collaborators.HTTPClientFactory.ClientCert != nil {
+		w.DispatchSubworker(CLIENT_CERT_RENEWAL, w.renewClientCert, 3600, false)
+	}
+
 	if w.Config.AgreementBot.CheckUpdatedPolicyS != 0 {
 		// Use custom subworker APIs for the policy watcher because it is stateful and already does its own time management.
 		ch := w.AddSubworker(POLICY_WATCHER)
@@ -1613,6 +1619,14 @@ func (w *AgreementBotWorker) stalePartitions() int {
 	return 0
 }
 
+// Renew the client certificate that the agbot presents to the exchange when it is about to expire.
+func (w *AgreementBotWorker) renewClientCert() int {
+	if err := w.Config.Collaborators.HTTPClientFactory.ClientCert.CheckRenewal(); err != nil {
+		glog.Errorf(AWlogString(fmt.Sprintf("unable to renew the exchange client certificate, error %v", err)))
+	}
+	return 0
+}
+
 // Ensure that the agbot's message key is still in its object in the exchange. If the agbot itself is missing,
 // we will panic (that should not happen). If the key is missing (i.e. the current key is a zero length byte array)
 // we will add our key back. If there is a key but it is just wrong, we will panic. This latter case could occur if
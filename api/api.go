@@ -82,6 +82,9 @@ func (a *API) router(includeStaticRedirects bool) *mux.Router {
 	router.HandleFunc("/service/config", a.serviceconfig).Methods("GET", "POST", "OPTIONS")
 	router.HandleFunc("/service/configstate", a.service_configstate).Methods("GET", "POST", "OPTIONS")
 	router.HandleFunc("/service/policy", a.servicepolicy).Methods("GET", "OPTIONS")
+	router.HandleFunc("/service/volume", a.servicevolume).Methods("GET", "OPTIONS")
+	router.HandleFunc("/service/volume/{name}/backup", a.servicevolumebackup).Methods("POST", "OPTIONS")
+	router.HandleFunc("/service/volume/{name}/restore", a.servicevolumerestore).Methods("POST", "OPTIONS")
 
 	// Connectivity and blockchain status info
 	router.HandleFunc("/status", a.status).Methods("GET", "OPTIONS")
@@ -95,6 +98,11 @@ func (a *API) router(includeStaticRedirects bool) *mux.Router {
 	router.HandleFunc("/node/configstate", a.nodeconfigstate).Methods("GET", "HEAD", "PUT", "OPTIONS")
 	router.HandleFunc("/node/policy", a.nodepolicy).Methods("GET", "HEAD", "PUT", "POST", "PATCH", "DELETE", "OPTIONS")
 	router.HandleFunc("/node/userinput", a.nodeuserinput).Methods("GET", "HEAD", "PUT", "POST", "PATCH", "DELETE", "OPTIONS")
+	router.HandleFunc("/node/policy/history", a.nodepolicyhistory).Methods("GET", "OPTIONS")
+	router.HandleFunc("/node/policy/rollback", a.nodepolicyrollback).Methods("POST", "OPTIONS")
+	router.HandleFunc("/node/userinput/history", a.nodeuserinputhistory).Methods("GET", "OPTIONS")
+	router.HandleFunc("/node/userinput/effective", a.nodeuserinputeffective).Methods("GET", "OPTIONS")
+	router.HandleFunc("/node/userinput/rollback", a.nodeuserinputrollback).Methods("POST", "OPTIONS")
 
 	// Used to get the event logs on this node.
 	// get the eventlogs for current registration.
@@ -168,6 +176,12 @@ func (a *API) NewEvent(incoming events.Message) {
 			glog.V(3).Infof(apiLogString(fmt.Sprintf("API Worker processed BC stopping for %v", msg)))
 		}
 
+	case *events.NodeTokenRotatedMessage:
+		msg, _ := incoming.(*events.NodeTokenRotatedMessage)
+		if a.EC != nil {
+			a.EC = a.EC.WithToken(msg.Token())
+		}
+
 	case *events.NodeShutdownCompleteMessage:
 		msg, _ := incoming.(*events.NodeShutdownCompleteMessage)
 		switch msg.Event().Id {
@@ -73,6 +73,13 @@ func (a *API) node(w http.ResponseWriter, r *http.Request) {
 			return errorHandler(err)
 		}
 
+		// The node id must match the exchange client certificate, if there is one.
+		if a.Config.Edge.ExchangeClientCert.IsConfigured() {
+			if checkClientCertIdentity(&newDevice, a.Config.Collaborators.HTTPClientFactory.ClientCert, create_device_error_handler) {
+				return
+			}
+		}
+
 		// Validate and create the new device registration.
 		errHandled, device, exDev := CreateHorizonDevice(&newDevice, create_device_error_handler, orgHandler, patternHandler, versionHandler, patchDeviceHandler, getDeviceHandler, a.em, a.db)
 		if errHandled {
@@ -523,3 +530,177 @@ func (a *API) nodeuserinput(w http.ResponseWriter, r *http.Request) {
 		w.WriteHeader(http.StatusMethodNotAllowed)
 	}
 }
+
+func (a *API) nodepolicyhistory(w http.ResponseWriter, r *http.Request) {
+
+	resource := "node/policy/history"
+
+	errorHandler := GetHTTPErrorHandler(w)
+
+	switch r.Method {
+	case "GET":
+		glog.V(5).Infof(apiLogString(fmt.Sprintf("Handling %v on resource %v", r.Method, resource)))
+
+		if out, err := FindNodePolicyHistoryForOutput(a.db); err != nil {
+			errorHandler(NewSystemError(fmt.Sprintf("Error getting %v for output, error %v", resource, err)))
+		} else {
+			writeResponse(w, out, http.StatusOK)
+		}
+
+	case "OPTIONS":
+		w.Header().Set("Allow", "GET, OPTIONS")
+		w.WriteHeader(http.StatusOK)
+	default:
+		w.WriteHeader(http.StatusMethodNotAllowed)
+	}
+}
+
+func (a *API) nodepolicyrollback(w http.ResponseWriter, r *http.Request) {
+
+	resource := "node/policy/rollback"
+
+	errorHandler := GetHTTPErrorHandler(w)
+
+	switch r.Method {
+	case "POST":
+		glog.V(5).Infof(apiLogString(fmt.Sprintf("Handling %v on resource %v", r.Method, resource)))
+
+		// An empty body rolls back to the version before the current one.
+		var rollback NodeConfigRollback
+		body, _ := ioutil.ReadAll(r.Body)
+		if len(body) != 0 {
+			if err := json.Unmarshal(body, &rollback); err != nil {
+				errorHandler(NewAPIUserInputError(fmt.Sprintf("Input body could not be deserialized to %v object: %v, error: %v", resource, string(body), err), "body"))
+				return
+			}
+		}
+
+		rollback_node_policy_error_handler := func(device interface{}, err error) bool {
+			LogDeviceEvent(a.db, persistence.SEVERITY_ERROR, persistence.NewMessageMeta(EL_API_ERR_IN_NODE_POLICY_ROLLBACK, err.Error()), persistence.EC_ERROR_NODE_POLICY_UPDATE, device)
+			return errorHandler(err)
+		}
+		nodeGetPolicyHandler := exchange.GetHTTPNodePolicyHandler(a)
+		nodePutPolicyHandler := exchange.GetHTTPPutNodePolicyHandler(a)
+		nodeDeletePolicyHandler := exchange.GetHTTPDeleteNodePolicyHandler(a)
+
+		errHandled, version, msgs := RollbackNodePolicy(&rollback, rollback_node_policy_error_handler, nodeGetPolicyHandler, nodePutPolicyHandler, nodeDeletePolicyHandler, a.db)
+		if errHandled {
+			return
+		}
+
+		// Send out all messages
+		for _, msg := range msgs {
+			a.Messages() <- msg
+		}
+
+		glog.V(5).Infof(apiLogString(fmt.Sprintf("Handled %v on resource %v", r.Method, resource)))
+
+		writeResponse(w, version, http.StatusOK)
+
+	case "OPTIONS":
+		w.Header().Set("Allow", "POST, OPTIONS")
+		w.WriteHeader(http.StatusOK)
+	default:
+		w.WriteHeader(http.StatusMethodNotAllowed)
+	}
+}
+
+func (a *API) nodeuserinputhistory(w http.ResponseWriter, r *http.Request) {
+
+	resource := "node/userinput/history"
+
+	errorHandler := GetHTTPErrorHandler(w)
+
+	switch r.Method {
+	case "GET":
+		glog.V(5).Infof(apiLogString(fmt.Sprintf("Handling %v on resource %v", r.Method, resource)))
+
+		if out, err := FindNodeUserInputHistoryForOutput(a.db); err != nil {
+			errorHandler(NewSystemError(fmt.Sprintf("Error getting %v for output, error %v", resource, err)))
+		} else {
+			writeResponse(w, out, http.StatusOK)
+		}
+
+	case "OPTIONS":
+		w.Header().Set("Allow", "GET, OPTIONS")
+		w.WriteHeader(http.StatusOK)
+	default:
+		w.WriteHeader(http.StatusMethodNotAllowed)
+	}
+}
+
+func (a *API) nodeuserinputeffective(w http.ResponseWriter, r *http.Request) {
+
+	resource := "node/userinput/effective"
+
+	errorHandler := GetHTTPErrorHandler(w)
+
+	switch r.Method {
+	case "GET":
+		glog.V(5).Infof(apiLogString(fmt.Sprintf("Handling %v on resource %v", r.Method, resource)))
+
+		svcUrl := r.URL.Query().Get("url")
+		svcOrg := r.URL.Query().Get("org")
+
+		if out, err := FindNodeEffectiveUserInputForOutput(svcUrl, svcOrg, exchange.GetHTTPServiceResolverHandler(a), a.db); err != nil {
+			errorHandler(NewSystemError(fmt.Sprintf("Error getting %v for output, error %v", resource, err)))
+		} else {
+			writeResponse(w, out, http.StatusOK)
+		}
+
+	case "OPTIONS":
+		w.Header().Set("Allow", "GET, OPTIONS")
+		w.WriteHeader(http.StatusOK)
+	default:
+		w.WriteHeader(http.StatusMethodNotAllowed)
+	}
+}
+
+func (a *API) nodeuserinputrollback(w http.ResponseWriter, r *http.Request) {
+
+	resource := "node/userinput/rollback"
+
+	errorHandler := GetHTTPErrorHandler(w)
+
+	switch r.Method {
+	case "POST":
+		glog.V(5).Infof(apiLogString(fmt.Sprintf("Handling %v on resource %v", r.Method, resource)))
+
+		// An empty body rolls back to the version before the current one.
+		var rollback NodeConfigRollback
+		body, _ := ioutil.ReadAll(r.Body)
+		if len(body) != 0 {
+			if err := json.Unmarshal(body, &rollback); err != nil {
+				errorHandler(NewAPIUserInputError(fmt.Sprintf("Input body could not be deserialized to %v object: %v, error: %v", resource, string(body), err), "body"))
+				return
+			}
+		}
+
+		rollback_node_userinput_error_handler := func(device interface{}, err error) bool {
+			LogDeviceEvent(a.db, persistence.SEVERITY_ERROR, persistence.NewMessageMeta(EL_API_ERR_IN_NODE_UI_ROLLBACK, err.Error()), persistence.EC_ERROR_NODE_USERINPUT_UPDATE, device)
+			return errorHandler(err)
+		}
+		getDevice := exchange.GetHTTPDeviceHandler(a)
+		patchDevice := exchange.GetHTTPPatchDeviceHandler(a)
+
+		errHandled, version, msgs := RollbackNodeUserInput(&rollback, rollback_node_userinput_error_handler, getDevice, patchDevice, a.db)
+		if errHandled {
+			return
+		}
+
+		// Send out all messages
+		for _, msg := range msgs {
+			a.Messages() <- msg
+		}
+
+		glog.V(5).Infof(apiLogString(fmt.Sprintf("Handled %v on resource %v", r.Method, resource)))
+
+		writeResponse(w, version, http.StatusOK)
+
+	case "OPTIONS":
+		w.Header().Set("Allow", "POST, OPTIONS")
+		w.WriteHeader(http.StatusOK)
+	default:
+		w.WriteHeader(http.StatusMethodNotAllowed)
+	}
+}
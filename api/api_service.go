@@ -239,3 +239,83 @@ func (a *API) servicepolicy(w http.ResponseWriter, r *http.Request) {
 	}
 
 }
+
+// For listing the named volumes of the services on the node and their backups.
+func (a *API) servicevolume(w http.ResponseWriter, r *http.Request) {
+
+	resource := "service/volume"
+	errorhandler := GetHTTPErrorHandler(w)
+
+	switch r.Method {
+	case "GET":
+		glog.V(5).Infof(apiLogString(fmt.Sprintf("Handling %v on resource %v", r.Method, resource)))
+
+		if out, err := FindVolumesForOutput(a.db); err != nil {
+			errorhandler(NewSystemError(fmt.Sprintf("Error getting %v for output, error %v", resource, err)))
+		} else {
+			writeResponse(w, out, http.StatusOK)
+		}
+
+	case "OPTIONS":
+		w.Header().Set("Allow", "GET, OPTIONS")
+		w.WriteHeader(http.StatusOK)
+	default:
+		w.WriteHeader(http.StatusMethodNotAllowed)
+	}
+}
+
+// For backing up a named volume of a service.
+func (a *API) servicevolumebackup(w http.ResponseWriter, r *http.Request) {
+
+	resource := "service/volume/backup"
+	errorhandler := GetHTTPErrorHandler(w)
+
+	switch r.Method {
+	case "POST":
+		glog.V(5).Infof(apiLogString(fmt.Sprintf("Handling %v on resource %v", r.Method, resource)))
+
+		name := mux.Vars(r)["name"]
+		if errHandled, backup := BackupVolume(name, errorhandler, a.db, a.Config); !errHandled {
+			writeResponse(w, backup, http.StatusOK)
+		}
+
+	case "OPTIONS":
+		w.Header().Set("Allow", "POST, OPTIONS")
+		w.WriteHeader(http.StatusOK)
+	default:
+		w.WriteHeader(http.StatusMethodNotAllowed)
+	}
+}
+
+// For restoring a named volume of a service from one of its backups.
+func (a *API) servicevolumerestore(w http.ResponseWriter, r *http.Request) {
+
+	resource := "service/volume/restore"
+	errorhandler := GetHTTPErrorHandler(w)
+
+	switch r.Method {
+	case "POST":
+		glog.V(5).Infof(apiLogString(fmt.Sprintf("Handling %v on resource %v", r.Method, resource)))
+
+		// An empty body restores the latest backup.
+		var restore VolumeRestore
+		body, _ := ioutil.ReadAll(r.Body)
+		if len(body) != 0 {
+			if err := json.Unmarshal(body, &restore); err != nil {
+				errorhandler(NewAPIUserInputError(fmt.Sprintf("Input body could not be deserialized to %v object: %v, error: %v", resource, string(body), err), "body"))
+				return
+			}
+		}
+
+		name := mux.Vars(r)["name"]
+		if errHandled, backup := RestoreVolume(name, &restore, errorhandler, a.db, a.Config); !errHandled {
+			writeResponse(w, backup, http.StatusOK)
+		}
+
+	case "OPTIONS":
+		w.Header().Set("Allow", "POST, OPTIONS")
+		w.WriteHeader(http.StatusOK)
+	default:
+		w.WriteHeader(http.StatusMethodNotAllowed)
+	}
+}
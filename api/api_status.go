@@ -11,6 +11,10 @@ func (a *API) status(w http.ResponseWriter, r *http.Request) {
 	case "GET":
 
 		info := apicommon.NewInfo(a.GetHTTPFactory(), a.GetExchangeURL(), a.GetCSSURL(), a.GetExchangeId(), a.GetExchangeToken())
+		info.Configuration.Proxy = apicommon.NewProxy(&a.Config.Edge.Proxy)
+		if a.Config.Edge.ExchangeClientCert.IsConfigured() {
+			info.Configuration.ClientCert = apicommon.NewClientCert(a.Config.Collaborators.HTTPClientFactory.ClientCert)
+		}
 
 		writeResponse(w, info, http.StatusOK)
 	case "OPTIONS":
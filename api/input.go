@@ -205,3 +205,9 @@ func NewService(url string, org string, name string, arch string, v string) *Ser
 		Attributes:    &[]Attribute{},
 	}
 }
+
+// The body of a rollback of the node user input or the node policy. Version 0, or no body, restores the version
+// before the current one.
+type NodeConfigRollback struct {
+	Version uint64 `json:"version"`
+}
@@ -15,16 +15,18 @@ const (
 	EL_API_ERR_POLICY_PATCH_INPUT_PROPERTY_ERROR   = "Error parsing input for node policy patch. Input body did not contain a Constraint Expression or Property List: %v, error: %v"
 	EL_API_ERR_PARSING_INPUT_FOR_NODE_UI           = "Error parsing input for node user input. Input body could not be deserialized as a UserInput object: %v, error: %v"
 
-	EL_API_ERR_IN_NODE_REG            = "Error in node configuration/registration for node %v. %v"
-	EL_API_ERR_IN_NODE_UPDATE         = "Error in updating node %v. %v"
-	EL_API_ERR_IN_NODE_UNREG          = "Error in node unregistration. %v"
-	EL_API_ERR_IN_VERIFY_EXCH_VERSION = "Error verifiying exchange version. error: %v"
-	EL_API_ERR_IN_NODE_POLICY_CREATE  = "Error in creating or replacing node policy. %v"
-	EL_API_ERR_IN_NODE_POLICY_PATCH   = "Error in patching node policy. %v"
-	EL_API_ERR_IN_NODE_POLICY_DEL     = "Error in deleting node policy. %v"
-	EL_API_ERR_IN_NODE_UI_UPDATE      = "Error in updating node user input. %v"
-	EL_API_ERR_IN_NODE_UI_PATCH       = "Error in patching node user input. %v"
-	EL_API_ERR_IN_NODE_UI_DEL         = "Error in deleting node userinput. %v"
+	EL_API_ERR_IN_NODE_REG             = "Error in node configuration/registration for node %v. %v"
+	EL_API_ERR_IN_NODE_UPDATE          = "Error in updating node %v. %v"
+	EL_API_ERR_IN_NODE_UNREG           = "Error in node unregistration. %v"
+	EL_API_ERR_IN_VERIFY_EXCH_VERSION  = "Error verifiying exchange version. error: %v"
+	EL_API_ERR_IN_NODE_POLICY_CREATE   = "Error in creating or replacing node policy. %v"
+	EL_API_ERR_IN_NODE_POLICY_PATCH    = "Error in patching node policy. %v"
+	EL_API_ERR_IN_NODE_POLICY_DEL      = "Error in deleting node policy. %v"
+	EL_API_ERR_IN_NODE_UI_UPDATE       = "Error in updating node user input. %v"
+	EL_API_ERR_IN_NODE_UI_PATCH        = "Error in patching node user input. %v"
+	EL_API_ERR_IN_NODE_UI_DEL          = "Error in deleting node userinput. %v"
+	EL_API_ERR_IN_NODE_POLICY_ROLLBACK = "Error in rolling back node policy. %v"
+	EL_API_ERR_IN_NODE_UI_ROLLBACK     = "Error in rolling back node user input. %v"
 
 	// from path_node.go
 	EL_API_START_NODE_REG       = "Start node configuration/registration for node %v."
@@ -55,13 +57,15 @@ const (
 	EL_API_IGNORE_TYPE_MISMATCH       = "Ignoring service. %v"
 
 	// from path_node_policy.go
-	EL_API_NEW_NODE_POL     = "New node policy: %v"
-	EL_API_NODE_POL_DELETED = "Deleted node policy"
+	EL_API_NEW_NODE_POL         = "New node policy: %v"
+	EL_API_NODE_POL_DELETED     = "Deleted node policy"
+	EL_API_NODE_POL_ROLLED_BACK = "Rolled back node policy to version %v"
 
 	// from path_node_userinput.go
 	EL_API_NEW_NODE_UI         = "New node user input: %v"
 	EL_API_NO_NODE_UI_TO_DEL   = "No node user input to detele"
 	EL_API_DELETED_ALL_NODE_UI = "Deleted all node user input"
+	EL_API_NODE_UI_ROLLED_BACK = "Rolled back node user input to version %v"
 
 	// from path_service_config.go
 	EL_API_START_SVC_CONFIG           = "Start service configuration with user input for %v/%v."
@@ -104,6 +108,8 @@ func MarkI18nMessages() {
 	msgPrinter.Sprintf(EL_API_ERR_IN_NODE_UI_UPDATE)
 	msgPrinter.Sprintf(EL_API_ERR_IN_NODE_UI_PATCH)
 	msgPrinter.Sprintf(EL_API_ERR_IN_NODE_UI_DEL)
+	msgPrinter.Sprintf(EL_API_ERR_IN_NODE_POLICY_ROLLBACK)
+	msgPrinter.Sprintf(EL_API_ERR_IN_NODE_UI_ROLLBACK)
 
 	// from path_node.go
 	msgPrinter.Sprintf(EL_API_START_NODE_REG)
@@ -136,11 +142,13 @@ func MarkI18nMessages() {
 	// from path_node_policy.go
 	msgPrinter.Sprintf(EL_API_NEW_NODE_POL)
 	msgPrinter.Sprintf(EL_API_NODE_POL_DELETED)
+	msgPrinter.Sprintf(EL_API_NODE_POL_ROLLED_BACK)
 
 	// from path_node_userinput.go
 	msgPrinter.Sprintf(EL_API_NEW_NODE_UI)
 	msgPrinter.Sprintf(EL_API_NO_NODE_UI_TO_DEL)
 	msgPrinter.Sprintf(EL_API_DELETED_ALL_NODE_UI)
+	msgPrinter.Sprintf(EL_API_NODE_UI_ROLLED_BACK)
 
 	// from path_service_config.go
 	msgPrinter.Sprintf(EL_API_START_SVC_CONFIG)
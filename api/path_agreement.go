@@ -29,6 +29,11 @@ func FindAgreementsForOutput(db *bolt.DB) (map[string]map[string][]persistence.E
 	wrap[agreementsKey][activeKey] = []persistence.EstablishedAgreement{}
 
 	for _, agreement := range agreements {
+		// The proposal holds the user input of the deployment policy, which can have secrets, so it is not shown.
+		if agreement.Proposal != "" {
+			agreement.Proposal = policy.REDACTED_VALUE
+		}
+
 		// The archived agreements and the agreements being terminated are returned as archived.
 		if agreement.Archived || agreement.AgreementTerminatedTime != 0 {
 			wrap[agreementsKey][archivedKey] = append(wrap[agreementsKey][archivedKey], agreement)
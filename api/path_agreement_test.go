@@ -3,8 +3,10 @@
 package api
 
 import (
+	"encoding/json"
 	"flag"
 	"github.com/open-horizon/anax/persistence"
+	"strings"
 	"testing"
 )
 
@@ -72,6 +74,38 @@ func Test_FindAgreementsForOutput1(t *testing.T) {
 
 }
 
+func Test_FindAgreementsForOutput_secrets(t *testing.T) {
+
+	dir, db, err := utsetup()
+	if err != nil {
+		t.Error(err)
+	}
+	defer cleanTestDir(dir)
+
+	// The proposal holds the user input of the deployment policy, with the value of a secret.
+	proposal := `{"tsandcs":"{\"userInput\":[{\"serviceUrl\":\"url\",\"inputs\":[{\"name\":\"PASSWORD\",\"value\":\"mysecretvalue\",\"type\":\"secret\"}]}]}"}`
+
+	wi, _ := persistence.NewWorkloadInfo("url", "org", "version", "")
+	if _, err := persistence.NewEstablishedAgreement(db, "name1", "agreementId1", "consumerId", proposal, "Basic", 1, []persistence.ServiceSpec{}, "signature", "address", "bcType", "bcName", "bcOrg", wi); err != nil {
+		t.Fatalf("error writing agreement1: %v", err)
+	}
+
+	if agsout, err := FindAgreementsForOutput(db); err != nil {
+		t.Errorf("error finding agreements: %v", err)
+	} else if len(agsout["agreements"]["active"]) != 1 {
+		t.Errorf("expecting 1 active agreement have %v", agsout["agreements"]["active"])
+	} else if out, err := json.Marshal(agsout); err != nil {
+		t.Errorf("error serializing agreements: %v", err)
+	} else if strings.Contains(string(out), "mysecretvalue") {
+		t.Errorf("the secret value is in the output: %v", string(out))
+	}
+
+	// the proposal is still kept in the database
+	if ags, err := persistence.FindEstablishedAgreements(db, "Basic", []persistence.EAFilter{}); err != nil || len(ags) != 1 || ags[0].Proposal != proposal {
+		t.Errorf("the proposal should be kept, got %v, error %v", ags, err)
+	}
+}
+
 func Test_DeleteAgreement0(t *testing.T) {
 
 	dir, db, err := utsetup()
@@ -5,6 +5,7 @@ import (
 	"fmt"
 	"github.com/boltdb/bolt"
 	"github.com/golang/glog"
+	"github.com/open-horizon/anax/config"
 	"github.com/open-horizon/anax/cutil"
 	"github.com/open-horizon/anax/eventlog"
 	"github.com/open-horizon/anax/events"
@@ -82,6 +83,31 @@ func FindHorizonDeviceForOutput(db *bolt.DB) (*HorizonDevice, error) {
 	return device, nil
 }
 
+// When the node presents a client certificate to the exchange, the node must be registered with the org and id
+// that the certificate identifies. If the node id is not specified, it is taken from the certificate.
+func checkClientCertIdentity(device *HorizonDevice, clientCert *config.ClientCertificate, errorhandler ErrorHandler) bool {
+	if clientCert == nil {
+		return false
+	}
+
+	org, id, err := clientCert.Identity()
+	if err != nil {
+		return errorhandler(NewSystemError(fmt.Sprintf("unable to get node identity from the exchange client certificate, error %v", err)))
+	}
+
+	if device.Id == nil || *device.Id == "" {
+		glog.V(3).Infof(apiLogString(fmt.Sprintf("using %v from the exchange client certificate as node ID.", id)))
+		device.Id = &id
+	} else if *device.Id != id {
+		return errorhandler(NewAPIUserInputError(fmt.Sprintf("%v does not match the node id %v in the exchange client certificate", *device.Id, id), "device.id"))
+	}
+
+	if device.Org != nil && *device.Org != org {
+		return errorhandler(NewAPIUserInputError(fmt.Sprintf("%v does not match the organization %v in the exchange client certificate", *device.Org, org), "device.organization"))
+	}
+	return false
+}
+
 // Given a demarshalled HorizonDevice object, validate it and save it, returning any errors.
 func CreateHorizonDevice(device *HorizonDevice,
 	errorhandler ErrorHandler,
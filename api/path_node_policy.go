@@ -103,3 +103,47 @@ func DeleteNodePolicy(errorhandler DeviceErrorHandler, db *bolt.DB,
 	return false, []*events.NodePolicyMessage{nodePolicyDeleted}
 
 }
+
+// Return the versions of the node policy, the oldest first.
+func FindNodePolicyHistoryForOutput(db *bolt.DB) ([]persistence.NodeConfigVersion, error) {
+
+	if history, err := persistence.FindNodePolicyHistory(db); err != nil {
+		return nil, errors.New(fmt.Sprintf("unable to read node policy history, error %v", err))
+	} else {
+		return history, nil
+	}
+}
+
+// Restore a previous version of the node policy in the local node database and in the exchange.
+func RollbackNodePolicy(rollback *NodeConfigRollback,
+	errorhandler DeviceErrorHandler,
+	nodeGetPolicyHandler exchange.NodePolicyHandler,
+	nodePutPolicyHandler exchange.PutNodePolicyHandler,
+	nodeDeletePolicyHandler exchange.DeleteNodePolicyHandler,
+	db *bolt.DB) (bool, *persistence.NodeConfigVersion, []*events.NodePolicyMessage) {
+
+	pDevice, err := persistence.FindExchangeDevice(db)
+	if err != nil {
+		return errorhandler(nil, NewSystemError(fmt.Sprintf("Unable to read node object, error %v", err))), nil, nil
+	} else if pDevice == nil {
+		return errorhandler(nil, NewNotFoundError("Exchange registration not recorded. Complete account and node registration with an exchange and then record node registration using this API's /node path.", "node")), nil, nil
+	}
+
+	if history, err := persistence.FindNodePolicyHistory(db); err != nil {
+		return errorhandler(pDevice, NewSystemError(fmt.Sprintf("Unable to read node policy history, error %v", err))), nil, nil
+	} else if _, err := exchangesync.RollbackTarget(history, rollback.Version); err != nil {
+		return errorhandler(pDevice, NewAPIUserInputError(fmt.Sprintf("Unable to roll back the node policy, %v", err), "version")), nil, nil
+	}
+
+	if target, err := exchangesync.RollbackNodePolicy(pDevice, db, rollback.Version, nodeGetPolicyHandler, nodePutPolicyHandler, nodeDeletePolicyHandler); err != nil {
+		return errorhandler(pDevice, NewSystemError(fmt.Sprintf("Unable to roll back the node policy. %v", err))), nil, nil
+	} else {
+		LogDeviceEvent(db, persistence.SEVERITY_INFO, persistence.NewMessageMeta(EL_API_NODE_POL_ROLLED_BACK, target.Version), persistence.EC_NODE_POLICY_UPDATED, pDevice)
+
+		msgId := events.UPDATE_POLICY
+		if target.Deleted {
+			msgId = events.DELETED_POLICY
+		}
+		return false, target, []*events.NodePolicyMessage{events.NewNodePolicyMessage(msgId)}
+	}
+}
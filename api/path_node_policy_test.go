@@ -16,6 +16,9 @@ func init() {
 	flag.Set("alsologtostderr", "true")
 	flag.Set("v", "7")
 	// no need to parse flags, that's done by test framework
+
+	// read the os, kernel and network properties from files that do not exist, so NUM_BUILT_INS holds on any machine
+	externalpolicy.SetSystemPropertySources("/nonexistent/os-release", "/nonexistent/osrelease", "/nonexistent/net")
 }
 
 // Verify that FindNodePolicyForOutput works when there is no node policy defined yet.
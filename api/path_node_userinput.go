@@ -5,6 +5,7 @@ import (
 	"fmt"
 	"github.com/boltdb/bolt"
 	"github.com/golang/glog"
+	"github.com/open-horizon/anax/abstractprotocol"
 	"github.com/open-horizon/anax/cutil"
 	"github.com/open-horizon/anax/events"
 	"github.com/open-horizon/anax/exchange"
@@ -14,7 +15,7 @@ import (
 	"github.com/open-horizon/anax/semanticversion"
 )
 
-// Return an empty user input object or the object that's in the local database.
+// Return an empty user input object or the object that's in the local database. The values of the secrets are redacted.
 func FindNodeUserInputForOutput(db *bolt.DB) ([]policy.UserInput, error) {
 
 	if userInput, err := persistence.FindNodeUserInput(db); err != nil {
@@ -22,7 +23,7 @@ func FindNodeUserInputForOutput(db *bolt.DB) ([]policy.UserInput, error) {
 	} else if userInput == nil {
 		return []policy.UserInput{}, nil
 	} else {
-		return userInput, nil
+		return policy.RedactSecretUserInput(userInput), nil
 	}
 }
 
@@ -43,6 +44,11 @@ func UpdateNodeUserInput(userInput []policy.UserInput,
 		return errorhandler(nil, NewNotFoundError("Exchange registration not recorded. Complete account and node registration with an exchange and then record node registration using this API's /node path.", "node")), nil, nil
 	}
 
+	// secrets that are given with the redacted value keep their current value
+	if userInput, err = keepNodeSecretValues(userInput, db); err != nil {
+		return errorhandler(pDevice, NewSystemError(err.Error())), nil, nil
+	}
+
 	// verify userinput: 1) service exist, 2) service type matches the node type,
 	// 3) variables definied in the service, otherwise return true but give warning message 4) values have correct type
 	validated := false
@@ -58,10 +64,11 @@ func UpdateNodeUserInput(userInput []policy.UserInput,
 	if changedSvcs, err := exchangesync.UpdateNodeUserInput(pDevice, db, userInput, getDevice, patchDevice); err != nil {
 		return errorhandler(pDevice, NewSystemError(fmt.Sprintf("Unable to update the node user input. %v", err))), nil, nil
 	} else {
-		LogDeviceEvent(db, persistence.SEVERITY_INFO, persistence.NewMessageMeta(EL_API_NEW_NODE_UI, userInput), persistence.EC_NODE_USERINPUT_UPDATED, pDevice)
+		redacted := policy.RedactSecretUserInput(userInput)
+		LogDeviceEvent(db, persistence.SEVERITY_INFO, persistence.NewMessageMeta(EL_API_NEW_NODE_UI, redacted), persistence.EC_NODE_USERINPUT_UPDATED, pDevice)
 
 		nodeUserInputUpdated := events.NewNodeUserInputMessage(events.UPDATE_NODE_USERINPUT, changedSvcs)
-		return false, userInput, []*events.NodeUserInputMessage{nodeUserInputUpdated}
+		return false, redacted, []*events.NodeUserInputMessage{nodeUserInputUpdated}
 	}
 }
 
@@ -80,6 +87,11 @@ func PatchNodeUserInput(patchObject []policy.UserInput,
 		return errorhandler(nil, NewNotFoundError("Exchange registration not recorded. Complete account and node registration with an exchange and then record node registration using this API's /node path.", "node")), nil, nil
 	}
 
+	// secrets that are given with the redacted value keep their current value
+	if patchObject, err = keepNodeSecretValues(patchObject, db); err != nil {
+		return errorhandler(pDevice, NewSystemError(err.Error())), nil, nil
+	}
+
 	// verify userinput: 1) service exist, 2) variables definied in the service, otherwise return true but give warning message 3) values have correct type
 	validated := false
 	for _, u := range patchObject {
@@ -94,7 +106,8 @@ func PatchNodeUserInput(patchObject []policy.UserInput,
 	if err := exchangesync.PatchNodeUserInput(pDevice, db, patchObject, getDevice, patchDevice); err != nil {
 		return errorhandler(pDevice, NewSystemError(fmt.Sprintf("Unable patch the user input. %v", err))), nil, nil
 	} else {
-		LogDeviceEvent(db, persistence.SEVERITY_INFO, persistence.NewMessageMeta(EL_API_NEW_NODE_UI, patchObject), persistence.EC_NODE_USERINPUT_UPDATED, pDevice)
+		redacted := policy.RedactSecretUserInput(patchObject)
+		LogDeviceEvent(db, persistence.SEVERITY_INFO, persistence.NewMessageMeta(EL_API_NEW_NODE_UI, redacted), persistence.EC_NODE_USERINPUT_UPDATED, pDevice)
 
 		chnagedSvcSpecs := new(persistence.ServiceSpecs)
 		for _, ui := range patchObject {
@@ -102,7 +115,7 @@ func PatchNodeUserInput(patchObject []policy.UserInput,
 
 		}
 		nodeUserInputUpdated := events.NewNodeUserInputMessage(events.UPDATE_NODE_USERINPUT, *chnagedSvcSpecs)
-		return false, patchObject, []*events.NodeUserInputMessage{nodeUserInputUpdated}
+		return false, redacted, []*events.NodeUserInputMessage{nodeUserInputUpdated}
 	}
 }
 
@@ -144,8 +157,18 @@ func DeleteNodeUserInput(errorhandler DeviceErrorHandler, db *bolt.DB,
 	return false, []*events.NodeUserInputMessage{nodeUserInputUpdated}
 }
 
+// Give the secrets in the user input that have the redacted value the value they have in the local database.
+func keepNodeSecretValues(userInput []policy.UserInput, db *bolt.DB) ([]policy.UserInput, error) {
+	if existing, err := persistence.FindNodeUserInput(db); err != nil {
+		return nil, errors.New(fmt.Sprintf("unable to read node user input object, error %v", err))
+	} else {
+		return policy.KeepSecretValues(userInput, existing), nil
+	}
+}
+
 // Validate 1) service exist; 2) service type matches the node type
-// 3) variables defined in the service; 4) values have correct type
+// 3) variables defined in the service; 4) values have correct type. The variables that the service declares as secrets
+// are given the secret type, the inputs share their array with the caller so that the type is saved with the value.
 func ValidateUserInput(pDevice *persistence.ExchangeDevice, userInput policy.UserInput, getService exchange.ServiceHandler) (bool, error) {
 	glog.V(3).Infof(apiLogString(fmt.Sprintf("Start validate userinput .... \n")))
 	serviceOrg := userInput.ServiceOrgid
@@ -215,7 +238,7 @@ func ValidateUserInput(pDevice *persistence.ExchangeDevice, userInput policy.Use
 	var ok bool
 	var inputNameNotDefinedInService []string
 
-	for _, policyInput := range policyUserInputs {
+	for i, policyInput := range policyUserInputs {
 		policyInputName = policyInput.Name
 		policyInputValue = policyInput.Value
 
@@ -224,7 +247,13 @@ func ValidateUserInput(pDevice *persistence.ExchangeDevice, userInput policy.Use
 			// give back a warning with this errorString
 			inputNameNotDefinedInService = append(inputNameNotDefinedInService, policyInputName)
 		} else {
-			if err := cutil.VerifyWorkloadVarTypes(policyInputValue, serviceInput.Type); err != nil {
+			if serviceInput.IsSecret() {
+				if policyInputValue == policy.REDACTED_VALUE {
+					return false, fmt.Errorf("The secret user input %v for service %v/%v has the redacted value but the node does not have a value for it.", policyInputName, serviceOrg, serviceUrl)
+				}
+				policyUserInputs[i].Type = policy.USERINPUT_TYPE_SECRET
+			}
+			if err := serviceInput.VerifyValue(policyInputValue); err != nil {
 				return false, fmt.Errorf("Error validating user input %v for service %v/%v. Error: %v", policyInputName, serviceOrg, serviceUrl, err)
 			}
 		}
@@ -237,3 +266,219 @@ func ValidateUserInput(pDevice *persistence.ExchangeDevice, userInput policy.Use
 
 	return true, nil
 }
+
+// Return the versions of the node user input, the oldest first.
+func FindNodeUserInputHistoryForOutput(db *bolt.DB) ([]persistence.NodeConfigVersion, error) {
+
+	if history, err := persistence.FindNodeUserInputHistory(db); err != nil {
+		return nil, errors.New(fmt.Sprintf("unable to read node user input history, error %v", err))
+	} else {
+		for i := range history {
+			history[i].UserInput = policy.RedactSecretUserInput(history[i].UserInput)
+		}
+		return history, nil
+	}
+}
+
+// Restore a previous version of the node user input in the local node database and in the exchange.
+func RollbackNodeUserInput(rollback *NodeConfigRollback,
+	errorhandler DeviceErrorHandler,
+	getDevice exchange.DeviceHandler,
+	patchDevice exchange.PatchDeviceHandler,
+	db *bolt.DB) (bool, *persistence.NodeConfigVersion, []*events.NodeUserInputMessage) {
+
+	pDevice, err := persistence.FindExchangeDevice(db)
+	if err != nil {
+		return errorhandler(nil, NewSystemError(fmt.Sprintf("Unable to read node object, error %v", err))), nil, nil
+	} else if pDevice == nil {
+		return errorhandler(nil, NewNotFoundError("Exchange registration not recorded. Complete account and node registration with an exchange and then record node registration using this API's /node path.", "node")), nil, nil
+	}
+
+	if history, err := persistence.FindNodeUserInputHistory(db); err != nil {
+		return errorhandler(pDevice, NewSystemError(fmt.Sprintf("Unable to read node user input history, error %v", err))), nil, nil
+	} else if _, err := exchangesync.RollbackTarget(history, rollback.Version); err != nil {
+		return errorhandler(pDevice, NewAPIUserInputError(fmt.Sprintf("Unable to roll back the node user input, %v", err), "version")), nil, nil
+	}
+
+	if target, changedSvcs, err := exchangesync.RollbackNodeUserInput(pDevice, db, rollback.Version, false, getDevice, patchDevice); err != nil {
+		return errorhandler(pDevice, NewSystemError(fmt.Sprintf("Unable to roll back the node user input. %v", err))), nil, nil
+	} else {
+		LogDeviceEvent(db, persistence.SEVERITY_INFO, persistence.NewMessageMeta(EL_API_NODE_UI_ROLLED_BACK, target.Version), persistence.EC_NODE_USERINPUT_UPDATED, pDevice)
+
+		target.UserInput = policy.RedactSecretUserInput(target.UserInput)
+
+		nodeUserInputUpdated := events.NewNodeUserInputMessage(events.UPDATE_NODE_USERINPUT, changedSvcs)
+		return false, target, []*events.NodeUserInputMessage{nodeUserInputUpdated}
+	}
+}
+
+// The user input that a service on this node receives. AgreementId is the agreement that the service runs for, it is
+// empty for an agreement-less service.
+type EffectiveServiceUserInput struct {
+	AgreementId string `json:"agreementId,omitempty"`
+	policy.EffectiveUserInput
+}
+
+// Return the user input that the services on this node receive, with the layer that each value comes from. A service
+// that runs for more than one agreement is returned once for each agreement, because the user input in the pattern
+// or deployment policy can differ between the agreements. Empty svcUrl and svcOrg return all the services.
+func FindNodeEffectiveUserInputForOutput(svcUrl string, svcOrg string,
+	resolveService exchange.ServiceResolverHandler,
+	db *bolt.DB) ([]EffectiveServiceUserInput, error) {
+
+	out := []EffectiveServiceUserInput{}
+
+	pDevice, err := persistence.FindExchangeDevice(db)
+	if err != nil {
+		return nil, errors.New(fmt.Sprintf("unable to read node object, error %v", err))
+	} else if pDevice == nil {
+		return out, nil
+	}
+
+	nodeUserInput, err := persistence.FindNodeUserInput(db)
+	if err != nil {
+		return nil, errors.New(fmt.Sprintf("unable to read node user input object, error %v", err))
+	}
+
+	// the user input in the agreement comes from the pattern or from a deployment policy
+	agSource := policy.USERINPUT_SOURCE_DEPLOYMENT_POLICY
+	if pDevice.Pattern != "" {
+		agSource = policy.USERINPUT_SOURCE_PATTERN
+	}
+
+	matches := func(url string, org string) bool {
+		return (svcUrl == "" || svcUrl == url) && (svcOrg == "" || svcOrg == org)
+	}
+
+	// the user input in the terms and conditions of each agreement
+	agUserInput := make(map[string][]policy.UserInput)
+	ags, err := persistence.FindEstablishedAgreementsAllProtocols(db, policy.AllAgreementProtocols(), []persistence.EAFilter{persistence.UnarchivedEAFilter()})
+	if err != nil {
+		return nil, errors.New(fmt.Sprintf("unable to read agreements, error %v", err))
+	}
+	for _, ag := range ags {
+		if ag.AgreementTerminatedTime != 0 || ag.Proposal == "" {
+			continue
+		}
+		if proposal, err := abstractprotocol.DemarshalProposal(ag.Proposal); err != nil {
+			return nil, errors.New(fmt.Sprintf("unable to demarshal proposal for agreement %v, error %v", ag.CurrentAgreementId, err))
+		} else if tcPolicy, err := policy.DemarshalPolicy(proposal.TsAndCs()); err != nil {
+			return nil, errors.New(fmt.Sprintf("unable to demarshal terms and conditions for agreement %v, error %v", ag.CurrentAgreementId, err))
+		} else {
+			agUserInput[ag.CurrentAgreementId] = tcPolicy.UserInput
+		}
+	}
+
+	// the top level services, the service definition comes from the exchange as it does when the service is started
+	for _, ag := range ags {
+		wl := ag.RunningWorkload
+		if _, ok := agUserInput[ag.CurrentAgreementId]; !ok || wl.URL == "" || !matches(wl.URL, wl.Org) {
+			continue
+		}
+		if _, sDef, _, err := resolveService(wl.URL, wl.Org, wl.Version, wl.Arch); err != nil {
+			return nil, errors.New(fmt.Sprintf("unable to get service %v/%v version %v from the exchange, error %v", wl.Org, wl.URL, wl.Version, err))
+		} else if sDef == nil {
+			return nil, errors.New(fmt.Sprintf("service %v/%v version %v is not in the exchange", wl.Org, wl.URL, wl.Version))
+		} else if e, err := nodeEffectiveUserInput(wl.URL, wl.Org, wl.Version, wl.Arch, sDef.UserInputs, agSource, agUserInput[ag.CurrentAgreementId], nodeUserInput, db); err != nil {
+			return nil, err
+		} else {
+			out = append(out, EffectiveServiceUserInput{AgreementId: ag.CurrentAgreementId, EffectiveUserInput: *e})
+		}
+	}
+
+	// the dependent and agreement-less services, the service definition is in the local db
+	msdefs, err := persistence.FindMicroserviceDefs(db, []persistence.MSFilter{persistence.UnarchivedMSFilter()})
+	if err != nil {
+		return nil, errors.New(fmt.Sprintf("unable to read service definitions, error %v", err))
+	}
+	for _, msdef := range msdefs {
+		if !matches(msdef.SpecRef, msdef.Org) {
+			continue
+		}
+
+		msis, err := persistence.FindMicroserviceInstances(db, []persistence.MIFilter{persistence.UnarchivedMIFilter(), persistence.AllInstancesMIFilter(msdef.SpecRef, msdef.Org, msdef.Version)})
+		if err != nil {
+			return nil, errors.New(fmt.Sprintf("unable to read service instances of %v/%v, error %v", msdef.Org, msdef.SpecRef, err))
+		}
+
+		svcUserInputs := make([]exchange.UserInput, 0, len(msdef.UserInputs))
+		for _, ui := range msdef.UserInputs {
+			svcUserInputs = append(svcUserInputs, exchange.UserInput{Name: ui.Name, Label: ui.Label, Type: ui.Type, DefaultValue: ui.DefaultValue, InputConstraints: ui.InputConstraints})
+		}
+
+		agIds := []string{}
+		for _, msi := range msis {
+			if msi.AgreementLess {
+				agIds = append(agIds, "")
+			}
+			for _, agId := range msi.AssociatedAgreements {
+				if _, ok := agUserInput[agId]; ok {
+					agIds = append(agIds, agId)
+				}
+			}
+		}
+
+		for _, agId := range agIds {
+			if e, err := nodeEffectiveUserInput(msdef.SpecRef, msdef.Org, msdef.Version, msdef.Arch, svcUserInputs, agSource, agUserInput[agId], nodeUserInput, db); err != nil {
+				return nil, err
+			} else {
+				out = append(out, EffectiveServiceUserInput{AgreementId: agId, EffectiveUserInput: *e})
+			}
+		}
+	}
+
+	return out, nil
+}
+
+// Merge the user input of a service the way the agent does when it starts the service. The agent applies the user
+// input of a service regardless of its version and arch, so the layers are not filtered on them.
+func nodeEffectiveUserInput(svcUrl string, svcOrg string, svcVersion string, svcArch string,
+	svcUserInputs []exchange.UserInput,
+	agSource string, agUserInput []policy.UserInput,
+	nodeUserInput []policy.UserInput,
+	db *bolt.DB) (*policy.EffectiveUserInput, error) {
+
+	layers := []policy.UserInputLayer{{Source: policy.USERINPUT_SOURCE_SERVICE, Inputs: []policy.Input{}}}
+	required := []string{}
+	sensitive := []string{}
+	for _, ui := range svcUserInputs {
+		if ui.DefaultValue != "" {
+			layers[0].Inputs = append(layers[0].Inputs, policy.Input{Name: ui.Name, Value: ui.DefaultValue, Type: ui.Type})
+		} else {
+			required = append(required, ui.Name)
+		}
+		if ui.IsSensitive() {
+			sensitive = append(sensitive, ui.Name)
+		}
+	}
+
+	for _, l := range []struct {
+		source    string
+		userInput []policy.UserInput
+	}{{agSource, agUserInput}, {policy.USERINPUT_SOURCE_NODE, nodeUserInput}} {
+		if layer, err := policy.NewUserInputLayer(l.source, svcUrl, svcOrg, "", "", l.userInput); err != nil {
+			return nil, err
+		} else {
+			layers = append(layers, *layer)
+		}
+	}
+
+	// the legacy user input attributes have the highest precedence
+	attrs, err := persistence.FindApplicableAttributes(db, svcUrl, svcOrg)
+	if err != nil {
+		return nil, errors.New(fmt.Sprintf("unable to read attributes for service %v/%v, error %v", svcOrg, svcUrl, err))
+	}
+	attrLayer := policy.UserInputLayer{Source: policy.USERINPUT_SOURCE_ATTRIBUTE, Inputs: []policy.Input{}}
+	for _, attr := range attrs {
+		if uiAttr, ok := attr.(persistence.UserInputAttributes); ok {
+			for name, value := range uiAttr.Mappings {
+				attrLayer.Inputs = append(attrLayer.Inputs, policy.Input{Name: name, Value: value})
+			}
+		}
+	}
+	layers = append(layers, attrLayer)
+
+	effective := policy.NewEffectiveUserInput(svcUrl, svcOrg, svcVersion, svcArch, layers, required)
+	effective.Redact(sensitive)
+	return effective, nil
+}
@@ -0,0 +1,86 @@
+// +build unit
+
+package api
+
+import (
+	"github.com/open-horizon/anax/exchange"
+	"github.com/open-horizon/anax/persistence"
+	"github.com/open-horizon/anax/policy"
+	"testing"
+)
+
+// Verify that the effective user input of an agreement-less service merges the service defaults and the node user input.
+func Test_FindNodeEffectiveUserInputForOutput(t *testing.T) {
+
+	dir, db, err := utsetup()
+	if err != nil {
+		t.Error(err)
+	}
+	defer cleanTestDir(dir)
+
+	resolveService := func(wUrl string, wOrg string, wVersion string, wArch string) (*policy.APISpecList, *exchange.ServiceDefinition, []string, error) {
+		t.Errorf("the exchange should not be called when there are no agreements")
+		return nil, nil, nil, nil
+	}
+
+	// not registered
+	if out, err := FindNodeEffectiveUserInputForOutput("", "", resolveService, db); err != nil {
+		t.Errorf("FindNodeEffectiveUserInputForOutput should not have returned error but got: %v", err)
+	} else if len(out) != 0 {
+		t.Errorf("there should be no effective user input for an unregistered node, got: %v", out)
+	}
+
+	if _, err := persistence.SaveNewExchangeDevice(db, "testid", "testtoken", "testname", "device", false, "myOrg", "", persistence.CONFIGSTATE_CONFIGURED); err != nil {
+		t.Errorf("failed to create persisted device, error %v", err)
+	}
+
+	nodeUserInput := []policy.UserInput{
+		policy.UserInput{ServiceOrgid: "myOrg", ServiceUrl: "svc1", Inputs: []policy.Input{policy.Input{Name: "var1", Value: "node1"}}},
+	}
+	if err := persistence.SaveNodeUserInput(db, nodeUserInput); err != nil {
+		t.Errorf("failed to save node user input, error %v", err)
+	}
+
+	msdef := &persistence.MicroserviceDefinition{
+		SpecRef: "svc1",
+		Org:     "myOrg",
+		Version: "1.0.0",
+		Arch:    "amd64",
+		UserInputs: []persistence.UserInput{
+			persistence.UserInput{Name: "var1", Type: "string", DefaultValue: "default1"},
+			persistence.UserInput{Name: "var2", Type: "string", DefaultValue: "default2"},
+			persistence.UserInput{Name: "var3", Type: "string"},
+		},
+	}
+	if err := persistence.SaveOrUpdateMicroserviceDef(db, msdef); err != nil {
+		t.Errorf("failed to save service definition, error %v", err)
+	}
+	if msi, err := persistence.NewMicroserviceInstance(db, "svc1", "myOrg", "1.0.0", msdef.Id, []persistence.ServiceInstancePathElement{}); err != nil {
+		t.Errorf("failed to create service instance, error %v", err)
+	} else if _, err := persistence.UpdateMSInstanceAgreementLess(db, msi.GetKey()); err != nil {
+		t.Errorf("failed to make the service instance agreement-less, error %v", err)
+	}
+
+	if out, err := FindNodeEffectiveUserInputForOutput("svc1", "myOrg", resolveService, db); err != nil {
+		t.Errorf("FindNodeEffectiveUserInputForOutput should not have returned error but got: %v", err)
+	} else if len(out) != 1 {
+		t.Errorf("there should be the effective user input of 1 service, got: %v", out)
+	} else if out[0].AgreementId != "" {
+		t.Errorf("the service is agreement-less, got agreement %v", out[0].AgreementId)
+	} else if len(out[0].Inputs) != 2 {
+		t.Errorf("there should be 2 effective inputs, got: %v", out[0].Inputs)
+	} else if out[0].Inputs[0].Source != policy.USERINPUT_SOURCE_NODE || out[0].Inputs[0].Value != "node1" {
+		t.Errorf("var1 should come from the node user input, got: %v", out[0].Inputs[0])
+	} else if out[0].Inputs[1].Source != policy.USERINPUT_SOURCE_SERVICE {
+		t.Errorf("var2 should come from the service definition, got: %v", out[0].Inputs[1])
+	} else if len(out[0].Missing) != 1 || out[0].Missing[0] != "var3" {
+		t.Errorf("var3 should be missing, got: %v", out[0].Missing)
+	}
+
+	// another service
+	if out, err := FindNodeEffectiveUserInputForOutput("svc2", "myOrg", resolveService, db); err != nil {
+		t.Errorf("FindNodeEffectiveUserInputForOutput should not have returned error but got: %v", err)
+	} else if len(out) != 0 {
+		t.Errorf("there should be no effective user input for svc2, got: %v", out)
+	}
+}
@@ -332,7 +332,7 @@ func CreateService(service *Service,
 			for varName, varValue := range attr.GetGenericMappings() {
 				glog.V(5).Infof(apiLogString(fmt.Sprintf("checking input variable: %v", varName)))
 				if ui := msdef.GetUserInputName(varName); ui != nil {
-					if err := cutil.VerifyWorkloadVarTypes(varValue, ui.Type); err != nil {
+					if err := ui.VerifyValue(varValue); err != nil {
 						return errorhandler(NewAPIUserInputError(fmt.Sprintf(cutil.ANAX_SVC_WRONG_TYPE+"%v", varName, cutil.FormOrgSpecUrl(*service.Url, *service.Org), err), "variables")), nil
 					}
 				}
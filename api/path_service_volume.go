@@ -0,0 +1,105 @@
+package api
+
+import (
+	"errors"
+	"fmt"
+	"github.com/boltdb/bolt"
+	"github.com/open-horizon/anax/config"
+	"github.com/open-horizon/anax/container"
+	"github.com/open-horizon/anax/persistence"
+	"sort"
+)
+
+// A named volume of a service and its backups.
+type VolumeOutput struct {
+	Name           string                     `json:"name"`
+	Service        string                     `json:"service"`
+	ServiceVersion string                     `json:"service_version"`
+	Retention      string                     `json:"retention"`
+	ReleaseTime    uint64                     `json:"release_time"`             // When the containers that used the volume were removed, 0 while they run.
+	RestoreBackup  string                     `json:"restore_backup,omitempty"` // The backup that is restored when the service is started again.
+	Backups        []persistence.VolumeBackup `json:"backups"`
+}
+
+// The input of the restore of a named volume.
+type VolumeRestore struct {
+	Backup string `json:"backup,omitempty"` // The record id of the backup, the latest backup when empty.
+}
+
+// Get the named volumes of the services on the node, sorted by name.
+func FindVolumesForOutput(db *bolt.DB) ([]VolumeOutput, error) {
+
+	cvs, err := persistence.FindContainerVolumes(db, []persistence.ContainerVolumeFilter{persistence.UnarchivedCVFilter(), persistence.DeclaredCVFilter()})
+	if err != nil {
+		return nil, errors.New(fmt.Sprintf("unable to read volumes, error %v", err))
+	}
+
+	out := make([]VolumeOutput, 0, len(cvs))
+	for _, cv := range cvs {
+		backups, err := persistence.FindVolumeBackups(db, []persistence.VolumeBackupFilter{persistence.VolumeNameVBFilter(cv.Name)})
+		if err != nil {
+			return nil, errors.New(fmt.Sprintf("unable to read the backups of volume %v, error %v", cv.Name, err))
+		}
+		out = append(out, VolumeOutput{
+			Name:           cv.Name,
+			Service:        cv.Service,
+			ServiceVersion: cv.ServiceVersion,
+			Retention:      cv.Retention,
+			ReleaseTime:    cv.ReleaseTime,
+			RestoreBackup:  cv.RestoreBackup,
+			Backups:        backups,
+		})
+	}
+
+	sort.Slice(out, func(i, j int) bool { return out[i].Name < out[j].Name })
+	return out, nil
+}
+
+// Back up a named volume of a service.
+func BackupVolume(name string, errorhandler ErrorHandler, db *bolt.DB, cfg *config.HorizonConfig) (bool, *persistence.VolumeBackup) {
+
+	if errHandled := checkDeclaredVolume(name, errorhandler, db); errHandled {
+		return true, nil
+	}
+
+	if backup, err := container.BackupNamedVolume(db, cfg, name); err != nil {
+		return errorhandler(NewSystemError(fmt.Sprintf("Unable to back up volume %v, error %v", name, err))), nil
+	} else {
+		return false, backup
+	}
+}
+
+// Restore a named volume of a service from one of its backups. The service must not be running.
+func RestoreVolume(name string, restore *VolumeRestore, errorhandler ErrorHandler, db *bolt.DB, cfg *config.HorizonConfig) (bool, *persistence.VolumeBackup) {
+
+	if errHandled := checkDeclaredVolume(name, errorhandler, db); errHandled {
+		return true, nil
+	}
+
+	filters := []persistence.VolumeBackupFilter{persistence.VolumeNameVBFilter(name)}
+	if restore.Backup != "" {
+		filters = append(filters, persistence.RecordIdVBFilter(restore.Backup))
+	}
+	if backups, err := persistence.FindVolumeBackups(db, filters); err != nil {
+		return errorhandler(NewSystemError(fmt.Sprintf("Unable to read the backups of volume %v, error %v", name, err))), nil
+	} else if len(backups) == 0 && restore.Backup != "" {
+		return errorhandler(NewAPIUserInputError(fmt.Sprintf("Volume %v has no backup %v.", name, restore.Backup), "backup")), nil
+	} else if len(backups) == 0 {
+		return errorhandler(NewAPIUserInputError(fmt.Sprintf("Volume %v has no backups.", name), "backup")), nil
+	}
+
+	if backup, err := container.RestoreNamedVolume(db, cfg, name, restore.Backup); err != nil {
+		return errorhandler(NewSystemError(fmt.Sprintf("Unable to restore volume %v, error %v", name, err))), nil
+	} else {
+		return false, backup
+	}
+}
+
+func checkDeclaredVolume(name string, errorhandler ErrorHandler, db *bolt.DB) bool {
+	if cv, err := persistence.FindUndeletedContainerVolume(db, name); err != nil {
+		return errorhandler(NewSystemError(fmt.Sprintf("Unable to read volume %v, error %v", name, err)))
+	} else if cv == nil || !cv.IsDeclared() {
+		return errorhandler(NewNotFoundError(fmt.Sprintf("Volume %v is not a named volume of a service on this node.", name), "name"))
+	}
+	return false
+}
@@ -3,6 +3,7 @@ package apicommon
 import (
 	"runtime"
 	"sync"
+	"time"
 
 	"github.com/golang/glog"
 	"github.com/open-horizon/anax/config"
@@ -12,13 +13,60 @@ import (
 )
 
 type Configuration struct {
-	ExchangeAPI     string `json:"exchange_api"`
-	ExchangeVersion string `json:"exchange_version"`
-	MinExchVersion  string `json:"required_minimum_exchange_version"`
-	PrefExchVersion string `json:"preferred_exchange_version"`
-	MMSAPI          string `json:"mms_api"`
-	Arch            string `json:"architecture"`
-	HorizonVersion  string `json:"horizon_version"`
+	ExchangeAPI     string      `json:"exchange_api"`
+	ExchangeVersion string      `json:"exchange_version"`
+	MinExchVersion  string      `json:"required_minimum_exchange_version"`
+	PrefExchVersion string      `json:"preferred_exchange_version"`
+	MMSAPI          string      `json:"mms_api"`
+	Arch            string      `json:"architecture"`
+	HorizonVersion  string      `json:"horizon_version"`
+	Proxy           *Proxy      `json:"proxy,omitempty"`
+	ClientCert      *ClientCert `json:"exchange_client_cert,omitempty"`
+}
+
+// The client certificate that is presented to the exchange.
+type ClientCert struct {
+	Org      string `json:"organization"`
+	Id       string `json:"id"`
+	NotAfter string `json:"not_after"`
+}
+
+// Returns nil if there is no client certificate configured.
+func NewClientCert(clientCert *config.ClientCertificate) *ClientCert {
+	if clientCert == nil {
+		return nil
+	}
+	cc := &ClientCert{
+		NotAfter: clientCert.NotAfter().Format(time.RFC3339),
+	}
+	if org, id, err := clientCert.Identity(); err != nil {
+		glog.Errorf("Failed to get exchange client certificate identity: %v", err)
+	} else {
+		cc.Org = org
+		cc.Id = id
+	}
+	return cc
+}
+
+// The proxy configuration of the node. The proxy credentials are never returned.
+type Proxy struct {
+	URL           string   `json:"url"`
+	Authenticated bool     `json:"authenticated"`
+	NoProxy       []string `json:"no_proxy,omitempty"`
+	CACertsPath   string   `json:"ca_certs_path,omitempty"`
+}
+
+// Returns nil if there is no proxy configured.
+func NewProxy(proxyConfig *config.ProxyConfig) *Proxy {
+	if !proxyConfig.IsConfigured() {
+		return nil
+	}
+	return &Proxy{
+		URL:           proxyConfig.URL,
+		Authenticated: proxyConfig.CredentialsFile != "",
+		NoProxy:       proxyConfig.NoProxy,
+		CACertsPath:   proxyConfig.CACertsPath,
+	}
 }
 
 // These fields are filled in by the API specific code, not the common code.
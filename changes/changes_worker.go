@@ -142,6 +142,12 @@ func (w *ChangesWorker) NewEvent(incoming events.Message) {
 		msg, _ := incoming.(*events.EdgeRegisteredExchangeMessage)
 		w.Commands <- NewDeviceRegisteredCommand(msg)
 
+	case *events.NodeTokenRotatedMessage:
+		msg, _ := incoming.(*events.NodeTokenRotatedMessage)
+		if w.EC != nil {
+			w.EC = w.EC.WithToken(msg.Token())
+		}
+
 	case *events.AgreementReachedMessage:
 		w.Commands <- NewAgreementCommand()
 
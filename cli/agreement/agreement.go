@@ -19,6 +19,34 @@ type ActiveAgreement struct {
 	AgreementDataReceivedTime   string                   `json:"agreement_data_received_time"`
 	AgreementProtocol           string                   `json:"agreement_protocol"` // the agreement protocol being used. It is also in the proposal.
 	Workload                    persistence.WorkloadInfo `json:"workload_to_run"`
+	ClusterStatus               *ClusterStatus           `json:"cluster_status,omitempty"` // the status of the workload on a cluster node
+}
+
+// The status of the workload of a cluster agreement, with a readable update time.
+type ClusterStatus struct {
+	Phase           string                         `json:"phase,omitempty"`
+	Conditions      []persistence.ClusterCondition `json:"conditions,omitempty"`
+	ReadyReplicas   int64                          `json:"ready_replicas"`
+	DesiredReplicas int64                          `json:"desired_replicas"`
+	LastError       string                         `json:"last_error,omitempty"`
+	Failed          bool                           `json:"failed"`
+	UpdateTime      string                         `json:"update_time"`
+}
+
+// NewClusterStatus returns the cluster status of an agreement for output, or nil if the agreement does not have one.
+func NewClusterStatus(status *persistence.ClusterWorkloadStatus) *ClusterStatus {
+	if status == nil {
+		return nil
+	}
+	return &ClusterStatus{
+		Phase:           status.Phase,
+		Conditions:      status.Conditions,
+		ReadyReplicas:   status.ReadyReplicas,
+		DesiredReplicas: status.DesiredReplicas,
+		LastError:       status.LastError,
+		Failed:          status.Failed,
+		UpdateTime:      cliutils.ConvertTime(status.UpdateTime),
+	}
 }
 
 // CopyAgreementInto copies the agreement info into our output struct
@@ -37,6 +65,7 @@ func (a *ActiveAgreement) CopyAgreementInto(agreement persistence.EstablishedAgr
 	a.AgreementProtocol = agreement.AgreementProtocol
 
 	a.Workload = agreement.RunningWorkload
+	a.ClusterStatus = NewClusterStatus(agreement.ClusterStatus)
 }
 
 type ArchivedAgreement struct {
@@ -54,6 +83,8 @@ type ArchivedAgreement struct {
 	AgreementTerminatedTime string `json:"agreement_terminated_time"`
 	TerminatedReason        uint64 `json:"terminated_reason"`      // the reason that the agreement was terminated
 	TerminatedDescription   string `json:"terminated_description"` // a string form of the reason that the agreement was terminated
+
+	ClusterStatus *ClusterStatus `json:"cluster_status,omitempty"` // the last status of the workload on a cluster node
 }
 
 // CopyAgreementInto copies the agreement info into our output struct
@@ -73,6 +104,7 @@ func (a *ArchivedAgreement) CopyAgreementInto(agreement persistence.EstablishedA
 	a.AgreementTerminatedTime = cliutils.ConvertTime(agreement.AgreementTerminatedTime)
 	a.TerminatedReason = agreement.TerminatedReason
 	a.TerminatedDescription = agreement.TerminatedDescription
+	a.ClusterStatus = NewClusterStatus(agreement.ClusterStatus)
 }
 
 func GetAgreements(archivedAgreements bool) (apiAgreements []persistence.EstablishedAgreement) {
@@ -35,7 +35,7 @@ func readUserInputFile(filePath string, inputFileStruct *[]policy.UserInput) {
 // check if the user inputs for services are compatible
 func UserInputCompatible(org string, userPw string, nodeId string, nodeArch string, nodeType string, nodeUIFile string,
 	businessPolId string, businessPolFile string, patternId string, patternFile string,
-	svcDefFiles []string, checkAllSvcs bool, showDetail bool) {
+	svcDefFiles []string, showEffective bool, checkAllSvcs bool, showDetail bool) {
 
 	msgPrinter := i18n.GetMessagePrinter()
 
@@ -49,6 +49,7 @@ func UserInputCompatible(org string, userPw string, nodeId string, nodeArch stri
 	uiCheckInput.BusinessPolicy = bp
 	uiCheckInput.PatternId = patternId
 	uiCheckInput.Pattern = pattern
+	uiCheckInput.Effective = showEffective
 
 	// use the user org for the patternId if it does not include an org id
 	if uiCheckInput.PatternId != "" {
@@ -326,20 +326,33 @@ func ValidateService(directory string, fInfo os.FileInfo, userInputs *register.I
 }
 
 func validateDependencyUserInputs(d common.AbstractServiceFile, uis []exchange.UserInput, configUserInputs []register.MicroWork, userInputsFilePath string) error {
+	given := func(name string) (interface{}, bool) {
+		for _, msUI := range configUserInputs {
+			if d.GetURL() == msUI.Url && (d.GetOrg() == "" || msUI.Org == "" || d.GetOrg() == msUI.Org) {
+				if value, ok := msUI.Variables[name]; ok {
+					return value, true
+				}
+			}
+		}
+		return nil, false
+	}
 	for _, ui := range uis {
-		if ui.DefaultValue == "" {
-			found := false
-			for _, msUI := range configUserInputs {
-				if d.GetURL() == msUI.Url && (d.GetOrg() == "" || msUI.Org == "" || d.GetOrg() == msUI.Org) {
-					if _, ok := msUI.Variables[ui.Name]; ok {
-						found = true
-						break
+		found := false
+		for _, msUI := range configUserInputs {
+			if d.GetURL() == msUI.Url && (d.GetOrg() == "" || msUI.Org == "" || d.GetOrg() == msUI.Org) {
+				if value, ok := msUI.Variables[ui.Name]; ok {
+					found = true
+					if ui.Type != "" {
+						if err := ui.VerifyValue(value); err != nil {
+							return errors.New(i18n.GetMessagePrinter().Sprintf("variable %v in %v is not valid: %v", ui.Name, userInputsFilePath, err))
+						}
 					}
+					break
 				}
 			}
-			if !found {
-				return errors.New(i18n.GetMessagePrinter().Sprintf("variable %v has no default and must be specified in %v", ui.Name, userInputsFilePath))
-			}
+		}
+		if !found && ui.IsRequired(uis, given) {
+			return errors.New(i18n.GetMessagePrinter().Sprintf("variable %v must be specified in %v, %v", ui.Name, userInputsFilePath, ui.RequiredReason()))
 		}
 	}
 	return nil
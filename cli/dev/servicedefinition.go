@@ -121,6 +121,8 @@ func ValidateServiceDefinition(directory string, fileName string) error {
 		for ix, ui := range sDef.UserInputs {
 			if (ui.Name != "" && ui.Type == "") || (ui.Name == "" && (ui.Type != "" || ui.DefaultValue != "")) {
 				return errors.New(msgPrinter.Sprintf("%v: userInput array index %v does not have name and type specified.", filePath, ix))
+			} else if err := ui.Validate(); err != nil {
+				return errors.New(msgPrinter.Sprintf("%v: userInput array index %v is not valid: %v", filePath, ix, err))
 			}
 		}
 	}
@@ -101,15 +101,24 @@ func UserInputExists(directory string) (bool, error) {
 }
 
 // Convert default user inputs to environment variables in a map. The input map is modified
-// by this function. If a variable is already in the input map, it is not modified.
-func AddDefaultUserInputs(uis []exchange.UserInput, envmap map[string]string) {
+// by this function. If a variable is already in the input map, it is not modified. The templates
+// in the default values are expanded with the given template data.
+func AddDefaultUserInputs(uis []exchange.UserInput, envmap map[string]string, tdata *cutil.TemplateData) error {
+	// get message printer
+	msgPrinter := i18n.GetMessagePrinter()
+
 	for _, ui := range uis {
 		if ui.Name != "" && ui.DefaultValue != "" {
 			if _, ok := envmap[ui.Name]; !ok {
-				envmap[ui.Name] = ui.DefaultValue
+				if value, err := cutil.ExpandTemplate(ui.DefaultValue, tdata); err != nil {
+					return errors.New(msgPrinter.Sprintf("unable to set the default value of %v: %v", ui.Name, err))
+				} else {
+					envmap[ui.Name] = value
+				}
 			}
 		}
 	}
+	return nil
 }
 
 // Convert user input variables and values (for a service) to environment variables and add them to an env var map.
@@ -196,7 +205,7 @@ func ValidateUserInput(i *register.InputFile, directory string, originalUserInpu
 			if ms.Url == sDef.URL {
 				foundDefinitionTuple = true
 				// For every variable that is set in the userinput file, make sure that variable is defined in the service definition.
-				if err := validateConfiguredVariables(ms.Variables, sDef.DefinesVariable); err != nil {
+				if err := validateConfiguredVariables(ms.Variables, sDef.DefinedUserInput); err != nil {
 					return errors.New(msgPrinter.Sprintf("%v: services array element at index %v is %v %v", originalUserInputFilePath, ix, ms, err))
 				}
 				// For every variable that is defined without a default, make sure it is set.
@@ -240,14 +249,19 @@ func validateServiceTuple(org string, vers string, url string) error {
 	return nil
 }
 
-func validateConfiguredVariables(variables map[string]interface{}, definesVar func(varName string) string) error {
+func validateConfiguredVariables(variables map[string]interface{}, definedUserInput func(varName string) *exchange.UserInput) error {
 	// get message printer
 	msgPrinter := i18n.GetMessagePrinter()
 
 	for varName, varValue := range variables {
-		if expectedType := definesVar(varName); expectedType != "" {
-			if err := cutil.VerifyWorkloadVarTypes(varValue, expectedType); err != nil {
+		if ui := definedUserInput(varName); ui != nil {
+			if err := cutil.VerifyWorkloadVarTypes(varValue, ui.Type); err != nil {
+				if ui.IsSensitive() {
+					return errors.New(msgPrinter.Sprintf("sets sensitive variable %v using a value of the wrong type, expecting %v.", varName, ui.Type))
+				}
 				return errors.New(msgPrinter.Sprintf("sets variable %v using a value of %v.", varName, err))
+			} else if err := ui.InputConstraints.Verify(varValue, ui.Type); err != nil {
+				return errors.New(msgPrinter.Sprintf("sets variable %v to a value that does not satisfy the constraints in the service definition: %v", varName, err))
 			}
 		} else {
 			return errors.New(msgPrinter.Sprintf("sets variable %v of type %T that is not defined.", varName, varValue))
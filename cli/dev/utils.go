@@ -301,6 +301,7 @@ func createEnvVarMap(agreementId string,
 	configVar map[string]interface{},
 	defaultVar []exchange.UserInput,
 	org string,
+	tdata *cutil.TemplateData,
 	cw *container.ContainerWorker,
 	attrConverter func(attributes []persistence.Attribute,
 		envvars map[string]string,
@@ -372,7 +373,9 @@ func createEnvVarMap(agreementId string,
 	// Last, now that the system and attribute based env vars are in place, we can convert the workload defined variables to env
 	// vars and add them into the env var map.
 	// Add in default variables from the workload definition.
-	AddDefaultUserInputs(defaultVar, envvars)
+	if err := AddDefaultUserInputs(defaultVar, envvars, tdata); err != nil {
+		return nil, err
+	}
 
 	// Then add in the configured variable values from the workload section of the user input file.
 	if err := AddConfiguredUserInputs(configVar, envvars); err != nil {
@@ -393,6 +396,7 @@ func createContainerWorker() (*container.ContainerWorker, error) {
 		Edge: config.Config{
 			ServiceStorage:                workloadStorageDir,
 			DefaultServiceRegistrationRAM: 0,
+			SecretFilesPath:               path.Join(GetDevWorkingDirectory(), "secrets"),
 			FileSyncService: config.FSSConfig{
 				AuthenticationPath: path.Join(GetDevWorkingDirectory(), "auth"),
 				APIListen:          path.Join(GetDevWorkingDirectory(), "essapi.sock"),
@@ -594,11 +598,26 @@ func StartContainers(deployment *containermessage.DeploymentDescription,
 	configVars := getConfiguredVariables(configUserInputs, specRef)
 
 	// Now that we have the configured variables, turn everything into environment variables for the container.
-	environmentAdditions, enverr := createEnvVarMap(agId, wlpw, globals, specRef, configVars, defUserInputs, org, cw, persistence.AttributesToEnvvarMap)
+	tdata := devTemplateData(agId, specRef, org, version)
+	environmentAdditions, enverr := createEnvVarMap(agId, wlpw, globals, specRef, configVars, defUserInputs, org, tdata, cw, persistence.AttributesToEnvvarMap)
 	if enverr != nil {
 		return nil, errors.New(msgPrinter.Sprintf("unable to create environment variables"))
 	}
 
+	// Expand the node and agreement values that the deployment refers to, the same way the agent does.
+	if err := deployment.ExpandTemplates(tdata); err != nil {
+		return nil, errors.New(msgPrinter.Sprintf("unable to expand the templates in %v, error: %v", dc.CLIString(), err))
+	}
+
+	// Secrets are given to the container as files, the same way the agent gives them.
+	secrets := make(map[string]string)
+	for _, ui := range defUserInputs {
+		if value, ok := environmentAdditions[ui.Name]; ok && ui.IsSecret() {
+			secrets[ui.Name] = value
+			delete(environmentAdditions, ui.Name)
+		}
+	}
+
 	cliutils.Verbose(msgPrinter.Sprintf("Passing environment variables: %v", environmentAdditions))
 
 	// Start the dpendent service
@@ -607,7 +626,7 @@ func StartContainers(deployment *containermessage.DeploymentDescription,
 	msgPrinter.Println()
 
 	// Start the dependent service container.
-	_, startErr := cw.ResourcesCreate(id, "", nil, deployment, []byte(""), environmentAdditions, msNetworks, cutil.FormOrgSpecUrl(cutil.NormalizeURL(specRef), org), "")
+	_, startErr := cw.ResourcesCreate(id, "", nil, deployment, []byte(""), environmentAdditions, secrets, msNetworks, cutil.FormOrgSpecUrl(cutil.NormalizeURL(specRef), org), "")
 	if startErr != nil {
 		return nil, errors.New(msgPrinter.Sprintf("unable to start container using %v, error: %v", dc.CLIString(), startErr))
 	}
@@ -619,6 +638,37 @@ func StartContainers(deployment *containermessage.DeploymentDescription,
 	return getContainerNetworks(dc, cw)
 }
 
+// Returns the environment variables that the agent gives a top-level service. This is for deployment types that run
+// the service themselves rather than in a container.
+func ServiceEnvVarMap(agreementId string, serviceDef *common.ServiceFile, userInputs *register.InputFile, cw *container.ContainerWorker) (map[string]string, error) {
+	configVars := getConfiguredVariables(userInputs.Services, serviceDef.URL)
+	tdata := devTemplateData(agreementId, serviceDef.URL, serviceDef.Org, serviceDef.Version)
+	return createEnvVarMap(agreementId, "deprecated", userInputs.Global, serviceDef.URL, configVars, serviceDef.UserInputs, serviceDef.Org, tdata, cw, persistence.AttributesToEnvvarMap)
+}
+
+// Returns the node and agreement values that the templates in the deployment and in the default user input refer to.
+// The properties come from the node policy of the local agent, if there is one.
+func devTemplateData(agreementId string, url string, org string, version string) *cutil.TemplateData {
+	nodePolicy := externalpolicy.ExternalPolicy{}
+	cliutils.HorizonGet("node/policy", []int{200}, &nodePolicy, true)
+
+	props := make(map[string]interface{})
+	for _, prop := range nodePolicy.Properties {
+		props[prop.Name] = prop.Value
+	}
+
+	return &cutil.TemplateData{
+		NodeId:         GetNodeId(),
+		NodeOrg:        org,
+		Pattern:        os.Getenv(DEVTOOL_HZN_PATTERN),
+		AgreementId:    agreementId,
+		ServiceURL:     url,
+		ServiceOrg:     org,
+		ServiceVersion: version,
+		Properties:     props,
+	}
+}
+
 func ProcessStopDependencies(dir string, deps []*common.ServiceFile, cw *container.ContainerWorker) error {
 
 	// Log the stopping of dependencies if there are any.
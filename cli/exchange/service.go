@@ -171,6 +171,15 @@ func SignAndPublish(sf *common.ServiceFile, org, userPw, jsonFilePath, keyFilePa
 	// get message printer
 	msgPrinter := i18n.GetMessagePrinter()
 
+	// The secret user inputs are given to the containers as files, a process or wasm deployment has no place for them.
+	if dep, ok := sf.Deployment.(map[string]interface{}); ok && (persistence.IsProcess(dep) || persistence.IsWasm(dep)) {
+		for _, ui := range sf.UserInputs {
+			if ui.IsSecret() {
+				cliutils.Fatal(cliutils.CLI_INPUT_ERROR, msgPrinter.Sprintf("user input %v is a secret, a process or wasm deployment cannot use secrets", ui.Name))
+			}
+		}
+	}
+
 	svcInput := exchange.ServiceDefinition{Label: sf.Label, Description: sf.Description, Public: sf.Public, Documentation: sf.Documentation, URL: sf.URL, Version: sf.Version, Arch: sf.Arch, Sharable: sf.Sharable, MatchHardware: sf.MatchHardware, RequiredServices: sf.RequiredServices, UserInputs: sf.UserInputs}
 
 	baseDir := filepath.Dir(jsonFilePath)
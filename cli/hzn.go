@@ -19,10 +19,12 @@ import (
 	_ "github.com/open-horizon/anax/cli/i18n_messages"
 	"github.com/open-horizon/anax/cli/key"
 	"github.com/open-horizon/anax/cli/kube_deployment"
+	"github.com/open-horizon/anax/cli/manifest_deployment"
 	"github.com/open-horizon/anax/cli/metering"
 	_ "github.com/open-horizon/anax/cli/native_deployment"
 	"github.com/open-horizon/anax/cli/node"
 	"github.com/open-horizon/anax/cli/policy"
+	"github.com/open-horizon/anax/cli/process_deployment"
 	"github.com/open-horizon/anax/cli/register"
 	"github.com/open-horizon/anax/cli/service"
 	"github.com/open-horizon/anax/cli/status"
@@ -30,6 +32,7 @@ import (
 	"github.com/open-horizon/anax/cli/unregister"
 	"github.com/open-horizon/anax/cli/userinput"
 	"github.com/open-horizon/anax/cli/utilcmds"
+	"github.com/open-horizon/anax/cli/wasm_deployment"
 	"github.com/open-horizon/anax/cutil"
 	"github.com/open-horizon/anax/i18n"
 	"gopkg.in/alecthomas/kingpin.v2"
@@ -389,6 +392,10 @@ Environment Variables:
 	policyPatchInput := policyPatchCmd.Arg("patch", msgPrinter.Sprintf("The new constraints or properties in the format '%s' or '%s'.", "{\"constraints\":[<constraint list>]}", "{\"properties\":[<property list>]}")).Required().String()
 	policyRemoveCmd := policyCmd.Command("remove", msgPrinter.Sprintf("Remove the node's policy."))
 	policyRemoveForce := policyRemoveCmd.Flag("force", msgPrinter.Sprintf("Skip the 'are you sure?' prompt.")).Short('f').Bool()
+	policyHistoryCmd := policyCmd.Command("history", msgPrinter.Sprintf("Display the previous versions of the node's policy, the oldest first. The read-only built-in properties are not part of the versions."))
+	policyRollbackCmd := policyCmd.Command("rollback", msgPrinter.Sprintf("Restore a previous version of the node's policy. The node's built-in properties are added to the restored policy."))
+	policyRollbackVersion := policyRollbackCmd.Flag("version", msgPrinter.Sprintf("The version to restore, as shown by 'hzn policy history'. The default is the version before the current one.")).Uint64()
+	policyRollbackForce := policyRollbackCmd.Flag("force", msgPrinter.Sprintf("Skip the 'are you sure?' prompt.")).Short('f').Bool()
 
 	deploycheckCmd := app.Command("deploycheck", msgPrinter.Sprintf("Check deployment compatibility."))
 	deploycheckOrg := deploycheckCmd.Flag("org", msgPrinter.Sprintf("The Horizon exchange organization ID. If not specified, HZN_ORG_ID will be used as a default.")).Short('o').String()
@@ -417,6 +424,7 @@ Environment Variables:
 	userinputCompDepPolFile := userinputCompCmd.Flag("deployment-pol", msgPrinter.Sprintf("The JSON input file name containing the deployment policy. Mutually exclusive with -b, -p and -P.")).Short('B').String()
 	userinputCompSvcFile := userinputCompCmd.Flag("service", msgPrinter.Sprintf("(optional) The JSON input file name containing the service definition. If omitted, the service defined in the deployment policy or pattern will be retrieved from the Exchange. This flag can be repeated to specify different versions of the service.")).Strings()
 	userinputCompPatternId := userinputCompCmd.Flag("pattern-id", msgPrinter.Sprintf("The Horizon exchange pattern ID. Mutually exclusive with -P, -b and -B. If you don't prepend it with the organization id, it will automatically be prepended with the node's organization id.")).Short('p').String()
+	userinputCompEffective := userinputCompCmd.Flag("effective", msgPrinter.Sprintf("Show the user inputs that each checked service receives, merged from the service definition, the deployment policy or pattern and the node user inputs, with the source of each value.")).Bool()
 	userinputCompPatternFile := userinputCompCmd.Flag("pattern", msgPrinter.Sprintf("The JSON input file name containing the pattern. Mutually exclusive with -p, -b and -B.")).Short('P').String()
 	allCompCmd := deploycheckCmd.Command("all", msgPrinter.Sprintf("Check all compatibilities for a deployment."))
 	allCompNodeArch := allCompCmd.Flag("arch", msgPrinter.Sprintf("The architecture of the node. It is required when -n is not specified. If omitted, the service of all the architectures referenced in the deployment policy or pattern will be checked for compatibility.")).Short('a').String()
@@ -457,6 +465,13 @@ Environment Variables:
 	userinputUpdateFilePath := userinputUpdateCmd.Flag("file-path", msgPrinter.Sprintf("The file path to the json file with the updated user input object. Specify -f- to read from stdin.")).Short('f').Required().String()
 	userinputRemoveCmd := userinputCmd.Command("remove", msgPrinter.Sprintf("Remove the user inputs that are currently registered on this Horizon edge node."))
 	userinputRemoveForce := userinputRemoveCmd.Flag("force", msgPrinter.Sprintf("Skip the 'Are you sure?' prompt.")).Short('f').Bool()
+	userinputHistoryCmd := userinputCmd.Command("history", msgPrinter.Sprintf("Display the previous versions of the user inputs of this Horizon edge node, the oldest first."))
+	userinputEffectiveCmd := userinputCmd.Command("effective", msgPrinter.Sprintf("Display the user inputs that the services on this Horizon edge node receive, merged from the service definition, the pattern or deployment policy and the node user inputs, with the source of each value."))
+	userinputEffectiveService := userinputEffectiveCmd.Arg("service", msgPrinter.Sprintf("The URL of the service. If omitted, all the services on this node are displayed.")).String()
+	userinputEffectiveOrg := userinputEffectiveCmd.Flag("org", msgPrinter.Sprintf("The organization of the service. If omitted, the services with the URL in all organizations are displayed.")).String()
+	userinputRollbackCmd := userinputCmd.Command("rollback", msgPrinter.Sprintf("Restore a previous version of the user inputs of this Horizon edge node. The services whose user inputs change are restarted."))
+	userinputRollbackVersion := userinputRollbackCmd.Flag("version", msgPrinter.Sprintf("The version to restore, as shown by 'hzn userinput history'. The default is the version before the current one.")).Uint64()
+	userinputRollbackForce := userinputRollbackCmd.Flag("force", msgPrinter.Sprintf("Skip the 'Are you sure?' prompt.")).Short('f').Bool()
 
 	serviceCmd := app.Command("service", msgPrinter.Sprintf("List or manage the services that are currently registered on this Horizon edge node."))
 	serviceLogCmd := serviceCmd.Command("log", msgPrinter.Sprintf("Show the container logs for a service."))
@@ -475,6 +490,14 @@ Environment Variables:
 	resumeAllServices := serviceConfigStateActiveCmd.Flag("all", msgPrinter.Sprintf("Resume all registerd services.")).Short('a').Bool()
 	resumeServiceOrg := serviceConfigStateActiveCmd.Arg("serviceorg", msgPrinter.Sprintf("The organization of the service that should be resumed.")).String()
 	resumeServiceName := serviceConfigStateActiveCmd.Arg("service", msgPrinter.Sprintf("The name of the service that should be resumed.")).String()
+	serviceVolumeCmd := serviceCmd.Command("volume", msgPrinter.Sprintf("List, back up or restore the named volumes of the services on this Horizon edge node."))
+	serviceVolumeListCmd := serviceVolumeCmd.Command("list", msgPrinter.Sprintf("List the named volumes of the services on this Horizon edge node and their backups."))
+	serviceVolumeBackupCmd := serviceVolumeCmd.Command("backup", msgPrinter.Sprintf("Back up the content of a named volume into a tarball on this Horizon edge node."))
+	serviceVolumeBackupName := serviceVolumeBackupCmd.Arg("volume", msgPrinter.Sprintf("The name of the volume.")).Required().String()
+	serviceVolumeRestoreCmd := serviceVolumeCmd.Command("restore", msgPrinter.Sprintf("Replace the content of a named volume with one of its backups. The service that uses the volume must not be running."))
+	serviceVolumeRestoreName := serviceVolumeRestoreCmd.Arg("volume", msgPrinter.Sprintf("The name of the volume.")).Required().String()
+	serviceVolumeRestoreBackup := serviceVolumeRestoreCmd.Flag("backup", msgPrinter.Sprintf("The id of the backup, as shown by 'hzn service volume list'. The default is the latest backup.")).Short('b').String()
+	serviceVolumeRestoreForce := serviceVolumeRestoreCmd.Flag("force", msgPrinter.Sprintf("Skip the 'Are you sure?' prompt.")).Short('f').Bool()
 
 	unregisterCmd := app.Command("unregister", msgPrinter.Sprintf("Unregister and reset this Horizon edge node so that it is ready to be registered again. Warning: this will stop all the Horizon services running on this edge node, and restart the Horizon agent."))
 
@@ -503,11 +526,11 @@ Environment Variables:
 	devServiceNewCmdOrg := devServiceNewCmd.Flag("org", msgPrinter.Sprintf("The Org id that the service is defined within. If this flag is omitted, the HZN_ORG_ID environment variable is used.")).Short('o').String()
 	devServiceNewCmdName := devServiceNewCmd.Flag("specRef", msgPrinter.Sprintf("The name of the service. If this flag and the -i flag are omitted, only the skeletal horizon metadata files will be generated.")).Short('s').String()
 	devServiceNewCmdVer := devServiceNewCmd.Flag("ver", msgPrinter.Sprintf("The version of the service. If this flag is omitted, '0.0.1' is used.")).Short('V').String()
-	devServiceNewCmdImage := devServiceNewCmd.Flag("image", msgPrinter.Sprintf("The docker container image base name without the version tag for the service. This command will add arch and version to the base name to form the final image name. The format is 'basename_arch:serviceversion'. This flag can be repeated to specify multiple images when '--noImageGen' flag is specified. This flag is ignored for the '--dconfig %v' deployment configuration.", kube_deployment.KUBE_DEPLOYMENT_CONFIG_TYPE)).Short('i').Strings()
+	devServiceNewCmdImage := devServiceNewCmd.Flag("image", msgPrinter.Sprintf("The docker container image base name without the version tag for the service. This command will add arch and version to the base name to form the final image name. The format is 'basename_arch:serviceversion'. This flag can be repeated to specify multiple images when '--noImageGen' flag is specified. This flag is ignored for the '--dconfig %v', '--dconfig %v', '--dconfig %v' and '--dconfig %v' deployment configurations.", kube_deployment.KUBE_DEPLOYMENT_CONFIG_TYPE, manifest_deployment.MANIFEST_DEPLOYMENT_CONFIG_TYPE, process_deployment.PROCESS_DEPLOYMENT_CONFIG_TYPE, wasm_deployment.WASM_DEPLOYMENT_CONFIG_TYPE)).Short('i').Strings()
 	devServiceNewCmdNoImageGen := devServiceNewCmd.Flag("noImageGen", msgPrinter.Sprintf("Indicates that the image is built somewhere else. No image sample code will be created by this command. If this flag is not specified, files for generating a simple service image will be created under current directory.")).Bool()
 	devServiceNewCmdNoPattern := devServiceNewCmd.Flag("noPattern", msgPrinter.Sprintf("Indicates no pattern definition file will be created.")).Bool()
 	devServiceNewCmdNoPolicy := devServiceNewCmd.Flag("noPolicy", msgPrinter.Sprintf("Indicate no policy file will be created.")).Bool()
-	devServiceNewCmdCfg := devServiceNewCmd.Flag("dconfig", msgPrinter.Sprintf("Indicates the type of deployment configuration that will be used, native (the default), or %v. This flag can be specified more than once to create a service with more than 1 kind of deployment configuration.", kube_deployment.KUBE_DEPLOYMENT_CONFIG_TYPE)).Short('c').Default("native").Strings()
+	devServiceNewCmdCfg := devServiceNewCmd.Flag("dconfig", msgPrinter.Sprintf("Indicates the type of deployment configuration that will be used, native (the default), %v, %v for a cluster service that is a set of Kubernetes manifests or a kustomization, %v for a service that runs as a process on the host without a container runtime, or %v for a service that is a WebAssembly module. This flag can be specified more than once to create a service with more than 1 kind of deployment configuration.", kube_deployment.KUBE_DEPLOYMENT_CONFIG_TYPE, manifest_deployment.MANIFEST_DEPLOYMENT_CONFIG_TYPE, process_deployment.PROCESS_DEPLOYMENT_CONFIG_TYPE, wasm_deployment.WASM_DEPLOYMENT_CONFIG_TYPE)).Short('c').Default("native").Strings()
 	devServiceStartTestCmd := devServiceCmd.Command("start", msgPrinter.Sprintf("Run a service in a mocked Horizon Agent environment. This command is not supported for services using the %v deployment configuration.", kube_deployment.KUBE_DEPLOYMENT_CONFIG_TYPE))
 	devServiceUserInputFile := devServiceStartTestCmd.Flag("userInputFile", msgPrinter.Sprintf("File containing user input values for running a test. If omitted, the userinput file for the project will be used.")).Short('f').String()
 	devServiceConfigFile := devServiceStartTestCmd.Flag("configFile", msgPrinter.Sprintf("File to be made available through the sync service APIs. This flag can be repeated to populate multiple files.")).Short('m').Strings()
@@ -908,10 +931,14 @@ Environment Variables:
 		policy.Patch(*policyPatchInput)
 	case policyRemoveCmd.FullCommand():
 		policy.Remove(*policyRemoveForce)
+	case policyHistoryCmd.FullCommand():
+		policy.History()
+	case policyRollbackCmd.FullCommand():
+		policy.Rollback(*policyRollbackVersion, *policyRollbackForce)
 	case policyCompCmd.FullCommand():
 		deploycheck.PolicyCompatible(*deploycheckOrg, *deploycheckUserPw, *policyCompNodeId, *policyCompNodeArch, *policyCompNodeType, *policyCompNodePolFile, *policyCompBPolId, *policyCompBPolFile, *policyCompSPolFile, *policyCompSvcFile, *deploycheckCheckAll, *deploycheckLong)
 	case userinputCompCmd.FullCommand():
-		deploycheck.UserInputCompatible(*deploycheckOrg, *deploycheckUserPw, *userinputCompNodeId, *userinputCompNodeArch, *userinputCompNodeType, *userinputCompNodeUIFile, *userinputCompBPolId, *userinputCompBPolFile, *userinputCompPatternId, *userinputCompPatternFile, *userinputCompSvcFile, *deploycheckCheckAll, *deploycheckLong)
+		deploycheck.UserInputCompatible(*deploycheckOrg, *deploycheckUserPw, *userinputCompNodeId, *userinputCompNodeArch, *userinputCompNodeType, *userinputCompNodeUIFile, *userinputCompBPolId, *userinputCompBPolFile, *userinputCompPatternId, *userinputCompPatternFile, *userinputCompSvcFile, *userinputCompEffective, *deploycheckCheckAll, *deploycheckLong)
 	case allCompCmd.FullCommand():
 		deploycheck.AllCompatible(*deploycheckOrg, *deploycheckUserPw, *allCompNodeId, *allCompNodeArch, *allCompNodeType, *allCompNodePolFile, *allCompNodeUIFile, *allCompBPolId, *allCompBPolFile, *allCompPatternId, *allCompPatternFile, *allCompSPolFile, *allCompSvcFile, *deploycheckCheckAll, *deploycheckLong)
 	case agreementListCmd.FullCommand():
@@ -932,6 +959,12 @@ Environment Variables:
 		userinput.Update(*userinputUpdateFilePath)
 	case userinputRemoveCmd.FullCommand():
 		userinput.Remove(*userinputRemoveForce)
+	case userinputHistoryCmd.FullCommand():
+		userinput.History()
+	case userinputEffectiveCmd.FullCommand():
+		userinput.Effective(*userinputEffectiveService, *userinputEffectiveOrg)
+	case userinputRollbackCmd.FullCommand():
+		userinput.Rollback(*userinputRollbackVersion, *userinputRollbackForce)
 	case serviceListCmd.FullCommand():
 		service.List()
 	case serviceLogCmd.FullCommand():
@@ -944,6 +977,12 @@ Environment Variables:
 		service.Suspend(*forceSuspendService, *suspendAllServices, *suspendServiceOrg, *suspendServiceName)
 	case serviceConfigStateActiveCmd.FullCommand():
 		service.Resume(*resumeAllServices, *resumeServiceOrg, *resumeServiceName)
+	case serviceVolumeListCmd.FullCommand():
+		service.VolumeList()
+	case serviceVolumeBackupCmd.FullCommand():
+		service.VolumeBackup(*serviceVolumeBackupName)
+	case serviceVolumeRestoreCmd.FullCommand():
+		service.VolumeRestore(*serviceVolumeRestoreName, *serviceVolumeRestoreBackup, *serviceVolumeRestoreForce)
 	case unregisterCmd.FullCommand():
 		unregister.DoIt(*forceUnregister, *removeNodeUnregister, *deepCleanUnregister, *timeoutUnregister)
 	case statusCmd.FullCommand():
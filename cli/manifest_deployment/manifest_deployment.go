@@ -0,0 +1,218 @@
+package manifest_deployment
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/open-horizon/anax/cli/cliutils"
+	"github.com/open-horizon/anax/cli/dev"
+	"github.com/open-horizon/anax/cli/plugin_registry"
+	"github.com/open-horizon/anax/i18n"
+	"github.com/open-horizon/rsapss-tool/sign"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+)
+
+const MANIFEST_DEPLOYMENT_CONFIG_TYPE = "manifest"
+
+func init() {
+	plugin_registry.Register(MANIFEST_DEPLOYMENT_CONFIG_TYPE, NewManifestDeploymentConfigPlugin())
+}
+
+type ManifestDeploymentConfigPlugin struct {
+}
+
+func NewManifestDeploymentConfigPlugin() plugin_registry.DeploymentConfigPlugin {
+	return new(ManifestDeploymentConfigPlugin)
+}
+
+func (p *ManifestDeploymentConfigPlugin) Sign(dep map[string]interface{}, keyFilePath string, ctx plugin_registry.PluginContext) (bool, string, string, error) {
+
+	// get message printer
+	msgPrinter := i18n.GetMessagePrinter()
+
+	if owned, err := p.Validate(nil, dep); !owned || err != nil {
+		return owned, "", "", err
+	}
+
+	// Grab the manifest archive or directory from the deployment config. It might be relative to the service
+	// definition file.
+	manifestPath := dep["manifestArchive"].(string)
+	if manifestPath = filepath.Clean(manifestPath); manifestPath == "." {
+		return true, "", "", errors.New(msgPrinter.Sprintf("cleaned %v resulted in an empty string.", dep["manifestArchive"].(string)))
+	}
+
+	if currentDir, ok := (ctx.Get("currentDir")).(string); !ok {
+		return true, "", "", errors.New(msgPrinter.Sprintf("plugin context must include 'currentDir' as the current directory of the service definition file"))
+	} else if !filepath.IsAbs(manifestPath) {
+		manifestPath = filepath.Join(currentDir, manifestPath)
+	}
+
+	// Get the base 64 encoding of the manifests, and put it into the deployment config.
+	if b64, err := ConvertPathToB64String(manifestPath); err != nil {
+		return true, "", "", errors.New(msgPrinter.Sprintf("unable to read manifests %v, error %v", dep["manifestArchive"], err))
+	} else {
+		dep["manifestArchive"] = b64
+	}
+
+	// Stringify and sign the deployment string.
+	deployment, err := json.Marshal(dep)
+	if err != nil {
+		return true, "", "", errors.New(msgPrinter.Sprintf("failed to marshal %v deployment string %v, error %v", MANIFEST_DEPLOYMENT_CONFIG_TYPE, dep, err))
+	}
+	depStr := string(deployment)
+
+	sig, err := sign.Input(keyFilePath, deployment)
+	if err != nil {
+		return true, "", "", errors.New(msgPrinter.Sprintf("problem signing %v deployment string with %s: %v", MANIFEST_DEPLOYMENT_CONFIG_TYPE, keyFilePath, err))
+	}
+
+	return true, depStr, sig, nil
+}
+
+func (p *ManifestDeploymentConfigPlugin) GetContainerImages(dep interface{}) (bool, []string, error) {
+	return false, []string{}, nil
+}
+
+// Return the default config object, which is nil in this case.
+func (p *ManifestDeploymentConfigPlugin) DefaultConfig(imageInfo interface{}) interface{} {
+	return nil
+}
+
+// Return the default cluster config object.
+func (p *ManifestDeploymentConfigPlugin) DefaultClusterConfig() interface{} {
+	return map[string]interface{}{
+		"manifestArchive": "",
+	}
+}
+
+func (p *ManifestDeploymentConfigPlugin) Validate(dep interface{}, cdep interface{}) (bool, error) {
+	// get message printer
+	msgPrinter := i18n.GetMessagePrinter()
+
+	// If there is a native deployment config, defer to that plugin.
+	if dep != nil {
+		return false, nil
+	}
+
+	if dc, ok := cdep.(map[string]interface{}); !ok {
+		return false, nil
+	} else if c, ok := dc["manifestArchive"]; !ok {
+		return false, nil
+	} else if ca, ok := c.(string); !ok {
+		return true, errors.New(msgPrinter.Sprintf("manifestArchive must have a string type value, has %T", c))
+	} else if len(ca) == 0 {
+		return true, errors.New(msgPrinter.Sprintf("manifestArchive must be non-empty strings"))
+	} else {
+		return true, nil
+	}
+}
+
+func (p *ManifestDeploymentConfigPlugin) StartTest(homeDirectory string, userInputFile string, configFiles []string, configType string, noFSS bool, userCreds string) bool {
+	return p.testNotSupported(homeDirectory, dev.SERVICE_START_COMMAND)
+}
+
+func (p *ManifestDeploymentConfigPlugin) StopTest(homeDirectory string) bool {
+	return p.testNotSupported(homeDirectory, dev.SERVICE_STOP_COMMAND)
+}
+
+// The services that are deployed with manifests cannot be run in the mocked agent environment. The plugin only claims
+// the services whose cluster deployment it owns.
+func (p *ManifestDeploymentConfigPlugin) testNotSupported(homeDirectory string, command string) bool {
+
+	// get message printer
+	msgPrinter := i18n.GetMessagePrinter()
+
+	// Perform the common execution setup.
+	dir, _, _ := dev.CommonExecutionSetup(homeDirectory, "", dev.SERVICE_COMMAND, command)
+
+	// Get the service definition, so that we can check if we own the deployment config object.
+	serviceDef, sderr := dev.GetServiceDefinition(dir, dev.SERVICE_DEFINITION_FILE)
+	if sderr != nil {
+		cliutils.Fatal(cliutils.CLI_GENERAL_ERROR, fmt.Sprintf("'%v %v' %v", dev.SERVICE_COMMAND, command, sderr))
+	}
+
+	if serviceDef.Deployment != nil {
+		return false
+	} else if owned, err := p.Validate(serviceDef.Deployment, serviceDef.ClusterDeployment); !owned || err != nil {
+		return false
+	}
+
+	cliutils.Fatal(cliutils.CLI_GENERAL_ERROR, msgPrinter.Sprintf("'%v %v' not supported for services using a %v deployment configuration", dev.SERVICE_COMMAND, command, MANIFEST_DEPLOYMENT_CONFIG_TYPE))
+	// For the compiler
+	return true
+}
+
+// Convert a tar.gz archive of manifests, or a directory of manifests that is archived first, into a base 64 encoded
+// string. The input filepath is assumed to be absolute.
+func ConvertPathToB64String(filePath string) (string, error) {
+
+	info, err := os.Stat(filePath)
+	if err != nil {
+		return "", err
+	}
+
+	var archive []byte
+	if info.IsDir() {
+		if archive, err = archiveDirectory(filePath); err != nil {
+			return "", err
+		}
+	} else if archive, err = ioutil.ReadFile(filePath); err != nil {
+		return "", err
+	}
+
+	return base64.StdEncoding.EncodeToString(archive), nil
+}
+
+// Tar and gzip the regular files in a directory. The names in the archive are relative to the directory, so that a
+// kustomization file in the directory is at the top of the archive.
+func archiveDirectory(dir string) ([]byte, error) {
+	var buf bytes.Buffer
+	zipWriter := gzip.NewWriter(&buf)
+	tarWriter := tar.NewWriter(zipWriter)
+
+	err := filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		} else if !info.Mode().IsRegular() {
+			return nil
+		}
+
+		rel, err := filepath.Rel(dir, path)
+		if err != nil {
+			return err
+		}
+		header, err := tar.FileInfoHeader(info, "")
+		if err != nil {
+			return err
+		}
+		header.Name = filepath.ToSlash(rel)
+		if err := tarWriter.WriteHeader(header); err != nil {
+			return err
+		}
+
+		f, err := os.Open(path)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		_, err = io.Copy(tarWriter, f)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if err := tarWriter.Close(); err != nil {
+		return nil, err
+	} else if err := zipWriter.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
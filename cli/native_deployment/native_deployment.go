@@ -159,11 +159,12 @@ func (p *NativeDeploymentConfigPlugin) Validate(dep interface{}, cdep interface{
 }
 
 // This can't be a const because a map literal isn't a const in go
-var VALID_DEPLOYMENT_FIELDS = map[string]int8{"image": 1, "privileged": 1, "cap_add": 1, "environment": 1, "devices": 1, "binds": 1, "specific_ports": 1, "command": 1, "ports": 1, "ephemeral_ports": 1, "tmpfs": 1, "network": 1}
+var VALID_DEPLOYMENT_FIELDS = map[string]int8{"image": 1, "privileged": 1, "cap_add": 1, "environment": 1, "devices": 1, "binds": 1, "specific_ports": 1, "command": 1, "ports": 1, "ephemeral_ports": 1, "tmpfs": 1, "network": 1, "max_memory_mb": 1, "cap_drop": 1, "read_only_rootfs": 1, "no_new_privileges": 1, "user": 1, "seccomp_profile": 1, "apparmor_profile": 1, "network_isolation": 1, "readiness": 1, "mms_objects": 1, "volumes": 1}
 
 // CheckDeploymentService verifies it has the required 'image' key, and checks for keys we don't recognize.
 // For now it only prints a warning for unrecognized keys, in case we recently added a key to anax and haven't updated hzn yet.
-// It also checks for invalid use of the default anax port, and puts out a warning message.
+// It also checks for invalid use of the default anax port, and puts out a warning message, and validates the container
+// hardening options, network isolation, mms objects, volumes and readiness probe.
 func CheckDeploymentService(svcName string, depSvc map[string]interface{}) error {
 	// get message printer
 	msgPrinter := i18n.GetMessagePrinter()
@@ -197,6 +198,31 @@ func CheckDeploymentService(svcName string, depSvc map[string]interface{}) error
 			}
 		}
 	}
+
+	// Check the container hardening options with the same rules that the agent applies when it starts the container.
+	var svc containermessage.Service
+	if bytes, err := json.Marshal(depSvc); err != nil {
+		return errors.New(msgPrinter.Sprintf("service '%s' defined under 'deployment.services' could not be marshalled, error %v", svcName, err))
+	} else if err := json.Unmarshal(bytes, &svc); err != nil {
+		return errors.New(msgPrinter.Sprintf("service '%s' defined under 'deployment.services' has a malformed value, error %v", svcName, err))
+	} else if err := svc.ValidateSecurityOptions(); err != nil {
+		return errors.New(msgPrinter.Sprintf("service '%s' defined under 'deployment.services' has invalid security options, %v", svcName, err))
+	} else if err := svc.ValidateTemplates(); err != nil {
+		return errors.New(msgPrinter.Sprintf("service '%s' defined under 'deployment.services' has an invalid template, %v", svcName, err))
+	} else if err := svc.ValidateMMSObjects(); err != nil {
+		return errors.New(msgPrinter.Sprintf("service '%s' defined under 'deployment.services' has invalid mms objects, %v", svcName, err))
+	} else if err := svc.ValidateVolumes(); err != nil {
+		return errors.New(msgPrinter.Sprintf("service '%s' defined under 'deployment.services' has invalid volumes, %v", svcName, err))
+	} else if svc.NetworkIsolation != nil {
+		if err := svc.NetworkIsolation.Validate(); err != nil {
+			return errors.New(msgPrinter.Sprintf("service '%s' defined under 'deployment.services' has invalid network isolation, %v", svcName, err))
+		}
+	}
+	if svc.Readiness != nil {
+		if err := svc.Readiness.Validate(); err != nil {
+			return errors.New(msgPrinter.Sprintf("service '%s' defined under 'deployment.services' has an invalid readiness probe, %v", svcName, err))
+		}
+	}
 	return nil
 }
 
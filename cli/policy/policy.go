@@ -7,6 +7,7 @@ import (
 	"github.com/open-horizon/anax/cli/cliutils"
 	"github.com/open-horizon/anax/externalpolicy"
 	"github.com/open-horizon/anax/i18n"
+	"github.com/open-horizon/anax/persistence"
 	"net/http"
 )
 
@@ -108,3 +109,38 @@ func New() {
 		fmt.Println(s)
 	}
 }
+
+// Display the previous versions of the node policy, the oldest first.
+func History() {
+	var history []persistence.NodeConfigVersion
+	cliutils.HorizonGet("node/policy/history", []int{200}, &history, false)
+
+	output, err := cliutils.DisplayAsJson(history)
+	if err != nil {
+		cliutils.Fatal(cliutils.JSON_PARSING_ERROR, i18n.GetMessagePrinter().Sprintf("failed to marshal 'hzn policy history' output: %v", err))
+	}
+
+	fmt.Println(output)
+}
+
+// Restore a previous version of the node policy. Version 0 restores the version before the current one.
+func Rollback(version uint64, force bool) {
+	msgPrinter := i18n.GetMessagePrinter()
+
+	if !force {
+		if version == 0 {
+			cliutils.ConfirmRemove(msgPrinter.Sprintf("Are you sure you want to roll back the node policy to the previous version?"))
+		} else {
+			cliutils.ConfirmRemove(msgPrinter.Sprintf("Are you sure you want to roll back the node policy to version %v?", version))
+		}
+	}
+
+	_, respBody, _ := cliutils.HorizonPutPost(http.MethodPost, "node/policy/rollback", []int{200}, map[string]uint64{"version": version}, true)
+
+	var restored persistence.NodeConfigVersion
+	if err := json.Unmarshal([]byte(respBody), &restored); err != nil {
+		cliutils.Fatal(cliutils.JSON_PARSING_ERROR, msgPrinter.Sprintf("Unable to unmarshal the response %v: %v", respBody, err))
+	}
+	msgPrinter.Printf("Horizon node policy rolled back to version %v.", restored.Version)
+	msgPrinter.Println()
+}
@@ -0,0 +1,360 @@
+package process_deployment
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/open-horizon/anax/cli/cliutils"
+	"github.com/open-horizon/anax/cli/dev"
+	"github.com/open-horizon/anax/cli/plugin_registry"
+	"github.com/open-horizon/anax/cutil"
+	"github.com/open-horizon/anax/i18n"
+	"github.com/open-horizon/anax/persistence"
+	"github.com/open-horizon/anax/process"
+	"github.com/open-horizon/rsapss-tool/sign"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"os/exec"
+	"path"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+)
+
+const PROCESS_DEPLOYMENT_CONFIG_TYPE = "process"
+
+// The directory in the project where a service is run by 'hzn dev service start'.
+const PROCESS_TEST_DIR = ".process"
+
+// The file in the test directory that holds the process id of the running service.
+const PROCESS_PID_FILE = "process.pid"
+
+func init() {
+	plugin_registry.Register(PROCESS_DEPLOYMENT_CONFIG_TYPE, NewProcessDeploymentConfigPlugin())
+}
+
+type ProcessDeploymentConfigPlugin struct {
+}
+
+func NewProcessDeploymentConfigPlugin() plugin_registry.DeploymentConfigPlugin {
+	return new(ProcessDeploymentConfigPlugin)
+}
+
+func (p *ProcessDeploymentConfigPlugin) Sign(dep map[string]interface{}, keyFilePath string, ctx plugin_registry.PluginContext) (bool, string, string, error) {
+
+	// get message printer
+	msgPrinter := i18n.GetMessagePrinter()
+
+	if owned, err := p.Validate(dep, nil); !owned || err != nil {
+		return owned, "", "", err
+	}
+
+	// If the executable names a local file, put the hash of the file into the deployment config in place of the file.
+	// The file might be relative to the service definition file.
+	pd, filePath, _ := getProcessDeployment(dep)
+	if filePath != "" {
+		if currentDir, ok := (ctx.Get("currentDir")).(string); !ok {
+			return true, "", "", errors.New(msgPrinter.Sprintf("plugin context must include 'currentDir' as the current directory of the service definition file"))
+		} else if !filepath.IsAbs(filePath) {
+			filePath = filepath.Join(currentDir, filePath)
+		}
+
+		hash, err := fileHash(filePath)
+		if err != nil {
+			return true, "", "", errors.New(msgPrinter.Sprintf("unable to read executable %v, error %v", filePath, err))
+		} else if pd.Executable.SHA256 != "" && !strings.EqualFold(pd.Executable.SHA256, hash) {
+			return true, "", "", errors.New(msgPrinter.Sprintf("the sha256 %v of the executable does not match the hash %v of %v", pd.Executable.SHA256, hash, filePath))
+		}
+
+		executable := dep["executable"].(map[string]interface{})
+		executable["sha256"] = hash
+		delete(executable, "file")
+	}
+
+	// Stringify and sign the deployment string.
+	deployment, err := json.Marshal(dep)
+	if err != nil {
+		return true, "", "", errors.New(msgPrinter.Sprintf("failed to marshal %v deployment string %v, error %v", PROCESS_DEPLOYMENT_CONFIG_TYPE, dep, err))
+	}
+	depStr := string(deployment)
+
+	sig, err := sign.Input(keyFilePath, deployment)
+	if err != nil {
+		return true, "", "", errors.New(msgPrinter.Sprintf("problem signing %v deployment string with %s: %v", PROCESS_DEPLOYMENT_CONFIG_TYPE, keyFilePath, err))
+	}
+
+	return true, depStr, sig, nil
+}
+
+// A process deployment has no container images.
+func (p *ProcessDeploymentConfigPlugin) GetContainerImages(dep interface{}) (bool, []string, error) {
+	owned, err := p.Validate(dep, nil)
+	return owned, []string{}, err
+}
+
+func (p *ProcessDeploymentConfigPlugin) DefaultConfig(imageInfo interface{}) interface{} {
+	return map[string]interface{}{
+		"executable": map[string]interface{}{
+			"url":  "",
+			"file": "",
+		},
+		"args": []string{},
+		"env":  []string{},
+		"restart": map[string]interface{}{
+			"max_retries":     0,
+			"initial_backoff": persistence.DEFAULT_PROCESS_INITIAL_BACKOFF,
+			"max_backoff":     persistence.DEFAULT_PROCESS_MAX_BACKOFF,
+		},
+	}
+}
+
+// Return the default cluster config object, which is nil in this case.
+func (p *ProcessDeploymentConfigPlugin) DefaultClusterConfig() interface{} {
+	return nil
+}
+
+func (p *ProcessDeploymentConfigPlugin) Validate(dep interface{}, cdep interface{}) (bool, error) {
+	// get message printer
+	msgPrinter := i18n.GetMessagePrinter()
+
+	dc, ok := dep.(map[string]interface{})
+	if !ok || !persistence.IsProcess(dc) {
+		return false, nil
+	}
+
+	pd, filePath, err := getProcessDeployment(dc)
+	if err != nil {
+		return true, err
+	}
+
+	// The hash of a local file is computed when the service is signed, so it does not have to be in the service definition.
+	if filePath != "" && pd.Executable.SHA256 == "" {
+		pd.Executable.SHA256 = strings.Repeat("0", sha256.Size*2)
+	}
+
+	if err := pd.Validate(); err != nil {
+		return true, errors.New(msgPrinter.Sprintf("invalid %v deployment config: %v", PROCESS_DEPLOYMENT_CONFIG_TYPE, err))
+	}
+	return true, nil
+}
+
+// Run the service as a process on this host. The process is not supervised, so it is not restarted when it exits and
+// the resource limits of the deployment config are not applied.
+func (p *ProcessDeploymentConfigPlugin) StartTest(homeDirectory string, userInputFile string, configFiles []string, configType string, noFSS bool, userCreds string) bool {
+
+	// get message printer
+	msgPrinter := i18n.GetMessagePrinter()
+
+	// Run verification before trying to start anything.
+	dev.ServiceValidate(homeDirectory, userInputFile, configFiles, configType, userCreds)
+
+	// Perform the common execution setup.
+	dir, userInputs, cw := dev.CommonExecutionSetup(homeDirectory, userInputFile, dev.SERVICE_COMMAND, dev.SERVICE_START_COMMAND)
+
+	// Get the service definition, so that we can look at the user input variable definitions.
+	serviceDef, sderr := dev.GetServiceDefinition(dir, dev.SERVICE_DEFINITION_FILE)
+	if sderr != nil {
+		cliutils.Fatal(cliutils.CLI_GENERAL_ERROR, "'%v %v' %v", dev.SERVICE_COMMAND, dev.SERVICE_START_COMMAND, sderr)
+	}
+
+	// Now that we have the service def, we can check if we own the deployment config object.
+	if owned, err := p.Validate(serviceDef.Deployment, nil); !owned || err != nil {
+		return false
+	}
+
+	testDir := path.Join(dir, PROCESS_TEST_DIR)
+	if pid, err := readPid(testDir); err == nil && syscall.Kill(pid, 0) == nil {
+		cliutils.Fatal(cliutils.CLI_GENERAL_ERROR, msgPrinter.Sprintf("'%v %v' the service is already running as process %v", dev.SERVICE_COMMAND, dev.SERVICE_START_COMMAND, pid))
+	} else if err := os.MkdirAll(testDir, 0750); err != nil {
+		cliutils.Fatal(cliutils.CLI_GENERAL_ERROR, msgPrinter.Sprintf("'%v %v' unable to create directory %v, error %v", dev.SERVICE_COMMAND, dev.SERVICE_START_COMMAND, testDir, err))
+	}
+
+	pd, filePath, _ := getProcessDeployment(serviceDef.Deployment.(map[string]interface{}))
+	execPath, err := getTestExecutable(dir, testDir, pd, filePath)
+	if err != nil {
+		cliutils.Fatal(cliutils.CLI_GENERAL_ERROR, "'%v %v' %v", dev.SERVICE_COMMAND, dev.SERVICE_START_COMMAND, err)
+	}
+
+	// Generate an agreement id for testing purposes.
+	agreementId, aerr := cutil.GenerateAgreementId()
+	if aerr != nil {
+		cliutils.Fatal(cliutils.CLI_GENERAL_ERROR, msgPrinter.Sprintf("'%v %v' unable to generate test agreementId, %v", dev.SERVICE_COMMAND, dev.SERVICE_START_COMMAND, aerr))
+	}
+
+	envvars, enverr := dev.ServiceEnvVarMap(agreementId, serviceDef, userInputs, cw)
+	if enverr != nil {
+		cliutils.Fatal(cliutils.CLI_GENERAL_ERROR, msgPrinter.Sprintf("'%v %v' unable to create environment variables, %v", dev.SERVICE_COMMAND, dev.SERVICE_START_COMMAND, enverr))
+	}
+
+	env := process.ProcessEnvironment(pd, envvars)
+	cliutils.Verbose(msgPrinter.Sprintf("Passing environment variables: %v", env))
+
+	logPath := path.Join(testDir, process.PROCESS_LOG_NAME)
+	logFile, err := os.OpenFile(logPath, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0640)
+	if err != nil {
+		cliutils.Fatal(cliutils.CLI_GENERAL_ERROR, msgPrinter.Sprintf("'%v %v' unable to open %v, error %v", dev.SERVICE_COMMAND, dev.SERVICE_START_COMMAND, logPath, err))
+	}
+	defer logFile.Close()
+
+	cmd := exec.Command(execPath, pd.Args...)
+	cmd.Dir = testDir
+	cmd.Env = env
+	cmd.Stdout = logFile
+	cmd.Stderr = logFile
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setpgid: true}
+
+	if err := cmd.Start(); err != nil {
+		cliutils.Fatal(cliutils.CLI_GENERAL_ERROR, msgPrinter.Sprintf("'%v %v' unable to start %v, error %v", dev.SERVICE_COMMAND, dev.SERVICE_START_COMMAND, execPath, err))
+	} else if err := ioutil.WriteFile(path.Join(testDir, PROCESS_PID_FILE), []byte(strconv.Itoa(cmd.Process.Pid)), 0640); err != nil {
+		syscall.Kill(-cmd.Process.Pid, syscall.SIGKILL)
+		cliutils.Fatal(cliutils.CLI_GENERAL_ERROR, msgPrinter.Sprintf("'%v %v' unable to save the process id, error %v", dev.SERVICE_COMMAND, dev.SERVICE_START_COMMAND, err))
+	}
+
+	msgPrinter.Printf("Running service %v as process %v, with output in %v.", serviceDef.URL, cmd.Process.Pid, logPath)
+	msgPrinter.Println()
+	cmd.Process.Release()
+
+	return true
+}
+
+func (p *ProcessDeploymentConfigPlugin) StopTest(homeDirectory string) bool {
+
+	// get message printer
+	msgPrinter := i18n.GetMessagePrinter()
+
+	// Perform the common execution setup.
+	dir, _, _ := dev.CommonExecutionSetup(homeDirectory, "", dev.SERVICE_COMMAND, dev.SERVICE_STOP_COMMAND)
+
+	// Get the service definition for this project.
+	serviceDef, sderr := dev.GetServiceDefinition(dir, dev.SERVICE_DEFINITION_FILE)
+	if sderr != nil {
+		cliutils.Fatal(cliutils.CLI_GENERAL_ERROR, "'%v %v' %v", dev.SERVICE_COMMAND, dev.SERVICE_STOP_COMMAND, sderr)
+	}
+
+	// Now that we have the service def, we can check if we own the deployment config object.
+	if owned, err := p.Validate(serviceDef.Deployment, nil); !owned || err != nil {
+		return false
+	}
+
+	testDir := path.Join(dir, PROCESS_TEST_DIR)
+	pid, err := readPid(testDir)
+	if err != nil {
+		cliutils.Fatal(cliutils.CLI_GENERAL_ERROR, msgPrinter.Sprintf("'%v %v' the service is not running, %v", dev.SERVICE_COMMAND, dev.SERVICE_STOP_COMMAND, err))
+	}
+
+	// Stop the process group of the service, and kill it if it does not exit in time.
+	syscall.Kill(-pid, syscall.SIGTERM)
+	for i := 0; i < process.PROCESS_STOP_TIMEOUT_S*10 && syscall.Kill(pid, 0) == nil; i++ {
+		time.Sleep(100 * time.Millisecond)
+	}
+	if syscall.Kill(pid, 0) == nil {
+		syscall.Kill(-pid, syscall.SIGKILL)
+	}
+
+	os.Remove(path.Join(testDir, PROCESS_PID_FILE))
+
+	msgPrinter.Printf("Stopped service.")
+	msgPrinter.Println()
+
+	return true
+}
+
+// Returns the process deployment in a deployment config map, and the local file of the executable if there is one.
+func getProcessDeployment(dc map[string]interface{}) (*persistence.ProcessDeploymentConfig, string, error) {
+	// get message printer
+	msgPrinter := i18n.GetMessagePrinter()
+
+	filePath := ""
+	if executable, ok := dc["executable"].(map[string]interface{}); !ok {
+		return nil, "", errors.New(msgPrinter.Sprintf("executable must be a json object, has %T", dc["executable"]))
+	} else if f, ok := executable["file"]; ok {
+		if filePath, ok = f.(string); !ok {
+			return nil, "", errors.New(msgPrinter.Sprintf("executable file must have a string type value, has %T", f))
+		}
+	}
+
+	pd := new(persistence.ProcessDeploymentConfig)
+	if jBytes, err := json.Marshal(dc); err != nil {
+		return nil, "", errors.New(msgPrinter.Sprintf("failed to marshal %v deployment config %v, error %v", PROCESS_DEPLOYMENT_CONFIG_TYPE, dc, err))
+	} else if err := json.Unmarshal(jBytes, pd); err != nil {
+		return nil, "", errors.New(msgPrinter.Sprintf("invalid %v deployment config: %v", PROCESS_DEPLOYMENT_CONFIG_TYPE, err))
+	}
+	return pd, filePath, nil
+}
+
+// Get the executable into the test directory, from the local file if there is one, or else from its url.
+func getTestExecutable(dir string, testDir string, pd *persistence.ProcessDeploymentConfig, filePath string) (string, error) {
+	// get message printer
+	msgPrinter := i18n.GetMessagePrinter()
+
+	var reader io.Reader
+	hash := pd.Executable.SHA256
+
+	if filePath != "" {
+		if !filepath.IsAbs(filePath) {
+			filePath = filepath.Join(dir, filePath)
+		}
+		if hash == "" {
+			h, err := fileHash(filePath)
+			if err != nil {
+				return "", errors.New(msgPrinter.Sprintf("unable to read executable %v, error %v", filePath, err))
+			}
+			hash = h
+		}
+		f, err := os.Open(filePath)
+		if err != nil {
+			return "", errors.New(msgPrinter.Sprintf("unable to read executable %v, error %v", filePath, err))
+		}
+		defer f.Close()
+		reader = f
+
+	} else if pd.Executable.URL != "" {
+		cliutils.Verbose(msgPrinter.Sprintf("Downloading executable from %v", pd.Executable.URL))
+		resp, err := http.Get(pd.Executable.URL)
+		if err != nil {
+			return "", errors.New(msgPrinter.Sprintf("unable to download executable from %v, error %v", pd.Executable.URL, err))
+		}
+		defer resp.Body.Close()
+		if resp.StatusCode != http.StatusOK {
+			return "", errors.New(msgPrinter.Sprintf("unable to download executable from %v, HTTP status %v", pd.Executable.URL, resp.Status))
+		}
+		reader = resp.Body
+
+	} else {
+		return "", errors.New(msgPrinter.Sprintf("set the executable 'file' to test a service whose executable is the object %v/%v", pd.Executable.ObjectType, pd.Executable.ObjectID))
+	}
+
+	return process.SaveExecutable(reader, hash, testDir)
+}
+
+func fileHash(filePath string) (string, error) {
+	f, err := os.Open(filePath)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	hash := sha256.New()
+	if _, err := io.Copy(hash, f); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(hash.Sum(nil)), nil
+}
+
+func readPid(testDir string) (int, error) {
+	content, err := ioutil.ReadFile(path.Join(testDir, PROCESS_PID_FILE))
+	if err != nil {
+		return 0, err
+	}
+	pid, err := strconv.Atoi(strings.TrimSpace(string(content)))
+	if err != nil {
+		return 0, fmt.Errorf("invalid process id %v, error %v", string(content), err)
+	}
+	return pid, nil
+}
@@ -158,6 +158,21 @@ func DoIt(org, pattern, nodeIdTok, userPw, inputFile string, nodeOrgFromFlag str
 
 	// Default node id and token if necessary
 	nodeId, nodeToken := cliutils.SplitIdToken(nodeIdTok)
+
+	// If the agent presents a client certificate to the exchange, the node must be registered with the identity in the certificate.
+	if clientCert := statusInfo.Configuration.ClientCert; clientCert != nil {
+		if clientCert.Org != org {
+			cliutils.Fatal(cliutils.CLI_INPUT_ERROR, msgPrinter.Sprintf("the node organization %v does not match the organization %v in the exchange client certificate of the Horizon agent.", org, clientCert.Org))
+		}
+		if nodeId == "" {
+			nodeId = clientCert.Id
+			msgPrinter.Printf("Using node ID '%s' from the exchange client certificate of the Horizon agent", nodeId)
+			msgPrinter.Println()
+		} else if _, id := cliutils.TrimOrg(org, nodeId); id != clientCert.Id {
+			cliutils.Fatal(cliutils.CLI_INPUT_ERROR, msgPrinter.Sprintf("the node ID %v does not match the node ID %v in the exchange client certificate of the Horizon agent.", id, clientCert.Id))
+		}
+	}
+
 	if nodeId == "" {
 		// Get the id from anax
 		if horDevice.Id == nil {
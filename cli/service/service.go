@@ -6,6 +6,7 @@ import (
 	"fmt"
 	"github.com/open-horizon/anax/api"
 	"github.com/open-horizon/anax/apicommon"
+	"github.com/open-horizon/anax/cli/agreement"
 	"github.com/open-horizon/anax/cli/cliutils"
 	"github.com/open-horizon/anax/cutil"
 	"github.com/open-horizon/anax/exchange"
@@ -33,6 +34,8 @@ type OurService struct {
 	Version   string                 `json:"version"` // The version of the service in OSGI version format
 	Arch      string                 `json:"arch"`    // The hardware architecture of the service impl
 	Variables map[string]interface{} `json:"variables"`
+	// The status of the workload of the service on a cluster node
+	ClusterStatus *agreement.ClusterStatus `json:"cluster_status,omitempty"`
 }
 
 func List() {
@@ -52,6 +55,14 @@ func List() {
 	cliutils.HorizonGet("status", []int{200}, &statusInfo, false)
 	anaxArch := (*statusInfo.Configuration).Arch
 
+	// On a cluster node, the status of the workload of a service comes from its agreement.
+	horDevice := api.HorizonDevice{}
+	cliutils.HorizonGet("node", []int{200}, &horDevice, false)
+	clusterAgreements := []persistence.EstablishedAgreement{}
+	if horDevice.NodeType != nil && *horDevice.NodeType == persistence.DEVICE_TYPE_CLUSTER {
+		clusterAgreements = agreement.GetAgreements(false)
+	}
+
 	// Go thru the services and pull out interesting fields
 	services := make([]OurService, 0)
 	for _, s := range apiOutput.Config {
@@ -73,6 +84,12 @@ func List() {
 			}
 		}
 
+		for _, ag := range clusterAgreements {
+			if ag.RunningWorkload.URL == serv.Url && ag.RunningWorkload.Org == serv.Org && ag.ClusterStatus != nil {
+				serv.ClusterStatus = agreement.NewClusterStatus(ag.ClusterStatus)
+			}
+		}
+
 		services = append(services, serv)
 	}
 
@@ -0,0 +1,65 @@
+package service
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/open-horizon/anax/api"
+	"github.com/open-horizon/anax/cli/cliutils"
+	"github.com/open-horizon/anax/i18n"
+	"github.com/open-horizon/anax/persistence"
+	"net/http"
+)
+
+// Display the named volumes of the services on this node and their backups.
+func VolumeList() {
+	// get message printer
+	msgPrinter := i18n.GetMessagePrinter()
+
+	var volumes []api.VolumeOutput
+	cliutils.HorizonGet("service/volume", []int{200}, &volumes, false)
+
+	output, err := cliutils.DisplayAsJson(volumes)
+	if err != nil {
+		cliutils.Fatal(cliutils.JSON_PARSING_ERROR, msgPrinter.Sprintf("failed to marshal 'hzn service volume list' output: %v", err))
+	}
+	fmt.Println(output)
+}
+
+// Back up a named volume of a service on this node.
+func VolumeBackup(name string) {
+	// get message printer
+	msgPrinter := i18n.GetMessagePrinter()
+
+	_, respBody, _ := cliutils.HorizonPutPost(http.MethodPost, "service/volume/"+name+"/backup", []int{200}, nil, true)
+
+	var backup persistence.VolumeBackup
+	if err := json.Unmarshal([]byte(respBody), &backup); err != nil {
+		cliutils.Fatal(cliutils.JSON_PARSING_ERROR, msgPrinter.Sprintf("Unable to unmarshal the response %v: %v", respBody, err))
+	}
+	msgPrinter.Printf("Volume %v backed up to %v, backup %v.", name, backup.File, backup.RecordId)
+	msgPrinter.Println()
+}
+
+// Restore a named volume of a service on this node from one of its backups, the latest one when backupId is empty.
+// The service that uses the volume must be stopped, the current content of the volume is lost.
+func VolumeRestore(name string, backupId string, force bool) {
+	// get message printer
+	msgPrinter := i18n.GetMessagePrinter()
+
+	if !force {
+		if backupId == "" {
+			cliutils.ConfirmRemove(msgPrinter.Sprintf("Are you sure you want to replace the content of volume %v with its latest backup?", name))
+		} else {
+			cliutils.ConfirmRemove(msgPrinter.Sprintf("Are you sure you want to replace the content of volume %v with backup %v?", name, backupId))
+		}
+	}
+
+	_, respBody, _ := cliutils.HorizonPutPost(http.MethodPost, "service/volume/"+name+"/restore", []int{200}, api.VolumeRestore{Backup: backupId}, true)
+
+	var backup persistence.VolumeBackup
+	if err := json.Unmarshal([]byte(respBody), &backup); err != nil {
+		cliutils.Fatal(cliutils.JSON_PARSING_ERROR, msgPrinter.Sprintf("Unable to unmarshal the response %v: %v", respBody, err))
+	}
+	msgPrinter.Printf("Volume %v restored from %v, backup %v.", name, backup.File, backup.RecordId)
+	msgPrinter.Println()
+}
@@ -3,11 +3,14 @@ package userinput
 import (
 	"encoding/json"
 	"fmt"
+	"github.com/open-horizon/anax/api"
 	"github.com/open-horizon/anax/cli/cliconfig"
 	"github.com/open-horizon/anax/cli/cliutils"
 	"github.com/open-horizon/anax/i18n"
+	"github.com/open-horizon/anax/persistence"
 	"github.com/open-horizon/anax/policy"
 	"net/http"
+	"net/url"
 )
 
 //Display a list of the current userInputs of the node
@@ -98,3 +101,63 @@ func Remove(force bool) {
 	msgPrinter.Printf("Horizon user inputs removed.")
 	msgPrinter.Println()
 }
+
+//Display the previous versions of the user inputs of the node, the oldest first
+func History() {
+	var history []persistence.NodeConfigVersion
+	cliutils.HorizonGet("node/userinput/history", []int{200}, &history, false)
+
+	output, err := cliutils.DisplayAsJson(history)
+	if err != nil {
+		cliutils.Fatal(cliutils.JSON_PARSING_ERROR, i18n.GetMessagePrinter().Sprintf("Unable to marshal userinput history object: %v", err))
+	}
+	fmt.Println(output)
+}
+
+//Restore a previous version of the user inputs for this node. Version 0 restores the version before the current one.
+func Rollback(version uint64, force bool) {
+	// get message printer
+	msgPrinter := i18n.GetMessagePrinter()
+
+	if !force {
+		if version == 0 {
+			cliutils.ConfirmRemove(msgPrinter.Sprintf("Are you sure you want to roll back the node user inputs to the previous version?"))
+		} else {
+			cliutils.ConfirmRemove(msgPrinter.Sprintf("Are you sure you want to roll back the node user inputs to version %v?", version))
+		}
+	}
+
+	_, respBody, _ := cliutils.HorizonPutPost(http.MethodPost, "node/userinput/rollback", []int{200}, map[string]uint64{"version": version}, true)
+
+	var restored persistence.NodeConfigVersion
+	if err := json.Unmarshal([]byte(respBody), &restored); err != nil {
+		cliutils.Fatal(cliutils.JSON_PARSING_ERROR, msgPrinter.Sprintf("Unable to unmarshal the response %v: %v", respBody, err))
+	}
+	msgPrinter.Printf("Horizon node user inputs rolled back to version %v.", restored.Version)
+	msgPrinter.Println()
+}
+
+//Display the user inputs that the services on this node receive and where each value comes from. Empty svcUrl and
+//svcOrg display all the services on this node.
+func Effective(svcUrl string, svcOrg string) {
+	query := url.Values{}
+	if svcUrl != "" {
+		query.Set("url", svcUrl)
+	}
+	if svcOrg != "" {
+		query.Set("org", svcOrg)
+	}
+	urlSuffix := "node/userinput/effective"
+	if len(query) != 0 {
+		urlSuffix += "?" + query.Encode()
+	}
+
+	var effective []api.EffectiveServiceUserInput
+	cliutils.HorizonGet(urlSuffix, []int{200}, &effective, false)
+
+	output, err := cliutils.DisplayAsJson(effective)
+	if err != nil {
+		cliutils.Fatal(cliutils.JSON_PARSING_ERROR, i18n.GetMessagePrinter().Sprintf("Unable to marshal effective userinput object: %v", err))
+	}
+	fmt.Println(output)
+}
@@ -0,0 +1,319 @@
+package wasm_deployment
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"github.com/open-horizon/anax/cli/cliutils"
+	"github.com/open-horizon/anax/cli/dev"
+	"github.com/open-horizon/anax/cli/plugin_registry"
+	"github.com/open-horizon/anax/cutil"
+	"github.com/open-horizon/anax/i18n"
+	"github.com/open-horizon/anax/persistence"
+	"github.com/open-horizon/anax/wasm"
+	"github.com/open-horizon/rsapss-tool/sign"
+	"io"
+	"net/http"
+	"os"
+	"os/signal"
+	"path"
+	"path/filepath"
+	"strings"
+	"syscall"
+	"time"
+)
+
+const WASM_DEPLOYMENT_CONFIG_TYPE = "wasm"
+
+// The directory in the project that holds the relative preopens of a service run by 'hzn dev service start'.
+const WASM_TEST_DIR = ".wasm"
+
+func init() {
+	plugin_registry.Register(WASM_DEPLOYMENT_CONFIG_TYPE, NewWasmDeploymentConfigPlugin())
+}
+
+type WasmDeploymentConfigPlugin struct {
+}
+
+func NewWasmDeploymentConfigPlugin() plugin_registry.DeploymentConfigPlugin {
+	return new(WasmDeploymentConfigPlugin)
+}
+
+func (p *WasmDeploymentConfigPlugin) Sign(dep map[string]interface{}, keyFilePath string, ctx plugin_registry.PluginContext) (bool, string, string, error) {
+
+	// get message printer
+	msgPrinter := i18n.GetMessagePrinter()
+
+	if owned, err := p.Validate(dep, nil); !owned || err != nil {
+		return owned, "", "", err
+	}
+
+	// If the module names a local file, put the digest of the file into the deployment config in place of the file.
+	// The file might be relative to the service definition file.
+	wd, filePath, _ := getWasmDeployment(dep)
+	if filePath != "" {
+		if currentDir, ok := (ctx.Get("currentDir")).(string); !ok {
+			return true, "", "", errors.New(msgPrinter.Sprintf("plugin context must include 'currentDir' as the current directory of the service definition file"))
+		} else if !filepath.IsAbs(filePath) {
+			filePath = filepath.Join(currentDir, filePath)
+		}
+
+		digest, err := fileDigest(filePath)
+		if err != nil {
+			return true, "", "", errors.New(msgPrinter.Sprintf("unable to read module %v, error %v", filePath, err))
+		} else if wd.Module.Digest != "" && !strings.EqualFold(wd.Module.Digest, digest) {
+			return true, "", "", errors.New(msgPrinter.Sprintf("the digest %v of the module does not match the digest %v of %v", wd.Module.Digest, digest, filePath))
+		}
+
+		module := dep["wasm_module"].(map[string]interface{})
+		module["digest"] = digest
+		delete(module, "file")
+	}
+
+	// Stringify and sign the deployment string.
+	deployment, err := json.Marshal(dep)
+	if err != nil {
+		return true, "", "", errors.New(msgPrinter.Sprintf("failed to marshal %v deployment string %v, error %v", WASM_DEPLOYMENT_CONFIG_TYPE, dep, err))
+	}
+	depStr := string(deployment)
+
+	sig, err := sign.Input(keyFilePath, deployment)
+	if err != nil {
+		return true, "", "", errors.New(msgPrinter.Sprintf("problem signing %v deployment string with %s: %v", WASM_DEPLOYMENT_CONFIG_TYPE, keyFilePath, err))
+	}
+
+	return true, depStr, sig, nil
+}
+
+// A wasm deployment has no container images.
+func (p *WasmDeploymentConfigPlugin) GetContainerImages(dep interface{}) (bool, []string, error) {
+	owned, err := p.Validate(dep, nil)
+	return owned, []string{}, err
+}
+
+func (p *WasmDeploymentConfigPlugin) DefaultConfig(imageInfo interface{}) interface{} {
+	return map[string]interface{}{
+		"wasm_module": map[string]interface{}{
+			"url":  "",
+			"file": "",
+		},
+		"args":            []string{},
+		"env":             []string{},
+		"preopens":        []interface{}{},
+		"memory_limit_mb": 16,
+	}
+}
+
+// Return the default cluster config object, which is nil in this case.
+func (p *WasmDeploymentConfigPlugin) DefaultClusterConfig() interface{} {
+	return nil
+}
+
+func (p *WasmDeploymentConfigPlugin) Validate(dep interface{}, cdep interface{}) (bool, error) {
+	// get message printer
+	msgPrinter := i18n.GetMessagePrinter()
+
+	dc, ok := dep.(map[string]interface{})
+	if !ok || !persistence.IsWasm(dc) {
+		return false, nil
+	}
+
+	wd, filePath, err := getWasmDeployment(dc)
+	if err != nil {
+		return true, err
+	}
+
+	// The digest of a local file is computed when the service is signed, so it does not have to be in the service definition.
+	if filePath != "" && wd.Module.Digest == "" {
+		wd.Module.Digest = persistence.WASM_DIGEST_PREFIX + strings.Repeat("0", sha256.Size*2)
+	}
+
+	if err := wd.Validate(); err != nil {
+		return true, errors.New(msgPrinter.Sprintf("invalid %v deployment config: %v", WASM_DEPLOYMENT_CONFIG_TYPE, err))
+	}
+	return true, nil
+}
+
+// Run the module of the service in the foreground, with its output on stdout, until it fails or the command is
+// interrupted.
+func (p *WasmDeploymentConfigPlugin) StartTest(homeDirectory string, userInputFile string, configFiles []string, configType string, noFSS bool, userCreds string) bool {
+
+	// get message printer
+	msgPrinter := i18n.GetMessagePrinter()
+
+	// Run verification before trying to start anything.
+	dev.ServiceValidate(homeDirectory, userInputFile, configFiles, configType, userCreds)
+
+	// Perform the common execution setup.
+	dir, userInputs, cw := dev.CommonExecutionSetup(homeDirectory, userInputFile, dev.SERVICE_COMMAND, dev.SERVICE_START_COMMAND)
+
+	// Get the service definition, so that we can look at the user input variable definitions.
+	serviceDef, sderr := dev.GetServiceDefinition(dir, dev.SERVICE_DEFINITION_FILE)
+	if sderr != nil {
+		cliutils.Fatal(cliutils.CLI_GENERAL_ERROR, "'%v %v' %v", dev.SERVICE_COMMAND, dev.SERVICE_START_COMMAND, sderr)
+	}
+
+	// Now that we have the service def, we can check if we own the deployment config object.
+	if owned, err := p.Validate(serviceDef.Deployment, nil); !owned || err != nil {
+		return false
+	}
+
+	testDir := path.Join(dir, WASM_TEST_DIR)
+	if err := os.MkdirAll(testDir, 0750); err != nil {
+		cliutils.Fatal(cliutils.CLI_GENERAL_ERROR, msgPrinter.Sprintf("'%v %v' unable to create directory %v, error %v", dev.SERVICE_COMMAND, dev.SERVICE_START_COMMAND, testDir, err))
+	}
+
+	wd, filePath, _ := getWasmDeployment(serviceDef.Deployment.(map[string]interface{}))
+	module, err := getTestModule(dir, wd, filePath)
+	if err != nil {
+		cliutils.Fatal(cliutils.CLI_GENERAL_ERROR, "'%v %v' %v", dev.SERVICE_COMMAND, dev.SERVICE_START_COMMAND, err)
+	}
+
+	// Generate an agreement id for testing purposes.
+	agreementId, aerr := cutil.GenerateAgreementId()
+	if aerr != nil {
+		cliutils.Fatal(cliutils.CLI_GENERAL_ERROR, msgPrinter.Sprintf("'%v %v' unable to generate test agreementId, %v", dev.SERVICE_COMMAND, dev.SERVICE_START_COMMAND, aerr))
+	}
+
+	envvars, enverr := dev.ServiceEnvVarMap(agreementId, serviceDef, userInputs, cw)
+	if enverr != nil {
+		cliutils.Fatal(cliutils.CLI_GENERAL_ERROR, msgPrinter.Sprintf("'%v %v' unable to create environment variables, %v", dev.SERVICE_COMMAND, dev.SERVICE_START_COMMAND, enverr))
+	}
+
+	env := wasm.WasmEnvironment(wd, envvars)
+	cliutils.Verbose(msgPrinter.Sprintf("Passing environment variables: %v", env))
+
+	r := wasm.NewRunner(agreementId, module, testDir, env, wd, os.Stdout)
+	if err := r.Start(); err != nil {
+		cliutils.Fatal(cliutils.CLI_GENERAL_ERROR, msgPrinter.Sprintf("'%v %v' unable to start the module, error %v", dev.SERVICE_COMMAND, dev.SERVICE_START_COMMAND, err))
+	}
+
+	msgPrinter.Printf("Running service %v. Use ctrl-C to stop it.", serviceDef.URL)
+	msgPrinter.Println()
+
+	sigs := make(chan os.Signal, 1)
+	signal.Notify(sigs, syscall.SIGINT, syscall.SIGTERM)
+	select {
+	case <-sigs:
+	case <-r.Done():
+	}
+	r.Stop(wasm.WASM_STOP_TIMEOUT_S * time.Second)
+
+	if status := r.Status(); status.LastError != "" {
+		msgPrinter.Printf("Service stopped, the module %v.", status.LastError)
+	} else {
+		msgPrinter.Printf("Service stopped.")
+	}
+	msgPrinter.Println()
+
+	return true
+}
+
+// The module of a service runs in the foreground of 'hzn dev service start', so there is nothing to stop.
+func (p *WasmDeploymentConfigPlugin) StopTest(homeDirectory string) bool {
+
+	// get message printer
+	msgPrinter := i18n.GetMessagePrinter()
+
+	// Perform the common execution setup.
+	dir, _, _ := dev.CommonExecutionSetup(homeDirectory, "", dev.SERVICE_COMMAND, dev.SERVICE_STOP_COMMAND)
+
+	// Get the service definition for this project.
+	serviceDef, sderr := dev.GetServiceDefinition(dir, dev.SERVICE_DEFINITION_FILE)
+	if sderr != nil {
+		cliutils.Fatal(cliutils.CLI_GENERAL_ERROR, "'%v %v' %v", dev.SERVICE_COMMAND, dev.SERVICE_STOP_COMMAND, sderr)
+	}
+
+	// Now that we have the service def, we can check if we own the deployment config object.
+	if owned, err := p.Validate(serviceDef.Deployment, nil); !owned || err != nil {
+		return false
+	}
+
+	msgPrinter.Printf("The module of a %v service runs in the foreground of '%v %v'. Use ctrl-C there to stop it.", WASM_DEPLOYMENT_CONFIG_TYPE, dev.SERVICE_COMMAND, dev.SERVICE_START_COMMAND)
+	msgPrinter.Println()
+
+	return true
+}
+
+// Returns the wasm deployment in a deployment config map, and the local file of the module if there is one.
+func getWasmDeployment(dc map[string]interface{}) (*persistence.WasmDeploymentConfig, string, error) {
+	// get message printer
+	msgPrinter := i18n.GetMessagePrinter()
+
+	filePath := ""
+	if module, ok := dc["wasm_module"].(map[string]interface{}); !ok {
+		return nil, "", errors.New(msgPrinter.Sprintf("wasm_module must be a json object, has %T", dc["wasm_module"]))
+	} else if f, ok := module["file"]; ok {
+		if filePath, ok = f.(string); !ok {
+			return nil, "", errors.New(msgPrinter.Sprintf("wasm_module file must have a string type value, has %T", f))
+		}
+	}
+
+	wd := new(persistence.WasmDeploymentConfig)
+	if jBytes, err := json.Marshal(dc); err != nil {
+		return nil, "", errors.New(msgPrinter.Sprintf("failed to marshal %v deployment config %v, error %v", WASM_DEPLOYMENT_CONFIG_TYPE, dc, err))
+	} else if err := json.Unmarshal(jBytes, wd); err != nil {
+		return nil, "", errors.New(msgPrinter.Sprintf("invalid %v deployment config: %v", WASM_DEPLOYMENT_CONFIG_TYPE, err))
+	}
+	return wd, filePath, nil
+}
+
+// Read the module, from the local file if there is one, or else from its url.
+func getTestModule(dir string, wd *persistence.WasmDeploymentConfig, filePath string) ([]byte, error) {
+	// get message printer
+	msgPrinter := i18n.GetMessagePrinter()
+
+	var reader io.Reader
+	digest := wd.Module.Digest
+
+	if filePath != "" {
+		if !filepath.IsAbs(filePath) {
+			filePath = filepath.Join(dir, filePath)
+		}
+		if digest == "" {
+			d, err := fileDigest(filePath)
+			if err != nil {
+				return nil, errors.New(msgPrinter.Sprintf("unable to read module %v, error %v", filePath, err))
+			}
+			digest = d
+		}
+		f, err := os.Open(filePath)
+		if err != nil {
+			return nil, errors.New(msgPrinter.Sprintf("unable to read module %v, error %v", filePath, err))
+		}
+		defer f.Close()
+		reader = f
+
+	} else if wd.Module.URL != "" {
+		cliutils.Verbose(msgPrinter.Sprintf("Downloading module from %v", wd.Module.URL))
+		resp, err := http.Get(wd.Module.URL)
+		if err != nil {
+			return nil, errors.New(msgPrinter.Sprintf("unable to download module from %v, error %v", wd.Module.URL, err))
+		}
+		defer resp.Body.Close()
+		if resp.StatusCode != http.StatusOK {
+			return nil, errors.New(msgPrinter.Sprintf("unable to download module from %v, HTTP status %v", wd.Module.URL, resp.Status))
+		}
+		reader = resp.Body
+
+	} else {
+		return nil, errors.New(msgPrinter.Sprintf("set the wasm_module 'file' to test a service whose module is the object %v/%v", wd.Module.ObjectType, wd.Module.ObjectID))
+	}
+
+	return wasm.ReadModule(reader, digest)
+}
+
+func fileDigest(filePath string) (string, error) {
+	f, err := os.Open(filePath)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	hash := sha256.New()
+	if _, err := io.Copy(hash, f); err != nil {
+		return "", err
+	}
+	return persistence.WASM_DIGEST_PREFIX + hex.EncodeToString(hash.Sum(nil)), nil
+}
@@ -197,6 +197,17 @@ func (sf *ServiceFile) DefinesVariable(name string) string {
 	return ""
 }
 
+// Returns the definition of the user input variable with the given name, nil if the service definition does not define
+// the variable or does not give its type.
+func (sf *ServiceFile) DefinedUserInput(name string) *exchange.UserInput {
+	for _, ui := range sf.UserInputs {
+		if ui.Name == name && ui.Type != "" {
+			return &ui
+		}
+	}
+	return nil
+}
+
 // Returns true if the service definition has required services.
 func (sf *ServiceFile) HasDependencies() bool {
 	if len(sf.RequiredServices) == 0 {
@@ -232,12 +243,17 @@ func (sf *ServiceFile) ConvertToDeploymentDescription(agreementService bool) (*D
 	}, nil
 }
 
-// Verify that non default user inputs are set in the input map.
+// Verify that non default user inputs are set in the input map, unless they are only required under a condition that
+// does not hold.
 func (sf *ServiceFile) RequiredVariablesAreSet(setVars map[string]interface{}) error {
+	given := func(name string) (interface{}, bool) {
+		v, ok := setVars[name]
+		return v, ok
+	}
 	for _, ui := range sf.UserInputs {
-		if ui.DefaultValue == "" && ui.Name != "" {
+		if ui.Name != "" && ui.IsRequired(sf.UserInputs, given) {
 			if _, ok := setVars[ui.Name]; !ok {
-				return errors.New(i18n.GetMessagePrinter().Sprintf("user input %v has no default value and is not set", ui.Name))
+				return errors.New(i18n.GetMessagePrinter().Sprintf("user input %v is not set, %v", ui.Name, ui.RequiredReason()))
 			}
 		}
 	}
@@ -262,6 +278,19 @@ func ValidateService(serviceDefResolverHandler exchange.ServiceDefResolverHandle
 		msgPrinter = i18n.GetMessagePrinter()
 	}
 
+	// the constraints on the user input variables must be valid
+	defined := map[string]bool{}
+	for _, ui := range svcFile.GetUserInputs() {
+		defined[ui.Name] = true
+	}
+	for _, ui := range svcFile.GetUserInputs() {
+		if err := ui.Validate(); err != nil {
+			return fmt.Errorf(msgPrinter.Sprintf("Invalid userInput %v: %v", ui.Name, err))
+		} else if ui.RequiredIf != nil && !defined[ui.RequiredIf.Name] {
+			return fmt.Errorf(msgPrinter.Sprintf("Invalid userInput %v: requiredIf names variable %v that the service does not define.", ui.Name, ui.RequiredIf.Name))
+		}
+	}
+
 	// cluster type, userinput and requiredServices are not allowed
 	topSvcType := svcFile.GetServiceType()
 	requiredServices := svcFile.GetRequiredServices()
@@ -81,14 +81,15 @@ func (p CompCheck) String() string {
 
 // The output format for the compatibility check
 type CompCheckOutput struct {
-	Compatible bool               `json:"compatible"`
-	Reason     map[string]string  `json:"reason"` // set when not compatible
-	Input      *CompCheckResource `json:"input,omitempty"`
+	Compatible         bool                        `json:"compatible"`
+	Reason             map[string]string           `json:"reason"` // set when not compatible
+	Input              *CompCheckResource          `json:"input,omitempty"`
+	EffectiveUserInput []policy.EffectiveUserInput `json:"effectiveUserInput,omitempty"` // set when the effective user input is requested
 }
 
 func (p *CompCheckOutput) String() string {
-	return fmt.Sprintf("Compatible: %v, Reason: %v, Input: %v",
-		p.Compatible, p.Reason, p.Input)
+	return fmt.Sprintf("Compatible: %v, Reason: %v, Input: %v, EffectiveUserInput: %v",
+		p.Compatible, p.Reason, p.Input, p.EffectiveUserInput)
 
 }
 
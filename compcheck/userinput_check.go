@@ -26,11 +26,12 @@ type UserInputCheck struct {
 	Pattern        *common.PatternFile            `json:"pattern,omitempty"`
 	Service        []common.ServiceFile           `json:"service,omitempty"`
 	ServiceToCheck []string                       `json:"service_to_check,omitempty"` // for internal use for performance. only check the service with the ids. If empty, check all.
+	Effective      bool                           `json:"effective,omitempty"`        // return the merged user input that each checked service receives
 }
 
 func (p UserInputCheck) String() string {
-	return fmt.Sprintf("NodeId: %v, NodeArch: %v, NodeType: %v, NodeUserInput: %v, BusinessPolId: %v, BusinessPolicy: %v, PatternId: %v, Pattern: %v, Service: %v,",
-		p.NodeId, p.NodeArch, p.NodeType, p.NodeUserInput, p.BusinessPolId, p.BusinessPolicy, p.PatternId, p.Pattern, p.Service)
+	return fmt.Sprintf("NodeId: %v, NodeArch: %v, NodeType: %v, NodeUserInput: %v, BusinessPolId: %v, BusinessPolicy: %v, PatternId: %v, Pattern: %v, Service: %v, Effective: %v",
+		p.NodeId, p.NodeArch, p.NodeType, p.NodeUserInput, p.BusinessPolId, p.BusinessPolicy, p.PatternId, p.Pattern, p.Service, p.Effective)
 }
 
 type ServiceDefinition struct {
@@ -437,7 +438,19 @@ func userInputCompatible(getDeviceHandler exchange.DeviceHandler,
 			}
 		}
 
-		return NewCompCheckOutput(overall_compatible, messages, resources), nil
+		output := NewCompCheckOutput(overall_compatible, messages, resources)
+		if input.Effective {
+			source := policy.USERINPUT_SOURCE_PATTERN
+			if useBPol {
+				source = policy.USERINPUT_SOURCE_DEPLOYMENT_POLICY
+			}
+			if effective, err := EffectiveUserInputForServices(all_services, source, bpUserInput, nodeUserInput); err != nil {
+				return nil, NewCompCheckError(err, COMPCHECK_GENERAL_ERROR)
+			} else {
+				output.EffectiveUserInput = effective
+			}
+		}
+		return output, nil
 
 	} else {
 		// If we get here, it means that no workload is found in the bp/pattern that matches the required node arch.
@@ -646,21 +659,36 @@ func VerifyUserInputForSingleServiceDef(sdef common.AbstractServiceFile,
 		mergedUI = ui2
 	}
 
-	// Verify that non-default variables are present.
+	given := func(name string) (interface{}, bool) {
+		if input := mergedUI.FindInput(name); input != nil {
+			return input.Value, true
+		}
+		return nil, false
+	}
+
+	// Verify that non-default variables are present, unless they are only required under a condition that does not hold.
 	for _, ui := range sdef.GetUserInputs() {
 		found := false
 		for _, mui := range mergedUI.Inputs {
 			if ui.Name == mui.Name {
 				found = true
 				if err := cutil.VerifyWorkloadVarTypes(mui.Value, ui.Type); err != nil {
+					if ui.IsSensitive() {
+						err = fmt.Errorf(msgPrinter.Sprintf("The value has the wrong type, expecting %v.", ui.Type))
+					}
 					return false, msgPrinter.Sprintf("Failed to validate the user input type for variable %v. %v", ui.Name, err), sdef, nil
+				} else if err := ui.InputConstraints.Verify(mui.Value, ui.Type); err != nil {
+					return false, msgPrinter.Sprintf("The user input for variable %v does not satisfy the constraints in the service definition: %v", ui.Name, err), sdef, nil
 				}
 				break
 			}
 		}
 
-		if !found && ui.DefaultValue == "" {
+		if !found && ui.IsRequired(sdef.GetUserInputs(), given) {
 			err_msg := msgPrinter.Sprintf("A required user input value is missing for variable %v.", ui.Name)
+			if ui.RequiredIf != nil {
+				err_msg = msgPrinter.Sprintf("A user input value is missing for variable %v, it is required when %v.", ui.Name, ui.RequiredIf)
+			}
 			if ui2 == nil {
 				err_msg = msgPrinter.Sprintf("%v Service %v/%v version %v arch %v is missing in the node user input.", err_msg, sdef.GetOrg(), sdef.GetURL(), sdef.GetVersion(), sdef.GetArch())
 			}
@@ -995,3 +1023,69 @@ func ServicesFromServiceMap(sMap map[string]common.AbstractServiceFile) []common
 
 	return services
 }
+
+// Returns the user input that each of the given services receives, merged from the default values in the service
+// definition, the user input of the deployment policy or pattern and the node user input. The source tells whether
+// bpUserInput comes from a deployment policy or a pattern. A service that is in the list more than once is only
+// returned once.
+func EffectiveUserInputForServices(services []common.AbstractServiceFile, source string, bpUserInput []policy.UserInput, nodeUserInput []policy.UserInput) ([]policy.EffectiveUserInput, error) {
+	out := []policy.EffectiveUserInput{}
+	done := map[string]bool{}
+	for _, sdef := range services {
+		sId := fmt.Sprintf("%v/%v", sdef.GetOrg(), cutil.FormExchangeIdForService(sdef.GetURL(), sdef.GetVersion(), sdef.GetArch()))
+		if sdef.GetURL() == "" || done[sId] {
+			continue
+		}
+		done[sId] = true
+
+		layers := []policy.UserInputLayer{ServiceDefaultUserInputLayer(sdef.GetUserInputs())}
+		for _, l := range []struct {
+			source    string
+			userInput []policy.UserInput
+		}{{source, bpUserInput}, {policy.USERINPUT_SOURCE_NODE, nodeUserInput}} {
+			if layer, err := policy.NewUserInputLayer(l.source, sdef.GetURL(), sdef.GetOrg(), sdef.GetVersion(), sdef.GetArch(), l.userInput); err != nil {
+				return nil, err
+			} else {
+				layers = append(layers, *layer)
+			}
+		}
+
+		effective := policy.NewEffectiveUserInput(sdef.GetURL(), sdef.GetOrg(), sdef.GetVersion(), sdef.GetArch(), layers, RequiredUserInputs(sdef.GetUserInputs()))
+		effective.Redact(SensitiveUserInputs(sdef.GetUserInputs()))
+		out = append(out, *effective)
+	}
+	return out, nil
+}
+
+// Returns the layer of the default values of the user input variables in a service definition.
+func ServiceDefaultUserInputLayer(userInputs []exchange.UserInput) policy.UserInputLayer {
+	layer := policy.UserInputLayer{Source: policy.USERINPUT_SOURCE_SERVICE, Inputs: []policy.Input{}}
+	for _, ui := range userInputs {
+		if ui.DefaultValue != "" {
+			layer.Inputs = append(layer.Inputs, policy.Input{Name: ui.Name, Value: ui.DefaultValue, Type: ui.Type})
+		}
+	}
+	return layer
+}
+
+// Returns the names of the user input variables in a service definition that do not have a default value.
+func RequiredUserInputs(userInputs []exchange.UserInput) []string {
+	required := []string{}
+	for _, ui := range userInputs {
+		if ui.DefaultValue == "" {
+			required = append(required, ui.Name)
+		}
+	}
+	return required
+}
+
+// Returns the names of the user input variables in a service definition that are sensitive.
+func SensitiveUserInputs(userInputs []exchange.UserInput) []string {
+	sensitive := []string{}
+	for _, ui := range userInputs {
+		if ui.IsSensitive() {
+			sensitive = append(sensitive, ui.Name)
+		}
+	}
+	return sensitive
+}
@@ -123,3 +123,133 @@ func Test_CheckRedundantUserinput(t *testing.T) {
 		t.Errorf("CheckRedundantUserinput should have returned nil but got %v", err)
 	}
 }
+
+func Test_EffectiveUserInputForServices(t *testing.T) {
+	s1 := ServiceDefinition{
+		"mycomp1",
+		exchange.ServiceDefinition{
+			URL:     "cpu1",
+			Version: "1.0.0",
+			Arch:    "amd64",
+			UserInputs: []exchange.UserInput{
+				exchange.UserInput{Name: "var1", Type: "string", DefaultValue: "default1"},
+				exchange.UserInput{Name: "var2", Type: "int", DefaultValue: "2"},
+				exchange.UserInput{Name: "var3", Type: "string", DefaultValue: ""},
+				exchange.UserInput{Name: "var4", Type: "string", DefaultValue: ""},
+			},
+		},
+	}
+
+	bpUserInput := []policy.UserInput{
+		policy.UserInput{ServiceOrgid: "mycomp1", ServiceUrl: "cpu1", Inputs: []policy.Input{policy.Input{Name: "var2", Value: float64(20)}, policy.Input{Name: "var3", Value: "policy3"}}},
+	}
+	nodeUserInput := []policy.UserInput{
+		policy.UserInput{ServiceOrgid: "mycomp1", ServiceUrl: "cpu1", Inputs: []policy.Input{policy.Input{Name: "var3", Value: "node3"}}},
+	}
+
+	// the same service twice is only returned once
+	services := []common.AbstractServiceFile{&s1, &s1}
+	effective, err := EffectiveUserInputForServices(services, policy.USERINPUT_SOURCE_DEPLOYMENT_POLICY, bpUserInput, nodeUserInput)
+	if err != nil {
+		t.Errorf("EffectiveUserInputForServices should not have returned error but got: %v", err)
+	} else if len(effective) != 1 {
+		t.Errorf("Expected the effective user input of 1 service but got %v", effective)
+	} else {
+		sources := map[string]string{}
+		for _, in := range effective[0].Inputs {
+			sources[in.Name] = in.Source
+		}
+		if sources["var1"] != policy.USERINPUT_SOURCE_SERVICE || sources["var2"] != policy.USERINPUT_SOURCE_DEPLOYMENT_POLICY || sources["var3"] != policy.USERINPUT_SOURCE_NODE {
+			t.Errorf("Wrong sources for the effective user input: %v", effective[0].Inputs)
+		}
+		if len(effective[0].Missing) != 1 || effective[0].Missing[0] != "var4" {
+			t.Errorf("Expected var4 to be missing but got %v", effective[0].Missing)
+		}
+	}
+}
+
+func Test_VerifyUserInputForSingleServiceDef_constraints(t *testing.T) {
+	max := float64(10)
+	s1 := ServiceDefinition{
+		"mycomp1",
+		exchange.ServiceDefinition{
+			URL:     "cpu1",
+			Version: "1.0.0",
+			Arch:    "amd64",
+			UserInputs: []exchange.UserInput{
+				exchange.UserInput{Name: "var1", Type: "int", InputConstraints: policy.InputConstraints{Max: &max}},
+				exchange.UserInput{Name: "var2", Type: "string", InputConstraints: policy.InputConstraints{Pattern: "^[a-z]+$", Sensitive: true}},
+			},
+		},
+	}
+
+	nodeUserInput := []policy.UserInput{
+		policy.UserInput{ServiceOrgid: "mycomp1", ServiceUrl: "cpu1", Inputs: []policy.Input{policy.Input{Name: "var1", Value: float64(5)}, policy.Input{Name: "var2", Value: "abc"}}},
+	}
+	if compatible, reason, _, err := VerifyUserInputForSingleServiceDef(&s1, nil, nodeUserInput, nil); err != nil {
+		t.Errorf("VerifyUserInputForSingleServiceDef should not have returned error but got: %v", err)
+	} else if !compatible {
+		t.Errorf("The user input should be compatible but got: %v", reason)
+	}
+
+	nodeUserInput[0].Inputs[0].Value = float64(50)
+	if compatible, reason, _, err := VerifyUserInputForSingleServiceDef(&s1, nil, nodeUserInput, nil); err != nil {
+		t.Errorf("VerifyUserInputForSingleServiceDef should not have returned error but got: %v", err)
+	} else if compatible {
+		t.Errorf("The user input should not be compatible because var1 is too large")
+	} else if !strings.Contains(reason, "var1") {
+		t.Errorf("The reason should name var1 but got: %v", reason)
+	}
+
+	nodeUserInput[0].Inputs[0].Value = float64(5)
+	nodeUserInput[0].Inputs[1].Value = "Secret123"
+	if compatible, reason, _, err := VerifyUserInputForSingleServiceDef(&s1, nil, nodeUserInput, nil); err != nil {
+		t.Errorf("VerifyUserInputForSingleServiceDef should not have returned error but got: %v", err)
+	} else if compatible {
+		t.Errorf("The user input should not be compatible because var2 does not match the pattern")
+	} else if strings.Contains(reason, "Secret123") {
+		t.Errorf("The reason should not show the sensitive value but got: %v", reason)
+	}
+}
+
+func Test_VerifyUserInputForSingleServiceDef_requiredIf(t *testing.T) {
+	s1 := ServiceDefinition{
+		"mycomp1",
+		exchange.ServiceDefinition{
+			URL:     "cpu1",
+			Version: "1.0.0",
+			Arch:    "amd64",
+			UserInputs: []exchange.UserInput{
+				exchange.UserInput{Name: "mode", Type: "string", DefaultValue: "local"},
+				exchange.UserInput{Name: "url", Type: "string", InputConstraints: policy.InputConstraints{RequiredIf: &policy.RequiredIf{Name: "mode", Values: []interface{}{"remote"}}}},
+			},
+		},
+	}
+
+	// the url is not required in the default mode
+	nodeUserInput := []policy.UserInput{
+		policy.UserInput{ServiceOrgid: "mycomp1", ServiceUrl: "cpu1", Inputs: []policy.Input{}},
+	}
+	if compatible, reason, _, err := VerifyUserInputForSingleServiceDef(&s1, nil, nodeUserInput, nil); err != nil {
+		t.Errorf("VerifyUserInputForSingleServiceDef should not have returned error but got: %v", err)
+	} else if !compatible {
+		t.Errorf("The user input should be compatible but got: %v", reason)
+	}
+
+	// but it is in the remote mode
+	nodeUserInput[0].Inputs = []policy.Input{policy.Input{Name: "mode", Value: "remote"}}
+	if compatible, reason, _, err := VerifyUserInputForSingleServiceDef(&s1, nil, nodeUserInput, nil); err != nil {
+		t.Errorf("VerifyUserInputForSingleServiceDef should not have returned error but got: %v", err)
+	} else if compatible {
+		t.Errorf("The user input should not be compatible because url is missing")
+	} else if !strings.Contains(reason, "url") || !strings.Contains(reason, "mode") {
+		t.Errorf("The reason should name url and mode but got: %v", reason)
+	}
+
+	nodeUserInput[0].Inputs = append(nodeUserInput[0].Inputs, policy.Input{Name: "url", Value: "https://example.com"})
+	if compatible, reason, _, err := VerifyUserInputForSingleServiceDef(&s1, nil, nodeUserInput, nil); err != nil {
+		t.Errorf("VerifyUserInputForSingleServiceDef should not have returned error but got: %v", err)
+	} else if !compatible {
+		t.Errorf("The user input should be compatible but got: %v", reason)
+	}
+}
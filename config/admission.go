@@ -0,0 +1,56 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"path/filepath"
+	"strings"
+)
+
+// The node-local admission policy. Deployments that break one of the rules are rejected before the node agrees to run
+// them, even when they are correctly signed. The zero value admits every deployment.
+type AdmissionPolicy struct {
+	AllowedRegistries   []string // Images must come from one of these registries or repository prefixes, e.g. docker.io or registry.example.com/myorg. The default allows any registry.
+	ForbidPrivileged    bool     // Reject services that run privileged.
+	ForbidHostNetwork   bool     // Reject services that use the host network.
+	ForbidDevices       bool     // Reject services that map host devices.
+	AllowedBindPaths    []string // Host paths, including sub-directories, that services may bind mount. The default allows any path.
+	MaxMemoryMB         int64    // Every service must declare a memory limit no larger than this. The default is no limit.
+	ForbidUnconfined    bool     // Reject services that turn off seccomp or AppArmor confinement.
+	ForbidCapAdd        bool     // Reject services that add Linux capabilities, other than the AllowedCapabilities.
+	AllowedCapabilities []string // The capabilities that services may add when ForbidCapAdd is set, e.g. NET_BIND_SERVICE.
+}
+
+func (a *AdmissionPolicy) String() string {
+	return fmt.Sprintf("AllowedRegistries: %v, ForbidPrivileged: %v, ForbidHostNetwork: %v, ForbidDevices: %v, AllowedBindPaths: %v, MaxMemoryMB: %v, ForbidUnconfined: %v, ForbidCapAdd: %v, AllowedCapabilities: %v",
+		a.AllowedRegistries, a.ForbidPrivileged, a.ForbidHostNetwork, a.ForbidDevices, a.AllowedBindPaths, a.MaxMemoryMB, a.ForbidUnconfined, a.ForbidCapAdd, a.AllowedCapabilities)
+}
+
+func (a *AdmissionPolicy) IsConfigured() bool {
+	return len(a.AllowedRegistries) != 0 || a.ForbidPrivileged || a.ForbidHostNetwork || a.ForbidDevices || len(a.AllowedBindPaths) != 0 || a.MaxMemoryMB != 0 || a.ForbidUnconfined || a.ForbidCapAdd
+}
+
+func (a *AdmissionPolicy) Validate() error {
+	for _, r := range a.AllowedRegistries {
+		if strings.TrimSpace(r) == "" {
+			return errors.New("AllowedRegistries must not contain empty entries.")
+		}
+	}
+	for _, p := range a.AllowedBindPaths {
+		if !filepath.IsAbs(p) {
+			return fmt.Errorf("AllowedBindPaths must contain absolute paths, %v is not.", p)
+		}
+	}
+	for _, c := range a.AllowedCapabilities {
+		if strings.TrimSpace(c) == "" {
+			return errors.New("AllowedCapabilities must not contain empty entries.")
+		}
+	}
+	if len(a.AllowedCapabilities) != 0 && !a.ForbidCapAdd {
+		return errors.New("AllowedCapabilities only applies when ForbidCapAdd is set.")
+	}
+	if a.MaxMemoryMB < 0 {
+		return fmt.Errorf("MaxMemoryMB must not be negative, is %v.", a.MaxMemoryMB)
+	}
+	return nil
+}
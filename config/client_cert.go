@@ -0,0 +1,199 @@
+package config
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"github.com/golang/glog"
+	"os"
+	"os/exec"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Configuration of the X.509 client certificate that the agent or agbot presents to the exchange for mutual TLS. When
+// the certificate is not configured, the exchange is accessed with the id and token only.
+type ClientCertConfig struct {
+	CertPath           string // The path to the PEM-encoded client certificate, optionally followed by intermediate CA certs.
+	KeyPath            string // The path to the PEM-encoded private key of the client certificate.
+	RenewBeforeExpiryH int    // Renew the certificate this many hours before it expires. The default is 168 (7 days).
+	RenewCommand       string // An executable that renews the certificate by replacing the files at CertPath and KeyPath. It is called with CertPath and KeyPath as arguments.
+}
+
+func (c *ClientCertConfig) String() string {
+	return fmt.Sprintf("CertPath: %v, KeyPath: %v, RenewBeforeExpiryH: %v, RenewCommand: %v", c.CertPath, c.KeyPath, c.RenewBeforeExpiryH, c.RenewCommand)
+}
+
+func (c *ClientCertConfig) IsConfigured() bool {
+	return c.CertPath != "" || c.KeyPath != ""
+}
+
+func (c *ClientCertConfig) GetRenewBeforeExpiryH() int {
+	if c.RenewBeforeExpiryH == 0 {
+		return ClientCertRenewBeforeExpiryH_DEFAULT
+	}
+	return c.RenewBeforeExpiryH
+}
+
+func (c *ClientCertConfig) Validate() error {
+	if !c.IsConfigured() {
+		return nil
+	} else if c.CertPath == "" || c.KeyPath == "" {
+		return errors.New("both CertPath and KeyPath must be specified for the exchange client certificate.")
+	} else if c.RenewBeforeExpiryH < 0 {
+		return fmt.Errorf("RenewBeforeExpiryH must not be negative, is %v.", c.RenewBeforeExpiryH)
+	}
+	return nil
+}
+
+// ClientCertificate holds the client certificate presented to the exchange. The certificate is reloaded from disk
+// whenever the files change, so that a renewed certificate is used without restarting the agent.
+type ClientCertificate struct {
+	config    ClientCertConfig
+	lock      sync.Mutex
+	renewLock sync.Mutex
+	cert      *tls.Certificate
+	modTime   time.Time
+}
+
+func NewClientCertificate(cfg ClientCertConfig) (*ClientCertificate, error) {
+	c := &ClientCertificate{
+		config: cfg,
+	}
+	if err := c.reload(); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+func (c *ClientCertificate) String() string {
+	return fmt.Sprintf("Config: {%v}, NotAfter: %v", c.config.String(), c.NotAfter())
+}
+
+// Suitable for tls.Config.GetClientCertificate. The same certificate is presented regardless of the CAs that
+// the server accepts, the server decides whether or not it trusts it.
+func (c *ClientCertificate) GetClientCertificate(*tls.CertificateRequestInfo) (*tls.Certificate, error) {
+	if err := c.reload(); err != nil {
+		// Keep using the last good certificate if the files are in the middle of being replaced.
+		glog.Errorf("Unable to reload exchange client certificate, error: %v", err)
+	}
+
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	return c.cert, nil
+}
+
+// Load the certificate if it has never been loaded or if the certificate file was modified since the last load.
+func (c *ClientCertificate) reload() error {
+	fi, err := os.Stat(c.config.CertPath)
+	if err != nil {
+		return fmt.Errorf("unable to access client certificate %v, error: %v", c.config.CertPath, err)
+	}
+	kfi, err := os.Stat(c.config.KeyPath)
+	if err != nil {
+		return fmt.Errorf("unable to access client certificate key %v, error: %v", c.config.KeyPath, err)
+	}
+	modTime := fi.ModTime()
+	if kfi.ModTime().After(modTime) {
+		modTime = kfi.ModTime()
+	}
+
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	if c.cert != nil && modTime.Equal(c.modTime) {
+		return nil
+	}
+
+	cert, err := tls.LoadX509KeyPair(c.config.CertPath, c.config.KeyPath)
+	if err != nil {
+		return fmt.Errorf("unable to load client certificate %v with key %v, error: %v", c.config.CertPath, c.config.KeyPath, err)
+	}
+	if cert.Leaf, err = x509.ParseCertificate(cert.Certificate[0]); err != nil {
+		return fmt.Errorf("unable to parse client certificate %v, error: %v", c.config.CertPath, err)
+	}
+
+	c.cert = &cert
+	c.modTime = modTime
+	glog.V(3).Infof("Loaded exchange client certificate %v for %v, expires at %v", c.config.CertPath, cert.Leaf.Subject.CommonName, cert.Leaf.NotAfter)
+	return nil
+}
+
+func (c *ClientCertificate) leaf() *x509.Certificate {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	if c.cert == nil {
+		return nil
+	}
+	return c.cert.Leaf
+}
+
+func (c *ClientCertificate) NotAfter() time.Time {
+	if leaf := c.leaf(); leaf != nil {
+		return leaf.NotAfter
+	}
+	return time.Time{}
+}
+
+// Returns the org and id that the certificate identifies.
+func (c *ClientCertificate) Identity() (string, string, error) {
+	if leaf := c.leaf(); leaf == nil {
+		return "", "", errors.New("no client certificate loaded")
+	} else {
+		return GetCertificateIdentity(leaf)
+	}
+}
+
+// The identity of a node or agbot certificate is taken from the subject. The common name is either the org
+// qualified id (org/id), or it is the id and the org is the first organization of the subject.
+func GetCertificateIdentity(cert *x509.Certificate) (string, string, error) {
+	cn := cert.Subject.CommonName
+	if parts := strings.Split(cn, "/"); len(parts) == 2 && parts[0] != "" && parts[1] != "" {
+		return parts[0], parts[1], nil
+	} else if cn != "" && !strings.Contains(cn, "/") && len(cert.Subject.Organization) != 0 && cert.Subject.Organization[0] != "" {
+		return cert.Subject.Organization[0], cn, nil
+	}
+	return "", "", fmt.Errorf("the client certificate subject %v does not identify an org and id, the common name must be org/id or the organization must be set.", cert.Subject)
+}
+
+// Returns true if the certificate is within the renewal window.
+func (c *ClientCertificate) NeedsRenewal(now time.Time) bool {
+	notAfter := c.NotAfter()
+	return !notAfter.IsZero() && now.Add(time.Duration(c.config.GetRenewBeforeExpiryH())*time.Hour).After(notAfter)
+}
+
+// Renew the certificate if it is about to expire. The renew command replaces the certificate files, which are
+// then picked up by the next TLS handshake.
+func (c *ClientCertificate) CheckRenewal() error {
+	c.renewLock.Lock()
+	defer c.renewLock.Unlock()
+
+	if err := c.reload(); err != nil {
+		return err
+	} else if !c.NeedsRenewal(time.Now()) {
+		return nil
+	} else if c.config.RenewCommand == "" {
+		glog.Warningf("Exchange client certificate %v expires at %v and there is no RenewCommand configured to renew it.", c.config.CertPath, c.NotAfter())
+		return nil
+	}
+
+	glog.V(3).Infof("Renewing exchange client certificate %v, it expires at %v", c.config.CertPath, c.NotAfter())
+
+	ctx, cancel := context.WithTimeout(context.Background(), ClientCertRenewTimeoutS*time.Second)
+	defer cancel()
+
+	cmd := exec.CommandContext(ctx, c.config.RenewCommand, c.config.CertPath, c.config.KeyPath)
+	if out, err := cmd.CombinedOutput(); err != nil {
+		return fmt.Errorf("renew command %v failed, error: %v, output: %v", c.config.RenewCommand, err, string(out))
+	} else if err := c.reload(); err != nil {
+		return err
+	} else if c.NeedsRenewal(time.Now()) {
+		return fmt.Errorf("renew command %v did not renew the certificate, it still expires at %v", c.config.RenewCommand, c.NotAfter())
+	}
+
+	glog.V(3).Infof("Renewed exchange client certificate %v, it now expires at %v", c.config.CertPath, c.NotAfter())
+	return nil
+}
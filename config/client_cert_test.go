@@ -0,0 +1,116 @@
+// +build unit
+
+package config
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"io/ioutil"
+	"math/big"
+	"os"
+	"path"
+	"testing"
+	"time"
+)
+
+func Test_ClientCertificate_load_and_renew(t *testing.T) {
+
+	dir, err := ioutil.TempDir("", "clientcerttest")
+	if err != nil {
+		t.Fatalf("unable to create temp dir, error %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	cfg := ClientCertConfig{
+		CertPath: path.Join(dir, "cert.pem"),
+		KeyPath:  path.Join(dir, "key.pem"),
+	}
+
+	// a certificate that expires within the default renewal window
+	writeTestClientCert(t, cfg, pkix.Name{CommonName: "myorg/node1"}, time.Now().Add(24*time.Hour))
+
+	cc, err := NewClientCertificate(cfg)
+	if err != nil {
+		t.Fatalf("unable to load client certificate, error %v", err)
+	}
+
+	if org, id, err := cc.Identity(); err != nil || org != "myorg" || id != "node1" {
+		t.Errorf("wrong identity %v/%v, error %v", org, id, err)
+	} else if !cc.NeedsRenewal(time.Now()) {
+		t.Errorf("certificate expiring at %v should need renewal", cc.NotAfter())
+	} else if cert, err := cc.GetClientCertificate(nil); err != nil || cert == nil {
+		t.Errorf("there should be a client certificate, error %v", err)
+	}
+
+	// replacing the files is picked up without creating a new object
+	writeTestClientCert(t, cfg, pkix.Name{CommonName: "node2", Organization: []string{"otherorg"}}, time.Now().Add(365*24*time.Hour))
+	later := time.Now().Add(time.Second)
+	os.Chtimes(cfg.CertPath, later, later)
+
+	if err := cc.CheckRenewal(); err != nil {
+		t.Errorf("unexpected error %v", err)
+	} else if org, id, err := cc.Identity(); err != nil || org != "otherorg" || id != "node2" {
+		t.Errorf("wrong identity %v/%v after reload, error %v", org, id, err)
+	} else if cc.NeedsRenewal(time.Now()) {
+		t.Errorf("certificate expiring at %v should not need renewal", cc.NotAfter())
+	}
+}
+
+func Test_ClientCertConfig_Validate(t *testing.T) {
+
+	if err := (&ClientCertConfig{}).Validate(); err != nil {
+		t.Errorf("empty client cert config should be valid, error %v", err)
+	} else if err := (&ClientCertConfig{CertPath: "/tmp/cert.pem"}).Validate(); err == nil {
+		t.Errorf("client cert config without key should be invalid")
+	} else if err := (&ClientCertConfig{CertPath: "/tmp/cert.pem", KeyPath: "/tmp/key.pem", RenewBeforeExpiryH: -1}).Validate(); err == nil {
+		t.Errorf("client cert config with negative renewal window should be invalid")
+	}
+}
+
+func Test_GetCertificateIdentity_invalid(t *testing.T) {
+
+	invalid := []pkix.Name{
+		{CommonName: "node1"},
+		{CommonName: "a/b/c", Organization: []string{"myorg"}},
+		{CommonName: "/node1"},
+		{Organization: []string{"myorg"}},
+	}
+	for _, n := range invalid {
+		if _, _, err := GetCertificateIdentity(&x509.Certificate{Subject: n}); err == nil {
+			t.Errorf("subject %v should not have an identity", n)
+		}
+	}
+}
+
+func writeTestClientCert(t *testing.T, cfg ClientCertConfig, subject pkix.Name, notAfter time.Time) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("unable to generate key, error %v", err)
+	}
+
+	template := x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      subject,
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     notAfter,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, &template, &template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatalf("unable to create certificate, error %v", err)
+	}
+	keyDer, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatalf("unable to marshal key, error %v", err)
+	}
+
+	if err := ioutil.WriteFile(cfg.CertPath, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0600); err != nil {
+		t.Fatalf("unable to write certificate, error %v", err)
+	} else if err := ioutil.WriteFile(cfg.KeyPath, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDer}), 0600); err != nil {
+		t.Fatalf("unable to write key, error %v", err)
+	}
+}
@@ -47,8 +47,9 @@ func (c *Collaborators) String() string {
 
 type HTTPClientFactory struct {
 	NewHTTPClient func(overrideTimeoutS *uint) *http.Client
-	RetryCount    int // number of retries for tranport error.
-	RetryInterval int // retry interval in second for tranport error. The default is 10 seconds.
+	ClientCert    *ClientCertificate // the client certificate presented to the exchange, nil if not configured.
+	RetryCount    int                // number of retries for tranport error.
+	RetryInterval int                // retry interval in second for tranport error. The default is 10 seconds.
 }
 
 // default retry interval is 10 seconds
@@ -119,6 +120,22 @@ func newHTTPClientFactory(hConfig HorizonConfig) (*HTTPClientFactory, error) {
 		glog.V(4).Infof("Read CSS cert from provided file %v", hConfig.AgreementBot.CSSSSLCert)
 	}
 
+	proxyCaBytes, err := hConfig.Edge.Proxy.ReadCACerts()
+	if err != nil {
+		return nil, err
+	} else if len(proxyCaBytes) != 0 {
+		glog.V(4).Infof("Read proxy CA certs from provided file %v", hConfig.Edge.Proxy.CACertsPath)
+	}
+
+	// The proxy, if configured, is used for every client created by this factory, regardless of the
+	// HTTP_PROXY and HTTPS_PROXY envvars.
+	proxyFunc, err := hConfig.Edge.Proxy.NewProxyFunc()
+	if err != nil {
+		return nil, err
+	} else if proxyFunc != nil {
+		glog.V(3).Infof("HTTP clients will use proxy %v, except for destinations %v", hConfig.Edge.Proxy.URL, hConfig.Edge.Proxy.NoProxy)
+	}
+
 	var tlsConf tls.Config
 	tlsConf.InsecureSkipVerify = false
 	// do not allow negotiation to previous versions of TLS
@@ -147,9 +164,23 @@ func newHTTPClientFactory(hConfig HorizonConfig) (*HTTPClientFactory, error) {
 	if len(cssCaBytes) != 0 {
 		certPool.AppendCertsFromPEM(cssCaBytes)
 	}
+	if len(proxyCaBytes) != 0 {
+		certPool.AppendCertsFromPEM(proxyCaBytes)
+	}
 
 	tlsConf.RootCAs = certPool
 
+	// Present a client certificate to the exchange for mutual TLS if one is configured, otherwise the exchange
+	// authenticates the agent with its token only.
+	var clientCert *ClientCertificate
+	if certConfig := hConfig.GetExchangeClientCertConfig(); certConfig.IsConfigured() {
+		if clientCert, err = NewClientCertificate(certConfig); err != nil {
+			return nil, err
+		}
+		tlsConf.GetClientCertificate = clientCert.GetClientCertificate
+		glog.V(3).Infof("HTTP clients will present client certificate %v", certConfig.CertPath)
+	}
+
 	tlsConf.BuildNameToCertificate()
 
 	clientFunc := func(overrideTimeoutS *uint) *http.Client {
@@ -167,6 +198,7 @@ func newHTTPClientFactory(hConfig HorizonConfig) (*HTTPClientFactory, error) {
 			// to the total payload size you expect
 			Timeout: time.Second * time.Duration(timeoutS),
 			Transport: &http.Transport{
+				Proxy: proxyFunc,
 				Dial: (&net.Dialer{
 					Timeout:   20 * time.Second,
 					KeepAlive: 60 * time.Second,
@@ -183,6 +215,7 @@ func newHTTPClientFactory(hConfig HorizonConfig) (*HTTPClientFactory, error) {
 
 	return &HTTPClientFactory{
 		NewHTTPClient: clientFunc,
+		ClientCert:    clientCert,
 		RetryCount:    0,
 		RetryInterval: 10,
 	}, nil
@@ -39,32 +39,48 @@ type Config struct {
 	ExchangeURL                      string
 	DefaultHTTPClientTimeoutS        uint
 	PolicyPath                       string
-	ExchangeHeartbeat                int       // Seconds between heartbeats
-	ExchangeVersionCheckIntervalM    int64     // Exchange version check interval in minutes. The default is 720. This is now deprecated with the usage of /changes API which returns exchange version on every call.
-	AgreementTimeoutS                uint64    // Number of seconds to wait before declaring agreement not finalized in blockchain
-	DVPrefix                         string    // When passing agreement ids into a workload container, add this prefix to the agreement id
-	RegistrationDelayS               uint64    // The number of seconds to wait after blockchain init before registering with the exchange. This is for testing initialization ONLY.
-	ExchangeMessageTTL               int       // The number of seconds the exchange will keep this message before automatically deleting it
-	ExchangeMessageDynamicPoll       bool      // Will the runtime dynamically increase the message poll interval? Default is true. Set to false to turn off dynamic message poll interval adjustments.
-	ExchangeMessagePollInterval      int       // The number of seconds the node will wait between polls to the exchange. This is the starting value, but at runtime this interval will increase if there is no message activity to reduce load on the exchange. If ExchangeMessageDynamicPoll is false, then the value of this field will never be changed by the runtime.
-	ExchangeMessagePollMaxInterval   int       // As the runtime increases the ExchangeMessagePollInterval, this value is the maximum that value can attain.
-	ExchangeMessagePollIncrement     int       // The number of seconds to increment the ExchangeMessagePollInterval when its time to increase the poll interval.
-	UserPublicKeyPath                string    // The location to store user keys uploaded through the REST API
-	ReportDeviceStatus               bool      // whether to report the device status to the exchange or not.
-	TrustCertUpdatesFromOrg          bool      // whether to trust the certs provided by the organization on the exchange or not.
-	TrustDockerAuthFromOrg           bool      // whether to turst the docker auths provided by the organization on the exchange or not.
-	ServiceUpgradeCheckIntervalS     int64     // service upgrade check interval in seconds. The default is 300 seconds.
-	MultipleAnaxInstances            bool      // multiple anax instances running on the same machine
-	DefaultServiceRetryCount         int       // the default service retry count if retries are not specified by the policy file. The default value is 2.
-	DefaultServiceRetryDuration      uint64    // the default retry duration in seconds. The next retry cycle occurs after the duration. The default value is 600
-	DefaultNodePolicyFile            string    // the default node policy file name.
-	NodeCheckIntervalS               int       // the node check interval. The default is 15 seconds.
-	NodePolicyCheckIntervalS         int       // the node policy check interval. The default is 15 seconds.
-	FileSyncService                  FSSConfig // The config for the embedded ESS sync service.
-	SurfaceErrorTimeoutS             int       // How long surfaced errors will remain active after they're created. Default is no timeout
-	SurfaceErrorCheckIntervalS       int       // Deprecated. Used to be how often the node will check for errors that are no longer active and update the exchange. Default is 15 seconds
-	SurfaceErrorAgreementPersistentS int       // How long an agreement needs to persist before it is considered persistent and the related errors are dismisse. Default is 90 seconds
-	InitialPollingBuffer             int       // the number of seconds to wait before increasing the polling interval while there is no agreement on the node.
+	ExchangeHeartbeat                int                    // Seconds between heartbeats
+	ExchangeVersionCheckIntervalM    int64                  // Exchange version check interval in minutes. The default is 720. This is now deprecated with the usage of /changes API which returns exchange version on every call.
+	AgreementTimeoutS                uint64                 // Number of seconds to wait before declaring agreement not finalized in blockchain
+	DVPrefix                         string                 // When passing agreement ids into a workload container, add this prefix to the agreement id
+	RegistrationDelayS               uint64                 // The number of seconds to wait after blockchain init before registering with the exchange. This is for testing initialization ONLY.
+	ExchangeMessageTTL               int                    // The number of seconds the exchange will keep this message before automatically deleting it
+	ExchangeMessageDynamicPoll       bool                   // Will the runtime dynamically increase the message poll interval? Default is true. Set to false to turn off dynamic message poll interval adjustments.
+	ExchangeMessagePollInterval      int                    // The number of seconds the node will wait between polls to the exchange. This is the starting value, but at runtime this interval will increase if there is no message activity to reduce load on the exchange. If ExchangeMessageDynamicPoll is false, then the value of this field will never be changed by the runtime.
+	ExchangeMessagePollMaxInterval   int                    // As the runtime increases the ExchangeMessagePollInterval, this value is the maximum that value can attain.
+	ExchangeMessagePollIncrement     int                    // The number of seconds to increment the ExchangeMessagePollInterval when its time to increase the poll interval.
+	UserPublicKeyPath                string                 // The location to store user keys uploaded through the REST API
+	ReportDeviceStatus               bool                   // whether to report the device status to the exchange or not.
+	TrustCertUpdatesFromOrg          bool                   // whether to trust the certs provided by the organization on the exchange or not.
+	TrustDockerAuthFromOrg           bool                   // whether to turst the docker auths provided by the organization on the exchange or not.
+	ServiceUpgradeCheckIntervalS     int64                  // service upgrade check interval in seconds. The default is 300 seconds.
+	MultipleAnaxInstances            bool                   // multiple anax instances running on the same machine
+	DefaultServiceRetryCount         int                    // the default service retry count if retries are not specified by the policy file. The default value is 2.
+	DefaultServiceRetryDuration      uint64                 // the default retry duration in seconds. The next retry cycle occurs after the duration. The default value is 600
+	DefaultNodePolicyFile            string                 // the default node policy file name.
+	NodeCheckIntervalS               int                    // the node check interval. The default is 15 seconds.
+	NodePolicyCheckIntervalS         int                    // the node policy check interval. The default is 15 seconds.
+	FileSyncService                  FSSConfig              // The config for the embedded ESS sync service.
+	SurfaceErrorTimeoutS             int                    // How long surfaced errors will remain active after they're created. Default is no timeout
+	SurfaceErrorCheckIntervalS       int                    // Deprecated. Used to be how often the node will check for errors that are no longer active and update the exchange. Default is 15 seconds
+	SurfaceErrorAgreementPersistentS int                    // How long an agreement needs to persist before it is considered persistent and the related errors are dismisse. Default is 90 seconds
+	InitialPollingBuffer             int                    // the number of seconds to wait before increasing the polling interval while there is no agreement on the node.
+	Proxy                            ProxyConfig            // The HTTP(S) proxy used for outbound connections. The default is no proxy.
+	ExchangeClientCert               ClientCertConfig       // The client certificate presented to the exchange. The default is to authenticate with the node token only.
+	TokenRotationIntervalH           int                    // How often the node replaces its exchange token with a new random token. The default is 0, the token is never rotated.
+	AdmissionPolicy                  AdmissionPolicy        // Rules that service deployments must follow to run on this node. The default admits all deployments.
+	ContainerReconcileIntervalS      int                    // How often the containers are listed to find failed ones while docker events are received. The default is 300 seconds.
+	NodePropertyProviders            []NodePropertyProvider // Providers that add read-only properties to the node policy. The default is no providers.
+	UserInputRollbackWindowS         int                    // How long after a node user input change a failing service rolls the change back. The default is 600 seconds, a negative value turns the rollback off.
+	UserInputSecretKeyFile           string                 // The file holding the key that encrypts secret node user input in the local database. The default is userinput.key in HZN_VAR_BASE.
+	SecretFilesPath                  string                 // The directory, on tmpfs, where the secret user input of running services is written. The default is /var/run/horizon/secrets.
+	SecretProviders                  []SecretProvider       // Providers that resolve the secret:// references in user input. The default is no providers.
+	SecretRefreshIntervalS           int                    // How often the referenced secrets of running services are checked for rotation. The default is 60 seconds.
+	MMSObjectFilesPath               string                 // The directory where the model management objects that are delivered into service containers are written. The default is mms-objects in HZN_VAR_BASE.
+	MMSObjectRefreshIntervalS        int                    // How often new versions of the model management objects that are delivered into service containers are looked for. The default is 30 seconds.
+	VolumeBackupPath                 string                 // The directory where the backups of the named volumes of services are written. The default is volume-backups in HZN_VAR_BASE.
+	VolumeBackupsKept                int                    // How many backups of each named volume are kept. The default is 3.
+	ProcessExecutablePath            string                 // The directory where the verified executables of process deployments are kept, which only the agent can write. The default is process-executables in HZN_VAR_BASE.
 
 	// these Ids could be provided in config or discovered after startup by the system
 	BlockchainAccountId        string
@@ -111,6 +127,7 @@ type AGConfig struct {
 	AgreementBatchSize           uint64           // The number of nodes that the agbot will process in a batch.
 	FullRescanS                  uint64           // The number of seconds between policy scans when there have been no changes reported by the exchange.
 	MaxExchangeChanges           int              // The maximum number of exchange changes to request on a given call the exchange /changes API.
+	ExchangeClientCert           ClientCertConfig // The client certificate presented to the exchange. The default is to authenticate with ExchangeToken only.
 }
 
 func (c *HorizonConfig) UserPublicKeyPath() string {
@@ -157,6 +174,79 @@ func (c *HorizonConfig) GetAgbotFullRescan() uint64 {
 	return c.AgreementBot.FullRescanS
 }
 
+// Returns the exchange client certificate config of the node, or of the agbot if the node does not have one.
+func (c *HorizonConfig) GetExchangeClientCertConfig() ClientCertConfig {
+	if c.Edge.ExchangeClientCert.IsConfigured() {
+		return c.Edge.ExchangeClientCert
+	}
+	return c.AgreementBot.ExchangeClientCert
+}
+
+// Returns the file holding the key that encrypts secret node user input in the local database.
+func (c *HorizonConfig) GetUserInputSecretKeyFile() string {
+	if c.Edge.UserInputSecretKeyFile == "" {
+		return path.Join(getDefaultBase(), HZN_USERINPUT_SECRET_KEY_FILE)
+	}
+	return c.Edge.UserInputSecretKeyFile
+}
+
+// Returns the directory where the secret user input of running services is written.
+func (c *HorizonConfig) GetSecretFilesPath() string {
+	if c.Edge.SecretFilesPath == "" {
+		return path.Join(HZN_FSS_DOMAIN_SOCKET_PATH, HZN_SECRETS_PATH)
+	}
+	return c.Edge.SecretFilesPath
+}
+
+// Returns how often the referenced secrets of running services are checked for rotation.
+func (c *HorizonConfig) GetSecretRefreshInterval() int {
+	if c.Edge.SecretRefreshIntervalS == 0 {
+		return DEFAULT_SECRET_REFRESH_INTERVALS
+	}
+	return c.Edge.SecretRefreshIntervalS
+}
+
+// Returns the directory where the model management objects that are delivered into service containers are written.
+func (c *HorizonConfig) GetMMSObjectFilesPath() string {
+	if c.Edge.MMSObjectFilesPath == "" {
+		return path.Join(getDefaultBase(), HZN_MMS_OBJECTS_PATH)
+	}
+	return c.Edge.MMSObjectFilesPath
+}
+
+// Returns how often new versions of the model management objects that are delivered into service containers are
+// looked for.
+func (c *HorizonConfig) GetMMSObjectRefreshInterval() int {
+	if c.Edge.MMSObjectRefreshIntervalS == 0 {
+		return DEFAULT_MMS_OBJECT_REFRESH_INTERVALS
+	}
+	return c.Edge.MMSObjectRefreshIntervalS
+}
+
+// Returns the directory where the backups of the named volumes of services are written.
+func (c *HorizonConfig) GetVolumeBackupPath() string {
+	if c.Edge.VolumeBackupPath == "" {
+		return path.Join(getDefaultBase(), HZN_VOLUME_BACKUPS_PATH)
+	}
+	return c.Edge.VolumeBackupPath
+}
+
+// Returns how many backups of each named volume are kept.
+func (c *HorizonConfig) GetVolumeBackupsKept() int {
+	if c.Edge.VolumeBackupsKept == 0 {
+		return DEFAULT_VOLUME_BACKUPS_KEPT
+	}
+	return c.Edge.VolumeBackupsKept
+}
+
+// Returns the directory where the verified executables of process deployments are kept.
+func (c *HorizonConfig) GetProcessExecutablePath() string {
+	if c.Edge.ProcessExecutablePath == "" {
+		return path.Join(getDefaultBase(), HZN_PROCESS_EXECUTABLES_PATH)
+	}
+	return c.Edge.ProcessExecutablePath
+}
+
 func getDefaultBase() string {
 	basePath := os.Getenv("HZN_VAR_BASE")
 	if basePath == "" {
@@ -261,6 +351,14 @@ func Read(file string) (*HorizonConfig, error) {
 			config.Edge.SurfaceErrorAgreementPersistentS = 90
 		}
 
+		if config.Edge.ContainerReconcileIntervalS == 0 {
+			config.Edge.ContainerReconcileIntervalS = 300
+		}
+
+		if config.Edge.UserInputRollbackWindowS == 0 {
+			config.Edge.UserInputRollbackWindowS = 600
+		}
+
 		// set default retry parameters
 		// the default DefaultServiceRetryCount is 2. It means 2 tries including the original one.
 		// so it is actually 1 retry.
@@ -293,6 +391,43 @@ func Read(file string) (*HorizonConfig, error) {
 			config.AgreementBot.PolicyPath = strings.TrimRight(config.AgreementBot.PolicyPath, "/") + "/"
 		}
 
+		if err := config.Edge.Proxy.Validate(); err != nil {
+			return nil, fmt.Errorf("Invalid proxy configuration: %v", err)
+		}
+
+		if err := config.Edge.AdmissionPolicy.Validate(); err != nil {
+			return nil, fmt.Errorf("Invalid Edge AdmissionPolicy: %v", err)
+		}
+
+		if err := ValidateNodePropertyProviders(config.Edge.NodePropertyProviders); err != nil {
+			return nil, fmt.Errorf("Invalid Edge NodePropertyProviders: %v", err)
+		}
+
+		if err := ValidateSecretProviders(config.Edge.SecretProviders); err != nil {
+			return nil, fmt.Errorf("Invalid Edge SecretProviders: %v", err)
+		} else if config.Edge.SecretRefreshIntervalS < 0 {
+			return nil, fmt.Errorf("Invalid Edge SecretRefreshIntervalS %v, must not be negative", config.Edge.SecretRefreshIntervalS)
+		}
+
+		if config.Edge.MMSObjectRefreshIntervalS < 0 {
+			return nil, fmt.Errorf("Invalid Edge MMSObjectRefreshIntervalS %v, must not be negative", config.Edge.MMSObjectRefreshIntervalS)
+		}
+
+		if config.Edge.VolumeBackupsKept < 0 {
+			return nil, fmt.Errorf("Invalid Edge VolumeBackupsKept %v, must not be negative", config.Edge.VolumeBackupsKept)
+		}
+
+		if config.Edge.TokenRotationIntervalH < 0 {
+			return nil, fmt.Errorf("Invalid Edge TokenRotationIntervalH %v, must not be negative", config.Edge.TokenRotationIntervalH)
+		}
+
+		if err := config.Edge.ExchangeClientCert.Validate(); err != nil {
+			return nil, fmt.Errorf("Invalid Edge exchange client certificate configuration: %v", err)
+		}
+		if err := config.AgreementBot.ExchangeClientCert.Validate(); err != nil {
+			return nil, fmt.Errorf("Invalid AgreementBot exchange client certificate configuration: %v", err)
+		}
+
 		// now make collaborators instance and assign it to member in this config
 		collaborators, err := NewCollaborators(config)
 		if err != nil {
@@ -353,6 +488,21 @@ func (con *Config) String() string {
 		", NodeCheckIntervalS: %v"+
 		", FileSyncService: {%v}"+
 		", InitialPollingBuffer: {%v}"+
+		", Proxy: {%v}"+
+		", ExchangeClientCert: {%v}"+
+		", TokenRotationIntervalH: %v"+
+		", AdmissionPolicy: {%v}"+
+		", NodePropertyProviders: %v"+
+		", UserInputRollbackWindowS: %v"+
+		", UserInputSecretKeyFile: %v"+
+		", SecretFilesPath: %v"+
+		", SecretProviders: %v"+
+		", SecretRefreshIntervalS: %v"+
+		", MMSObjectFilesPath: %v"+
+		", MMSObjectRefreshIntervalS: %v"+
+		", VolumeBackupPath: %v"+
+		", VolumeBackupsKept: %v"+
+		", ProcessExecutablePath: %v"+
 		", BlockchainAccountId: %v"+
 		", BlockchainDirectoryAddress %v",
 		con.ServiceStorage, con.APIListen, con.DBPath, con.DockerEndpoint, con.DockerCredFilePath, con.DefaultCPUSet,
@@ -362,7 +512,7 @@ func (con *Config) String() string {
 		con.ExchangeMessagePollMaxInterval, con.ExchangeMessagePollIncrement, con.UserPublicKeyPath, con.ReportDeviceStatus,
 		con.TrustCertUpdatesFromOrg, con.TrustDockerAuthFromOrg, con.ServiceUpgradeCheckIntervalS, con.MultipleAnaxInstances,
 		con.DefaultServiceRetryCount, con.DefaultServiceRetryDuration, con.NodeCheckIntervalS, con.FileSyncService.String(),
-		con.InitialPollingBuffer, con.BlockchainAccountId, con.BlockchainDirectoryAddress)
+		con.InitialPollingBuffer, con.Proxy.String(), con.ExchangeClientCert.String(), con.TokenRotationIntervalH, con.AdmissionPolicy.String(), con.NodePropertyProviders, con.UserInputRollbackWindowS, con.UserInputSecretKeyFile, con.SecretFilesPath, con.SecretProviders, con.SecretRefreshIntervalS, con.MMSObjectFilesPath, con.MMSObjectRefreshIntervalS, con.VolumeBackupPath, con.VolumeBackupsKept, con.ProcessExecutablePath, con.BlockchainAccountId, con.BlockchainDirectoryAddress)
 }
 
 func (agc *AGConfig) String() string {
@@ -400,12 +550,13 @@ func (agc *AGConfig) String() string {
 		", CheckUpdatedPolicyS: %v"+
 		", CSSURL: %v"+
 		", CSSSSLCert: %v"+
-		", AgreementBatchSize: %v",
+		", AgreementBatchSize: %v"+
+		", ExchangeClientCert: {%v}",
 		agc.TxLostDelayTolerationSeconds, agc.AgreementWorkers, agc.DBPath, agc.Postgresql.String(),
 		agc.PartitionStale, agc.ProtocolTimeoutS, agc.AgreementTimeoutS, agc.NoDataIntervalS, agc.ActiveAgreementsURL,
 		agc.ActiveAgreementsUser, mask, agc.PolicyPath, agc.NewContractIntervalS, agc.ProcessGovernanceIntervalS,
 		agc.IgnoreContractWithAttribs, agc.ExchangeURL, agc.ExchangeHeartbeat, agc.ExchangeId,
 		mask, agc.DVPrefix, agc.ActiveDeviceTimeoutS, agc.ExchangeMessageTTL, agc.MessageKeyPath, mask, agc.APIListen,
 		agc.SecureAPIListenHost, agc.SecureAPIListenPort, agc.SecureAPIServerCert, agc.SecureAPIServerKey,
-		agc.PurgeArchivedAgreementHours, agc.CheckUpdatedPolicyS, agc.CSSURL, agc.CSSSSLCert, agc.AgreementBatchSize)
+		agc.PurgeArchivedAgreementHours, agc.CheckUpdatedPolicyS, agc.CSSURL, agc.CSSSSLCert, agc.AgreementBatchSize, agc.ExchangeClientCert.String())
 }
@@ -65,3 +65,28 @@ func Test_enrichFromEnvvars_success(t *testing.T) {
 	}
 
 }
+
+func Test_ValidateNodePropertyProviders(t *testing.T) {
+
+	providers := []NodePropertyProvider{
+		{Name: "site", Type: PROPERTY_PROVIDER_FILE, Path: "/etc/horizon/site.json"},
+		{Name: "sensors", Type: PROPERTY_PROVIDER_EXEC, Command: "/usr/bin/list-sensors", IntervalS: 60},
+		{Name: "firmware", Type: "firmware"},
+	}
+	if err := ValidateNodePropertyProviders(providers); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+
+	bad := [][]NodePropertyProvider{
+		{{Name: "", Type: PROPERTY_PROVIDER_FILE, Path: "/etc/site.json"}},
+		{{Name: "site", Type: PROPERTY_PROVIDER_FILE, Path: "site.json"}},
+		{{Name: "sensors", Type: PROPERTY_PROVIDER_EXEC}},
+		{{Name: "sensors", Type: PROPERTY_PROVIDER_EXEC, Command: "/bin/true", IntervalS: -1}},
+		{providers[0], providers[0]},
+	}
+	for _, b := range bad {
+		if err := ValidateNodePropertyProviders(b); err == nil {
+			t.Errorf("expected an error for %v", b)
+		}
+	}
+}
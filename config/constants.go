@@ -42,6 +42,30 @@ const HZN_FSS_AUTH_MOUNT = "/" + HZN_FSS_AUTH_PATH
 // The name of the authentication file that a service can use to authenticate to the FSS (ESS) API.
 const HZN_FSS_AUTH_FILE = "auth.json"
 
+// The name of the file that holds the key that encrypts secret node user input in the local database.
+const HZN_USERINPUT_SECRET_KEY_FILE = "userinput.key"
+
+// The relative path of the secret user input files of running services. This path should be combined with HZN_FSS_DOMAIN_SOCKET_PATH.
+const HZN_SECRETS_PATH = "secrets"
+
+// The name of the file mount where a service finds its secret user input, one file per variable.
+const HZN_SECRETS_MOUNT = "/run/secrets"
+
+// The relative path of the model management objects that are delivered into service containers. This path should be combined with HZN_VAR_BASE.
+const HZN_MMS_OBJECTS_PATH = "mms-objects"
+
+// The default for how often new versions of the model management objects that are delivered into service containers are looked for.
+const DEFAULT_MMS_OBJECT_REFRESH_INTERVALS = 30
+
+// The relative path of the backups of the named volumes of services. This path should be combined with HZN_VAR_BASE.
+const HZN_VOLUME_BACKUPS_PATH = "volume-backups"
+
+// The default number of backups of each named volume that are kept.
+const DEFAULT_VOLUME_BACKUPS_KEPT = 3
+
+// The relative path of the verified executables of process deployments. This path should be combined with HZN_VAR_BASE.
+const HZN_PROCESS_EXECUTABLES_PATH = "process-executables"
+
 // The relative path of SSL client certificate used by services to access the sync service.
 const HZN_FSS_CERT_PATH = "ess-cert"
 
@@ -80,3 +104,9 @@ const AgbotFullRescan_DEFAULT = 600
 
 // The maximum number of changes to retrieve at once from the exchange
 const AgbotMaxChanges_DEFAULT = 1000
+
+// The default number of hours before expiry that the exchange client certificate is renewed
+const ClientCertRenewBeforeExpiryH_DEFAULT = 168
+
+// The maximum number of seconds that the client certificate renew command is allowed to run
+const ClientCertRenewTimeoutS = 120
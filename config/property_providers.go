@@ -0,0 +1,91 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"path/filepath"
+)
+
+// The built-in node property provider types. Other types can be registered by the nodeproperty package.
+const (
+	PROPERTY_PROVIDER_EXEC = "exec" // Runs a command that prints the properties on stdout.
+	PROPERTY_PROVIDER_FILE = "file" // Reads the properties from a local file.
+)
+
+// The defaults for the node property providers.
+const (
+	DEFAULT_PROPERTY_PROVIDER_INTERVALS = 300
+	DEFAULT_PROPERTY_PROVIDER_TIMEOUTS  = 30
+)
+
+// A node property provider adds read-only properties to the node policy. The properties are collected on an interval,
+// and the node policy is updated when they change. Both the exec and the file provider produce a JSON array of
+// properties in the node policy format, e.g. [{"name":"siteId","value":"store-42"}].
+type NodePropertyProvider struct {
+	Name      string   // A unique name for the provider, used in logs and event log messages.
+	Type      string   // exec, file, or the type of a provider that has been registered with the agent.
+	Command   string   // The absolute path of the command that the exec provider runs.
+	Args      []string // The arguments of the command.
+	Path      string   // The absolute path of the file that the file provider reads.
+	IntervalS int      // How often the properties are collected. The default is 300 seconds.
+	TimeoutS  int      // How long the exec provider waits for the command to finish. The default is 30 seconds.
+}
+
+func (p *NodePropertyProvider) String() string {
+	return fmt.Sprintf("Name: %v, Type: %v, Command: %v, Args: %v, Path: %v, IntervalS: %v, TimeoutS: %v",
+		p.Name, p.Type, p.Command, p.Args, p.Path, p.IntervalS, p.TimeoutS)
+}
+
+func (p *NodePropertyProvider) GetInterval() int {
+	if p.IntervalS == 0 {
+		return DEFAULT_PROPERTY_PROVIDER_INTERVALS
+	}
+	return p.IntervalS
+}
+
+func (p *NodePropertyProvider) GetTimeout() int {
+	if p.TimeoutS == 0 {
+		return DEFAULT_PROPERTY_PROVIDER_TIMEOUTS
+	}
+	return p.TimeoutS
+}
+
+// Validate the fields that every provider uses, and the fields of the built-in provider types. The type of a
+// registered provider is checked when the provider is created.
+func (p *NodePropertyProvider) Validate() error {
+	if p.Name == "" {
+		return errors.New("Name must be set.")
+	} else if p.Type == "" {
+		return fmt.Errorf("Type of provider %v must be set.", p.Name)
+	} else if p.IntervalS < 0 {
+		return fmt.Errorf("IntervalS of provider %v must not be negative, is %v.", p.Name, p.IntervalS)
+	} else if p.TimeoutS < 0 {
+		return fmt.Errorf("TimeoutS of provider %v must not be negative, is %v.", p.Name, p.TimeoutS)
+	}
+
+	switch p.Type {
+	case PROPERTY_PROVIDER_EXEC:
+		if !filepath.IsAbs(p.Command) {
+			return fmt.Errorf("Command of provider %v must be an absolute path, %v is not.", p.Name, p.Command)
+		}
+	case PROPERTY_PROVIDER_FILE:
+		if !filepath.IsAbs(p.Path) {
+			return fmt.Errorf("Path of provider %v must be an absolute path, %v is not.", p.Name, p.Path)
+		}
+	}
+	return nil
+}
+
+// Validate a list of node property providers. The provider names must be unique.
+func ValidateNodePropertyProviders(providers []NodePropertyProvider) error {
+	names := make(map[string]bool)
+	for _, p := range providers {
+		if err := p.Validate(); err != nil {
+			return err
+		} else if names[p.Name] {
+			return fmt.Errorf("provider name %v is used more than once.", p.Name)
+		}
+		names[p.Name] = true
+	}
+	return nil
+}
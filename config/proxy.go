@@ -0,0 +1,203 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+)
+
+// Configuration for an HTTP(S) proxy that the agent uses for all of its outbound connections to the management hub
+// (exchange, CSS). Images are pulled by the docker daemon, which does not use this proxy, so the daemon must be given
+// the same proxy in its own configuration. The agent warns about the registries that the daemon reaches directly.
+type ProxyConfig struct {
+	URL             string   // The URL of the proxy, e.g. http://proxy.example.com:3128. If empty, no proxy is used.
+	CredentialsFile string   // The path to a file containing the proxy credentials as user:password. The file must not be accessible by group or others.
+	NoProxy         []string // Destinations that are reached directly. Each entry is a host name, a domain suffix (.example.com or *.example.com), an IP address or a CIDR, optionally followed by :port. "*" disables the proxy for all destinations.
+	CACertsPath     string   // The path to a file containing PEM-encoded x509 certs of the proxy's CA. These are trusted in addition to the certs configured by CACertsPath.
+}
+
+func (p *ProxyConfig) String() string {
+	return fmt.Sprintf("URL: %v, CredentialsFile: %v, NoProxy: %v, CACertsPath: %v", p.URL, p.CredentialsFile, p.NoProxy, p.CACertsPath)
+}
+
+func (p *ProxyConfig) IsConfigured() bool {
+	return p.URL != ""
+}
+
+// Verify that the proxy config is consistent. This is called when the config file is read, so that an invalid
+// proxy config stops the agent from starting instead of silently bypassing the proxy.
+func (p *ProxyConfig) Validate() error {
+	if !p.IsConfigured() {
+		if p.CredentialsFile != "" || p.CACertsPath != "" || len(p.NoProxy) != 0 {
+			return errors.New("Proxy.URL must be set when other proxy attributes are specified.")
+		}
+		return nil
+	}
+
+	if u, err := p.parseURL(); err != nil {
+		return err
+	} else if u.User != nil {
+		return fmt.Errorf("Proxy.URL for host %v must not contain credentials, use Proxy.CredentialsFile instead.", u.Host)
+	}
+
+	if p.CredentialsFile != "" {
+		if _, _, err := p.readCredentials(); err != nil {
+			return err
+		}
+	}
+
+	for _, np := range p.NoProxy {
+		if strings.TrimSpace(np) == "" {
+			return errors.New("Proxy.NoProxy must not contain empty entries.")
+		}
+	}
+	return nil
+}
+
+func (p *ProxyConfig) parseURL() (*url.URL, error) {
+	u, err := url.Parse(p.URL)
+	if err != nil {
+		return nil, fmt.Errorf("Unable to parse Proxy.URL %v, error: %v", p.URL, err)
+	} else if u.Scheme != "http" && u.Scheme != "https" {
+		return nil, fmt.Errorf("Proxy.URL %v must use the http or https scheme.", p.URL)
+	} else if u.Host == "" {
+		return nil, fmt.Errorf("Proxy.URL %v must contain a host.", p.URL)
+	}
+	return u, nil
+}
+
+// Read the proxy user and password from the credentials file. The file is refused if it can be read by
+// anyone other than its owner.
+func (p *ProxyConfig) readCredentials() (string, string, error) {
+	if fi, err := os.Stat(p.CredentialsFile); err != nil {
+		return "", "", fmt.Errorf("Unable to access Proxy.CredentialsFile %v, error: %v", p.CredentialsFile, err)
+	} else if fi.Mode().Perm()&0077 != 0 {
+		return "", "", fmt.Errorf("Proxy.CredentialsFile %v must not be accessible by group or others, permissions are %v.", p.CredentialsFile, fi.Mode().Perm())
+	}
+
+	content, err := ioutil.ReadFile(p.CredentialsFile)
+	if err != nil {
+		return "", "", fmt.Errorf("Unable to read Proxy.CredentialsFile %v, error: %v", p.CredentialsFile, err)
+	}
+
+	creds := strings.TrimSpace(string(content))
+	parts := strings.SplitN(creds, ":", 2)
+	if len(parts) != 2 || parts[0] == "" {
+		return "", "", fmt.Errorf("Proxy.CredentialsFile %v must contain the credentials in the form user:password.", p.CredentialsFile)
+	}
+	return parts[0], parts[1], nil
+}
+
+// Returns the proxy URL including the credentials from the credentials file, if there is one.
+func (p *ProxyConfig) GetURLWithCredentials() (*url.URL, error) {
+	u, err := p.parseURL()
+	if err != nil {
+		return nil, err
+	}
+	if p.CredentialsFile != "" {
+		if user, pw, err := p.readCredentials(); err != nil {
+			return nil, err
+		} else {
+			u.User = url.UserPassword(user, pw)
+		}
+	}
+	return u, nil
+}
+
+// Returns true if a connection to the given destination should go through the proxy. The destination
+// is a host name or IP address, optionally followed by :port.
+func (p *ProxyConfig) UseProxy(destination string) bool {
+	if !p.IsConfigured() {
+		return false
+	}
+
+	host, port, err := net.SplitHostPort(destination)
+	if err != nil {
+		host = destination
+		port = ""
+	}
+	host = strings.ToLower(strings.TrimSuffix(host, "."))
+
+	// Loopback destinations are never proxied.
+	if host == "localhost" {
+		return false
+	}
+	ip := net.ParseIP(host)
+	if ip != nil && ip.IsLoopback() {
+		return false
+	}
+
+	for _, rule := range p.NoProxy {
+		if noProxyMatch(strings.ToLower(strings.TrimSpace(rule)), host, port, ip) {
+			return false
+		}
+	}
+	return true
+}
+
+// Returns true if the no proxy rule matches the destination host and port.
+func noProxyMatch(rule string, host string, port string, ip net.IP) bool {
+	if rule == "*" {
+		return true
+	}
+
+	// A CIDR never has a port.
+	if _, cidr, err := net.ParseCIDR(rule); err == nil {
+		return ip != nil && cidr.Contains(ip)
+	}
+
+	ruleHost, rulePort, err := net.SplitHostPort(rule)
+	if err != nil {
+		ruleHost = rule
+		rulePort = ""
+	}
+	if rulePort != "" && rulePort != port {
+		return false
+	}
+
+	if ruleIP := net.ParseIP(ruleHost); ruleIP != nil {
+		return ip != nil && ruleIP.Equal(ip)
+	}
+
+	// .example.com and *.example.com match the domain and all of its sub-domains, example.com
+	// matches the same way because that is how the NO_PROXY envvar is commonly interpreted.
+	ruleHost = strings.TrimPrefix(strings.TrimPrefix(ruleHost, "*"), ".")
+	return host == ruleHost || strings.HasSuffix(host, "."+ruleHost)
+}
+
+// Returns a function suitable for http.Transport.Proxy, or nil if there is no proxy configured. A nil
+// proxy function means that connections are made directly.
+func (p *ProxyConfig) NewProxyFunc() (func(*http.Request) (*url.URL, error), error) {
+	if !p.IsConfigured() {
+		return nil, nil
+	}
+
+	proxyURL, err := p.GetURLWithCredentials()
+	if err != nil {
+		return nil, err
+	}
+
+	return func(req *http.Request) (*url.URL, error) {
+		if p.UseProxy(req.URL.Host) {
+			return proxyURL, nil
+		}
+		return nil, nil
+	}, nil
+}
+
+// Returns the content of the proxy CA cert file, or nil if there isnt one.
+func (p *ProxyConfig) ReadCACerts() ([]byte, error) {
+	if p.CACertsPath == "" {
+		return nil, nil
+	}
+	caBytes, err := ioutil.ReadFile(p.CACertsPath)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to read Proxy.CACertsPath: %v", p.CACertsPath)
+	}
+	return caBytes, nil
+}
@@ -0,0 +1,118 @@
+// +build unit
+
+package config
+
+import (
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path"
+	"testing"
+)
+
+func Test_ProxyConfig_UseProxy(t *testing.T) {
+
+	proxy := ProxyConfig{
+		URL:     "http://proxy.example.com:3128",
+		NoProxy: []string{".internal.example.com", "*.corp.com", "mgmt-hub", "10.0.0.0/8", "192.168.1.5", "css.example.com:9443"},
+	}
+
+	direct := []string{"localhost", "127.0.0.1:8080", "a.internal.example.com", "internal.example.com", "x.y.corp.com",
+		"mgmt-hub:8080", "10.1.2.3", "192.168.1.5:443", "css.example.com:9443", "MGMT-HUB"}
+	for _, d := range direct {
+		if proxy.UseProxy(d) {
+			t.Errorf("destination %v should be reached directly", d)
+		}
+	}
+
+	proxied := []string{"exchange.example.com", "notinternal.example.com", "corp.com.evil.org", "192.168.1.6",
+		"css.example.com:443", "11.0.0.1"}
+	for _, d := range proxied {
+		if !proxy.UseProxy(d) {
+			t.Errorf("destination %v should be reached through the proxy", d)
+		}
+	}
+
+	all := ProxyConfig{URL: "http://proxy.example.com:3128", NoProxy: []string{"*"}}
+	if all.UseProxy("exchange.example.com") {
+		t.Errorf("no destination should be proxied with a * no proxy rule")
+	}
+
+	none := ProxyConfig{}
+	if none.UseProxy("exchange.example.com") {
+		t.Errorf("no destination should be proxied without a proxy URL")
+	}
+}
+
+func Test_ProxyConfig_Validate(t *testing.T) {
+
+	dir, err := ioutil.TempDir("", "proxytest")
+	if err != nil {
+		t.Fatalf("unable to create temp dir, error %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	goodCreds := path.Join(dir, "good")
+	if err := ioutil.WriteFile(goodCreds, []byte("user:pass:word\n"), 0600); err != nil {
+		t.Fatalf("unable to write file, error %v", err)
+	}
+	openCreds := path.Join(dir, "open")
+	if err := ioutil.WriteFile(openCreds, []byte("user:password"), 0644); err != nil {
+		t.Fatalf("unable to write file, error %v", err)
+	} else if err := os.Chmod(openCreds, 0644); err != nil {
+		t.Fatalf("unable to chmod file, error %v", err)
+	}
+	badCreds := path.Join(dir, "bad")
+	if err := ioutil.WriteFile(badCreds, []byte("nocolon"), 0600); err != nil {
+		t.Fatalf("unable to write file, error %v", err)
+	}
+
+	invalid := []ProxyConfig{
+		{NoProxy: []string{"a.com"}},
+		{URL: "socks5://proxy:1080"},
+		{URL: "http://user:pw@proxy:3128"},
+		{URL: "http://proxy:3128", CredentialsFile: openCreds},
+		{URL: "http://proxy:3128", CredentialsFile: badCreds},
+		{URL: "http://proxy:3128", CredentialsFile: path.Join(dir, "missing")},
+		{URL: "http://proxy:3128", NoProxy: []string{" "}},
+	}
+	for _, p := range invalid {
+		if err := p.Validate(); err == nil {
+			t.Errorf("proxy config %v should be invalid", p.String())
+		}
+	}
+
+	valid := ProxyConfig{URL: "http://proxy:3128", CredentialsFile: goodCreds}
+	if err := valid.Validate(); err != nil {
+		t.Errorf("proxy config %v should be valid, error %v", valid.String(), err)
+	}
+
+	if u, err := valid.GetURLWithCredentials(); err != nil {
+		t.Errorf("unexpected error %v", err)
+	} else if pw, _ := u.User.Password(); u.User.Username() != "user" || pw != "pass:word" {
+		t.Errorf("wrong proxy credentials %v", u.User)
+	}
+}
+
+func Test_ProxyConfig_NewProxyFunc(t *testing.T) {
+
+	if f, err := (&ProxyConfig{}).NewProxyFunc(); err != nil || f != nil {
+		t.Errorf("there should be no proxy func, error %v", err)
+	}
+
+	proxy := ProxyConfig{URL: "http://proxy:3128", NoProxy: []string{"css.example.com"}}
+	f, err := proxy.NewProxyFunc()
+	if err != nil || f == nil {
+		t.Fatalf("there should be a proxy func, error %v", err)
+	}
+
+	req, _ := http.NewRequest("GET", "https://exchange.example.com/v1/orgs", nil)
+	if u, err := f(req); err != nil || u == nil || u.Host != "proxy:3128" {
+		t.Errorf("exchange request should use the proxy, is %v, error %v", u, err)
+	}
+
+	req, _ = http.NewRequest("GET", "https://css.example.com:9443/api/v1", nil)
+	if u, err := f(req); err != nil || u != nil {
+		t.Errorf("css request should not use the proxy, is %v, error %v", u, err)
+	}
+}
@@ -0,0 +1,60 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"path/filepath"
+)
+
+// The built-in secret provider types. Other types can be registered by the secretprovider package.
+const (
+	SECRET_PROVIDER_FILE = "file" // Reads the secrets from a local directory or file.
+)
+
+// The default for how often the secrets that are referenced by running services are checked for rotation.
+const DEFAULT_SECRET_REFRESH_INTERVALS = 60
+
+// A secret provider resolves the secret references, secret://<provider name>/<path>, in the user input of a service
+// when the service containers are started. The file provider reads the secret from a file below Path when Path is a
+// directory, or from a JSON object of path and secret pairs when Path is a file.
+type SecretProvider struct {
+	Name string // A unique name for the provider, used in the secret references and in event log messages.
+	Type string // file, or the type of a provider that has been registered with the agent.
+	Path string // The absolute path of the directory or file that the file provider reads.
+}
+
+func (p *SecretProvider) String() string {
+	return fmt.Sprintf("Name: %v, Type: %v, Path: %v", p.Name, p.Type, p.Path)
+}
+
+// Validate the fields that every provider uses, and the fields of the built-in provider types. The type of a
+// registered provider is checked when the provider is created.
+func (p *SecretProvider) Validate() error {
+	if p.Name == "" {
+		return errors.New("Name must be set.")
+	} else if p.Type == "" {
+		return fmt.Errorf("Type of provider %v must be set.", p.Name)
+	}
+
+	switch p.Type {
+	case SECRET_PROVIDER_FILE:
+		if !filepath.IsAbs(p.Path) {
+			return fmt.Errorf("Path of provider %v must be an absolute path, %v is not.", p.Name, p.Path)
+		}
+	}
+	return nil
+}
+
+// Validate a list of secret providers. The provider names must be unique.
+func ValidateSecretProviders(providers []SecretProvider) error {
+	names := make(map[string]bool)
+	for _, p := range providers {
+		if err := p.Validate(); err != nil {
+			return err
+		} else if names[p.Name] {
+			return fmt.Errorf("provider name %v is used more than once.", p.Name)
+		}
+		names[p.Name] = true
+	}
+	return nil
+}
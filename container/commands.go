@@ -2,6 +2,7 @@ package container
 
 import (
 	"fmt"
+	docker "github.com/fsouza/go-dockerclient"
 	"github.com/open-horizon/anax/containermessage"
 	"github.com/open-horizon/anax/cutil"
 	"github.com/open-horizon/anax/events"
@@ -174,3 +175,90 @@ func NewNodeUnconfigCommand(msg *events.NodeShutdownCompleteMessage) *NodeUnconf
 		msg: msg,
 	}
 }
+
+// ==============================================================================================================
+// A docker event about the failure of a container.
+type ContainerEventCommand struct {
+	Event *docker.APIEvents
+}
+
+func (c ContainerEventCommand) String() string {
+	return c.ShortString()
+}
+
+func (c ContainerEventCommand) ShortString() string {
+	return fmt.Sprintf("ContainerEventCommand: Action %v, Container %v, Attributes %v", c.Event.Action, c.Event.Actor.ID, c.Event.Actor.Attributes)
+}
+
+func NewContainerEventCommand(event *docker.APIEvents) *ContainerEventCommand {
+	return &ContainerEventCommand{
+		Event: event,
+	}
+}
+
+// ==============================================================================================================
+// A container passed its readiness probe, so the services waiting for it can be started.
+type ContainerReadyCommand struct {
+	ContainerId string
+}
+
+func (c ContainerReadyCommand) String() string {
+	return c.ShortString()
+}
+
+func (c ContainerReadyCommand) ShortString() string {
+	return fmt.Sprintf("ContainerReadyCommand: ContainerId %v", c.ContainerId)
+}
+
+func NewContainerReadyCommand(id string) *ContainerReadyCommand {
+	return &ContainerReadyCommand{
+		ContainerId: id,
+	}
+}
+
+// ==============================================================================================================
+// The worker has subscribed to the docker event stream, or the stream has ended.
+type EventStreamCommand struct {
+	Connected bool
+}
+
+func (c EventStreamCommand) String() string {
+	return c.ShortString()
+}
+
+func (c EventStreamCommand) ShortString() string {
+	return fmt.Sprintf("EventStreamCommand: Connected %v", c.Connected)
+}
+
+func NewEventStreamCommand(connected bool) *EventStreamCommand {
+	return &EventStreamCommand{
+		Connected: connected,
+	}
+}
+
+// ==============================================================================================================
+// A new version of a model management object has been delivered into the containers of a service, which asked to be
+// restarted or signalled when that happens.
+type MMSObjectUpdatedCommand struct {
+	Key         string // The agreement or service instance that the containers belong to.
+	ServiceName string
+	OnUpdate    string
+	Signal      string
+}
+
+func (c MMSObjectUpdatedCommand) String() string {
+	return c.ShortString()
+}
+
+func (c MMSObjectUpdatedCommand) ShortString() string {
+	return fmt.Sprintf("MMSObjectUpdatedCommand: Key %v, ServiceName %v, OnUpdate %v, Signal %v", c.Key, c.ServiceName, c.OnUpdate, c.Signal)
+}
+
+func NewMMSObjectUpdatedCommand(key string, serviceName string, onUpdate string, signal string) *MMSObjectUpdatedCommand {
+	return &MMSObjectUpdatedCommand{
+		Key:         key,
+		ServiceName: serviceName,
+		OnUpdate:    onUpdate,
+		Signal:      signal,
+	}
+}
@@ -13,6 +13,7 @@ import (
 	"github.com/golang/glog"
 	"github.com/open-horizon/anax/config"
 	"github.com/open-horizon/anax/containermessage"
+	"github.com/open-horizon/anax/containerruntime"
 	"github.com/open-horizon/anax/cutil"
 	"github.com/open-horizon/anax/eventlog"
 	"github.com/open-horizon/anax/events"
@@ -20,6 +21,7 @@ import (
 	"github.com/open-horizon/anax/persistence"
 	"github.com/open-horizon/anax/policy"
 	"github.com/open-horizon/anax/resource"
+	"github.com/open-horizon/anax/secretprovider"
 	"github.com/open-horizon/anax/worker"
 	"golang.org/x/sys/unix"
 	"io"
@@ -29,6 +31,7 @@ import (
 	"path"
 	"strconv"
 	"strings"
+	"sync"
 )
 
 const LABEL_PREFIX = "openhorizon.anax"
@@ -42,15 +45,28 @@ const (
 	EL_CONT_DEPLOYCONF_UNSUPPORT_BIND_FOR     = "Deployment config %v contains unsupported bind for %v, %v"
 	EL_CONT_ERROR_UNMARSHAL_DEPLOY            = "Error Unmarshalling deployment string %v, error: %v"
 	EL_CONT_ERROR_UNMARSHAL_DEPLOY_OVERRIDE   = "Error Unmarshalling deployment override string %v for agreement %v, error: %v"
+	EL_CONT_DEPLOYCONF_TEMPLATE_ERROR         = "Error expanding the templates in deployment config %v, error: %v"
 	EL_CONT_START_CONTAINER_ERROR             = "Error starting containers: %v"
 	EL_CONT_START_CONTAINER_ERROR_FOR_AG      = "Error starting containers for agreement %v: %v"
 	EL_CONT_RESTART_CONTAINER_ERROR_FOR_AG    = "Error restarting containers for agreements %v: %v"
 	EL_CONT_CLEAN_OLD_CONTAINER_ERROR         = "Error cleaning up old containers before starting up new containers for %v. Error: %v"
 	EL_CONT_FAIL_GET_PAENT_CONT_FOR_SVC       = "Failed to get a list of parent containers for service retry for %v. %v"
 	EL_CONT_FAIL_RESTORE_NW_WITH_PARENT       = "Failed to restoring the network connection with the parents for service %v. %v"
+	EL_CONT_DEPENDENT_SERVICE_NOT_READY       = "Dependent service %v did not become ready within %v seconds. Output of the readiness probe: %v"
 	EL_CONT_TERM_UNABLE_ACCESS_STORAGE_DIR    = "anax terminating. Unable to access service storage direcotry specified in config: %v. %v"
 	EL_CONT_TERM_UNABLE_INIT_IPTABLE_CLIENT   = "anax terminating. Failed to instantiate iptables client. %v"
 	EL_CONT_TERM_UNABLE_INIT_DOCKER_CLIENT    = "anax terminating. Failed to instantiate docker client. %v"
+	EL_CONT_SECRET_READ                       = "Secret %v for %v was read from %v."
+	EL_CONT_SECRET_ROTATED                    = "Secret %v for %v was rotated and read again from %v."
+	EL_CONT_SECRET_READ_ERROR                 = "Error reading secret %v for %v from %v, error: %v"
+	EL_CONT_MMS_OBJECT_DELIVERED              = "Object %v/%v instance %v was delivered into %v of service %v for %v."
+	EL_CONT_MMS_OBJECT_REMOVED                = "Deleted object %v/%v was removed from %v of service %v for %v."
+	EL_CONT_MMS_OBJECT_ERROR                  = "Error delivering object %v/%v into %v of service %v for %v, error: %v"
+	EL_CONT_VOLUME_BACKED_UP                  = "Volume %v of service %v version %v was backed up to %v."
+	EL_CONT_VOLUME_BACKUP_ERROR               = "Error backing up volume %v of service %v version %v, error: %v"
+	EL_CONT_VOLUME_RESTORED                   = "Volume %v of service %v was restored from %v."
+	EL_CONT_VOLUME_RESTORE_ERROR              = "Error restoring volume %v of service %v from %v, error: %v"
+	EL_CONT_VOLUME_REMOVED                    = "Volume %v of service %v was removed, its retention is %v."
 )
 
 // This is does nothing useful at run time.
@@ -67,15 +83,28 @@ func MarkI18nMessages() {
 	msgPrinter.Sprintf(EL_CONT_DEPLOYCONF_UNSUPPORT_BIND_FOR)
 	msgPrinter.Sprintf(EL_CONT_ERROR_UNMARSHAL_DEPLOY)
 	msgPrinter.Sprintf(EL_CONT_ERROR_UNMARSHAL_DEPLOY_OVERRIDE)
+	msgPrinter.Sprintf(EL_CONT_DEPLOYCONF_TEMPLATE_ERROR)
 	msgPrinter.Sprintf(EL_CONT_START_CONTAINER_ERROR)
 	msgPrinter.Sprintf(EL_CONT_START_CONTAINER_ERROR_FOR_AG)
 	msgPrinter.Sprintf(EL_CONT_RESTART_CONTAINER_ERROR_FOR_AG)
 	msgPrinter.Sprintf(EL_CONT_CLEAN_OLD_CONTAINER_ERROR)
 	msgPrinter.Sprintf(EL_CONT_FAIL_GET_PAENT_CONT_FOR_SVC)
 	msgPrinter.Sprintf(EL_CONT_FAIL_RESTORE_NW_WITH_PARENT)
+	msgPrinter.Sprintf(EL_CONT_DEPENDENT_SERVICE_NOT_READY)
 	msgPrinter.Sprintf(EL_CONT_TERM_UNABLE_ACCESS_STORAGE_DIR)
 	msgPrinter.Sprintf(EL_CONT_TERM_UNABLE_INIT_IPTABLE_CLIENT)
 	msgPrinter.Sprintf(EL_CONT_TERM_UNABLE_INIT_DOCKER_CLIENT)
+	msgPrinter.Sprintf(EL_CONT_SECRET_READ)
+	msgPrinter.Sprintf(EL_CONT_SECRET_ROTATED)
+	msgPrinter.Sprintf(EL_CONT_SECRET_READ_ERROR)
+	msgPrinter.Sprintf(EL_CONT_MMS_OBJECT_DELIVERED)
+	msgPrinter.Sprintf(EL_CONT_MMS_OBJECT_REMOVED)
+	msgPrinter.Sprintf(EL_CONT_MMS_OBJECT_ERROR)
+	msgPrinter.Sprintf(EL_CONT_VOLUME_BACKED_UP)
+	msgPrinter.Sprintf(EL_CONT_VOLUME_BACKUP_ERROR)
+	msgPrinter.Sprintf(EL_CONT_VOLUME_RESTORED)
+	msgPrinter.Sprintf(EL_CONT_VOLUME_RESTORE_ERROR)
+	msgPrinter.Sprintf(EL_CONT_VOLUME_REMOVED)
 }
 
 /*
@@ -204,7 +233,7 @@ func removeDuplicateVariable(existingArray *[]string, newVar string) {
 
 }
 
-func (w *ContainerWorker) finalizeDeployment(agreementId string, deployment *containermessage.DeploymentDescription, environmentAdditions map[string]string, workloadRWStorageDir string, cpuSet string, uds string) (map[string]servicePair, error) {
+func (w *ContainerWorker) finalizeDeployment(agreementId string, deployment *containermessage.DeploymentDescription, environmentAdditions map[string]string, secretsDir string, workloadRWStorageDir string, cpuSet string, uds string) (map[string]servicePair, error) {
 
 	// final structure
 	services := make(map[string]servicePair, 0)
@@ -232,6 +261,38 @@ func (w *ContainerWorker) finalizeDeployment(agreementId string, deployment *con
 			return nil, err
 		}
 
+		if err := service.ValidateSecurityOptions(); err != nil {
+			return nil, fmt.Errorf("service %v has invalid security options, %v", serviceName, err)
+		}
+		securityOpt, err := service.SecurityOpt()
+		if err != nil {
+			return nil, fmt.Errorf("service %v: %v", serviceName, err)
+		}
+		if service.NetworkIsolation != nil {
+			if err := service.NetworkIsolation.Validate(); err != nil {
+				return nil, fmt.Errorf("service %v has invalid network isolation, %v", serviceName, err)
+			}
+		}
+		if service.Readiness != nil {
+			if err := service.Readiness.Validate(); err != nil {
+				return nil, fmt.Errorf("service %v has an invalid readiness probe, %v", serviceName, err)
+			}
+		}
+		if len(service.MMSObjects) != 0 {
+			if err := service.ValidateMMSObjects(); err != nil {
+				return nil, fmt.Errorf("service %v has invalid mms objects, %v", serviceName, err)
+			} else if deployment.ServicePattern.IsShared("singleton", serviceName) {
+				return nil, fmt.Errorf("service %v is shared, mms objects can only be delivered into the containers of one agreement", serviceName)
+			}
+		}
+		if len(service.Volumes) != 0 {
+			if err := service.ValidateVolumes(); err != nil {
+				return nil, fmt.Errorf("service %v has invalid volumes, %v", serviceName, err)
+			} else if deployment.ServicePattern.IsShared("singleton", serviceName) {
+				return nil, fmt.Errorf("service %v is shared, the retention of its volumes can only follow the containers of one agreement", serviceName)
+			}
+		}
+
 		// If the FSS is using a unix domain socket listener, add a filesystem binding for it.
 		if uds != "" {
 			service.Binds = append(service.Binds, fmt.Sprintf("%v:%v", uds, uds))
@@ -243,6 +304,17 @@ func (w *ContainerWorker) finalizeDeployment(agreementId string, deployment *con
 		// Add a filesystem binding for the FSS (ESS) API SSL client certificate.
 		service.Binds = append(service.Binds, fmt.Sprintf("%v:%v:ro", w.Config.GetESSSSLClientCertPath(), config.HZN_FSS_CERT_MOUNT))
 
+		// Add a filesystem binding for the secret user input files.
+		if secretsDir != "" {
+			service.Binds = append(service.Binds, fmt.Sprintf("%v:%v:ro", secretsDir, config.HZN_SECRETS_MOUNT))
+		}
+
+		// Add a filesystem binding for each directory of model management objects.
+		service.Binds = append(service.Binds, w.mmsObjectBinds(agreementId, serviceName, service)...)
+
+		// Add a filesystem binding for each named volume.
+		service.Binds = append(service.Binds, volumeBinds(service)...)
+
 		// Create the volume map based on the container paths being bound to the host.
 		// The bind string looks like this: <host-path>:<container-path>:<ro> where ro means readonly and is optional.
 		vols := make(map[string]struct{})
@@ -258,6 +330,11 @@ func (w *ContainerWorker) finalizeDeployment(agreementId string, deployment *con
 		labels[LABEL_PREFIX+".service_name"] = serviceName
 		labels[LABEL_PREFIX+".variation"] = service.VariationLabel
 		labels[LABEL_PREFIX+".deployment_description_hash"] = deploymentHash
+		if permits, err := inboundPermitLabel(service); err != nil {
+			return nil, err
+		} else if permits != "" {
+			labels[LABEL_INBOUND_PERMIT_ONLY] = permits
+		}
 
 		var logConfig docker.LogConfig
 
@@ -282,11 +359,18 @@ func (w *ContainerWorker) finalizeDeployment(agreementId string, deployment *con
 				},
 			}
 		}
+		// A memory limit declared by the service replaces the default, which is the memory of the node.
+		memoryBytes := ramBytes
+		if service.MaxMemoryMB != 0 {
+			memoryBytes = service.MaxMemoryMB * 1024 * 1024
+		}
+
 		serviceConfig := &persistence.ServiceConfig{
 			Config: docker.Config{
 				Image:        service.Image,
 				Env:          []string{},
 				Cmd:          service.Command,
+				User:         service.User,
 				CPUSet:       cpuSet,
 				Labels:       labels,
 				Volumes:      vols,
@@ -296,11 +380,14 @@ func (w *ContainerWorker) finalizeDeployment(agreementId string, deployment *con
 				Privileged:      service.Privileged,
 				NetworkMode:     service.Network,
 				CapAdd:          service.CapAdd,
+				CapDrop:         service.CapDrop,
+				ReadonlyRootfs:  service.ReadOnlyRootfs,
+				SecurityOpt:     securityOpt,
 				PublishAllPorts: false,
 				PortBindings:    map[docker.Port][]docker.PortBinding{},
 				Links:           nil, // do not allow any
 				RestartPolicy:   docker.AlwaysRestart(),
-				Memory:          ramBytes,
+				Memory:          memoryBytes,
 				MemorySwap:      0,
 				Devices:         []docker.Device{},
 				LogConfig:       logConfig,
@@ -314,6 +401,13 @@ func (w *ContainerWorker) finalizeDeployment(agreementId string, deployment *con
 			serviceConfig.Config.Labels[LABEL_PREFIX+".infrastructure"] = ""
 		}
 
+		// The readiness probe is run by docker as a health check. The label tells the services that depend on this
+		// one to wait for it.
+		if service.Readiness != nil {
+			serviceConfig.Config.Healthcheck = service.Readiness.HealthConfig()
+			serviceConfig.Config.Labels[LABEL_READINESS_TIMEOUT] = strconv.Itoa(service.Readiness.GetTimeoutS())
+		}
+
 		// add environment additions to each service
 		for k, v := range environmentAdditions {
 			serviceConfig.Config.Env = append(serviceConfig.Config.Env, fmt.Sprintf("%s=%v", k, v))
@@ -434,14 +528,23 @@ func (w *ContainerWorker) finalizeDeployment(agreementId string, deployment *con
 type ContainerWorker struct {
 	worker.BaseWorker // embedded field
 	db                *bolt.DB
-	client            *docker.Client
+	client            containerruntime.ContainerRuntime
 	iptables          *iptables.IPTables
 	authMgr           *resource.AuthenticationManager
 	pattern           string
+	watch             *eventWatch
+	secretResolver    *secretprovider.Resolver
+	secretLock        sync.Mutex // Serializes the changes to the secret files.
+	mmsStore          mmsObjectStore
+	mmsLock           sync.Mutex // Serializes the changes to the model management object files.
 }
 
+// Returns the docker client of the worker, or nil if the worker does not run containers with docker.
 func (cw *ContainerWorker) GetClient() *docker.Client {
-	return cw.client
+	if d, ok := cw.client.(*containerruntime.DockerRuntime); ok {
+		return d.Client
+	}
+	return nil
 }
 
 func (cw *ContainerWorker) GetAuthenticationManager() *resource.AuthenticationManager {
@@ -450,18 +553,21 @@ func (cw *ContainerWorker) GetAuthenticationManager() *resource.AuthenticationMa
 
 func CreateCLIContainerWorker(config *config.HorizonConfig) (*ContainerWorker, error) {
 	dockerEP := "unix:///var/run/docker.sock"
-	client, derr := docker.NewClient(dockerEP)
+	client, derr := containerruntime.NewDockerRuntime(dockerEP)
 	if derr != nil {
 		return nil, derr
 	}
 
 	return &ContainerWorker{
-		BaseWorker: worker.NewBaseWorker("mock", config, nil),
-		db:         nil,
-		client:     client,
-		iptables:   nil,
-		authMgr:    resource.NewAuthenticationManager(config.GetFileSyncServiceAuthPath()),
-		pattern:    "",
+		BaseWorker:     worker.NewBaseWorker("mock", config, nil),
+		db:             nil,
+		client:         client,
+		iptables:       nil,
+		authMgr:        resource.NewAuthenticationManager(config.GetFileSyncServiceAuthPath()),
+		pattern:        "",
+		watch:          newEventWatch(),
+		secretResolver: secretprovider.NewResolver(config.Edge.SecretProviders),
+		mmsStore:       essObjectStore{},
 	}, nil
 }
 
@@ -489,7 +595,7 @@ func NewContainerWorker(name string, config *config.HorizonConfig, db *bolt.DB,
 
 	var err error
 	var ipt *iptables.IPTables
-	var client *docker.Client
+	var client containerruntime.ContainerRuntime
 
 	ipt, err = iptables.New()
 	if err != nil {
@@ -502,7 +608,7 @@ func NewContainerWorker(name string, config *config.HorizonConfig, db *bolt.DB,
 	}
 
 	if config.Edge.DockerEndpoint != "" {
-		client, err = docker.NewClient(config.Edge.DockerEndpoint)
+		client, err = containerruntime.NewDockerRuntime(config.Edge.DockerEndpoint)
 		if err != nil {
 			glog.Errorf("Failed to instantiate docker Client: %v", err)
 			eventlog.LogNodeEvent(db, persistence.SEVERITY_FATAL,
@@ -519,12 +625,15 @@ func NewContainerWorker(name string, config *config.HorizonConfig, db *bolt.DB,
 	}
 
 	worker := &ContainerWorker{
-		BaseWorker: worker.NewBaseWorker(name, config, nil),
-		db:         db,
-		client:     client,
-		iptables:   ipt,
-		authMgr:    am,
-		pattern:    pattern,
+		BaseWorker:     worker.NewBaseWorker(name, config, nil),
+		db:             db,
+		client:         client,
+		iptables:       ipt,
+		authMgr:        am,
+		pattern:        pattern,
+		watch:          newEventWatch(),
+		secretResolver: secretprovider.NewResolver(config.Edge.SecretProviders),
+		mmsStore:       essObjectStore{},
 	}
 	worker.SetDeferredDelay(15)
 
@@ -545,6 +654,7 @@ func (w *ContainerWorker) NewEvent(incoming events.Message) {
 
 		// stop the container worker for the cluster device type
 		if msg.DeviceType() == persistence.DEVICE_TYPE_CLUSTER {
+			w.watch.Stop()
 			w.Commands <- worker.NewTerminateCommand("cluster node")
 		}
 
@@ -625,7 +735,7 @@ func (w *ContainerWorker) NewEvent(incoming events.Message) {
 	return
 }
 
-func mkBridge(client *docker.Client, name string, infrastructure bool, sharedPattern bool) (*docker.Network, error) {
+func mkBridge(client containerruntime.ContainerRuntime, name string, infrastructure bool, sharedPattern bool) (*docker.Network, error) {
 
 	// Labels on the docker network indicate attributes about the network.
 	labels := make(map[string]string)
@@ -662,7 +772,7 @@ func mkBridge(client *docker.Client, name string, infrastructure bool, sharedPat
 	return bridge, nil
 }
 
-func serviceStart(client *docker.Client,
+func serviceStart(client containerruntime.ContainerRuntime,
 	agreementId string,
 	serviceName string,
 	shareLabel string,
@@ -747,7 +857,7 @@ func serviceStart(client *docker.Client,
 	return nil
 }
 
-func serviceDestroy(client *docker.Client, agreementId string, containerId string) (bool, error) {
+func serviceDestroy(client containerruntime.ContainerRuntime, agreementId string, containerId string) (bool, error) {
 	glog.V(3).Infof("Attempting to stop container %v from agreement: %v.", containerId, agreementId)
 	err := client.KillContainer(docker.KillContainerOptions{ID: containerId})
 
@@ -763,7 +873,7 @@ func serviceDestroy(client *docker.Client, agreementId string, containerId strin
 	return true, client.RemoveContainer(docker.RemoveContainerOptions{ID: containerId, RemoveVolumes: true, Force: true})
 }
 
-func existingShared(client *docker.Client, serviceName string, servicePair *servicePair, bridgeName string, shareLabel string) (*docker.Network, *docker.APIContainers, error) {
+func existingShared(client containerruntime.ContainerRuntime, serviceName string, servicePair *servicePair, bridgeName string, shareLabel string) (*docker.Network, *docker.APIContainers, error) {
 
 	var sBridge docker.Network
 	networks, err := client.ListNetworks()
@@ -892,7 +1002,7 @@ func generatePermittedString(isolation *containermessage.NetworkIsolation, netwo
 	return fmt.Sprintf("%v%v/%v", permittedString, network.IPAddress, network.IPPrefixLen), nil
 }
 
-func processPostCreate(ipt *iptables.IPTables, client *docker.Client, agreementId string, deployment containermessage.DeploymentDescription, configureRaw []byte, hasSpecifiedEthAccount bool, containers []interface{}, fail func(container *docker.Container, name string, err error) error) error {
+func processPostCreate(ipt *iptables.IPTables, client containerruntime.ContainerRuntime, agreementId string, deployment containermessage.DeploymentDescription, configureRaw []byte, hasSpecifiedEthAccount bool, containers []interface{}, fail func(container *docker.Container, name string, err error) error) error {
 
 	if ipt != nil {
 		rules, err := ipt.List("filter", IPT_COLONUS_ISOLATED_CHAIN)
@@ -999,9 +1109,15 @@ func processPostCreate(ipt *iptables.IPTables, client *docker.Client, agreementI
 						}
 					}
 				}
+
+				if ipt != nil {
+					if err := applyInboundPolicies(ipt, client, agreementId, conDetail.ID, conDetail.Config.Labels, conDetail.NetworkSettings.Networks); err != nil {
+						return fail(nil, serviceName, fmt.Errorf("Unable to create inbound network rules for service. Error: %v", err))
+					}
+				}
 			}
 
-			// no need to handle case of new shared container needing rules to permit traffic from existing agreements, that case is unsupported
+			// other than the inbound policies, there is no need to handle case of new shared container needing rules to permit traffic from existing agreements, that case is unsupported
 
 		case *docker.APIContainers:
 			glog.Infof("Doing post-create on existing (shared) container: %v", con)
@@ -1025,6 +1141,13 @@ func processPostCreate(ipt *iptables.IPTables, client *docker.Client, agreementI
 						}
 					}
 				}
+
+				// the inbound restrictions of the shared container are also tied to this agreement so that they remain while any agreement uses the container
+				if ipt != nil {
+					if err := applyInboundPolicies(ipt, client, agreementId, container.ID, container.Labels, container.Networks.Networks); err != nil {
+						return fail(nil, serviceName, fmt.Errorf("Unable to create inbound network rules for service. Error: %v", err))
+					}
+				}
 			}
 
 		default:
@@ -1045,8 +1168,9 @@ func (b *ContainerWorker) workloadStorageDir(agreementId string) (string, bool)
 	}
 }
 
-// This function creates the containers, volumes, networks for the given agreement or service.
-func (b *ContainerWorker) ResourcesCreate(agreementId string, agreementProtocol string, configure *events.ContainerConfig, deployment *containermessage.DeploymentDescription, configureRaw []byte, environmentAdditions map[string]string, ms_networks map[string]docker.ContainerNetwork, serviceURL string, sVer string) (persistence.DeploymentConfig, error) {
+// This function creates the containers, volumes, networks for the given agreement or service. The secrets are given
+// to the containers as files.
+func (b *ContainerWorker) ResourcesCreate(agreementId string, agreementProtocol string, configure *events.ContainerConfig, deployment *containermessage.DeploymentDescription, configureRaw []byte, environmentAdditions map[string]string, secrets map[string]string, ms_networks map[string]docker.ContainerNetwork, serviceURL string, sVer string) (persistence.DeploymentConfig, error) {
 
 	// local helpers
 	fail := func(container *docker.Container, name string, err error) error {
@@ -1095,6 +1219,13 @@ func (b *ContainerWorker) ResourcesCreate(agreementId string, agreementProtocol
 		return endpoints
 	}
 
+	// Refuse a deployment that breaks the node admission policy before creating anything for it. The service storage
+	// is bound into every container by the agent itself.
+	if err := deployment.CheckAdmission(&b.Config.Edge.AdmissionPolicy, b.Config.Edge.ServiceStorage); err != nil {
+		glog.Errorf("Deployment for %v is not admitted on this node: %v", agreementId, err)
+		return nil, err
+	}
+
 	workloadRWStorageDir, useVolume := b.workloadStorageDir(agreementId)
 
 	if !useVolume {
@@ -1130,11 +1261,27 @@ func (b *ContainerWorker) ResourcesCreate(agreementId string, agreementProtocol
 		glog.Errorf("Failed to create MMS Authentication credential file for %v, error %v", agreementId, err)
 	}
 
-	servicePairs, err := b.finalizeDeployment(agreementId, deployment, environmentAdditions, workloadRWStorageDir, b.Config.Edge.DefaultCPUSet, b.Config.GetFileSyncServiceAPIUnixDomainSocketPath())
+	// secret references are resolved into secret files, they are never given to the containers as environment variables
+	environmentAdditions, secrets, secretRefs, err := b.resolveSecretReferences(agreementId, serviceURL, sVer, environmentAdditions, secrets)
 	if err != nil {
 		return nil, err
 	}
 
+	secretsDir, err := b.writeSecrets(agreementId, serviceURL, sVer, secrets, secretRefs)
+	if err != nil {
+		return nil, err
+	}
+
+	servicePairs, err := b.finalizeDeployment(agreementId, deployment, environmentAdditions, secretsDir, workloadRWStorageDir, b.Config.Edge.DefaultCPUSet, b.Config.GetFileSyncServiceAPIUnixDomainSocketPath())
+	if err == nil {
+		err = b.createMMSObjectFiles(agreementId, environmentAdditions[config.ENVVAR_PREFIX+"ORGANIZATION"], serviceURL, sVer, deployment)
+	}
+	if err != nil {
+		b.removeSecretFiles(agreementId)
+		b.removeMMSObjectFiles(agreementId)
+		return nil, err
+	}
+
 	// process services that are "shared" first, then others
 	shared := make(map[string]servicePair, 0)
 	private := make(map[string]servicePair, 0)
@@ -1189,6 +1336,11 @@ func (b *ContainerWorker) ResourcesCreate(agreementId string, agreementProtocol
 			return nil, err
 		}
 	}
+
+	// record the retention of the named volumes, and restore the ones that are waiting for it
+	if err := b.claimServiceVolumes(agreementId, serviceURL, sVer, servicePairs); err != nil {
+		return nil, err
+	}
 	// finished pre-processing
 
 	// process shared by finding existing or creating new then hooking up "private" in pattern to the shared by adding two endpoints. Note! a shared container is not in the agreement bridge it came from
@@ -1304,6 +1456,25 @@ func (b *ContainerWorker) ResourcesCreate(agreementId string, agreementProtocol
 
 func (b *ContainerWorker) Initialize() bool {
 	b.syncupResources()
+
+	// the secret files are on a tmpfs file system, they are gone when the node was rebooted
+	b.restoreSecretFiles()
+
+	// failed containers are found from the docker events, instead of waiting for the next maintenance check
+	if b.client != nil {
+		go b.watchEvents()
+	}
+
+	// secrets that are referenced by running services are written again when they are rotated
+	if !b.secretResolver.IsEmpty() {
+		b.DispatchSubworker(SECRET_REFRESH, b.refreshSecrets, b.Config.GetSecretRefreshInterval(), false)
+	}
+
+	// new versions of the model management objects are delivered into the containers that use them
+	b.DispatchSubworker(MMS_OBJECT_REFRESH, b.refreshMMSObjects, b.Config.GetMMSObjectRefreshInterval(), false)
+
+	// named volumes that are kept for a number of days are removed when the days are over
+	b.DispatchSubworker(VOLUME_EXPIRY, b.expireVolumes, VOLUME_EXPIRY_INTERVAL_S, false)
 	return true
 }
 
@@ -1327,6 +1498,12 @@ func (b *ContainerWorker) CommandHandler(command worker.Command) bool {
 			glog.Infof("Receved configure command for agreement %v. Ignoring it because the containers for this agreement has been configured.", agreementId)
 		} else if ms_containers, err := b.findDependencyContainersForService(persistence.NewServiceInstancePathElement(ags[0].RunningWorkload.URL, ags[0].RunningWorkload.Org, ags[0].RunningWorkload.Version), []string{agreementId}, cmd.AgreementLaunchContext.Microservices); err != nil {
 			glog.Errorf("Error checking service containers: %v", err)
+			if notReady, ok := err.(*NotReadyError); ok && notReady.TimedOut {
+				// the agreement is given up on too, rather than waiting for the dependency to be started again
+				b.reportNotReady(notReady, []string{agreementId})
+				b.Messages() <- events.NewWorkloadMessage(events.EXECUTION_FAILED, cmd.AgreementLaunchContext.AgreementProtocol, agreementId, nil)
+				return true
+			}
 
 			// requeue the command
 			b.AddDeferredCommand(cmd)
@@ -1377,6 +1554,16 @@ func (b *ContainerWorker) CommandHandler(command worker.Command) bool {
 				}
 			}
 
+			// Expand the node and agreement values that the deployment string refers to.
+			if err := deploymentDesc.ExpandTemplates(cmd.AgreementLaunchContext.TemplateData); err != nil {
+				eventlog.LogAgreementEvent(b.db, persistence.SEVERITY_ERROR,
+					persistence.NewMessageMeta(EL_CONT_DEPLOYCONF_TEMPLATE_ERROR, cmd.AgreementLaunchContext.Configure.Deployment, err.Error()),
+					persistence.EC_ERROR_IN_DEPLOYMENT_CONFIG, ags[0])
+				glog.Errorf("Error expanding the templates in deployment config %v for agreement %v, error: %v", cmd.AgreementLaunchContext.Configure.Deployment, agreementId, err)
+				b.Messages() <- events.NewWorkloadMessage(events.EXECUTION_FAILED, cmd.AgreementLaunchContext.AgreementProtocol, agreementId, nil)
+				return true
+			}
+
 			// Dynamically add in a filesystem mapping so that the workload container has a RO filesystem.
 			for serviceName, service := range deploymentDesc.Services {
 
@@ -1407,10 +1594,10 @@ func (b *ContainerWorker) CommandHandler(command worker.Command) bool {
 			sVer := ags[0].RunningWorkload.Version
 
 			// Create the docker configuration and launch the containers.
-			if deploymentConfig, err := b.ResourcesCreate(agreementId, cmd.AgreementLaunchContext.AgreementProtocol, &cmd.AgreementLaunchContext.Configure, deploymentDesc, cmd.AgreementLaunchContext.ConfigureRaw, *cmd.AgreementLaunchContext.EnvironmentAdditions, ms_children_networks, serviceIdentity, sVer); err != nil {
+			if deploymentConfig, err := b.ResourcesCreate(agreementId, cmd.AgreementLaunchContext.AgreementProtocol, &cmd.AgreementLaunchContext.Configure, deploymentDesc, cmd.AgreementLaunchContext.ConfigureRaw, *cmd.AgreementLaunchContext.EnvironmentAdditions, cmd.AgreementLaunchContext.Secrets, ms_children_networks, serviceIdentity, sVer); err != nil {
 				eventlog.LogAgreementEvent(b.db, persistence.SEVERITY_ERROR,
 					persistence.NewMessageMeta(EL_CONT_START_CONTAINER_ERROR, err.Error()),
-					persistence.EC_ERROR_START_CONTAINER,
+					startContainerErrorCode(err),
 					ags[0])
 				glog.Errorf("Error starting containers: %v", err)
 				b.Messages() <- events.NewWorkloadMessage(events.EXECUTION_FAILED, cmd.AgreementLaunchContext.AgreementProtocol, agreementId, deploymentConfig) // still using deployment here, need it to shutdown containers
@@ -1419,6 +1606,7 @@ func (b *ContainerWorker) CommandHandler(command worker.Command) bool {
 				glog.Infof("Success starting pattern for agreement: %v, protocol: %v, serviceNames: %v", agreementId, cmd.AgreementLaunchContext.AgreementProtocol, deploymentConfig.ToString())
 
 				// perhaps add the tc info to the container message so it can be enforced
+				b.watch.setStarted(agreementId)
 				b.Messages() <- events.NewWorkloadMessage(events.EXECUTION_BEGUN, cmd.AgreementLaunchContext.AgreementProtocol, agreementId, deploymentConfig)
 			}
 		}
@@ -1455,6 +1643,12 @@ func (b *ContainerWorker) CommandHandler(command worker.Command) bool {
 		if len(lc.Microservices) != 0 {
 			if ms_containers, err := b.findDependencyContainersForService(lc.GetServicePathElement(), lc.AgreementIds, lc.Microservices); err != nil {
 				glog.Errorf("Error checking service containers: %v", err)
+				if notReady, ok := err.(*NotReadyError); ok && notReady.TimedOut {
+					// this service is retried too, rather than waiting for the dependency to be started again
+					b.reportNotReady(notReady, lc.AgreementIds)
+					b.Messages() <- events.NewContainerMessage(events.EXECUTION_FAILED, *cmd.ContainerLaunchContext, "", "")
+					return true
+				}
 
 				// Requeue the command
 				b.AddDeferredCommand(cmd)
@@ -1515,6 +1709,14 @@ func (b *ContainerWorker) CommandHandler(command worker.Command) bool {
 			glog.Errorf("Deployment config %v contains unsupported capability for infrastructure container.", lc.Configure.Deployment)
 			b.Messages() <- events.NewContainerMessage(events.EXECUTION_FAILED, *cmd.ContainerLaunchContext, "", "")
 			return true
+		} else if err := deploymentDesc.ExpandTemplates(lc.TemplateData); err != nil {
+			eventlog.LogServiceEvent2(b.db, persistence.SEVERITY_ERROR,
+				persistence.NewMessageMeta(EL_CONT_DEPLOYCONF_TEMPLATE_ERROR, lc.Configure.Deployment, err.Error()),
+				persistence.EC_ERROR_IN_DEPLOYMENT_CONFIG,
+				"", lc.ServicePathElement.URL, lc.ServicePathElement.Org, lc.ServicePathElement.Version, "", lc.AgreementIds)
+			glog.Errorf("Error expanding the templates in deployment config %v, error: %v", lc.Configure.Deployment, err)
+			b.Messages() <- events.NewContainerMessage(events.EXECUTION_FAILED, *cmd.ContainerLaunchContext, "", "")
+			return true
 		}
 
 		serviceNames := deploymentDesc.ServiceNames()
@@ -1574,14 +1776,14 @@ func (b *ContainerWorker) CommandHandler(command worker.Command) bool {
 		sVer := lc.ServicePathElement.Version
 
 		// Get the container started
-		if deployment, err := b.ResourcesCreate(lc.Name, "", &lc.Configure, deploymentDesc, []byte(""), *lc.EnvironmentAdditions, ms_children_networks, serviceIdentity, sVer); err != nil {
+		if deployment, err := b.ResourcesCreate(lc.Name, "", &lc.Configure, deploymentDesc, []byte(""), *lc.EnvironmentAdditions, lc.Secrets, ms_children_networks, serviceIdentity, sVer); err != nil {
 			log_str := EL_CONT_START_CONTAINER_ERROR_FOR_AG
 			if lc.IsRetry {
 				log_str = EL_CONT_RESTART_CONTAINER_ERROR_FOR_AG
 			}
 			eventlog.LogServiceEvent2(b.db, persistence.SEVERITY_ERROR,
 				persistence.NewMessageMeta(log_str, fmt.Sprintf("%v", lc.AgreementIds), err.Error()),
-				persistence.EC_ERROR_START_CONTAINER, "",
+				startContainerErrorCode(err), "",
 				lc.ServicePathElement.URL, lc.ServicePathElement.Org, lc.ServicePathElement.Version, "", lc.AgreementIds)
 			glog.Errorf("Error starting containers: %v", err)
 			b.Messages() <- events.NewContainerMessage(events.EXECUTION_FAILED, *cmd.ContainerLaunchContext, "", "")
@@ -1609,6 +1811,7 @@ func (b *ContainerWorker) CommandHandler(command worker.Command) bool {
 			}
 
 			glog.V(1).Infof("Success starting container pattern for serviceNames: %v", deployment.ToString())
+			b.watch.setStarted(lc.Name)
 
 			// perhaps add the tc info to the container message so it can be enforced
 			if ov := os.Getenv("CMTN_SERVICEOVERRIDE"); ov != "" {
@@ -1624,7 +1827,10 @@ func (b *ContainerWorker) CommandHandler(command worker.Command) bool {
 
 		cMatches := make([]docker.APIContainers, 0)
 
-		if cmd.Deployment.IsNative() {
+		if cmd.Deployment.IsNative() && !b.watch.needsReconcile(cmd.AgreementId, b.Config.Edge.ContainerReconcileIntervalS) {
+			glog.V(5).Infof("ContainerWorker skipping container check for agreement %v, the docker events are being received", cmd.AgreementId)
+
+		} else if cmd.Deployment.IsNative() {
 
 			nd := cmd.Deployment.(*persistence.NativeDeploymentConfig)
 			serviceNames := persistence.ServiceConfigNames(&nd.Services)
@@ -1669,6 +1875,11 @@ func (b *ContainerWorker) CommandHandler(command worker.Command) bool {
 			agreements = append(agreements, cmd.CurrentAgreementId)
 		}
 
+		// the containers are about to be stopped, so the events about them are expected
+		for _, ag := range agreements {
+			b.watch.setStopped(ag)
+		}
+
 		if err := b.ResourcesRemove(agreements); err != nil {
 			glog.Errorf("Error removing resources: %v", err)
 		}
@@ -1680,6 +1891,7 @@ func (b *ContainerWorker) CommandHandler(command worker.Command) bool {
 		cmd := command.(*ContainerStopCommand)
 
 		glog.V(3).Infof("ContainerWorker received infrastructure container stop command: %v", cmd)
+		b.watch.setStopped(cmd.Msg.ContainerName)
 		if err := b.ResourcesRemove([]string{cmd.Msg.ContainerName}); err != nil {
 			glog.Errorf("Error removing resources: %v", err)
 		}
@@ -1693,7 +1905,9 @@ func (b *ContainerWorker) CommandHandler(command worker.Command) bool {
 
 		cMatches := make([]docker.APIContainers, 0)
 
-		if msinst, err := persistence.FindMicroserviceInstanceWithKey(b.db, cmd.MsInstKey); err != nil {
+		if !b.watch.needsReconcile(cmd.MsInstKey, b.Config.Edge.ContainerReconcileIntervalS) {
+			glog.V(5).Infof("ContainerWorker skipping container check for service instance %v, the docker events are being received", cmd.MsInstKey)
+		} else if msinst, err := persistence.FindMicroserviceInstanceWithKey(b.db, cmd.MsInstKey); err != nil {
 			glog.Errorf("Error retrieving service instance from database for %v, error: %v", cmd.MsInstKey, err)
 		} else if msinst == nil {
 			glog.Errorf("Cannot find service instance record from database for %v.", cmd.MsInstKey)
@@ -1736,6 +1950,7 @@ func (b *ContainerWorker) CommandHandler(command worker.Command) bool {
 		if cmd.MsInstKey != "" {
 			glog.Infof("ContainerWorker received shutdown command for service %v. Shutting down resources", cmd.MsInstKey)
 			agreements = append(agreements, cmd.MsInstKey)
+			b.watch.setStopped(cmd.MsInstKey)
 		}
 
 		if err := b.ResourcesRemove(agreements); err != nil {
@@ -1745,7 +1960,26 @@ func (b *ContainerWorker) CommandHandler(command worker.Command) bool {
 		// send the event to let others know that the microservice clean up has been processed
 		b.Messages() <- events.NewMicroserviceContainersDestroyedMessage(events.CONTAINER_DESTROYED, cmd.MsInstKey)
 
+	case *ContainerEventCommand:
+		cmd := command.(*ContainerEventCommand)
+		b.handleContainerEvent(cmd.Event)
+
+	case *MMSObjectUpdatedCommand:
+		cmd := command.(*MMSObjectUpdatedCommand)
+		b.handleMMSObjectUpdated(cmd)
+
+	case *ContainerReadyCommand:
+		// the configure commands that wait for dependencies are tried again now rather than after the deferred delay
+		if b.HasDeferredCommands() {
+			b.RequeueDeferredCommands()
+		}
+
+	case *EventStreamCommand:
+		cmd := command.(*EventStreamCommand)
+		b.watch.setConnected(cmd.Connected)
+
 	case *NodeUnconfigCommand:
+		b.watch.Stop()
 		if err := b.GetAuthenticationManager().RemoveAll(); err != nil {
 			glog.Errorf("Error handling node unconfig command: %v", err)
 		}
@@ -2039,6 +2273,19 @@ func (b *ContainerWorker) ResourcesRemove(agreements []string) error {
 			glog.Errorf("Failed to remove FSS Authentication credential file for %v, error %v", agreementId, err)
 		}
 
+		// Remove the secret user input files.
+		if err := b.removeSecretFiles(agreementId); err != nil {
+			glog.Errorf("Failed to remove secret files for %v, error %v", agreementId, err)
+		}
+
+		// Remove the model management object files.
+		if err := b.removeMMSObjectFiles(agreementId); err != nil {
+			glog.Errorf("Failed to remove object files for %v, error %v", agreementId, err)
+		}
+
+		// Release the named volumes, the ones that are not kept are removed.
+		b.releaseServiceVolumes(agreementId)
+
 	}
 
 	// gather agreement networks to free
@@ -2093,17 +2340,16 @@ func (b *ContainerWorker) ResourcesRemove(agreements []string) error {
 				return fmt.Errorf("Unable to list rules in %v. Error: %v", IPT_COLONUS_ISOLATED_CHAIN, err)
 			}
 
-			for _, agreementId := range agreements {
-				glog.V(4).Infof("Removing iptables isolation rules for agreement %v", agreementId)
+			glog.V(4).Infof("Removing iptables isolation rules for agreements %v", agreements)
 
-				// count backwards so we don't have to adjust the indices b/c they change w/ each ipt delete
-				for ix := len(rules) - 1; ix >= 0; ix-- {
-					if strings.Contains(rules[ix], fmt.Sprintf("agreement_id=%v", agreementId)) {
+			// count backwards so we don't have to adjust the indices b/c they change w/ each ipt delete. A rule between
+			// two agreements names both of them, so it is deleted once even when both agreements are removed.
+			for ix := len(rules) - 1; ix >= 0; ix-- {
+				if ruleMatchesAgreements(rules[ix], agreements) {
 
-						glog.V(3).Infof("Deleting isolation rule: %v", rules[ix])
-						if err := b.iptables.Delete("filter", IPT_COLONUS_ISOLATED_CHAIN, strconv.Itoa(ix)); err != nil {
-							return err
-						}
+					glog.V(3).Infof("Deleting isolation rule: %v", rules[ix])
+					if err := b.iptables.Delete("filter", IPT_COLONUS_ISOLATED_CHAIN, strconv.Itoa(ix)); err != nil {
+						return err
 					}
 				}
 			}
@@ -2179,7 +2425,8 @@ func (b *ContainerWorker) findMicroserviceDefContainerNames(api_spec string, org
 }
 
 // This function finds the all the containers of the direct childrent for the given service.
-// It only includes the containers with "running" state.
+// It only includes the containers with "running" state, and returns a *NotReadyError when a container has not
+// passed its readiness probe.
 func (b *ContainerWorker) findDependencyContainersForService(parent *persistence.ServiceInstancePathElement, agreementIds []string, microservices []events.MicroserviceSpec) ([]docker.APIContainers, error) {
 	ms_containers := make([]docker.APIContainers, 0)
 	if containers, err := b.client.ListContainers(docker.ListContainersOptions{}); err != nil {
@@ -2236,9 +2483,11 @@ func (b *ContainerWorker) findDependencyContainersForService(parent *persistence
 						if _, ok := container.Labels[LABEL_PREFIX+".infrastructure"]; ok {
 							cname := container.Names[0]
 							if cname == "/"+ms_instance.GetKey()+"-"+serviceName {
-								// check if the container is up and running
+								// check if the container is up and running, and ready if it has a readiness probe
 								if container.State != "running" {
 									return nil, fmt.Errorf("The service container %v is not up and running. %v", serviceName, err)
+								} else if err := b.checkReadiness(&container, ms_instance.GetKey(), api_spec); err != nil {
+									return nil, err
 								} else {
 									glog.V(5).Infof("Found running service container %v for service %v", container, api_spec)
 									ms_containers = append(ms_containers, container)
@@ -2424,6 +2673,15 @@ func isAnaxNetwork(net *docker.Network, bridgeName string) bool {
 	return false
 }
 
+// Returns the event log code for an error returned by ResourcesCreate, so that deployments rejected by the node
+// admission policy can be told apart from deployments that failed to start.
+func startContainerErrorCode(err error) string {
+	if _, ok := err.(*containermessage.AdmissionError); ok {
+		return persistence.EC_ERROR_ADMISSION_POLICY
+	}
+	return persistence.EC_ERROR_START_CONTAINER
+}
+
 // Verify that the permission bits for the host side of the binding allow anyone/other
 // to access that file or directory.
 func hasValidBindPermissions(binds []string) error {
@@ -2514,9 +2772,14 @@ func (b *ContainerWorker) createDockerVolumesForContainer(serviceName string, ag
 				} else {
 					glog.V(3).Infof("Volume %v created for service %v.", vol_name, serviceName)
 
-					// same the volume in local db so that it can be cleaned up at unregistration time
+					// same the volume in local db so that it can be cleaned up at unregistration time, unless it is
+					// already recorded because it is waiting to be restored from a backup.
 					// Ling todo - only save the ones that are specified by the user in binds.
-					if persistence.SaveContainerVolumeByName(b.db, vol_name); err != nil {
+					if cv, err := persistence.FindUndeletedContainerVolume(b.db, vol_name); err != nil {
+						return fmt.Errorf("Failed to read the docker volume %v from the local db. %v", vol_name, err)
+					} else if cv != nil {
+						continue
+					} else if err := persistence.SaveContainerVolumeByName(b.db, vol_name); err != nil {
 						return fmt.Errorf("Failed to get save the docker volume name %v into the local db. %v", vol_name, err)
 					}
 				}
@@ -2545,7 +2808,7 @@ func DeleteLeftoverDockerVolumes(db *bolt.DB, config *config.HorizonConfig) erro
 		return nil
 	}
 
-	if client, err := docker.NewClient(config.Edge.DockerEndpoint); err != nil {
+	if client, err := containerruntime.NewDockerRuntime(config.Edge.DockerEndpoint); err != nil {
 		return fmt.Errorf("Failed to instantiate docker Client: %v", err)
 	} else {
 		// check existing docker volumes
@@ -2560,6 +2823,12 @@ func DeleteLeftoverDockerVolumes(db *bolt.DB, config *config.HorizonConfig) erro
 
 		for _, cv := range cvs {
 
+			// named volumes that are kept outlive the registration, the ones kept for some days expire on their own
+			if policy, _, err := containermessage.ParseVolumeRetention(cv.Retention); err == nil && policy == containermessage.VOLUME_RETENTION_KEEP {
+				glog.V(3).Infof("Docker volume %v is kept in cleanup process.", cv.Name)
+				continue
+			}
+
 			// make sure the volume still exists and it has openhorizon as the owner
 			found := false
 			for _, dv := range volumes_docker {
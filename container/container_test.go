@@ -271,3 +271,59 @@ func Test_CheckPermissions_0(t *testing.T) {
 	}
 
 }
+
+func Test_UnmarshalInboundPermits(t *testing.T) {
+	s := `
+		{
+			"outbound_permit_only": [],
+			"inbound_permit_only": [{"from": ["service_a", "10.1.0.0/16"], "ports": ["8080", "53/udp"]}, {}]
+		}
+	`
+
+	var n containermessage.NetworkIsolation
+
+	if err := json.Unmarshal([]byte(s), &n); err != nil {
+		t.Error(err)
+	} else if len(n.InboundPermitOnly) != 2 || len(n.InboundPermitOnly[0].From) != 2 || len(n.InboundPermitOnly[0].Ports) != 2 {
+		t.Errorf("Ill-formed inbound permits %v", n.InboundPermitOnly)
+	} else if err := n.Validate(); err != nil {
+		t.Error(err)
+	}
+
+	svc := containermessage.Service{NetworkIsolation: &n}
+	if label, err := inboundPermitLabel(&svc); err != nil {
+		t.Error(err)
+	} else if peer, err := newNetworkPeer(map[string]string{LABEL_PREFIX + ".service_name": "service_b", LABEL_INBOUND_PERMIT_ONLY: label}, "172.17.0.2/16"); err != nil {
+		t.Error(err)
+	} else if peer.serviceName != "service_b" || peer.ip != "172.17.0.2" || len(peer.permits) != 2 {
+		t.Errorf("Ill-formed network peer %v", peer)
+	}
+
+	n.InboundPermitOnly = append(n.InboundPermitOnly, containermessage.InboundPermit{Ports: []string{"80/icmp"}})
+	if err := n.Validate(); err == nil {
+		t.Errorf("Expected an error for protocol icmp")
+	}
+}
+
+func Test_inboundComment(t *testing.T) {
+	if c := inboundComment("a1", ""); c != "agreement_id=a1" {
+		t.Errorf("unexpected comment %v", c)
+	} else if c := inboundComment("a1", "a1"); c != "agreement_id=a1" {
+		t.Errorf("unexpected comment %v", c)
+	} else if c := inboundComment("a1", "a2"); c != "agreement_id=a1,agreement_id=a2" {
+		t.Errorf("unexpected comment %v", c)
+	}
+}
+
+func Test_ruleMatchesAgreements(t *testing.T) {
+	rule := `-A HORIZON-ISOLATED -s 10.0.0.2/32 -d 10.0.0.3/32 -m comment --comment "agreement_id=a1,agreement_id=a2" -j ACCEPT`
+	if !ruleMatchesAgreements(rule, []string{"a1"}) || !ruleMatchesAgreements(rule, []string{"a2"}) || !ruleMatchesAgreements(rule, []string{"a1", "a2"}) {
+		t.Errorf("expected the rule to match its agreements")
+	} else if ruleMatchesAgreements(rule, []string{"a"}) || ruleMatchesAgreements(rule, []string{"a3"}) {
+		t.Errorf("expected the rule not to match other agreements")
+	} else if !ruleMatchesAgreements("-A HORIZON-ISOLATED -d 10.0.0.3/32 -m comment --comment agreement_id=a10 -j REJECT", []string{"a1", "a10"}) {
+		t.Errorf("expected the rule to match the agreement that its prefix agreement does not")
+	} else if ruleMatchesAgreements("-A HORIZON-ISOLATED -d 10.0.0.3/32 -m comment --comment agreement_id=a10 -j REJECT", []string{"a1"}) {
+		t.Errorf("expected the rule not to match an agreement id that is a prefix")
+	}
+}
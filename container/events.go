@@ -0,0 +1,289 @@
+package container
+
+import (
+	"fmt"
+	docker "github.com/fsouza/go-dockerclient"
+	"github.com/golang/glog"
+	"github.com/open-horizon/anax/events"
+	"github.com/open-horizon/anax/persistence"
+	"github.com/open-horizon/anax/policy"
+	"github.com/open-horizon/anax/worker"
+	"sync"
+	"time"
+)
+
+// The docker event actions that mean that a container of an agreement or service instance is no longer working.
+const (
+	EVENT_CONTAINER_DIE       = "die"
+	EVENT_CONTAINER_OOM       = "oom"
+	EVENT_CONTAINER_UNHEALTHY = "health_status: unhealthy"
+)
+
+// The docker event action of a container that passed its readiness probe.
+const EVENT_CONTAINER_HEALTHY = "health_status: healthy"
+
+// The number of events that can be waiting for the worker before docker is made to wait.
+const EVENT_QUEUE_SIZE = 100
+
+// The delay before subscribing to the event stream again after it ends. It doubles up to the maximum.
+const EVENT_RESUBSCRIBE_MIN_S = 5
+const EVENT_RESUBSCRIBE_MAX_S = 300
+
+// How long the keys of stopped agreements and service instances are remembered.
+const EVENT_STOPPED_KEY_TTL_S = 3600
+
+// The state of the docker event stream of the worker. The worker learns about failed containers from the event stream,
+// and lists the containers of an agreement or service instance only to reconcile the events that it might have missed.
+// Apart from the stop channel, the state is only used by the command handler.
+type eventWatch struct {
+	connected  bool             // the worker is subscribed to the event stream
+	stopped    map[string]int64 // the agreements and service instances whose containers have been reported or removed
+	reconciled map[string]int64 // when the containers of each agreement or service instance were last listed
+	restarting map[string]bool  // the containers that the worker is restarting, by id
+	stop       chan bool
+	stopOnce   sync.Once
+}
+
+func newEventWatch() *eventWatch {
+	return &eventWatch{
+		stopped:    make(map[string]int64),
+		reconciled: make(map[string]int64),
+		restarting: make(map[string]bool),
+		stop:       make(chan bool),
+	}
+}
+
+// Stop watching the event stream. It is safe to call more than once.
+func (e *eventWatch) Stop() {
+	e.stopOnce.Do(func() { close(e.stop) })
+}
+
+// Record that the containers of an agreement or service instance are expected to stop, so that the events about them
+// are ignored until they are started again.
+func (e *eventWatch) setStopped(key string) {
+	now := time.Now().Unix()
+	for k, t := range e.stopped {
+		if now-t > EVENT_STOPPED_KEY_TTL_S {
+			delete(e.stopped, k)
+		}
+	}
+	e.stopped[key] = now
+}
+
+func (e *eventWatch) setStarted(key string) {
+	delete(e.stopped, key)
+}
+
+func (e *eventWatch) isStopped(key string) bool {
+	_, ok := e.stopped[key]
+	return ok
+}
+
+// Record that a container is restarted by the worker, so that the event about it stopping is ignored.
+func (e *eventWatch) setRestarting(id string) {
+	e.restarting[id] = true
+}
+
+func (e *eventWatch) setRestarted(id string) {
+	delete(e.restarting, id)
+}
+
+func (e *eventWatch) isRestarting(id string) bool {
+	return e.restarting[id]
+}
+
+// Returns true when the containers of an agreement or service instance need to be listed. While the event stream is
+// connected they are only listed once in the reconcile interval, in case an event was missed.
+func (e *eventWatch) needsReconcile(key string, intervalS int) bool {
+	now := time.Now().Unix()
+	if e.connected && now-e.reconciled[key] < int64(intervalS) {
+		return false
+	}
+	e.reconciled[key] = now
+	return true
+}
+
+func (e *eventWatch) setConnected(connected bool) {
+	e.connected = connected
+	e.reconciled = make(map[string]int64)
+}
+
+// Subscribe to the docker event stream and pass the events about failed containers to the command handler. The
+// subscription is renewed when the stream ends, e.g. because docker was restarted, until the watch is stopped.
+func (b *ContainerWorker) watchEvents() {
+
+	delay := EVENT_RESUBSCRIBE_MIN_S
+
+	for {
+		listener := make(chan *docker.APIEvents, EVENT_QUEUE_SIZE)
+		if err := b.client.AddEventListener(listener); err != nil {
+			glog.Errorf("ContainerWorker unable to subscribe to docker events, error %v", err)
+		} else {
+			glog.V(3).Infof("ContainerWorker subscribed to docker events")
+			if !b.sendEventCommand(NewEventStreamCommand(true)) {
+				b.client.RemoveEventListener(listener)
+				return
+			}
+			delay = EVENT_RESUBSCRIBE_MIN_S
+
+			if !b.receiveEvents(listener) {
+				b.client.RemoveEventListener(listener)
+				return
+			}
+
+			glog.Warningf("ContainerWorker docker event stream ended, containers will be listed until it is resumed")
+			if !b.sendEventCommand(NewEventStreamCommand(false)) {
+				return
+			}
+		}
+
+		select {
+		case <-b.watch.stop:
+			return
+		case <-time.After(time.Duration(delay) * time.Second):
+		}
+
+		if delay *= 2; delay > EVENT_RESUBSCRIBE_MAX_S {
+			delay = EVENT_RESUBSCRIBE_MAX_S
+		}
+	}
+}
+
+// Receive events until the listener is closed, which returns true, or until the watch is stopped.
+func (b *ContainerWorker) receiveEvents(listener chan *docker.APIEvents) bool {
+	for {
+		select {
+		case <-b.watch.stop:
+			return false
+		case event, ok := <-listener:
+			if !ok {
+				return true
+			} else if isContainerFailure(event) {
+				if !b.sendEventCommand(NewContainerEventCommand(event)) {
+					return false
+				}
+			} else if event.Type == "container" && event.Action == EVENT_CONTAINER_HEALTHY {
+				if !b.sendEventCommand(NewContainerReadyCommand(event.Actor.ID)) {
+					return false
+				}
+			}
+		}
+	}
+}
+
+// Queue a command for the worker, unless the watch is stopped, in which case the worker might no longer be reading commands.
+func (b *ContainerWorker) sendEventCommand(command worker.Command) bool {
+	select {
+	case <-b.watch.stop:
+		return false
+	case b.Commands <- command:
+		return true
+	}
+}
+
+func isContainerFailure(event *docker.APIEvents) bool {
+	if event.Type != "container" {
+		return false
+	}
+	switch event.Action {
+	case EVENT_CONTAINER_DIE, EVENT_CONTAINER_OOM, EVENT_CONTAINER_UNHEALTHY:
+		return true
+	}
+	return false
+}
+
+// Returns the reason that an event gives for the failure of a container.
+func eventReason(event *docker.APIEvents) string {
+	name := event.Actor.Attributes["name"]
+	switch event.Action {
+	case EVENT_CONTAINER_DIE:
+		return fmt.Sprintf("container %v exited with code %v", name, event.Actor.Attributes["exitCode"])
+	case EVENT_CONTAINER_OOM:
+		return fmt.Sprintf("container %v ran out of memory", name)
+	case EVENT_CONTAINER_UNHEALTHY:
+		return fmt.Sprintf("container %v is unhealthy", name)
+	}
+	return fmt.Sprintf("container %v: %v", name, event.Action)
+}
+
+// Returns true when docker will start a container that died again, according to its restart policy. A container that
+// cannot be inspected, e.g. because it was removed, is not restarted.
+func (b *ContainerWorker) dockerRestarts(id string) bool {
+	container, err := b.client.InspectContainer(id)
+	if err != nil {
+		glog.V(3).Infof("ContainerWorker unable to inspect container %v, error %v", id, err)
+		return false
+	} else if container.State.Running || container.State.Restarting {
+		return true
+	} else if container.HostConfig == nil {
+		return false
+	}
+
+	policy := container.HostConfig.RestartPolicy
+	switch policy.Name {
+	case "always", "unless-stopped":
+		return true
+	case "on-failure":
+		return container.State.ExitCode != 0 && (policy.MaximumRetryCount == 0 || container.RestartCount < policy.MaximumRetryCount)
+	}
+	return false
+}
+
+// Report the failure of a container to the agreement or service instance that it belongs to. Shared containers are not
+// labeled with an agreement, so they are left to the service instances that use them. A container that died is only a
+// failure when docker will not restart it. A container that docker keeps restarting is left to the reconcile pass,
+// which fails the agreement when the container is not running.
+func (b *ContainerWorker) handleContainerEvent(event *docker.APIEvents) {
+
+	if event.Action == EVENT_CONTAINER_DIE && b.watch.isRestarting(event.Actor.ID) {
+		glog.V(5).Infof("ContainerWorker ignoring event for container %v that is being restarted", event.Actor.ID)
+		b.watch.setRestarted(event.Actor.ID)
+		return
+	} else if event.Action == EVENT_CONTAINER_DIE && b.dockerRestarts(event.Actor.ID) {
+		glog.V(3).Infof("ContainerWorker ignoring event for container %v that docker will restart: %v", event.Actor.ID, eventReason(event))
+		return
+	}
+
+	key, ok := event.Actor.Attributes[LABEL_PREFIX+".agreement_id"]
+	if !ok || key == "" {
+		glog.V(5).Infof("ContainerWorker ignoring event for container %v without an agreement", event.Actor.ID)
+		return
+	} else if b.watch.isStopped(key) {
+		glog.V(5).Infof("ContainerWorker ignoring event for container %v of stopped %v", event.Actor.ID, key)
+		return
+	}
+
+	reason := eventReason(event)
+
+	if _, infrastructure := event.Actor.Attributes[LABEL_PREFIX+".infrastructure"]; infrastructure {
+
+		if msinst, err := persistence.FindMicroserviceInstanceWithKey(b.db, key); err != nil {
+			glog.Errorf("Error retrieving service instance from database for %v, error: %v", key, err)
+		} else if msinst == nil || msinst.Archived || msinst.CleanupStartTime != 0 {
+			glog.V(3).Infof("ContainerWorker ignoring event for container of inactive service instance %v: %v", key, reason)
+		} else if msinst.ExecutionStartTime == 0 {
+			glog.V(3).Infof("ContainerWorker ignoring event for container of service instance %v that is still starting: %v", key, reason)
+		} else {
+			glog.Errorf("Service container failed for service instance %v: %v", key, reason)
+			b.watch.setStopped(key)
+
+			// ask governer to record it into the db
+			cc := events.NewContainerConfig("", "", "", "", "", "", nil)
+			ll := events.NewContainerLaunchContext(cc, nil, events.BlockchainConfig{}, key, []string{}, []events.MicroserviceSpec{}, persistence.NewServiceInstancePathElement("", "", ""), false)
+			b.Messages() <- events.NewContainerMessage(events.EXECUTION_FAILED, *ll, "", "")
+		}
+
+	} else if ags, err := persistence.FindEstablishedAgreementsAllProtocols(b.db, policy.AllAgreementProtocols(), []persistence.EAFilter{persistence.UnarchivedEAFilter(), persistence.IdEAFilter(key)}); err != nil {
+		glog.Errorf("Unable to retrieve agreement %v from database, error %v", key, err)
+	} else if len(ags) != 1 || ags[0].AgreementTerminatedTime != 0 {
+		glog.V(3).Infof("ContainerWorker ignoring event for container of inactive agreement %v: %v", key, reason)
+	} else if ags[0].AgreementExecutionStartTime == 0 {
+		glog.V(3).Infof("ContainerWorker ignoring event for container of agreement %v that is still starting: %v", key, reason)
+	} else {
+		glog.Errorf("Workload container failed for agreement %v: %v", key, reason)
+		b.watch.setStopped(key)
+
+		// ask governer to cancel the agreement
+		b.Messages() <- events.NewWorkloadMessage(events.EXECUTION_FAILED, ags[0].AgreementProtocol, key, ags[0].GetDeploymentConfig())
+	}
+}
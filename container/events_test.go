@@ -0,0 +1,220 @@
+// +build unit
+
+package container
+
+import (
+	docker "github.com/fsouza/go-dockerclient"
+	"github.com/open-horizon/anax/containermessage"
+	"github.com/open-horizon/anax/events"
+	"github.com/open-horizon/anax/persistence"
+	"github.com/open-horizon/anax/policy"
+	"testing"
+	"time"
+)
+
+func Test_handleContainerEvent_agreement(t *testing.T) {
+
+	dir, db, fake, w := fakeWorkerSetup(t)
+	defer cleanFakeWorker(dir, db)
+	w.Manager.Messages = make(chan events.Message, 10)
+
+	fake.AddImage("myorg/app:1.0")
+	deployment := &containermessage.DeploymentDescription{
+		Services: map[string]*containermessage.Service{
+			"app": {Image: "myorg/app:1.0"},
+		},
+	}
+	if _, err := w.ResourcesCreate("ag1", "", nil, deployment, []byte("{}"), fakeEnvironment(), nil, nil, "https://myorg/app", "1.0"); err != nil {
+		t.Fatalf("unexpected error creating resources, %v", err)
+	}
+
+	listener := make(chan *docker.APIEvents, 10)
+	fake.AddEventListener(listener)
+
+	// the agreement is not known yet, so the failure is not reported
+	fake.SendContainerEvent("ag1-app", EVENT_CONTAINER_UNHEALTHY)
+	w.handleContainerEvent(<-listener)
+	checkNoMessage(t, w)
+
+	wi, _ := persistence.NewWorkloadInfo("https://myorg/app", "myorg", "1.0", "")
+	if _, err := persistence.NewEstablishedAgreement(db, "ag1", "ag1", "agbot", "{}", policy.BasicProtocol, 1, nil, "", "", "", "", "", wi); err != nil {
+		t.Fatalf("unable to save the agreement, %v", err)
+	} else if _, err := persistence.AgreementStateExecutionStarted(db, "ag1", policy.BasicProtocol); err != nil {
+		t.Fatalf("unable to update the agreement, %v", err)
+	}
+
+	// the container is restarted by docker, so it is not a failure when it dies
+	fake.SendContainerEvent("ag1-app", EVENT_CONTAINER_DIE)
+	event := <-listener
+	w.handleContainerEvent(event)
+	checkNoMessage(t, w)
+
+	// but it is when it runs out of memory
+	fake.SendContainerEvent("ag1-app", EVENT_CONTAINER_OOM)
+	event = <-listener
+	if !isContainerFailure(event) {
+		t.Errorf("expected a container failure, got %v", event)
+	}
+	w.handleContainerEvent(event)
+
+	select {
+	case msg := <-w.Messages():
+		if wm, ok := msg.(*events.WorkloadMessage); !ok || wm.Event().Id != events.EXECUTION_FAILED || wm.AgreementId != "ag1" {
+			t.Errorf("expected an execution failure of ag1, got %v", msg)
+		}
+	default:
+		t.Fatalf("the container failure was not reported")
+	}
+
+	// the agreement is being cancelled, so later events about it are not reported again
+	w.handleContainerEvent(event)
+	checkNoMessage(t, w)
+
+	// and neither are the events of containers stopped by the worker
+	w.watch.setStarted("ag1")
+	w.watch.setStopped("ag1")
+	w.handleContainerEvent(event)
+	checkNoMessage(t, w)
+}
+
+func Test_handleContainerEvent_die(t *testing.T) {
+
+	dir, db, fake, w := fakeWorkerSetup(t)
+	defer cleanFakeWorker(dir, db)
+	w.Manager.Messages = make(chan events.Message, 10)
+
+	fake.AddImage("myorg/app:1.0")
+	deployment := &containermessage.DeploymentDescription{
+		Services: map[string]*containermessage.Service{
+			"app": {Image: "myorg/app:1.0"},
+		},
+	}
+	if _, err := w.ResourcesCreate("ag1", "", nil, deployment, []byte("{}"), fakeEnvironment(), nil, nil, "https://myorg/app", "1.0"); err != nil {
+		t.Fatalf("unexpected error creating resources, %v", err)
+	}
+
+	wi, _ := persistence.NewWorkloadInfo("https://myorg/app", "myorg", "1.0", "")
+	if _, err := persistence.NewEstablishedAgreement(db, "ag1", "ag1", "agbot", "{}", policy.BasicProtocol, 1, nil, "", "", "", "", "", wi); err != nil {
+		t.Fatalf("unable to save the agreement, %v", err)
+	} else if _, err := persistence.AgreementStateExecutionStarted(db, "ag1", policy.BasicProtocol); err != nil {
+		t.Fatalf("unable to update the agreement, %v", err)
+	}
+
+	listener := make(chan *docker.APIEvents, 10)
+	fake.AddEventListener(listener)
+	fake.SendContainerEvent("ag1-app", EVENT_CONTAINER_DIE)
+	event := <-listener
+
+	// docker does not restart a container that has been removed
+	if err := fake.RemoveContainer(docker.RemoveContainerOptions{ID: event.Actor.ID}); err != nil {
+		t.Fatalf("unable to remove the container, %v", err)
+	}
+	w.handleContainerEvent(event)
+
+	select {
+	case msg := <-w.Messages():
+		if wm, ok := msg.(*events.WorkloadMessage); !ok || wm.Event().Id != events.EXECUTION_FAILED || wm.AgreementId != "ag1" {
+			t.Errorf("expected an execution failure of ag1, got %v", msg)
+		}
+	default:
+		t.Fatalf("the container failure was not reported")
+	}
+}
+
+func Test_handleContainerEvent_shared(t *testing.T) {
+
+	dir, db, _, w := fakeWorkerSetup(t)
+	defer cleanFakeWorker(dir, db)
+	w.Manager.Messages = make(chan events.Message, 10)
+
+	event := &docker.APIEvents{
+		Type:   "container",
+		Action: EVENT_CONTAINER_OOM,
+		Actor: docker.APIActor{ID: "1", Attributes: map[string]string{
+			LABEL_PREFIX + ".service_name":           "helper",
+			LABEL_PREFIX + ".service_pattern.shared": "singleton",
+		}},
+	}
+	w.handleContainerEvent(event)
+	checkNoMessage(t, w)
+}
+
+func Test_eventWatch_needsReconcile(t *testing.T) {
+
+	watch := newEventWatch()
+
+	// without the event stream, the containers are always listed
+	if !watch.needsReconcile("ag1", 300) || !watch.needsReconcile("ag1", 300) {
+		t.Errorf("expected the containers to be listed while the event stream is down")
+	}
+
+	watch.setConnected(true)
+	if !watch.needsReconcile("ag1", 300) {
+		t.Errorf("expected the containers to be listed once after the event stream is connected")
+	} else if watch.needsReconcile("ag1", 300) {
+		t.Errorf("expected the containers not to be listed again within the reconcile interval")
+	} else if !watch.needsReconcile("ag2", 300) {
+		t.Errorf("expected the containers of another agreement to be listed")
+	}
+
+	watch.setConnected(false)
+	if !watch.needsReconcile("ag1", 300) {
+		t.Errorf("expected the containers to be listed after the event stream ends")
+	}
+}
+
+func Test_watchEvents(t *testing.T) {
+
+	dir, db, fake, w := fakeWorkerSetup(t)
+	defer cleanFakeWorker(dir, db)
+
+	fake.AddImage("myorg/app:1.0")
+	con, err := fake.CreateContainer(docker.CreateContainerOptions{
+		Name:   "ag1-app",
+		Config: &docker.Config{Image: "myorg/app:1.0", Labels: map[string]string{LABEL_PREFIX + ".agreement_id": "ag1"}},
+	})
+	if err != nil {
+		t.Fatalf("unable to create container, %v", err)
+	} else if err := fake.StartContainer(con.ID, nil); err != nil {
+		t.Fatalf("unable to start container, %v", err)
+	}
+
+	go w.watchEvents()
+	defer w.watch.Stop()
+
+	if cmd, ok := nextCommand(t, w).(*EventStreamCommand); !ok || !cmd.Connected {
+		t.Fatalf("expected the event stream to be connected, got %v", cmd)
+	}
+
+	// events that are not failures are not passed to the worker
+	fake.SendContainerEvent("ag1-app", "exec_start")
+	if err := fake.StopContainer(con.ID, 10); err != nil {
+		t.Fatalf("unable to stop container, %v", err)
+	}
+	if cmd, ok := nextCommand(t, w).(*ContainerEventCommand); !ok || cmd.Event.Action != EVENT_CONTAINER_DIE || cmd.Event.Actor.Attributes[LABEL_PREFIX+".agreement_id"] != "ag1" {
+		t.Errorf("expected the die event of the container, got %v", cmd)
+	}
+
+	fake.CloseEventListeners()
+	if cmd, ok := nextCommand(t, w).(*EventStreamCommand); !ok || cmd.Connected {
+		t.Errorf("expected the event stream to be disconnected, got %v", cmd)
+	}
+}
+
+func nextCommand(t *testing.T, w *ContainerWorker) interface{} {
+	select {
+	case cmd := <-w.Commands:
+		return cmd
+	case <-time.After(5 * time.Second):
+		t.Fatalf("timed out waiting for a command")
+	}
+	return nil
+}
+
+func checkNoMessage(t *testing.T, w *ContainerWorker) {
+	select {
+	case msg := <-w.Messages():
+		t.Errorf("unexpected message %v", msg)
+	default:
+	}
+}
@@ -0,0 +1,454 @@
+package container
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	docker "github.com/fsouza/go-dockerclient"
+	"github.com/golang/glog"
+	"github.com/open-horizon/anax/containermessage"
+	"github.com/open-horizon/anax/eventlog"
+	"github.com/open-horizon/anax/persistence"
+	"github.com/open-horizon/edge-sync-service/common"
+	"github.com/open-horizon/edge-sync-service/core/base"
+	"github.com/open-horizon/edge-sync-service/core/communications"
+	"io"
+	"io/ioutil"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+const MMS_OBJECT_REFRESH = "MMSObjectRefresh"
+
+// The suffix of the file that keeps the model management objects delivered into the containers of an agreement or a
+// service instance.
+const MMS_OBJECTS_SUFFIX = ".objects"
+
+// How long a container that is restarted for a new object is given to stop before it is killed.
+const MMS_RESTART_TIMEOUT_S = 10
+
+// The model management objects that were sent to the node. It is the sync service embedded in the agent, except in
+// the tests.
+type mmsObjectStore interface {
+	GetObject(org string, objectType string, objectID string) (*common.MetaData, error)
+	ListObjects(org string, objectType string) ([]common.MetaData, error)
+	GetObjectData(org string, objectType string, objectID string) (io.Reader, error) // nil until the object is completely received
+	ObjectConsumed(org string, objectType string, objectID string) error
+	ObjectFailed(meta *common.MetaData, reason string) error
+}
+
+type essObjectStore struct{}
+
+func (s essObjectStore) GetObject(org string, objectType string, objectID string) (*common.MetaData, error) {
+	if !common.Running {
+		return nil, errors.New("the file sync service is not running")
+	} else if meta, err := base.GetObject(org, objectType, objectID); err != nil {
+		return nil, err
+	} else {
+		return meta, nil
+	}
+}
+
+// Returns the objects of the type that have been received, or deleted, since they were last consumed.
+func (s essObjectStore) ListObjects(org string, objectType string) ([]common.MetaData, error) {
+	if !common.Running {
+		return nil, errors.New("the file sync service is not running")
+	} else if metas, err := base.ListUpdatedObjects(org, objectType, true); err != nil {
+		return nil, err
+	} else {
+		return metas, nil
+	}
+}
+
+func (s essObjectStore) GetObjectData(org string, objectType string, objectID string) (io.Reader, error) {
+	if !common.Running {
+		return nil, errors.New("the file sync service is not running")
+	} else if r, err := base.GetObjectData(org, objectType, objectID); err != nil {
+		return nil, err
+	} else {
+		return r, nil
+	}
+}
+
+func (s essObjectStore) ObjectConsumed(org string, objectType string, objectID string) error {
+	if !common.Running {
+		return errors.New("the file sync service is not running")
+	} else if err := base.ObjectConsumed(org, objectType, objectID); err != nil {
+		return err
+	}
+	return nil
+}
+
+// Report to the model management system that the version of the object could not be delivered, so that the object
+// shows the error for the node instead of waiting to be consumed.
+func (s essObjectStore) ObjectFailed(meta *common.MetaData, reason string) error {
+	if !common.Running {
+		return errors.New("the file sync service is not running")
+	} else if err := communications.Comm.SendErrorMessage(&common.IOError{Message: reason}, meta, true); err != nil {
+		return err
+	}
+	return nil
+}
+
+// The objects delivered into one directory of the containers of a service.
+type mmsDelivery struct {
+	ServiceName string                     `json:"service_name"`
+	Dir         string                     `json:"dir"`
+	Object      containermessage.MMSObject `json:"object"`
+	Delivered   map[string]int64           `json:"delivered"`        // The id of each delivered object and the instance id of its version.
+	Failed      map[string]int64           `json:"failed,omitempty"` // The id of each object whose version could not be delivered, and the instance id of the version.
+}
+
+// The model management objects of the containers of an agreement or a service instance. They are kept next to the
+// object files, outside of the directories that are bound into the containers, so that new versions of the objects
+// can be delivered while the containers run.
+type mmsDeliveries struct {
+	Org        string        `json:"org"`
+	ServiceURL string        `json:"service_url"`
+	Version    string        `json:"version"`
+	Deliveries []mmsDelivery `json:"deliveries"`
+}
+
+// Returns the host directory that holds the objects of the containers of an agreement or of a service instance.
+func (b *ContainerWorker) mmsObjectsDir(key string) string {
+	return path.Join(b.Config.GetMMSObjectFilesPath(), key)
+}
+
+// Returns the host directory that is bound into a mount path of the containers of a service.
+func (b *ContainerWorker) mmsObjectMountDir(key string, serviceName string, mountPath string) string {
+	return path.Join(b.mmsObjectsDir(key), serviceName, url.PathEscape(mountPath))
+}
+
+// Returns the file that keeps the delivered objects of the containers of an agreement or a service instance.
+func (b *ContainerWorker) mmsObjectsFile(key string) string {
+	return path.Join(b.Config.GetMMSObjectFilesPath(), key+MMS_OBJECTS_SUFFIX)
+}
+
+// Returns the read-only binds of the directories that hold the objects of the service.
+func (b *ContainerWorker) mmsObjectBinds(key string, serviceName string, service *containermessage.Service) []string {
+	binds := make([]string, 0)
+	seen := make(map[string]bool)
+	for _, o := range service.MMSObjects {
+		if mp := o.GetMountPath(); !seen[mp] {
+			seen[mp] = true
+			binds = append(binds, fmt.Sprintf("%v:%v:ro", b.mmsObjectMountDir(key, serviceName, mp), mp))
+		}
+	}
+	return binds
+}
+
+// Create the directories that are bound into the containers and deliver the objects that the node already has. The
+// objects that are received later are delivered by the refresh. The objects belong to the org of the node.
+func (b *ContainerWorker) createMMSObjectFiles(key string, org string, serviceURL string, version string, deployment *containermessage.DeploymentDescription) error {
+
+	md := mmsDeliveries{Org: org, ServiceURL: serviceURL, Version: version, Deliveries: make([]mmsDelivery, 0)}
+	for serviceName, service := range deployment.Services {
+		for _, o := range service.MMSObjects {
+			md.Deliveries = append(md.Deliveries, mmsDelivery{
+				ServiceName: serviceName,
+				Dir:         b.mmsObjectMountDir(key, serviceName, o.GetMountPath()),
+				Object:      o,
+				Delivered:   make(map[string]int64),
+			})
+		}
+	}
+	if len(md.Deliveries) == 0 {
+		return nil
+	}
+
+	b.mmsLock.Lock()
+	defer b.mmsLock.Unlock()
+
+	// a new directory each time so that objects delivered to earlier containers are removed
+	if err := os.RemoveAll(b.mmsObjectsDir(key)); err != nil {
+		return errors.New(fmt.Sprintf("unable to remove old object files in %v, error: %v", b.mmsObjectsDir(key), err))
+	}
+	for _, d := range md.Deliveries {
+		if err := os.MkdirAll(d.Dir, 0755); err != nil {
+			return errors.New(fmt.Sprintf("unable to create directory %v for object files, error: %v", d.Dir, err))
+		}
+	}
+
+	for i := range md.Deliveries {
+		b.deliverMMSObjects(key, &md, &md.Deliveries[i])
+	}
+	return b.saveMMSDeliveries(key, &md)
+}
+
+// Remove the object files and the delivered objects of the containers of an agreement or a service instance.
+func (b *ContainerWorker) removeMMSObjectFiles(key string) error {
+	b.mmsLock.Lock()
+	defer b.mmsLock.Unlock()
+
+	if err := os.RemoveAll(b.mmsObjectsDir(key)); err != nil {
+		return errors.New(fmt.Sprintf("unable to remove object files in %v, error: %v", b.mmsObjectsDir(key), err))
+	} else if err := os.Remove(b.mmsObjectsFile(key)); err != nil && !os.IsNotExist(err) {
+		return errors.New(fmt.Sprintf("unable to remove the delivered objects of %v, error: %v", key, err))
+	}
+	return nil
+}
+
+func (b *ContainerWorker) saveMMSDeliveries(key string, md *mmsDeliveries) error {
+	if out, err := json.Marshal(md); err != nil {
+		return errors.New(fmt.Sprintf("unable to serialize the delivered objects of %v, error: %v", key, err))
+	} else if err := ioutil.WriteFile(b.mmsObjectsFile(key), out, 0600); err != nil {
+		return errors.New(fmt.Sprintf("unable to save the delivered objects of %v, error: %v", key, err))
+	}
+	return nil
+}
+
+// Deliver the new versions of the objects of the containers of all the agreements and service instances, and ask the
+// command handler to restart or signal the containers that want to know about them.
+func (b *ContainerWorker) refreshMMSObjects() int {
+
+	base := b.Config.GetMMSObjectFilesPath()
+	files, err := filepath.Glob(path.Join(base, "*"+MMS_OBJECTS_SUFFIX))
+	if err != nil {
+		glog.Errorf("Unable to list the delivered objects in %v, error: %v", base, err)
+		return 0
+	}
+
+	for _, file := range files {
+		key := strings.TrimSuffix(filepath.Base(file), MMS_OBJECTS_SUFFIX)
+		for _, cmd := range b.refreshMMSObjectFiles(key, file) {
+			b.Commands <- cmd
+		}
+	}
+	return 0
+}
+
+// Deliver the new versions of the objects of the containers of an agreement or a service instance. It returns the
+// commands that restart or signal the containers whose objects changed.
+func (b *ContainerWorker) refreshMMSObjectFiles(key string, file string) []*MMSObjectUpdatedCommand {
+	b.mmsLock.Lock()
+	defer b.mmsLock.Unlock()
+
+	var md mmsDeliveries
+	if out, err := ioutil.ReadFile(file); os.IsNotExist(err) {
+		// the containers have been removed since the directory was listed
+		return nil
+	} else if err != nil {
+		glog.Errorf("Unable to read the delivered objects of %v, error: %v", key, err)
+		return nil
+	} else if err := json.Unmarshal(out, &md); err != nil {
+		glog.Errorf("Unable to parse the delivered objects of %v, error: %v", key, err)
+		return nil
+	}
+
+	cmds := make([]*MMSObjectUpdatedCommand, 0)
+	notified := make(map[string]bool)
+	changed := false
+	for i := range md.Deliveries {
+		d := &md.Deliveries[i]
+		failed := len(d.Failed)
+		if !b.deliverMMSObjects(key, &md, d) {
+			changed = changed || len(d.Failed) != failed
+			continue
+		}
+		changed = true
+
+		// the objects of a service that share a mount path ask for the same action, so it is only taken once
+		if onUpdate := d.Object.GetOnUpdate(); onUpdate != containermessage.MMS_ON_UPDATE_NONE && !notified[d.ServiceName+d.Dir] {
+			notified[d.ServiceName+d.Dir] = true
+			cmds = append(cmds, NewMMSObjectUpdatedCommand(key, d.ServiceName, onUpdate, d.Object.GetSignal()))
+		}
+	}
+
+	if changed {
+		if err := b.saveMMSDeliveries(key, &md); err != nil {
+			glog.Errorf("%v", err)
+		}
+	}
+	return cmds
+}
+
+// Write the versions of the objects of a delivery that are not in its directory yet, and remove the objects that have
+// been deleted. An object that cannot be read keeps its current file, and the failure is reported to the model
+// management system once for each version. It returns true when a file changed.
+func (b *ContainerWorker) deliverMMSObjects(key string, md *mmsDeliveries, d *mmsDelivery) bool {
+
+	if b.mmsStore == nil {
+		return false
+	}
+
+	var metas []common.MetaData
+	o := d.Object
+	if o.ObjectID != "" {
+		if meta, err := b.mmsStore.GetObject(md.Org, o.ObjectType, o.ObjectID); err != nil {
+			b.logMMSObjectError(key, md, d, o.ObjectID, err)
+			return false
+		} else if meta != nil {
+			metas = []common.MetaData{*meta}
+		}
+	} else if list, err := b.mmsStore.ListObjects(md.Org, o.ObjectType); err != nil {
+		b.logMMSObjectError(key, md, d, "", err)
+		return false
+	} else {
+		metas = list
+	}
+
+	changed := false
+	for _, meta := range metas {
+		id := meta.ObjectID
+		file := path.Join(d.Dir, id)
+
+		if meta.Deleted {
+			if _, ok := d.Delivered[id]; ok {
+				if err := os.Remove(file); err != nil && !os.IsNotExist(err) {
+					b.logMMSObjectError(key, md, d, id, err)
+					continue
+				}
+				delete(d.Delivered, id)
+				changed = true
+				b.logMMSObjectEvent(persistence.SEVERITY_INFO,
+					persistence.NewMessageMeta(EL_CONT_MMS_OBJECT_REMOVED, o.ObjectType, id, o.GetMountPath(), d.ServiceName, key),
+					persistence.EC_MMS_OBJECT_REMOVED, key, md)
+			}
+			continue
+		} else if instance, ok := d.Delivered[id]; ok && instance == meta.InstanceID {
+			continue
+		} else if meta.NoData || !isFileName(id) {
+			continue
+		}
+
+		r, err := b.mmsStore.GetObjectData(md.Org, o.ObjectType, id)
+		if err != nil {
+			b.reportMMSObjectError(key, md, d, &meta, err)
+			continue
+		} else if r == nil {
+			// the object is still being received
+			continue
+		}
+
+		// write the new version next to the old one and rename it so that a container never reads a partial object
+		tmp := path.Join(d.Dir, "."+id+".new")
+		if err := writeMMSObjectFile(tmp, r); err != nil {
+			os.Remove(tmp)
+			b.reportMMSObjectError(key, md, d, &meta, err)
+			continue
+		} else if err := os.Rename(tmp, file); err != nil {
+			os.Remove(tmp)
+			b.reportMMSObjectError(key, md, d, &meta, err)
+			continue
+		}
+
+		d.Delivered[id] = meta.InstanceID
+		delete(d.Failed, id)
+		changed = true
+		b.logMMSObjectEvent(persistence.SEVERITY_INFO,
+			persistence.NewMessageMeta(EL_CONT_MMS_OBJECT_DELIVERED, o.ObjectType, id, meta.InstanceID, o.GetMountPath(), d.ServiceName, key),
+			persistence.EC_MMS_OBJECT_DELIVERED, key, md)
+
+		// tell the model management system that the object reached the service
+		if err := b.mmsStore.ObjectConsumed(md.Org, o.ObjectType, id); err != nil {
+			glog.Warningf("Unable to mark object %v/%v as consumed for %v, error: %v", o.ObjectType, id, key, err)
+		}
+	}
+	return changed
+}
+
+func writeMMSObjectFile(file string, r io.Reader) error {
+	f, err := os.OpenFile(file, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0444)
+	if err != nil {
+		return err
+	} else if _, err := io.Copy(f, r); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// Returns true when the object id can be used as the name of a file in the directory of the delivery.
+func isFileName(s string) bool {
+	return s != "" && s != "." && s != ".." && !strings.HasPrefix(s, ".") && filepath.Base(s) == s
+}
+
+func (b *ContainerWorker) logMMSObjectError(key string, md *mmsDeliveries, d *mmsDelivery, id string, err error) {
+	if id == "" {
+		id = "*"
+	}
+	b.logMMSObjectEvent(persistence.SEVERITY_ERROR,
+		persistence.NewMessageMeta(EL_CONT_MMS_OBJECT_ERROR, d.Object.ObjectType, id, d.Object.GetMountPath(), d.ServiceName, key, err.Error()),
+		persistence.EC_ERROR_MMS_OBJECT_DELIVERY, key, md)
+}
+
+// Log that a version of an object could not be delivered, and report it to the model management system unless it was
+// already reported for that version.
+func (b *ContainerWorker) reportMMSObjectError(key string, md *mmsDeliveries, d *mmsDelivery, meta *common.MetaData, err error) {
+	b.logMMSObjectError(key, md, d, meta.ObjectID, err)
+
+	if instance, ok := d.Failed[meta.ObjectID]; ok && instance == meta.InstanceID {
+		return
+	} else if rerr := b.mmsStore.ObjectFailed(meta, fmt.Sprintf("unable to deliver the object to service %v of %v: %v", d.ServiceName, key, err)); rerr != nil {
+		glog.Warningf("Unable to report the delivery error of object %v/%v for %v, error: %v", meta.ObjectType, meta.ObjectID, key, rerr)
+		return
+	}
+	if d.Failed == nil {
+		d.Failed = make(map[string]int64)
+	}
+	d.Failed[meta.ObjectID] = meta.InstanceID
+}
+
+func (b *ContainerWorker) logMMSObjectEvent(severity string, meta *persistence.MessageMeta, code string, key string, md *mmsDeliveries) {
+	if severity == persistence.SEVERITY_ERROR {
+		glog.Errorf(meta.MessageKey, meta.MessageArgs...)
+	} else {
+		glog.V(3).Infof(meta.MessageKey, meta.MessageArgs...)
+	}
+
+	if b.db != nil {
+		eventlog.LogServiceEvent2(b.db, severity, meta, code, key, md.ServiceURL, "", md.Version, "", []string{})
+	}
+}
+
+// Restart or signal the containers of a service whose objects changed. A restarted container is expected to stop, so
+// its die event does not fail the agreement or service instance. A signaled container is expected to keep running, so
+// the service has to handle the signal; a container that the signal stops is a failed container like any other.
+func (b *ContainerWorker) handleMMSObjectUpdated(cmd *MMSObjectUpdatedCommand) {
+
+	containers, err := b.client.ListContainers(docker.ListContainersOptions{
+		Filters: map[string][]string{
+			"label": []string{
+				fmt.Sprintf("%v.agreement_id=%v", LABEL_PREFIX, cmd.Key),
+				fmt.Sprintf("%v.service_name=%v", LABEL_PREFIX, cmd.ServiceName),
+			},
+		},
+	})
+	if err != nil {
+		glog.Errorf("Unable to list the containers of service %v for %v, error: %v", cmd.ServiceName, cmd.Key, err)
+		return
+	}
+
+	for _, c := range containers {
+		switch cmd.OnUpdate {
+		case containermessage.MMS_ON_UPDATE_RESTART:
+			glog.V(3).Infof("Restarting container %v of service %v for %v after a new object was delivered", c.ID, cmd.ServiceName, cmd.Key)
+			b.watch.setRestarting(c.ID)
+			if err := b.client.StopContainer(c.ID, MMS_RESTART_TIMEOUT_S); err != nil {
+				b.watch.setRestarted(c.ID)
+				glog.Errorf("Unable to stop container %v of service %v for %v, error: %v", c.ID, cmd.ServiceName, cmd.Key, err)
+			} else if err := b.client.StartContainer(c.ID, nil); err != nil {
+				glog.Errorf("Unable to start container %v of service %v for %v, error: %v", c.ID, cmd.ServiceName, cmd.Key, err)
+			}
+		case containermessage.MMS_ON_UPDATE_SIGNAL:
+			glog.V(3).Infof("Sending %v to container %v of service %v for %v after a new object was delivered", cmd.Signal, c.ID, cmd.ServiceName, cmd.Key)
+			if err := b.client.KillContainer(docker.KillContainerOptions{ID: c.ID, Signal: mmsSignal(cmd.Signal)}); err != nil {
+				glog.Errorf("Unable to send %v to container %v of service %v for %v, error: %v", cmd.Signal, c.ID, cmd.ServiceName, cmd.Key, err)
+			}
+		}
+	}
+}
+
+// Returns the docker signal of one of the signals that the deployment string can ask for.
+func mmsSignal(name string) docker.Signal {
+	switch name {
+	case "SIGUSR1":
+		return docker.SIGUSR1
+	case "SIGUSR2":
+		return docker.SIGUSR2
+	}
+	return docker.SIGHUP
+}
@@ -0,0 +1,273 @@
+// +build unit
+
+package container
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	docker "github.com/fsouza/go-dockerclient"
+	"github.com/open-horizon/anax/config"
+	"github.com/open-horizon/anax/containermessage"
+	"github.com/open-horizon/anax/persistence"
+	"github.com/open-horizon/edge-sync-service/common"
+	"io"
+	"io/ioutil"
+	"os"
+	"path"
+	"testing"
+)
+
+func Test_ResourcesCreate_mmsObjects(t *testing.T) {
+
+	dir, db, fake, w := fakeWorkerSetup(t)
+	defer cleanFakeWorker(dir, db)
+
+	store := newFakeObjectStore()
+	store.put("myorg", "model", "weights", 1, "v1")
+	store.put("myorg", "other", "ignored", 1, "other")
+	w.mmsStore = store
+
+	fake.AddImage("myorg/app:1.0")
+
+	deployment := &containermessage.DeploymentDescription{
+		Services: map[string]*containermessage.Service{
+			"app": {
+				Image:      "myorg/app:1.0",
+				MMSObjects: []containermessage.MMSObject{{ObjectType: "model", OnUpdate: containermessage.MMS_ON_UPDATE_SIGNAL}},
+			},
+		},
+	}
+
+	env := fakeEnvironment()
+	env[config.ENVVAR_PREFIX+"ORGANIZATION"] = "myorg"
+	if _, err := w.ResourcesCreate("ag1", "", nil, deployment, []byte("{}"), env, nil, nil, "https://myorg/app", "1.0"); err != nil {
+		t.Fatalf("unexpected error creating resources, %v", err)
+	}
+
+	// the objects that the node already has are delivered before the container starts
+	mountDir := w.mmsObjectMountDir("ag1", "app", "/mms/model")
+	objectFile := path.Join(mountDir, "weights")
+	if content, err := ioutil.ReadFile(objectFile); err != nil || string(content) != "v1" {
+		t.Errorf("the object file should have the first version, got %v, error: %v", string(content), err)
+	} else if _, err := os.Stat(path.Join(mountDir, "ignored")); !os.IsNotExist(err) {
+		t.Errorf("an object of another type was delivered, %v", err)
+	} else if len(store.consumed) != 1 {
+		t.Errorf("the delivered object should be consumed, got %v", store.consumed)
+	}
+
+	con, err := fake.InspectContainer("ag1-app")
+	if err != nil {
+		t.Fatalf("unable to inspect container, %v", err)
+	}
+	found := false
+	for _, bind := range con.HostConfig.Binds {
+		if bind == mountDir+":/mms/model:ro" {
+			found = true
+		}
+	}
+	if !found {
+		t.Errorf("the object files are not bound into the container, binds %v", con.HostConfig.Binds)
+	}
+
+	// nothing changed, so nothing is done to the container
+	if cmds := w.refreshMMSObjectFiles("ag1", w.mmsObjectsFile("ag1")); len(cmds) != 0 {
+		t.Errorf("expected no commands, got %v", cmds)
+	}
+
+	// a new version replaces the file and the container is signalled
+	store.put("myorg", "model", "weights", 2, "v2")
+	cmds := w.refreshMMSObjectFiles("ag1", w.mmsObjectsFile("ag1"))
+	if content, err := ioutil.ReadFile(objectFile); err != nil || string(content) != "v2" {
+		t.Errorf("the object file should have the second version, got %v, error: %v", string(content), err)
+	} else if len(cmds) != 1 || cmds[0].OnUpdate != containermessage.MMS_ON_UPDATE_SIGNAL || cmds[0].Signal != "SIGHUP" {
+		t.Fatalf("expected a command to signal the container, got %v", cmds)
+	}
+
+	w.handleMMSObjectUpdated(cmds[0])
+	if signals := fake.Signals(con.ID); len(signals) != 1 || signals[0] != docker.SIGHUP {
+		t.Errorf("expected the container to be sent SIGHUP, got %v", signals)
+	} else if c, err := fake.InspectContainer(con.ID); err != nil || !c.State.Running {
+		t.Errorf("the signalled container should be running, error: %v", err)
+	}
+
+	// a restarted container is running again and its die event is ignored
+	w.handleMMSObjectUpdated(NewMMSObjectUpdatedCommand("ag1", "app", containermessage.MMS_ON_UPDATE_RESTART, ""))
+	if c, err := fake.InspectContainer(con.ID); err != nil || !c.State.Running {
+		t.Errorf("the restarted container should be running, error: %v", err)
+	} else if !w.watch.isRestarting(con.ID) {
+		t.Errorf("the restarted container should be recorded")
+	}
+	w.handleContainerEvent(&docker.APIEvents{Type: "container", Action: EVENT_CONTAINER_DIE, Actor: docker.APIActor{ID: con.ID, Attributes: map[string]string{LABEL_PREFIX + ".agreement_id": "ag1"}}})
+	if w.watch.isRestarting(con.ID) || w.watch.isStopped("ag1") {
+		t.Errorf("the die event of the restarted container should be ignored")
+	}
+
+	// a deleted object is removed from the container
+	store.objects["myorg/model/weights"].meta.Deleted = true
+	w.refreshMMSObjectFiles("ag1", w.mmsObjectsFile("ag1"))
+	if _, err := os.Stat(objectFile); !os.IsNotExist(err) {
+		t.Errorf("the deleted object was not removed, %v", err)
+	}
+
+	if logs, err := persistence.FindAllEventLogs(db); err != nil {
+		t.Errorf("unable to read the event log, %v", err)
+	} else {
+		codes := []string{}
+		for _, l := range logs {
+			codes = append(codes, l.EventCode)
+		}
+		checkNames(t, "event codes", codes, persistence.EC_MMS_OBJECT_DELIVERED, persistence.EC_MMS_OBJECT_DELIVERED, persistence.EC_MMS_OBJECT_REMOVED)
+	}
+
+	if err := w.ResourcesRemove([]string{"ag1"}); err != nil {
+		t.Fatalf("unexpected error removing resources, %v", err)
+	} else if _, err := os.Stat(w.mmsObjectsDir("ag1")); !os.IsNotExist(err) {
+		t.Errorf("the object files were not removed, %v", err)
+	} else if _, err := os.Stat(w.mmsObjectsFile("ag1")); !os.IsNotExist(err) {
+		t.Errorf("the delivered objects were not removed, %v", err)
+	}
+}
+
+func Test_ResourcesCreate_mmsObjectsError(t *testing.T) {
+
+	dir, db, fake, w := fakeWorkerSetup(t)
+	defer cleanFakeWorker(dir, db)
+
+	store := newFakeObjectStore()
+	store.put("myorg", "model", "weights", 1, "v1")
+	store.dataErr = errors.New("disk error")
+	w.mmsStore = store
+
+	fake.AddImage("myorg/app:1.0")
+
+	deployment := &containermessage.DeploymentDescription{
+		Services: map[string]*containermessage.Service{
+			"app": {
+				Image:      "myorg/app:1.0",
+				MMSObjects: []containermessage.MMSObject{{ObjectType: "model", ObjectID: "weights"}},
+			},
+		},
+	}
+
+	env := fakeEnvironment()
+	env[config.ENVVAR_PREFIX+"ORGANIZATION"] = "myorg"
+	if _, err := w.ResourcesCreate("ag1", "", nil, deployment, []byte("{}"), env, nil, nil, "https://myorg/app", "1.0"); err != nil {
+		t.Fatalf("unexpected error creating resources, %v", err)
+	}
+
+	// the error is reported once for each version of the object
+	objectFile := path.Join(w.mmsObjectMountDir("ag1", "app", "/mms/model"), "weights")
+	if _, err := os.Stat(objectFile); !os.IsNotExist(err) {
+		t.Errorf("the object should not be delivered, %v", err)
+	} else if len(store.failed) != 1 || store.failed[0] != "myorg/model/weights/1" {
+		t.Errorf("the delivery error should be reported, got %v", store.failed)
+	} else if len(store.consumed) != 0 {
+		t.Errorf("the object should not be consumed, got %v", store.consumed)
+	}
+
+	w.refreshMMSObjectFiles("ag1", w.mmsObjectsFile("ag1"))
+	if len(store.failed) != 1 {
+		t.Errorf("the delivery error should only be reported once, got %v", store.failed)
+	}
+
+	store.put("myorg", "model", "weights", 2, "v2")
+	w.refreshMMSObjectFiles("ag1", w.mmsObjectsFile("ag1"))
+	if len(store.failed) != 2 || store.failed[1] != "myorg/model/weights/2" {
+		t.Errorf("the delivery error of the new version should be reported, got %v", store.failed)
+	}
+
+	// once the object can be read it is delivered and consumed
+	store.dataErr = nil
+	w.refreshMMSObjectFiles("ag1", w.mmsObjectsFile("ag1"))
+	if content, err := ioutil.ReadFile(objectFile); err != nil || string(content) != "v2" {
+		t.Errorf("the object file should have the second version, got %v, error: %v", string(content), err)
+	} else if len(store.consumed) != 1 {
+		t.Errorf("the delivered object should be consumed, got %v", store.consumed)
+	}
+}
+
+func Test_ResourcesCreate_mmsObjectsShared(t *testing.T) {
+
+	dir, db, fake, w := fakeWorkerSetup(t)
+	defer cleanFakeWorker(dir, db)
+
+	w.mmsStore = newFakeObjectStore()
+	fake.AddImage("myorg/app:1.0")
+
+	deployment := &containermessage.DeploymentDescription{
+		Services: map[string]*containermessage.Service{
+			"app": {
+				Image:      "myorg/app:1.0",
+				MMSObjects: []containermessage.MMSObject{{ObjectType: "model"}},
+			},
+		},
+		ServicePattern: containermessage.Pattern{Shared: map[string][]string{"singleton": []string{"app"}}},
+	}
+
+	if _, err := w.ResourcesCreate("ag1", "", nil, deployment, []byte("{}"), fakeEnvironment(), nil, nil, "https://myorg/app", "1.0"); err == nil {
+		t.Errorf("expected an error for objects of a shared service")
+	}
+	checkNames(t, "containers", fake.ContainerNames())
+}
+
+type fakeObject struct {
+	meta common.MetaData
+	data string
+}
+
+// An object store that keeps the objects in memory.
+type fakeObjectStore struct {
+	objects  map[string]*fakeObject
+	consumed []string
+	failed   []string
+	dataErr  error // returned when the data of an object is read
+}
+
+func newFakeObjectStore() *fakeObjectStore {
+	return &fakeObjectStore{objects: make(map[string]*fakeObject)}
+}
+
+func (s *fakeObjectStore) put(org string, objectType string, objectID string, instance int64, data string) {
+	s.objects[org+"/"+objectType+"/"+objectID] = &fakeObject{
+		meta: common.MetaData{DestOrgID: org, ObjectType: objectType, ObjectID: objectID, InstanceID: instance},
+		data: data,
+	}
+}
+
+func (s *fakeObjectStore) GetObject(org string, objectType string, objectID string) (*common.MetaData, error) {
+	if o, ok := s.objects[org+"/"+objectType+"/"+objectID]; ok {
+		meta := o.meta
+		return &meta, nil
+	}
+	return nil, nil
+}
+
+func (s *fakeObjectStore) ListObjects(org string, objectType string) ([]common.MetaData, error) {
+	metas := make([]common.MetaData, 0)
+	for _, o := range s.objects {
+		if o.meta.DestOrgID == org && o.meta.ObjectType == objectType {
+			metas = append(metas, o.meta)
+		}
+	}
+	return metas, nil
+}
+
+func (s *fakeObjectStore) GetObjectData(org string, objectType string, objectID string) (io.Reader, error) {
+	if s.dataErr != nil {
+		return nil, s.dataErr
+	} else if o, ok := s.objects[org+"/"+objectType+"/"+objectID]; ok {
+		return bytes.NewBufferString(o.data), nil
+	}
+	return nil, nil
+}
+
+func (s *fakeObjectStore) ObjectConsumed(org string, objectType string, objectID string) error {
+	s.consumed = append(s.consumed, org+"/"+objectType+"/"+objectID)
+	return nil
+}
+
+func (s *fakeObjectStore) ObjectFailed(meta *common.MetaData, reason string) error {
+	s.failed = append(s.failed, fmt.Sprintf("%v/%v/%v/%v", meta.DestOrgID, meta.ObjectType, meta.ObjectID, meta.InstanceID))
+	return nil
+}
@@ -0,0 +1,230 @@
+package container
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/coreos/go-iptables/iptables"
+	docker "github.com/fsouza/go-dockerclient"
+	"github.com/golang/glog"
+	"github.com/open-horizon/anax/containermessage"
+	"github.com/open-horizon/anax/containerruntime"
+	"strings"
+)
+
+// The label on the containers of services that restrict inbound connections. It holds the JSON encoded inbound permits
+// so that the rules can be completed when other containers join the networks of the service later.
+const LABEL_INBOUND_PERMIT_ONLY = LABEL_PREFIX + ".inbound_permit_only"
+
+// A container on one of the networks of a container that is being wired up.
+type networkPeer struct {
+	serviceName string
+	agreementId string
+	ip          string
+	permits     []containermessage.InboundPermit
+}
+
+func (p *networkPeer) String() string {
+	return fmt.Sprintf("service: %v, agreement: %v, ip: %v, permits: %v", p.serviceName, p.agreementId, p.ip, p.permits)
+}
+
+func newNetworkPeer(labels map[string]string, ip string) (*networkPeer, error) {
+	peer := &networkPeer{
+		serviceName: labels[LABEL_PREFIX+".service_name"],
+		agreementId: labels[LABEL_PREFIX+".agreement_id"],
+		ip:          strings.Split(ip, "/")[0],
+	}
+	if permits, ok := labels[LABEL_INBOUND_PERMIT_ONLY]; ok {
+		if err := json.Unmarshal([]byte(permits), &peer.permits); err != nil {
+			return nil, fmt.Errorf("unable to demarshal inbound permits %v of service %v, error %v", permits, peer.serviceName, err)
+		}
+	}
+	return peer, nil
+}
+
+// Returns the label value that records the inbound permits of a service, or an empty string if the service does not
+// restrict inbound connections.
+func inboundPermitLabel(service *containermessage.Service) (string, error) {
+	if service.NetworkIsolation == nil || len(service.NetworkIsolation.InboundPermitOnly) == 0 {
+		return "", nil
+	}
+	permits, err := json.Marshal(service.NetworkIsolation.InboundPermitOnly)
+	if err != nil {
+		return "", err
+	}
+	return string(permits), nil
+}
+
+// Apply the inbound network policies between a container and the containers it shares a network with. If the
+// container's service declares inbound permits, all other inbound connections to it are rejected. Connections between
+// the container and its peers are accepted when the receiving side permits the sending service.
+func applyInboundPolicies(ipt *iptables.IPTables, client containerruntime.ContainerRuntime, agreementId string, containerId string, labels map[string]string, networks map[string]docker.ContainerNetwork) error {
+
+	for name, network := range networks {
+		if network.IPAddress == "" {
+			continue
+		}
+
+		self, err := newNetworkPeer(labels, network.IPAddress)
+		if err != nil {
+			return err
+		}
+
+		if len(self.permits) != 0 {
+			glog.V(3).Infof("Restricting inbound connections on network %v to service %v", name, self)
+			if err := restrictInbound(ipt, self, inboundComment(agreementId, "")); err != nil {
+				return err
+			}
+		}
+
+		net, err := client.NetworkInfo(network.NetworkID)
+		if err != nil {
+			return fmt.Errorf("unable to inspect network %v, error %v", name, err)
+		}
+
+		for id, endpoint := range net.Containers {
+			if id == containerId || endpoint.IPv4Address == "" {
+				continue
+			}
+
+			con, err := client.InspectContainer(id)
+			if err != nil {
+				return fmt.Errorf("unable to inspect container %v on network %v, error %v", id, name, err)
+			} else if _, ok := con.Config.Labels[LABEL_PREFIX+".service_name"]; !ok {
+				continue
+			}
+
+			peer, err := newNetworkPeer(con.Config.Labels, endpoint.IPv4Address)
+			if err != nil {
+				return err
+			}
+
+			comment := inboundComment(agreementId, peer.agreementId)
+			if err := permitInbound(ipt, peer, self, comment); err != nil {
+				return err
+			} else if err := permitInbound(ipt, self, peer, comment); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// Reject the inbound connections to the service except for replies, published ports and the permits that do not
+// name a service. The reject rule is placed at the end of the chain so that the accept rules for the service's peers,
+// which are added as the peers are started, always come before it.
+func restrictInbound(ipt *iptables.IPTables, dst *networkPeer, comment string) error {
+
+	rules, err := ipt.List("filter", IPT_COLONUS_ISOLATED_CHAIN)
+	if err != nil {
+		return fmt.Errorf("unable to list rules in %v, error %v", IPT_COLONUS_ISOLATED_CHAIN, err)
+	}
+
+	// The first entry in the list is the chain itself, so the index of the return rule is also its rule number.
+	returnAt := len(rules)
+	for ix, rule := range rules {
+		if rule == fmt.Sprintf("-A %v -j RETURN", IPT_COLONUS_ISOLATED_CHAIN) {
+			returnAt = ix
+		}
+	}
+
+	if err := insertRule(ipt, returnAt, "-d", dst.ip, "-j", "REJECT", "-m", "comment", "--comment", comment); err != nil {
+		return err
+	} else if err := insertRule(ipt, 1, "-d", dst.ip, "-m", "conntrack", "--ctstate", "ESTABLISHED,RELATED,DNAT", "-j", "ACCEPT", "-m", "comment", "--comment", comment); err != nil {
+		return err
+	}
+
+	for _, permit := range dst.permits {
+		if len(permit.From) == 0 {
+			if err := acceptInbound(ipt, "", dst.ip, permit.Ports, comment); err != nil {
+				return err
+			}
+		}
+		for _, from := range permit.From {
+			if containermessage.IsAddressSource(from) {
+				if err := acceptInbound(ipt, from, dst.ip, permit.Ports, comment); err != nil {
+					return err
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// Accept connections from the src container to the dst container on the ports that dst permits for the src service.
+func permitInbound(ipt *iptables.IPTables, src *networkPeer, dst *networkPeer, comment string) error {
+	for _, permit := range dst.permits {
+		for _, from := range permit.From {
+			if from == src.serviceName {
+				glog.V(3).Infof("Permitting inbound connections from %v to %v", src, dst)
+				if err := acceptInbound(ipt, src.ip, dst.ip, permit.Ports, comment); err != nil {
+					return err
+				}
+			}
+		}
+	}
+	return nil
+}
+
+func acceptInbound(ipt *iptables.IPTables, src string, dst string, ports []string, comment string) error {
+	rule := []string{"-d", dst}
+	if src != "" {
+		rule = append(rule, "-s", src)
+	}
+
+	if len(ports) == 0 {
+		return insertRule(ipt, 1, append(rule, "-j", "ACCEPT", "-m", "comment", "--comment", comment)...)
+	}
+
+	for _, p := range ports {
+		port, protocol, err := containermessage.ParseInboundPort(p)
+		if err != nil {
+			return err
+		}
+		portRule := append(append([]string{}, rule...), "-p", protocol, "--dport", port, "-j", "ACCEPT", "-m", "comment", "--comment", comment)
+		if err := insertRule(ipt, 1, portRule...); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Insert the rule at the position in the isolation chain, unless the chain already has it.
+func insertRule(ipt *iptables.IPTables, pos int, rulespec ...string) error {
+	if exists, err := ipt.Exists("filter", IPT_COLONUS_ISOLATED_CHAIN, rulespec...); err != nil {
+		return fmt.Errorf("unable to interrogate iptables on host, error %v", err)
+	} else if exists {
+		return nil
+	} else if err := ipt.Insert("filter", IPT_COLONUS_ISOLATED_CHAIN, pos, rulespec...); err != nil {
+		return fmt.Errorf("unable to create inbound rule %v, error %v", rulespec, err)
+	}
+	return nil
+}
+
+// Rules are removed with the agreements named in their comment, so a rule between the containers of two agreements
+// names both of them and is removed when either agreement ends.
+func inboundComment(agreementId string, peerAgreementId string) string {
+	comment := fmt.Sprintf("agreement_id=%v", agreementId)
+	if peerAgreementId != "" && peerAgreementId != agreementId {
+		comment += fmt.Sprintf(",agreement_id=%v", peerAgreementId)
+	}
+	return comment
+}
+
+// Returns true if the comment of the iptables rule names one of the agreements. The agreement id has to end where the
+// comment or one of its agreement ids ends, so that an agreement id that is a prefix of another does not match it.
+func ruleMatchesAgreements(rule string, agreements []string) bool {
+	for _, agreementId := range agreements {
+		tag := fmt.Sprintf("agreement_id=%v", agreementId)
+		for ix := strings.Index(rule, tag); ix != -1; {
+			end := ix + len(tag)
+			if end == len(rule) || strings.ContainsRune(`," `, rune(rule[end])) {
+				return true
+			} else if next := strings.Index(rule[end:], tag); next != -1 {
+				ix = end + next
+			} else {
+				break
+			}
+		}
+	}
+	return false
+}
@@ -0,0 +1,96 @@
+package container
+
+import (
+	"fmt"
+	docker "github.com/fsouza/go-dockerclient"
+	"github.com/golang/glog"
+	"github.com/open-horizon/anax/cutil"
+	"github.com/open-horizon/anax/eventlog"
+	"github.com/open-horizon/anax/events"
+	"github.com/open-horizon/anax/persistence"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// The label on the containers of services with a readiness probe. It holds the number of seconds that the container
+// has to become ready.
+const LABEL_READINESS_TIMEOUT = LABEL_PREFIX + ".readiness_timeout_s"
+
+// The error returned when a dependency container has not passed its readiness probe yet. The dependent services are
+// started once it does, unless it timed out.
+type NotReadyError struct {
+	InstanceKey string // the key of the service instance that is not ready
+	Service     events.MicroserviceSpec
+	Container   string
+	TimeoutS    int
+	TimedOut    bool
+	Output      string // the output of the last run of the readiness probe
+}
+
+func (e *NotReadyError) Error() string {
+	if e.TimedOut {
+		return fmt.Sprintf("service container %v of %v did not become ready within %v seconds, probe output: %v", e.Container, e.InstanceKey, e.TimeoutS, e.Output)
+	}
+	return fmt.Sprintf("service container %v of %v is not ready yet", e.Container, e.InstanceKey)
+}
+
+// Returns an error if a running container has a readiness probe that it has not passed yet.
+func (b *ContainerWorker) checkReadiness(container *docker.APIContainers, instanceKey string, service events.MicroserviceSpec) error {
+
+	label, ok := container.Labels[LABEL_READINESS_TIMEOUT]
+	if !ok {
+		return nil
+	}
+	timeoutS, err := strconv.Atoi(label)
+	if err != nil {
+		return fmt.Errorf("container %v has an invalid %v label %v", container.ID, LABEL_READINESS_TIMEOUT, label)
+	}
+
+	con, err := b.client.InspectContainer(container.ID)
+	if err != nil {
+		return fmt.Errorf("unable to inspect container %v, error %v", container.ID, err)
+	}
+
+	health := con.State.Health
+	if health.Status == "healthy" {
+		return nil
+	}
+
+	notReady := &NotReadyError{
+		InstanceKey: instanceKey,
+		Service:     service,
+		Container:   strings.TrimPrefix(con.Name, "/"),
+		TimeoutS:    timeoutS,
+	}
+	if len(health.Log) != 0 {
+		notReady.Output = strings.TrimSpace(health.Log[len(health.Log)-1].Output)
+	}
+
+	// docker only marks the container unhealthy after the failures that follow the timeout, so the start time is
+	// checked too.
+	if health.Status == "unhealthy" || (!con.State.StartedAt.IsZero() && time.Since(con.State.StartedAt) > time.Duration(timeoutS)*time.Second) {
+		notReady.TimedOut = true
+	}
+	return notReady
+}
+
+// Report a dependency that did not become ready in time as a failure of its service instance, so that it is retried
+// like a dependency that failed to start. The error is only reported once for each attempt to start the service.
+func (b *ContainerWorker) reportNotReady(notReady *NotReadyError, agreementIds []string) {
+
+	if b.watch.isStopped(notReady.InstanceKey) {
+		return
+	}
+	b.watch.setStopped(notReady.InstanceKey)
+
+	glog.Errorf("Dependent service %v is not ready: %v", notReady.InstanceKey, notReady)
+	eventlog.LogServiceEvent2(b.db, persistence.SEVERITY_ERROR,
+		persistence.NewMessageMeta(EL_CONT_DEPENDENT_SERVICE_NOT_READY, cutil.FormOrgSpecUrl(notReady.Service.SpecRef, notReady.Service.Org), notReady.TimeoutS, notReady.Output),
+		persistence.EC_DEPENDENT_SERVICE_NOT_READY,
+		notReady.InstanceKey, notReady.Service.SpecRef, notReady.Service.Org, notReady.Service.Version, "", agreementIds)
+
+	cc := events.NewContainerConfig("", "", "", "", "", "", nil)
+	ll := events.NewContainerLaunchContext(cc, nil, events.BlockchainConfig{}, notReady.InstanceKey, []string{}, []events.MicroserviceSpec{}, persistence.NewServiceInstancePathElement("", "", ""), false)
+	b.Messages() <- events.NewContainerMessage(events.EXECUTION_FAILED, *ll, "", "")
+}
@@ -0,0 +1,95 @@
+// +build unit
+
+package container
+
+import (
+	docker "github.com/fsouza/go-dockerclient"
+	"github.com/open-horizon/anax/containermessage"
+	"github.com/open-horizon/anax/events"
+	"github.com/open-horizon/anax/persistence"
+	"testing"
+)
+
+func Test_checkReadiness(t *testing.T) {
+
+	dir, db, fake, w := fakeWorkerSetup(t)
+	defer cleanFakeWorker(dir, db)
+
+	fake.AddImage("myorg/db:1.0")
+	deployment := &containermessage.DeploymentDescription{
+		Services: map[string]*containermessage.Service{
+			"db": {Image: "myorg/db:1.0", Readiness: &containermessage.ReadinessProbe{Command: []string{"pg_isready"}, TimeoutS: 60}},
+		},
+	}
+	if _, err := w.ResourcesCreate("ag1", "", nil, deployment, []byte("{}"), fakeEnvironment(), nil, nil, "https://myorg/db", "1.0"); err != nil {
+		t.Fatalf("unexpected error creating resources, %v", err)
+	}
+
+	con, _ := fake.InspectContainer("ag1-db")
+	if con.Config.Healthcheck == nil || con.Config.Healthcheck.Test[1] != "pg_isready" {
+		t.Errorf("expected the readiness probe as the health check, got %v", con.Config.Healthcheck)
+	} else if con.Config.Labels[LABEL_READINESS_TIMEOUT] != "60" {
+		t.Errorf("expected the readiness timeout label, got %v", con.Config.Labels)
+	}
+
+	containers, _ := fake.ListContainers(docker.ListContainersOptions{})
+	if len(containers) != 1 {
+		t.Fatalf("expected one container, got %v", containers)
+	}
+	spec := events.MicroserviceSpec{SpecRef: "https://myorg/db", Org: "myorg", Version: "1.0"}
+
+	// the probe has not passed yet
+	if err := w.checkReadiness(&containers[0], "ag1", spec); err == nil {
+		t.Errorf("expected the container not to be ready")
+	} else if notReady, ok := err.(*NotReadyError); !ok || notReady.TimedOut {
+		t.Errorf("expected a not ready error without a timeout, got %v", err)
+	}
+
+	// docker gave up on the container
+	fake.SetContainerHealth("ag1-db", "unhealthy", "no response")
+	if err := w.checkReadiness(&containers[0], "ag1", spec); err == nil {
+		t.Errorf("expected the container not to be ready")
+	} else if notReady, ok := err.(*NotReadyError); !ok || !notReady.TimedOut || notReady.Output != "no response" {
+		t.Errorf("expected a timed out error with the probe output, got %v", err)
+	}
+
+	fake.SetContainerHealth("ag1-db", "healthy", "accepting connections")
+	if err := w.checkReadiness(&containers[0], "ag1", spec); err != nil {
+		t.Errorf("expected the container to be ready, got %v", err)
+	}
+}
+
+func Test_reportNotReady(t *testing.T) {
+
+	dir, db, _, w := fakeWorkerSetup(t)
+	defer cleanFakeWorker(dir, db)
+	w.Manager.Messages = make(chan events.Message, 10)
+
+	notReady := &NotReadyError{
+		InstanceKey: "myorg_db_1.0_1",
+		Service:     events.MicroserviceSpec{SpecRef: "https://myorg/db", Org: "myorg", Version: "1.0"},
+		Container:   "myorg_db_1.0_1-db",
+		TimeoutS:    60,
+		TimedOut:    true,
+	}
+	w.reportNotReady(notReady, []string{"ag1"})
+
+	select {
+	case msg := <-w.Messages():
+		if cm, ok := msg.(*events.ContainerMessage); !ok || cm.Event().Id != events.EXECUTION_FAILED || cm.LaunchContext.Name != notReady.InstanceKey {
+			t.Errorf("expected an execution failure of the service instance, got %v", msg)
+		}
+	default:
+		t.Fatalf("the timeout was not reported")
+	}
+
+	if logs, err := persistence.FindEventLogs(db, []persistence.EventLogFilter{persistence.SeverityELFilter(persistence.SEVERITY_ERROR)}); err != nil {
+		t.Errorf("unable to read the event log, %v", err)
+	} else if len(logs) != 1 || logs[0].EventCode != persistence.EC_DEPENDENT_SERVICE_NOT_READY {
+		t.Errorf("expected a surfaced service error, got %v", logs)
+	}
+
+	// the timeout is reported once until the service is started again
+	w.reportNotReady(notReady, []string{"ag1"})
+	checkNoMessage(t, w)
+}
@@ -0,0 +1,362 @@
+// +build unit
+
+package container
+
+import (
+	"github.com/boltdb/bolt"
+	"github.com/open-horizon/anax/config"
+	"github.com/open-horizon/anax/containermessage"
+	"github.com/open-horizon/anax/containerruntime"
+	"github.com/open-horizon/anax/persistence"
+	"github.com/open-horizon/anax/policy"
+	"github.com/open-horizon/anax/resource"
+	"github.com/open-horizon/anax/secretprovider"
+	"github.com/open-horizon/anax/worker"
+	"io/ioutil"
+	"os"
+	"path"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+)
+
+func Test_ResourcesCreateRemove(t *testing.T) {
+
+	dir, db, fake, w := fakeWorkerSetup(t)
+	defer cleanFakeWorker(dir, db)
+
+	fake.AddImage("myorg/app:1.0")
+	fake.AddImage("myorg/helper:1.0")
+
+	deployment := &containermessage.DeploymentDescription{
+		Services: map[string]*containermessage.Service{
+			"app":    {Image: "myorg/app:1.0", Binds: []string{"appdata:/data"}},
+			"helper": {Image: "myorg/helper:1.0"},
+		},
+	}
+
+	dc, err := w.ResourcesCreate("ag1", "", nil, deployment, []byte("{}"), fakeEnvironment(), nil, nil, "https://myorg/app", "1.0")
+	if err != nil {
+		t.Fatalf("unexpected error creating resources, %v", err)
+	} else if _, ok := dc.(*persistence.NativeDeploymentConfig); !ok {
+		t.Errorf("expected a native deployment config, got %T", dc)
+	}
+
+	checkNames(t, "containers", fake.ContainerNames(), "ag1-app", "ag1-helper")
+	checkNames(t, "networks", fake.NetworkNames(), "ag1")
+	checkNames(t, "volumes", fake.VolumeNames(), "appdata")
+
+	// every container of the agreement is running and on the agreement network
+	for _, name := range []string{"ag1-app", "ag1-helper"} {
+		if con, err := fake.InspectContainer(name); err != nil {
+			t.Errorf("unable to inspect container %v, %v", name, err)
+		} else if !con.State.Running {
+			t.Errorf("container %v is not running", name)
+		} else if _, ok := con.NetworkSettings.Networks["ag1"]; !ok {
+			t.Errorf("container %v is not on the agreement network, networks %v", name, con.NetworkSettings.Networks)
+		} else if con.Config.Labels[LABEL_PREFIX+".agreement_id"] != "ag1" {
+			t.Errorf("container %v is not labeled with the agreement, labels %v", name, con.Config.Labels)
+		}
+	}
+
+	if _, err := os.Stat(path.Join(dir, "storage", "ag1", "Configure")); err != nil {
+		t.Errorf("the workload configuration was not written, %v", err)
+	}
+
+	if err := w.ResourcesRemove([]string{"ag1"}); err != nil {
+		t.Fatalf("unexpected error removing resources, %v", err)
+	}
+
+	checkNames(t, "containers", fake.ContainerNames())
+	checkNames(t, "networks", fake.NetworkNames())
+
+	// named volumes remain until the node is unregistered
+	checkNames(t, "volumes", fake.VolumeNames(), "appdata")
+
+	if _, err := os.Stat(path.Join(dir, "storage", "ag1")); !os.IsNotExist(err) {
+		t.Errorf("the workload storage was not removed, %v", err)
+	}
+}
+
+func Test_ResourcesCreateRemove_shared(t *testing.T) {
+
+	dir, db, fake, w := fakeWorkerSetup(t)
+	defer cleanFakeWorker(dir, db)
+
+	fake.AddImage("myorg/app:1.0")
+	fake.AddImage("myorg/db:1.0")
+
+	newDeployment := func() *containermessage.DeploymentDescription {
+		return &containermessage.DeploymentDescription{
+			Services: map[string]*containermessage.Service{
+				"app": {Image: "myorg/app:1.0"},
+				"db":  {Image: "myorg/db:1.0"},
+			},
+			ServicePattern: containermessage.Pattern{Shared: map[string][]string{"singleton": {"db"}}},
+		}
+	}
+
+	for _, ag := range []string{"ag1", "ag2"} {
+		if _, err := w.ResourcesCreate(ag, "", nil, newDeployment(), []byte("{}"), fakeEnvironment(), nil, nil, "https://myorg/app", "1.0"); err != nil {
+			t.Fatalf("unexpected error creating resources for %v, %v", ag, err)
+		}
+	}
+
+	// both agreements use the same shared container
+	checkNames(t, "containers", fake.ContainerNames(), "ag1-app", "ag2-app", "singleton-db")
+	checkNames(t, "networks", fake.NetworkNames(), "ag1", "ag2", "singleton-db")
+
+	if con, err := fake.InspectContainer("ag2-app"); err != nil {
+		t.Errorf("unable to inspect container, %v", err)
+	} else if _, ok := con.NetworkSettings.Networks["singleton-db"]; !ok {
+		t.Errorf("container is not on the shared network, networks %v", con.NetworkSettings.Networks)
+	}
+
+	// the shared container stays while another agreement uses it
+	if err := w.ResourcesRemove([]string{"ag1"}); err != nil {
+		t.Fatalf("unexpected error removing resources, %v", err)
+	}
+	checkNames(t, "containers", fake.ContainerNames(), "ag2-app", "singleton-db")
+	checkNames(t, "networks", fake.NetworkNames(), "ag2", "singleton-db")
+
+	if err := w.ResourcesRemove([]string{"ag2"}); err != nil {
+		t.Fatalf("unexpected error removing resources, %v", err)
+	}
+	checkNames(t, "containers", fake.ContainerNames())
+	checkNames(t, "networks", fake.NetworkNames())
+}
+
+// Verify that secrets are given to the containers as files and not as environment variables.
+func Test_ResourcesCreateRemove_secrets(t *testing.T) {
+
+	dir, db, fake, w := fakeWorkerSetup(t)
+	defer cleanFakeWorker(dir, db)
+
+	fake.AddImage("myorg/app:1.0")
+
+	deployment := &containermessage.DeploymentDescription{
+		Services: map[string]*containermessage.Service{
+			"app": {Image: "myorg/app:1.0"},
+		},
+	}
+
+	secrets := map[string]string{"PASSWORD": "s3cret"}
+	if _, err := w.ResourcesCreate("ag1", "", nil, deployment, []byte("{}"), fakeEnvironment(), secrets, nil, "https://myorg/app", "1.0"); err != nil {
+		t.Fatalf("unexpected error creating resources, %v", err)
+	}
+
+	secretsDir := path.Join(dir, "secrets", "ag1")
+	if content, err := ioutil.ReadFile(path.Join(secretsDir, "PASSWORD")); err != nil {
+		t.Errorf("the secret file was not written, %v", err)
+	} else if string(content) != "s3cret" {
+		t.Errorf("the secret file has the wrong content %v", string(content))
+	}
+
+	if con, err := fake.InspectContainer("ag1-app"); err != nil {
+		t.Errorf("unable to inspect container, %v", err)
+	} else {
+		for _, env := range con.Config.Env {
+			if strings.Contains(env, "s3cret") {
+				t.Errorf("the secret is in the container environment %v", con.Config.Env)
+			}
+		}
+		found := false
+		for _, bind := range con.HostConfig.Binds {
+			if bind == secretsDir+":"+config.HZN_SECRETS_MOUNT+":ro" {
+				found = true
+			}
+		}
+		if !found {
+			t.Errorf("the secret files are not bound into the container, binds %v", con.HostConfig.Binds)
+		}
+	}
+
+	// the secret files of an active agreement are written again after a reboot
+	wi, _ := persistence.NewWorkloadInfo("https://myorg/app", "myorg", "1.0", "")
+	if _, err := persistence.NewEstablishedAgreement(db, "ag1", "ag1", "agbot", "{}", policy.BasicProtocol, 1, nil, "", "", "", "", "", wi); err != nil {
+		t.Fatalf("unable to save the agreement, %v", err)
+	} else if err := os.RemoveAll(secretsDir); err != nil {
+		t.Fatal(err)
+	}
+	w.restoreSecretFiles()
+	if content, err := ioutil.ReadFile(path.Join(secretsDir, "PASSWORD")); err != nil {
+		t.Errorf("the secret file was not restored, %v", err)
+	} else if string(content) != "s3cret" {
+		t.Errorf("the restored secret file has the wrong content %v", string(content))
+	}
+
+	if err := w.ResourcesRemove([]string{"ag1"}); err != nil {
+		t.Fatalf("unexpected error removing resources, %v", err)
+	}
+	if _, err := os.Stat(secretsDir); !os.IsNotExist(err) {
+		t.Errorf("the secret files were not removed, %v", err)
+	} else if saved, err := persistence.FindContainerSecrets(db); err != nil || len(saved) != 0 {
+		t.Errorf("the saved secrets were not removed, %v, error: %v", saved, err)
+	}
+}
+
+func Test_ResourcesCreate_secretReferences(t *testing.T) {
+
+	dir, db, fake, w := fakeWorkerSetup(t)
+	defer cleanFakeWorker(dir, db)
+
+	fake.AddImage("myorg/app:1.0")
+
+	vault := path.Join(dir, "vault")
+	if err := os.MkdirAll(path.Join(vault, "db"), 0700); err != nil {
+		t.Fatal(err)
+	} else if err := ioutil.WriteFile(path.Join(vault, "db", "password"), []byte("s3cret"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	w.secretResolver = secretprovider.NewResolver([]config.SecretProvider{config.SecretProvider{Name: "local", Type: config.SECRET_PROVIDER_FILE, Path: vault}})
+
+	deployment := &containermessage.DeploymentDescription{
+		Services: map[string]*containermessage.Service{
+			"app": {Image: "myorg/app:1.0"},
+		},
+	}
+
+	env := fakeEnvironment()
+	env["DB_PASSWORD"] = "secret://local/db/password"
+	if _, err := w.ResourcesCreate("ag1", "", nil, deployment, []byte("{}"), env, nil, nil, "https://myorg/app", "1.0"); err != nil {
+		t.Fatalf("unexpected error creating resources, %v", err)
+	}
+
+	secretFile := path.Join(dir, "secrets", "ag1", "DB_PASSWORD")
+	if content, err := ioutil.ReadFile(secretFile); err != nil {
+		t.Errorf("the secret file was not written, %v", err)
+	} else if string(content) != "s3cret" {
+		t.Errorf("the secret file has the wrong content %v", string(content))
+	}
+
+	if con, err := fake.InspectContainer("ag1-app"); err != nil {
+		t.Errorf("unable to inspect container, %v", err)
+	} else {
+		for _, e := range con.Config.Env {
+			if strings.HasPrefix(e, "DB_PASSWORD=") {
+				t.Errorf("the secret reference is in the container environment %v", con.Config.Env)
+			}
+		}
+	}
+
+	// a rotated secret is written to the secret file
+	if err := ioutil.WriteFile(path.Join(vault, "db", "password"), []byte("rotated"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	w.refreshSecrets()
+	if content, err := ioutil.ReadFile(secretFile); err != nil || string(content) != "rotated" {
+		t.Errorf("the secret file should have the rotated secret, got %v, error: %v", string(content), err)
+	}
+
+	if logs, err := persistence.FindAllEventLogs(db); err != nil {
+		t.Errorf("unable to read the event log, %v", err)
+	} else {
+		codes := []string{}
+		for _, l := range logs {
+			codes = append(codes, l.EventCode)
+		}
+		checkNames(t, "event codes", codes, persistence.EC_SECRET_READ, persistence.EC_SECRET_ROTATED)
+	}
+
+	if err := w.ResourcesRemove([]string{"ag1"}); err != nil {
+		t.Fatalf("unexpected error removing resources, %v", err)
+	} else if _, err := os.Stat(w.secretRefsFile("ag1")); !os.IsNotExist(err) {
+		t.Errorf("the secret references were not removed, %v", err)
+	}
+
+	// a reference that cannot be resolved stops the deployment
+	env["DB_PASSWORD"] = "secret://local/db/missing"
+	if _, err := w.ResourcesCreate("ag2", "", nil, deployment, []byte("{}"), env, nil, nil, "https://myorg/app", "1.0"); err == nil {
+		t.Errorf("expected an error for a secret that cannot be read")
+	}
+}
+
+func Test_ResourcesCreate_missingImage(t *testing.T) {
+
+	dir, db, fake, w := fakeWorkerSetup(t)
+	defer cleanFakeWorker(dir, db)
+
+	fake.AddImage("myorg/app:1.0")
+
+	deployment := &containermessage.DeploymentDescription{
+		Services: map[string]*containermessage.Service{
+			"app":    {Image: "myorg/app:1.0"},
+			"helper": {Image: "myorg/helper:1.0"},
+		},
+	}
+
+	if _, err := w.ResourcesCreate("ag1", "", nil, deployment, []byte("{}"), fakeEnvironment(), nil, nil, "https://myorg/app", "1.0"); err == nil {
+		t.Fatalf("expected an error for the missing image")
+	}
+
+	// nothing is left behind
+	checkNames(t, "containers", fake.ContainerNames())
+	checkNames(t, "networks", fake.NetworkNames())
+}
+
+func fakeWorkerSetup(t *testing.T) (string, *bolt.DB, *containerruntime.FakeRuntime, *ContainerWorker) {
+	dir, err := ioutil.TempDir("", "container-")
+	if err != nil {
+		t.Fatalf("unable to create temp dir, %v", err)
+	}
+
+	db, err := bolt.Open(path.Join(dir, "anax-ut.db"), 0600, &bolt.Options{Timeout: 10 * time.Second})
+	if err != nil {
+		t.Fatalf("unable to open the database, %v", err)
+	}
+
+	if err := persistence.InitUserInputSecretKey(path.Join(dir, "userinput.key")); err != nil {
+		t.Fatalf("unable to create the secret key, %v", err)
+	}
+
+	if err := os.Mkdir(path.Join(dir, "storage"), 0700); err != nil {
+		t.Fatalf("unable to create the service storage dir, %v", err)
+	}
+
+	cfg := &config.HorizonConfig{
+		Edge: config.Config{
+			ServiceStorage:     path.Join(dir, "storage"),
+			DefaultCPUSet:      "0-1",
+			SecretFilesPath:    path.Join(dir, "secrets"),
+			MMSObjectFilesPath: path.Join(dir, "mms"),
+			VolumeBackupPath:   path.Join(dir, "backups"),
+		},
+	}
+
+	fake := containerruntime.NewFakeRuntime()
+	w := &ContainerWorker{
+		BaseWorker: worker.NewBaseWorker("test", cfg, nil),
+		db:         db,
+		client:     fake,
+		iptables:   nil,
+		authMgr:    resource.NewAuthenticationManager(path.Join(dir, "auth")),
+		pattern:    "",
+		watch:      newEventWatch(),
+	}
+	return dir, db, fake, w
+}
+
+func cleanFakeWorker(dir string, db *bolt.DB) {
+	db.Close()
+	os.RemoveAll(dir)
+}
+
+func fakeEnvironment() map[string]string {
+	return map[string]string{config.ENVVAR_PREFIX + "RAM": "128"}
+}
+
+func checkNames(t *testing.T, kind string, actual []string, expected ...string) {
+	sort.Strings(actual)
+	sort.Strings(expected)
+	if len(actual) != len(expected) {
+		t.Errorf("expected %v %v, found %v", kind, expected, actual)
+		return
+	}
+	for i := range actual {
+		if actual[i] != expected[i] {
+			t.Errorf("expected %v %v, found %v", kind, expected, actual)
+			return
+		}
+	}
+}
@@ -0,0 +1,187 @@
+package container
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/golang/glog"
+	"github.com/open-horizon/anax/eventlog"
+	"github.com/open-horizon/anax/persistence"
+	"github.com/open-horizon/anax/policy"
+	"io/ioutil"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+const SECRET_REFRESH = "SecretRefresh"
+
+// The suffix of the file that keeps the secret references of the containers of an agreement or a service instance.
+const SECRET_REFS_SUFFIX = ".refs"
+
+// The secret references of the containers of an agreement or a service instance. They are kept next to the secret
+// files, outside of the directory that is bound into the containers, so that rotated secrets can be written to the
+// secret files while the containers run.
+type secretReferences struct {
+	ServiceURL string            `json:"service_url"`
+	Version    string            `json:"version"`
+	Refs       map[string]string `json:"refs"` // The name of the secret file and the reference that it is resolved from.
+}
+
+// Returns the file that keeps the secret references of the containers of an agreement or a service instance.
+func (b *ContainerWorker) secretRefsFile(key string) string {
+	return path.Join(b.Config.GetSecretFilesPath(), key+SECRET_REFS_SUFFIX)
+}
+
+// Resolve the environment variables and the secret user input whose values are secret references. The resolved
+// secrets are added to the secrets, so they are given to the containers as files, and the references are removed from
+// the environment. Every read of a secret is recorded in the event log. The environment, the secrets and the
+// references are returned as copies.
+func (b *ContainerWorker) resolveSecretReferences(key string, serviceURL string, version string, environmentAdditions map[string]string, secrets map[string]string) (map[string]string, map[string]string, map[string]string, error) {
+
+	envAdds := make(map[string]string, len(environmentAdditions))
+	resolved := make(map[string]string, len(secrets))
+	refs := make(map[string]string)
+
+	for name, value := range environmentAdditions {
+		if policy.IsSecretReference(value) {
+			refs[name] = value
+		} else {
+			envAdds[name] = value
+		}
+	}
+	for name, value := range secrets {
+		if policy.IsSecretReference(value) {
+			refs[name] = value
+		} else {
+			resolved[name] = value
+		}
+	}
+
+	for name, ref := range refs {
+		secret, err := b.secretResolver.Resolve(ref)
+		b.logSecretAccess(key, serviceURL, version, name, ref, false, err)
+		if err != nil {
+			return nil, nil, nil, errors.New(fmt.Sprintf("unable to resolve secret %v of %v, error: %v", name, key, err))
+		}
+		resolved[name] = secret
+	}
+
+	return envAdds, resolved, refs, nil
+}
+
+// Save the secret references of the containers of an agreement or a service instance so that the secrets can be
+// refreshed.
+func (b *ContainerWorker) saveSecretReferences(key string, serviceURL string, version string, refs map[string]string) error {
+	if len(refs) == 0 {
+		return nil
+	}
+
+	b.secretLock.Lock()
+	defer b.secretLock.Unlock()
+
+	sr := secretReferences{ServiceURL: serviceURL, Version: version, Refs: refs}
+	if out, err := json.Marshal(sr); err != nil {
+		return errors.New(fmt.Sprintf("unable to serialize the secret references of %v, error: %v", key, err))
+	} else if err := ioutil.WriteFile(b.secretRefsFile(key), out, 0600); err != nil {
+		return errors.New(fmt.Sprintf("unable to save the secret references of %v, error: %v", key, err))
+	}
+	return nil
+}
+
+// Read the secret references again and rewrite the secret files whose secrets have been rotated. A secret that cannot
+// be read keeps its current file. The containers see the new secret files right away because the directory that holds
+// them is bound into the containers.
+func (b *ContainerWorker) refreshSecrets() int {
+
+	base := b.Config.GetSecretFilesPath()
+	files, err := filepath.Glob(path.Join(base, "*"+SECRET_REFS_SUFFIX))
+	if err != nil {
+		glog.Errorf("Unable to list the secret references in %v, error: %v", base, err)
+		return 0
+	}
+
+	for _, file := range files {
+		b.refreshSecretFiles(strings.TrimSuffix(filepath.Base(file), SECRET_REFS_SUFFIX), file)
+	}
+	return 0
+}
+
+// Rewrite the secret files of the containers of an agreement or a service instance whose secrets have been rotated.
+func (b *ContainerWorker) refreshSecretFiles(key string, file string) {
+	b.secretLock.Lock()
+	defer b.secretLock.Unlock()
+
+	var sr secretReferences
+	if out, err := ioutil.ReadFile(file); os.IsNotExist(err) {
+		// the containers have been removed since the directory was listed
+		return
+	} else if err != nil {
+		glog.Errorf("Unable to read the secret references of %v, error: %v", key, err)
+		return
+	} else if err := json.Unmarshal(out, &sr); err != nil {
+		glog.Errorf("Unable to parse the secret references of %v, error: %v", key, err)
+		return
+	}
+
+	dir := b.secretsDir(key)
+	for name, ref := range sr.Refs {
+		secretFile := path.Join(dir, name)
+		current, err := ioutil.ReadFile(secretFile)
+		if err != nil {
+			glog.Errorf("Unable to read secret file %v, error: %v", secretFile, err)
+			continue
+		}
+
+		secret, err := b.secretResolver.Resolve(ref)
+		if err != nil {
+			b.logSecretAccess(key, sr.ServiceURL, sr.Version, name, ref, true, err)
+			continue
+		} else if secret == string(current) {
+			continue
+		}
+
+		// write the new secret next to the old one and rename it so that a container never reads a partial secret
+		tmp := path.Join(dir, "."+name+".new")
+		if err := ioutil.WriteFile(tmp, []byte(secret), 0444); err != nil {
+			glog.Errorf("Unable to write rotated secret %v of %v, error: %v", name, key, err)
+		} else if err := os.Rename(tmp, secretFile); err != nil {
+			os.Remove(tmp)
+			glog.Errorf("Unable to replace secret file %v, error: %v", secretFile, err)
+		} else {
+			b.logSecretAccess(key, sr.ServiceURL, sr.Version, name, ref, true, nil)
+		}
+	}
+}
+
+// Record a read of a secret from a secret provider in the event log. A successful refresh is only recorded when the
+// secret has been rotated, so that the refresh interval does not fill the event log.
+func (b *ContainerWorker) logSecretAccess(key string, serviceURL string, version string, name string, ref string, rotated bool, err error) {
+	if err != nil {
+		glog.Errorf("Error reading secret %v for %v from %v, error: %v", name, key, ref, err)
+	} else {
+		glog.V(3).Infof("Read secret %v for %v from %v", name, key, ref)
+	}
+
+	if b.db == nil {
+		return
+	}
+
+	if err != nil {
+		eventlog.LogServiceEvent2(b.db, persistence.SEVERITY_ERROR,
+			persistence.NewMessageMeta(EL_CONT_SECRET_READ_ERROR, name, key, ref, err.Error()),
+			persistence.EC_ERROR_SECRET_READ,
+			key, serviceURL, "", version, "", []string{})
+	} else if rotated {
+		eventlog.LogServiceEvent2(b.db, persistence.SEVERITY_INFO,
+			persistence.NewMessageMeta(EL_CONT_SECRET_ROTATED, name, key, ref),
+			persistence.EC_SECRET_ROTATED,
+			key, serviceURL, "", version, "", []string{})
+	} else {
+		eventlog.LogServiceEvent2(b.db, persistence.SEVERITY_INFO,
+			persistence.NewMessageMeta(EL_CONT_SECRET_READ, name, key, ref),
+			persistence.EC_SECRET_READ,
+			key, serviceURL, "", version, "", []string{})
+	}
+}
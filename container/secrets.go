@@ -0,0 +1,205 @@
+package container
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/golang/glog"
+	"github.com/open-horizon/anax/persistence"
+	"github.com/open-horizon/anax/policy"
+	"io/ioutil"
+	"os"
+	"path"
+	"path/filepath"
+)
+
+// Returns the host directory that holds the secret files of the containers of an agreement or of a service instance.
+func (b *ContainerWorker) secretsDir(key string) string {
+	return path.Join(b.Config.GetSecretFilesPath(), key)
+}
+
+// Write each secret user input into a file that is named after the variable. The directory is bound read-only into
+// the containers of the agreement or service instance, so the secrets do not show up in the container environment.
+// It returns an empty directory name when there are no secrets.
+func (b *ContainerWorker) createSecretFiles(key string, secrets map[string]string) (string, error) {
+	if len(secrets) == 0 {
+		return "", nil
+	}
+
+	b.secretLock.Lock()
+	defer b.secretLock.Unlock()
+
+	base := b.Config.GetSecretFilesPath()
+	dir := b.secretsDir(key)
+	if err := os.MkdirAll(base, 0700); err != nil {
+		return "", errors.New(fmt.Sprintf("unable to create directory %v for secret files, error: %v", base, err))
+	} else if !isTmpfs(base) {
+		glog.Warningf("Secret files directory %v is not on a tmpfs file system, the secrets of %v will be written to disk.", base, key)
+	}
+
+	// a new directory each time so that secrets that are no longer set are removed
+	if err := os.RemoveAll(dir); err != nil {
+		return "", errors.New(fmt.Sprintf("unable to remove old secret files in %v, error: %v", dir, err))
+	} else if err := os.Mkdir(dir, 0755); err != nil {
+		return "", errors.New(fmt.Sprintf("unable to create directory %v for secret files, error: %v", dir, err))
+	}
+
+	for name, value := range secrets {
+		if err := writeSecretFile(dir, name, value); err != nil {
+			return "", err
+		}
+	}
+
+	glog.V(5).Infof("Created %v secret files for %v in %v.", len(secrets), key, dir)
+	return dir, nil
+}
+
+func writeSecretFile(dir string, name string, value string) error {
+	if name == "" || name == "." || name == ".." || filepath.Base(name) != name {
+		return errors.New(fmt.Sprintf("secret user input name %v is not a valid file name", name))
+	} else if err := ioutil.WriteFile(path.Join(dir, name), []byte(value), 0444); err != nil {
+		return errors.New(fmt.Sprintf("unable to write secret file for %v in %v, error: %v", name, dir, err))
+	}
+	return nil
+}
+
+// Write the secret files and the secret references of the containers of an agreement or a service instance, and save
+// the secrets in the local database so that the files can be written again after the node was rebooted. The refs are
+// the secrets that were resolved from a secret provider, they are resolved again then.
+func (b *ContainerWorker) writeSecrets(key string, serviceURL string, version string, secrets map[string]string, refs map[string]string) (string, error) {
+	dir, err := b.createSecretFiles(key, secrets)
+	if err == nil {
+		err = b.saveSecretReferences(key, serviceURL, version, refs)
+	}
+	if err == nil && b.db != nil && len(secrets) != 0 {
+		cs := persistence.ContainerSecrets{ServiceURL: serviceURL, Version: version, Secrets: make(map[string]string), Refs: refs}
+		for name, value := range secrets {
+			if _, ok := refs[name]; !ok {
+				cs.Secrets[name] = value
+			}
+		}
+		if err = persistence.SaveContainerSecrets(b.db, key, cs); err != nil {
+			err = errors.New(fmt.Sprintf("unable to save the secrets of %v, error: %v", key, err))
+		}
+	}
+	if err != nil {
+		b.removeSecretFiles(key)
+		return "", err
+	}
+	return dir, nil
+}
+
+// Write the secret files of the running agreements and service instances again when they are gone, which happens when
+// the node was rebooted because they are on a tmpfs file system. The files are written into the directory that is bound
+// into the containers without replacing it, because docker may have restarted the containers already.
+func (b *ContainerWorker) restoreSecretFiles() {
+	if b.db == nil {
+		return
+	}
+
+	all, err := persistence.FindContainerSecrets(b.db)
+	if err != nil {
+		glog.Errorf("Unable to read the secrets of the containers from the database, error: %v", err)
+		return
+	} else if len(all) == 0 {
+		return
+	}
+
+	active := make(map[string]bool)
+	if ags, err := persistence.FindEstablishedAgreementsAllProtocols(b.db, policy.AllAgreementProtocols(), []persistence.EAFilter{persistence.UnarchivedEAFilter()}); err != nil {
+		glog.Errorf("Unable to retrieve agreements from the database, error: %v", err)
+		return
+	} else {
+		for _, ag := range ags {
+			if ag.AgreementTerminatedTime == 0 {
+				active[ag.CurrentAgreementId] = true
+			}
+		}
+	}
+	if msInsts, err := persistence.FindMicroserviceInstances(b.db, []persistence.MIFilter{persistence.UnarchivedMIFilter()}); err != nil {
+		glog.Errorf("Unable to retrieve service instances from the database, error: %v", err)
+		return
+	} else {
+		for _, msi := range msInsts {
+			active[msi.GetKey()] = true
+		}
+	}
+
+	for key, cs := range all {
+		if !active[key] {
+			glog.V(3).Infof("Removing the secrets of %v, it is no longer running.", key)
+			if err := b.removeSecretFiles(key); err != nil {
+				glog.Errorf("Failed to remove secret files for %v, error %v", key, err)
+			}
+			continue
+		}
+
+		secrets := make(map[string]string, len(cs.Secrets)+len(cs.Refs))
+		for name, value := range cs.Secrets {
+			secrets[name] = value
+		}
+		for name, ref := range cs.Refs {
+			secret, err := b.secretResolver.Resolve(ref)
+			b.logSecretAccess(key, cs.ServiceURL, cs.Version, name, ref, false, err)
+			if err == nil {
+				secrets[name] = secret
+			}
+		}
+
+		if err := b.restoreSecretFilesFor(key, cs, secrets); err != nil {
+			glog.Errorf("Failed to restore secret files for %v, error %v", key, err)
+		}
+	}
+}
+
+// Write the missing secret files and the secret references of an agreement or a service instance.
+func (b *ContainerWorker) restoreSecretFilesFor(key string, cs persistence.ContainerSecrets, secrets map[string]string) error {
+	b.secretLock.Lock()
+	defer b.secretLock.Unlock()
+
+	dir := b.secretsDir(key)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return errors.New(fmt.Sprintf("unable to create directory %v for secret files, error: %v", dir, err))
+	}
+
+	restored := 0
+	for name, value := range secrets {
+		if _, err := os.Stat(path.Join(dir, name)); err == nil {
+			continue
+		} else if err := writeSecretFile(dir, name, value); err != nil {
+			return err
+		}
+		restored += 1
+	}
+
+	if _, err := os.Stat(b.secretRefsFile(key)); len(cs.Refs) != 0 && os.IsNotExist(err) {
+		sr := secretReferences{ServiceURL: cs.ServiceURL, Version: cs.Version, Refs: cs.Refs}
+		if out, err := json.Marshal(sr); err != nil {
+			return errors.New(fmt.Sprintf("unable to serialize the secret references of %v, error: %v", key, err))
+		} else if err := ioutil.WriteFile(b.secretRefsFile(key), out, 0600); err != nil {
+			return errors.New(fmt.Sprintf("unable to save the secret references of %v, error: %v", key, err))
+		}
+	}
+
+	if restored != 0 {
+		glog.V(3).Infof("Restored %v secret files for %v in %v.", restored, key, dir)
+	}
+	return nil
+}
+
+// Remove the secret files and the secret references of the containers of an agreement or a service instance.
+func (b *ContainerWorker) removeSecretFiles(key string) error {
+	b.secretLock.Lock()
+	defer b.secretLock.Unlock()
+
+	if err := os.RemoveAll(b.secretsDir(key)); err != nil {
+		return errors.New(fmt.Sprintf("unable to remove secret files in %v, error: %v", b.secretsDir(key), err))
+	} else if err := os.Remove(b.secretRefsFile(key)); err != nil && !os.IsNotExist(err) {
+		return errors.New(fmt.Sprintf("unable to remove the secret references of %v, error: %v", key, err))
+	} else if b.db != nil {
+		if err := persistence.DeleteContainerSecrets(b.db, key); err != nil {
+			return errors.New(fmt.Sprintf("unable to remove the saved secrets of %v, error: %v", key, err))
+		}
+	}
+	return nil
+}
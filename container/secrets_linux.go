@@ -0,0 +1,16 @@
+// +build linux
+
+package container
+
+import (
+	"golang.org/x/sys/unix"
+)
+
+// Returns true when the directory is on a tmpfs file system, so files written into it are not stored on disk.
+func isTmpfs(dir string) bool {
+	var st unix.Statfs_t
+	if err := unix.Statfs(dir, &st); err != nil {
+		return false
+	}
+	return st.Type == unix.TMPFS_MAGIC
+}
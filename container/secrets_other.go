@@ -0,0 +1,8 @@
+// +build !linux
+
+package container
+
+// Only linux is checked for tmpfs.
+func isTmpfs(dir string) bool {
+	return false
+}
@@ -0,0 +1,481 @@
+package container
+
+import (
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"github.com/boltdb/bolt"
+	docker "github.com/fsouza/go-dockerclient"
+	"github.com/golang/glog"
+	"github.com/open-horizon/anax/config"
+	"github.com/open-horizon/anax/containermessage"
+	"github.com/open-horizon/anax/containerruntime"
+	"github.com/open-horizon/anax/cutil"
+	"github.com/open-horizon/anax/eventlog"
+	"github.com/open-horizon/anax/persistence"
+	"io/ioutil"
+	"os"
+	"path"
+	"time"
+)
+
+const VOLUME_EXPIRY = "VolumeExpiry"
+
+// How often the volumes whose retention has run out are looked for.
+const VOLUME_EXPIRY_INTERVAL_S = 3600
+
+// Where a volume is mounted into the container that the agent uses to read and write it. The container is created from
+// an image of the service but it is never started.
+const VOLUME_HELPER_MOUNT = "/horizon-volume"
+
+// Returns the binds of the named volumes of the service.
+func volumeBinds(service *containermessage.Service) []string {
+	binds := make([]string, 0, len(service.Volumes))
+	for _, v := range service.Volumes {
+		binds = append(binds, v.Bind())
+	}
+	return binds
+}
+
+// Record which agreement or service instance uses the named volumes of its services, and with which retention. A
+// volume that has a backup waiting to be restored, because its service was rolled back, is restored before the
+// containers are created; the containers are not created when the restore fails, so that it is tried again when the
+// service is retried. Volumes that the agent did not create are left alone.
+func (b *ContainerWorker) claimServiceVolumes(key string, serviceURL string, version string, servicePairs map[string]servicePair) error {
+
+	for serviceName, sp := range servicePairs {
+		for _, v := range sp.service.Volumes {
+			cv, err := persistence.FindUndeletedContainerVolume(b.db, v.Name)
+			if err != nil {
+				return fmt.Errorf("unable to read volume %v from the local db, error: %v", v.Name, err)
+			} else if cv == nil {
+				if owned, err := b.isAgentVolume(v.Name); err != nil {
+					return err
+				} else if !owned {
+					glog.Warningf("Volume %v of service %v was not created by the agent, its retention is not managed.", v.Name, serviceName)
+					continue
+				}
+				// a kept volume that outlived the registration of the node
+				cv = persistence.NewContainerVolume(v.Name)
+			}
+
+			cv.Retention = v.GetRetention()
+			cv.Service = serviceURL
+			cv.ServiceVersion = version
+			cv.Image = sp.serviceConfig.Config.Image
+			cv.InstanceKey = key
+			cv.ReleaseTime = 0
+
+			// the volume keeps waiting for the restore until it succeeds, unless the backup is gone
+			if cv.RestoreBackup != "" {
+				if backups, err := persistence.FindVolumeBackups(b.db, []persistence.VolumeBackupFilter{persistence.VolumeNameVBFilter(cv.Name), persistence.RecordIdVBFilter(cv.RestoreBackup)}); err != nil {
+					return fmt.Errorf("unable to read the backups of volume %v from the local db, error: %v", cv.Name, err)
+				} else if len(backups) == 0 {
+					logVolumeEvent(b.db, persistence.SEVERITY_ERROR,
+						persistence.NewMessageMeta(EL_CONT_VOLUME_RESTORE_ERROR, cv.Name, cv.Service, cv.RestoreBackup, fmt.Sprintf("volume %v has no backup %v", cv.Name, cv.RestoreBackup)),
+						persistence.EC_ERROR_VOLUME_RESTORE, cv)
+				} else if err := restoreVolume(b.client, b.db, cv, &backups[0]); err != nil {
+					return fmt.Errorf("unable to restore volume %v of service %v, error: %v", cv.Name, serviceName, err)
+				}
+				cv.RestoreBackup = ""
+			}
+
+			if err := persistence.SaveContainerVolume(b.db, cv); err != nil {
+				return fmt.Errorf("unable to save volume %v into the local db, error: %v", v.Name, err)
+			}
+		}
+	}
+	return nil
+}
+
+// Returns true when the docker volume was created by the agent.
+func (b *ContainerWorker) isAgentVolume(name string) (bool, error) {
+	volumes, err := b.client.ListVolumes(docker.ListVolumesOptions{})
+	if err != nil {
+		return false, fmt.Errorf("unable to list the docker volumes, error: %v", err)
+	}
+	for _, v := range volumes {
+		if v.Name == name {
+			return v.Labels[LABEL_PREFIX+".owner"] == "openhorizon", nil
+		}
+	}
+	return false, nil
+}
+
+// Record that the containers of an agreement or service instance no longer use their named volumes, and remove the
+// volumes whose retention says so.
+func (b *ContainerWorker) releaseServiceVolumes(key string) {
+	cvs, err := persistence.FindContainerVolumes(b.db, []persistence.ContainerVolumeFilter{persistence.UnarchivedCVFilter(), persistence.DeclaredCVFilter(), persistence.InstanceKeyCVFilter(key)})
+	if err != nil {
+		glog.Errorf("Unable to read the volumes of %v from the local db, error: %v", key, err)
+		return
+	}
+
+	for _, cv := range cvs {
+		cv.ReleaseTime = uint64(time.Now().Unix())
+		if err := persistence.SaveContainerVolume(b.db, &cv); err != nil {
+			glog.Errorf("Unable to save volume %v into the local db, error: %v", cv.Name, err)
+		}
+	}
+
+	if len(cvs) != 0 {
+		b.expireVolumes()
+	}
+}
+
+// Remove the named volumes that are no longer used and whose retention has run out. A volume that is still in use by
+// another container is tried again later.
+func (b *ContainerWorker) expireVolumes() int {
+	cvs, err := persistence.FindContainerVolumes(b.db, []persistence.ContainerVolumeFilter{persistence.UnarchivedCVFilter(), persistence.DeclaredCVFilter()})
+	if err != nil {
+		glog.Errorf("Unable to read the volumes from the local db, error: %v", err)
+		return 0
+	}
+
+	now := uint64(time.Now().Unix())
+	for _, cv := range cvs {
+		if cv.ReleaseTime == 0 {
+			continue
+		} else if policy, days, err := containermessage.ParseVolumeRetention(cv.Retention); err != nil {
+			glog.Errorf("Volume %v has an invalid retention, error: %v", cv.Name, err)
+			continue
+		} else if policy == containermessage.VOLUME_RETENTION_KEEP && (days == 0 || now < cv.ReleaseTime+uint64(days)*24*3600) {
+			continue
+		}
+
+		if err := b.client.RemoveVolume(cv.Name); err == docker.ErrVolumeInUse {
+			glog.V(3).Infof("Volume %v is still in use, it will be removed later.", cv.Name)
+			continue
+		} else if err != nil && err != docker.ErrNoSuchVolume {
+			glog.Errorf("Unable to remove volume %v, error: %v", cv.Name, err)
+			continue
+		}
+
+		if err := persistence.ArchiveContainerVolumes(b.db, &cv); err != nil {
+			glog.Errorf("%v", err)
+		}
+		logVolumeEvent(b.db, persistence.SEVERITY_INFO,
+			persistence.NewMessageMeta(EL_CONT_VOLUME_REMOVED, cv.Name, cv.Service, cv.Retention),
+			persistence.EC_VOLUME_REMOVED, &cv)
+	}
+	return 0
+}
+
+// Back up the named volumes of a service before it is upgraded from the given version. The service is the org/url
+// of the service.
+func BackupServiceVolumes(db *bolt.DB, cfg *config.HorizonConfig, service string, version string) error {
+	cvs, err := persistence.FindContainerVolumes(db, []persistence.ContainerVolumeFilter{persistence.UnarchivedCVFilter(), persistence.DeclaredCVFilter(), persistence.ServiceCVFilter(service)})
+	if err != nil {
+		return fmt.Errorf("unable to read the volumes of service %v from the local db, error: %v", service, err)
+	} else if len(cvs) == 0 {
+		return nil
+	}
+
+	client, err := volumeRuntime(cfg)
+	if err != nil {
+		return err
+	}
+	return backupServiceVolumes(client, db, cfg, cvs, version)
+}
+
+func backupServiceVolumes(client containerruntime.ContainerRuntime, db *bolt.DB, cfg *config.HorizonConfig, cvs []persistence.ContainerVolume, version string) error {
+	var firstErr error
+	for _, cv := range cvs {
+		cv.ServiceVersion = version
+		if _, err := backupVolume(client, db, cfg, &cv, persistence.VOLUME_BACKUP_UPGRADE); err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+	return firstErr
+}
+
+// Back up a named volume on request of the user.
+func BackupNamedVolume(db *bolt.DB, cfg *config.HorizonConfig, name string) (*persistence.VolumeBackup, error) {
+	if cv, err := findDeclaredVolume(db, name); err != nil {
+		return nil, err
+	} else if client, err := volumeRuntime(cfg); err != nil {
+		return nil, err
+	} else {
+		return backupVolume(client, db, cfg, cv, persistence.VOLUME_BACKUP_MANUAL)
+	}
+}
+
+// Restore a named volume from one of its backups, the latest one when the backup id is empty. The volume must not be
+// in use.
+func RestoreNamedVolume(db *bolt.DB, cfg *config.HorizonConfig, name string, backupId string) (*persistence.VolumeBackup, error) {
+	cv, err := findDeclaredVolume(db, name)
+	if err != nil {
+		return nil, err
+	}
+	backup, err := findVolumeBackup(db, name, backupId)
+	if err != nil {
+		return nil, err
+	}
+	client, err := volumeRuntime(cfg)
+	if err != nil {
+		return nil, err
+	}
+	if err := restoreVolume(client, db, cv, backup); err != nil {
+		return nil, err
+	}
+	return backup, nil
+}
+
+// Ask for the named volumes of a service to be restored, when the service is started again, from the backups that
+// were made before the given version was upgraded. It is used when an upgrade fails and the service is rolled back.
+func MarkServiceVolumesForRestore(db *bolt.DB, service string, version string) error {
+	backups, err := persistence.FindVolumeBackups(db, []persistence.VolumeBackupFilter{persistence.ServiceVersionVBFilter(service, version), persistence.ReasonVBFilter(persistence.VOLUME_BACKUP_UPGRADE)})
+	if err != nil {
+		return fmt.Errorf("unable to read the volume backups of service %v from the local db, error: %v", service, err)
+	}
+
+	// the latest backup of each volume
+	latest := make(map[string]persistence.VolumeBackup)
+	for _, backup := range backups {
+		latest[backup.VolumeName] = backup
+	}
+
+	for name, backup := range latest {
+		cv, err := persistence.FindUndeletedContainerVolume(db, name)
+		if err != nil {
+			return fmt.Errorf("unable to read volume %v from the local db, error: %v", name, err)
+		} else if cv == nil {
+			// the volume was removed with the containers of the failed version
+			cv = persistence.NewContainerVolume(name)
+		}
+		cv.RestoreBackup = backup.RecordId
+		if err := persistence.SaveContainerVolume(db, cv); err != nil {
+			return fmt.Errorf("unable to save volume %v into the local db, error: %v", name, err)
+		}
+		glog.V(3).Infof("Volume %v of service %v will be restored from backup %v when the service is started.", name, service, backup.File)
+	}
+	return nil
+}
+
+// Returns the container runtime that backs up and restores volumes outside of the container worker.
+func volumeRuntime(cfg *config.HorizonConfig) (containerruntime.ContainerRuntime, error) {
+	if cfg.Edge.DockerEndpoint == "" {
+		return nil, errors.New("Docker client cannot be initialized. Please make sure DockerEndpoint is set in the configuration file.")
+	} else if client, err := containerruntime.NewDockerRuntime(cfg.Edge.DockerEndpoint); err != nil {
+		return nil, fmt.Errorf("Failed to instantiate docker Client: %v", err)
+	} else {
+		return client, nil
+	}
+}
+
+func findDeclaredVolume(db *bolt.DB, name string) (*persistence.ContainerVolume, error) {
+	if cv, err := persistence.FindUndeletedContainerVolume(db, name); err != nil {
+		return nil, fmt.Errorf("unable to read volume %v from the local db, error: %v", name, err)
+	} else if cv == nil || !cv.IsDeclared() {
+		return nil, fmt.Errorf("volume %v is not a named volume of a service on this node", name)
+	} else {
+		return cv, nil
+	}
+}
+
+// Returns a backup of the volume, the latest one when the id is empty.
+func findVolumeBackup(db *bolt.DB, name string, id string) (*persistence.VolumeBackup, error) {
+	filters := []persistence.VolumeBackupFilter{persistence.VolumeNameVBFilter(name)}
+	if id != "" {
+		filters = append(filters, persistence.RecordIdVBFilter(id))
+	}
+
+	if backups, err := persistence.FindVolumeBackups(db, filters); err != nil {
+		return nil, fmt.Errorf("unable to read the backups of volume %v from the local db, error: %v", name, err)
+	} else if len(backups) == 0 && id != "" {
+		return nil, fmt.Errorf("volume %v has no backup %v", name, id)
+	} else if len(backups) == 0 {
+		return nil, fmt.Errorf("volume %v has no backups", name)
+	} else {
+		return &backups[len(backups)-1], nil
+	}
+}
+
+// Create a container, which is never started, that mounts the volume so that its content can be read and written
+// with the archive API of docker.
+func createVolumeHelper(client containerruntime.ContainerRuntime, name string, image string) (string, error) {
+	if image == "" {
+		return "", fmt.Errorf("no image is known for volume %v, the service that uses it must be started first", name)
+	}
+
+	c, err := client.CreateContainer(docker.CreateContainerOptions{
+		Name: fmt.Sprintf("horizon-volume-%v-%v", name, time.Now().UnixNano()),
+		Config: &docker.Config{
+			Image:  image,
+			Cmd:    []string{"true"},
+			Labels: map[string]string{LABEL_PREFIX + ".volume_helper": name},
+		},
+		HostConfig: &docker.HostConfig{
+			Binds:       []string{fmt.Sprintf("%v:%v", name, VOLUME_HELPER_MOUNT)},
+			NetworkMode: "none",
+		},
+	})
+	if err != nil {
+		return "", fmt.Errorf("unable to create a container to access volume %v, error: %v", name, err)
+	}
+	return c.ID, nil
+}
+
+func removeVolumeHelper(client containerruntime.ContainerRuntime, id string) {
+	if err := client.RemoveContainer(docker.RemoveContainerOptions{ID: id, Force: true}); err != nil {
+		glog.Errorf("Unable to remove the container %v that accessed a volume, error: %v", id, err)
+	}
+}
+
+// Write the content of the volume into a gzipped tar file and record it. The oldest backups of the volume are removed
+// so that only the configured number is kept.
+func backupVolume(client containerruntime.ContainerRuntime, db *bolt.DB, cfg *config.HorizonConfig, cv *persistence.ContainerVolume, reason string) (*persistence.VolumeBackup, error) {
+
+	backup, err := writeVolumeBackup(client, cfg, cv, reason)
+	if err == nil {
+		err = persistence.SaveVolumeBackup(db, backup)
+	}
+	if err != nil {
+		logVolumeEvent(db, persistence.SEVERITY_ERROR,
+			persistence.NewMessageMeta(EL_CONT_VOLUME_BACKUP_ERROR, cv.Name, cv.Service, cv.ServiceVersion, err.Error()),
+			persistence.EC_ERROR_VOLUME_BACKUP, cv)
+		return nil, err
+	}
+
+	logVolumeEvent(db, persistence.SEVERITY_INFO,
+		persistence.NewMessageMeta(EL_CONT_VOLUME_BACKED_UP, cv.Name, cv.Service, cv.ServiceVersion, backup.File),
+		persistence.EC_VOLUME_BACKED_UP, cv)
+
+	pruneVolumeBackups(db, cv, cfg.GetVolumeBackupsKept())
+	return backup, nil
+}
+
+func writeVolumeBackup(client containerruntime.ContainerRuntime, cfg *config.HorizonConfig, cv *persistence.ContainerVolume, reason string) (*persistence.VolumeBackup, error) {
+
+	dir := cfg.GetVolumeBackupPath()
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return nil, fmt.Errorf("unable to create directory %v for volume backups, error: %v", dir, err)
+	}
+
+	helper, err := createVolumeHelper(client, cv.Name, cv.Image)
+	if err != nil {
+		return nil, err
+	}
+	defer removeVolumeHelper(client, helper)
+
+	// write the backup next to its final name so that a partial backup is never recorded
+	file := path.Join(dir, fmt.Sprintf("%v-%v.tar.gz", cv.Name, time.Now().UnixNano()))
+	tmp, err := ioutil.TempFile(dir, ".backup-")
+	if err != nil {
+		return nil, fmt.Errorf("unable to create the backup of volume %v, error: %v", cv.Name, err)
+	}
+	defer os.Remove(tmp.Name())
+
+	gz := gzip.NewWriter(tmp)
+	if err := client.DownloadFromContainer(helper, docker.DownloadFromContainerOptions{OutputStream: gz, Path: VOLUME_HELPER_MOUNT}); err != nil {
+		tmp.Close()
+		return nil, fmt.Errorf("unable to read the content of volume %v, error: %v", cv.Name, err)
+	} else if err := gz.Close(); err != nil {
+		tmp.Close()
+		return nil, fmt.Errorf("unable to write the backup of volume %v, error: %v", cv.Name, err)
+	} else if err := tmp.Close(); err != nil {
+		return nil, fmt.Errorf("unable to write the backup of volume %v, error: %v", cv.Name, err)
+	}
+
+	info, err := os.Stat(tmp.Name())
+	if err != nil {
+		return nil, fmt.Errorf("unable to read the backup of volume %v, error: %v", cv.Name, err)
+	} else if err := os.Rename(tmp.Name(), file); err != nil {
+		return nil, fmt.Errorf("unable to move the backup of volume %v to %v, error: %v", cv.Name, file, err)
+	}
+
+	return persistence.NewVolumeBackup(cv.Name, file, info.Size(), cv.Service, cv.ServiceVersion, reason), nil
+}
+
+// Remove the oldest backups of the volume beyond the number that is kept. A backup that is waiting to be restored is
+// kept.
+func pruneVolumeBackups(db *bolt.DB, cv *persistence.ContainerVolume, kept int) {
+	backups, err := persistence.FindVolumeBackups(db, []persistence.VolumeBackupFilter{persistence.VolumeNameVBFilter(cv.Name)})
+	if err != nil {
+		glog.Errorf("Unable to read the backups of volume %v from the local db, error: %v", cv.Name, err)
+		return
+	}
+
+	for i := 0; i < len(backups)-kept; i++ {
+		if backups[i].RecordId == cv.RestoreBackup {
+			continue
+		} else if err := os.Remove(backups[i].File); err != nil && !os.IsNotExist(err) {
+			glog.Errorf("Unable to remove backup %v of volume %v, error: %v", backups[i].File, cv.Name, err)
+		} else if err := persistence.DeleteVolumeBackup(db, backups[i].RecordId); err != nil {
+			glog.Errorf("Unable to remove backup %v of volume %v from the local db, error: %v", backups[i].RecordId, cv.Name, err)
+		}
+	}
+}
+
+// Replace the content of the volume with the content of the backup. The volume is created again so that the files
+// that are not in the backup are removed, which fails while a container uses the volume.
+func restoreVolume(client containerruntime.ContainerRuntime, db *bolt.DB, cv *persistence.ContainerVolume, backup *persistence.VolumeBackup) error {
+
+	err := writeVolumeContent(client, cv, backup)
+	if err != nil {
+		logVolumeEvent(db, persistence.SEVERITY_ERROR,
+			persistence.NewMessageMeta(EL_CONT_VOLUME_RESTORE_ERROR, cv.Name, cv.Service, backup.File, err.Error()),
+			persistence.EC_ERROR_VOLUME_RESTORE, cv)
+		return err
+	}
+
+	logVolumeEvent(db, persistence.SEVERITY_INFO,
+		persistence.NewMessageMeta(EL_CONT_VOLUME_RESTORED, cv.Name, cv.Service, backup.File),
+		persistence.EC_VOLUME_RESTORED, cv)
+	return nil
+}
+
+func writeVolumeContent(client containerruntime.ContainerRuntime, cv *persistence.ContainerVolume, backup *persistence.VolumeBackup) error {
+
+	f, err := os.Open(backup.File)
+	if err != nil {
+		return fmt.Errorf("unable to open backup %v, error: %v", backup.File, err)
+	}
+	defer f.Close()
+
+	gz, err := gzip.NewReader(f)
+	if err != nil {
+		return fmt.Errorf("unable to read backup %v, error: %v", backup.File, err)
+	}
+
+	if err := client.RemoveVolume(cv.Name); err == docker.ErrVolumeInUse {
+		return fmt.Errorf("volume %v is in use, the service that uses it must be stopped first", cv.Name)
+	} else if err != nil && err != docker.ErrNoSuchVolume {
+		return fmt.Errorf("unable to remove volume %v, error: %v", cv.Name, err)
+	}
+
+	if _, err := client.CreateVolume(docker.CreateVolumeOptions{
+		Name:   cv.Name,
+		Driver: "local",
+		Labels: map[string]string{
+			LABEL_PREFIX + ".agreement_id": cv.InstanceKey,
+			LABEL_PREFIX + ".owner":        "openhorizon"},
+	}); err != nil {
+		return fmt.Errorf("unable to create volume %v, error: %v", cv.Name, err)
+	}
+
+	helper, err := createVolumeHelper(client, cv.Name, cv.Image)
+	if err != nil {
+		return err
+	}
+	defer removeVolumeHelper(client, helper)
+
+	// the entries of the backup are in the directory where the volume is mounted
+	if err := client.UploadToContainer(helper, docker.UploadToContainerOptions{InputStream: gz, Path: "/"}); err != nil {
+		return fmt.Errorf("unable to write the content of volume %v, error: %v", cv.Name, err)
+	}
+	return nil
+}
+
+func logVolumeEvent(db *bolt.DB, severity string, meta *persistence.MessageMeta, code string, cv *persistence.ContainerVolume) {
+	if severity == persistence.SEVERITY_ERROR {
+		glog.Errorf(meta.MessageKey, meta.MessageArgs...)
+	} else {
+		glog.V(3).Infof(meta.MessageKey, meta.MessageArgs...)
+	}
+
+	if db != nil {
+		org, url := cutil.SplitOrgSpecUrl(cv.Service)
+		eventlog.LogServiceEvent2(db, severity, meta, code, cv.InstanceKey, url, org, cv.ServiceVersion, "", []string{})
+	}
+}
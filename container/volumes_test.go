@@ -0,0 +1,237 @@
+// +build unit
+
+package container
+
+import (
+	"github.com/open-horizon/anax/config"
+	"github.com/open-horizon/anax/containermessage"
+	"github.com/open-horizon/anax/persistence"
+	"os"
+	"testing"
+)
+
+func volumeDeployment(volumes ...containermessage.NamedVolume) *containermessage.DeploymentDescription {
+	return &containermessage.DeploymentDescription{
+		Services: map[string]*containermessage.Service{
+			"app": {
+				Image:   "myorg/app:1.0",
+				Volumes: volumes,
+			},
+		},
+	}
+}
+
+func Test_ResourcesCreateRemove_volumes(t *testing.T) {
+
+	dir, db, fake, w := fakeWorkerSetup(t)
+	defer cleanFakeWorker(dir, db)
+
+	fake.AddImage("myorg/app:1.0")
+
+	// the binds are added to the deployment, so each agreement gets its own
+	volumes := []containermessage.NamedVolume{
+		{Name: "scratch", MountPath: "/scratch"},
+		{Name: "data", MountPath: "/data", Retention: containermessage.VOLUME_RETENTION_KEEP},
+		{Name: "cache", MountPath: "/cache", ReadOnly: true, Retention: "keep-7-days"},
+	}
+	if _, err := w.ResourcesCreate("ag1", "", nil, volumeDeployment(volumes...), []byte("{}"), fakeEnvironment(), nil, nil, "myorg/https://myorg/app", "1.0"); err != nil {
+		t.Fatalf("unexpected error creating resources, %v", err)
+	}
+
+	con, err := fake.InspectContainer("ag1-app")
+	if err != nil {
+		t.Fatalf("unable to inspect container, %v", err)
+	}
+	binds := map[string]bool{}
+	for _, bind := range con.HostConfig.Binds {
+		binds[bind] = true
+	}
+	if !binds["scratch:/scratch:rw"] || !binds["data:/data:rw"] || !binds["cache:/cache:ro"] {
+		t.Errorf("the volumes are not bound into the container, binds %v", con.HostConfig.Binds)
+	}
+	checkNames(t, "volumes", fake.VolumeNames(), "cache", "data", "scratch")
+
+	if cv, err := persistence.FindUndeletedContainerVolume(db, "cache"); err != nil || cv == nil {
+		t.Fatalf("the volume should be recorded, error %v", err)
+	} else if cv.Retention != "keep-7-days" || cv.Service != "myorg/https://myorg/app" || cv.ServiceVersion != "1.0" || cv.Image != "myorg/app:1.0" || cv.InstanceKey != "ag1" || cv.ReleaseTime != 0 {
+		t.Errorf("unexpected volume record %v", cv)
+	}
+
+	// the volumes that are not kept are removed with the containers
+	if err := w.ResourcesRemove([]string{"ag1"}); err != nil {
+		t.Fatalf("unexpected error removing resources, %v", err)
+	}
+	checkNames(t, "volumes", fake.VolumeNames(), "cache", "data")
+
+	cv, err := persistence.FindUndeletedContainerVolume(db, "cache")
+	if err != nil || cv == nil {
+		t.Fatalf("the kept volume should be recorded, error %v", err)
+	} else if cv.ReleaseTime == 0 {
+		t.Errorf("the kept volume should be released, %v", cv)
+	} else if cv, _ := persistence.FindUndeletedContainerVolume(db, "scratch"); cv != nil {
+		t.Errorf("the removed volume should be archived, %v", cv)
+	}
+
+	// a volume that is used again is no longer released
+	if _, err := w.ResourcesCreate("ag2", "", nil, volumeDeployment(volumes...), []byte("{}"), fakeEnvironment(), nil, nil, "myorg/https://myorg/app", "1.0"); err != nil {
+		t.Fatalf("unexpected error creating resources, %v", err)
+	} else if cv, _ := persistence.FindUndeletedContainerVolume(db, "cache"); cv == nil || cv.ReleaseTime != 0 || cv.InstanceKey != "ag2" {
+		t.Errorf("the volume should be claimed again, %v", cv)
+	} else if err := w.ResourcesRemove([]string{"ag2"}); err != nil {
+		t.Fatalf("unexpected error removing resources, %v", err)
+	}
+
+	// the volume that is kept for some days is removed when they are over
+	cv, _ = persistence.FindUndeletedContainerVolume(db, "cache")
+	cv.ReleaseTime -= 8 * 24 * 3600
+	if err := persistence.SaveContainerVolume(db, cv); err != nil {
+		t.Fatalf("unable to save the volume, %v", err)
+	}
+	w.expireVolumes()
+	checkNames(t, "volumes", fake.VolumeNames(), "data")
+
+	if logs, err := persistence.FindAllEventLogs(db); err != nil {
+		t.Errorf("unable to read the event log, %v", err)
+	} else {
+		codes := []string{}
+		for _, l := range logs {
+			if l.EventCode == persistence.EC_VOLUME_REMOVED {
+				codes = append(codes, l.EventCode)
+			}
+		}
+		checkNames(t, "event codes", codes, persistence.EC_VOLUME_REMOVED, persistence.EC_VOLUME_REMOVED, persistence.EC_VOLUME_REMOVED)
+	}
+}
+
+func Test_ResourcesCreate_volumesShared(t *testing.T) {
+
+	dir, db, fake, w := fakeWorkerSetup(t)
+	defer cleanFakeWorker(dir, db)
+
+	fake.AddImage("myorg/app:1.0")
+
+	deployment := volumeDeployment(containermessage.NamedVolume{Name: "data", MountPath: "/data"})
+	deployment.ServicePattern = containermessage.Pattern{Shared: map[string][]string{"singleton": []string{"app"}}}
+
+	if _, err := w.ResourcesCreate("ag1", "", nil, deployment, []byte("{}"), fakeEnvironment(), nil, nil, "myorg/https://myorg/app", "1.0"); err == nil {
+		t.Errorf("expected an error for volumes of a shared service")
+	}
+	checkNames(t, "containers", fake.ContainerNames())
+}
+
+func Test_volumeBackupRestore(t *testing.T) {
+
+	dir, db, fake, w := fakeWorkerSetup(t)
+	defer cleanFakeWorker(dir, db)
+
+	fake.AddImage("myorg/app:1.0")
+
+	volume := containermessage.NamedVolume{Name: "data", MountPath: "/data"}
+	if _, err := w.ResourcesCreate("ag1", "", nil, volumeDeployment(volume), []byte("{}"), fakeEnvironment(), nil, nil, "myorg/https://myorg/app", "1.0"); err != nil {
+		t.Fatalf("unexpected error creating resources, %v", err)
+	}
+	fake.WriteVolumeFile("data", "db", []byte("v1"))
+
+	// the volumes of the service are backed up before it is upgraded
+	cvs, err := persistence.FindContainerVolumes(db, []persistence.ContainerVolumeFilter{persistence.UnarchivedCVFilter(), persistence.DeclaredCVFilter(), persistence.ServiceCVFilter("myorg/https://myorg/app")})
+	if err != nil || len(cvs) != 1 {
+		t.Fatalf("expected the volume of the service, got %v, error %v", cvs, err)
+	} else if err := backupServiceVolumes(fake, db, w.Config, cvs, "1.0"); err != nil {
+		t.Fatalf("unexpected error backing up the volumes, %v", err)
+	}
+	checkNames(t, "containers", fake.ContainerNames(), "ag1-app")
+
+	backups, err := persistence.FindVolumeBackups(db, []persistence.VolumeBackupFilter{persistence.VolumeNameVBFilter("data")})
+	if err != nil || len(backups) != 1 {
+		t.Fatalf("expected one backup, got %v, error %v", backups, err)
+	} else if backups[0].Reason != persistence.VOLUME_BACKUP_UPGRADE || backups[0].ServiceVersion != "1.0" {
+		t.Errorf("unexpected backup %v", backups[0])
+	} else if info, err := os.Stat(backups[0].File); err != nil || info.Size() != backups[0].Size {
+		t.Errorf("the backup file should exist with its size, error %v", err)
+	}
+
+	// a volume in use cannot be restored
+	fake.WriteVolumeFile("data", "db", []byte("v2"))
+	fake.WriteVolumeFile("data", "new", []byte("v2"))
+	cv, _ := persistence.FindUndeletedContainerVolume(db, "data")
+	if err := restoreVolume(fake, db, cv, &backups[0]); err == nil {
+		t.Errorf("expected an error restoring a volume in use")
+	}
+
+	// the service is rolled back, so the volume gets the content of the backup when the service is started again
+	if err := w.ResourcesRemove([]string{"ag1"}); err != nil {
+		t.Fatalf("unexpected error removing resources, %v", err)
+	}
+	checkNames(t, "volumes", fake.VolumeNames())
+
+	if err := MarkServiceVolumesForRestore(db, "myorg/https://myorg/app", "1.0"); err != nil {
+		t.Fatalf("unexpected error marking the volumes, %v", err)
+	}
+
+	// the service is not started while the restore fails, and the volume keeps waiting for it
+	if err := os.Rename(backups[0].File, backups[0].File+".moved"); err != nil {
+		t.Fatalf("unable to move the backup file, %v", err)
+	} else if _, err := w.ResourcesCreate("ag2", "", nil, volumeDeployment(volume), []byte("{}"), fakeEnvironment(), nil, nil, "myorg/https://myorg/app", "1.0"); err == nil {
+		t.Errorf("expected an error creating resources when the restore fails")
+	} else if cv, _ := persistence.FindUndeletedContainerVolume(db, "data"); cv == nil || cv.RestoreBackup != backups[0].RecordId {
+		t.Errorf("the volume should still wait for the restore, got %v", cv)
+	} else if err := os.Rename(backups[0].File+".moved", backups[0].File); err != nil {
+		t.Fatalf("unable to move the backup file back, %v", err)
+	}
+	checkNames(t, "containers", fake.ContainerNames())
+
+	if _, err := w.ResourcesCreate("ag2", "", nil, volumeDeployment(volume), []byte("{}"), fakeEnvironment(), nil, nil, "myorg/https://myorg/app", "1.0"); err != nil {
+		t.Fatalf("unexpected error creating resources, %v", err)
+	}
+
+	if files := fake.VolumeFiles("data"); len(files) != 1 || string(files["db"]) != "v1" {
+		t.Errorf("the volume should have the content of the backup, got %v", files)
+	}
+	checkNames(t, "containers", fake.ContainerNames(), "ag2-app")
+	if cvs, err := persistence.FindContainerVolumes(db, []persistence.ContainerVolumeFilter{persistence.UnarchivedCVFilter()}); err != nil || len(cvs) != 1 {
+		t.Errorf("expected one volume record, got %v, error %v", cvs, err)
+	} else if cvs[0].RestoreBackup != "" || cvs[0].InstanceKey != "ag2" {
+		t.Errorf("unexpected volume record %v", cvs[0])
+	}
+
+	// only the latest backups are kept
+	w.Config.Edge.VolumeBackupsKept = 1
+	cv, _ = persistence.FindUndeletedContainerVolume(db, "data")
+	if _, err := backupVolume(fake, db, w.Config, cv, persistence.VOLUME_BACKUP_MANUAL); err != nil {
+		t.Fatalf("unexpected error backing up the volume, %v", err)
+	} else if backups, _ := persistence.FindVolumeBackups(db, []persistence.VolumeBackupFilter{persistence.VolumeNameVBFilter("data")}); len(backups) != 1 || backups[0].Reason != persistence.VOLUME_BACKUP_MANUAL {
+		t.Errorf("expected only the manual backup, got %v", backups)
+	} else if _, err := os.Stat(backups[0].File); err != nil {
+		t.Errorf("the kept backup file should exist, %v", err)
+	}
+
+	if logs, err := persistence.FindAllEventLogs(db); err != nil {
+		t.Errorf("unable to read the event log, %v", err)
+	} else {
+		codes := []string{}
+		for _, l := range logs {
+			if l.EventCode != persistence.EC_VOLUME_REMOVED {
+				codes = append(codes, l.EventCode)
+			}
+		}
+		checkNames(t, "event codes", codes, persistence.EC_VOLUME_BACKED_UP, persistence.EC_ERROR_VOLUME_RESTORE, persistence.EC_ERROR_VOLUME_RESTORE, persistence.EC_VOLUME_RESTORED, persistence.EC_VOLUME_BACKED_UP)
+	}
+}
+
+func Test_MarkServiceVolumesForRestore_noBackups(t *testing.T) {
+
+	dir, db, _, w := fakeWorkerSetup(t)
+	defer cleanFakeWorker(dir, db)
+
+	if err := MarkServiceVolumesForRestore(db, "myorg/https://myorg/app", "1.0"); err != nil {
+		t.Errorf("unexpected error, %v", err)
+	} else if cvs, _ := persistence.FindContainerVolumes(db, nil); len(cvs) != 0 {
+		t.Errorf("expected no volume records, got %v", cvs)
+	}
+
+	if _, err := RestoreNamedVolume(db, w.Config, "data", ""); err == nil {
+		t.Errorf("expected an error restoring an unknown volume")
+	} else if _, err := BackupNamedVolume(db, &config.HorizonConfig{}, "data"); err == nil {
+		t.Errorf("expected an error backing up an unknown volume")
+	}
+}
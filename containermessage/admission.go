@@ -0,0 +1,233 @@
+package containermessage
+
+import (
+	"fmt"
+	"github.com/open-horizon/anax/config"
+	"github.com/open-horizon/anax/cutil"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// The names of the admission policy rules, used to tell the user which rule blocked a deployment.
+const (
+	ADMISSION_RULE_REGISTRY     = "AllowedRegistries"
+	ADMISSION_RULE_PRIVILEGED   = "ForbidPrivileged"
+	ADMISSION_RULE_HOST_NETWORK = "ForbidHostNetwork"
+	ADMISSION_RULE_DEVICES      = "ForbidDevices"
+	ADMISSION_RULE_BIND_PATHS   = "AllowedBindPaths"
+	ADMISSION_RULE_MAX_MEMORY   = "MaxMemoryMB"
+	ADMISSION_RULE_UNCONFINED   = "ForbidUnconfined"
+	ADMISSION_RULE_CAP_ADD      = "ForbidCapAdd"
+)
+
+// Returned when a deployment breaks a rule of the node's admission policy.
+type AdmissionError struct {
+	Service string
+	Rule    string
+	Reason  string
+}
+
+func (e *AdmissionError) Error() string {
+	return fmt.Sprintf("service %v is rejected by the node admission policy rule %v: %v", e.Service, e.Rule, e.Reason)
+}
+
+// Verify that all services in the deployment follow the node's admission policy. Bind mounts of the agentPaths (and
+// their sub-directories) are always allowed because the agent adds them itself. The services are checked in name
+// order so that the same deployment always reports the same violation. The deployment overrides, if there are any, are
+// applied to the services before they are checked.
+func (d *DeploymentDescription) CheckAdmission(ap *config.AdmissionPolicy, agentPaths ...string) error {
+	if ap == nil || !ap.IsConfigured() {
+		return nil
+	}
+
+	names := make([]string, 0, len(d.Services))
+	for name := range d.Services {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		if err := d.Services[name].withOverride(d.Overrides[name]).checkAdmission(name, ap, agentPaths); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *Service) checkAdmission(name string, ap *config.AdmissionPolicy, agentPaths []string) error {
+	reject := func(rule string, reason string) error {
+		return &AdmissionError{Service: name, Rule: rule, Reason: reason}
+	}
+
+	if err := CheckRegistryAdmission(ap, name, "image "+s.Image, imageRepository(s.Image)); err != nil {
+		return err
+	}
+
+	if ap.ForbidPrivileged && s.Privileged {
+		return reject(ADMISSION_RULE_PRIVILEGED, "the service runs privileged")
+	}
+
+	if ap.ForbidHostNetwork && s.Network == "host" {
+		return reject(ADMISSION_RULE_HOST_NETWORK, "the service uses the host network")
+	}
+
+	if ap.ForbidDevices && len(s.Devices) != 0 {
+		return reject(ADMISSION_RULE_DEVICES, fmt.Sprintf("the service maps host devices %v", s.Devices))
+	}
+
+	for _, bind := range s.Binds {
+		hostPath := strings.Split(bind, ":")[0]
+
+		// A bind source that is not a path is a named volume.
+		if !filepath.IsAbs(hostPath) {
+			continue
+		} else if err := CheckHostPathAdmission(ap, name, hostPath, agentPaths...); err != nil {
+			return err
+		}
+	}
+
+	if ap.ForbidUnconfined && s.SeccompProfile == PROFILE_UNCONFINED {
+		return reject(ADMISSION_RULE_UNCONFINED, "the service turns off seccomp confinement")
+	} else if ap.ForbidUnconfined && s.AppArmorProfile == PROFILE_UNCONFINED {
+		return reject(ADMISSION_RULE_UNCONFINED, "the service turns off AppArmor confinement")
+	}
+
+	if ap.ForbidCapAdd {
+		for _, c := range s.CapAdd {
+			allowed := false
+			for _, a := range ap.AllowedCapabilities {
+				if !strings.EqualFold(c, "ALL") && normalizeCapability(a) == normalizeCapability(c) {
+					allowed = true
+					break
+				}
+			}
+			if !allowed {
+				return reject(ADMISSION_RULE_CAP_ADD, fmt.Sprintf("the service adds capability %v, the node allows only %v", c, ap.AllowedCapabilities))
+			}
+		}
+	}
+
+	return CheckMemoryAdmission(ap, name, "max_memory_mb", s.MaxMemoryMB)
+}
+
+// Returns a copy of the service with the fields that the admission policy checks replaced by the ones set in the
+// override.
+func (s *Service) withOverride(o *Service) *Service {
+	if o == nil {
+		return s
+	}
+
+	merged := *s
+	if o.Image != "" {
+		merged.Image = o.Image
+	}
+	merged.Privileged = s.Privileged || o.Privileged
+	if o.Network != "" {
+		merged.Network = o.Network
+	}
+	merged.Devices = append(append([]string{}, s.Devices...), o.Devices...)
+	merged.Binds = append(append([]string{}, s.Binds...), o.Binds...)
+	merged.CapAdd = append(append([]string{}, s.CapAdd...), o.CapAdd...)
+	if o.MaxMemoryMB != 0 {
+		merged.MaxMemoryMB = o.MaxMemoryMB
+	}
+	if o.SeccompProfile != "" {
+		merged.SeccompProfile = o.SeccompProfile
+	}
+	if o.AppArmorProfile != "" {
+		merged.AppArmorProfile = o.AppArmorProfile
+	}
+	return &merged
+}
+
+// Verify that the source of the code of a service, e.g. the repository of its image, is one of the allowed registries.
+func CheckRegistryAdmission(ap *config.AdmissionPolicy, name string, artifact string, repository string) error {
+	if ap == nil || len(ap.AllowedRegistries) == 0 {
+		return nil
+	}
+
+	allowed := make([]string, 0, len(ap.AllowedRegistries))
+	for _, r := range ap.AllowedRegistries {
+		allowed = append(allowed, normalizeRegistry(r))
+	}
+	if !matchesPathPrefix(repository, allowed) {
+		return &AdmissionError{Service: name, Rule: ADMISSION_RULE_REGISTRY, Reason: fmt.Sprintf("%v is not from one of the allowed registries %v", artifact, ap.AllowedRegistries)}
+	}
+	return nil
+}
+
+// Verify that a host path that a service is given access to, e.g. by a bind mount, is allowed by the admission
+// policy. The agentPaths (and their sub-directories) are always allowed because the agent adds them itself.
+func CheckHostPathAdmission(ap *config.AdmissionPolicy, name string, hostPath string, agentPaths ...string) error {
+	if ap == nil || len(ap.AllowedBindPaths) == 0 {
+		return nil
+	}
+
+	allowed := append([]string{}, ap.AllowedBindPaths...)
+	for _, p := range agentPaths {
+		if p != "" {
+			allowed = append(allowed, p)
+		}
+	}
+	if !matchesPathPrefix(filepath.Clean(hostPath), allowed) {
+		return &AdmissionError{Service: name, Rule: ADMISSION_RULE_BIND_PATHS, Reason: fmt.Sprintf("host path %v is not in one of the allowed bind paths %v", hostPath, ap.AllowedBindPaths)}
+	}
+	return nil
+}
+
+// Verify that a memory limit follows the admission policy. A limit of zero means that the service has no limit.
+func CheckMemoryAdmission(ap *config.AdmissionPolicy, name string, field string, limitMB int64) error {
+	if ap == nil || ap.MaxMemoryMB == 0 {
+		return nil
+	} else if limitMB == 0 {
+		return &AdmissionError{Service: name, Rule: ADMISSION_RULE_MAX_MEMORY, Reason: fmt.Sprintf("the service does not declare %v, the node requires a limit of at most %v MB", field, ap.MaxMemoryMB)}
+	} else if limitMB > ap.MaxMemoryMB {
+		return &AdmissionError{Service: name, Rule: ADMISSION_RULE_MAX_MEMORY, Reason: fmt.Sprintf("the service memory limit of %v MB is larger than %v MB", limitMB, ap.MaxMemoryMB)}
+	}
+	return nil
+}
+
+// The names under which docker hub is known. Images from docker hub are compared as docker.io/<path>.
+var dockerHubDomains = []string{"docker.io", "index.docker.io", "registry-1.docker.io"}
+
+// Returns the registry qualified repository of an image, e.g. docker.io/library/ubuntu for ubuntu:18.04 and for
+// docker.io/ubuntu:18.04.
+func imageRepository(image string) string {
+	domain, path, _, _ := cutil.ParseDockerImagePath(image)
+	if domain == "" || isDockerHub(domain) {
+		domain = "docker.io"
+		if !strings.Contains(path, "/") {
+			path = "library/" + path
+		}
+	}
+	return domain + "/" + path
+}
+
+// Returns an allowed registry with the docker hub domain in the same form as imageRepository returns it.
+func normalizeRegistry(registry string) string {
+	parts := strings.SplitN(registry, "/", 2)
+	if isDockerHub(parts[0]) {
+		parts[0] = "docker.io"
+	}
+	return strings.Join(parts, "/")
+}
+
+func isDockerHub(domain string) bool {
+	for _, d := range dockerHubDomains {
+		if strings.EqualFold(domain, d) {
+			return true
+		}
+	}
+	return false
+}
+
+func matchesPathPrefix(path string, prefixes []string) bool {
+	for _, p := range prefixes {
+		p = strings.TrimSuffix(p, "/")
+		if path == p || strings.HasPrefix(path, p+"/") || p == "" {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,108 @@
+// +build unit
+
+package containermessage
+
+import (
+	"github.com/open-horizon/anax/config"
+	"testing"
+)
+
+func Test_CheckAdmission(t *testing.T) {
+
+	ap := &config.AdmissionPolicy{
+		AllowedRegistries:   []string{"registry.example.com/edge", "docker.io/library"},
+		ForbidPrivileged:    true,
+		ForbidHostNetwork:   true,
+		ForbidDevices:       true,
+		AllowedBindPaths:    []string{"/var/data"},
+		MaxMemoryMB:         512,
+		ForbidUnconfined:    true,
+		ForbidCapAdd:        true,
+		AllowedCapabilities: []string{"NET_BIND_SERVICE"},
+	}
+
+	good := &DeploymentDescription{Services: map[string]*Service{
+		"s1": {Image: "registry.example.com/edge/s1:1.0", Binds: []string{"/var/data/s1:/data:ro", "myvolume:/vol", "/var/horizon/service_storage/ag1:/service_config:rw"}, MaxMemoryMB: 256},
+		"s2": {Image: "busybox:latest", MaxMemoryMB: 512, CapAdd: []string{"cap_net_bind_service"}, SeccompProfile: "/etc/seccomp/s2.json"},
+	}}
+	if err := good.CheckAdmission(ap, "/var/horizon/service_storage"); err != nil {
+		t.Errorf("deployment should be admitted, error %v", err)
+	}
+
+	bad := map[string]*Service{
+		ADMISSION_RULE_REGISTRY:     {Image: "registry.example.com/other/s1:1.0", MaxMemoryMB: 256},
+		ADMISSION_RULE_PRIVILEGED:   {Image: "busybox", Privileged: true, MaxMemoryMB: 256},
+		ADMISSION_RULE_HOST_NETWORK: {Image: "busybox", Network: "host", MaxMemoryMB: 256},
+		ADMISSION_RULE_DEVICES:      {Image: "busybox", Devices: []string{"/dev/ttyUSB0:/dev/ttyUSB0"}, MaxMemoryMB: 256},
+		ADMISSION_RULE_BIND_PATHS:   {Image: "busybox", Binds: []string{"/var/database:/data"}, MaxMemoryMB: 256},
+		ADMISSION_RULE_MAX_MEMORY:   {Image: "busybox"},
+		ADMISSION_RULE_UNCONFINED:   {Image: "busybox", AppArmorProfile: PROFILE_UNCONFINED, MaxMemoryMB: 256},
+		ADMISSION_RULE_CAP_ADD:      {Image: "busybox", CapAdd: []string{"NET_BIND_SERVICE", "SYS_ADMIN"}, MaxMemoryMB: 256},
+	}
+	for rule, service := range bad {
+		dd := &DeploymentDescription{Services: map[string]*Service{"s1": service}}
+		if err := dd.CheckAdmission(ap); err == nil {
+			t.Errorf("service %v should be rejected by rule %v", *service, rule)
+		} else if aerr, ok := err.(*AdmissionError); !ok || aerr.Rule != rule {
+			t.Errorf("service %v should be rejected by rule %v, error %v", *service, rule, err)
+		}
+	}
+
+	if err := (&DeploymentDescription{Services: map[string]*Service{"s1": bad[ADMISSION_RULE_PRIVILEGED]}}).CheckAdmission(&config.AdmissionPolicy{}); err != nil {
+		t.Errorf("an empty admission policy should admit everything, error %v", err)
+	}
+}
+
+func Test_CheckAdmission_dockerHub(t *testing.T) {
+
+	ap := &config.AdmissionPolicy{AllowedRegistries: []string{"index.docker.io/library", "docker.io/myorg"}}
+
+	for _, image := range []string{"ubuntu:18.04", "docker.io/ubuntu:18.04", "index.docker.io/ubuntu", "registry-1.docker.io/library/ubuntu", "myorg/s1:1.0", "docker.io/myorg/s1"} {
+		dd := &DeploymentDescription{Services: map[string]*Service{"s1": {Image: image}}}
+		if err := dd.CheckAdmission(ap); err != nil {
+			t.Errorf("image %v should be admitted, error %v", image, err)
+		}
+	}
+
+	for _, image := range []string{"otherorg/s1", "docker.io/otherorg/s1", "registry.example.com/library/ubuntu", "registry.example.com/ubuntu"} {
+		dd := &DeploymentDescription{Services: map[string]*Service{"s1": {Image: image}}}
+		if err := dd.CheckAdmission(ap); err == nil {
+			t.Errorf("image %v should be rejected", image)
+		}
+	}
+}
+
+func Test_CheckAdmission_overrides(t *testing.T) {
+
+	ap := &config.AdmissionPolicy{
+		AllowedRegistries: []string{"registry.example.com/edge"},
+		ForbidPrivileged:  true,
+		AllowedBindPaths:  []string{"/var/data"},
+	}
+
+	dd := &DeploymentDescription{
+		Services:  map[string]*Service{"s1": {Image: "registry.example.com/edge/s1:1.0", Binds: []string{"/var/data/s1:/data"}}},
+		Overrides: map[string]*Service{"s1": {Environment: []string{"FOO=bar"}}},
+	}
+	if err := dd.CheckAdmission(ap); err != nil {
+		t.Errorf("deployment should be admitted, error %v", err)
+	}
+
+	overrides := map[string]*Service{
+		ADMISSION_RULE_REGISTRY:   {Image: "registry.example.com/other/s1:1.0"},
+		ADMISSION_RULE_PRIVILEGED: {Privileged: true},
+		ADMISSION_RULE_BIND_PATHS: {Binds: []string{"/etc:/host_etc"}},
+	}
+	for rule, o := range overrides {
+		dd.Overrides = map[string]*Service{"s1": o}
+		if err := dd.CheckAdmission(ap); err == nil {
+			t.Errorf("override %v should be rejected by rule %v", *o, rule)
+		} else if aerr, ok := err.(*AdmissionError); !ok || aerr.Rule != rule {
+			t.Errorf("override %v should be rejected by rule %v, error %v", *o, rule, err)
+		}
+	}
+
+	if len(dd.Services["s1"].Binds) != 1 {
+		t.Errorf("the overrides should not change the deployment, binds %v", dd.Services["s1"].Binds)
+	}
+}
@@ -0,0 +1,128 @@
+package containermessage
+
+import (
+	"fmt"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// What the agent does to the container when it delivers a new version of a model management object.
+const (
+	MMS_ON_UPDATE_NONE    = "none"
+	MMS_ON_UPDATE_RESTART = "restart"
+	MMS_ON_UPDATE_SIGNAL  = "signal"
+)
+
+// The directory in the container that holds the delivered objects of a type, unless the deployment sets another one.
+const MMS_OBJECTS_MOUNT = "/mms"
+
+// The signal that is sent to the container when it asks to be signalled without naming a signal.
+const MMS_DEFAULT_SIGNAL = "SIGHUP"
+
+// The signals that a container can ask for. They tell a process to reload its files without stopping it.
+var mmsSignals = []string{"SIGHUP", "SIGUSR1", "SIGUSR2"}
+
+// A model management object that the agent downloads from the sync service of the node and delivers into the
+// container as a read-only file named after the object id. A new version of the object replaces the file atomically,
+// so the container never reads a partial object.
+type MMSObject struct {
+	ObjectType string `json:"object_type"`
+	ObjectID   string `json:"object_id,omitempty"`  // When omitted, every object of the type that is sent to the node is delivered.
+	MountPath  string `json:"mount_path,omitempty"` // The directory in the container that holds the files. The default is /mms/<object_type>.
+	OnUpdate   string `json:"on_update,omitempty"`  // What is done to the container when a new version is delivered: none (the default), restart or signal.
+	Signal     string `json:"signal,omitempty"`     // The signal that is sent when on_update is signal: SIGHUP (the default), SIGUSR1 or SIGUSR2.
+}
+
+func (o MMSObject) String() string {
+	return fmt.Sprintf("ObjectType: %v, ObjectID: %v, MountPath: %v, OnUpdate: %v, Signal: %v", o.ObjectType, o.ObjectID, o.MountPath, o.OnUpdate, o.Signal)
+}
+
+func (o MMSObject) GetMountPath() string {
+	if o.MountPath == "" {
+		return path.Join(MMS_OBJECTS_MOUNT, o.ObjectType)
+	}
+	return o.MountPath
+}
+
+func (o MMSObject) GetOnUpdate() string {
+	if o.OnUpdate == "" {
+		return MMS_ON_UPDATE_NONE
+	}
+	return o.OnUpdate
+}
+
+func (o MMSObject) GetSignal() string {
+	if o.Signal == "" {
+		return MMS_DEFAULT_SIGNAL
+	}
+	return strings.ToUpper(o.Signal)
+}
+
+// Returns true when the string can be used as the name of a file, and so as the name of a directory in the container.
+func isFileName(s string) bool {
+	return s != "" && s != "." && s != ".." && filepath.Base(s) == s
+}
+
+func (o MMSObject) Validate() error {
+	if !isFileName(o.ObjectType) {
+		return fmt.Errorf("mms object_type %v must be a non-empty name without a /", o.ObjectType)
+	} else if o.ObjectID != "" && !isFileName(o.ObjectID) {
+		return fmt.Errorf("mms object_id %v must be a name without a /", o.ObjectID)
+	} else if mp := o.GetMountPath(); !path.IsAbs(mp) || path.Clean(mp) != mp || mp == "/" {
+		return fmt.Errorf("mms mount_path %v must be a clean absolute path other than /", mp)
+	} else if strings.ContainsAny(mp, ":,") {
+		return fmt.Errorf("mms mount_path %v must not contain : or ,", mp)
+	}
+
+	switch o.GetOnUpdate() {
+	case MMS_ON_UPDATE_NONE, MMS_ON_UPDATE_RESTART:
+		if o.Signal != "" {
+			return fmt.Errorf("mms signal %v is only used when on_update is %v", o.Signal, MMS_ON_UPDATE_SIGNAL)
+		}
+	case MMS_ON_UPDATE_SIGNAL:
+		found := false
+		for _, s := range mmsSignals {
+			if o.GetSignal() == s {
+				found = true
+			}
+		}
+		if !found {
+			return fmt.Errorf("mms signal %v must be one of %v", o.Signal, mmsSignals)
+		}
+	default:
+		return fmt.Errorf("mms on_update %v must be %v, %v or %v", o.OnUpdate, MMS_ON_UPDATE_NONE, MMS_ON_UPDATE_RESTART, MMS_ON_UPDATE_SIGNAL)
+	}
+	return nil
+}
+
+// Verify the model management objects of the service. The files of the objects that share a mount path are named
+// after the object ids, so those objects must be of the same type, must not be delivered twice and must ask for the
+// same action on update.
+func (s *Service) ValidateMMSObjects() error {
+	byMount := make(map[string][]MMSObject)
+	for _, o := range s.MMSObjects {
+		if err := o.Validate(); err != nil {
+			return err
+		}
+
+		mp := o.GetMountPath()
+		for _, other := range byMount[mp] {
+			if other.ObjectType != o.ObjectType {
+				return fmt.Errorf("mms objects of types %v and %v cannot share mount_path %v", other.ObjectType, o.ObjectType, mp)
+			} else if other.ObjectID == "" || o.ObjectID == "" || other.ObjectID == o.ObjectID {
+				return fmt.Errorf("mms object %v/%v is delivered more than once into mount_path %v", o.ObjectType, o.ObjectID, mp)
+			} else if other.GetOnUpdate() != o.GetOnUpdate() || (o.GetOnUpdate() == MMS_ON_UPDATE_SIGNAL && other.GetSignal() != o.GetSignal()) {
+				return fmt.Errorf("mms objects in mount_path %v must have the same on_update and signal", mp)
+			}
+		}
+		byMount[mp] = append(byMount[mp], o)
+
+		for _, bind := range s.Binds {
+			if parts := strings.Split(bind, ":"); len(parts) > 1 && path.Clean(parts[1]) == mp {
+				return fmt.Errorf("mms mount_path %v is also bound by %v", mp, bind)
+			}
+		}
+	}
+	return nil
+}
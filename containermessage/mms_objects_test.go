@@ -0,0 +1,80 @@
+// +build unit
+
+package containermessage
+
+import (
+	"testing"
+)
+
+func Test_MMSObject_Validate(t *testing.T) {
+
+	valid := []MMSObject{
+		{ObjectType: "model"},
+		{ObjectType: "model", ObjectID: "weights.bin", MountPath: "/opt/model"},
+		{ObjectType: "model", OnUpdate: MMS_ON_UPDATE_RESTART},
+		{ObjectType: "model", OnUpdate: MMS_ON_UPDATE_SIGNAL},
+		{ObjectType: "model", OnUpdate: MMS_ON_UPDATE_SIGNAL, Signal: "sigusr1"},
+	}
+	for _, o := range valid {
+		if err := o.Validate(); err != nil {
+			t.Errorf("object %v should be valid, but got error %v", o, err)
+		}
+	}
+
+	invalid := []MMSObject{
+		{},
+		{ObjectType: "a/b"},
+		{ObjectType: "model", ObjectID: ".."},
+		{ObjectType: "model", MountPath: "relative"},
+		{ObjectType: "model", MountPath: "/"},
+		{ObjectType: "model", MountPath: "/opt/../model"},
+		{ObjectType: "model", MountPath: "/opt:rw"},
+		{ObjectType: "model", OnUpdate: "reload"},
+		{ObjectType: "model", Signal: "SIGHUP"},
+		{ObjectType: "model", OnUpdate: MMS_ON_UPDATE_SIGNAL, Signal: "SIGKILL"},
+	}
+	for _, o := range invalid {
+		if err := o.Validate(); err == nil {
+			t.Errorf("object %v should be invalid", o)
+		}
+	}
+}
+
+func Test_MMSObject_defaults(t *testing.T) {
+
+	o := MMSObject{ObjectType: "model"}
+	if o.GetMountPath() != "/mms/model" {
+		t.Errorf("expected the default mount path, got %v", o.GetMountPath())
+	} else if o.GetOnUpdate() != MMS_ON_UPDATE_NONE {
+		t.Errorf("expected the default on update, got %v", o.GetOnUpdate())
+	} else if o.GetSignal() != MMS_DEFAULT_SIGNAL {
+		t.Errorf("expected the default signal, got %v", o.GetSignal())
+	}
+}
+
+func Test_Service_ValidateMMSObjects(t *testing.T) {
+
+	valid := []Service{
+		{MMSObjects: []MMSObject{{ObjectType: "model"}, {ObjectType: "config"}}},
+		{MMSObjects: []MMSObject{{ObjectType: "model", ObjectID: "a"}, {ObjectType: "model", ObjectID: "b"}}},
+		{MMSObjects: []MMSObject{{ObjectType: "model"}}, Binds: []string{"/var/data:/data"}},
+	}
+	for _, s := range valid {
+		if err := s.ValidateMMSObjects(); err != nil {
+			t.Errorf("objects %v should be valid, but got error %v", s.MMSObjects, err)
+		}
+	}
+
+	invalid := []Service{
+		{MMSObjects: []MMSObject{{ObjectType: "model", MountPath: "/data"}, {ObjectType: "config", MountPath: "/data"}}},
+		{MMSObjects: []MMSObject{{ObjectType: "model"}, {ObjectType: "model", ObjectID: "a"}}},
+		{MMSObjects: []MMSObject{{ObjectType: "model", ObjectID: "a"}, {ObjectType: "model", ObjectID: "b", OnUpdate: MMS_ON_UPDATE_RESTART}}},
+		{MMSObjects: []MMSObject{{ObjectType: "model", MountPath: "/data"}}, Binds: []string{"/var/data:/data"}},
+		{MMSObjects: []MMSObject{{ObjectType: "model", OnUpdate: "reload"}}},
+	}
+	for _, s := range invalid {
+		if err := s.ValidateMMSObjects(); err == nil {
+			t.Errorf("objects %v should be invalid", s.MMSObjects)
+		}
+	}
+}
@@ -49,6 +49,12 @@ import (
  *             "encoding": "JSON",
  *             "path": "cloudMsgBrokerHost.foo.goo"
  *           }
+ *         ],
+ *         "inbound_permit_only": [
+ *           {
+ *             "from": ["service_a"],
+ *             "ports": ["8080/tcp"]
+ *           }
  *         ]
  *       }
  *     }
@@ -151,6 +157,16 @@ type Service struct {
 	Ports            []docker.PortBinding `json:"ports,omitempty"`
 	EphemeralPorts   []Port               `json:"ephemeral_ports,omitempty"`
 	SpecificPorts    []docker.PortBinding `json:"specific_ports,omitempty"` // obselete. for backward compatibility only, new way should use ports instead.
+	MaxMemoryMB      int64                `json:"max_memory_mb,omitempty"`  // The memory limit of the container. The default is the memory of the node.
+	CapDrop          []string             `json:"cap_drop,omitempty"`
+	ReadOnlyRootfs   bool                 `json:"read_only_rootfs,omitempty"`  // Mount the root filesystem of the container read-only.
+	NoNewPrivileges  bool                 `json:"no_new_privileges,omitempty"` // Prevent the container processes from gaining privileges, e.g. through setuid binaries.
+	User             string               `json:"user,omitempty"`              // The user, and optionally the group, to run as, in the form user[:group]. Names or numeric ids.
+	SeccompProfile   string               `json:"seccomp_profile,omitempty"`   // "unconfined" or the absolute path of a seccomp profile on the node. The default is the docker default profile.
+	AppArmorProfile  string               `json:"apparmor_profile,omitempty"`  // The name of an AppArmor profile loaded on the node, or "unconfined". The default is the docker default profile.
+	Readiness        *ReadinessProbe      `json:"readiness,omitempty"`         // Tells when the service is ready for the services that depend on it. The default is ready once started.
+	MMSObjects       []MMSObject          `json:"mms_objects,omitempty"`       // Model management objects that the agent delivers into the container as read-only files.
+	Volumes          []NamedVolume        `json:"volumes,omitempty"`           // Docker volumes whose lifecycle is managed by the agent, e.g. kept across agreements and backed up before upgrades.
 }
 
 func (s *Service) AddFilesystemBinding(bind string) {
@@ -252,15 +268,28 @@ const (
 
 type OutboundPermitValue interface{}
 
+// Allows inbound connections to a service from the listed sources on the listed ports. A source is the name of a
+// service, as it appears in the services section of a deployment, or an IP address or CIDR.
+type InboundPermit struct {
+	From  []string `json:"from,omitempty"`  // Any source on a network shared with the service when omitted.
+	Ports []string `json:"ports,omitempty"` // In the form port[/protocol], the protocol defaults to tcp. All ports when omitted.
+}
+
+func (i InboundPermit) String() string {
+	return fmt.Sprintf("From: %v, Ports: %v", i.From, i.Ports)
+}
+
 type NetworkIsolation struct {
 	OutboundPermitOnlyIgnore OutboundPermitOnlyIgnore `json:"outbound_permit_only_ignore"`
 	OutboundPermitOnly       []OutboundPermitValue    `json:"outbound_permit_only"`
+	InboundPermitOnly        []InboundPermit          `json:"inbound_permit_only,omitempty"` // When set, all other inbound connections to the service are rejected.
 }
 
 func (n *NetworkIsolation) UnmarshalJSON(data []byte) error {
 	type polyNType struct {
 		OutboundPermitOnlyIgnore OutboundPermitOnlyIgnore `json:"outbound_permit_only_ignore,omitempty"`
 		OutboundPermitOnly       []json.RawMessage        `json:"outbound_permit_only"`
+		InboundPermitOnly        []InboundPermit          `json:"inbound_permit_only,omitempty"`
 	}
 
 	var polyN polyNType
@@ -271,6 +300,7 @@ func (n *NetworkIsolation) UnmarshalJSON(data []byte) error {
 	}
 
 	n.OutboundPermitOnlyIgnore = polyN.OutboundPermitOnlyIgnore
+	n.InboundPermitOnly = polyN.InboundPermitOnly
 
 	// dumb way you have to handle polymorphic types in golang
 	for _, permit := range polyN.OutboundPermitOnly {
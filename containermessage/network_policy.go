@@ -0,0 +1,54 @@
+package containermessage
+
+import (
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+)
+
+// Verify the inbound rules of the network isolation.
+func (n *NetworkIsolation) Validate() error {
+	for _, permit := range n.InboundPermitOnly {
+		for _, from := range permit.From {
+			if strings.TrimSpace(from) == "" {
+				return fmt.Errorf("inbound_permit_only %v has an empty source", permit)
+			}
+		}
+		for _, p := range permit.Ports {
+			if _, _, err := ParseInboundPort(p); err != nil {
+				return fmt.Errorf("inbound_permit_only %v has an invalid port, %v", permit, err)
+			}
+		}
+	}
+	return nil
+}
+
+// Returns true if the source is an IP address or CIDR rather than the name of a service.
+func IsAddressSource(from string) bool {
+	if _, _, err := net.ParseCIDR(from); err == nil {
+		return true
+	}
+	return net.ParseIP(from) != nil
+}
+
+// Splits an inbound port of the form port[/protocol] into its port and protocol. The protocol defaults to tcp.
+func ParseInboundPort(p string) (string, string, error) {
+	parts := strings.Split(p, "/")
+	if len(parts) > 2 {
+		return "", "", fmt.Errorf("port %v must be of the form port[/protocol]", p)
+	}
+
+	if port, err := strconv.Atoi(parts[0]); err != nil || port < 1 || port > 65535 {
+		return "", "", fmt.Errorf("port %v must be a number between 1 and 65535", parts[0])
+	}
+
+	protocol := "tcp"
+	if len(parts) == 2 {
+		protocol = strings.ToLower(parts[1])
+		if protocol != "tcp" && protocol != "udp" && protocol != "sctp" {
+			return "", "", fmt.Errorf("protocol %v must be tcp, udp or sctp", parts[1])
+		}
+	}
+	return parts[0], protocol, nil
+}
@@ -0,0 +1,71 @@
+package containermessage
+
+import (
+	"fmt"
+	docker "github.com/fsouza/go-dockerclient"
+	"strings"
+	"time"
+)
+
+// The defaults of a readiness probe.
+const (
+	READINESS_INTERVAL_S = 5
+	READINESS_TIMEOUT_S  = 300
+	READINESS_RETRIES    = 3
+)
+
+// The limit on the time that a service can take to become ready.
+const READINESS_MAX_TIMEOUT_S = 3600
+
+// A probe that tells when the container of a service is ready, e.g. a database that accepts connections. Docker runs
+// the command in the container as a health check, and the container is ready once the command succeeds. The services
+// that depend on it are not started until then.
+type ReadinessProbe struct {
+	Command   []string `json:"command"`              // The command and its arguments, run in the container without a shell.
+	IntervalS int      `json:"interval_s,omitempty"` // The time between runs of the command. The default is 5 seconds.
+	TimeoutS  int      `json:"timeout_s,omitempty"`  // How long the container has to become ready. The default is 300 seconds.
+}
+
+func (r ReadinessProbe) String() string {
+	return fmt.Sprintf("Command: %v, IntervalS: %v, TimeoutS: %v", r.Command, r.IntervalS, r.TimeoutS)
+}
+
+func (r *ReadinessProbe) Validate() error {
+	if len(r.Command) == 0 || strings.TrimSpace(r.Command[0]) == "" {
+		return fmt.Errorf("readiness must have a command")
+	} else if r.IntervalS < 0 {
+		return fmt.Errorf("readiness interval_s %v must not be negative", r.IntervalS)
+	} else if r.TimeoutS < 0 || r.TimeoutS > READINESS_MAX_TIMEOUT_S {
+		return fmt.Errorf("readiness timeout_s %v must be between 0 and %v", r.TimeoutS, READINESS_MAX_TIMEOUT_S)
+	} else if r.TimeoutS != 0 && r.TimeoutS < r.GetIntervalS() {
+		return fmt.Errorf("readiness timeout_s %v must not be shorter than interval_s %v", r.TimeoutS, r.GetIntervalS())
+	}
+	return nil
+}
+
+func (r *ReadinessProbe) GetIntervalS() int {
+	if r.IntervalS == 0 {
+		return READINESS_INTERVAL_S
+	}
+	return r.IntervalS
+}
+
+func (r *ReadinessProbe) GetTimeoutS() int {
+	if r.TimeoutS == 0 {
+		return READINESS_TIMEOUT_S
+	}
+	return r.TimeoutS
+}
+
+// Returns the docker health check that runs the probe. Failures within the timeout are not counted by docker, so the
+// container only becomes unhealthy when it is not ready in time, or when it stops passing the probe after it was ready.
+func (r *ReadinessProbe) HealthConfig() *docker.HealthConfig {
+	interval := time.Duration(r.GetIntervalS()) * time.Second
+	return &docker.HealthConfig{
+		Test:        append([]string{"CMD"}, r.Command...),
+		Interval:    interval,
+		Timeout:     interval,
+		StartPeriod: time.Duration(r.GetTimeoutS()) * time.Second,
+		Retries:     READINESS_RETRIES,
+	}
+}
@@ -0,0 +1,48 @@
+// +build unit
+
+package containermessage
+
+import (
+	"testing"
+	"time"
+)
+
+func Test_ReadinessProbe_Validate(t *testing.T) {
+
+	valid := []ReadinessProbe{
+		{Command: []string{"pg_isready"}},
+		{Command: []string{"pg_isready", "-U", "postgres"}, IntervalS: 2, TimeoutS: 60},
+	}
+	for _, r := range valid {
+		if err := r.Validate(); err != nil {
+			t.Errorf("probe %v should be valid, but got error %v", r, err)
+		}
+	}
+
+	invalid := []ReadinessProbe{
+		{},
+		{Command: []string{" "}},
+		{Command: []string{"pg_isready"}, IntervalS: -1},
+		{Command: []string{"pg_isready"}, TimeoutS: READINESS_MAX_TIMEOUT_S + 1},
+		{Command: []string{"pg_isready"}, IntervalS: 30, TimeoutS: 10},
+	}
+	for _, r := range invalid {
+		if err := r.Validate(); err == nil {
+			t.Errorf("probe %v should be invalid", r)
+		}
+	}
+}
+
+func Test_ReadinessProbe_HealthConfig(t *testing.T) {
+
+	r := &ReadinessProbe{Command: []string{"pg_isready", "-U", "postgres"}}
+	hc := r.HealthConfig()
+
+	if len(hc.Test) != 4 || hc.Test[0] != "CMD" || hc.Test[1] != "pg_isready" {
+		t.Errorf("unexpected health check test %v", hc.Test)
+	} else if hc.Interval != READINESS_INTERVAL_S*time.Second || hc.StartPeriod != READINESS_TIMEOUT_S*time.Second {
+		t.Errorf("expected the default interval and timeout, got %v", hc)
+	} else if hc.Retries != READINESS_RETRIES {
+		t.Errorf("expected %v retries, got %v", READINESS_RETRIES, hc.Retries)
+	}
+}
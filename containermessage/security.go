@@ -0,0 +1,75 @@
+package containermessage
+
+import (
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// The special profile name that turns off seccomp or AppArmor confinement.
+const PROFILE_UNCONFINED = "unconfined"
+
+var capabilityRE = regexp.MustCompile(`(?i)^(CAP_)?[A-Z][A-Z0-9_]*$`)
+var userRE = regexp.MustCompile(`^[A-Za-z0-9_][A-Za-z0-9_.-]*(:[A-Za-z0-9_][A-Za-z0-9_.-]*)?$`)
+var appArmorProfileRE = regexp.MustCompile(`^[A-Za-z0-9_][A-Za-z0-9_./-]*$`)
+
+// Verify the container hardening options of the service. The capabilities in cap_add are not checked here because
+// deployments have always been allowed to pass any value there.
+func (s *Service) ValidateSecurityOptions() error {
+	for _, c := range s.CapDrop {
+		if !strings.EqualFold(c, "ALL") && !capabilityRE.MatchString(c) {
+			return fmt.Errorf("cap_drop contains %v, which is not a capability name", c)
+		}
+		for _, a := range s.CapAdd {
+			if !strings.EqualFold(a, "ALL") && normalizeCapability(a) == normalizeCapability(c) {
+				return fmt.Errorf("capability %v is in both cap_add and cap_drop", c)
+			}
+		}
+	}
+
+	if s.User != "" && !userRE.MatchString(s.User) {
+		return fmt.Errorf("user %v must be of the form user[:group]", s.User)
+	}
+
+	if s.SeccompProfile != "" && s.SeccompProfile != PROFILE_UNCONFINED && !filepath.IsAbs(s.SeccompProfile) {
+		return fmt.Errorf("seccomp_profile %v must be %v or an absolute path", s.SeccompProfile, PROFILE_UNCONFINED)
+	}
+
+	if s.AppArmorProfile != "" && !appArmorProfileRE.MatchString(s.AppArmorProfile) {
+		return fmt.Errorf("apparmor_profile %v is not a valid profile name", s.AppArmorProfile)
+	}
+
+	return nil
+}
+
+// Returns the docker security options for the service. A seccomp profile file is read from the node because docker
+// expects the content of the profile rather than its path.
+func (s *Service) SecurityOpt() ([]string, error) {
+	opts := make([]string, 0, 3)
+
+	if s.NoNewPrivileges {
+		opts = append(opts, "no-new-privileges")
+	}
+
+	if s.SeccompProfile == PROFILE_UNCONFINED {
+		opts = append(opts, "seccomp="+PROFILE_UNCONFINED)
+	} else if s.SeccompProfile != "" {
+		profile, err := ioutil.ReadFile(s.SeccompProfile)
+		if err != nil {
+			return nil, fmt.Errorf("unable to read seccomp profile %v, error %v", s.SeccompProfile, err)
+		}
+		opts = append(opts, "seccomp="+string(profile))
+	}
+
+	if s.AppArmorProfile != "" {
+		opts = append(opts, "apparmor="+s.AppArmorProfile)
+	}
+
+	return opts, nil
+}
+
+func normalizeCapability(c string) string {
+	return strings.TrimPrefix(strings.ToUpper(c), "CAP_")
+}
@@ -0,0 +1,76 @@
+// +build unit
+
+package containermessage
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func Test_ValidateSecurityOptions(t *testing.T) {
+
+	valid := []Service{
+		{},
+		{CapDrop: []string{"ALL"}, CapAdd: []string{"NET_BIND_SERVICE"}},
+		{CapDrop: []string{"CAP_SYS_ADMIN", "net_raw"}},
+		{User: "1000"},
+		{User: "appuser:appgroup"},
+		{SeccompProfile: PROFILE_UNCONFINED},
+		{SeccompProfile: "/etc/horizon/seccomp.json"},
+		{AppArmorProfile: "docker-default"},
+	}
+	for _, s := range valid {
+		if err := s.ValidateSecurityOptions(); err != nil {
+			t.Errorf("service %v should be valid, but got error %v", s, err)
+		}
+	}
+
+	invalid := []Service{
+		{CapDrop: []string{"SYS ADMIN"}},
+		{CapDrop: []string{"NET_RAW"}, CapAdd: []string{"CAP_NET_RAW"}},
+		{User: "user:"},
+		{User: "a:b:c"},
+		{SeccompProfile: "seccomp.json"},
+		{AppArmorProfile: "bad profile"},
+	}
+	for _, s := range invalid {
+		if err := s.ValidateSecurityOptions(); err == nil {
+			t.Errorf("service %v should be invalid", s)
+		}
+	}
+}
+
+func Test_SecurityOpt(t *testing.T) {
+
+	s := Service{NoNewPrivileges: true, SeccompProfile: PROFILE_UNCONFINED, AppArmorProfile: "myprofile"}
+	if opts, err := s.SecurityOpt(); err != nil {
+		t.Errorf("unexpected error %v", err)
+	} else if len(opts) != 3 || opts[0] != "no-new-privileges" || opts[1] != "seccomp=unconfined" || opts[2] != "apparmor=myprofile" {
+		t.Errorf("unexpected security options %v", opts)
+	}
+
+	dir, err := ioutil.TempDir("", "seccomp")
+	if err != nil {
+		t.Fatalf("unable to create temp dir, error %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	profile := filepath.Join(dir, "profile.json")
+	if err := ioutil.WriteFile(profile, []byte(`{"defaultAction":"SCMP_ACT_ERRNO"}`), 0600); err != nil {
+		t.Fatalf("unable to write profile, error %v", err)
+	}
+
+	s = Service{SeccompProfile: profile}
+	if opts, err := s.SecurityOpt(); err != nil {
+		t.Errorf("unexpected error %v", err)
+	} else if len(opts) != 1 || opts[0] != `seccomp={"defaultAction":"SCMP_ACT_ERRNO"}` {
+		t.Errorf("unexpected security options %v", opts)
+	}
+
+	s = Service{SeccompProfile: filepath.Join(dir, "missing.json")}
+	if _, err := s.SecurityOpt(); err == nil {
+		t.Errorf("expected an error for a missing profile")
+	}
+}
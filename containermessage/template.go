@@ -0,0 +1,67 @@
+package containermessage
+
+import (
+	"fmt"
+	"github.com/open-horizon/anax/cutil"
+)
+
+// Verify the syntax of the templates in the environment variables and the command of the service. The values that
+// the templates refer to are only known on the node.
+func (s *Service) ValidateTemplates() error {
+	for _, e := range s.Environment {
+		if err := cutil.VerifyTemplate(e); err != nil {
+			return fmt.Errorf("environment variable %v: %v", e, err)
+		}
+	}
+	for _, c := range s.Command {
+		if err := cutil.VerifyTemplate(c); err != nil {
+			return fmt.Errorf("command %v: %v", c, err)
+		}
+	}
+	return nil
+}
+
+// Expand the templates in the environment variables and the command of the services and their overrides with the
+// node and agreement values of the data.
+func (d *DeploymentDescription) ExpandTemplates(data *cutil.TemplateData) error {
+	for _, services := range []map[string]*Service{d.Services, d.Overrides} {
+		for name, s := range services {
+			if s == nil {
+				continue
+			}
+			for i, e := range s.Environment {
+				if v, err := cutil.ExpandTemplate(e, data); err != nil {
+					return fmt.Errorf("service %v environment variable: %v", name, err)
+				} else {
+					s.Environment[i] = v
+				}
+			}
+			for i, c := range s.Command {
+				if v, err := cutil.ExpandTemplate(c, data); err != nil {
+					return fmt.Errorf("service %v command: %v", name, err)
+				} else {
+					s.Command[i] = v
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// Returns the environment variables and the commands of the services and their overrides that are templates.
+func (d *DeploymentDescription) Templates() []string {
+	templates := make([]string, 0)
+	for _, services := range []map[string]*Service{d.Services, d.Overrides} {
+		for _, s := range services {
+			if s == nil {
+				continue
+			}
+			for _, v := range append(append([]string{}, s.Environment...), s.Command...) {
+				if cutil.IsTemplate(v) {
+					templates = append(templates, v)
+				}
+			}
+		}
+	}
+	return templates
+}
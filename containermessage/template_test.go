@@ -0,0 +1,49 @@
+// +build unit
+
+package containermessage
+
+import (
+	"github.com/open-horizon/anax/cutil"
+	"testing"
+)
+
+func Test_ExpandTemplates(t *testing.T) {
+
+	d := DeploymentDescription{
+		Services: map[string]*Service{
+			"s1": &Service{
+				Image:       "myimage",
+				Environment: []string{"SITE={%property \"site_id\"%}", "PLAIN=value"},
+				Command:     []string{"/bin/run", "--node={%.NodeId%}"},
+			},
+		},
+		Overrides: map[string]*Service{
+			"s1": &Service{Environment: []string{"AG={%.AgreementId%}"}},
+		},
+	}
+
+	if err := d.Services["s1"].ValidateTemplates(); err != nil {
+		t.Errorf("ValidateTemplates returned error %v", err)
+	}
+
+	data := &cutil.TemplateData{NodeId: "node1", AgreementId: "ag1", Properties: map[string]interface{}{"site_id": "s42"}}
+	if err := d.ExpandTemplates(data); err != nil {
+		t.Errorf("ExpandTemplates returned error %v", err)
+	} else if d.Services["s1"].Environment[0] != "SITE=s42" || d.Services["s1"].Environment[1] != "PLAIN=value" {
+		t.Errorf("wrong environment %v", d.Services["s1"].Environment)
+	} else if d.Services["s1"].Command[1] != "--node=node1" {
+		t.Errorf("wrong command %v", d.Services["s1"].Command)
+	} else if d.Overrides["s1"].Environment[0] != "AG=ag1" {
+		t.Errorf("wrong override environment %v", d.Overrides["s1"].Environment)
+	}
+
+	bad := Service{Environment: []string{"A={%.NodeId"}}
+	if err := bad.ValidateTemplates(); err == nil {
+		t.Errorf("ValidateTemplates should have returned an error")
+	}
+
+	missing := DeploymentDescription{Services: map[string]*Service{"s1": &Service{Environment: []string{"A={%property \"zone\"%}"}}}}
+	if err := missing.ExpandTemplates(data); err == nil {
+		t.Errorf("ExpandTemplates should have returned an error for a missing property")
+	}
+}
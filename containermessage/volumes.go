@@ -0,0 +1,105 @@
+package containermessage
+
+import (
+	"fmt"
+	"path"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// What the agent does with a named volume when the containers that use it are removed.
+const (
+	VOLUME_RETENTION_DELETE = "delete" // The volume is removed with the containers.
+	VOLUME_RETENTION_KEEP   = "keep"   // The volume is kept, even when the node is unregistered.
+)
+
+// The form of a retention that keeps the volume for a number of days after the containers that use it are removed.
+var keepDaysRetention = regexp.MustCompile(`^keep-([0-9]+)-days?$`)
+
+// The names that docker accepts for a volume.
+var volumeName = regexp.MustCompile(`^[a-zA-Z0-9][a-zA-Z0-9_.-]*$`)
+
+// A docker volume that holds data of the service that should outlive its containers. The agent creates the volume,
+// removes it according to its retention, and backs it up before the service is upgraded.
+type NamedVolume struct {
+	Name      string `json:"name"`
+	MountPath string `json:"mount_path"`
+	ReadOnly  bool   `json:"read_only,omitempty"`
+	Retention string `json:"retention,omitempty"` // delete (the default), keep, or keep-<N>-days.
+}
+
+func (v NamedVolume) String() string {
+	return fmt.Sprintf("Name: %v, MountPath: %v, ReadOnly: %v, Retention: %v", v.Name, v.MountPath, v.ReadOnly, v.Retention)
+}
+
+func (v NamedVolume) GetRetention() string {
+	if v.Retention == "" {
+		return VOLUME_RETENTION_DELETE
+	}
+	return v.Retention
+}
+
+// Returns the bind of the volume into the container.
+func (v NamedVolume) Bind() string {
+	if v.ReadOnly {
+		return fmt.Sprintf("%v:%v:ro", v.Name, v.MountPath)
+	}
+	return fmt.Sprintf("%v:%v:rw", v.Name, v.MountPath)
+}
+
+func (v NamedVolume) Validate() error {
+	if !volumeName.MatchString(v.Name) {
+		return fmt.Errorf("volume name %v must start with a letter or digit and contain only letters, digits, _, . and -", v.Name)
+	} else if mp := v.MountPath; !path.IsAbs(mp) || path.Clean(mp) != mp || mp == "/" {
+		return fmt.Errorf("volume %v mount_path %v must be a clean absolute path other than /", v.Name, mp)
+	} else if strings.ContainsAny(v.MountPath, ":,") {
+		return fmt.Errorf("volume %v mount_path %v must not contain : or ,", v.Name, v.MountPath)
+	} else if _, _, err := ParseVolumeRetention(v.GetRetention()); err != nil {
+		return fmt.Errorf("volume %v: %v", v.Name, err)
+	}
+	return nil
+}
+
+// Returns the policy of a retention, which is delete or keep, and the number of days that a kept volume is kept
+// after the containers that use it are removed. A volume that is kept without a number of days has 0 days.
+func ParseVolumeRetention(retention string) (string, int, error) {
+	switch retention {
+	case VOLUME_RETENTION_DELETE, VOLUME_RETENTION_KEEP:
+		return retention, 0, nil
+	}
+	if m := keepDaysRetention.FindStringSubmatch(retention); m != nil {
+		if days, err := strconv.Atoi(m[1]); err == nil && days > 0 {
+			return VOLUME_RETENTION_KEEP, days, nil
+		}
+	}
+	return "", 0, fmt.Errorf("retention %v must be %v, %v or keep-<N>-days with N greater than 0", retention, VOLUME_RETENTION_DELETE, VOLUME_RETENTION_KEEP)
+}
+
+// Verify the named volumes of the service. Each volume is mounted once, and not where a bind or an object directory
+// is mounted.
+func (s *Service) ValidateVolumes() error {
+	names := make(map[string]bool)
+	mounts := make(map[string]bool)
+	for _, o := range s.MMSObjects {
+		mounts[o.GetMountPath()] = true
+	}
+	for _, bind := range s.Binds {
+		if parts := strings.Split(bind, ":"); len(parts) > 1 {
+			mounts[path.Clean(parts[1])] = true
+		}
+	}
+
+	for _, v := range s.Volumes {
+		if err := v.Validate(); err != nil {
+			return err
+		} else if names[v.Name] {
+			return fmt.Errorf("volume %v is declared more than once", v.Name)
+		} else if mounts[v.MountPath] {
+			return fmt.Errorf("volume %v mount_path %v is already mounted", v.Name, v.MountPath)
+		}
+		names[v.Name] = true
+		mounts[v.MountPath] = true
+	}
+	return nil
+}
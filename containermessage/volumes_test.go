@@ -0,0 +1,110 @@
+// +build unit
+
+package containermessage
+
+import (
+	"testing"
+)
+
+func Test_NamedVolume_Validate(t *testing.T) {
+
+	valid := []NamedVolume{
+		{Name: "data", MountPath: "/data"},
+		{Name: "my.data-1_a", MountPath: "/var/lib/app", ReadOnly: true},
+		{Name: "data", MountPath: "/data", Retention: VOLUME_RETENTION_DELETE},
+		{Name: "data", MountPath: "/data", Retention: VOLUME_RETENTION_KEEP},
+		{Name: "data", MountPath: "/data", Retention: "keep-1-day"},
+		{Name: "data", MountPath: "/data", Retention: "keep-30-days"},
+	}
+	for _, v := range valid {
+		if err := v.Validate(); err != nil {
+			t.Errorf("volume %v should be valid, but got error %v", v, err)
+		}
+	}
+
+	invalid := []NamedVolume{
+		{},
+		{Name: "data"},
+		{Name: "/var/data", MountPath: "/data"},
+		{Name: "-data", MountPath: "/data"},
+		{Name: "data", MountPath: "relative"},
+		{Name: "data", MountPath: "/"},
+		{Name: "data", MountPath: "/data/../etc"},
+		{Name: "data", MountPath: "/data:ro"},
+		{Name: "data", MountPath: "/data", Retention: "forever"},
+		{Name: "data", MountPath: "/data", Retention: "keep-0-days"},
+		{Name: "data", MountPath: "/data", Retention: "keep--1-days"},
+	}
+	for _, v := range invalid {
+		if err := v.Validate(); err == nil {
+			t.Errorf("volume %v should be invalid", v)
+		}
+	}
+}
+
+func Test_ParseVolumeRetention(t *testing.T) {
+
+	tests := []struct {
+		retention string
+		policy    string
+		days      int
+	}{
+		{VOLUME_RETENTION_DELETE, VOLUME_RETENTION_DELETE, 0},
+		{VOLUME_RETENTION_KEEP, VOLUME_RETENTION_KEEP, 0},
+		{"keep-1-day", VOLUME_RETENTION_KEEP, 1},
+		{"keep-7-days", VOLUME_RETENTION_KEEP, 7},
+	}
+	for _, test := range tests {
+		if policy, days, err := ParseVolumeRetention(test.retention); err != nil {
+			t.Errorf("unexpected error parsing %v, %v", test.retention, err)
+		} else if policy != test.policy || days != test.days {
+			t.Errorf("expected %v %v for %v, got %v %v", test.policy, test.days, test.retention, policy, days)
+		}
+	}
+
+	if _, _, err := ParseVolumeRetention(""); err == nil {
+		t.Errorf("an empty retention should be invalid")
+	}
+}
+
+func Test_NamedVolume_defaults(t *testing.T) {
+
+	v := NamedVolume{Name: "data", MountPath: "/data"}
+	if v.GetRetention() != VOLUME_RETENTION_DELETE {
+		t.Errorf("expected the default retention, got %v", v.GetRetention())
+	} else if v.Bind() != "data:/data:rw" {
+		t.Errorf("unexpected bind %v", v.Bind())
+	}
+
+	v.ReadOnly = true
+	if v.Bind() != "data:/data:ro" {
+		t.Errorf("unexpected bind %v", v.Bind())
+	}
+}
+
+func Test_Service_ValidateVolumes(t *testing.T) {
+
+	valid := []Service{
+		{Volumes: []NamedVolume{{Name: "a", MountPath: "/a"}, {Name: "b", MountPath: "/b"}}},
+		{Volumes: []NamedVolume{{Name: "a", MountPath: "/a"}}, Binds: []string{"/var/data:/data"}},
+		{Volumes: []NamedVolume{{Name: "a", MountPath: "/a"}}, MMSObjects: []MMSObject{{ObjectType: "model"}}},
+	}
+	for _, s := range valid {
+		if err := s.ValidateVolumes(); err != nil {
+			t.Errorf("volumes %v should be valid, but got error %v", s.Volumes, err)
+		}
+	}
+
+	invalid := []Service{
+		{Volumes: []NamedVolume{{Name: "a"}}},
+		{Volumes: []NamedVolume{{Name: "a", MountPath: "/a"}, {Name: "a", MountPath: "/b"}}},
+		{Volumes: []NamedVolume{{Name: "a", MountPath: "/a"}, {Name: "b", MountPath: "/a"}}},
+		{Volumes: []NamedVolume{{Name: "a", MountPath: "/data"}}, Binds: []string{"/var/data:/data/:ro"}},
+		{Volumes: []NamedVolume{{Name: "a", MountPath: "/mms/model"}}, MMSObjects: []MMSObject{{ObjectType: "model"}}},
+	}
+	for _, s := range invalid {
+		if err := s.ValidateVolumes(); err == nil {
+			t.Errorf("volumes %v should be invalid", s.Volumes)
+		}
+	}
+}
@@ -0,0 +1,845 @@
+package containerruntime
+
+import (
+	"archive/tar"
+	"encoding/json"
+	"fmt"
+	docker "github.com/fsouza/go-dockerclient"
+	"io"
+	"io/ioutil"
+	"path"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// An in-memory container runtime for tests. It keeps track of images, containers, networks and volumes the way the
+// docker daemon does, without running anything, so that the workers can be tested on hosts without docker.
+type FakeRuntime struct {
+	lock       sync.Mutex
+	nextId     int
+	nextIP     map[string]int               // keyed by network id
+	images     map[string]*docker.Image     // keyed by repo tag
+	containers map[string]*docker.Container // keyed by id
+	networks   map[string]*docker.Network   // keyed by id
+	volumes    map[string]*docker.Volume    // keyed by name
+	signals    map[string][]docker.Signal   // keyed by container id
+	files      map[string]map[string][]byte // the files in each volume, keyed by volume name and path in the volume
+	listeners  []chan<- *docker.APIEvents
+}
+
+func NewFakeRuntime() *FakeRuntime {
+	return &FakeRuntime{
+		images:     make(map[string]*docker.Image),
+		containers: make(map[string]*docker.Container),
+		networks:   make(map[string]*docker.Network),
+		volumes:    make(map[string]*docker.Volume),
+		signals:    make(map[string][]docker.Signal),
+		files:      make(map[string]map[string][]byte),
+		nextIP:     make(map[string]int),
+	}
+}
+
+// Make an image available locally, as if it had been pulled.
+func (f *FakeRuntime) AddImage(name string) {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	f.addImage(name)
+}
+
+// Returns the names of the containers, without the leading slash, in no particular order.
+func (f *FakeRuntime) ContainerNames() []string {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+
+	names := make([]string, 0, len(f.containers))
+	for _, c := range f.containers {
+		names = append(names, strings.TrimPrefix(c.Name, "/"))
+	}
+	return names
+}
+
+// Returns the names of the networks in no particular order.
+func (f *FakeRuntime) NetworkNames() []string {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+
+	names := make([]string, 0, len(f.networks))
+	for _, n := range f.networks {
+		names = append(names, n.Name)
+	}
+	return names
+}
+
+// Returns the names of the volumes in no particular order.
+func (f *FakeRuntime) VolumeNames() []string {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+
+	names := make([]string, 0, len(f.volumes))
+	for name := range f.volumes {
+		names = append(names, name)
+	}
+	return names
+}
+
+// Write a file into a volume, as if a container had written it.
+func (f *FakeRuntime) WriteVolumeFile(volume string, name string, data []byte) {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+
+	if f.files[volume] == nil {
+		f.files[volume] = make(map[string][]byte)
+	}
+	f.files[volume][name] = append([]byte{}, data...)
+}
+
+// Returns the paths and the content of the files in a volume.
+func (f *FakeRuntime) VolumeFiles(volume string) map[string][]byte {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+
+	files := make(map[string][]byte)
+	for name, data := range f.files[volume] {
+		files[name] = append([]byte{}, data...)
+	}
+	return files
+}
+
+func (f *FakeRuntime) Info() (*docker.DockerInfo, error) {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+
+	return &docker.DockerInfo{
+		Containers: len(f.containers),
+		Images:     len(f.images),
+		Driver:     "fake",
+	}, nil
+}
+
+func (f *FakeRuntime) PullImage(opts docker.PullImageOptions, auth docker.AuthConfiguration) error {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+
+	if opts.Tag != "" {
+		f.addImage(opts.Repository + ":" + opts.Tag)
+	} else {
+		f.addImage(opts.Repository)
+	}
+	return nil
+}
+
+// Loads the images named in the manifest of an archive created by docker save.
+func (f *FakeRuntime) LoadImage(opts docker.LoadImageOptions) error {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+
+	tr := tar.NewReader(opts.InputStream)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			return fmt.Errorf("the image archive does not have a manifest.json")
+		} else if err != nil {
+			return err
+		} else if hdr.Name != "manifest.json" {
+			continue
+		}
+
+		var manifest []struct {
+			RepoTags []string
+		}
+		if err := json.NewDecoder(tr).Decode(&manifest); err != nil {
+			return fmt.Errorf("unable to decode the manifest of the image archive, error %v", err)
+		}
+		for _, m := range manifest {
+			for _, tag := range m.RepoTags {
+				f.addImage(tag)
+			}
+		}
+		return nil
+	}
+}
+
+func (f *FakeRuntime) ListImages(opts docker.ListImagesOptions) ([]docker.APIImages, error) {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+
+	images := make([]docker.APIImages, 0, len(f.images))
+	for tag, image := range f.images {
+		images = append(images, docker.APIImages{ID: image.ID, RepoTags: []string{tag}})
+	}
+	return images, nil
+}
+
+func (f *FakeRuntime) InspectImage(name string) (*docker.Image, error) {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+
+	if image := f.findImage(name); image != nil {
+		i := *image
+		return &i, nil
+	}
+	return nil, docker.ErrNoSuchImage
+}
+
+func (f *FakeRuntime) CreateContainer(opts docker.CreateContainerOptions) (*docker.Container, error) {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+
+	if opts.Config == nil {
+		return nil, fmt.Errorf("the container config is required")
+	} else if f.findContainer(opts.Name) != nil {
+		return nil, docker.ErrContainerAlreadyExists
+	}
+
+	image := f.findImage(opts.Config.Image)
+	if image == nil {
+		return nil, docker.ErrNoSuchImage
+	}
+
+	c := &docker.Container{
+		ID:              f.newId(),
+		Name:            "/" + opts.Name,
+		Image:           image.ID,
+		Config:          copyConfig(opts.Config),
+		HostConfig:      &docker.HostConfig{},
+		State:           docker.State{Status: "created"},
+		NetworkSettings: &docker.NetworkSettings{Networks: make(map[string]docker.ContainerNetwork)},
+	}
+	if opts.HostConfig != nil {
+		hc := *opts.HostConfig
+		c.HostConfig = &hc
+	}
+	f.containers[c.ID] = c
+
+	if opts.NetworkingConfig != nil {
+		for name, ep := range opts.NetworkingConfig.EndpointsConfig {
+			id := name
+			if ep != nil && ep.NetworkID != "" {
+				id = ep.NetworkID
+			}
+			if err := f.connect(id, c, ep); err != nil {
+				delete(f.containers, c.ID)
+				return nil, err
+			}
+		}
+	}
+
+	// Like docker, a container that is not given a network is attached to the one named in its network mode.
+	if len(c.NetworkSettings.Networks) == 0 && c.HostConfig.NetworkMode != "" && c.HostConfig.NetworkMode != "host" {
+		if f.findNetwork(c.HostConfig.NetworkMode) != nil {
+			if err := f.connect(c.HostConfig.NetworkMode, c, nil); err != nil {
+				delete(f.containers, c.ID)
+				return nil, err
+			}
+		}
+	}
+
+	r := *c
+	r.NetworkSettings = &docker.NetworkSettings{Networks: copyNetworks(c.NetworkSettings.Networks)}
+	r.State.Health.Log = append([]docker.HealthCheck{}, c.State.Health.Log...)
+	return &r, nil
+}
+
+func (f *FakeRuntime) StartContainer(id string, hostConfig *docker.HostConfig) error {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+
+	c := f.findContainer(id)
+	if c == nil {
+		return &docker.NoSuchContainer{ID: id}
+	} else if c.State.Running {
+		return &docker.ContainerAlreadyRunning{ID: id}
+	}
+	c.State.Running = true
+	c.State.Status = "running"
+	c.State.StartedAt = time.Now()
+	if c.Config.Healthcheck != nil {
+		c.State.Health = docker.Health{Status: "starting"}
+	}
+	return nil
+}
+
+func (f *FakeRuntime) StopContainer(id string, timeout uint) error {
+	return f.stop(id, 0)
+}
+
+// Only SIGKILL and SIGTERM stop the container, like a process that handles the other signals. The signals are recorded.
+func (f *FakeRuntime) KillContainer(opts docker.KillContainerOptions) error {
+	if opts.Signal == 0 || opts.Signal == docker.SIGKILL || opts.Signal == docker.SIGTERM {
+		return f.stop(opts.ID, 137)
+	}
+
+	f.lock.Lock()
+	defer f.lock.Unlock()
+
+	c := f.findContainer(opts.ID)
+	if c == nil {
+		return &docker.NoSuchContainer{ID: opts.ID}
+	} else if !c.State.Running {
+		return &docker.ContainerNotRunning{ID: opts.ID}
+	}
+	f.signals[c.ID] = append(f.signals[c.ID], opts.Signal)
+	return nil
+}
+
+// Returns the signals, other than the ones that stop it, that were sent to a container.
+func (f *FakeRuntime) Signals(id string) []docker.Signal {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+
+	if c := f.findContainer(id); c != nil {
+		return append([]docker.Signal{}, f.signals[c.ID]...)
+	}
+	return nil
+}
+
+func (f *FakeRuntime) RemoveContainer(opts docker.RemoveContainerOptions) error {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+
+	c := f.findContainer(opts.ID)
+	if c == nil {
+		return &docker.NoSuchContainer{ID: opts.ID}
+	} else if c.State.Running && !opts.Force {
+		return fmt.Errorf("container %v is running, stop it before removing it or use force", opts.ID)
+	}
+
+	for name := range c.NetworkSettings.Networks {
+		if n := f.findNetwork(name); n != nil {
+			delete(n.Containers, c.ID)
+		}
+	}
+	delete(f.containers, c.ID)
+	delete(f.signals, c.ID)
+	return nil
+}
+
+func (f *FakeRuntime) InspectContainer(id string) (*docker.Container, error) {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+
+	c := f.findContainer(id)
+	if c == nil {
+		return nil, &docker.NoSuchContainer{ID: id}
+	}
+	r := *c
+	r.NetworkSettings = &docker.NetworkSettings{Networks: copyNetworks(c.NetworkSettings.Networks)}
+	return &r, nil
+}
+
+// Supports the All option and the label filter, which are the ones the agent uses.
+func (f *FakeRuntime) ListContainers(opts docker.ListContainersOptions) ([]docker.APIContainers, error) {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+
+	containers := make([]docker.APIContainers, 0)
+	for _, c := range f.containers {
+		if !opts.All && !c.State.Running {
+			continue
+		} else if !matchesLabels(c.Config.Labels, opts.Filters["label"]) {
+			continue
+		}
+
+		containers = append(containers, docker.APIContainers{
+			ID:       c.ID,
+			Image:    c.Config.Image,
+			State:    c.State.Status,
+			Names:    []string{c.Name},
+			Labels:   copyLabels(c.Config.Labels),
+			Networks: docker.NetworkList{Networks: copyNetworks(c.NetworkSettings.Networks)},
+		})
+	}
+	return containers, nil
+}
+
+// Only the paths where a volume is mounted into the container are supported. Like docker, the entries of the archive
+// are named after the last element of the path.
+func (f *FakeRuntime) DownloadFromContainer(id string, opts docker.DownloadFromContainerOptions) error {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+
+	c := f.findContainer(id)
+	if c == nil {
+		return &docker.NoSuchContainer{ID: id}
+	}
+	volume := f.volumeMountedAt(c, path.Clean(opts.Path))
+	if volume == "" {
+		return fmt.Errorf("no volume is mounted at %v in container %v", opts.Path, id)
+	}
+
+	base := path.Base(path.Clean(opts.Path))
+	names := make([]string, 0, len(f.files[volume]))
+	for name := range f.files[volume] {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	tw := tar.NewWriter(opts.OutputStream)
+	if err := tw.WriteHeader(&tar.Header{Name: base + "/", Typeflag: tar.TypeDir, Mode: 0755}); err != nil {
+		return err
+	}
+	for _, name := range names {
+		data := f.files[volume][name]
+		if err := tw.WriteHeader(&tar.Header{Name: path.Join(base, name), Typeflag: tar.TypeReg, Mode: 0644, Size: int64(len(data))}); err != nil {
+			return err
+		} else if _, err := tw.Write(data); err != nil {
+			return err
+		}
+	}
+	return tw.Close()
+}
+
+// The files of the archive are extracted into the volumes that are mounted into the container. Files outside of the
+// volumes are not supported.
+func (f *FakeRuntime) UploadToContainer(id string, opts docker.UploadToContainerOptions) error {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+
+	c := f.findContainer(id)
+	if c == nil {
+		return &docker.NoSuchContainer{ID: id}
+	}
+
+	tr := tar.NewReader(opts.InputStream)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			return nil
+		} else if err != nil {
+			return err
+		} else if hdr.Typeflag != tar.TypeReg {
+			continue
+		}
+
+		file := path.Join(opts.Path, hdr.Name)
+		dir := path.Dir(file)
+		for dir != "/" && f.volumeMountedAt(c, dir) == "" {
+			dir = path.Dir(dir)
+		}
+		volume := f.volumeMountedAt(c, dir)
+		if volume == "" {
+			return fmt.Errorf("no volume is mounted above %v in container %v", file, id)
+		}
+
+		data, err := ioutil.ReadAll(tr)
+		if err != nil {
+			return err
+		} else if f.files[volume] == nil {
+			f.files[volume] = make(map[string][]byte)
+		}
+		f.files[volume][strings.TrimPrefix(file, dir+"/")] = data
+	}
+}
+
+func (f *FakeRuntime) CreateNetwork(opts docker.CreateNetworkOptions) (*docker.Network, error) {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+
+	if f.findNetwork(opts.Name) != nil {
+		return nil, docker.ErrNetworkAlreadyExists
+	}
+
+	n := &docker.Network{
+		ID:         f.newId(),
+		Name:       opts.Name,
+		Driver:     opts.Driver,
+		Labels:     copyLabels(opts.Labels),
+		Internal:   opts.Internal,
+		Containers: make(map[string]docker.Endpoint),
+		// Each network gets its own /24 so that every container address on the node is unique.
+		IPAM: docker.IPAMOptions{Config: []docker.IPAMConfig{{Subnet: fmt.Sprintf("172.30.%v.0/24", len(f.networks)+1)}}},
+	}
+	f.networks[n.ID] = n
+
+	r := *n
+	r.Containers = make(map[string]docker.Endpoint)
+	return &r, nil
+}
+
+func (f *FakeRuntime) RemoveNetwork(id string) error {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+
+	n := f.findNetwork(id)
+	if n == nil {
+		return &docker.NoSuchNetwork{ID: id}
+	} else if len(n.Containers) != 0 {
+		return fmt.Errorf("network %v has active endpoints", n.Name)
+	}
+	delete(f.networks, n.ID)
+	return nil
+}
+
+func (f *FakeRuntime) NetworkInfo(id string) (*docker.Network, error) {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+
+	n := f.findNetwork(id)
+	if n == nil {
+		return nil, &docker.NoSuchNetwork{ID: id}
+	}
+	r := *n
+	r.Containers = make(map[string]docker.Endpoint, len(n.Containers))
+	for k, v := range n.Containers {
+		r.Containers[k] = v
+	}
+	return &r, nil
+}
+
+func (f *FakeRuntime) ListNetworks() ([]docker.Network, error) {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+
+	networks := make([]docker.Network, 0, len(f.networks))
+	for _, n := range f.networks {
+		r := *n
+		r.Containers = nil
+		networks = append(networks, r)
+	}
+	return networks, nil
+}
+
+func (f *FakeRuntime) ConnectNetwork(id string, opts docker.NetworkConnectionOptions) error {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+
+	c := f.findContainer(opts.Container)
+	if c == nil {
+		return &docker.NoSuchNetworkOrContainer{NetworkID: id, ContainerID: opts.Container}
+	}
+	return f.connect(id, c, opts.EndpointConfig)
+}
+
+func (f *FakeRuntime) DisconnectNetwork(id string, opts docker.NetworkConnectionOptions) error {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+
+	n := f.findNetwork(id)
+	c := f.findContainer(opts.Container)
+	if n == nil || c == nil {
+		return &docker.NoSuchNetworkOrContainer{NetworkID: id, ContainerID: opts.Container}
+	}
+	delete(n.Containers, c.ID)
+	delete(c.NetworkSettings.Networks, n.Name)
+	return nil
+}
+
+func (f *FakeRuntime) CreateVolume(opts docker.CreateVolumeOptions) (*docker.Volume, error) {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+
+	// Like docker, creating a volume that already exists returns the existing volume.
+	if v, ok := f.volumes[opts.Name]; ok {
+		r := *v
+		return &r, nil
+	}
+
+	v := &docker.Volume{
+		Name:       opts.Name,
+		Driver:     opts.Driver,
+		Labels:     copyLabels(opts.Labels),
+		Mountpoint: "/var/lib/docker/volumes/" + opts.Name + "/_data",
+	}
+	f.volumes[v.Name] = v
+
+	r := *v
+	return &r, nil
+}
+
+func (f *FakeRuntime) RemoveVolume(name string) error {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+
+	if _, ok := f.volumes[name]; !ok {
+		return docker.ErrNoSuchVolume
+	}
+	for _, c := range f.containers {
+		for _, bind := range c.HostConfig.Binds {
+			if strings.Split(bind, ":")[0] == name {
+				return docker.ErrVolumeInUse
+			}
+		}
+	}
+	delete(f.volumes, name)
+	delete(f.files, name)
+	return nil
+}
+
+func (f *FakeRuntime) ListVolumes(opts docker.ListVolumesOptions) ([]docker.Volume, error) {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+
+	volumes := make([]docker.Volume, 0, len(f.volumes))
+	for _, v := range f.volumes {
+		volumes = append(volumes, *v)
+	}
+	return volumes, nil
+}
+
+// The functions below expect the caller to hold the lock.
+
+// Returns the name of the volume that is mounted at the path in the container, or an empty string.
+func (f *FakeRuntime) volumeMountedAt(c *docker.Container, dir string) string {
+	if c.HostConfig == nil {
+		return ""
+	}
+	for _, bind := range c.HostConfig.Binds {
+		parts := strings.Split(bind, ":")
+		if len(parts) > 1 && !strings.Contains(parts[0], "/") && path.Clean(parts[1]) == dir {
+			if _, ok := f.volumes[parts[0]]; ok {
+				return parts[0]
+			}
+		}
+	}
+	return ""
+}
+
+// The events are delivered synchronously, so a listener must be read while containers are stopped.
+func (f *FakeRuntime) AddEventListener(listener chan<- *docker.APIEvents) error {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+
+	for _, l := range f.listeners {
+		if l == listener {
+			return docker.ErrListenerAlreadyExists
+		}
+	}
+	f.listeners = append(f.listeners, listener)
+	return nil
+}
+
+func (f *FakeRuntime) RemoveEventListener(listener chan *docker.APIEvents) error {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+
+	for i, l := range f.listeners {
+		if l == listener {
+			f.listeners = append(f.listeners[:i], f.listeners[i+1:]...)
+			break
+		}
+	}
+	return nil
+}
+
+// Sends an event about a container to the listeners, as if docker had sent it, e.g. oom or "health_status: unhealthy".
+// A die event also stops the container, as if it had crashed.
+func (f *FakeRuntime) SendContainerEvent(idOrName string, action string) error {
+	if action == "die" {
+		return f.stop(idOrName, 1)
+	}
+
+	f.lock.Lock()
+	c := f.findContainer(idOrName)
+	if c == nil {
+		f.lock.Unlock()
+		return &docker.NoSuchContainer{ID: idOrName}
+	}
+	event := containerEvent(c, action)
+	listeners := append([]chan<- *docker.APIEvents{}, f.listeners...)
+	f.lock.Unlock()
+
+	for _, l := range listeners {
+		l <- event
+	}
+	return nil
+}
+
+// Sets the result of the health check of a container, as if docker had run it, and sends the health_status event.
+func (f *FakeRuntime) SetContainerHealth(idOrName string, status string, output string) error {
+	f.lock.Lock()
+	c := f.findContainer(idOrName)
+	if c == nil {
+		f.lock.Unlock()
+		return &docker.NoSuchContainer{ID: idOrName}
+	}
+	c.State.Health.Status = status
+	c.State.Health.Log = append(c.State.Health.Log, docker.HealthCheck{Start: time.Now(), End: time.Now(), Output: output})
+	event := containerEvent(c, "health_status: "+status)
+	listeners := append([]chan<- *docker.APIEvents{}, f.listeners...)
+	f.lock.Unlock()
+
+	for _, l := range listeners {
+		l <- event
+	}
+	return nil
+}
+
+// Ends the event stream, which closes the listeners the way the docker client does when it loses the daemon.
+func (f *FakeRuntime) CloseEventListeners() {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+
+	for _, l := range f.listeners {
+		close(l)
+	}
+	f.listeners = nil
+}
+
+func (f *FakeRuntime) newId() string {
+	f.nextId++
+	return fmt.Sprintf("%064x", f.nextId)
+}
+
+func (f *FakeRuntime) addImage(name string) {
+	if !hasTag(name) {
+		name += ":latest"
+	}
+	if _, ok := f.images[name]; !ok {
+		f.images[name] = &docker.Image{ID: "sha256:" + f.newId(), RepoTags: []string{name}}
+	}
+}
+
+func (f *FakeRuntime) findImage(name string) *docker.Image {
+	if image, ok := f.images[name]; ok {
+		return image
+	} else if !hasTag(name) {
+		return f.images[name+":latest"]
+	}
+	for _, image := range f.images {
+		if image.ID == name {
+			return image
+		}
+	}
+	return nil
+}
+
+// Finds a container by id or name, like docker does.
+func (f *FakeRuntime) findContainer(idOrName string) *docker.Container {
+	if c, ok := f.containers[idOrName]; ok {
+		return c
+	}
+	for _, c := range f.containers {
+		if c.Name == "/"+strings.TrimPrefix(idOrName, "/") {
+			return c
+		}
+	}
+	return nil
+}
+
+// Finds a network by id or name, like docker does.
+func (f *FakeRuntime) findNetwork(idOrName string) *docker.Network {
+	if n, ok := f.networks[idOrName]; ok {
+		return n
+	}
+	for _, n := range f.networks {
+		if n.Name == idOrName {
+			return n
+		}
+	}
+	return nil
+}
+
+func (f *FakeRuntime) connect(id string, c *docker.Container, ep *docker.EndpointConfig) error {
+	n := f.findNetwork(id)
+	if n == nil {
+		return &docker.NoSuchNetworkOrContainer{NetworkID: id, ContainerID: c.ID}
+	} else if _, ok := n.Containers[c.ID]; ok {
+		return nil
+	}
+
+	// Hand out the addresses in order and never reuse them, the first one is the gateway.
+	subnet := strings.TrimSuffix(n.IPAM.Config[0].Subnet, "0/24")
+	f.nextIP[n.ID]++
+	ip := fmt.Sprintf("%v%v", subnet, f.nextIP[n.ID]+1)
+
+	cn := docker.ContainerNetwork{
+		NetworkID:   n.ID,
+		EndpointID:  f.newId(),
+		IPAddress:   ip,
+		IPPrefixLen: 24,
+		Gateway:     subnet + "1",
+	}
+	if ep != nil {
+		cn.Aliases = ep.Aliases
+	}
+
+	n.Containers[c.ID] = docker.Endpoint{Name: strings.TrimPrefix(c.Name, "/"), ID: cn.EndpointID, IPv4Address: ip + "/24"}
+	c.NetworkSettings.Networks[n.Name] = cn
+	return nil
+}
+
+// Stops a running container and sends the die event that docker sends when a container exits.
+func (f *FakeRuntime) stop(id string, exitCode int) error {
+	f.lock.Lock()
+
+	c := f.findContainer(id)
+	if c == nil {
+		f.lock.Unlock()
+		return &docker.NoSuchContainer{ID: id}
+	} else if !c.State.Running {
+		f.lock.Unlock()
+		return &docker.ContainerNotRunning{ID: id}
+	}
+	c.State.Running = false
+	c.State.Status = "exited"
+	c.State.ExitCode = exitCode
+	event := containerEvent(c, "die")
+	event.Actor.Attributes["exitCode"] = fmt.Sprintf("%v", exitCode)
+	listeners := append([]chan<- *docker.APIEvents{}, f.listeners...)
+	f.lock.Unlock()
+
+	for _, l := range listeners {
+		l <- event
+	}
+	return nil
+}
+
+// Returns an event about a container, with the container labels, name and image as attributes like docker does.
+func containerEvent(c *docker.Container, action string) *docker.APIEvents {
+	attributes := copyLabels(c.Config.Labels)
+	attributes["name"] = strings.TrimPrefix(c.Name, "/")
+	attributes["image"] = c.Config.Image
+	return &docker.APIEvents{
+		Type:     "container",
+		Action:   action,
+		Status:   action,
+		ID:       c.ID,
+		From:     c.Config.Image,
+		Actor:    docker.APIActor{ID: c.ID, Attributes: attributes},
+		TimeNano: time.Now().UnixNano(),
+		Time:     time.Now().Unix(),
+	}
+}
+
+// Returns true if the image name has a tag or a digest after its last path element.
+func hasTag(name string) bool {
+	last := name[strings.LastIndex(name, "/")+1:]
+	return strings.Contains(last, ":") || strings.Contains(last, "@")
+}
+
+// Returns true if the labels match all the filters, each of the form key or key=value.
+func matchesLabels(labels map[string]string, filters []string) bool {
+	for _, filter := range filters {
+		kv := strings.SplitN(filter, "=", 2)
+		if v, ok := labels[kv[0]]; !ok {
+			return false
+		} else if len(kv) == 2 && v != kv[1] {
+			return false
+		}
+	}
+	return true
+}
+
+func copyConfig(config *docker.Config) *docker.Config {
+	c := *config
+	c.Labels = copyLabels(config.Labels)
+	return &c
+}
+
+func copyLabels(labels map[string]string) map[string]string {
+	c := make(map[string]string, len(labels))
+	for k, v := range labels {
+		c[k] = v
+	}
+	return c
+}
+
+func copyNetworks(networks map[string]docker.ContainerNetwork) map[string]docker.ContainerNetwork {
+	c := make(map[string]docker.ContainerNetwork, len(networks))
+	for k, v := range networks {
+		c[k] = v
+	}
+	return c
+}
@@ -0,0 +1,200 @@
+// +build unit
+
+package containerruntime
+
+import (
+	"archive/tar"
+	"bytes"
+	docker "github.com/fsouza/go-dockerclient"
+	"testing"
+)
+
+func Test_FakeRuntime_images(t *testing.T) {
+	f := NewFakeRuntime()
+
+	if err := f.PullImage(docker.PullImageOptions{Repository: "registry.example.com/myorg/app", Tag: "1.0"}, docker.AuthConfiguration{}); err != nil {
+		t.Errorf("unexpected error %v", err)
+	} else if _, err := f.InspectImage("registry.example.com/myorg/app:1.0"); err != nil {
+		t.Errorf("the pulled image was not found, error %v", err)
+	}
+
+	// an image saved with docker save
+	var archive bytes.Buffer
+	tw := tar.NewWriter(&archive)
+	manifest := []byte(`[{"Config":"abc.json","RepoTags":["myorg/loaded:2.0"],"Layers":[]}]`)
+	tw.WriteHeader(&tar.Header{Name: "manifest.json", Mode: 0644, Size: int64(len(manifest))})
+	tw.Write(manifest)
+	tw.Close()
+
+	if err := f.LoadImage(docker.LoadImageOptions{InputStream: &archive}); err != nil {
+		t.Errorf("unexpected error %v", err)
+	} else if _, err := f.InspectImage("myorg/loaded:2.0"); err != nil {
+		t.Errorf("the loaded image was not found, error %v", err)
+	}
+
+	if _, err := f.InspectImage("myorg/missing"); err != docker.ErrNoSuchImage {
+		t.Errorf("expected ErrNoSuchImage, got %v", err)
+	}
+}
+
+func Test_FakeRuntime_containers(t *testing.T) {
+	f := NewFakeRuntime()
+	f.AddImage("myorg/app")
+
+	net, err := f.CreateNetwork(docker.CreateNetworkOptions{Name: "net1"})
+	if err != nil {
+		t.Fatalf("unexpected error %v", err)
+	} else if _, err := f.CreateNetwork(docker.CreateNetworkOptions{Name: "net1"}); err != docker.ErrNetworkAlreadyExists {
+		t.Errorf("expected ErrNetworkAlreadyExists, got %v", err)
+	}
+
+	opts := docker.CreateContainerOptions{
+		Name:             "app",
+		Config:           &docker.Config{Image: "myorg/app", Labels: map[string]string{"role": "app"}},
+		NetworkingConfig: &docker.NetworkingConfig{EndpointsConfig: map[string]*docker.EndpointConfig{"net1": {NetworkID: net.ID}}},
+	}
+	con, err := f.CreateContainer(opts)
+	if err != nil {
+		t.Fatalf("unexpected error %v", err)
+	} else if _, err := f.CreateContainer(opts); err != docker.ErrContainerAlreadyExists {
+		t.Errorf("expected ErrContainerAlreadyExists, got %v", err)
+	}
+
+	// created containers are only listed with the All option until they are started
+	if l, _ := f.ListContainers(docker.ListContainersOptions{}); len(l) != 0 {
+		t.Errorf("expected no running containers, got %v", l)
+	} else if err := f.StartContainer(con.ID, nil); err != nil {
+		t.Errorf("unexpected error %v", err)
+	} else if l, _ := f.ListContainers(docker.ListContainersOptions{Filters: map[string][]string{"label": {"role=app"}}}); len(l) != 1 {
+		t.Errorf("expected one container with the label, got %v", l)
+	} else if l, _ := f.ListContainers(docker.ListContainersOptions{Filters: map[string][]string{"label": {"role=db"}}}); len(l) != 0 {
+		t.Errorf("expected no containers with the label, got %v", l)
+	}
+
+	if n, _ := f.NetworkInfo(net.ID); len(n.Containers) != 1 {
+		t.Errorf("expected the container on the network, got %v", n.Containers)
+	} else if err := f.RemoveNetwork(net.ID); err == nil {
+		t.Errorf("expected an error removing a network in use")
+	}
+
+	// a signal that does not stop the container is only recorded
+	if err := f.KillContainer(docker.KillContainerOptions{ID: con.ID, Signal: docker.SIGHUP}); err != nil {
+		t.Errorf("unexpected error %v", err)
+	} else if c, _ := f.InspectContainer(con.ID); !c.State.Running {
+		t.Errorf("the signalled container should be running")
+	} else if s := f.Signals(con.ID); len(s) != 1 || s[0] != docker.SIGHUP {
+		t.Errorf("expected the container to be sent SIGHUP, got %v", s)
+	}
+
+	if err := f.KillContainer(docker.KillContainerOptions{ID: con.ID}); err != nil {
+		t.Errorf("unexpected error %v", err)
+	} else if err := f.KillContainer(docker.KillContainerOptions{ID: con.ID}); err == nil {
+		t.Errorf("expected an error killing a stopped container")
+	} else if err := f.RemoveContainer(docker.RemoveContainerOptions{ID: con.ID}); err != nil {
+		t.Errorf("unexpected error %v", err)
+	} else if err := f.RemoveNetwork(net.ID); err != nil {
+		t.Errorf("unexpected error %v", err)
+	}
+}
+
+func Test_FakeRuntime_events(t *testing.T) {
+	f := NewFakeRuntime()
+	f.AddImage("myorg/app")
+
+	con, err := f.CreateContainer(docker.CreateContainerOptions{Name: "app", Config: &docker.Config{Image: "myorg/app", Labels: map[string]string{"role": "app"}}})
+	if err != nil {
+		t.Fatalf("unexpected error %v", err)
+	} else if err := f.StartContainer(con.ID, nil); err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+
+	listener := make(chan *docker.APIEvents, 10)
+	if err := f.AddEventListener(listener); err != nil {
+		t.Fatalf("unexpected error %v", err)
+	} else if err := f.AddEventListener(listener); err != docker.ErrListenerAlreadyExists {
+		t.Errorf("expected ErrListenerAlreadyExists, got %v", err)
+	}
+
+	// events carry the labels of the container, like the events of docker
+	if err := f.SendContainerEvent("app", "oom"); err != nil {
+		t.Errorf("unexpected error %v", err)
+	} else if e := <-listener; e.Action != "oom" || e.Actor.ID != con.ID || e.Actor.Attributes["role"] != "app" || e.Actor.Attributes["name"] != "app" {
+		t.Errorf("unexpected event %v", e)
+	}
+
+	// stopping a container sends its die event
+	if err := f.KillContainer(docker.KillContainerOptions{ID: con.ID}); err != nil {
+		t.Errorf("unexpected error %v", err)
+	} else if e := <-listener; e.Action != "die" || e.Actor.Attributes["exitCode"] != "137" {
+		t.Errorf("unexpected event %v", e)
+	}
+
+	f.CloseEventListeners()
+	if _, ok := <-listener; ok {
+		t.Errorf("expected the listener to be closed")
+	}
+}
+
+func Test_FakeRuntime_volumeArchive(t *testing.T) {
+	f := NewFakeRuntime()
+	f.AddImage("myorg/app")
+
+	if _, err := f.CreateVolume(docker.CreateVolumeOptions{Name: "data"}); err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	f.WriteVolumeFile("data", "a.txt", []byte("a"))
+	f.WriteVolumeFile("data", "sub/b.txt", []byte("b"))
+
+	con, err := f.CreateContainer(docker.CreateContainerOptions{
+		Name:       "app",
+		Config:     &docker.Config{Image: "myorg/app"},
+		HostConfig: &docker.HostConfig{Binds: []string{"data:/data"}},
+	})
+	if err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+
+	// the entries are named after the mount path
+	var archive bytes.Buffer
+	if err := f.DownloadFromContainer(con.ID, docker.DownloadFromContainerOptions{OutputStream: &archive, Path: "/data"}); err != nil {
+		t.Fatalf("unexpected error %v", err)
+	} else if err := f.DownloadFromContainer(con.ID, docker.DownloadFromContainerOptions{OutputStream: &bytes.Buffer{}, Path: "/other"}); err == nil {
+		t.Errorf("expected an error reading a path without a volume")
+	}
+	names := []string{}
+	tr := tar.NewReader(bytes.NewReader(archive.Bytes()))
+	for hdr, err := tr.Next(); err == nil; hdr, err = tr.Next() {
+		names = append(names, hdr.Name)
+	}
+	if len(names) != 3 || names[0] != "data/" || names[1] != "data/a.txt" || names[2] != "data/sub/b.txt" {
+		t.Errorf("unexpected entries %v", names)
+	}
+
+	// the volume is in use until the container is removed
+	if err := f.RemoveVolume("data"); err != docker.ErrVolumeInUse {
+		t.Errorf("expected ErrVolumeInUse, got %v", err)
+	} else if err := f.RemoveContainer(docker.RemoveContainerOptions{ID: con.ID}); err != nil {
+		t.Errorf("unexpected error %v", err)
+	} else if err := f.RemoveVolume("data"); err != nil {
+		t.Errorf("unexpected error %v", err)
+	} else if files := f.VolumeFiles("data"); len(files) != 0 {
+		t.Errorf("expected the files to be removed with the volume, got %v", files)
+	}
+
+	// the archive is extracted into a new volume
+	if _, err := f.CreateVolume(docker.CreateVolumeOptions{Name: "data"}); err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	con, err = f.CreateContainer(docker.CreateContainerOptions{
+		Name:       "restore",
+		Config:     &docker.Config{Image: "myorg/app"},
+		HostConfig: &docker.HostConfig{Binds: []string{"data:/data"}},
+	})
+	if err != nil {
+		t.Fatalf("unexpected error %v", err)
+	} else if err := f.UploadToContainer(con.ID, docker.UploadToContainerOptions{InputStream: bytes.NewReader(archive.Bytes()), Path: "/"}); err != nil {
+		t.Fatalf("unexpected error %v", err)
+	} else if files := f.VolumeFiles("data"); len(files) != 2 || string(files["a.txt"]) != "a" || string(files["sub/b.txt"]) != "b" {
+		t.Errorf("unexpected files %v", files)
+	}
+}
@@ -0,0 +1,61 @@
+package containerruntime
+
+import (
+	docker "github.com/fsouza/go-dockerclient"
+)
+
+// The operations that the agent performs on the container runtime of an edge node. The method signatures are those
+// of the docker client so that the docker implementation needs no translation, and so that the other implementations
+// behave the way the workers already expect, including the error values they return.
+type ContainerRuntime interface {
+	// System
+	Info() (*docker.DockerInfo, error)
+
+	// Images
+	PullImage(opts docker.PullImageOptions, auth docker.AuthConfiguration) error
+	LoadImage(opts docker.LoadImageOptions) error
+	ListImages(opts docker.ListImagesOptions) ([]docker.APIImages, error)
+	InspectImage(name string) (*docker.Image, error)
+
+	// Containers
+	CreateContainer(opts docker.CreateContainerOptions) (*docker.Container, error)
+	StartContainer(id string, hostConfig *docker.HostConfig) error
+	StopContainer(id string, timeout uint) error
+	KillContainer(opts docker.KillContainerOptions) error
+	RemoveContainer(opts docker.RemoveContainerOptions) error
+	InspectContainer(id string) (*docker.Container, error)
+	ListContainers(opts docker.ListContainersOptions) ([]docker.APIContainers, error)
+	DownloadFromContainer(id string, opts docker.DownloadFromContainerOptions) error
+	UploadToContainer(id string, opts docker.UploadToContainerOptions) error
+
+	// Networks
+	CreateNetwork(opts docker.CreateNetworkOptions) (*docker.Network, error)
+	RemoveNetwork(id string) error
+	NetworkInfo(id string) (*docker.Network, error)
+	ListNetworks() ([]docker.Network, error)
+	ConnectNetwork(id string, opts docker.NetworkConnectionOptions) error
+	DisconnectNetwork(id string, opts docker.NetworkConnectionOptions) error
+
+	// Volumes
+	CreateVolume(opts docker.CreateVolumeOptions) (*docker.Volume, error)
+	RemoveVolume(name string) error
+	ListVolumes(opts docker.ListVolumesOptions) ([]docker.Volume, error)
+
+	// Events. The listener is closed by the runtime when the event stream ends.
+	AddEventListener(listener chan<- *docker.APIEvents) error
+	RemoveEventListener(listener chan *docker.APIEvents) error
+}
+
+// The container runtime of a node that runs docker.
+type DockerRuntime struct {
+	*docker.Client
+}
+
+// Returns the runtime for the docker daemon at the endpoint, e.g. unix:///var/run/docker.sock.
+func NewDockerRuntime(endpoint string) (*DockerRuntime, error) {
+	client, err := docker.NewClient(endpoint)
+	if err != nil {
+		return nil, err
+	}
+	return &DockerRuntime{Client: client}, nil
+}
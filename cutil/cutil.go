@@ -10,6 +10,7 @@ import (
 	"fmt"
 	"github.com/golang/glog"
 	"github.com/open-horizon/anax/config"
+	"io/ioutil"
 	"k8s.io/apimachinery/pkg/api/resource"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/client-go/kubernetes"
@@ -22,6 +23,7 @@ import (
 	"runtime"
 	"strconv"
 	"strings"
+	"syscall"
 	"time"
 )
 
@@ -184,8 +186,8 @@ func VerifyWorkloadVarTypes(varValue interface{}, expectedType string) error {
 			return errors.New(fmt.Sprintf("type %T, expecting %v.", varValue, expectedType))
 		}
 	case string:
-		// if the type is empty, it defaults to string
-		if expectedType != "string" && expectedType != "" {
+		// if the type is empty, it defaults to string. A secret is a string that is handled with care.
+		if expectedType != "string" && expectedType != "" && expectedType != "secret" {
 			return errors.New(fmt.Sprintf("type %T, expecting %v.", varValue, expectedType))
 		}
 	case json.Number:
@@ -696,6 +698,102 @@ func ConvertToMB(value string, unit string) (uint64, error) {
 	}
 }
 
+// Get the distribution id and the version of the operating system, e.g. ubuntu and 20.04. If osReleaseFile is an
+// empty string, this function will use /etc/os-release for Linux.
+func GetOSRelease(osReleaseFile string) (string, string, error) {
+	if osReleaseFile == "" {
+		// does not support
+		if runtime.GOOS == "darwin" {
+			return "", "", fmt.Errorf("Does not support mac os for getting the os release.")
+		} else {
+			osReleaseFile = "/etc/os-release"
+		}
+	}
+
+	fh, err := os.Open(osReleaseFile)
+	if err != nil {
+		return "", "", err
+	}
+	defer fh.Close()
+
+	id := ""
+	version := ""
+	scanner := bufio.NewScanner(fh)
+	for scanner.Scan() {
+		parts := strings.SplitN(strings.TrimSpace(scanner.Text()), "=", 2)
+		if len(parts) != 2 {
+			continue
+		}
+		value := strings.Trim(parts[1], `"'`)
+		switch parts[0] {
+		case "ID":
+			id = value
+		case "VERSION_ID":
+			version = value
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return "", "", err
+	}
+	return id, version, nil
+}
+
+// Get the release of the kernel, e.g. 5.4.0-42-generic. If kernelReleaseFile is an empty string, this function will
+// use /proc/sys/kernel/osrelease for Linux.
+func GetKernelVersion(kernelReleaseFile string) (string, error) {
+	if kernelReleaseFile == "" {
+		// does not support
+		if runtime.GOOS == "darwin" {
+			return "", fmt.Errorf("Does not support mac os for getting the kernel version.")
+		} else {
+			kernelReleaseFile = "/proc/sys/kernel/osrelease"
+		}
+	}
+
+	if b, err := ioutil.ReadFile(kernelReleaseFile); err != nil {
+		return "", err
+	} else {
+		return strings.TrimSpace(string(b)), nil
+	}
+}
+
+// Get the free disk space in MegaBytes of the file system that holds the given path. Only the space that is available
+// to unprivileged users is counted.
+func GetFreeDiskMB(path string) (uint64, error) {
+	var stat syscall.Statfs_t
+	if err := syscall.Statfs(path, &stat); err != nil {
+		return 0, err
+	}
+	return (uint64(stat.Bavail) * uint64(stat.Bsize)) >> 20, nil
+}
+
+// Get the number of physical network interfaces. Virtual interfaces, like the loopback interface and the bridges and
+// veth pairs that containers use, have no device in sysfs and are not counted, so starting a container does not change
+// the count. If sysNetDir is an empty string, this function will use /sys/class/net for Linux.
+func GetNetworkInterfaceCount(sysNetDir string) (int, error) {
+	if sysNetDir == "" {
+		// does not support
+		if runtime.GOOS == "darwin" {
+			return 0, fmt.Errorf("Does not support mac os for getting the network interfaces.")
+		} else {
+			sysNetDir = "/sys/class/net"
+		}
+	}
+
+	entries, err := ioutil.ReadDir(sysNetDir)
+	if err != nil {
+		return 0, err
+	}
+
+	count := 0
+	for _, entry := range entries {
+		if _, err := os.Stat(path.Join(sysNetDir, entry.Name(), "device")); err == nil {
+			count++
+		}
+	}
+	return count, nil
+}
+
 // FormExchangeId combines url, version, arch the same way the exchange does to form the resource ID.
 func FormExchangeIdForService(url, version, arch string) string {
 	// Remove the https:// from the beginning of workloadUrl and replace troublesome chars with a dash.
@@ -778,6 +876,39 @@ func GetClusterCountInfo() (float64, float64, float64, string, string, error) {
 	return math.Round(availMem), math.Round(totalMem), cpu, arch, version, nil
 }
 
+// GetClusterSystemInfo returns the os image, kernel version and container runtime version that the nodes of the cluster
+// report, e.g. "Ubuntu 20.04.1 LTS", "5.4.0-42-generic" and "docker://19.3.12". A value is empty when the nodes do not
+// all report the same one.
+func GetClusterSystemInfo() (string, string, string, error) {
+	client, err := NewKubeClient()
+	if err != nil {
+		return "", "", "", fmt.Errorf("Failed to get kube client for introspecting cluster properties. %v", err)
+	}
+	nodes, err := client.CoreV1().Nodes().List(metav1.ListOptions{})
+	if err != nil {
+		return "", "", "", fmt.Errorf("Failed to list the cluster nodes. %v", err)
+	}
+
+	osImage, kernel, containerRuntime := "", "", ""
+	for i, node := range nodes.Items {
+		info := node.Status.NodeInfo
+		if i == 0 {
+			osImage, kernel, containerRuntime = info.OSImage, info.KernelVersion, info.ContainerRuntimeVersion
+			continue
+		}
+		if osImage != info.OSImage {
+			osImage = ""
+		}
+		if kernel != info.KernelVersion {
+			kernel = ""
+		}
+		if containerRuntime != info.ContainerRuntimeVersion {
+			containerRuntime = ""
+		}
+	}
+	return osImage, kernel, containerRuntime, nil
+}
+
 // FloatFromQuantity returns a float64 with the value of the given quantity type
 func FloatFromQuantity(quantVal *resource.Quantity) float64 {
 	if intVal, ok := quantVal.AsInt64(); ok {
@@ -5,6 +5,9 @@ package cutil
 import (
 	"fmt"
 	"github.com/stretchr/testify/assert"
+	"io/ioutil"
+	"os"
+	"path"
 	"testing"
 )
 
@@ -403,3 +406,32 @@ func Test_RemoveArchFromServiceId(t *testing.T) {
 		t.Errorf("RemoveArchFromServiceId should have returned 'mycluster/hello' but got: %v", no_arch)
 	}
 }
+
+func Test_GetOSRelease(t *testing.T) {
+	id, version, err := GetOSRelease("./test/os-release")
+	if err != nil {
+		t.Errorf("GetOSRelease should not get error but got: %v", err)
+	} else if id != "ubuntu" || version != "20.04" {
+		t.Errorf("Should have ubuntu 20.04 but got: %v %v", id, version)
+	}
+}
+
+func Test_GetNetworkInterfaceCount(t *testing.T) {
+	dir, err := ioutil.TempDir("", "net")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	// only the interfaces with a device are physical
+	os.MkdirAll(path.Join(dir, "eth0", "device"), 0755)
+	os.MkdirAll(path.Join(dir, "wlan0", "device"), 0755)
+	os.MkdirAll(path.Join(dir, "lo"), 0755)
+	os.MkdirAll(path.Join(dir, "docker0"), 0755)
+
+	if c, err := GetNetworkInterfaceCount(dir); err != nil {
+		t.Errorf("GetNetworkInterfaceCount should not get error but got: %v", err)
+	} else if c != 2 {
+		t.Errorf("Should have 2 network interfaces but got: %v", c)
+	}
+}
@@ -0,0 +1,206 @@
+package cutil
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"text/template"
+	"text/template/parse"
+)
+
+// The values that a service deployment string and the default values of the service user input can refer to. They
+// are known only on the node, so the templates are expanded by the agent when the service is started. A template
+// uses the go text/template syntax with the {% and %} delimiters, so that {{ in existing deployment strings is left
+// as is, e.g.
+//
+//	"SITE={% property \"site_id\" %}"
+//	"{%.NodeOrg%}/{%.NodeId%} runs {%.ServiceURL%} {%.ServiceVersion%} in agreement {%.AgreementId%}"
+//
+// The property function fails when the node policy does not have the property, unless a default is given as the
+// second argument, e.g. {% property "site_id" "unknown" %}.
+type TemplateData struct {
+	NodeId         string                 `json:"nodeId"`
+	NodeOrg        string                 `json:"nodeOrg"`
+	Pattern        string                 `json:"pattern"`
+	AgreementId    string                 `json:"agreementId"` // Empty for a dependent service that is shared by several agreements.
+	ServiceURL     string                 `json:"serviceUrl"`
+	ServiceOrg     string                 `json:"serviceOrg"`
+	ServiceVersion string                 `json:"serviceVersion"`
+	Properties     map[string]interface{} `json:"properties"` // The properties of the node policy.
+}
+
+func (t TemplateData) String() string {
+	return fmt.Sprintf("NodeId: %v, NodeOrg: %v, Pattern: %v, AgreementId: %v, ServiceURL: %v, ServiceOrg: %v, ServiceVersion: %v, Properties: %v",
+		t.NodeId, t.NodeOrg, t.Pattern, t.AgreementId, t.ServiceURL, t.ServiceOrg, t.ServiceVersion, t.Properties)
+}
+
+// The delimiters of the actions in a template.
+const (
+	TEMPLATE_LEFT_DELIM  = "{%"
+	TEMPLATE_RIGHT_DELIM = "%}"
+)
+
+// Returns true if the string has to be expanded on the node.
+func IsTemplate(s string) bool {
+	return strings.Contains(s, TEMPLATE_LEFT_DELIM)
+}
+
+func newTemplate(data *TemplateData) *template.Template {
+	return template.New("").Delims(TEMPLATE_LEFT_DELIM, TEMPLATE_RIGHT_DELIM).Funcs(templateFuncs(data)).Option("missingkey=error")
+}
+
+// Returns the template functions. The property function looks up the node policy properties of the given data; a
+// nil data is used to verify the syntax only.
+func templateFuncs(data *TemplateData) template.FuncMap {
+	return template.FuncMap{
+		"property": func(name string, def ...string) (interface{}, error) {
+			if len(def) > 1 {
+				return nil, errors.New(fmt.Sprintf("property %v has more than one default value", name))
+			} else if data != nil {
+				if value, ok := data.Properties[name]; ok {
+					return value, nil
+				}
+			}
+			if len(def) == 1 {
+				return def[0], nil
+			}
+			return nil, errors.New(fmt.Sprintf("the node policy does not have property %v", name))
+		},
+	}
+}
+
+// Verify that the template syntax of the string is correct. The values it refers to are only known on the node.
+func VerifyTemplate(s string) error {
+	if !IsTemplate(s) {
+		return nil
+	} else if _, err := newTemplate(nil).Parse(s); err != nil {
+		return errors.New(fmt.Sprintf("invalid template %v: %v", s, err))
+	}
+	return nil
+}
+
+// Returns the names of the node policy properties that the template in the string refers to, through the property
+// function, a .Properties field or an index of .Properties. It returns true instead of the names when the template uses
+// the properties in another way, e.g. ranges over them, so that it depends on all of them.
+func TemplateProperties(s string) ([]string, bool, error) {
+	if !IsTemplate(s) {
+		return nil, false, nil
+	}
+
+	tmpl, err := newTemplate(nil).Parse(s)
+	if err != nil {
+		return nil, false, errors.New(fmt.Sprintf("invalid template %v: %v", s, err))
+	}
+
+	names := make(map[string]bool)
+	all := false
+	var walk func(node parse.Node)
+	walk = func(node parse.Node) {
+		switch n := node.(type) {
+		case *parse.ListNode:
+			if n != nil {
+				for _, c := range n.Nodes {
+					walk(c)
+				}
+			}
+		case *parse.ActionNode:
+			walk(n.Pipe)
+		case *parse.IfNode:
+			walk(n.Pipe)
+			walk(n.List)
+			walk(n.ElseList)
+		case *parse.RangeNode:
+			walk(n.Pipe)
+			walk(n.List)
+			walk(n.ElseList)
+		case *parse.WithNode:
+			walk(n.Pipe)
+			walk(n.List)
+			walk(n.ElseList)
+		case *parse.TemplateNode:
+			walk(n.Pipe)
+		case *parse.PipeNode:
+			if n != nil {
+				for _, c := range n.Cmds {
+					walk(c)
+				}
+			}
+		case *parse.CommandNode:
+			if len(n.Args) >= 2 {
+				if ident, ok := n.Args[0].(*parse.IdentifierNode); ok && ident.Ident == "property" {
+					if name, ok := n.Args[1].(*parse.StringNode); ok {
+						names[name.Text] = true
+						return
+					}
+				} else if ok && ident.Ident == "index" && len(n.Args) == 3 {
+					if field, ok := n.Args[1].(*parse.FieldNode); ok && len(field.Ident) == 1 && field.Ident[0] == "Properties" {
+						if name, ok := n.Args[2].(*parse.StringNode); ok {
+							names[name.Text] = true
+							return
+						}
+					}
+				}
+			}
+			for _, arg := range n.Args {
+				walk(arg)
+			}
+		case *parse.FieldNode:
+			if n.Ident[0] == "Properties" {
+				if len(n.Ident) > 1 {
+					names[n.Ident[1]] = true
+				} else {
+					all = true
+				}
+			}
+		case *parse.VariableNode:
+			if len(n.Ident) > 1 && n.Ident[0] == "$" && n.Ident[1] == "Properties" {
+				if len(n.Ident) > 2 {
+					names[n.Ident[2]] = true
+				} else {
+					all = true
+				}
+			} else if len(n.Ident) == 1 {
+				all = true
+			}
+		case *parse.IdentifierNode:
+			if n.Ident == "property" {
+				all = true
+			}
+		case *parse.DotNode, *parse.ChainNode:
+			all = true
+		}
+	}
+	walk(tmpl.Tree.Root)
+
+	if all {
+		return nil, true, nil
+	}
+	result := make([]string, 0, len(names))
+	for name := range names {
+		result = append(result, name)
+	}
+	sort.Strings(result)
+	return result, false, nil
+}
+
+// Expand the template in the string with the given data. A string that is not a template is returned as is.
+func ExpandTemplate(s string, data *TemplateData) (string, error) {
+	if !IsTemplate(s) {
+		return s, nil
+	} else if data == nil {
+		return "", errors.New(fmt.Sprintf("no values to expand template %v", s))
+	}
+
+	tmpl, err := newTemplate(data).Parse(s)
+	if err != nil {
+		return "", errors.New(fmt.Sprintf("invalid template %v: %v", s, err))
+	}
+
+	var out bytes.Buffer
+	if err := tmpl.Execute(&out, data); err != nil {
+		return "", errors.New(fmt.Sprintf("unable to expand template %v: %v", s, err))
+	}
+	return out.String(), nil
+}
@@ -0,0 +1,96 @@
+// +build unit
+
+package cutil
+
+import (
+	"fmt"
+	"testing"
+)
+
+func Test_ExpandTemplate(t *testing.T) {
+
+	data := &TemplateData{
+		NodeId:         "node1",
+		NodeOrg:        "myorg",
+		Pattern:        "mypattern",
+		AgreementId:    "ag1",
+		ServiceURL:     "my.com.svc",
+		ServiceOrg:     "svcorg",
+		ServiceVersion: "1.2.3",
+		Properties:     map[string]interface{}{"site_id": "s42", "cores": 4},
+	}
+
+	tests := []struct {
+		in  string
+		out string
+	}{
+		{"plain value", "plain value"},
+		{"{{.NodeId}} is not a template", "{{.NodeId}} is not a template"},
+		{"{%.NodeOrg%}/{%.NodeId%}", "myorg/node1"},
+		{"{%.Pattern%} {%.AgreementId%}", "mypattern ag1"},
+		{"{%.ServiceOrg%}/{%.ServiceURL%}_{%.ServiceVersion%}", "svcorg/my.com.svc_1.2.3"},
+		{`SITE={%property "site_id"%}`, "SITE=s42"},
+		{`{%property "cores"%}`, "4"},
+		{`{%property "zone" "default"%}`, "default"},
+		{`{%index .Properties "site_id"%}`, "s42"},
+	}
+	for _, test := range tests {
+		if out, err := ExpandTemplate(test.in, data); err != nil {
+			t.Errorf("ExpandTemplate(%v) returned error %v", test.in, err)
+		} else if out != test.out {
+			t.Errorf("ExpandTemplate(%v) returned %v, expected %v", test.in, out, test.out)
+		}
+	}
+
+	for _, in := range []string{`{%property "zone"%}`, `{%.Unknown%}`, `{%.NodeId`, `{%property "a" "b" "c"%}`} {
+		if _, err := ExpandTemplate(in, data); err == nil {
+			t.Errorf("ExpandTemplate(%v) should have returned an error", in)
+		}
+	}
+
+	if _, err := ExpandTemplate("{%.NodeId%}", nil); err == nil {
+		t.Errorf("ExpandTemplate without data should have returned an error")
+	} else if out, err := ExpandTemplate("value", nil); err != nil || out != "value" {
+		t.Errorf("ExpandTemplate without data should have returned the value, got %v %v", out, err)
+	}
+}
+
+func Test_VerifyTemplate(t *testing.T) {
+	for _, in := range []string{"plain", "{{.NodeId", "{%.NodeId%}", `{%property "site_id"%}`, `{%property "site_id" "none"%}`} {
+		if err := VerifyTemplate(in); err != nil {
+			t.Errorf("VerifyTemplate(%v) returned error %v", in, err)
+		}
+	}
+	for _, in := range []string{"{%.NodeId", "{%unknownfunc%}"} {
+		if err := VerifyTemplate(in); err == nil {
+			t.Errorf("VerifyTemplate(%v) should have returned an error", in)
+		}
+	}
+}
+
+func Test_TemplateProperties(t *testing.T) {
+	tests := []struct {
+		in    string
+		names string
+		all   bool
+	}{
+		{"plain", "[]", false},
+		{"{%.NodeOrg%}/{%.NodeId%}", "[]", false},
+		{`{%property "site_id"%} {%property "zone" "default"%}`, "[site_id zone]", false},
+		{`{%.Properties.cores%} {%index .Properties "site_id"%}`, "[cores site_id]", false},
+		{`{%if property "site_id"%}{%$.Properties.zone%}{%end%}`, "[site_id zone]", false},
+		{`{%range $k, $v := .Properties%}{%$k%}{%end%}`, "[]", true},
+		{`{%"site_id" | property%}`, "[]", true},
+	}
+	for _, test := range tests {
+		if names, all, err := TemplateProperties(test.in); err != nil {
+			t.Errorf("TemplateProperties(%v) returned error %v", test.in, err)
+		} else if fmt.Sprintf("%v", names) != test.names || all != test.all {
+			t.Errorf("TemplateProperties(%v) returned %v %v, expected %v %v", test.in, names, all, test.names, test.all)
+		}
+	}
+
+	if _, _, err := TemplateProperties("{%.NodeId"); err == nil {
+		t.Errorf("TemplateProperties of an invalid template should have returned an error")
+	}
+}
@@ -3,7 +3,9 @@ package events
 import (
 	"fmt"
 	"github.com/open-horizon/anax/containermessage"
+	"github.com/open-horizon/anax/cutil"
 	"github.com/open-horizon/anax/persistence"
+	"sort"
 	"time"
 )
 
@@ -83,6 +85,7 @@ const (
 	AGBOT_QUIESCE_COMPLETE       EventId = "AGBOT_QUIESCE_COMPLETE"
 	NODE_HEARTBEAT_FAILED        EventId = "HEARTBEAT_FAILED"
 	NODE_HEARTBEAT_RESTORED      EventId = "HEARTBEAT_RESTORED"
+	NODE_TOKEN_ROTATED           EventId = "NODE_TOKEN_ROTATED"
 	UPDATE_NODE_USERINPUT        EventId = "UPDATE_USER_INPUT"
 	NODE_PATTERN_CHANGE_SHUTDOWN EventId = "NODE_PATTERN_CHANGE_SHUTDOWN"
 	NODE_PATTERN_CHANGE_REREG    EventId = "NODE_PATTERN_CHANGE_REREG"
@@ -120,6 +123,9 @@ const (
 	AG_TERMINATED EndContractCause = "AG_TERMINATED"
 	AG_ERROR      EndContractCause = "AG_ERROR"
 	AG_FULFILLED  EndContractCause = "AG_FULFILLED"
+
+	// The agbot changed its policy, and might replace the agreement with one for the same service.
+	AG_POLICY_CHANGED EndContractCause = "AG_POLICY_CHANGED"
 )
 
 type Message interface {
@@ -144,12 +150,15 @@ type AgreementLaunchContext struct {
 	AgreementId          string
 	Configure            ContainerConfig
 	ConfigureRaw         []byte
-	EnvironmentAdditions *map[string]string // provided by platform, not but user
-	Microservices        []MicroserviceSpec // for ms split.
+	EnvironmentAdditions *map[string]string  // provided by platform, not but user
+	Secrets              map[string]string   // secret user input, given to the containers as files instead of environment variables
+	Microservices        []MicroserviceSpec  // for ms split.
+	ClusterNamespace     string              // the namespace of a cluster service, set by the deployment policy
+	TemplateData         *cutil.TemplateData // the node and agreement values that the templates in the deployment string refer to
 }
 
 func (c AgreementLaunchContext) String() string {
-	return fmt.Sprintf("AgreementProtocol: %v, AgreementId: %v, Configure: %v, EnvironmentAdditions: %v, Microservices: %v", c.AgreementProtocol, c.AgreementId, c.Configure, c.EnvironmentAdditions, c.Microservices)
+	return fmt.Sprintf("AgreementProtocol: %v, AgreementId: %v, Configure: %v, EnvironmentAdditions: %v, Secrets: %v, Microservices: %v, ClusterNamespace: %v", c.AgreementProtocol, c.AgreementId, c.Configure, c.EnvironmentAdditions, SecretNames(c.Secrets), c.Microservices, c.ClusterNamespace)
 }
 
 func (c AgreementLaunchContext) ShortString() string {
@@ -160,6 +169,16 @@ func (c AgreementLaunchContext) ContainerConfig() ContainerConfig {
 	return c.Configure
 }
 
+// Returns the sorted names of the secrets, only the names of the secrets are logged.
+func SecretNames(secrets map[string]string) []string {
+	names := make([]string, 0, len(secrets))
+	for name := range secrets {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
 type ImageDockerAuth struct {
 	Registry string `json:"registry"`
 	UserName string `json:"username"`
@@ -211,16 +230,18 @@ type BlockchainConfig struct {
 type ContainerLaunchContext struct {
 	Configure            ContainerConfig
 	EnvironmentAdditions *map[string]string
+	Secrets              map[string]string // secret user input, given to the containers as files instead of environment variables
 	Blockchain           BlockchainConfig
 	Name                 string // used as the docker network name and part of container name. For microservice it is the ms instance key
 	AgreementIds         []string
 	Microservices        []MicroserviceSpec                     // Service dependencies go here. Microservices (in the workload/microservice model) never have dependencies.
 	ServicePathElement   persistence.ServiceInstancePathElement // The service that we're trying to start.
 	IsRetry              bool
+	TemplateData         *cutil.TemplateData // the node and agreement values that the templates in the deployment string refer to
 }
 
 func (c ContainerLaunchContext) String() string {
-	return fmt.Sprintf("ContainerConfig: %v, EnvironmentAdditions: %v, Blockchain: %v, Name: %v, AgreementIds: %v, ServiceDependencies: %v, ThisService: %v, IsRetry: %v", c.Configure, c.EnvironmentAdditions, c.Blockchain, c.Name, c.AgreementIds, c.Microservices, c.ServicePathElement, c.IsRetry)
+	return fmt.Sprintf("ContainerConfig: %v, EnvironmentAdditions: %v, Secrets: %v, Blockchain: %v, Name: %v, AgreementIds: %v, ServiceDependencies: %v, ThisService: %v, IsRetry: %v", c.Configure, c.EnvironmentAdditions, SecretNames(c.Secrets), c.Blockchain, c.Name, c.AgreementIds, c.Microservices, c.ServicePathElement, c.IsRetry)
 }
 
 func (c ContainerLaunchContext) ShortString() string {
@@ -1800,6 +1821,43 @@ func NewNodeHeartbeatStateChangeMessage(id EventId, node_org string, node_id str
 	}
 }
 
+// This event indicates that the node's exchange token was replaced by a new token.
+type NodeTokenRotatedMessage struct {
+	event    Event
+	deviceId string
+	token    string
+}
+
+func (w *NodeTokenRotatedMessage) Event() Event {
+	return w.event
+}
+
+func (w *NodeTokenRotatedMessage) String() string {
+	return w.ShortString()
+}
+
+func (w *NodeTokenRotatedMessage) ShortString() string {
+	return fmt.Sprintf("Event: %v, DeviceId: %v, Token: %v", w.event, w.deviceId, "********")
+}
+
+func (w *NodeTokenRotatedMessage) DeviceId() string {
+	return w.deviceId
+}
+
+func (w *NodeTokenRotatedMessage) Token() string {
+	return w.token
+}
+
+func NewNodeTokenRotatedMessage(id EventId, deviceId string, token string) *NodeTokenRotatedMessage {
+	return &NodeTokenRotatedMessage{
+		event: Event{
+			Id: id,
+		},
+		deviceId: deviceId,
+		token:    token,
+	}
+}
+
 type ServiceConfigState struct {
 	Url         string `json:"url"`
 	Org         string `json:"org"`
@@ -60,6 +60,12 @@ func (w *ExchangeMessageWorker) NewEvent(incoming events.Message) {
 		msg, _ := incoming.(*events.EdgeRegisteredExchangeMessage)
 		w.EC = worker.NewExchangeContext(fmt.Sprintf("%v/%v", msg.Org(), msg.DeviceId()), msg.Token(), w.Config.Edge.ExchangeURL, w.Config.GetCSSURL(), newLimitedRetryHTTPFactory(w.Config.Collaborators.HTTPClientFactory))
 
+	case *events.NodeTokenRotatedMessage:
+		msg, _ := incoming.(*events.NodeTokenRotatedMessage)
+		if w.EC != nil {
+			w.EC = w.EC.WithToken(msg.Token())
+		}
+
 	case *events.NodeShutdownCompleteMessage:
 		msg, _ := incoming.(*events.NodeShutdownCompleteMessage)
 		switch msg.Event().Id {
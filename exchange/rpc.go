@@ -393,6 +393,7 @@ type PatchDeviceRequest struct {
 	Pattern            string              `json:"pattern,omitempty"`
 	Arch               string              `json:"arch,omitempty"`
 	RegisteredServices *[]Microservice     `json:"registeredServices,omitempty"`
+	Token              string              `json:"token,omitempty"`
 }
 
 func (p PatchDeviceRequest) String() string {
@@ -65,12 +65,63 @@ func (sd ServiceDependency) GetVersionRange() string {
 type UserInput struct {
 	Name         string `json:"name"`
 	Label        string `json:"label"`
-	Type         string `json:"type"` // Valid values are "string", "int", "float", "boolean", "list of strings"
+	Type         string `json:"type"` // Valid values are "string", "int", "float", "boolean", "list of strings", "secret"
 	DefaultValue string `json:"defaultValue"`
+	policy.InputConstraints
 }
 
 func (ui UserInput) String() string {
-	return fmt.Sprintf("{Name: %v, :Label: %v, Type: %v, DefaultValue: %v}", ui.Name, ui.Label, ui.Type, ui.DefaultValue)
+	if ui.InputConstraints.IsEmpty() && !ui.Sensitive && ui.Description == "" {
+		return fmt.Sprintf("{Name: %v, :Label: %v, Type: %v, DefaultValue: %v}", ui.Name, ui.Label, ui.Type, ui.DefaultValue)
+	}
+	return fmt.Sprintf("{Name: %v, :Label: %v, Type: %v, DefaultValue: %v, %v}", ui.Name, ui.Label, ui.Type, ui.DefaultValue, ui.InputConstraints)
+}
+
+// Validate the constraints that the service definition declares for the variable.
+func (ui UserInput) Validate() error {
+	return ui.InputConstraints.Validate(ui.Name, ui.Type, ui.DefaultValue)
+}
+
+// Returns true when the variable holds a secret.
+func (ui UserInput) IsSecret() bool {
+	return ui.Type == policy.USERINPUT_TYPE_SECRET
+}
+
+// Returns true when the value of the variable must not be shown. Secrets are always sensitive.
+func (ui UserInput) IsSensitive() bool {
+	return ui.Sensitive || ui.IsSecret()
+}
+
+// Verify that a value of the variable has the declared type and satisfies the declared constraints.
+func (ui UserInput) VerifyValue(value interface{}) error {
+	return policy.VerifyInputValue(value, ui.Type, ui.InputConstraints)
+}
+
+// Returns true when the variable has to be given a value. The given function returns the values that the variables of
+// the service were given.
+func (ui UserInput) IsRequired(userInputs []UserInput, given func(name string) (interface{}, bool)) bool {
+	return ui.InputConstraints.IsRequired(ui.DefaultValue, func(name string) (interface{}, bool) {
+		if v, ok := given(name); ok {
+			return v, true
+		}
+		for _, other := range userInputs {
+			if other.Name == name && other.DefaultValue != "" {
+				if v, err := policy.DefaultValueOfType(other.DefaultValue, other.Type); err == nil {
+					return v, true
+				}
+				return other.DefaultValue, true
+			}
+		}
+		return nil, false
+	})
+}
+
+// Returns the message that tells why a variable that was not given a value has to be.
+func (ui UserInput) RequiredReason() string {
+	if ui.RequiredIf != nil {
+		return fmt.Sprintf("it is required when %v", ui.RequiredIf)
+	}
+	return "it has no default value"
 }
 
 // This is the structure of the object returned on a GET /service.
@@ -153,14 +204,21 @@ func (s *ServiceDefinition) NeedsUserInput() bool {
 	return false
 }
 
-func (s *ServiceDefinition) PopulateDefaultUserInput(envAdds map[string]string) {
+// Add the default values of the user input that is not set. A default value can be a template that is expanded with
+// the node and agreement values of the data.
+func (s *ServiceDefinition) PopulateDefaultUserInput(envAdds map[string]string, data *cutil.TemplateData) error {
 	for _, ui := range s.UserInputs {
 		if ui.DefaultValue != "" {
 			if _, ok := envAdds[ui.Name]; !ok {
-				envAdds[ui.Name] = ui.DefaultValue
+				if value, err := cutil.ExpandTemplate(ui.DefaultValue, data); err != nil {
+					return fmt.Errorf("unable to set the default value of %v: %v", ui.Name, err)
+				} else {
+					envAdds[ui.Name] = value
+				}
 			}
 		}
 	}
+	return nil
 }
 
 func (s *ServiceDefinition) GetDeploymentString() string {
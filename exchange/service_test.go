@@ -5,6 +5,7 @@ package exchange
 import (
 	"errors"
 	"flag"
+	"github.com/open-horizon/anax/cutil"
 	"testing"
 )
 
@@ -385,9 +386,9 @@ func TestService_PopulateDefaultUserInput(t *testing.T) {
 	}
 
 	envAdds := make(map[string]string)
-	s.PopulateDefaultUserInput(envAdds)
-
-	if len(envAdds) != 1 {
+	if err := s.PopulateDefaultUserInput(envAdds, nil); err != nil {
+		t.Errorf("Should not have returned an error, got %v", err)
+	} else if len(envAdds) != 1 {
 		t.Errorf("Should have populated 1 entry in the map. Map is %v", envAdds)
 	} else if val, ok := envAdds[targetName]; !ok {
 		t.Errorf("Should have entry for %v, have %v", targetName, envAdds)
@@ -395,6 +396,21 @@ func TestService_PopulateDefaultUserInput(t *testing.T) {
 		t.Errorf("Should have value of %v, have %v", targetValue, envAdds)
 	}
 
+	// a template default is expanded with the node values
+	s.UserInputs[1].DefaultValue = `{%.NodeId%}-{%property "site" "none"%}`
+	envAdds = make(map[string]string)
+	data := &cutil.TemplateData{NodeId: "node1", Properties: map[string]interface{}{"site": "s42"}}
+	if err := s.PopulateDefaultUserInput(envAdds, data); err != nil {
+		t.Errorf("Should not have returned an error, got %v", err)
+	} else if envAdds["name2"] != "node1-s42" {
+		t.Errorf("Should have expanded the default value, have %v", envAdds)
+	}
+
+	// a template default cannot be expanded without the node values
+	if err := s.PopulateDefaultUserInput(make(map[string]string), nil); err == nil {
+		t.Errorf("Should have returned an error for a template without values")
+	}
+
 }
 
 func TestService_GetDeployment(t *testing.T) {
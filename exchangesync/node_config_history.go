@@ -0,0 +1,137 @@
+package exchangesync
+
+import (
+	"fmt"
+	"github.com/boltdb/bolt"
+	"github.com/golang/glog"
+	"github.com/open-horizon/anax/exchange"
+	"github.com/open-horizon/anax/externalpolicy"
+	"github.com/open-horizon/anax/persistence"
+	"github.com/open-horizon/anax/policy"
+)
+
+// Add the node user input to the history. The history is a convenience for the user, so a failure is logged and
+// does not fail the update.
+func recordNodeUserInputVersion(db *bolt.DB, userInput []policy.UserInput) {
+	if _, err := persistence.AddNodeUserInputVersion(db, userInput); err != nil {
+		glog.Errorf("Failed to add the node user input to the history. %v", err)
+	}
+}
+
+// Add the node policy to the history. The read-only built-in properties and the properties from the node property
+// providers are left out. They are added again when a version is restored, and the history should only change
+// when the user changes the node policy.
+func recordNodePolicyVersion(db *bolt.DB, nodePolicy *externalpolicy.ExternalPolicy) {
+	if nodePolicy != nil {
+		readOnly := externalpolicy.PropertyList{}
+		for _, name := range externalpolicy.ListReadOnlyProperties() {
+			readOnly = append(readOnly, externalpolicy.Property{Name: name})
+		}
+		if providerProps, err := persistence.FindNodeProviderProperties(db); err != nil {
+			glog.Errorf("Failed to retrieve the node provider properties from local db: %v", err)
+		} else {
+			readOnly = append(readOnly, providerProps...)
+		}
+
+		userPolicy := &externalpolicy.ExternalPolicy{Properties: externalpolicy.PropertyList{}, Constraints: nodePolicy.Constraints}
+		for _, prop := range nodePolicy.Properties {
+			if !readOnly.HasProperty(prop.Name) {
+				userPolicy.Properties = append(userPolicy.Properties, prop)
+			}
+		}
+		nodePolicy = userPolicy
+	}
+
+	if _, err := persistence.AddNodePolicyVersion(db, nodePolicy); err != nil {
+		glog.Errorf("Failed to add the node policy to the history. %v", err)
+	}
+}
+
+// Find the version to roll back to. Version 0 is the version before the current one. It is an error to roll back
+// to the current content.
+func RollbackTarget(versions []persistence.NodeConfigVersion, version uint64) (*persistence.NodeConfigVersion, error) {
+	if len(versions) == 0 {
+		return nil, fmt.Errorf("there is no history to roll back to")
+	}
+
+	current := versions[len(versions)-1]
+	var target *persistence.NodeConfigVersion
+	if version == 0 {
+		if len(versions) < 2 {
+			return nil, fmt.Errorf("there is no version before the current version %v", current.Version)
+		}
+		target = &versions[len(versions)-2]
+	} else if target = persistence.FindNodeConfigVersion(versions, version); target == nil {
+		return nil, fmt.Errorf("version %v is not in the history", version)
+	}
+
+	if target.SameContent(current) {
+		return nil, fmt.Errorf("version %v is the same as the current version %v", target.Version, current.Version)
+	}
+	return target, nil
+}
+
+// Restore a previous version of the node user input on the local db and the exchange. Version 0 restores the version
+// before the current one. It returns the restored version and the services whose user input changed.
+func RollbackNodeUserInput(pDevice *persistence.ExchangeDevice, db *bolt.DB, version uint64, automatic bool,
+	getDevice exchange.DeviceHandler,
+	patchDevice exchange.PatchDeviceHandler) (*persistence.NodeConfigVersion, persistence.ServiceSpecs, error) {
+
+	versions, err := persistence.FindNodeUserInputHistory(db)
+	if err != nil {
+		return nil, nil, fmt.Errorf("Unable to read the node user input history. %v", err)
+	}
+
+	target, err := RollbackTarget(versions, version)
+	if err != nil {
+		return nil, nil, fmt.Errorf("Unable to roll back the node user input, %v", err)
+	}
+
+	userInput := target.UserInput
+	if userInput == nil {
+		userInput = []policy.UserInput{}
+	}
+
+	changedSvcs, err := UpdateNodeUserInput(pDevice, db, userInput, getDevice, patchDevice)
+	if err != nil {
+		return nil, nil, err
+	} else if err := persistence.MarkNodeUserInputRollback(db, target.Version, automatic); err != nil {
+		glog.Errorf("Failed to record the rollback to version %v in the node user input history. %v", target.Version, err)
+	}
+
+	glog.V(3).Infof("Rolled back the node user input to version %v: %v", target.Version, userInput)
+	return target, changedSvcs, nil
+}
+
+// Restore a previous version of the node policy on the local db and the exchange. Version 0 restores the version
+// before the current one. The built-in properties of the node are added to the restored policy.
+func RollbackNodePolicy(pDevice *persistence.ExchangeDevice, db *bolt.DB, version uint64,
+	nodeGetPolicyHandler exchange.NodePolicyHandler,
+	nodePutPolicyHandler exchange.PutNodePolicyHandler,
+	nodeDeletePolicyHandler exchange.DeleteNodePolicyHandler) (*persistence.NodeConfigVersion, error) {
+
+	versions, err := persistence.FindNodePolicyHistory(db)
+	if err != nil {
+		return nil, fmt.Errorf("Unable to read the node policy history. %v", err)
+	}
+
+	target, err := RollbackTarget(versions, version)
+	if err != nil {
+		return nil, fmt.Errorf("Unable to roll back the node policy, %v", err)
+	}
+
+	if target.Deleted || target.Policy == nil {
+		err = DeleteNodePolicy(pDevice, db, nodeGetPolicyHandler, nodeDeletePolicyHandler)
+	} else {
+		nodePolicy := *target.Policy
+		err = UpdateNodePolicy(pDevice, db, &nodePolicy, nodeGetPolicyHandler, nodePutPolicyHandler)
+	}
+	if err != nil {
+		return nil, err
+	} else if err := persistence.MarkNodePolicyRollback(db, target.Version, false); err != nil {
+		glog.Errorf("Failed to record the rollback to version %v in the node policy history. %v", target.Version, err)
+	}
+
+	glog.V(3).Infof("Rolled back the node policy to version %v: %v", target.Version, target.Policy)
+	return target, nil
+}
@@ -0,0 +1,94 @@
+// +build unit
+
+package exchangesync
+
+import (
+	"github.com/open-horizon/anax/externalpolicy"
+	"github.com/open-horizon/anax/persistence"
+	"github.com/open-horizon/anax/policy"
+	"testing"
+)
+
+// Verify that the node policy history leaves out the built-in properties and that a previous version can be restored.
+func Test_RollbackNodePolicy(t *testing.T) {
+
+	dir, db, err := utsetup()
+	if err != nil {
+		t.Error(err)
+	}
+	defer cleanTestDir(dir)
+
+	pDevice, err := persistence.SaveNewExchangeDevice(db, "testid", "testtoken", "testname", "device", false, "myOrg", "", persistence.CONFIGSTATE_CONFIGURING)
+	if err != nil {
+		t.Errorf("failed to create persisted device, error %v", err)
+	}
+
+	ExchangeNodePolicyLastUpdated = ""
+	ExchangeNodePolicy = nil
+
+	for _, val := range []string{"val1", "val2"} {
+		pol := &externalpolicy.ExternalPolicy{Properties: externalpolicy.PropertyList{*externalpolicy.Property_Factory("prop1", val)}}
+		if err := UpdateNodePolicy(pDevice, db, pol, getDummyNodePolicyHandler(), getDummyPutNodePolicyHandler()); err != nil {
+			t.Errorf("Unexpected error: %v", err)
+		}
+	}
+
+	history, err := persistence.FindNodePolicyHistory(db)
+	if err != nil {
+		t.Errorf("Unexpected error: %v", err)
+	} else if len(history) != 2 {
+		t.Errorf("expected 2 versions, got %v", history)
+	} else {
+		for _, name := range externalpolicy.ListReadOnlyProperties() {
+			if history[1].Policy.Properties.HasProperty(name) {
+				t.Errorf("expected the read-only built-in properties to be left out of the history, got %v", history[1])
+			}
+		}
+	}
+
+	// version 0 restores the version before the current one
+	if target, err := RollbackNodePolicy(pDevice, db, 0, getDummyNodePolicyHandler(), getDummyPutNodePolicyHandler(), getDummyDeleteNodePolicyHandler()); err != nil {
+		t.Errorf("Unexpected error: %v", err)
+	} else if target.Version != 1 {
+		t.Errorf("expected a rollback to version 1, got %v", target)
+	} else if fnp, err := persistence.FindNodePolicy(db); err != nil {
+		t.Errorf("failed to find node policy in db, error %v", err)
+	} else if len(fnp.Properties) != 1+NUM_BUILT_INS {
+		t.Errorf("expected the built-in properties to be added to the restored policy, got %v", *fnp)
+	} else if prop, err := fnp.Properties.GetProperty("prop1"); err != nil || prop.Value != "val1" {
+		t.Errorf("expected prop1 to be restored to val1, got %v", *fnp)
+	}
+
+	if history, err := persistence.FindNodePolicyHistory(db); err != nil {
+		t.Errorf("Unexpected error: %v", err)
+	} else if len(history) != 3 || history[2].RolledBackTo != 1 || history[2].Automatic {
+		t.Errorf("expected version 3 to record the rollback to version 1, got %v", history)
+	}
+
+	// the current content cannot be restored again
+	if _, err := RollbackNodePolicy(pDevice, db, 1, getDummyNodePolicyHandler(), getDummyPutNodePolicyHandler(), getDummyDeleteNodePolicyHandler()); err == nil {
+		t.Errorf("expected an error restoring the current content")
+	} else if _, err := RollbackNodePolicy(pDevice, db, 10, getDummyNodePolicyHandler(), getDummyPutNodePolicyHandler(), getDummyDeleteNodePolicyHandler()); err == nil {
+		t.Errorf("expected an error restoring a version that is not in the history")
+	}
+}
+
+func Test_RollbackTarget(t *testing.T) {
+
+	ui := func(v string) []policy.UserInput {
+		return []policy.UserInput{{ServiceOrgid: "myorg", ServiceUrl: "svc1", Inputs: []policy.Input{{Name: "var1", Value: v}}}}
+	}
+	history := []persistence.NodeConfigVersion{{Version: 3, UserInput: ui("a")}, {Version: 4, UserInput: ui("b")}, {Version: 5, UserInput: ui("a")}}
+
+	if _, err := RollbackTarget(nil, 0); err == nil {
+		t.Errorf("expected an error without a history")
+	} else if _, err := RollbackTarget(history[:1], 0); err == nil {
+		t.Errorf("expected an error without a previous version")
+	} else if target, err := RollbackTarget(history, 0); err != nil || target.Version != 4 {
+		t.Errorf("expected version 4, got %v, error %v", target, err)
+	} else if _, err := RollbackTarget(history, 3); err == nil {
+		t.Errorf("expected an error for a version with the current content")
+	} else if _, err := RollbackTarget(history, 2); err == nil {
+		t.Errorf("expected an error for a version that is not in the history")
+	}
+}
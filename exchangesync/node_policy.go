@@ -47,6 +47,7 @@ func SyncNodePolicyWithExchange(db *bolt.DB, pDevice *persistence.ExchangeDevice
 			} else if err := persistence.SaveNodePolicyLastUpdated_Exch(db, exchangeNodePolicy.GetLastUpdated()); err != nil {
 				return false, nil, fmt.Errorf("unable to save the exchange node policy last update string %v to local database. %v", nodePolicyLastUpdated, err)
 			} else {
+				recordNodePolicyVersion(db, &newNodePolicy)
 				glog.V(3).Infof("Updated the local node policy with the exchange copy: %v", newNodePolicy)
 				return true, &newNodePolicy, nil
 			}
@@ -66,6 +67,7 @@ func SyncNodePolicyWithExchange(db *bolt.DB, pDevice *persistence.ExchangeDevice
 			if err := persistence.DeleteNodePolicy(db); err != nil {
 				return false, nil, fmt.Errorf("Node policy could not be deleted, error %v", err)
 			}
+			recordNodePolicyVersion(db, nil)
 			updated = true
 		}
 
@@ -95,9 +97,9 @@ func GetProcessedExchangeNodePolicy(pDevice *persistence.ExchangeDevice, getExch
 	builtinPolicyReadOnly := &externalpolicy.ExternalPolicy{}
 	builtinPolicyReadWrite := &externalpolicy.ExternalPolicy{}
 	if (exchangeNodePolicy != nil && exchangeNodePolicy.Properties.HasProperty(externalpolicy.PROP_NODE_HARDWAREID)) || (existingPol != nil && existingPol.Properties.HasProperty(externalpolicy.PROP_NODE_HARDWAREID)) {
-		builtinPolicyReadOnly, builtinPolicyReadWrite = externalpolicy.CreateNodeBuiltInPolicy(false, true, existingPol, pDevice.IsEdgeCluster())
+		builtinPolicyReadOnly, builtinPolicyReadWrite = nodeBuiltInPolicy(db, true, existingPol, pDevice.IsEdgeCluster())
 	} else {
-		builtinPolicyReadOnly, builtinPolicyReadWrite = externalpolicy.CreateNodeBuiltInPolicy(false, false, existingPol, pDevice.IsEdgeCluster())
+		builtinPolicyReadOnly, builtinPolicyReadWrite = nodeBuiltInPolicy(db, false, existingPol, pDevice.IsEdgeCluster())
 	}
 
 	builtinPolicy := &externalpolicy.ExternalPolicy{}
@@ -168,7 +170,7 @@ func SetDefaultNodePolicy(config *config.HorizonConfig, pDevice *persistence.Exc
 	if err != nil {
 		glog.V(2).Infof("Failed to retrieve node policy from local db: %v", err)
 	}
-	builtinNodePol, builtinNodePolReadWrite := externalpolicy.CreateNodeBuiltInPolicy(false, false, existingPol, pDevice.IsEdgeCluster())
+	builtinNodePol, builtinNodePolReadWrite := nodeBuiltInPolicy(db, false, existingPol, pDevice.IsEdgeCluster())
 	builtinNodePol.MergeWith(builtinNodePolReadWrite, true)
 
 	// get the default node policy file name from the config and set it up in local and exchange
@@ -334,6 +336,7 @@ func DeleteNodePolicy(pDevice *persistence.ExchangeDevice, db *bolt.DB,
 	if err := persistence.DeleteNodePolicy(db); err != nil {
 		return fmt.Errorf("Node policy could not be deleted, error %v", err)
 	}
+	recordNodePolicyVersion(db, nil)
 
 	if err := persistence.DeleteNodePolicyLastUpdated_Exch(db); err != nil {
 		return fmt.Errorf("Exchange node policy last update string could not be deleted from the local database, error %v", err)
@@ -356,7 +359,7 @@ func UpdateNodePolicy(pDevice *persistence.ExchangeDevice, db *bolt.DB, nodePoli
 	if err != nil {
 		glog.V(2).Infof("Failed to retrieve node policy from local db: %v", err)
 	}
-	builtinNodePol, builtinNodePolReadWrite := externalpolicy.CreateNodeBuiltInPolicy(false, false, existingPol, pDevice.IsEdgeCluster())
+	builtinNodePol, builtinNodePolReadWrite := nodeBuiltInPolicy(db, false, existingPol, pDevice.IsEdgeCluster())
 
 	if builtinNodePol != nil {
 		nodePolicy.MergeWith(builtinNodePol, true)
@@ -407,6 +410,13 @@ func PatchNodePolicy(pDevice *persistence.ExchangeDevice, db *bolt.DB, patchObje
 		return nil, fmt.Errorf("Unable to determine type of patch. %T %v", patchObject, patchObject)
 	}
 
+	// the properties from the node property providers are read-only
+	if providerProps, err := persistence.FindNodeProviderProperties(db); err != nil {
+		return nil, fmt.Errorf("Unable to read the node provider properties. %v", err)
+	} else {
+		localNodePolicy.Properties.MergeWith(&providerProps, true)
+	}
+
 	if err := localNodePolicy.Validate(); err != nil {
 		return nil, err
 	}
@@ -420,3 +430,99 @@ func PatchNodePolicy(pDevice *persistence.ExchangeDevice, db *bolt.DB, patchObje
 
 	return localNodePolicy, nil
 }
+
+// Returns the read-only and the read-write built-in policies of the node. The properties that the node property
+// providers reported last are added to the read-only built-ins.
+func nodeBuiltInPolicy(db *bolt.DB, omitGenHwId bool, existingPol *externalpolicy.ExternalPolicy, cluster bool) (*externalpolicy.ExternalPolicy, *externalpolicy.ExternalPolicy) {
+	builtinPolicyReadOnly, builtinPolicyReadWrite := externalpolicy.CreateNodeBuiltInPolicy(false, omitGenHwId, existingPol, cluster)
+
+	if providerProps, err := persistence.FindNodeProviderProperties(db); err != nil {
+		glog.Errorf("Failed to retrieve the node provider properties from local db: %v", err)
+	} else if len(providerProps) != 0 {
+		if builtinPolicyReadOnly == nil {
+			builtinPolicyReadOnly = &externalpolicy.ExternalPolicy{}
+		}
+		builtinPolicyReadOnly.Properties.MergeWith(&providerProps, true)
+	}
+	return builtinPolicyReadOnly, builtinPolicyReadWrite
+}
+
+// Save the properties from the node property providers and, when they changed, update the node policy on the local
+// db and the exchange with them. Properties that a provider no longer reports are removed from the node policy. It
+// returns true when the node policy was updated.
+func UpdateNodeProviderProperties(pDevice *persistence.ExchangeDevice, db *bolt.DB, providerProps externalpolicy.PropertyList,
+	nodeGetPolicyHandler exchange.NodePolicyHandler,
+	nodePutPolicyHandler exchange.PutNodePolicyHandler) (bool, error) {
+
+	oldProps, err := persistence.FindNodeProviderProperties(db)
+	if err != nil {
+		return false, fmt.Errorf("Unable to read the node provider properties. %v", err)
+	} else if len(oldProps) == len(providerProps) && oldProps.IsSame(providerProps) && providerProps.IsSame(oldProps) {
+		return false, nil
+	}
+
+	localNodePolicy, err := persistence.FindNodePolicy(db)
+	if err != nil {
+		return false, fmt.Errorf("Unable to read local node policy object. %v", err)
+	}
+
+	if err := persistence.SaveNodeProviderProperties(db, providerProps); err != nil {
+		return false, fmt.Errorf("Unable to save the node provider properties. %v", err)
+	}
+
+	// until the node policy is set up there is nothing to update, the properties are added when it is set up
+	if localNodePolicy == nil {
+		return false, nil
+	}
+
+	nodePolicy := &externalpolicy.ExternalPolicy{Constraints: localNodePolicy.Constraints}
+	for _, prop := range localNodePolicy.Properties {
+		if !oldProps.HasProperty(prop.Name) || providerProps.HasProperty(prop.Name) {
+			nodePolicy.Properties = append(nodePolicy.Properties, prop)
+		}
+	}
+
+	if err := UpdateNodePolicy(pDevice, db, nodePolicy, nodeGetPolicyHandler, nodePutPolicyHandler); err != nil {
+		// restore the old properties so that the update is tried again on the next collection
+		if err := persistence.SaveNodeProviderProperties(db, oldProps); err != nil {
+			glog.Errorf("Unable to restore the node provider properties. %v", err)
+		}
+		return false, err
+	}
+	return true, nil
+}
+
+// Check whether the read-only built-in properties of the node changed, e.g. after an os or docker upgrade, and update
+// the node policy on the local db and the exchange with the new values. It returns true when the node policy was updated.
+func RefreshNodeBuiltInProperties(pDevice *persistence.ExchangeDevice, db *bolt.DB,
+	nodeGetPolicyHandler exchange.NodePolicyHandler,
+	nodePutPolicyHandler exchange.PutNodePolicyHandler) (bool, error) {
+
+	localNodePolicy, err := persistence.FindNodePolicy(db)
+	if err != nil {
+		return false, fmt.Errorf("Unable to read local node policy object. %v", err)
+	} else if localNodePolicy == nil {
+		return false, nil
+	}
+
+	builtinNodePol, _ := nodeBuiltInPolicy(db, true, localNodePolicy, pDevice.IsEdgeCluster())
+	if builtinNodePol == nil {
+		return false, nil
+	}
+
+	changed := false
+	for _, prop := range builtinNodePol.Properties {
+		if current, err := localNodePolicy.Properties.GetProperty(prop.Name); err != nil || !current.IsSame(prop) {
+			glog.V(3).Infof("Node built-in property %v changed to %v.", prop.Name, prop.Value)
+			changed = true
+		}
+	}
+	if !changed {
+		return false, nil
+	}
+
+	if err := UpdateNodePolicy(pDevice, db, localNodePolicy, nodeGetPolicyHandler, nodePutPolicyHandler); err != nil {
+		return false, err
+	}
+	return true, nil
+}
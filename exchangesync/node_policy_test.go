@@ -23,6 +23,12 @@ var ExchangeNodePolicy *externalpolicy.ExternalPolicy
 
 const NUM_BUILT_INS = 5
 
+// The os, kernel and network properties of the machine that runs the tests are left out, so that the number of
+// built-in properties is the same everywhere.
+func init() {
+	externalpolicy.SetSystemPropertySources("/nonexistent/os-release", "/nonexistent/osrelease", "/nonexistent/net")
+}
+
 // Verify that a Node Policy Object can be created and saved the first time.
 func Test_UpdateNodePolicy(t *testing.T) {
 
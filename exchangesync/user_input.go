@@ -64,20 +64,24 @@ func SyncLocalUserInputWithExchange(db *bolt.DB, pDevice *persistence.ExchangeDe
 			return true, nil, fmt.Errorf("Failed get user input from local db. %v", err)
 		}
 
+		// the exchange only has the redacted values of the secrets, keep the ones in the local db
+		newUserInput := policy.KeepSecretValues(exchDevice.UserInput, oldUserInput)
+
 		// save exchange node user input to local db
-		if err := persistence.SaveNodeUserInput(db, exchDevice.UserInput); err != nil {
-			return true, nil, fmt.Errorf("Failed save user input %v to local db. %v", exchDevice.UserInput, err)
+		if err := persistence.SaveNodeUserInput(db, newUserInput); err != nil {
+			return true, nil, fmt.Errorf("Failed save user input %v to local db. %v", newUserInput, err)
 		}
+		recordNodeUserInputVersion(db, newUserInput)
 
 		// update the hash
 		if err := persistence.SaveNodeUserInputHash_Exch(db, exchHash); err != nil {
 			return true, nil, fmt.Errorf("Failed to save the user input hash %v to the local db. %v", exchHash, err)
 		}
 
-		glog.V(3).Infof("Node synced with exchange. New node user input is: %v", exchDevice.UserInput)
+		glog.V(3).Infof("Node synced with exchange. New node user input is: %v", newUserInput)
 
 		// Get a list of what services has been changed
-		changedServiceSpecs := GetChangedServices(oldUserInput, exchDevice.UserInput)
+		changedServiceSpecs := GetChangedServices(oldUserInput, newUserInput)
 
 		return true, changedServiceSpecs, nil
 	}
@@ -321,12 +325,20 @@ func UpdateNodeUserInput(pDevice *persistence.ExchangeDevice, db *bolt.DB,
 		}
 	}
 
+	// the local db has the same user input as the exchange now, and it also has the values of the secrets
+	oldUserInput, err := persistence.FindNodeUserInput(db)
+	if err != nil {
+		return nil, fmt.Errorf("Failed get user input from local db. %v", err)
+	} else if oldUserInput == nil {
+		oldUserInput = exchUserInput
+	}
+
 	if err := SaveNodeUserInput(pDevice, db, userInputs, getDevice, patchDevice); err != nil {
 		return nil, fmt.Errorf("Failed to save the user input %v. %v.", userInputs, err)
 	}
 
 	// Get a list of what services has been changed
-	changedServiceSpecs := GetChangedServices(exchUserInput, userInputs)
+	changedServiceSpecs := GetChangedServices(oldUserInput, policy.KeepSecretValues(userInputs, oldUserInput))
 
 	return changedServiceSpecs, nil
 }
@@ -372,8 +384,17 @@ func SaveNodeUserInput(pDevice *persistence.ExchangeDevice, db *bolt.DB,
 		userInputs = []policy.UserInput{}
 	}
 
+	// Secrets that are given with the redacted value keep the value they have in the local db. The exchange only
+	// gets the redacted values of the secrets.
+	if localUserInput, err := persistence.FindNodeUserInput(db); err != nil {
+		return fmt.Errorf("Failed get user input from local db. %v", err)
+	} else {
+		userInputs = policy.KeepSecretValues(userInputs, localUserInput)
+	}
+	exchUserInputs := policy.RedactSecretUserInput(userInputs)
+
 	pdr := exchange.PatchDeviceRequest{}
-	pdr.UserInput = &userInputs
+	pdr.UserInput = &exchUserInputs
 
 	glog.V(3).Infof("Updating exchange with new user input: %v.", pdr)
 	if err := patchDevice(fmt.Sprintf("%v/%v", pDevice.Org, pDevice.Id), pDevice.Token, &pdr); err != nil {
@@ -385,6 +406,7 @@ func SaveNodeUserInput(pDevice *persistence.ExchangeDevice, db *bolt.DB,
 	if err := persistence.SaveNodeUserInput(db, userInputs); err != nil {
 		return fmt.Errorf("Failed save user input %v to local db. %v", userInputs, err)
 	}
+	recordNodeUserInputVersion(db, userInputs)
 
 	// get the node user input from the exchange do that we can get an accurate hash
 	newExchDevice, err := getDevice(fmt.Sprintf("%v/%v", pDevice.Org, pDevice.Id), pDevice.Token)
@@ -1,9 +1,15 @@
 package externalpolicy
 
 import (
+	"fmt"
 	"github.com/golang/glog"
+	"github.com/open-horizon/anax/containerruntime"
 	"github.com/open-horizon/anax/cutil"
+	"regexp"
 	"runtime"
+	"strings"
+	"sync"
+	"time"
 )
 
 // These are built-in property names that can be used in the policies.
@@ -11,12 +17,18 @@ import (
 // The user defined policies (business policy, node policy) need to add constraints on these properties if needed.
 const (
 	// for node policy
-	PROP_NODE_CPU         = "openhorizon.cpu"               // The number of CPUs
-	PROP_NODE_MEMORY      = "openhorizon.memory"            // The amount of memory in MBs
-	PROP_NODE_ARCH        = "openhorizon.arch"              // The hardware architecture of the node (e.g. amd64, armv6, etc)
-	PROP_NODE_HARDWAREID  = "openhorizon.hardwareId"        // The device serial number if it can be found. A generated Id otherwise.
-	PROP_NODE_PRIVILEGED  = "openhorizon.allowPrivileged"   // Property set to determine if privileged services may be run on this device. Can be set by user, default is false.
-	PROP_NODE_K8S_VERSION = "openhorizon.kubernetesVersion" // Server version of the cluster the agent is running in
+	PROP_NODE_CPU                = "openhorizon.cpu"               // The number of CPUs
+	PROP_NODE_MEMORY             = "openhorizon.memory"            // The amount of memory in MBs
+	PROP_NODE_ARCH               = "openhorizon.arch"              // The hardware architecture of the node (e.g. amd64, armv6, etc)
+	PROP_NODE_HARDWAREID         = "openhorizon.hardwareId"        // The device serial number if it can be found. A generated Id otherwise.
+	PROP_NODE_PRIVILEGED         = "openhorizon.allowPrivileged"   // Property set to determine if privileged services may be run on this device. Can be set by user, default is false.
+	PROP_NODE_K8S_VERSION        = "openhorizon.kubernetesVersion" // Server version of the cluster the agent is running in
+	PROP_NODE_OS                 = "openhorizon.os"                // The distribution of the operating system (e.g. ubuntu, rhel, etc)
+	PROP_NODE_OS_VERSION         = "openhorizon.osVersion"         // The version of the operating system distribution
+	PROP_NODE_KERNEL_VERSION     = "openhorizon.kernelVersion"     // The version of the kernel, without the distribution suffix (e.g. 5.4.0)
+	PROP_NODE_FREE_DISK          = "openhorizon.freeDisk"          // The free disk space in MBs where the service storage is, rounded down to a multiple of 1024
+	PROP_NODE_DOCKER_VERSION     = "openhorizon.dockerVersion"     // The version of the docker engine
+	PROP_NODE_NETWORK_INTERFACES = "openhorizon.networkInterfaces" // The number of physical network interfaces
 
 	// for service policy
 	PROP_SVC_URL        = "openhorizon.service.url"     // The unique name of the service.
@@ -25,12 +37,46 @@ const (
 	PROP_SVC_VERSION    = "openhorizon.service.version" // The version of a service using the same semantic version syntax.
 	PROP_SVC_ARCH       = "openhorizon.service.arch"    // The hardware architecture of the node this service can run on.
 	PROP_SVC_PRIVILEGED = "openhorizon.allowPrivileged" // Does the service use workloads that require privileged mode or net==host to run. Can be set by user. Is an error to set to false if service introspection indicates true.
+
+	// for deployment policy
+	PROP_DEPLOY_K8S_NAMESPACE = "openhorizon.kubernetesNamespace" // The namespace that a cluster service is deployed into. By default, each agreement gets a namespace of its own.
 )
 
 const MAX_MEMEORY = 1048576 // the unit is MB. This is 1000G
 
+// The free disk space is rounded down to a multiple of this many MBs, so that the node policy does not change
+// every time a service writes a file.
+const FREE_DISK_ROUNDING_MB = 1024
+
+// Where the built-in properties that depend on the agent configuration are read from. They are set when the
+// agent starts, the properties are omitted until then.
+var serviceStoragePath = ""
+var dockerEndpoint = ""
+
+// Set the service storage path that the free disk space is measured on and the docker endpoint that the docker
+// version is read from.
+func SetBuiltInPropertySources(storagePath string, endpoint string) {
+	serviceStoragePath = storagePath
+	dockerEndpoint = endpoint
+}
+
+// Where the os, kernel and network properties are read from. The default, an empty string, is the file or directory
+// of the system.
+var osReleaseFile = ""
+var kernelReleaseFile = ""
+var sysNetDir = ""
+
+// Set the files that the os and kernel properties are read from and the directory that the network interfaces are
+// counted in, e.g. so that tests do not depend on the machine that runs them.
+func SetSystemPropertySources(osRelease string, kernelRelease string, netDir string) {
+	osReleaseFile = osRelease
+	kernelReleaseFile = kernelRelease
+	sysNetDir = netDir
+}
+
 func ListReadOnlyProperties() []string {
-	return []string{PROP_NODE_CPU, PROP_NODE_ARCH, PROP_NODE_MEMORY, PROP_NODE_HARDWAREID, PROP_NODE_K8S_VERSION}
+	return []string{PROP_NODE_CPU, PROP_NODE_ARCH, PROP_NODE_MEMORY, PROP_NODE_HARDWAREID, PROP_NODE_K8S_VERSION,
+		PROP_NODE_OS, PROP_NODE_OS_VERSION, PROP_NODE_KERNEL_VERSION, PROP_NODE_FREE_DISK, PROP_NODE_DOCKER_VERSION, PROP_NODE_NETWORK_INTERFACES}
 }
 
 // CreateNodeBuiltInPolicy returns 2 externalpolicies.
@@ -65,9 +111,135 @@ func createClusterNodeBuiltInPolicy(availableMem bool) *ExternalPolicy {
 	} else {
 		builtInPol.Add_Property(Property_Factory(PROP_NODE_MEMORY, totMem), false)
 	}
+
+	if osImage, kernel, containerRuntime, err := cutil.GetClusterSystemInfo(); err != nil {
+		glog.V(2).Infof("Error getting cluster system properties: %v", err)
+	} else {
+		addClusterSystemProperties(builtInPol, osImage, kernel, containerRuntime)
+	}
 	return &ExternalPolicy{Properties: *builtInPol}
 }
 
+// Add the os, kernel and docker properties from the system info that the cluster nodes report. The os image is the
+// distribution name followed by its version, e.g. "Ubuntu 20.04.1 LTS". The docker version is only added when the
+// container runtime of the nodes is docker.
+func addClusterSystemProperties(props *PropertyList, osImage string, kernel string, containerRuntime string) {
+	if osImage != "" {
+		name := []string{}
+		version := ""
+		for _, word := range strings.Fields(osImage) {
+			if word[0] >= '0' && word[0] <= '9' {
+				version = word
+				break
+			}
+			name = append(name, word)
+		}
+		props.Add_Property(Property_Factory(PROP_NODE_OS, strings.ToLower(strings.Join(name, " "))), false)
+		if version != "" {
+			props.Add_Property(versionProperty(PROP_NODE_OS_VERSION, version), false)
+		}
+	}
+	if v := leadingVersion(kernel); v != "" {
+		props.Add_Property(versionProperty(PROP_NODE_KERNEL_VERSION, v), false)
+	}
+	if strings.HasPrefix(containerRuntime, "docker://") {
+		if v := leadingVersion(strings.TrimPrefix(containerRuntime, "docker://")); v != "" {
+			props.Add_Property(versionProperty(PROP_NODE_DOCKER_VERSION, v), false)
+		}
+	}
+}
+
+// Add the os, kernel, disk, docker and network properties of the device. A property that cannot be read is left out.
+func addDeviceSystemProperties(props *PropertyList) {
+	if id, version, err := cutil.GetOSRelease(osReleaseFile); err != nil {
+		glog.V(2).Infof("Failed to get the os release for the local node. %v", err)
+	} else {
+		if id != "" {
+			props.Add_Property(Property_Factory(PROP_NODE_OS, id), false)
+		}
+		if version != "" {
+			props.Add_Property(versionProperty(PROP_NODE_OS_VERSION, version), false)
+		}
+	}
+
+	if kernel, err := cutil.GetKernelVersion(kernelReleaseFile); err != nil {
+		glog.V(2).Infof("Failed to get the kernel version for the local node. %v", err)
+	} else if v := leadingVersion(kernel); v != "" {
+		props.Add_Property(versionProperty(PROP_NODE_KERNEL_VERSION, v), false)
+	}
+
+	if serviceStoragePath != "" {
+		if freeMB, err := cutil.GetFreeDiskMB(serviceStoragePath); err != nil {
+			glog.V(2).Infof("Failed to get the free disk space on %v. %v", serviceStoragePath, err)
+		} else {
+			props.Add_Property(Property_Factory(PROP_NODE_FREE_DISK, float64(freeMB-freeMB%FREE_DISK_ROUNDING_MB)), false)
+		}
+	}
+
+	if dockerEndpoint != "" {
+		if version, err := getDockerVersion(); err != nil {
+			glog.V(2).Infof("Failed to get the docker version for the local node. %v", err)
+		} else if v := leadingVersion(version); v != "" {
+			props.Add_Property(versionProperty(PROP_NODE_DOCKER_VERSION, v), false)
+		}
+	}
+
+	if count, err := cutil.GetNetworkInterfaceCount(sysNetDir); err != nil {
+		glog.V(2).Infof("Failed to get the network interfaces for the local node. %v", err)
+	} else {
+		props.Add_Property(Property_Factory(PROP_NODE_NETWORK_INTERFACES, float64(count)), false)
+	}
+}
+
+// The built-in properties are created often, so the docker version is cached instead of asking docker every time.
+var dockerVersionLock sync.Mutex
+var dockerVersion = ""
+var dockerVersionTime time.Time
+
+const DOCKER_VERSION_CACHE_S = 300
+const DOCKER_VERSION_TIMEOUT_S = 10
+
+func getDockerVersion() (string, error) {
+	dockerVersionLock.Lock()
+	defer dockerVersionLock.Unlock()
+
+	if dockerVersion != "" && time.Since(dockerVersionTime) < DOCKER_VERSION_CACHE_S*time.Second {
+		return dockerVersion, nil
+	}
+
+	client, err := containerruntime.NewDockerRuntime(dockerEndpoint)
+	if err != nil {
+		return "", fmt.Errorf("Failed to create a docker client for %v. %v", dockerEndpoint, err)
+	}
+	client.SetTimeout(DOCKER_VERSION_TIMEOUT_S * time.Second)
+
+	info, err := client.Info()
+	if err != nil {
+		return "", fmt.Errorf("Failed to get the docker version from %v. %v", dockerEndpoint, err)
+	}
+	version := info.ServerVersion
+	dockerVersion = version
+	dockerVersionTime = time.Now()
+	return version, nil
+}
+
+// Returns the version at the start of the string, e.g. 5.4.0 for 5.4.0-42-generic, or an empty string.
+func leadingVersion(s string) string {
+	return leadingVersionRegex.FindString(s)
+}
+
+var leadingVersionRegex = regexp.MustCompile(`^[0-9]+(\.[0-9]+){0,2}`)
+
+// Creates a property of the version type when the value is a valid version, so that constraints can compare it with
+// version ranges. Other values, e.g. 20.04 with its leading zero, are kept as strings.
+func versionProperty(name string, value string) *Property {
+	p := Property_Factory(name, value)
+	if IsVersionString(value) {
+		p.Type = VERSION_TYPE
+	}
+	return p
+}
+
 func createDeviceNodeBuiltInPolicy(availableMem bool, omitGenHwId bool, existingPolicy *ExternalPolicy) (*ExternalPolicy, *ExternalPolicy) {
 	nodeBuiltInReadOnlyProps := new(PropertyList)
 	nodeBuiltInReadWriteProps := new(PropertyList)
@@ -137,6 +309,7 @@ func createDeviceNodeBuiltInPolicy(availableMem bool, omitGenHwId bool, existing
 	}
 	nodeBuiltInReadOnlyProps.Add_Property(Property_Factory(PROP_NODE_CPU, float64(cpu)), false)
 	nodeBuiltInReadOnlyProps.Add_Property(Property_Factory(PROP_NODE_ARCH, runtime.GOARCH), false)
+	addDeviceSystemProperties(nodeBuiltInReadOnlyProps)
 
 	nodeBuiltInReadWriteProps.Add_Property(Property_Factory(PROP_NODE_PRIVILEGED, privileged), false)
 
@@ -0,0 +1,121 @@
+// +build unit
+
+package externalpolicy
+
+import (
+	"io/ioutil"
+	"os"
+	"path"
+	"testing"
+	"time"
+)
+
+func Test_addClusterSystemProperties(t *testing.T) {
+
+	props := new(PropertyList)
+	addClusterSystemProperties(props, "Ubuntu 20.04.1 LTS", "5.4.0-42-generic", "docker://19.3.12")
+
+	if p, err := props.GetProperty(PROP_NODE_OS); err != nil || p.Value != "ubuntu" {
+		t.Errorf("expected os ubuntu, got %v %v", p, err)
+	}
+	if p, err := props.GetProperty(PROP_NODE_OS_VERSION); err != nil || p.Value != "20.04.1" {
+		t.Errorf("expected os version 20.04.1, got %v %v", p, err)
+	} else if p.Type == VERSION_TYPE {
+		t.Errorf("expected a version with a leading zero to be a string, got %v", p)
+	}
+	if p, err := props.GetProperty(PROP_NODE_KERNEL_VERSION); err != nil || p.Value != "5.4.0" || p.Type != VERSION_TYPE {
+		t.Errorf("expected kernel version 5.4.0, got %v %v", p, err)
+	}
+	if p, err := props.GetProperty(PROP_NODE_DOCKER_VERSION); err != nil || p.Value != "19.3.12" {
+		t.Errorf("expected docker version 19.3.12, got %v %v", p, err)
+	}
+
+	// nodes that do not agree and a runtime that is not docker leave the properties out
+	props = new(PropertyList)
+	addClusterSystemProperties(props, "Red Hat Enterprise Linux CoreOS 46.82.202012051820-0 (Ootpa)", "", "cri-o://1.19.0")
+	if p, err := props.GetProperty(PROP_NODE_OS); err != nil || p.Value != "red hat enterprise linux coreos" {
+		t.Errorf("expected the os name, got %v %v", p, err)
+	}
+	if props.HasProperty(PROP_NODE_KERNEL_VERSION) || props.HasProperty(PROP_NODE_DOCKER_VERSION) {
+		t.Errorf("expected no kernel or docker version, got %v", props)
+	}
+}
+
+func Test_CreateNodeBuiltInPolicy_systemProperties(t *testing.T) {
+
+	dir, err := ioutil.TempDir("", "builtin-")
+	if err != nil {
+		t.Fatalf("unable to create temp dir, %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	// An os release, a kernel release and a network directory with one physical and one virtual interface.
+	if err := ioutil.WriteFile(path.Join(dir, "os-release"), []byte("NAME=\"Ubuntu\"\nID=ubuntu\nVERSION_ID=\"20.04\"\n"), 0644); err != nil {
+		t.Fatalf("unable to write os release, %v", err)
+	} else if err := ioutil.WriteFile(path.Join(dir, "osrelease"), []byte("5.4.0-42-generic\n"), 0644); err != nil {
+		t.Fatalf("unable to write kernel release, %v", err)
+	} else if err := os.MkdirAll(path.Join(dir, "net", "eth0", "device"), 0755); err != nil {
+		t.Fatalf("unable to create network interface, %v", err)
+	} else if err := os.MkdirAll(path.Join(dir, "net", "lo"), 0755); err != nil {
+		t.Fatalf("unable to create network interface, %v", err)
+	}
+
+	// The docker version is taken from the cache, so that no docker is needed.
+	dockerVersion, dockerVersionTime = "20.10.7", time.Now()
+	SetSystemPropertySources(path.Join(dir, "os-release"), path.Join(dir, "osrelease"), path.Join(dir, "net"))
+	SetBuiltInPropertySources(dir, "unix:///var/run/docker.sock")
+	defer func() {
+		dockerVersion, dockerVersionTime = "", time.Time{}
+		SetSystemPropertySources("", "", "")
+		SetBuiltInPropertySources("", "")
+	}()
+
+	readOnly, _ := CreateNodeBuiltInPolicy(false, true, nil, false)
+
+	expected := map[string]interface{}{
+		PROP_NODE_OS:                 "ubuntu",
+		PROP_NODE_OS_VERSION:         "20.04",
+		PROP_NODE_KERNEL_VERSION:     "5.4.0",
+		PROP_NODE_DOCKER_VERSION:     "20.10.7",
+		PROP_NODE_NETWORK_INTERFACES: float64(1),
+	}
+	for name, value := range expected {
+		if p, err := readOnly.Properties.GetProperty(name); err != nil {
+			t.Errorf("expected property %v, got %v", name, readOnly.Properties)
+		} else if p.Value != value {
+			t.Errorf("expected %v for property %v, got %v", value, name, p.Value)
+		}
+	}
+	if p, err := readOnly.Properties.GetProperty(PROP_NODE_FREE_DISK); err != nil {
+		t.Errorf("expected property %v, got %v", PROP_NODE_FREE_DISK, readOnly.Properties)
+	} else if freeMB, ok := p.Value.(float64); !ok || int64(freeMB)%FREE_DISK_ROUNDING_MB != 0 {
+		t.Errorf("expected the free disk space rounded down to %v MB, got %v", FREE_DISK_ROUNDING_MB, p.Value)
+	}
+
+	// without the sources the properties are left out
+	SetSystemPropertySources(path.Join(dir, "missing"), path.Join(dir, "missing"), path.Join(dir, "missing"))
+	SetBuiltInPropertySources("", "")
+	readOnly, _ = CreateNodeBuiltInPolicy(false, true, nil, false)
+	for _, name := range []string{PROP_NODE_OS, PROP_NODE_OS_VERSION, PROP_NODE_KERNEL_VERSION, PROP_NODE_FREE_DISK, PROP_NODE_DOCKER_VERSION, PROP_NODE_NETWORK_INTERFACES} {
+		if readOnly.Properties.HasProperty(name) {
+			t.Errorf("expected no property %v, got %v", name, readOnly.Properties)
+		}
+	}
+}
+
+func Test_leadingVersion(t *testing.T) {
+	cases := map[string]string{
+		"5.4.0-42-generic": "5.4.0",
+		"4.19.104+":        "4.19.104",
+		"20.10.7":          "20.10.7",
+		"20.10.21+dfsg1":   "20.10.21",
+		"1.2.3.4":          "1.2.3",
+		"unknown":          "",
+		"":                 "",
+	}
+	for in, expected := range cases {
+		if v := leadingVersion(in); v != expected {
+			t.Errorf("expected %v for %v, got %v", expected, in, v)
+		}
+	}
+}
@@ -120,9 +120,9 @@ func (self *PropertyList) Compatible_With(other *PropertyList, ignoreBuiltIn boo
 	for _, self_ele := range *self {
 		for _, other_ele := range *other {
 			if self_ele.Name == other_ele.Name && !self_ele.IsSame(other_ele) {
-				// the built-in properties available memory and cpu could change from time to time.
+				// the built-in properties available memory, cpu and free disk could change from time to time.
 				// so we ingnore the error here if ignoreBuiltIn is true
-				if ignoreBuiltIn && (self_ele.Name == PROP_NODE_MEMORY || self_ele.Name == PROP_NODE_CPU) {
+				if ignoreBuiltIn && (self_ele.Name == PROP_NODE_MEMORY || self_ele.Name == PROP_NODE_CPU || self_ele.Name == PROP_NODE_FREE_DISK) {
 					continue
 				} else {
 					return errors.New(fmt.Sprintf("Property %v has value %v and %v.", self_ele.Name, self_ele.Value, other_ele.Value))
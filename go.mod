@@ -41,6 +41,7 @@ require (
 	github.com/satori/go.uuid v1.2.1-0.20181016184021-8ccf5352a842
 	github.com/sirupsen/logrus v1.5.0 // indirect
 	github.com/stretchr/testify v1.4.0
+	github.com/tetratelabs/wazero v1.2.1
 	github.com/vbatts/tar-split v0.11.1 // indirect
 	golang.org/x/crypto v0.0.0-20200220183623-bac4c82f6975
 	golang.org/x/net v0.0.0-20200226121028-0de0cce0169b // indirect
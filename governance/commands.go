@@ -303,3 +303,37 @@ func (c ServiceChangeCommand) ShortString() string {
 func NewServiceChangeCommand() *ServiceChangeCommand {
 	return &ServiceChangeCommand{}
 }
+
+// ==============================================================================================================
+// A service failed to start, roll back a recent node user input change that it depends on
+type ServiceStartFailedCommand struct {
+	AgreementId string // The agreement of a top level service.
+	MsInstKey   string // The instance key of a dependent service.
+}
+
+func (c ServiceStartFailedCommand) ShortString() string {
+	return fmt.Sprintf("ServiceStartFailedCommand: AgreementId %v, MsInstKey %v", c.AgreementId, c.MsInstKey)
+}
+
+func (w *GovernanceWorker) NewServiceStartFailedCommand(agreementId string, msInstKey string) *ServiceStartFailedCommand {
+	return &ServiceStartFailedCommand{
+		AgreementId: agreementId,
+		MsInstKey:   msInstKey,
+	}
+}
+
+// ==============================================================================================================
+// The node exchange token was rotated, the worker's exchange contexts are switched to the new token
+type NodeTokenRotatedCommand struct {
+	Token string
+}
+
+func (c NodeTokenRotatedCommand) ShortString() string {
+	return fmt.Sprintf("NodeTokenRotatedCommand")
+}
+
+func (w *GovernanceWorker) NewNodeTokenRotatedCommand(token string) *NodeTokenRotatedCommand {
+	return &NodeTokenRotatedCommand{
+		Token: token,
+	}
+}
@@ -7,14 +7,17 @@ import (
 	"github.com/boltdb/bolt"
 	"github.com/golang/glog"
 	"github.com/open-horizon/anax/abstractprotocol"
+	"github.com/open-horizon/anax/basicprotocol"
 	"github.com/open-horizon/anax/cache"
 	"github.com/open-horizon/anax/config"
 	"github.com/open-horizon/anax/cutil"
 	"github.com/open-horizon/anax/eventlog"
 	"github.com/open-horizon/anax/events"
 	"github.com/open-horizon/anax/exchange"
+	"github.com/open-horizon/anax/externalpolicy"
 	"github.com/open-horizon/anax/metering"
 	"github.com/open-horizon/anax/microservice"
+	"github.com/open-horizon/anax/nodeproperty"
 	"github.com/open-horizon/anax/persistence"
 	"github.com/open-horizon/anax/policy"
 	"github.com/open-horizon/anax/producer"
@@ -46,6 +49,10 @@ const MICROSERVICE_GOVERNOR = "MicroserviceGovernor"
 const BC_GOVERNOR = "BlockchainGovernor"
 const SURFACEERRORS = "SurfaceExchErrors"
 const NODESTATUS = "NodeStatus"
+const CLIENT_CERT_RENEWAL = "ClientCertRenewal"
+const TOKEN_ROTATION = "TokenRotation"
+const NODE_PROPERTIES = "NodeProperties"
+const BUILTIN_PROPERTIES = "BuiltInProperties"
 
 // Keys for the exchange errors cache in the worker
 const EXCHANGE_ERRORS = "ExchangeErrors"
@@ -62,6 +69,7 @@ type GovernanceWorker struct {
 	patternChange     ChangePattern
 	limitedRetryEC    exchange.ExchangeContext
 	exchErrors        cache.Cache
+	propertyCollector *nodeproperty.Collector
 }
 
 func NewGovernanceWorker(name string, cfg *config.HorizonConfig, db *bolt.DB, pm *policy.PolicyManager) *GovernanceWorker {
@@ -90,6 +98,10 @@ func NewGovernanceWorker(name string, cfg *config.HorizonConfig, db *bolt.DB, pm
 		exchErrors:      cache.NewSimpleMapCache(),
 	}
 
+	if len(cfg.Edge.NodePropertyProviders) != 0 {
+		worker.propertyCollector = nodeproperty.NewCollector(cfg.Edge.NodePropertyProviders)
+	}
+
 	// Start the worker and set the no work interval to 10 seconds.
 	worker.Start(worker, 10)
 	return worker
@@ -119,6 +131,11 @@ func (w *GovernanceWorker) NewEvent(incoming events.Message) {
 		w.deviceType = msg.DeviceType()
 		w.limitedRetryEC = newLimitedRetryExchangeContext(w.EC)
 
+	case *events.NodeTokenRotatedMessage:
+		msg, _ := incoming.(*events.NodeTokenRotatedMessage)
+		cmd := w.NewNodeTokenRotatedCommand(msg.Token())
+		w.Commands <- cmd
+
 	case *events.EdgeConfigCompleteMessage:
 		// Start any services that run without needing an agreement.
 		cmd := w.NewStartAgreementLessServicesCommand()
@@ -134,9 +151,11 @@ func (w *GovernanceWorker) NewEvent(incoming events.Message) {
 			cmd := w.NewStartGovernExecutionCommand(msg.Deployment, msg.AgreementProtocol, msg.AgreementId)
 			w.Commands <- cmd
 		case events.EXECUTION_FAILED:
+			w.Commands <- w.NewServiceStartFailedCommand(msg.AgreementId, "")
 			cmd := w.NewCleanupExecutionCommand(msg.AgreementProtocol, msg.AgreementId, w.producerPH[msg.AgreementProtocol].GetTerminationCode(producer.TERM_REASON_CONTAINER_FAILURE), msg.Deployment)
 			w.Commands <- cmd
 		case events.IMAGE_LOAD_FAILED:
+			w.Commands <- w.NewServiceStartFailedCommand(msg.AgreementId, "")
 			cmd := w.NewCleanupExecutionCommand(msg.AgreementProtocol, msg.AgreementId, w.producerPH[msg.AgreementProtocol].GetTerminationCode(producer.TERM_REASON_WL_IMAGE_LOAD_FAILURE), msg.Deployment)
 			w.Commands <- cmd
 		case events.WORKLOAD_DESTROYED:
@@ -277,9 +296,11 @@ func (w *GovernanceWorker) NewEvent(incoming events.Message) {
 				cmd := w.NewUpdateMicroserviceCommand(msg.LaunchContext.Name, true, 0, "")
 				w.Commands <- cmd
 			case events.EXECUTION_FAILED:
+				w.Commands <- w.NewServiceStartFailedCommand("", msg.LaunchContext.Name)
 				cmd := w.NewUpdateMicroserviceCommand(msg.LaunchContext.Name, false, microservice.MS_EXEC_FAILED, microservice.DecodeReasonCode(microservice.MS_EXEC_FAILED))
 				w.Commands <- cmd
 			case events.IMAGE_LOAD_FAILED:
+				w.Commands <- w.NewServiceStartFailedCommand("", msg.LaunchContext.Name)
 				cmd := w.NewUpdateMicroserviceCommand(msg.LaunchContext.Name, false, microservice.MS_IMAGE_LOAD_FAILED, microservice.DecodeReasonCode(microservice.MS_IMAGE_LOAD_FAILED))
 				w.Commands <- cmd
 			}
@@ -752,6 +773,33 @@ func (w *GovernanceWorker) Initialize() bool {
 	// Fire up the microservice governor
 	w.DispatchSubworker(MICROSERVICE_GOVERNOR, w.governMicroservices, 60, false)
 
+	// renew the exchange client certificate before it expires
+	if w.Config.Edge.ExchangeClientCert.IsConfigured() && w.Config.Collaborators.HTTPClientFactory.ClientCert != nil {
+		w.DispatchSubworker(CLIENT_CERT_RENEWAL, w.renewClientCert, 3600, false)
+	}
+
+	// finish a token rotation that was interrupted when the agent stopped, even if rotation has since been turned off
+	if pDevice, err := persistence.FindExchangeDevice(w.db); err != nil {
+		glog.Errorf(logString(fmt.Sprintf("unable to read node object from the local database, error %v", err)))
+	} else if pDevice != nil && pDevice.PendingToken != "" {
+		if err := w.recoverTokenRotation(pDevice, nil); err != nil {
+			glog.Errorf(logString(fmt.Sprintf("unable to complete the interrupted token rotation, error %v", err)))
+		}
+	}
+
+	// rotate the node exchange token on the configured schedule
+	if w.Config.Edge.TokenRotationIntervalH > 0 {
+		w.DispatchSubworker(TOKEN_ROTATION, w.rotateExchangeToken, 600, false)
+	}
+
+	// keep the node policy up to date with the built-in properties that can change, like the free disk space
+	w.DispatchSubworker(BUILTIN_PROPERTIES, w.refreshBuiltInProperties, 300, false)
+
+	// keep the node policy up to date with the properties from the node property providers
+	if w.propertyCollector != nil && !w.propertyCollector.IsEmpty() {
+		w.DispatchSubworker(NODE_PROPERTIES, w.collectNodeProperties, w.propertyCollector.MinInterval(), false)
+	}
+
 	// for the policy case update the exchange with the latest registeredServices
 	if w.devicePattern == "" {
 		w.UpdateRegisteredServicesWithAgreement()
@@ -761,6 +809,14 @@ func (w *GovernanceWorker) Initialize() bool {
 
 }
 
+// Renew the client certificate that the node presents to the exchange when it is about to expire.
+func (w *GovernanceWorker) renewClientCert() int {
+	if err := w.Config.Collaborators.HTTPClientFactory.ClientCert.CheckRenewal(); err != nil {
+		glog.Errorf(logString(fmt.Sprintf("unable to renew the exchange client certificate, error %v", err)))
+	}
+	return 0
+}
+
 func (w *GovernanceWorker) CommandHandler(command worker.Command) bool {
 
 	// Handle the domain specific commands
@@ -1020,7 +1076,8 @@ func (w *GovernanceWorker) CommandHandler(command worker.Command) bool {
 					} else {
 						w.cancelAgreement(canReceived.AgreementId(), msgProtocol, canReceived.Reason(), w.producerPH[msgProtocol].GetTerminationReason(canReceived.Reason()))
 						// cleanup workloads if needed
-						w.Messages() <- events.NewGovernanceWorkloadCancelationMessage(events.AGREEMENT_ENDED, events.AG_TERMINATED, ags[0].AgreementProtocol, ags[0].CurrentAgreementId, ags[0].GetDeploymentConfig())
+						eventId, cause := w.cancelEventId(protocolHandler, ags[0], canReceived.Reason())
+						w.Messages() <- events.NewGovernanceWorkloadCancelationMessage(eventId, cause, ags[0].AgreementProtocol, ags[0].CurrentAgreementId, ags[0].GetDeploymentConfig())
 						// clean up microservice instances if needed
 						w.handleMicroserviceInstForAgEnded(ags[0].CurrentAgreementId, false)
 						deleteMessage = true
@@ -1347,6 +1404,21 @@ func (w *GovernanceWorker) CommandHandler(command worker.Command) bool {
 
 		w.handleNodeUserInputUpdated(cmd.Msg.ServiceSpecs)
 
+	case *ServiceStartFailedCommand:
+		cmd, _ := command.(*ServiceStartFailedCommand)
+		glog.V(5).Infof(logString(fmt.Sprintf("%v", cmd.ShortString())))
+
+		w.rollbackNodeUserInputForFailedService(cmd.AgreementId, cmd.MsInstKey)
+
+	case *NodeTokenRotatedCommand:
+		cmd, _ := command.(*NodeTokenRotatedCommand)
+		glog.V(5).Infof(logString(fmt.Sprintf("%v", cmd.ShortString())))
+
+		if w.EC != nil && w.GetExchangeToken() != cmd.Token {
+			w.EC = w.EC.WithToken(cmd.Token)
+			w.limitedRetryEC = newLimitedRetryExchangeContext(w.EC)
+		}
+
 	case *NodePatternChangedCommand:
 		cmd, _ := command.(*NodePatternChangedCommand)
 		glog.V(5).Infof(logString(fmt.Sprintf("%v", cmd)))
@@ -1499,9 +1571,17 @@ func (w *GovernanceWorker) RecordReply(proposal abstractprotocol.Proposal, proto
 			return fmt.Errorf("Cound not find service metadata for %v/%v.", workload.Org, workload.WorkloadURL)
 		} else {
 			serviceDef = sDef
-			sDef.PopulateDefaultUserInput(envAdds)
 		}
 
+		// The default user input and the deployment string can refer to node and agreement values.
+		tdata, err := w.serviceTemplateData(proposal.AgreementId(), workload.WorkloadURL, workload.Org, workload.Version)
+		if err != nil {
+			return errors.New(logString(fmt.Sprintf("unable to get the template values for agreement %v, error %v", proposal.AgreementId(), err)))
+		} else if err := serviceDef.PopulateDefaultUserInput(envAdds, tdata); err != nil {
+			return errors.New(logString(fmt.Sprintf("unable to set the default user input for agreement %v, error %v", proposal.AgreementId(), err)))
+		}
+		lc.TemplateData = tdata
+
 		cutil.SetPlatformEnvvars(envAdds,
 			config.ENVVAR_PREFIX,
 			proposal.AgreementId(),
@@ -1514,8 +1594,24 @@ func (w *GovernanceWorker) RecordReply(proposal abstractprotocol.Proposal, proto
 			w.BaseWorker.Manager.Config.GetFileSyncServiceAPIListen(),
 			strconv.Itoa(int(w.BaseWorker.Manager.Config.GetFileSyncServiceAPIPort())))
 
+		// The secrets are given to the containers as files on a device, and in a kubernetes secret instead of the
+		// config map with the environment variables on a cluster.
+		secretNames := []string{}
+		for _, ui := range serviceDef.UserInputs {
+			if ui.IsSecret() {
+				secretNames = append(secretNames, ui.Name)
+			}
+		}
+		lc.Secrets = splitSecretEnvVars(envAdds, secretNames)
+
 		lc.EnvironmentAdditions = &envAdds
 
+		if w.deviceType == persistence.DEVICE_TYPE_CLUSTER && tcPolicy.Properties.HasProperty(externalpolicy.PROP_DEPLOY_K8S_NAMESPACE) {
+			if prop, err := tcPolicy.Properties.GetProperty(externalpolicy.PROP_DEPLOY_K8S_NAMESPACE); err == nil {
+				lc.ClusterNamespace = fmt.Sprintf("%v", prop.Value)
+			}
+		}
+
 		if w.deviceType == persistence.DEVICE_TYPE_DEVICE {
 			// Make a list of service dependencies for this workload. For sevices, it is just the top level dependencies.
 			deps := serviceDef.GetServiceDependencies()
@@ -1543,6 +1639,29 @@ func (w *GovernanceWorker) RecordReply(proposal abstractprotocol.Proposal, proto
 			}
 		}
 
+		// Save the node policy properties that the templates of the service and of its dependencies refer to, so
+		// that the agreement is ended when a node policy change gives them other values.
+		defaults := make([]string, 0, len(serviceDef.UserInputs))
+		for _, ui := range serviceDef.UserInputs {
+			defaults = append(defaults, ui.DefaultValue)
+		}
+		templates := serviceTemplates(defaults, "", "")
+		if w.deviceType == persistence.DEVICE_TYPE_DEVICE {
+			templates = serviceTemplates(defaults, workload.Deployment, workload.DeploymentOverrides)
+			if depTemplates, err := w.dependencyTemplates(proposal.AgreementId()); err != nil {
+				return errors.New(logString(fmt.Sprintf("unable to get the templates of the dependencies of agreement %v, error %v", proposal.AgreementId(), err)))
+			} else {
+				templates = append(templates, depTemplates...)
+			}
+		}
+		if tp, err := templateProperties(tdata, templates); err != nil {
+			return errors.New(logString(fmt.Sprintf("unable to get the template properties for agreement %v, error %v", proposal.AgreementId(), err)))
+		} else if tp != nil {
+			if _, err := persistence.AgreementTemplatePropertiesUsed(w.db, proposal.AgreementId(), protocol, tp); err != nil {
+				return errors.New(logString(fmt.Sprintf("unable to save the template properties for agreement %v, error %v", proposal.AgreementId(), err)))
+			}
+		}
+
 		eventlog.LogAgreementEvent(w.db, persistence.SEVERITY_INFO,
 			persistence.NewMessageMeta(EL_GOV_START_WORKLOAD_SVC, ag.RunningWorkload.Org, ag.RunningWorkload.URL),
 			persistence.EC_START_SERVICE,
@@ -1924,3 +2043,45 @@ func (w *GovernanceWorker) agreementRequiresService(ag persistence.EstablishedAg
 
 	return false, nil
 }
+
+// Returns the event that tells the workers to clean up the workload of an agreement cancelled by the agbot, and its
+// cause. A Helm release is kept running when the agreement is cancelled to change its version, so that the agreement
+// that replaces it can upgrade the release in place. When the agbot's policy changed, the release is only kept if
+// the node would still accept the agreement, because otherwise the agbot has nothing to replace it with.
+func (w *GovernanceWorker) cancelEventId(protocolHandler abstractprotocol.ProtocolHandler, ag persistence.EstablishedAgreement, reason uint) (events.EventId, events.EndContractCause) {
+	if _, ok := ag.GetDeploymentConfig().(*persistence.HelmDeploymentConfig); !ok || ag.AgreementProtocol != policy.BasicProtocol {
+		return events.AGREEMENT_ENDED, events.AG_TERMINATED
+	} else if reason == basicprotocol.AB_CANCEL_FORCED_UPGRADE {
+		return events.WORKLOAD_UPGRADE, events.AG_TERMINATED
+	} else if reason == basicprotocol.AB_CANCEL_POLICY_CHANGED && w.agreementInPolicy(protocolHandler, ag) {
+		return events.WORKLOAD_UPGRADE, events.AG_POLICY_CHANGED
+	}
+	return events.AGREEMENT_ENDED, events.AG_TERMINATED
+}
+
+// Returns true when the proposal of an agreement is still compatible with the pattern or the policy of the node.
+func (w *GovernanceWorker) agreementInPolicy(protocolHandler abstractprotocol.ProtocolHandler, ag persistence.EstablishedAgreement) bool {
+
+	if proposal, err := protocolHandler.DemarshalProposal(ag.Proposal); err != nil {
+		glog.Errorf(logString(fmt.Sprintf("encountered error demarshalling proposal for agreement %v, error %v", ag.CurrentAgreementId, err)))
+	} else if tcPolicy, err := policy.DemarshalPolicy(proposal.TsAndCs()); err != nil {
+		glog.Errorf(logString(fmt.Sprintf("unable to  demarshal TsAndCs of agreement %v, error %v", ag.CurrentAgreementId, err)))
+	} else if tcPolicy.PatternId != "" {
+		dev, err := persistence.FindExchangeDevice(w.db)
+		if err != nil {
+			glog.Errorf(logString(fmt.Sprintf("unable to read the node from the local database, error %v", err)))
+		}
+		return dev != nil && dev.Pattern == tcPolicy.PatternId
+	} else if pol, err := policy.DemarshalPolicy(proposal.ProducerPolicy()); err != nil {
+		glog.Errorf(logString(fmt.Sprintf("unable to demarshal policy for agreement %v, error %v", ag.CurrentAgreementId, err)))
+	} else if policies, err := w.pm.GetPolicyList(exchange.GetOrg(w.GetExchangeId()), pol); err != nil {
+		glog.Errorf(logString(fmt.Sprintf("unable to get policy list for producer policy in agreement %v, error %v", ag.CurrentAgreementId, err)))
+	} else if mergedPolicy, err := w.pm.MergeAllProducers(&policies, pol); err != nil || mergedPolicy == nil {
+		glog.Errorf(logString(fmt.Sprintf("unable to merge producer policies for agreement %v, error %v", ag.CurrentAgreementId, err)))
+	} else if err := policy.Are_Compatible(mergedPolicy, tcPolicy, nil); err != nil {
+		glog.V(3).Infof(logString(fmt.Sprintf("proposal for %v is out of policy: %v", ag.CurrentAgreementId, err)))
+	} else {
+		return true
+	}
+	return false
+}
@@ -106,6 +106,23 @@ const (
 	EL_GOV_ERR_VALIDATE_NEW_PATTERN        = "Error validating new node pattern %v: %v"
 	EL_GOV_NODE_KEEP_OLD_PATTERN           = "The node will keep using the old pattern %v"
 	EL_GOV_NEW_PATTERN_VERIFIED            = "New pattern %v is verified. Will cancel agreements and re-register the node with the new pattern."
+
+	// token rotation
+	EL_GOV_NODE_TOKEN_ROTATED            = "The node exchange token was rotated."
+	EL_GOV_NODE_TOKEN_ROTATION_RECOVERED = "The node exchange token rotation that was interrupted has been completed."
+	EL_GOV_ERR_NODE_TOKEN_ROTATION       = "Error rotating the node exchange token. %v"
+
+	// node property providers
+	EL_GOV_NODE_PROVIDER_PROPS_UPDATED = "The node policy was updated with the node provider properties %v."
+	EL_GOV_ERR_NODE_PROVIDER_PROPS     = "Error updating the node policy with the node provider properties. %v"
+
+	// node built-in properties
+	EL_GOV_NODE_BUILTIN_PROPS_UPDATED = "The node policy was updated with the changed node built-in properties."
+	EL_GOV_ERR_NODE_BUILTIN_PROPS     = "Error updating the node policy with the node built-in properties. %v"
+
+	// node user input rollback
+	EL_GOV_NODE_UI_ROLLED_BACK  = "Service %v/%v failed to start after the node user input changed, rolled back the node user input to version %v."
+	EL_GOV_ERR_NODE_UI_ROLLBACK = "Error rolling back the node user input after service %v/%v failed to start. %v"
 )
 
 // This is does nothing useful at run time.
@@ -216,4 +233,21 @@ func MarkI18nMessages() {
 	msgPrinter.Sprintf(EL_GOV_ERR_VALIDATE_NEW_PATTERN)
 	msgPrinter.Sprintf(EL_GOV_NODE_KEEP_OLD_PATTERN)
 	msgPrinter.Sprintf(EL_GOV_NEW_PATTERN_VERIFIED)
+
+	// token rotation
+	msgPrinter.Sprintf(EL_GOV_NODE_TOKEN_ROTATED)
+	msgPrinter.Sprintf(EL_GOV_NODE_TOKEN_ROTATION_RECOVERED)
+	msgPrinter.Sprintf(EL_GOV_ERR_NODE_TOKEN_ROTATION)
+
+	// node property providers
+	msgPrinter.Sprintf(EL_GOV_NODE_PROVIDER_PROPS_UPDATED)
+	msgPrinter.Sprintf(EL_GOV_ERR_NODE_PROVIDER_PROPS)
+
+	// node built-in properties
+	msgPrinter.Sprintf(EL_GOV_NODE_BUILTIN_PROPS_UPDATED)
+	msgPrinter.Sprintf(EL_GOV_ERR_NODE_BUILTIN_PROPS)
+
+	// node user input rollback
+	msgPrinter.Sprintf(EL_GOV_NODE_UI_ROLLED_BACK)
+	msgPrinter.Sprintf(EL_GOV_ERR_NODE_UI_ROLLBACK)
 }
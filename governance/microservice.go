@@ -5,6 +5,7 @@ import (
 	"github.com/golang/glog"
 	"github.com/open-horizon/anax/api"
 	"github.com/open-horizon/anax/config"
+	"github.com/open-horizon/anax/container"
 	"github.com/open-horizon/anax/cutil"
 	"github.com/open-horizon/anax/eventlog"
 	"github.com/open-horizon/anax/events"
@@ -191,12 +192,6 @@ func (w *GovernanceWorker) StartMicroservice(ms_key string, agreementId string,
 			// Fire an event to the torrent worker so that it will download the container
 			cc := events.NewContainerConfig(ms_workload.Deployment, ms_workload.DeploymentSignature, ms_workload.DeploymentUserInfo, "", "", "", img_auths)
 
-			// convert the user input from the service attributes, user input from policy and node to env variables
-			envAdds, err := w.GetEnvVarsForServiceDepolyment(msdef, ms_instance, agreementId)
-			if err != nil {
-				return nil, err
-			}
-
 			agIds := make([]string, 0)
 			if agreementId != "" {
 				// service originally start up
@@ -206,7 +201,25 @@ func (w *GovernanceWorker) StartMicroservice(ms_key string, agreementId string,
 				agIds = ms_instance.AssociatedAgreements
 			}
 
+			// the templates of a service that is shared by several agreements cannot refer to one of them
+			templateAgId := ""
+			if len(agIds) == 1 {
+				templateAgId = agIds[0]
+			}
+			tdata, err := w.serviceTemplateData(templateAgId, msdef.SpecRef, msdef.Org, msdef.Version)
+			if err != nil {
+				return nil, fmt.Errorf(logString(fmt.Sprintf("failed to get the template values for %v/%v: %v", msdef.Org, msdef.SpecRef, err)))
+			}
+
+			// convert the user input from the service attributes, user input from policy and node to env variables
+			envAdds, secrets, err := w.GetEnvVarsForServiceDepolyment(msdef, ms_instance, agreementId, tdata)
+			if err != nil {
+				return nil, err
+			}
+
 			lc := events.NewContainerLaunchContext(cc, &envAdds, events.BlockchainConfig{}, ms_instance.GetKey(), agIds, ms_specs, persistence.NewServiceInstancePathElement(msdef.SpecRef, msdef.Org, msdef.Version), isRetry)
+			lc.Secrets = secrets
+			lc.TemplateData = tdata
 			w.Messages() <- events.NewLoadContainerMessage(events.LOAD_CONTAINER, lc)
 
 			return ms_instance, nil // assume there is only one workload for a microservice
@@ -215,7 +228,9 @@ func (w *GovernanceWorker) StartMicroservice(ms_key string, agreementId string,
 }
 
 // Collect the user inputs from node, policy and service. Convert them to a map of strings that can be used as environmental variables for a dependent service container.
-func (w *GovernanceWorker) GetEnvVarsForServiceDepolyment(msdef *persistence.MicroserviceDefinition, msInst *persistence.MicroserviceInstance, agreementId string) (map[string]string, error) {
+// The secret user inputs are returned separately, they are given to the container as files and they are saved encrypted in the service instance.
+// The templates in the default values of the user inputs are expanded with the given template data.
+func (w *GovernanceWorker) GetEnvVarsForServiceDepolyment(msdef *persistence.MicroserviceDefinition, msInst *persistence.MicroserviceInstance, agreementId string, tdata *cutil.TemplateData) (map[string]string, map[string]string, error) {
 
 	var envAdds map[string]string
 
@@ -225,15 +240,15 @@ func (w *GovernanceWorker) GetEnvVarsForServiceDepolyment(msdef *persistence.Mic
 		// this the first time this dependent service is brought up
 		ags, err := persistence.FindEstablishedAgreementsAllProtocols(w.db, policy.AllAgreementProtocols(), []persistence.EAFilter{persistence.UnarchivedEAFilter(), persistence.IdEAFilter(agreementId)})
 		if err != nil {
-			return nil, fmt.Errorf(logString(fmt.Sprintf("failed to retrieve agreement %v from database, error %v", agreementId, err)))
+			return nil, nil, fmt.Errorf(logString(fmt.Sprintf("failed to retrieve agreement %v from database, error %v", agreementId, err)))
 		} else if len(ags) == 0 {
-			return nil, fmt.Errorf(logString(fmt.Sprintf("unable to find agreement %v from database.", agreementId)))
+			return nil, nil, fmt.Errorf(logString(fmt.Sprintf("unable to find agreement %v from database.", agreementId)))
 		}
 
 		if proposal, err := w.producerPH[ags[0].AgreementProtocol].AgreementProtocolHandler("", "", "").DemarshalProposal(ags[0].Proposal); err != nil {
-			return nil, fmt.Errorf(logString(fmt.Sprintf("Error demarshalling proposal from agreement %v, %v", agreementId, err)))
+			return nil, nil, fmt.Errorf(logString(fmt.Sprintf("Error demarshalling proposal from agreement %v, %v", agreementId, err)))
 		} else if pol, err := policy.DemarshalPolicy(proposal.TsAndCs()); err != nil {
-			return nil, fmt.Errorf(logString(fmt.Sprintf("Error demarshalling policy from proposal for agreement %v, %v", agreementId, err)))
+			return nil, nil, fmt.Errorf(logString(fmt.Sprintf("Error demarshalling policy from proposal for agreement %v, %v", agreementId, err)))
 		} else {
 			tcPolicy = pol
 		}
@@ -244,7 +259,7 @@ func (w *GovernanceWorker) GetEnvVarsForServiceDepolyment(msdef *persistence.Mic
 
 	envAdds, err := w.GetServicePreference(msdef.SpecRef, msdef.Org, tcPolicy)
 	if err != nil {
-		return nil, fmt.Errorf(logString(fmt.Sprintf("Error getting environment variables from node settings for %v %v: %v", msdef.SpecRef, msdef.Org, err)))
+		return nil, nil, fmt.Errorf(logString(fmt.Sprintf("Error getting environment variables from node settings for %v %v: %v", msdef.SpecRef, msdef.Org, err)))
 	}
 
 	// for the retry case, get the variables from the old tcPolicy back
@@ -255,6 +270,17 @@ func (w *GovernanceWorker) GetEnvVarsForServiceDepolyment(msdef *persistence.Mic
 			}
 		}
 	}
+	if msInst != nil && len(msInst.Secrets) != 0 {
+		if oldSecrets, err := msInst.GetSecrets(); err != nil {
+			return nil, nil, fmt.Errorf(logString(fmt.Sprintf("Error reading the secrets of ms instance %v: %v", msInst.GetKey(), err)))
+		} else {
+			for k, v := range oldSecrets {
+				if _, ok := envAdds[k]; !ok {
+					envAdds[k] = v
+				}
+			}
+		}
+	}
 
 	envAdds[config.ENVVAR_PREFIX+"DEVICE_ID"] = exchange.GetId(w.GetExchangeId())
 	envAdds[config.ENVVAR_PREFIX+"ORGANIZATION"] = exchange.GetOrg(w.GetExchangeId())
@@ -265,17 +291,41 @@ func (w *GovernanceWorker) GetEnvVarsForServiceDepolyment(msdef *persistence.Mic
 	for _, ui := range msdef.UserInputs {
 		if ui.DefaultValue != "" {
 			if _, ok := envAdds[ui.Name]; !ok {
-				envAdds[ui.Name] = ui.DefaultValue
+				if value, err := cutil.ExpandTemplate(ui.DefaultValue, tdata); err != nil {
+					return nil, nil, fmt.Errorf(logString(fmt.Sprintf("Error setting the default value of %v for %v %v: %v", ui.Name, msdef.SpecRef, msdef.Org, err)))
+				} else {
+					envAdds[ui.Name] = value
+				}
 			}
 		}
 	}
 
+	secretNames := []string{}
+	for _, ui := range msdef.UserInputs {
+		if ui.IsSecret() {
+			secretNames = append(secretNames, ui.Name)
+		}
+	}
+	secrets := splitSecretEnvVars(envAdds, secretNames)
+
 	// save the envvars for retry case
-	if _, err := persistence.UpdateMSInstanceEnvVars(w.db, msInst.GetKey(), envAdds); err != nil {
-		return nil, fmt.Errorf(logString(fmt.Sprintf("Error saving environmental variable settings to ms instance %v: %v", msInst.GetKey(), err)))
+	if _, err := persistence.UpdateMSInstanceEnvVars(w.db, msInst.GetKey(), envAdds, secrets); err != nil {
+		return nil, nil, fmt.Errorf(logString(fmt.Sprintf("Error saving environmental variable settings to ms instance %v: %v", msInst.GetKey(), err)))
 	}
 
-	return envAdds, nil
+	return envAdds, secrets, nil
+}
+
+// Move the secret user inputs out of the environment variables. It returns the secrets that have a value.
+func splitSecretEnvVars(envAdds map[string]string, secretNames []string) map[string]string {
+	secrets := make(map[string]string)
+	for _, name := range secretNames {
+		if value, ok := envAdds[name]; ok {
+			secrets[name] = value
+			delete(envAdds, name)
+		}
+	}
+	return secrets
 }
 
 // It cleans the microservice instance and its associated agreements
@@ -376,6 +426,15 @@ func (w *GovernanceWorker) UpgradeMicroservice(msdef *persistence.MicroserviceDe
 			}
 		}
 	}
+
+	// back up the named volumes of the old microservice once its instances are cleaned up, before the new version
+	// starts, so that they can be restored if the new version is rolled back
+	if upgrade {
+		if err := container.BackupServiceVolumes(w.db, w.Config, cutil.FormOrgSpecUrl(cutil.NormalizeURL(msdef.SpecRef), msdef.Org), msdef.Version); err != nil {
+			glog.Errorf(logString(fmt.Sprintf("Failed to back up the volumes of service %v/%v version %v. %v", msdef.Org, msdef.SpecRef, msdef.Version, err)))
+		}
+	}
+
 	// update msdef UpgradeAgreementsClearedTime
 	if eClearError != nil {
 		if _, err := persistence.MSDefUpgradeFailed(w.db, new_msdef.Id, microservice.MS_CLEAR_OLD_AGS_FAILED, microservice.DecodeReasonCode(microservice.MS_CLEAR_OLD_AGS_FAILED)); err != nil {
@@ -479,6 +538,10 @@ func (w *GovernanceWorker) RollbackMicroservice(msdef *persistence.MicroserviceD
 				glog.Errorf(logString(fmt.Sprintf("Failed to downgrade %v/%v from version %v key %v to version %v key %v. %v", msdef.Org, msdef.SpecRef, msdef.Version, msdef.Id, new_msdef.Version, new_msdef.Id, err)))
 				msdef = new_msdef
 			} else {
+				// the named volumes get back the content that the version rolled back to wrote
+				if err := container.MarkServiceVolumesForRestore(w.db, cutil.FormOrgSpecUrl(cutil.NormalizeURL(new_msdef.SpecRef), new_msdef.Org), new_msdef.Version); err != nil {
+					glog.Errorf(logString(fmt.Sprintf("Failed to restore the volumes of service %v/%v version %v. %v", new_msdef.Org, new_msdef.SpecRef, new_msdef.Version, err)))
+				}
 				eventlog.LogServiceEvent2(w.db, persistence.SEVERITY_INFO,
 					persistence.NewMessageMeta(EL_GOV_COMPLETE_DOWNGRADE, msdef.Org, msdef.SpecRef, msdef.Version, new_msdef.Version),
 					persistence.EC_COMPLETE_DOWNGRADE_SERVICE,
@@ -734,13 +797,13 @@ func (w *GovernanceWorker) handleMicroserviceExecFailure(msdef *persistence.Micr
 		current_retry := msi.CurrentRetryCount + 1
 		// start the retry
 		eventlog.LogServiceEvent2(w.db, persistence.SEVERITY_INFO,
-			persistence.NewMessageMeta(EL_GOV_START_SVC_RETRY, string(current_retry), msdef.SpecRef, msdef.Version),
+			persistence.NewMessageMeta(EL_GOV_START_SVC_RETRY, fmt.Sprintf("%v", current_retry), msdef.SpecRef, msdef.Version),
 			persistence.EC_START_RETRY_DEPENDENT_SERVICE,
 			msinst_key, msdef.SpecRef, msdef.Org, msdef.Version, msdef.Arch, []string{})
 
 		if err := w.RetryMicroservice(msi); err != nil {
 			eventlog.LogServiceEvent2(w.db, persistence.SEVERITY_ERROR,
-				persistence.NewMessageMeta(EL_GOV_FAILED_SVC_RETRY, string(current_retry), msdef.SpecRef, msdef.Version),
+				persistence.NewMessageMeta(EL_GOV_FAILED_SVC_RETRY, fmt.Sprintf("%v", current_retry), msdef.SpecRef, msdef.Version),
 				persistence.EC_ERROR_START_RETRY_DEPENDENT_SERVICE,
 				msinst_key, msdef.SpecRef, msdef.Org, msdef.Version, msdef.Arch, []string{})
 			glog.Errorf(logString(fmt.Sprintf("error retrying number %v for failed dependent service %v.", msinst_key, err)))
@@ -474,12 +474,20 @@ func ValidateUserInput(sdef *exchange.ServiceDefinition, serviceOrg string, merg
 			}
 		}
 	} else {
-		// check if the user input has all the necessary values
+		// check if the user input has all the necessary values and that the values satisfy the constraints
+		given := func(name string) (interface{}, bool) {
+			if input := merged_ui.FindInput(name); input != nil {
+				return input.Value, true
+			}
+			return nil, false
+		}
 		for _, ui := range sdef.UserInputs {
-			if ui.DefaultValue != "" {
-				continue
-			} else if merged_ui.FindInput(ui.Name) == nil {
-				return fmt.Errorf("Userinput for %v is required for service %v/%v.", ui.Name, serviceOrg, sdef.URL)
+			if input := merged_ui.FindInput(ui.Name); input != nil {
+				if err := ui.InputConstraints.Verify(input.Value, ui.Type); err != nil {
+					return fmt.Errorf("Userinput for %v does not satisfy the constraints of service %v/%v: %v", ui.Name, serviceOrg, sdef.URL, err)
+				}
+			} else if ui.IsRequired(sdef.UserInputs, given) {
+				return fmt.Errorf("Userinput for %v is required for service %v/%v, %v.", ui.Name, serviceOrg, sdef.URL, ui.RequiredReason())
 			}
 		}
 	}
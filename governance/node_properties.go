@@ -0,0 +1,79 @@
+package governance
+
+import (
+	"fmt"
+	"github.com/golang/glog"
+	"github.com/open-horizon/anax/eventlog"
+	"github.com/open-horizon/anax/events"
+	"github.com/open-horizon/anax/exchange"
+	"github.com/open-horizon/anax/exchangesync"
+	"github.com/open-horizon/anax/persistence"
+	"time"
+)
+
+// Collect the properties from the node property providers and update the node policy when they change. The agreements
+// are then checked against the new node policy.
+func (w *GovernanceWorker) collectNodeProperties() int {
+
+	pDevice, err := persistence.FindExchangeDevice(w.db)
+	if err != nil {
+		glog.Errorf(logString(fmt.Sprintf("unable to read node object from the local database, error %v", err)))
+		return 0
+	} else if pDevice == nil || !pDevice.IsState(persistence.CONFIGSTATE_CONFIGURED) {
+		return 0
+	}
+
+	props := w.propertyCollector.Collect(time.Now())
+
+	updated, err := exchangesync.UpdateNodeProviderProperties(pDevice, w.db, props, exchange.GetHTTPNodePolicyHandler(w), exchange.GetHTTPPutNodePolicyHandler(w))
+	if err != nil {
+		glog.Errorf(logString(fmt.Sprintf("unable to update the node policy with the node provider properties, error %v", err)))
+		eventlog.LogNodeEvent(w.db,
+			persistence.SEVERITY_ERROR,
+			persistence.NewMessageMeta(EL_GOV_ERR_NODE_PROVIDER_PROPS, err.Error()),
+			persistence.EC_ERROR_NODE_POLICY_UPDATE,
+			pDevice.Id, pDevice.Org, pDevice.Pattern, pDevice.Config.State)
+	} else if updated {
+		glog.V(3).Infof(logString(fmt.Sprintf("updated the node policy with the node provider properties %v", props)))
+		eventlog.LogNodeEvent(w.db,
+			persistence.SEVERITY_INFO,
+			persistence.NewMessageMeta(EL_GOV_NODE_PROVIDER_PROPS_UPDATED, props),
+			persistence.EC_NODE_POLICY_UPDATED,
+			pDevice.Id, pDevice.Org, pDevice.Pattern, pDevice.Config.State)
+
+		w.Messages() <- events.NewNodePolicyMessage(events.UPDATE_POLICY)
+	}
+	return 0
+}
+
+// Update the node policy when the read-only built-in properties of the node change, so that the agreements are checked
+// against the current os, kernel, disk, docker and network properties.
+func (w *GovernanceWorker) refreshBuiltInProperties() int {
+
+	pDevice, err := persistence.FindExchangeDevice(w.db)
+	if err != nil {
+		glog.Errorf(logString(fmt.Sprintf("unable to read node object from the local database, error %v", err)))
+		return 0
+	} else if pDevice == nil || !pDevice.IsState(persistence.CONFIGSTATE_CONFIGURED) {
+		return 0
+	}
+
+	updated, err := exchangesync.RefreshNodeBuiltInProperties(pDevice, w.db, exchange.GetHTTPNodePolicyHandler(w), exchange.GetHTTPPutNodePolicyHandler(w))
+	if err != nil {
+		glog.Errorf(logString(fmt.Sprintf("unable to update the node policy with the node built-in properties, error %v", err)))
+		eventlog.LogNodeEvent(w.db,
+			persistence.SEVERITY_ERROR,
+			persistence.NewMessageMeta(EL_GOV_ERR_NODE_BUILTIN_PROPS, err.Error()),
+			persistence.EC_ERROR_NODE_POLICY_UPDATE,
+			pDevice.Id, pDevice.Org, pDevice.Pattern, pDevice.Config.State)
+	} else if updated {
+		eventlog.LogNodeEvent(w.db,
+			persistence.SEVERITY_INFO,
+			persistence.NewMessageMeta(EL_GOV_NODE_BUILTIN_PROPS_UPDATED),
+			persistence.EC_NODE_POLICY_UPDATED,
+			pDevice.Id, pDevice.Org, pDevice.Pattern, pDevice.Config.State)
+
+		w.Messages() <- events.NewNodePolicyMessage(events.UPDATE_POLICY)
+	}
+	return 0
+}
@@ -42,8 +42,10 @@ func (w *GovernanceWorker) handleUpdatePolicy(cmd *UpdatePolicyCommand) {
 
 }
 
-// When node policy gets updated or deleted, all the agreements wil need
-// to be canceled so that new negotiation can start
+// When node policy gets updated or deleted, all the agreements of a policy based node wil need
+// to be canceled so that new negotiation can start. The agreements of a pattern are only canceled
+// when the node policy properties that the templates of their service refer to have changed, so
+// that the service is started again with the new values.
 func (w *GovernanceWorker) handleNodePolicyUpdated() {
 	glog.V(5).Infof(logString(fmt.Sprintf("handling node policy changes")))
 
@@ -54,10 +56,18 @@ func (w *GovernanceWorker) handleNodePolicyUpdated() {
 		return
 	}
 
+	props, err := w.nodePolicyProperties()
+	if err != nil {
+		glog.Errorf(logString(fmt.Sprintf("Unable to read the node policy properties, error %v", err)))
+		return
+	}
+
 	for _, ag := range agreements {
 		agreementId := ag.CurrentAgreementId
 		if ag.AgreementTerminatedTime != 0 && ag.AgreementForceTerminatedTime == 0 {
 			glog.V(3).Infof(logString(fmt.Sprintf("skip agreement %v, it is already terminating", agreementId)))
+		} else if w.devicePattern != "" && (ag.TemplateProperties == nil || !ag.TemplateProperties.Changed(props)) {
+			glog.V(3).Infof(logString(fmt.Sprintf("skip agreement %v, the node policy change does not change its template values", agreementId)))
 		} else {
 			glog.V(3).Infof(logString(fmt.Sprintf("ending the agreement: %v", agreementId)))
 
@@ -144,6 +144,12 @@ func (w *GovernanceWorker) nodeShutdown(cmd *NodeShutdownCommand) {
 		return
 	}
 
+	// Delete the node user input and node policy history
+	if err := persistence.DeleteNodeConfigHistory(w.db); err != nil {
+		w.completedWithError(logString(err.Error()))
+		return
+	}
+
 	// Delete exchange change state from local db
 	if err := persistence.DeleteExchangeChangeState(w.db); err != nil {
 		w.completedWithError(logString(err.Error()))
@@ -1,11 +1,13 @@
 package governance
 
 import (
+	"encoding/json"
 	"fmt"
 	docker "github.com/fsouza/go-dockerclient"
 	"github.com/golang/glog"
 	"github.com/open-horizon/anax/container"
 	"github.com/open-horizon/anax/containermessage"
+	"github.com/open-horizon/anax/containerruntime"
 	"github.com/open-horizon/anax/cutil"
 	"github.com/open-horizon/anax/exchange"
 	"github.com/open-horizon/anax/exchangesync"
@@ -13,6 +15,8 @@ import (
 	"github.com/open-horizon/anax/kube_operator"
 	"github.com/open-horizon/anax/persistence"
 	"github.com/open-horizon/anax/policy"
+	"github.com/open-horizon/anax/process"
+	"github.com/open-horizon/anax/wasm"
 	"reflect"
 	"time"
 )
@@ -93,7 +97,7 @@ func (w *GovernanceWorker) ReportDeviceStatus() int {
 	// get docker containers
 	containers := make([]docker.APIContainers, 0)
 	if w.deviceType == persistence.DEVICE_TYPE_DEVICE {
-		if client, err := docker.NewClient(w.Config.Edge.DockerEndpoint); err != nil {
+		if client, err := containerruntime.NewDockerRuntime(w.Config.Edge.DockerEndpoint); err != nil {
 			glog.Errorf(logString(fmt.Sprintf("Failed to instantiate docker Client: %v", err)))
 		} else {
 			containers, err = client.ListContainers(docker.ListContainersOptions{})
@@ -252,8 +256,9 @@ func (w *GovernanceWorker) getWorkloadStatus(containers []docker.APIContainers)
 						wl_status.Version = wl.Version
 						wl_status.Arch = wl.Arch
 
-						if wl.ClusterDeployment != "" {
-							opStatus, opErr := GetOperatorStatus(wl.ClusterDeployment)
+						clusterDeployment := agreementClusterDeployment(&ag, wl.ClusterDeployment)
+						if clusterDeployment != "" {
+							opStatus, opErr := GetOperatorStatus(clusterDeployment)
 							if opErr != nil {
 								glog.Errorf(logString(fmt.Sprintf("Error finding workload operator status for %v: %v.", ag, opErr)))
 							} else {
@@ -263,7 +268,7 @@ func (w *GovernanceWorker) getWorkloadStatus(containers []docker.APIContainers)
 
 						deployment := wl.Deployment
 						if deployment == "" {
-							deployment = wl.ClusterDeployment
+							deployment = clusterDeployment
 						}
 						cstatus, cErr := GetContainerStatus(deployment, ag.CurrentAgreementId, false, containers)
 						if cErr == nil {
@@ -334,7 +339,7 @@ func GetContainerStatus(deployment string, key string, infrastructure bool, cont
 			container_status.State = fmt.Sprintf("Unknown, error: %v", err)
 			status = append(status, container_status)
 		} else {
-			if kubeStatus, err := kc.Status(kdc.OperatorYamlArchive); err != nil {
+			if kubeStatus, err := kc.Status(kdc.OperatorYamlArchive, kdc.Namespace); err != nil {
 				container_status.State = fmt.Sprintf("Unknown, error: %v", err)
 				status = append(status, container_status)
 			} else {
@@ -347,12 +352,63 @@ func GetContainerStatus(deployment string, key string, infrastructure bool, cont
 				}
 			}
 		}
+	} else if mdc, err := persistence.GetManifestDeployment(deployment); err == nil {
+		var container_status ContainerStatus
+
+		if kc, err := kube_operator.NewKubeClient(); err != nil {
+			container_status.State = fmt.Sprintf("Unknown, error: %v", err)
+			status = append(status, container_status)
+		} else if manifestStatus, err := kc.ManifestStatus(kube_operator.ManifestNamespace(mdc, key)); err != nil {
+			container_status.State = fmt.Sprintf("Unknown, error: %v", err)
+			status = append(status, container_status)
+		} else {
+			for _, container := range manifestStatus {
+				container_status.State = container.State
+				container_status.Name = container.Name
+				container_status.Created = container.CreatedTime
+				container_status.Image = container.Image
+				status = append(status, container_status)
+			}
+		}
+	} else if pdc, err := persistence.GetProcessDeployment(deployment); err == nil {
+		var container_status ContainerStatus
+		container_status.Name = fmt.Sprintf("Process: %v", key)
+		container_status.Image = pdc.Executable.String()
+		container_status.State = "not started"
+		if ps := process.GetStatus(key); ps != nil {
+			container_status.State = ps.State
+			container_status.Created = ps.Started
+		}
+		status = append(status, container_status)
+	} else if wdc, err := persistence.GetWasmDeployment(deployment); err == nil {
+		var container_status ContainerStatus
+		container_status.Name = fmt.Sprintf("Wasm: %v", key)
+		container_status.Image = wdc.Module.String()
+		container_status.State = "not started"
+		if ws := wasm.GetStatus(key); ws != nil {
+			container_status.State = ws.State
+			container_status.Created = ws.Started
+		}
+		status = append(status, container_status)
 	} else {
 		return nil, fmt.Errorf(logString(fmt.Sprintf("Error Unmarshalling deployment string %v. %v", deployment, err)))
 	}
 	return status, nil
 }
 
+// Returns the cluster deployment of an agreement as it was started, which has the namespace of the agreement in it.
+// The agreements that have not started yet use the cluster deployment of their workload.
+func agreementClusterDeployment(ag *persistence.EstablishedAgreement, clusterDeployment string) string {
+	if clusterDeployment == "" || !(persistence.IsKube(ag.ExtendedDeployment) || persistence.IsManifest(ag.ExtendedDeployment)) {
+		return clusterDeployment
+	} else if dep, err := json.Marshal(ag.ExtendedDeployment); err != nil {
+		glog.Errorf(logString(fmt.Sprintf("unable to marshal the cluster deployment of agreement %v, error %v", ag.CurrentAgreementId, err)))
+		return clusterDeployment
+	} else {
+		return string(dep)
+	}
+}
+
 // GetOperatorStatus will check if the given deployment is for a kube operator and return the operator defined status if it is
 // Will return nil for the interface and no error if the deployment is not for a kube operator
 func GetOperatorStatus(deployment string) (interface{}, error) {
@@ -361,7 +417,7 @@ func GetOperatorStatus(deployment string) (interface{}, error) {
 		if err != nil {
 			return nil, fmt.Errorf(logString(fmt.Sprintf("Error retrieving operator status from cluster, error: %v", err)))
 		}
-		opStatus, err := client.OperatorStatus(kd.OperatorYamlArchive)
+		opStatus, err := client.OperatorStatus(kd.OperatorYamlArchive, kd.Namespace)
 		if err != nil {
 			return nil, fmt.Errorf(logString(fmt.Sprintf("Error retrieving operator status from cluster, error: %v", err)))
 		}
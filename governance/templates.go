@@ -0,0 +1,116 @@
+package governance
+
+import (
+	"fmt"
+	"github.com/open-horizon/anax/containermessage"
+	"github.com/open-horizon/anax/cutil"
+	"github.com/open-horizon/anax/exchange"
+	"github.com/open-horizon/anax/persistence"
+)
+
+// Collect the node and agreement values that the templates in the deployment string and in the default values of the
+// user input of a service refer to. The node policy properties are read when the service is started. The properties
+// that the templates of an agreement refer to are saved with the agreement, so that the agreement is ended and the
+// service is started again with the new values when a node policy change gives them other values.
+func (w *GovernanceWorker) serviceTemplateData(agreementId string, url string, org string, version string) (*cutil.TemplateData, error) {
+
+	props, err := w.nodePolicyProperties()
+	if err != nil {
+		return nil, err
+	}
+
+	return &cutil.TemplateData{
+		NodeId:         exchange.GetId(w.GetExchangeId()),
+		NodeOrg:        exchange.GetOrg(w.GetExchangeId()),
+		Pattern:        w.devicePattern,
+		AgreementId:    agreementId,
+		ServiceURL:     url,
+		ServiceOrg:     org,
+		ServiceVersion: version,
+		Properties:     props,
+	}, nil
+}
+
+// Returns the properties of the node policy by name.
+func (w *GovernanceWorker) nodePolicyProperties() (map[string]interface{}, error) {
+	props := make(map[string]interface{})
+	if nodePol, err := persistence.FindNodePolicy(w.db); err != nil {
+		return nil, fmt.Errorf("unable to read the node policy, error %v", err)
+	} else if nodePol != nil {
+		for _, prop := range nodePol.Properties {
+			props[prop.Name] = prop.Value
+		}
+	}
+	return props, nil
+}
+
+// Returns the templates in the given default values of the user input of a service and, when they are given, in its
+// deployment string and deployment overrides. A deployment that is not a native deployment has no templates.
+func serviceTemplates(defaults []string, deployment string, overrides string) []string {
+	templates := make([]string, 0)
+	for _, def := range defaults {
+		if cutil.IsTemplate(def) {
+			templates = append(templates, def)
+		}
+	}
+	for _, dep := range []string{deployment, overrides} {
+		if dep == "" {
+			continue
+		} else if dd, err := containermessage.GetNativeDeployment(dep); err == nil {
+			templates = append(templates, dd.Templates()...)
+		}
+	}
+	return templates
+}
+
+// Returns the templates of the service instances that were started for the dependencies of an agreement.
+func (w *GovernanceWorker) dependencyTemplates(agreementId string) ([]string, error) {
+	templates := make([]string, 0)
+
+	msInsts, err := persistence.FindMicroserviceInstances(w.db, []persistence.MIFilter{persistence.UnarchivedMIFilter()})
+	if err != nil {
+		return nil, fmt.Errorf("unable to retrieve service instances from the database, error %v", err)
+	}
+
+	for _, msi := range msInsts {
+		if !cutil.SliceContains(msi.AssociatedAgreements, agreementId) {
+			continue
+		} else if msdef, err := persistence.FindMicroserviceDefWithKey(w.db, msi.MicroserviceDefId); err != nil {
+			return nil, fmt.Errorf("unable to retrieve service definition %v from the database, error %v", msi.MicroserviceDefId, err)
+		} else if msdef != nil {
+			defaults := make([]string, 0, len(msdef.UserInputs))
+			for _, ui := range msdef.UserInputs {
+				defaults = append(defaults, ui.DefaultValue)
+			}
+			templates = append(templates, serviceTemplates(defaults, msdef.Deployment, "")...)
+		}
+	}
+	return templates, nil
+}
+
+// Returns the node policy properties that the templates refer to, with the values of the template data. It returns
+// nil when the templates do not refer to the node policy properties.
+func templateProperties(tdata *cutil.TemplateData, templates []string) (*persistence.TemplateProperties, error) {
+	tp := &persistence.TemplateProperties{Values: make(map[string]interface{})}
+	for _, t := range templates {
+		if names, all, err := cutil.TemplateProperties(t); err != nil {
+			return nil, err
+		} else if all {
+			tp.All = true
+		} else {
+			for _, name := range names {
+				tp.Values[name] = tdata.Properties[name]
+			}
+		}
+	}
+
+	if tp.All {
+		tp.Values = make(map[string]interface{}, len(tdata.Properties))
+		for name, value := range tdata.Properties {
+			tp.Values[name] = value
+		}
+	} else if len(tp.Values) == 0 {
+		return nil, nil
+	}
+	return tp, nil
+}
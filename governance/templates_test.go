@@ -0,0 +1,36 @@
+// +build unit
+
+package governance
+
+import (
+	"github.com/open-horizon/anax/cutil"
+	"testing"
+)
+
+func Test_templateProperties(t *testing.T) {
+
+	tdata := &cutil.TemplateData{NodeId: "node1", Properties: map[string]interface{}{"site_id": "s42", "cores": 4}}
+	defaults := []string{`{%property "site_id"%}`, "{{.NodeId}}", ""}
+	deployment := `{"services":{"s1":{"image":"img","environment":["ZONE={%property \"zone\" \"none\"%}","NODE={%.NodeId%}"]}}}`
+
+	templates := serviceTemplates(defaults, deployment, "")
+	if len(templates) != 3 {
+		t.Fatalf("expected 3 templates, got %v", templates)
+	}
+
+	if tp, err := templateProperties(tdata, templates); err != nil {
+		t.Errorf("templateProperties returned error %v", err)
+	} else if tp == nil || tp.All || len(tp.Values) != 2 || tp.Values["site_id"] != "s42" || tp.Values["zone"] != nil {
+		t.Errorf("wrong template properties %v", tp)
+	} else if tp.Changed(tdata.Properties) {
+		t.Errorf("template properties should not have changed")
+	} else if !tp.Changed(map[string]interface{}{"site_id": "s42", "zone": "z1"}) {
+		t.Errorf("template properties should have changed")
+	}
+
+	if tp, err := templateProperties(tdata, []string{"{%.NodeId%}"}); err != nil || tp != nil {
+		t.Errorf("templates without properties should not return template properties, got %v %v", tp, err)
+	} else if tp, err := templateProperties(tdata, []string{"{%range .Properties%}{%.%}{%end%}"}); err != nil || tp == nil || !tp.All || len(tp.Values) != 2 {
+		t.Errorf("templates that range over the properties should return all of them, got %v %v", tp, err)
+	}
+}
@@ -0,0 +1,128 @@
+package governance
+
+import (
+	"errors"
+	"fmt"
+	"github.com/golang/glog"
+	"github.com/open-horizon/anax/cutil"
+	"github.com/open-horizon/anax/eventlog"
+	"github.com/open-horizon/anax/events"
+	"github.com/open-horizon/anax/exchange"
+	"github.com/open-horizon/anax/persistence"
+	"time"
+)
+
+// Check whether the node's exchange token is due to be rotated, and if so, rotate it.
+func (w *GovernanceWorker) rotateExchangeToken() int {
+
+	pDevice, err := persistence.FindExchangeDevice(w.db)
+	if err != nil {
+		glog.Errorf(logString(fmt.Sprintf("unable to read node object from the local database, error %v", err)))
+		return 0
+	} else if pDevice == nil || !pDevice.TokenValid || pDevice.Token == "" {
+		return 0
+	} else if !pDevice.IsState(persistence.CONFIGSTATE_CONFIGURED) {
+		glog.V(5).Infof(logString(fmt.Sprintf("skipping token rotation, the node is in state %v", pDevice.Config.State)))
+		return 0
+	}
+
+	// Finish a previous rotation before starting a new one, otherwise a token that the exchange might already have
+	// would be lost.
+	if pDevice.PendingToken != "" {
+		err = w.recoverTokenRotation(pDevice, nil)
+	} else if rotateAt := pDevice.TokenLastValidTime + uint64(w.Config.Edge.TokenRotationIntervalH)*3600; uint64(time.Now().Unix()) >= rotateAt {
+		err = w.rotateToken(pDevice)
+	}
+
+	if err != nil {
+		glog.Errorf(logString(fmt.Sprintf("unable to rotate the node exchange token, error %v", err)))
+		eventlog.LogNodeEvent(w.db,
+			persistence.SEVERITY_ERROR,
+			persistence.NewMessageMeta(EL_GOV_ERR_NODE_TOKEN_ROTATION, err.Error()),
+			persistence.EC_ERROR_NODE_TOKEN_ROTATION,
+			pDevice.Id, pDevice.Org, pDevice.Pattern, pDevice.Config.State)
+	}
+	return 0
+}
+
+// Replace the node's exchange token with a new random token. The new token is saved in the local database before the
+// exchange is changed so that recoverTokenRotation can figure out which token is valid if the agent stops before the
+// rotation is complete.
+func (w *GovernanceWorker) rotateToken(pDevice *persistence.ExchangeDevice) error {
+
+	newToken, err := cutil.SecureRandomString()
+	if err != nil {
+		return errors.New(fmt.Sprintf("unable to generate a new token, error %v", err))
+	}
+
+	glog.V(3).Infof(logString(fmt.Sprintf("rotating the node exchange token, the current token was set at %v", pDevice.TokenLastValidTime)))
+
+	dev, err := pDevice.SetPendingToken(w.db, pDevice.Id, newToken)
+	if err != nil {
+		return errors.New(fmt.Sprintf("unable to save the new token in the local database, error %v", err))
+	}
+
+	pdr := exchange.PatchDeviceRequest{Token: newToken}
+	if err := exchange.PatchExchangeDevice(w.limitedRetryEC.GetHTTPFactory(), w.GetExchangeId(), pDevice.Token, w.GetExchangeURL(), &pdr); err != nil {
+		// The exchange might or might not have the new token, depending on where the request failed. Leave the
+		// pending token in place, the next check will find out which one is valid.
+		return w.recoverTokenRotation(dev, errors.New(fmt.Sprintf("unable to set the new token in the exchange, error %v", err)))
+	}
+
+	return w.commitToken(dev, EL_GOV_NODE_TOKEN_ROTATED)
+}
+
+// Called when the node starts and when a rotation fails, to resolve a rotation that did not complete. The pending
+// token is used if the exchange accepts it, otherwise the pending token is dropped if the current token still works.
+// If neither token can be verified, the pending token is kept so that the decision can be made later.
+func (w *GovernanceWorker) recoverTokenRotation(pDevice *persistence.ExchangeDevice, cause error) error {
+
+	if pDevice.PendingToken == "" {
+		return cause
+	}
+
+	if w.verifyToken(pDevice.PendingToken) {
+		return w.commitToken(pDevice, EL_GOV_NODE_TOKEN_ROTATION_RECOVERED)
+	} else if w.verifyToken(pDevice.Token) {
+		if _, err := pDevice.SetPendingToken(w.db, pDevice.Id, ""); err != nil {
+			return errors.New(fmt.Sprintf("unable to clear the pending token in the local database, error %v", err))
+		}
+		glog.V(3).Infof(logString("the exchange did not accept the new token, the node will keep using the current token"))
+		return cause
+	}
+
+	if cause == nil {
+		cause = errors.New("the interrupted token rotation could not be resolved, neither token is accepted by the exchange")
+	}
+	return cause
+}
+
+// Returns true if the exchange accepts the token for this node.
+func (w *GovernanceWorker) verifyToken(token string) bool {
+	if _, err := exchange.GetExchangeDevice(w.limitedRetryEC.GetHTTPFactory(), w.GetExchangeId(), w.GetExchangeId(), token, w.GetExchangeURL()); err != nil {
+		glog.V(5).Infof(logString(fmt.Sprintf("token verification failed, error %v", err)))
+		return false
+	}
+	return true
+}
+
+// Make the pending token the node's token in the local database and tell the other workers to use it.
+func (w *GovernanceWorker) commitToken(pDevice *persistence.ExchangeDevice, msg string) error {
+
+	dev, err := pDevice.CommitPendingToken(w.db, pDevice.Id)
+	if err != nil {
+		return errors.New(fmt.Sprintf("the exchange has the new token but it could not be saved in the local database, error %v", err))
+	}
+
+	// The exchange contexts of this worker are switched to the new token by the command handler, like the
+	// other workers, so that they are not changed while it uses them.
+	w.Messages() <- events.NewNodeTokenRotatedMessage(events.NODE_TOKEN_ROTATED, dev.Id, dev.Token)
+
+	glog.V(3).Infof(logString(msg))
+	eventlog.LogNodeEvent(w.db,
+		persistence.SEVERITY_INFO,
+		persistence.NewMessageMeta(msg),
+		persistence.EC_NODE_TOKEN_ROTATED,
+		dev.Id, dev.Org, dev.Pattern, dev.Config.State)
+	return nil
+}
@@ -0,0 +1,112 @@
+package governance
+
+import (
+	"fmt"
+	"github.com/golang/glog"
+	"github.com/open-horizon/anax/eventlog"
+	"github.com/open-horizon/anax/events"
+	"github.com/open-horizon/anax/exchange"
+	"github.com/open-horizon/anax/exchangesync"
+	"github.com/open-horizon/anax/persistence"
+	"github.com/open-horizon/anax/policy"
+	"time"
+)
+
+// A service failed to start. When the node user input of the service was changed a short while ago, the change is
+// the likely cause, so the node user input is rolled back to the version before the change. The agreements of the
+// services whose user input changes are then cancelled as for any other node user input change, so the services
+// are started again with the old user input.
+func (w *GovernanceWorker) rollbackNodeUserInputForFailedService(agreementId string, msInstKey string) {
+
+	if w.Config.Edge.UserInputRollbackWindowS < 0 {
+		return
+	}
+
+	// find the service that failed
+	var svcUrl, svcOrg string
+	if agreementId != "" {
+		if ags, err := persistence.FindEstablishedAgreementsAllProtocols(w.db, policy.AllAgreementProtocols(), []persistence.EAFilter{persistence.IdEAFilter(agreementId)}); err != nil {
+			glog.Errorf(logString(fmt.Sprintf("unable to retrieve agreement %v from the database, error %v", agreementId, err)))
+			return
+		} else if len(ags) == 0 {
+			return
+		} else {
+			svcUrl, svcOrg = ags[0].RunningWorkload.URL, ags[0].RunningWorkload.Org
+		}
+	} else {
+		if msi, err := persistence.FindMicroserviceInstanceWithKey(w.db, msInstKey); err != nil {
+			glog.Errorf(logString(fmt.Sprintf("unable to retrieve service instance %v from the database, error %v", msInstKey, err)))
+			return
+		} else if msi == nil {
+			return
+		} else {
+			svcUrl, svcOrg = msi.SpecRef, msi.Org
+		}
+	}
+
+	history, err := persistence.FindNodeUserInputHistory(w.db)
+	if err != nil {
+		glog.Errorf(logString(fmt.Sprintf("unable to read the node user input history, error %v", err)))
+		return
+	}
+
+	version := userInputRollbackVersion(history, svcUrl, svcOrg, w.Config.Edge.UserInputRollbackWindowS, time.Now())
+	if version == 0 {
+		return
+	}
+
+	pDevice, err := persistence.FindExchangeDevice(w.db)
+	if err != nil {
+		glog.Errorf(logString(fmt.Sprintf("unable to read node object from the local database, error %v", err)))
+		return
+	} else if pDevice == nil || !pDevice.IsState(persistence.CONFIGSTATE_CONFIGURED) {
+		return
+	}
+
+	glog.Infof(logString(fmt.Sprintf("service %v/%v failed to start after its node user input changed, rolling back the node user input to version %v", svcOrg, svcUrl, version)))
+
+	_, changedSvcs, err := exchangesync.RollbackNodeUserInput(pDevice, w.db, version, true, exchange.GetHTTPDeviceHandler(w), exchange.GetHTTPPatchDeviceHandler(w))
+	if err != nil {
+		glog.Errorf(logString(fmt.Sprintf("unable to roll back the node user input, error %v", err)))
+		eventlog.LogNodeEvent(w.db,
+			persistence.SEVERITY_ERROR,
+			persistence.NewMessageMeta(EL_GOV_ERR_NODE_UI_ROLLBACK, svcOrg, svcUrl, err.Error()),
+			persistence.EC_ERROR_NODE_USERINPUT_UPDATE,
+			pDevice.Id, pDevice.Org, pDevice.Pattern, pDevice.Config.State)
+		return
+	}
+
+	eventlog.LogNodeEvent(w.db,
+		persistence.SEVERITY_WARN,
+		persistence.NewMessageMeta(EL_GOV_NODE_UI_ROLLED_BACK, svcOrg, svcUrl, version),
+		persistence.EC_NODE_USERINPUT_UPDATED,
+		pDevice.Id, pDevice.Org, pDevice.Pattern, pDevice.Config.State)
+
+	w.Messages() <- events.NewNodeUserInputMessage(events.UPDATE_NODE_USERINPUT, changedSvcs)
+}
+
+// Returns the version of the node user input to roll back to when the given service fails to start, or 0 when the
+// failure should not cause a rollback. There is a rollback when the latest version changed the user input of the
+// service within the rollback window. The latest version must not be a rollback itself, so that a service that also
+// fails with the old user input does not make the node switch back and forth.
+func userInputRollbackVersion(history []persistence.NodeConfigVersion, svcUrl string, svcOrg string, windowS int, now time.Time) uint64 {
+
+	if len(history) < 2 || svcUrl == "" {
+		return 0
+	}
+
+	latest := history[len(history)-1]
+	previous := history[len(history)-2]
+	if latest.RolledBackTo != 0 {
+		return 0
+	} else if now.Unix()-int64(latest.Time) > int64(windowS) {
+		return 0
+	}
+
+	for _, sp := range exchangesync.GetChangedServices(previous.UserInput, latest.UserInput) {
+		if sp.Url == svcUrl && sp.Org == svcOrg {
+			return previous.Version
+		}
+	}
+	return 0
+}
@@ -0,0 +1,48 @@
+// +build unit
+
+package governance
+
+import (
+	"github.com/open-horizon/anax/persistence"
+	"github.com/open-horizon/anax/policy"
+	"testing"
+	"time"
+)
+
+func Test_userInputRollbackVersion(t *testing.T) {
+
+	ui := func(v string) []policy.UserInput {
+		return []policy.UserInput{
+			{ServiceOrgid: "myorg", ServiceUrl: "svc1", Inputs: []policy.Input{{Name: "var1", Value: v}}},
+			{ServiceOrgid: "myorg", ServiceUrl: "svc2", Inputs: []policy.Input{{Name: "var2", Value: "x"}}},
+		}
+	}
+
+	now := time.Now()
+	changed := uint64(now.Add(-time.Minute).Unix())
+	history := []persistence.NodeConfigVersion{{Version: 1, UserInput: ui("a")}, {Version: 2, UserInput: ui("b"), Time: changed}}
+
+	if v := userInputRollbackVersion(history, "svc1", "myorg", 600, now); v != 1 {
+		t.Errorf("expected a rollback to version 1, got %v", v)
+	}
+
+	// the user input of svc2 did not change
+	if v := userInputRollbackVersion(history, "svc2", "myorg", 600, now); v != 0 {
+		t.Errorf("expected no rollback for a service whose user input did not change, got %v", v)
+	}
+
+	// the change is older than the window
+	if v := userInputRollbackVersion(history, "svc1", "myorg", 30, now); v != 0 {
+		t.Errorf("expected no rollback outside of the window, got %v", v)
+	}
+
+	// a rollback is never rolled back
+	history[1].RolledBackTo = 1
+	if v := userInputRollbackVersion(history, "svc1", "myorg", 600, now); v != 0 {
+		t.Errorf("expected no rollback of a rollback, got %v", v)
+	}
+
+	if v := userInputRollbackVersion(history[:1], "svc1", "myorg", 600, now); v != 0 {
+		t.Errorf("expected no rollback without a previous version, got %v", v)
+	}
+}
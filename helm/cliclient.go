@@ -1,9 +1,11 @@
 package helm
 
 import (
+	"encoding/json"
 	"errors"
 	"fmt"
 	"github.com/golang/glog"
+	"os"
 	"os/exec"
 	"strings"
 )
@@ -14,8 +16,12 @@ type CliClient struct {
 }
 
 const INSTALL_ARGS = "install -n %v %v"
+const UPGRADE_ARGS = "upgrade %v %v"
+const ROLLBACK_ARGS = "rollback %v %v"
 const UNINSTALL_ARGS = "delete --purge %v"
 const STATUS_ARGS = "list -a"
+const HISTORY_ARGS = "history %v --output json"
+const VALUES_ARGS = " -f %v"
 const DEPLOYED = "DEPLOYED"
 
 const EOL = "\x0a"
@@ -25,13 +31,17 @@ func NewCliClient() *CliClient {
 	return new(CliClient)
 }
 
-func (c *CliClient) Install(b64Package string, releaseName string) error {
+func (c *CliClient) Install(b64Package string, releaseName string, values map[string]string) error {
 
 	if fileName, err := ConvertB64StringToFile(b64Package); err != nil {
 		return errors.New(fmt.Sprintf("error converting Helm package to file: %v", err))
+	} else if valuesFile, err := WriteValuesFile(values); err != nil {
+		return errors.New(fmt.Sprintf("error writing Helm values to file: %v", err))
 	} else {
+		defer os.Remove(fileName)
+		defer os.Remove(valuesFile)
 		glog.V(5).Infof(clilogString(fmt.Sprintf("Decoded Helm package to file: %v", fileName)))
-		args := fmt.Sprintf(INSTALL_ARGS, releaseName, fileName)
+		args := fmt.Sprintf(INSTALL_ARGS, releaseName, fileName) + fmt.Sprintf(VALUES_ARGS, valuesFile)
 		glog.V(5).Infof(clilogString(fmt.Sprintf("Installing Helm package: %v", args)))
 		argFields := strings.Fields(args)
 		if out, err := exec.Command("helm", argFields...).Output(); err != nil {
@@ -48,6 +58,57 @@ func (c *CliClient) Install(b64Package string, releaseName string) error {
 	return nil
 }
 
+// Upgrade the release to the chart in place. The release keeps running while the changed resources are replaced.
+func (c *CliClient) Upgrade(b64Package string, releaseName string, values map[string]string) error {
+
+	if fileName, err := ConvertB64StringToFile(b64Package); err != nil {
+		return errors.New(fmt.Sprintf("error converting Helm package to file: %v", err))
+	} else if valuesFile, err := WriteValuesFile(values); err != nil {
+		return errors.New(fmt.Sprintf("error writing Helm values to file: %v", err))
+	} else {
+		defer os.Remove(fileName)
+		defer os.Remove(valuesFile)
+		args := fmt.Sprintf(UPGRADE_ARGS, releaseName, fileName) + fmt.Sprintf(VALUES_ARGS, valuesFile)
+		glog.V(5).Infof(clilogString(fmt.Sprintf("Upgrading Helm release: %v", args)))
+		if out, err := runHelm(args); err != nil {
+			return errors.New(fmt.Sprintf("error upgrading Helm release: %v", err))
+		} else {
+			glog.V(5).Infof(clilogString(fmt.Sprintf("Output from upgrade: %s", string(out))))
+		}
+	}
+
+	return nil
+}
+
+func (c *CliClient) Rollback(releaseName string, revision int) error {
+
+	args := fmt.Sprintf(ROLLBACK_ARGS, releaseName, revision)
+	glog.V(5).Infof(clilogString(fmt.Sprintf("Rolling back Helm release: %v", args)))
+	if out, err := runHelm(args); err != nil {
+		return errors.New(fmt.Sprintf("error rolling back Helm release: %v", err))
+	} else {
+		glog.V(5).Infof(clilogString(fmt.Sprintf("Output from rollback: %s", string(out))))
+	}
+
+	return nil
+}
+
+func (c *CliClient) History(releaseName string) ([]ReleaseRevision, error) {
+
+	args := fmt.Sprintf(HISTORY_ARGS, releaseName)
+	glog.V(5).Infof(clilogString(fmt.Sprintf("Listing Helm release history: %v", args)))
+	out, err := runHelm(args)
+	if err != nil {
+		return nil, errors.New(fmt.Sprintf("error listing Helm release history: %v", err))
+	}
+
+	history := make([]ReleaseRevision, 0)
+	if err := json.Unmarshal(out, &history); err != nil {
+		return nil, errors.New(fmt.Sprintf("error demarshalling Helm release history %s: %v", string(out), err))
+	}
+	return history, nil
+}
+
 func (c *CliClient) UnInstall(releaseName string) error {
 
 	args := fmt.Sprintf(UNINSTALL_ARGS, releaseName)
@@ -119,6 +180,19 @@ func (c *CliClient) ReleaseTimeFormat() string {
 	return HelmCLIReleaseStatusTimeFormat
 }
 
+// Run the helm CLI. The error includes the error output of the CLI.
+func runHelm(args string) ([]byte, error) {
+	out, err := exec.Command("helm", strings.Fields(args)...).Output()
+	if err != nil {
+		errMsg := ""
+		if exErr, ok := err.(*exec.ExitError); ok {
+			errMsg = string(exErr.Stderr)
+		}
+		return nil, errors.New(fmt.Sprintf("(%T) %v error message: %v", err, err, errMsg))
+	}
+	return out, nil
+}
+
 var clilogString = func(v interface{}) string {
 	return fmt.Sprintf("Helm CliClient: %v", v)
 }
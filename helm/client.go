@@ -2,10 +2,12 @@ package helm
 
 import (
 	"encoding/base64"
+	"encoding/json"
 	"fmt"
 	"github.com/golang/glog"
 	"io/ioutil"
 	"os"
+	"strings"
 )
 
 // Status object returned by our Helm client.
@@ -18,11 +20,24 @@ type ReleaseStatus struct {
 	Namespace string // The k8s namespace that the chart was deployed into
 }
 
-// The Helm Client interface that we use, regardless of how its implemented under the covers.
+// A revision in the history of a release.
+type ReleaseRevision struct {
+	Revision    int    `json:"revision"`    // Revision number, starting at 1 for the install
+	Updated     string `json:"updated"`     // The date of the revision
+	Status      string `json:"status"`      // The status of the revision, e.g. DEPLOYED, SUPERSEDED or FAILED
+	Chart       string `json:"chart"`       // The name and version of the chart
+	Description string `json:"description"` // What happened in the revision, e.g. Upgrade complete
+}
+
+// The Helm Client interface that we use, regardless of how its implemented under the covers. The values override the
+// defaults of the chart.
 type HelmClient interface {
-	Install(b64Package string, releaseName string) error
+	Install(b64Package string, releaseName string, values map[string]string) error
+	Upgrade(b64Package string, releaseName string, values map[string]string) error
+	Rollback(releaseName string, revision int) error
 	UnInstall(releaseName string) error
 	Status(releaseName string) (*ReleaseStatus, error)
+	History(releaseName string) ([]ReleaseRevision, error)
 	ReleaseTimeFormat() string
 }
 
@@ -34,6 +49,7 @@ func NewHelmClient() HelmClient {
 // Utility functions that all clients will need.
 
 const TEMP_PACKAGE_PREFIX = "anax-helm-package-"
+const TEMP_VALUES_PREFIX = "anax-helm-values-"
 
 // Convert a base 64 encoded string into its original bytes and then write the bytes to a file
 // in the file system.
@@ -53,6 +69,33 @@ func ConvertB64StringToFile(b64Package string) (string, error) {
 	}
 }
 
+// Write the values that override the defaults of a chart to a file that can be passed to helm. Helm reads JSON as YAML.
+func WriteValuesFile(values map[string]string) (string, error) {
+	if content, err := json.Marshal(values); err != nil {
+		return "", err
+	} else if f, err := ioutil.TempFile("", TEMP_VALUES_PREFIX); err != nil {
+		return "", err
+	} else {
+		defer f.Close()
+		if _, err := f.Write(content); err != nil {
+			return "", err
+		}
+		return f.Name(), nil
+	}
+}
+
+// Returns the revision of a release that is deployed, which is the one that a failed upgrade is rolled back to.
+// Returns 0 if no revision is deployed.
+func DeployedRevision(history []ReleaseRevision) int {
+	deployed := 0
+	for _, r := range history {
+		if r.Revision > deployed && strings.EqualFold(r.Status, DEPLOYED) {
+			deployed = r.Revision
+		}
+	}
+	return deployed
+}
+
 // Convert a Helm chart archive file into a base 64 encoded string. The input filepath is assumed to be absolute.
 func ConvertFileToB64String(filePath string) (string, error) {
 
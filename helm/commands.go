@@ -62,3 +62,34 @@ func NewMaintenanceCommand(protocol string, agreementId string, deployment persi
 		Deployment:        deployment,
 	}
 }
+
+type WorkloadUpgradeCommand struct {
+	AgreementProtocol string
+	AgreementId       string
+	Deployment        persistence.DeploymentConfig
+	WaitS             int64 // how long the release waits for the agreement that replaces this one
+}
+
+func (c WorkloadUpgradeCommand) ShortString() string {
+	return fmt.Sprintf("%v", c)
+}
+
+func NewWorkloadUpgradeCommand(protocol string, agreementId string, deployment persistence.DeploymentConfig, waitS int64) *WorkloadUpgradeCommand {
+	return &WorkloadUpgradeCommand{
+		AgreementProtocol: protocol,
+		AgreementId:       agreementId,
+		Deployment:        deployment,
+		WaitS:             waitS,
+	}
+}
+
+type UnInstallPendingCommand struct {
+}
+
+func (c UnInstallPendingCommand) ShortString() string {
+	return "UnInstallPendingCommand"
+}
+
+func NewUnInstallPendingCommand() *UnInstallPendingCommand {
+	return &UnInstallPendingCommand{}
+}
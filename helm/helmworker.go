@@ -9,11 +9,26 @@ import (
 	"github.com/open-horizon/anax/events"
 	"github.com/open-horizon/anax/persistence"
 	"github.com/open-horizon/anax/worker"
+	"strings"
+	"time"
 )
 
+// How long a release whose agreement was cancelled for an upgrade is kept running while it waits for the agreement
+// that upgrades it. It is uninstalled if no such agreement is made in time.
+const HELM_UPGRADE_WAIT_S = 600
+
+// How long a release whose agreement was cancelled because the policy of the agbot changed is kept running. The agbot
+// makes the agreement that replaces it, if there is one, as soon as it has evaluated the changed policy.
+const HELM_POLICY_CHANGE_WAIT_S = 120
+
+// How often the worker checks for releases that waited too long for their upgrade.
+const HELM_NO_WORK_INTERVAL_S = 60
+
 type HelmWorker struct {
 	worker.BaseWorker // embedded field
 	db                *bolt.DB
+	client            HelmClient
+	pending           map[string]int64 // the releases waiting for an upgrade, and when they stop waiting, as saved in the db
 }
 
 func NewHelmWorker(name string, config *config.HorizonConfig, db *bolt.DB) *HelmWorker {
@@ -21,13 +36,25 @@ func NewHelmWorker(name string, config *config.HorizonConfig, db *bolt.DB) *Helm
 	worker := &HelmWorker{
 		BaseWorker: worker.NewBaseWorker(name, config, nil),
 		db:         db,
+		client:     NewHelmClient(),
+		pending:    make(map[string]int64),
 	}
 
 	glog.Info(hpwlog(fmt.Sprintf("Starting Helm worker")))
-	worker.Start(worker, 0)
+	worker.Start(worker, HELM_NO_WORK_INTERVAL_S)
 	return worker
 }
 
+// The releases that were waiting for an upgrade when the agent stopped keep waiting until their time is over.
+func (w *HelmWorker) Initialize() bool {
+	if pending, err := persistence.FindPendingHelmReleases(w.db); err != nil {
+		glog.Errorf(hpwlog(fmt.Sprintf("unable to read the pending Helm releases from the local database, error %v", err)))
+	} else {
+		w.pending = pending
+	}
+	return true
+}
+
 func (w *HelmWorker) Messages() chan events.Message {
 	return w.BaseWorker.Manager.Messages
 }
@@ -48,6 +75,13 @@ func (w *HelmWorker) NewEvent(incoming events.Message) {
 		case events.AGREEMENT_ENDED:
 			cmd := NewUnInstallCommand(msg.AgreementProtocol, msg.AgreementId, msg.Deployment)
 			w.Commands <- cmd
+		case events.WORKLOAD_UPGRADE:
+			waitS := int64(HELM_UPGRADE_WAIT_S)
+			if msg.Cause == events.AG_POLICY_CHANGED {
+				waitS = HELM_POLICY_CHANGE_WAIT_S
+			}
+			cmd := NewWorkloadUpgradeCommand(msg.AgreementProtocol, msg.AgreementId, msg.Deployment, waitS)
+			w.Commands <- cmd
 		}
 
 	case *events.GovernanceMaintenanceMessage:
@@ -63,6 +97,7 @@ func (w *HelmWorker) NewEvent(incoming events.Message) {
 		msg, _ := incoming.(*events.NodeShutdownCompleteMessage)
 		switch msg.Event().Id {
 		case events.UNCONFIGURE_COMPLETE:
+			w.Commands <- NewUnInstallPendingCommand()
 			w.Commands <- worker.NewTerminateCommand("shutdown")
 		}
 
@@ -114,6 +149,8 @@ func (w *HelmWorker) CommandHandler(command worker.Command) bool {
 		if !ok {
 			glog.Warningf(hpwlog(fmt.Sprintf("ignoring non-Helm deployment: %v", cmd.Deployment)))
 			return true
+		} else if _, pending := w.pending[hdc.ReleaseName]; pending {
+			glog.V(3).Infof(hpwlog(fmt.Sprintf("keeping Helm release %v that was rolled back for another upgrade", hdc.ReleaseName)))
 		} else if err := w.uninstallHelmPackage(hdc); err != nil {
 			// Since we have a Helm deployment package, uninstall it.
 			glog.Errorf(hpwlog(fmt.Sprintf("failed to uninstall helm package after agreement cancellation: %v", err)))
@@ -121,6 +158,24 @@ func (w *HelmWorker) CommandHandler(command worker.Command) bool {
 
 		w.Messages() <- events.NewWorkloadMessage(events.WORKLOAD_DESTROYED, cmd.AgreementProtocol, cmd.CurrentAgreementId, hdc)
 
+	case *WorkloadUpgradeCommand:
+
+		cmd := command.(*WorkloadUpgradeCommand)
+		glog.V(5).Infof(hpwlog(fmt.Sprintf("keeping release for upgrade %v", cmd.Deployment)))
+
+		hdc, ok := cmd.Deployment.(*persistence.HelmDeploymentConfig)
+		if !ok {
+			glog.Warningf(hpwlog(fmt.Sprintf("ignoring non-Helm deployment: %v", cmd.Deployment)))
+			return true
+		}
+
+		// The release keeps running until the agreement with the new version of the chart upgrades it.
+		w.setPending(hdc.ReleaseName, cmd.WaitS)
+		w.Messages() <- events.NewWorkloadMessage(events.WORKLOAD_DESTROYED, cmd.AgreementProtocol, cmd.AgreementId, hdc)
+
+	case *UnInstallPendingCommand:
+		w.uninstallPending(true)
+
 	case *MaintenanceCommand:
 		cmd := command.(*MaintenanceCommand)
 		glog.V(3).Infof(hpwlog(fmt.Sprintf("received maintenance command: %v", cmd)))
@@ -157,8 +212,14 @@ func (w *HelmWorker) processHelmPackage(launchContext *events.AgreementLaunchCon
 
 	// TODO: Verify signature
 
-	c := NewHelmClient()
-	if err := c.Install(hd.ChartArchive, hd.ReleaseName); err != nil {
+	values := chartValues(launchContext.EnvironmentAdditions, launchContext.Secrets)
+
+	if _, ok := w.pending[hd.ReleaseName]; ok {
+		w.clearPending(hd.ReleaseName)
+		return w.upgradeHelmPackage(hd, values)
+	}
+
+	if err := w.client.Install(hd.ChartArchive, hd.ReleaseName, values); err != nil {
 		return errors.New(fmt.Sprintf("unable to install Helm package %v, error: %v", hd, err))
 	}
 
@@ -167,12 +228,44 @@ func (w *HelmWorker) processHelmPackage(launchContext *events.AgreementLaunchCon
 	return nil
 }
 
+// Upgrade a running release in place. If the upgrade fails, the release is rolled back to the revision that was
+// running before it and the error is returned, so that the agreement is cancelled.
+func (w *HelmWorker) upgradeHelmPackage(hd *persistence.HelmDeploymentConfig, values map[string]string) error {
+
+	glog.V(5).Infof(hpwlog(fmt.Sprintf("begin upgrade of Helm Deployment release %v", hd.ReleaseName)))
+
+	history, err := w.client.History(hd.ReleaseName)
+	if err != nil {
+		return errors.New(fmt.Sprintf("unable to list the history of Helm release %v, error: %v", hd.ReleaseName, err))
+	}
+	revision := DeployedRevision(history)
+
+	if err := w.client.Upgrade(hd.ChartArchive, hd.ReleaseName, values); err != nil {
+		upgradeErr := errors.New(fmt.Sprintf("unable to upgrade Helm release %v, error: %v", hd.ReleaseName, err))
+		if revision == 0 {
+			glog.Errorf(hpwlog(fmt.Sprintf("no revision of Helm release %v to roll back to", hd.ReleaseName)))
+		} else if err := w.client.Rollback(hd.ReleaseName, revision); err != nil {
+			glog.Errorf(hpwlog(fmt.Sprintf("unable to roll back Helm release %v to revision %v, error: %v", hd.ReleaseName, revision, err)))
+		} else {
+			// The rolled back release keeps running when the agreement is cancelled, so that the next agreement can try
+			// the upgrade again.
+			glog.V(3).Infof(hpwlog(fmt.Sprintf("rolled back Helm release %v to revision %v", hd.ReleaseName, revision)))
+			w.setPending(hd.ReleaseName, HELM_UPGRADE_WAIT_S)
+		}
+		return upgradeErr
+	}
+
+	glog.V(5).Infof(hpwlog(fmt.Sprintf("completed upgrade of Helm Deployment release %v", hd.ReleaseName)))
+
+	return nil
+}
+
 func (w *HelmWorker) uninstallHelmPackage(hd *persistence.HelmDeploymentConfig) error {
 
 	glog.V(5).Infof(hpwlog(fmt.Sprintf("begin uninstall of Helm Deployment release %v", hd.ReleaseName)))
 
-	c := NewHelmClient()
-	if err := c.UnInstall(hd.ReleaseName); err != nil {
+	w.clearPending(hd.ReleaseName)
+	if err := w.client.UnInstall(hd.ReleaseName); err != nil {
 		return errors.New(fmt.Sprintf("unable to uninstall Helm package %v, error: %v", hd, err))
 	}
 
@@ -185,8 +278,7 @@ func (w *HelmWorker) releaseStatus(hd *persistence.HelmDeploymentConfig, desired
 
 	glog.V(5).Infof(hpwlog(fmt.Sprintf("begin listing Helm Deployment release %v", hd.ReleaseName)))
 
-	c := NewHelmClient()
-	status, err := c.Status(hd.ReleaseName)
+	status, err := w.client.Status(hd.ReleaseName)
 	if err != nil {
 		return errors.New(fmt.Sprintf("unable to list Helm release %v, error: %v", hd.ReleaseName, err))
 	} else if status.Status != desiredStatus {
@@ -198,6 +290,62 @@ func (w *HelmWorker) releaseStatus(hd *persistence.HelmDeploymentConfig, desired
 	return nil
 }
 
+// Uninstall the releases that were kept for an upgrade, either all of them or only the ones that waited too long.
+func (w *HelmWorker) uninstallPending(all bool) {
+	now := time.Now().Unix()
+	for releaseName, expires := range w.pending {
+		if !all && expires > now {
+			continue
+		}
+		glog.V(3).Infof(hpwlog(fmt.Sprintf("uninstalling Helm release %v that was not upgraded", releaseName)))
+		w.clearPending(releaseName)
+		if err := w.client.UnInstall(releaseName); err != nil {
+			glog.Errorf(hpwlog(fmt.Sprintf("unable to uninstall Helm release %v, error: %v", releaseName, err)))
+		}
+	}
+}
+
+// Keep a release running for waitS seconds, also when the agent is restarted in the meantime.
+func (w *HelmWorker) setPending(releaseName string, waitS int64) {
+	expires := time.Now().Unix() + waitS
+	w.pending[releaseName] = expires
+	if err := persistence.SavePendingHelmRelease(w.db, releaseName, expires); err != nil {
+		glog.Errorf(hpwlog(fmt.Sprintf("unable to save pending Helm release %v, error %v", releaseName, err)))
+	}
+}
+
+func (w *HelmWorker) clearPending(releaseName string) {
+	if _, ok := w.pending[releaseName]; !ok {
+		return
+	}
+	delete(w.pending, releaseName)
+	if err := persistence.DeletePendingHelmRelease(w.db, releaseName); err != nil {
+		glog.Errorf(hpwlog(fmt.Sprintf("unable to delete pending Helm release %v, error %v", releaseName, err)))
+	}
+}
+
+func (w *HelmWorker) NoWorkHandler() {
+	w.uninstallPending(false)
+}
+
+// Returns the values that override the defaults of the chart, which are the user inputs of the service, secrets
+// included. Helm keeps the values of a release in a kubernetes secret. The variables that the agent provides to every
+// service are left out.
+func chartValues(envAdds *map[string]string, secrets map[string]string) map[string]string {
+	values := make(map[string]string)
+	if envAdds != nil {
+		for k, v := range *envAdds {
+			if !strings.HasPrefix(k, config.ENVVAR_PREFIX) {
+				values[k] = v
+			}
+		}
+	}
+	for k, v := range secrets {
+		values[k] = v
+	}
+	return values
+}
+
 var hpwlog = func(v interface{}) string {
 	return fmt.Sprintf("Helm Package Worker: %v", v)
 }
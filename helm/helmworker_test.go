@@ -0,0 +1,208 @@
+// +build unit
+
+package helm
+
+import (
+	"errors"
+	"github.com/boltdb/bolt"
+	"github.com/open-horizon/anax/config"
+	"github.com/open-horizon/anax/events"
+	"github.com/open-horizon/anax/persistence"
+	"github.com/open-horizon/anax/policy"
+	"github.com/open-horizon/anax/worker"
+	"io/ioutil"
+	"os"
+	"path"
+	"testing"
+	"time"
+)
+
+func Test_upgrade_pending_release(t *testing.T) {
+
+	client := &fakeClient{history: []ReleaseRevision{{Revision: 1, Status: "SUPERSEDED"}, {Revision: 2, Status: "DEPLOYED"}}}
+	dir, w := fakeHelmWorker(t, client)
+	defer cleanFakeHelmWorker(dir, w)
+	hd := &persistence.HelmDeploymentConfig{ChartArchive: "chart", ReleaseName: "myrelease"}
+
+	// the agreement is cancelled for an upgrade, so the release is kept
+	w.CommandHandler(NewWorkloadUpgradeCommand(policy.BasicProtocol, "ag1", hd, HELM_UPGRADE_WAIT_S))
+	checkDestroyed(t, w, "ag1")
+	if len(client.calls) != 0 {
+		t.Errorf("expected the release to be kept, got calls %v", client.calls)
+	}
+
+	// also when the agent is restarted
+	w.pending = make(map[string]int64)
+	w.Initialize()
+	if _, ok := w.pending[hd.ReleaseName]; !ok {
+		t.Errorf("expected the pending release to be read from the database, got %v", w.pending)
+	}
+
+	// the next agreement upgrades it in place
+	envAdds := map[string]string{"MY_VAR": "value", config.ENVVAR_PREFIX + "AGREEMENTID": "ag2"}
+	lc := &events.AgreementLaunchContext{AgreementId: "ag2", EnvironmentAdditions: &envAdds}
+	if err := w.processHelmPackage(lc, hd); err != nil {
+		t.Fatalf("unexpected error upgrading the release, %v", err)
+	} else if len(client.calls) != 1 || client.calls[0] != "upgrade" {
+		t.Errorf("expected the release to be upgraded, got calls %v", client.calls)
+	} else if len(client.values) != 1 || client.values["MY_VAR"] != "value" {
+		t.Errorf("expected the user input to be passed to the chart, got %v", client.values)
+	} else if pending, _ := persistence.FindPendingHelmReleases(w.db); len(w.pending) != 0 || len(pending) != 0 {
+		t.Errorf("expected no pending release, got %v and %v in the database", w.pending, pending)
+	}
+
+	// the release of a later agreement is installed
+	client.calls = nil
+	if err := w.processHelmPackage(lc, hd); err != nil {
+		t.Fatalf("unexpected error installing the release, %v", err)
+	} else if len(client.calls) != 1 || client.calls[0] != "install" {
+		t.Errorf("expected the release to be installed, got calls %v", client.calls)
+	}
+}
+
+func Test_upgrade_rollback(t *testing.T) {
+
+	client := &fakeClient{
+		history:    []ReleaseRevision{{Revision: 1, Status: "SUPERSEDED"}, {Revision: 2, Status: "DEPLOYED"}},
+		upgradeErr: errors.New("timed out waiting for the condition"),
+	}
+	dir, w := fakeHelmWorker(t, client)
+	defer cleanFakeHelmWorker(dir, w)
+	hd := &persistence.HelmDeploymentConfig{ChartArchive: "chart", ReleaseName: "myrelease"}
+
+	w.CommandHandler(NewWorkloadUpgradeCommand(policy.BasicProtocol, "ag1", hd, HELM_UPGRADE_WAIT_S))
+	checkDestroyed(t, w, "ag1")
+
+	lc := &events.AgreementLaunchContext{AgreementId: "ag2"}
+	if err := w.processHelmPackage(lc, hd); err == nil {
+		t.Fatalf("expected the failed upgrade to be returned")
+	} else if len(client.calls) != 2 || client.calls[1] != "rollback" || client.rollback != 2 {
+		t.Errorf("expected a rollback to revision 2, got calls %v revision %v", client.calls, client.rollback)
+	}
+
+	// the rolled back release is kept when the failed agreement is cancelled
+	w.CommandHandler(NewUnInstallCommand(policy.BasicProtocol, "ag2", hd))
+	checkDestroyed(t, w, "ag2")
+	if len(client.calls) != 2 {
+		t.Errorf("expected the rolled back release to be kept, got calls %v", client.calls)
+	}
+
+	// until it waits too long for another upgrade
+	w.pending[hd.ReleaseName] = 1
+	w.NoWorkHandler()
+	if len(client.calls) != 3 || client.calls[2] != "uninstall" {
+		t.Errorf("expected the release to be uninstalled, got calls %v", client.calls)
+	} else if len(w.pending) != 0 {
+		t.Errorf("expected no pending release, got %v", w.pending)
+	}
+}
+
+func Test_policy_change_wait(t *testing.T) {
+
+	dir, w := fakeHelmWorker(t, &fakeClient{})
+	defer cleanFakeHelmWorker(dir, w)
+	w.Commands = make(chan worker.Command, 10)
+	hd := &persistence.HelmDeploymentConfig{ChartArchive: "chart", ReleaseName: "myrelease"}
+
+	// a release waits less for the agreement that replaces one cancelled for a policy change than for an upgrade
+	w.NewEvent(events.NewGovernanceWorkloadCancelationMessage(events.WORKLOAD_UPGRADE, events.AG_POLICY_CHANGED, policy.BasicProtocol, "ag1", hd))
+	if cmd, ok := (<-w.Commands).(*WorkloadUpgradeCommand); !ok || cmd.WaitS != HELM_POLICY_CHANGE_WAIT_S {
+		t.Errorf("expected an upgrade command that waits %v seconds, got %v", HELM_POLICY_CHANGE_WAIT_S, cmd)
+	}
+
+	w.NewEvent(events.NewGovernanceWorkloadCancelationMessage(events.WORKLOAD_UPGRADE, events.AG_TERMINATED, policy.BasicProtocol, "ag1", hd))
+	if cmd, ok := (<-w.Commands).(*WorkloadUpgradeCommand); !ok || cmd.WaitS != HELM_UPGRADE_WAIT_S {
+		t.Errorf("expected an upgrade command that waits %v seconds, got %v", HELM_UPGRADE_WAIT_S, cmd)
+	}
+}
+
+func Test_DeployedRevision(t *testing.T) {
+
+	if r := DeployedRevision(nil); r != 0 {
+		t.Errorf("expected no revision, got %v", r)
+	} else if r := DeployedRevision([]ReleaseRevision{{Revision: 1, Status: "SUPERSEDED"}, {Revision: 3, Status: "deployed"}, {Revision: 2, Status: "SUPERSEDED"}}); r != 3 {
+		t.Errorf("expected revision 3, got %v", r)
+	} else if r := DeployedRevision([]ReleaseRevision{{Revision: 1, Status: "DEPLOYED"}, {Revision: 2, Status: "FAILED"}}); r != 1 {
+		t.Errorf("expected revision 1, got %v", r)
+	}
+}
+
+func fakeHelmWorker(t *testing.T, client HelmClient) (string, *HelmWorker) {
+	dir, err := ioutil.TempDir("", "helm-")
+	if err != nil {
+		t.Fatalf("unable to create temp dir, %v", err)
+	}
+	db, err := bolt.Open(path.Join(dir, "anax-ut.db"), 0600, &bolt.Options{Timeout: 10 * time.Second})
+	if err != nil {
+		t.Fatalf("unable to open the database, %v", err)
+	}
+
+	w := &HelmWorker{
+		BaseWorker: worker.NewBaseWorker("Helm", &config.HorizonConfig{}, nil),
+		db:         db,
+		client:     client,
+		pending:    make(map[string]int64),
+	}
+	w.Manager.Messages = make(chan events.Message, 10)
+	return dir, w
+}
+
+func cleanFakeHelmWorker(dir string, w *HelmWorker) {
+	w.db.Close()
+	os.RemoveAll(dir)
+}
+
+func checkDestroyed(t *testing.T, w *HelmWorker, agreementId string) {
+	select {
+	case msg := <-w.Messages():
+		if wm, ok := msg.(*events.WorkloadMessage); !ok || wm.Event().Id != events.WORKLOAD_DESTROYED || wm.AgreementId != agreementId {
+			t.Errorf("expected the workload of %v to be destroyed, got %v", agreementId, msg)
+		}
+	default:
+		t.Errorf("expected a message for %v", agreementId)
+	}
+}
+
+// A Helm client that records the calls made to it.
+type fakeClient struct {
+	calls      []string
+	values     map[string]string
+	history    []ReleaseRevision
+	upgradeErr error
+	rollback   int
+}
+
+func (c *fakeClient) Install(b64Package string, releaseName string, values map[string]string) error {
+	c.calls = append(c.calls, "install")
+	c.values = values
+	return nil
+}
+
+func (c *fakeClient) Upgrade(b64Package string, releaseName string, values map[string]string) error {
+	c.calls = append(c.calls, "upgrade")
+	c.values = values
+	return c.upgradeErr
+}
+
+func (c *fakeClient) Rollback(releaseName string, revision int) error {
+	c.calls = append(c.calls, "rollback")
+	c.rollback = revision
+	return nil
+}
+
+func (c *fakeClient) UnInstall(releaseName string) error {
+	c.calls = append(c.calls, "uninstall")
+	return nil
+}
+
+func (c *fakeClient) Status(releaseName string) (*ReleaseStatus, error) {
+	return &ReleaseStatus{Name: releaseName, Status: DEPLOYED}, nil
+}
+
+func (c *fakeClient) History(releaseName string) ([]ReleaseRevision, error) {
+	return c.history, nil
+}
+
+func (c *fakeClient) ReleaseTimeFormat() string {
+	return HelmCLIReleaseStatusTimeFormat
+}
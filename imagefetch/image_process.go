@@ -8,6 +8,7 @@ import (
 	"github.com/golang/glog"
 	"github.com/open-horizon/anax/config"
 	"github.com/open-horizon/anax/containermessage"
+	"github.com/open-horizon/anax/containerruntime"
 	"github.com/open-horizon/anax/events"
 	"github.com/open-horizon/anax/persistence"
 	"github.com/open-horizon/anax/worker"
@@ -17,7 +18,7 @@ import (
 type ImageFetchWorker struct {
 	worker.BaseWorker // embedded field
 	db                *bolt.DB
-	client            *docker.Client
+	client            containerruntime.ContainerRuntime
 }
 
 func NewImageFetchWorker(name string, config *config.HorizonConfig, db *bolt.DB) *ImageFetchWorker {
@@ -28,10 +29,10 @@ func NewImageFetchWorker(name string, config *config.HorizonConfig, db *bolt.DB)
 		return nil
 	}
 
-	var client *docker.Client
+	var client containerruntime.ContainerRuntime
 	var err error
 	if config.Edge.DockerEndpoint != "" {
-		client, err = docker.NewClient(config.Edge.DockerEndpoint)
+		client, err = containerruntime.NewDockerRuntime(config.Edge.DockerEndpoint)
 		if err != nil {
 			glog.Errorf("Failed to instantiate docker Client: %v", err)
 			panic("Unable to instantiate docker Client")
@@ -192,7 +193,7 @@ func processDeployment(cfg *config.HorizonConfig, containerConfig events.Contain
 	return pemFiles, &deploymentDesc, nil
 }
 
-func processFetch(cfg *config.HorizonConfig, client *docker.Client, db *bolt.DB, deploymentDesc *containermessage.DeploymentDescription, imageDockerAuths []events.ImageDockerAuth) error {
+func processFetch(cfg *config.HorizonConfig, client containerruntime.ContainerRuntime, db *bolt.DB, deploymentDesc *containermessage.DeploymentDescription, imageDockerAuths []events.ImageDockerAuth) error {
 	if client == nil {
 		return fmt.Errorf("Docker client is nil. Please make sure DockerEndpoint is set in the configuration file.")
 	}
@@ -214,7 +215,7 @@ func processFetch(cfg *config.HorizonConfig, client *docker.Client, db *bolt.DB,
 	return fetchImage(cfg, client, db, deploymentDesc, dockerAuthConfigurations)
 }
 
-func fetchImage(cfg *config.HorizonConfig, client *docker.Client, db *bolt.DB, deploymentDesc *containermessage.DeploymentDescription, dockerAuthConfigurations map[string][]docker.AuthConfiguration) error {
+func fetchImage(cfg *config.HorizonConfig, client containerruntime.ContainerRuntime, db *bolt.DB, deploymentDesc *containermessage.DeploymentDescription, dockerAuthConfigurations map[string][]docker.AuthConfiguration) error {
 
 	skipCheckFn := SkipCheckFn(client)
 	// using Docker pull (newer option, uses docker client to pull images from repos in image names in deployment description)
@@ -234,7 +235,7 @@ func fetchImage(cfg *config.HorizonConfig, client *docker.Client, db *bolt.DB, d
 // 2) from the dockerAuthConfigurations
 // 3) from the config.DockerCredFilePath file.
 // 4) from /root/.docker/config.json if 3) is not set.
-func ProcessImageFetch(cfg *config.HorizonConfig, client *docker.Client, containerConfig *events.ContainerConfig, dockerAuthConfigurations map[string][]docker.AuthConfiguration) error {
+func ProcessImageFetch(cfg *config.HorizonConfig, client containerruntime.ContainerRuntime, containerConfig *events.ContainerConfig, dockerAuthConfigurations map[string][]docker.AuthConfiguration) error {
 
 	dockerAuthNew := make(map[string][]docker.AuthConfiguration, 0)
 
@@ -8,6 +8,7 @@ import (
 	"github.com/golang/glog"
 	"github.com/open-horizon/anax/config"
 	"github.com/open-horizon/anax/containermessage"
+	"github.com/open-horizon/anax/containerruntime"
 	"github.com/open-horizon/anax/cutil"
 	"os"
 	"time"
@@ -65,11 +66,14 @@ func authDockerFile(config config.Config, authConfigs map[string][]docker.AuthCo
 	return nil
 }
 
-func pullImageFromRepos(config config.Config, authConfigs map[string][]docker.AuthConfiguration, client *docker.Client, skipPartFetchFn *func(repotag string) (bool, error), deploymentDesc *containermessage.DeploymentDescription) error {
+func pullImageFromRepos(config config.Config, authConfigs map[string][]docker.AuthConfiguration, client containerruntime.ContainerRuntime, skipPartFetchFn *func(repotag string) (bool, error), deploymentDesc *containermessage.DeploymentDescription) error {
 
 	// append docker auth from docker file
 	authDockerFile(config, authConfigs)
 
+	// warn about registries that the daemon reaches without the configured proxy
+	checkDaemonProxy(config.Proxy, client, deploymentDesc)
+
 	// TODO: can we fetch in parallel with the docker client? If so, lift pattern from https://github.com/open-horizon/horizon-pkg-fetch/blob/master/fetch.go#L350
 	for name, service := range deploymentDesc.Services {
 
@@ -142,8 +146,43 @@ func pullImageFromRepos(config config.Config, authConfigs map[string][]docker.Au
 	return nil
 }
 
+// The docker daemon, not the agent, connects to the image registries, so the agent's proxy config cannot be applied
+// to image pulls directly. This function verifies that the daemon has a proxy configured for each registry that the
+// agent would reach through its proxy, and logs a warning for each registry that the daemon would reach directly.
+func checkDaemonProxy(proxy config.ProxyConfig, client containerruntime.ContainerRuntime, deploymentDesc *containermessage.DeploymentDescription) {
+	if !proxy.IsConfigured() {
+		return
+	}
+
+	info, err := client.Info()
+	if err != nil {
+		glog.Warningf("Unable to get the docker daemon proxy setting, error: %v", err)
+		return
+	}
+
+	daemonProxy := config.ProxyConfig{URL: info.HTTPSProxy}
+	if daemonProxy.URL == "" {
+		daemonProxy.URL = info.HTTPProxy
+	}
+	for _, np := range strings.Split(info.NoProxy, ",") {
+		if strings.TrimSpace(np) != "" {
+			daemonProxy.NoProxy = append(daemonProxy.NoProxy, np)
+		}
+	}
+
+	for name, service := range deploymentDesc.Services {
+		domain, _, _, _ := cutil.ParseDockerImagePath(service.Image)
+		if domain == "" {
+			domain = "docker.io"
+		}
+		if proxy.UseProxy(domain) && !daemonProxy.UseProxy(domain) {
+			glog.Warningf("The docker daemon will pull image %v for service %v from %v without using the proxy %v. Configure the proxy for the docker daemon if the registry cannot be reached directly.", service.Image, name, domain, proxy.URL)
+		}
+	}
+}
+
 //  This function try maxPullAttempts times to pull the image from the repo. It exits out imediately if there is auth error.
-func pullSingleImageFromRepo(client *docker.Client, opts docker.PullImageOptions, auth docker.AuthConfiguration) error {
+func pullSingleImageFromRepo(client containerruntime.ContainerRuntime, opts docker.PullImageOptions, auth docker.AuthConfiguration) error {
 	glog.V(5).Infof("Pulling image %v with auth name %v.", opts, auth.Username)
 
 	var pullAttempts int
@@ -186,7 +225,7 @@ func pullSingleImageFromRepo(client *docker.Client, opts docker.PullImageOptions
 	return nil
 }
 
-func listImages(client *docker.Client) ([]docker.APIImages, error) {
+func listImages(client containerruntime.ContainerRuntime) ([]docker.APIImages, error) {
 
 	if images, err := client.ListImages(docker.ListImagesOptions{
 		All: true,
@@ -198,7 +237,7 @@ func listImages(client *docker.Client) ([]docker.APIImages, error) {
 }
 
 // TODO: user needs to use image IDs instead of repotags to avoid overwriting or otherwise mistaken handling because of name collisions
-func SkipCheckFn(client *docker.Client) func(repotag string) (bool, error) {
+func SkipCheckFn(client containerruntime.ContainerRuntime) func(repotag string) (bool, error) {
 
 	return func(repotag string) (bool, error) {
 		repotagParts := strings.Split(repotag, ":")
@@ -8,6 +8,7 @@ import (
 	"github.com/golang/glog"
 	"github.com/open-horizon/anax/config"
 	"github.com/open-horizon/anax/cutil"
+	"github.com/open-horizon/anax/persistence"
 	yaml "gopkg.in/yaml.v2"
 	"io"
 	"io/ioutil"
@@ -16,6 +17,7 @@ import (
 	rbacv1 "k8s.io/api/rbac/v1"
 	"k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1beta1"
 	apiv1beta1client "k8s.io/apiextensions-apiserver/pkg/client/clientset/clientset/typed/apiextensions/v1beta1"
+	"k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
 	"k8s.io/apimachinery/pkg/runtime"
@@ -35,6 +37,10 @@ const (
 	HZN_ENV_VARS = "hzn-env-vars"
 	// Variable that contains the name of the config map
 	HZN_ENV_KEY = "HZN_ENV_VARS"
+	// Name for the secret with the secret user input. Only characters allowed: [a-z] "." and "-"
+	HZN_SECRETS = "hzn-secrets"
+	// Variable that contains the name of the secret
+	HZN_SECRETS_KEY = "HZN_SECRETS"
 
 	K8S_ROLE_TYPE           = "Role"
 	K8S_ROLEBINDING_TYPE    = "RoleBinding"
@@ -97,8 +103,10 @@ func NewCRDClient() (*apiv1beta1client.ApiextensionsV1beta1Client, error) {
 	return clientset, nil
 }
 
-// Install creates the objects specified in the operator deployment in the cluster and creates the custom resource to start the operator
-func (c KubeClient) Install(tar string, envVars map[string]string, agId string) error {
+// Install creates the objects specified in the operator deployment in the cluster and creates the custom resource to start the operator.
+// The objects are created in the given namespace, which is prepared for the agreement. Without a namespace, they are created in the
+// namespace of the operator yaml. The secret user input is put into a kubernetes secret that the operator is told about.
+func (c KubeClient) Install(tar string, envVars map[string]string, secrets map[string]string, agId string, namespace string, resources *persistence.ClusterResources) error {
 	// Read the yaml files from the commpressed tar files
 	yamls, err := getYamlFromTarGz(tar)
 	if err != nil {
@@ -117,17 +125,25 @@ func (c KubeClient) Install(tar string, envVars map[string]string, agId string)
 	// Sort the k8s api objects by kind
 	apiObjMap := sortAPIObjects(k8sObjs)
 
-	nsObj, namespace, err := getOperatorNamespace(apiObjMap)
+	nsObj, yamlNamespace, err := getOperatorNamespace(apiObjMap)
 	if err != nil {
 		return err
 	}
 
-	if nsObj != nil {
+	if namespace != "" {
+		if err := c.prepareNamespace(namespace, agId, resources); err != nil {
+			return err
+		}
+		moveToNamespace(apiObjMap, yamlNamespace, namespace)
+	} else if nsObj != nil {
+		namespace = yamlNamespace
 		glog.V(3).Infof(kwlog(fmt.Sprintf("attempting to create namespace %v", nsObj)))
 		_, err := c.Client.CoreV1().Namespaces().Create(nsObj)
 		if err != nil {
 			glog.Warningf(kwlog(fmt.Sprintf("Failed to create namspace %s. Continuing with installation.", nsObj.ObjectMeta.Name)))
 		}
+	} else {
+		namespace = yamlNamespace
 	}
 
 	// The ESS is not supported in edge cluster services, so for now, remove the ESS env vars.
@@ -139,6 +155,14 @@ func (c KubeClient) Install(tar string, envVars map[string]string, agId string)
 		return err
 	}
 
+	// Create the secret.
+	secretName := ""
+	if len(secrets) != 0 {
+		if secretName, err = c.CreateSecret(secrets, agId, namespace); err != nil {
+			return err
+		}
+	}
+
 	// Create the role types in the cluster
 	for _, roleDef := range apiObjMap[K8S_ROLE_TYPE] {
 		newRole := roleDef.Object.(*rbacv1.Role)
@@ -171,6 +195,9 @@ func (c KubeClient) Install(tar string, envVars map[string]string, agId string)
 		newDep := dep.Object.(*appsv1.Deployment)
 		glog.V(3).Infof(kwlog(fmt.Sprintf("creating deployment %v", newDep)))
 		newDepWithEnv := addConfigMapVarToDeploymentObject(*newDep, mapName)
+		if secretName != "" {
+			newDepWithEnv = addSecretVarToDeploymentObject(newDepWithEnv, secretName)
+		}
 		_, err := c.Client.AppsV1().Deployments(namespace).Create(&newDepWithEnv)
 		if err != nil {
 			return err
@@ -228,8 +255,8 @@ func (c KubeClient) Install(tar string, envVars map[string]string, agId string)
 	return nil
 }
 
-// Install creates the objects specified in the operator deployment in the cluster and creates the custom resource to start the operator
-func (c KubeClient) Uninstall(tar string, agId string) error {
+// Uninstall deletes the objects specified in the operator deployment from the cluster, and the namespace of the agreement.
+func (c KubeClient) Uninstall(tar string, agId string, namespace string) error {
 	// Read the yaml files from the commpressed tar files
 	yamls, err := getYamlFromTarGz(tar)
 	if err != nil {
@@ -243,10 +270,14 @@ func (c KubeClient) Uninstall(tar string, agId string) error {
 	// Sort the k8s api objects by kind
 	apiObjMap := sortAPIObjects(k8sObjs)
 
-	_, namespace, err := getOperatorNamespace(apiObjMap)
+	_, yamlNamespace, err := getOperatorNamespace(apiObjMap)
 	if err != nil {
 		return err
 	}
+	agreementNamespace := namespace
+	if namespace == "" {
+		namespace = yamlNamespace
+	}
 
 	configMapName := fmt.Sprintf("%s-%s", HZN_ENV_VARS, agId)
 	glog.V(3).Infof(kwlog(fmt.Sprintf("deleting config map %v", configMapName)))
@@ -256,6 +287,12 @@ func (c KubeClient) Uninstall(tar string, agId string) error {
 		glog.Errorf(kwlog(fmt.Sprintf("unable to delete config map %s. Error: %v", configMapName, err)))
 	}
 
+	// Delete the agreement secret, there is none when the service has no secret user input
+	secretName := fmt.Sprintf("%s-%s", HZN_SECRETS, agId)
+	if err := c.Client.CoreV1().Secrets(namespace).Delete(secretName, &metav1.DeleteOptions{}); err != nil && !errors.IsNotFound(err) {
+		glog.Errorf(kwlog(fmt.Sprintf("unable to delete secret %s. Error: %v", secretName, err)))
+	}
+
 	// Delete the custom resource definitions from the cluster
 	kindToGVRMap := map[string]schema.GroupVersionResource{}
 	for _, crd := range apiObjMap[K8S_CRD_TYPE] {
@@ -352,7 +389,15 @@ func (c KubeClient) Uninstall(tar string, agId string) error {
 			glog.Errorf(kwlog(fmt.Sprintf("unable to delete role %s. Error: %v", newRole.ObjectMeta.Name, err)))
 		}
 	}
+	if agreementNamespace != "" {
+		if err := c.removeNamespace(agreementNamespace, agId); err != nil {
+			glog.Errorf(kwlog(fmt.Sprintf("unable to remove namespace %s. Error: %v", agreementNamespace, err)))
+		}
+	}
 	for _, namespaceDef := range apiObjMap[K8S_NAMESPACE_TYPE] {
+		if agreementNamespace != "" {
+			break
+		}
 		newNs := namespaceDef.Object.(*corev1.Namespace)
 		glog.V(3).Infof(kwlog(fmt.Sprintf("deleting namespace %v", newNs)))
 		err := c.Client.CoreV1().Namespaces().Delete(newNs.ObjectMeta.Name, &metav1.DeleteOptions{})
@@ -363,7 +408,22 @@ func (c KubeClient) Uninstall(tar string, agId string) error {
 	glog.V(3).Infof(kwlog(fmt.Sprintf("Completed removal of all operator objects from the cluster.")))
 	return nil
 }
-func (c KubeClient) OperatorStatus(tar string) (interface{}, error) {
+func (c KubeClient) OperatorStatus(tar string, namespace string) (interface{}, error) {
+	res, err := c.operatorResource(tar, namespace)
+	if err != nil {
+		return nil, err
+	}
+
+	if status, ok := res.Object["status"]; ok {
+		return status, nil
+	} else {
+		return nil, fmt.Errorf("Error status not found")
+	}
+
+}
+
+// Returns the custom resource of the operator as it is in the cluster.
+func (c KubeClient) operatorResource(tar string, namespace string) (*unstructured.Unstructured, error) {
 	// Read the yaml files from the commpressed tar files
 	yamls, err := getYamlFromTarGz(tar)
 	if err != nil {
@@ -377,9 +437,10 @@ func (c KubeClient) OperatorStatus(tar string) (interface{}, error) {
 	// Sort the k8s api objects by kind
 	apiObjMap := sortAPIObjects(k8sObjs)
 
-	_, namespace, err := getOperatorNamespace(apiObjMap)
-	if err != nil {
-		return nil, err
+	if namespace == "" {
+		if _, namespace, err = getOperatorNamespace(apiObjMap); err != nil {
+			return nil, err
+		}
 	}
 
 	kindToGVRMap := map[string]schema.GroupVersionResource{}
@@ -407,19 +468,40 @@ func (c KubeClient) OperatorStatus(tar string) (interface{}, error) {
 	}
 	crClient := dynClient.Resource(kindToGVRMap[fmt.Sprintf("%v", crMap["kind"])])
 	name := fmt.Sprintf("%v", crMap["metadata"].(map[string]interface{})["name"])
-	res, err := crClient.Namespace(namespace).Get(name, metav1.GetOptions{})
+	return crClient.Namespace(namespace).Get(name, metav1.GetOptions{})
+}
+
+func (c KubeClient) Status(tar string, namespace string) ([]ContainerStatus, error) {
+	pods, err := c.operatorPods(tar, namespace)
 	if err != nil {
 		return nil, err
 	}
-
-	if status, ok := res.Object["status"]; ok {
-		return status, nil
-	} else {
-		return nil, fmt.Errorf("Error status not found")
+	if len(pods) < 1 {
+		return nil, nil
 	}
+	pod := pods[0]
+	containerStatuses := []ContainerStatus{}
 
+	for _, status := range pod.Status.ContainerStatuses {
+		newStatus := ContainerStatus{Name: pod.ObjectMeta.Name}
+		newStatus.Image = status.Image
+		newStatus.Name = status.Name
+		if status.State.Running != nil {
+			newStatus.State = "Running"
+			newStatus.CreatedTime = status.State.Running.StartedAt.Time.Unix()
+		} else if status.State.Terminated != nil {
+			newStatus.State = "Terminated"
+			newStatus.CreatedTime = status.State.Terminated.StartedAt.Time.Unix()
+		} else {
+			newStatus.State = "Waiting"
+		}
+		containerStatuses = append(containerStatuses, newStatus)
+	}
+	return containerStatuses, nil
 }
-func (c KubeClient) Status(tar string) ([]ContainerStatus, error) {
+
+// Returns the pods of the operator.
+func (c KubeClient) operatorPods(tar string, namespace string) ([]corev1.Pod, error) {
 	// Read the yaml files from the commpressed tar files
 	yamls, err := getYamlFromTarGz(tar)
 	if err != nil {
@@ -437,32 +519,14 @@ func (c KubeClient) Status(tar string) ([]ContainerStatus, error) {
 	deploymentObj := deploymentUnstruct[0].Object.(*appsv1.Deployment)
 	opName := deploymentObj.Spec.Template.ObjectMeta.Labels["name"]
 
-	podList, err := c.Client.CoreV1().Pods(ANAX_NAMESPACE).List(metav1.ListOptions{LabelSelector: fmt.Sprintf("%s=%s", "name", opName)})
+	if namespace == "" {
+		namespace = ANAX_NAMESPACE
+	}
+	podList, err := c.Client.CoreV1().Pods(namespace).List(metav1.ListOptions{LabelSelector: fmt.Sprintf("%s=%s", "name", opName)})
 	if err != nil {
 		return nil, err
 	}
-	if len(podList.Items) < 1 {
-		return nil, nil
-	}
-	pod := podList.Items[0]
-	containerStatuses := []ContainerStatus{}
-
-	for _, status := range pod.Status.ContainerStatuses {
-		newStatus := ContainerStatus{Name: pod.ObjectMeta.Name}
-		newStatus.Image = status.Image
-		newStatus.Name = status.Name
-		if status.State.Running != nil {
-			newStatus.State = "Running"
-			newStatus.CreatedTime = status.State.Running.StartedAt.Time.Unix()
-		} else if status.State.Terminated != nil {
-			newStatus.State = "Terminated"
-			newStatus.CreatedTime = status.State.Terminated.StartedAt.Time.Unix()
-		} else {
-			newStatus.State = "Waiting"
-		}
-		containerStatuses = append(containerStatuses, newStatus)
-	}
-	return containerStatuses, nil
+	return podList.Items, nil
 }
 
 // CreateConfigMap will create a config map with the provided environment variable map
@@ -475,6 +539,16 @@ func (c KubeClient) CreateConfigMap(envVars map[string]string, agId string, name
 	return res.ObjectMeta.Name, nil
 }
 
+// CreateSecret will create a secret with the provided secret user input
+func (c KubeClient) CreateSecret(secrets map[string]string, agId string, namespace string) (string, error) {
+	hznSecret := corev1.Secret{ObjectMeta: metav1.ObjectMeta{Name: fmt.Sprintf("%s-%s", HZN_SECRETS, agId)}, Type: corev1.SecretTypeOpaque, StringData: secrets}
+	res, err := c.Client.CoreV1().Secrets(namespace).Create(&hznSecret)
+	if err != nil {
+		return "", fmt.Errorf("Error: failed to create secret for %s: %v", agId, err)
+	}
+	return res.ObjectMeta.Name, nil
+}
+
 func getOperatorNamespace(allObjects map[string][]APIObjects) (*corev1.Namespace, string, error) {
 	namespace := ANAX_NAMESPACE
 	var nsObj *corev1.Namespace
@@ -506,6 +580,29 @@ func getOperatorNamespace(allObjects map[string][]APIObjects) (*corev1.Namespace
 	return nsObj, namespace, nil
 }
 
+// Move the namespaced objects of the operator yaml into another namespace. The service accounts that the role bindings
+// refer to move with them.
+func moveToNamespace(allObjects map[string][]APIObjects, from string, to string) {
+	for _, roleDef := range allObjects[K8S_ROLE_TYPE] {
+		roleDef.Object.(*rbacv1.Role).ObjectMeta.Namespace = ""
+	}
+	for _, roleBindingDef := range allObjects[K8S_ROLEBINDING_TYPE] {
+		newRoleBinding := roleBindingDef.Object.(*rbacv1.RoleBinding)
+		newRoleBinding.ObjectMeta.Namespace = ""
+		for i, subject := range newRoleBinding.Subjects {
+			if subject.Kind == rbacv1.ServiceAccountKind && (subject.Namespace == "" || subject.Namespace == from) {
+				newRoleBinding.Subjects[i].Namespace = to
+			}
+		}
+	}
+	for _, svcAcctDef := range allObjects[K8S_SERVICEACCOUNT_TYPE] {
+		svcAcctDef.Object.(*corev1.ServiceAccount).ObjectMeta.Namespace = ""
+	}
+	for _, dep := range allObjects[K8S_DEPLOYMENT_TYPE] {
+		dep.Object.(*appsv1.Deployment).ObjectMeta.Namespace = ""
+	}
+}
+
 // add a reference to the envvar config map to the deployment
 func addConfigMapVarToDeploymentObject(deployment appsv1.Deployment, configMapName string) appsv1.Deployment {
 	hznEnvVar := corev1.EnvVar{Name: HZN_ENV_KEY, Value: configMapName}
@@ -518,6 +615,15 @@ func addConfigMapVarToDeploymentObject(deployment appsv1.Deployment, configMapNa
 	return deployment
 }
 
+// add a reference to the secret to the deployment
+func addSecretVarToDeploymentObject(deployment appsv1.Deployment, secretName string) appsv1.Deployment {
+	hznSecretVar := corev1.EnvVar{Name: HZN_SECRETS_KEY, Value: secretName}
+	for i := range deployment.Spec.Template.Spec.Containers {
+		deployment.Spec.Template.Spec.Containers[i].Env = append(deployment.Spec.Template.Spec.Containers[i].Env, hznSecretVar)
+	}
+	return deployment
+}
+
 // recursively go over the given interface to ensure any map keys are strings
 func makeAllKeysStrings(unmarshYaml interface{}) interface{} {
 	if reflect.ValueOf(unmarshYaml).Kind() == reflect.Map {
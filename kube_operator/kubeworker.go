@@ -5,11 +5,29 @@ import (
 	"github.com/boltdb/bolt"
 	"github.com/golang/glog"
 	"github.com/open-horizon/anax/config"
+	"github.com/open-horizon/anax/eventlog"
 	"github.com/open-horizon/anax/events"
+	"github.com/open-horizon/anax/i18n"
 	"github.com/open-horizon/anax/persistence"
 	"github.com/open-horizon/anax/worker"
 )
 
+// messages for event logs
+const (
+	EL_KUBE_OPERATOR_FAILED = "The operator of service %v failed: %v"
+)
+
+// This is does nothing useful at run time.
+// This code is only used in compileing time to make the eventlog messages gets into the catalog so that
+// they can be translated.
+// The event log messages will be saved in English. But the CLI can request them in different languages.
+func MarkI18nMessages() {
+	// get message printer. anax default language is English
+	msgPrinter := i18n.GetMessagePrinter()
+
+	msgPrinter.Sprintf(EL_KUBE_OPERATOR_FAILED)
+}
+
 type KubeWorker struct {
 	worker.BaseWorker
 	db *bolt.DB
@@ -84,9 +102,15 @@ func (w *KubeWorker) CommandHandler(command worker.Command) bool {
 
 			// Check the deployment to check if it is a kube deployment
 			deploymentConfig := lc.ContainerConfig().ClusterDeployment
-			if kd, err := persistence.GetKubeDeployment(deploymentConfig); err != nil {
+			if md, err := persistence.GetManifestDeployment(deploymentConfig); err == nil {
+				w.installManifests(lc, md)
+			} else if kd, err := persistence.GetKubeDeployment(deploymentConfig); err != nil {
 				glog.Errorf(kwlog(fmt.Sprintf("error getting kube deployment configuration: %v", err)))
 				return true
+			} else if kd.Namespace, err = ResolveNamespace(lc.ClusterNamespace, lc.AgreementId); err != nil {
+				glog.Errorf(kwlog(fmt.Sprintf("failed to process kube package after agreement negotiation: %v", err)))
+				w.Messages() <- events.NewWorkloadMessage(events.EXECUTION_FAILED, lc.AgreementProtocol, lc.AgreementId, kd)
+				return true
 			} else if _, err := persistence.AgreementDeploymentStarted(w.db, lc.AgreementId, lc.AgreementProtocol, kd); err != nil {
 				glog.Errorf(kwlog(fmt.Sprintf("received error updating database deployment state, %v", err)))
 				w.Messages() <- events.NewWorkloadMessage(events.EXECUTION_FAILED, lc.AgreementProtocol, lc.AgreementId, kd)
@@ -103,6 +127,14 @@ func (w *KubeWorker) CommandHandler(command worker.Command) bool {
 		cmd := command.(*UnInstallCommand)
 		glog.V(3).Infof(kwlog(fmt.Sprintf("uninstalling %v", cmd.Deployment)))
 
+		if mdc, ok := cmd.Deployment.(*persistence.ManifestDeploymentConfig); ok {
+			if err := w.uninstallManifests(mdc, cmd.CurrentAgreementId); err != nil {
+				glog.Errorf(kwlog(fmt.Sprintf("failed to uninstall manifests of %v: %v", cmd.CurrentAgreementId, err)))
+			}
+			w.Messages() <- events.NewWorkloadMessage(events.WORKLOAD_DESTROYED, cmd.AgreementProtocol, cmd.CurrentAgreementId, mdc)
+			return true
+		}
+
 		kdc, ok := cmd.Deployment.(*persistence.KubeDeploymentConfig)
 		if !ok {
 			glog.Warningf(kwlog(fmt.Sprintf("ignoring non-Kube cancelation command %v", cmd)))
@@ -116,10 +148,18 @@ func (w *KubeWorker) CommandHandler(command worker.Command) bool {
 		cmd := command.(*MaintenanceCommand)
 		glog.V(3).Infof(kwlog(fmt.Sprintf("recieved maintenance command %v", cmd)))
 
+		if mdc, ok := cmd.Deployment.(*persistence.ManifestDeploymentConfig); ok {
+			if err := w.rolloutStatus(mdc, cmd.AgreementId); err != nil {
+				glog.Errorf(kwlog(fmt.Sprintf("%v", err)))
+				w.Messages() <- events.NewWorkloadMessage(events.EXECUTION_FAILED, cmd.AgreementProtocol, cmd.AgreementId, mdc)
+			}
+			return true
+		}
+
 		kdc, ok := cmd.Deployment.(*persistence.KubeDeploymentConfig)
 		if !ok {
 			glog.Warningf(kwlog(fmt.Sprintf("ignoring non-Kube maintenence command: %v", cmd)))
-		} else if err := w.operatorStatus(kdc, "Running", cmd.AgreementId); err != nil {
+		} else if err := w.operatorStatus(kdc, cmd.AgreementProtocol, cmd.AgreementId); err != nil {
 			glog.Errorf(kwlog(fmt.Sprintf("%v", err)))
 			w.Messages() <- events.NewWorkloadMessage(events.EXECUTION_FAILED, cmd.AgreementProtocol, cmd.AgreementId, kdc)
 		}
@@ -144,46 +184,103 @@ func (w *KubeWorker) processKubeOperator(lc *events.AgreementLaunchContext, kd *
 	if err != nil {
 		return err
 	}
-	err = client.Install(kd.OperatorYamlArchive, *(lc.EnvironmentAdditions), lc.AgreementId)
+	err = client.Install(kd.OperatorYamlArchive, *(lc.EnvironmentAdditions), lc.Secrets, lc.AgreementId, kd.Namespace, kd.Resources)
 	if err != nil {
 		return err
 	}
 	return nil
 }
 
+// Apply the manifests of an agreement and tell governance whether the workload started.
+func (w *KubeWorker) installManifests(lc *events.AgreementLaunchContext, md *persistence.ManifestDeploymentConfig) {
+	namespace, err := ResolveNamespace(lc.ClusterNamespace, lc.AgreementId)
+	if err != nil {
+		glog.Errorf(kwlog(fmt.Sprintf("failed to process manifests after agreement negotiation: %v", err)))
+		w.Messages() <- events.NewWorkloadMessage(events.EXECUTION_FAILED, lc.AgreementProtocol, lc.AgreementId, md)
+		return
+	}
+	md.Namespace = namespace
+
+	if _, err := persistence.AgreementDeploymentStarted(w.db, lc.AgreementId, lc.AgreementProtocol, md); err != nil {
+		glog.Errorf(kwlog(fmt.Sprintf("received error updating database deployment state, %v", err)))
+		w.Messages() <- events.NewWorkloadMessage(events.EXECUTION_FAILED, lc.AgreementProtocol, lc.AgreementId, md)
+		return
+	}
+
+	glog.V(3).Infof(kwlog(fmt.Sprintf("begin install of manifests for %s", lc.AgreementId)))
+	envAdds := map[string]string{}
+	if lc.EnvironmentAdditions != nil {
+		envAdds = *(lc.EnvironmentAdditions)
+	}
+	if client, err := NewKubeClient(); err != nil {
+		glog.Errorf(kwlog(fmt.Sprintf("failed to process manifests after agreement negotiation: %v", err)))
+		w.Messages() <- events.NewWorkloadMessage(events.EXECUTION_FAILED, lc.AgreementProtocol, lc.AgreementId, md)
+	} else if err := client.InstallManifests(md.ManifestArchive, envAdds, lc.Secrets, lc.AgreementId, md.Namespace, md.Resources); err != nil {
+		glog.Errorf(kwlog(fmt.Sprintf("failed to process manifests after agreement negotiation: %v", err)))
+		w.Messages() <- events.NewWorkloadMessage(events.EXECUTION_FAILED, lc.AgreementProtocol, lc.AgreementId, md)
+	} else {
+		w.Messages() <- events.NewWorkloadMessage(events.EXECUTION_BEGUN, lc.AgreementProtocol, lc.AgreementId, md)
+	}
+}
+
+func (w *KubeWorker) uninstallManifests(md *persistence.ManifestDeploymentConfig, agId string) error {
+	glog.V(3).Infof(kwlog(fmt.Sprintf("begin uninstall of manifests for %s", agId)))
+	client, err := NewKubeClient()
+	if err != nil {
+		return err
+	}
+	return client.UninstallManifests(md.ManifestArchive, agId, ManifestNamespace(md, agId))
+}
+
+func (w *KubeWorker) rolloutStatus(md *persistence.ManifestDeploymentConfig, agId string) error {
+	glog.V(5).Infof(kwlog(fmt.Sprintf("begin checking rollout status of %v", agId)))
+	client, err := NewKubeClient()
+	if err != nil {
+		return err
+	}
+	return client.RolloutStatus(ManifestNamespace(md, agId))
+}
+
 func (w *KubeWorker) uninstallKubeOperator(kd *persistence.KubeDeploymentConfig, agId string) error {
 	glog.V(3).Infof(kwlog(fmt.Sprintf("begin uninstall of Kube Deployment %s", agId)))
 	client, err := NewKubeClient()
 	if err != nil {
 		return err
 	}
-	err = client.Uninstall(kd.OperatorYamlArchive, agId)
+	err = client.Uninstall(kd.OperatorYamlArchive, agId, kd.Namespace)
 	if err != nil {
 		return err
 	}
 	return nil
 }
 
-func (w *KubeWorker) operatorStatus(kd *persistence.KubeDeploymentConfig, intendedState string, agId string) error {
+// Save the status of the workload of an operator into its agreement, and return an error when the workload failed.
+func (w *KubeWorker) operatorStatus(kd *persistence.KubeDeploymentConfig, protocol string, agId string) error {
 	glog.V(5).Infof(kwlog(fmt.Sprintf("begin listing operator status %v", kd)))
 	client, err := NewKubeClient()
 	if err != nil {
 		return err
 	}
-	opStatus, err := client.Status(kd.OperatorYamlArchive)
+	status, err := client.WorkloadStatus(kd.OperatorYamlArchive, kd.Namespace)
 	if err != nil {
 		return err
 	}
-	retErrorStr := ""
-	for _, container := range opStatus {
-		if container.State != intendedState {
-			retErrorStr = fmt.Sprintf("%s %s", retErrorStr, fmt.Sprintf("Container %s has status %s.", container.Name, container.State))
-		}
+
+	glog.V(5).Infof(kwlog(fmt.Sprintf("operator status of %v: %v", agId, status)))
+	ag, err := persistence.AgreementClusterStatusUpdated(w.db, agId, protocol, status)
+	if err != nil {
+		glog.Errorf(kwlog(fmt.Sprintf("unable to save the operator status of %v, error: %v", agId, err)))
 	}
-	if retErrorStr != "" {
-		return fmt.Errorf(retErrorStr)
+
+	if !status.Failed {
+		return nil
+	} else if ag != nil {
+		eventlog.LogAgreementEvent(w.db, persistence.SEVERITY_ERROR,
+			persistence.NewMessageMeta(EL_KUBE_OPERATOR_FAILED, ag.RunningWorkload.URL, status.LastError),
+			persistence.EC_ERROR_START_CONTAINER,
+			*ag)
 	}
-	return nil
+	return fmt.Errorf("operator of agreement %v failed: %v", agId, status.LastError)
 }
 
 var kwlog = func(v interface{}) string {
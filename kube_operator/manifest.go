@@ -0,0 +1,505 @@
+package kube_operator
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"github.com/golang/glog"
+	"github.com/open-horizon/anax/config"
+	"github.com/open-horizon/anax/cutil"
+	"github.com/open-horizon/anax/persistence"
+	"io"
+	"io/ioutil"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/types"
+	utilyaml "k8s.io/apimachinery/pkg/util/yaml"
+	"k8s.io/client-go/restmapper"
+	"os"
+	"os/exec"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+const (
+	// The namespaces of the agreements are named with this prefix and the agreement id.
+	AGREEMENT_NAMESPACE_PREFIX = "hzn-"
+	// The annotation on the namespace of an agreement, and on the objects applied into it, that holds the agreement id.
+	AGREEMENT_ANNOTATION = "openhorizon.anax/agreement-id"
+	// The label on the namespaces that the agent created.
+	MANAGED_BY_LABEL = "app.kubernetes.io/managed-by"
+	MANAGED_BY_VALUE = "openhorizon-agent"
+	// The field manager of the server-side apply of the manifests.
+	MANIFEST_FIELD_MANAGER = "openhorizon-agent"
+	// The command that builds an archive with a kustomization file at its top.
+	KUSTOMIZE_COMMAND = "kubectl kustomize %v"
+)
+
+// The names of the kustomization file that kustomize looks for.
+var kustomizationFiles = []string{"kustomization.yaml", "kustomization.yml", "Kustomization"}
+
+// The kinds of objects that have a pod template at spec.template, where the user input is injected.
+var podTemplateKinds = map[string]bool{"Deployment": true, "StatefulSet": true, "DaemonSet": true, "ReplicaSet": true, "Job": true}
+
+// InstallManifests applies the manifests in the archive into the namespace of the agreement with server-side apply.
+// The namespace is created for the agreement, and the user input is put into a config map that the containers get
+// their environment from. The secret user input is put into a secret that the containers get their environment from.
+func (c KubeClient) InstallManifests(archive string, envVars map[string]string, secrets map[string]string, agId string, namespace string, resources *persistence.ClusterResources) error {
+
+	objs, err := getManifestObjects(archive)
+	if err != nil {
+		return err
+	}
+
+	if err := c.prepareNamespace(namespace, agId, resources); err != nil {
+		return err
+	}
+
+	// The ESS is not supported in edge cluster services, so for now, remove the ESS env vars.
+	envAdds := cutil.RemoveESSEnvVars(envVars, config.ENVVAR_PREFIX)
+	configMap, err := configMapObject(envAdds, agId)
+	if err != nil {
+		return err
+	}
+	mapName := configMap.GetName()
+
+	envObjs := []*unstructured.Unstructured{configMap}
+	secretName := ""
+	if len(secrets) != 0 {
+		secret, err := secretObject(secrets, agId)
+		if err != nil {
+			return err
+		}
+		secretName = secret.GetName()
+		envObjs = append(envObjs, secret)
+	}
+
+	groupResources, err := restmapper.GetAPIGroupResources(c.Client.Discovery())
+	if err != nil {
+		return fmt.Errorf("Error discovering the resources of the cluster: %v", err)
+	}
+	mapper := restmapper.NewDiscoveryRESTMapper(groupResources)
+
+	dynClient, err := NewDynamicKubeClient()
+	if err != nil {
+		return err
+	}
+
+	force := true
+	for _, obj := range append(envObjs, objs...) {
+		gvk := obj.GroupVersionKind()
+		mapping, err := mapper.RESTMapping(gvk.GroupKind(), gvk.Version)
+		if err != nil {
+			return fmt.Errorf("Error finding the resource of %v %v: %v", gvk.Kind, obj.GetName(), err)
+		} else if mapping.Scope.Name() != meta.RESTScopeNameNamespace {
+			return fmt.Errorf("%v %v is not namespaced, only namespaced objects can be in the manifests", gvk.Kind, obj.GetName())
+		}
+
+		if ns := obj.GetNamespace(); ns != "" && ns != namespace {
+			glog.Warningf(kwlog(fmt.Sprintf("applying %v %v into namespace %v instead of %v", gvk.Kind, obj.GetName(), namespace, ns)))
+		}
+		obj.SetNamespace(namespace)
+		setAgreementAnnotation(obj, agId)
+		if err := addConfigMapEnvFrom(obj, mapName, secretName); err != nil {
+			return err
+		}
+
+		data, err := json.Marshal(obj.Object)
+		if err != nil {
+			return fmt.Errorf("Error marshalling %v %v: %v", gvk.Kind, obj.GetName(), err)
+		}
+
+		glog.V(3).Infof(kwlog(fmt.Sprintf("applying %v %v in namespace %v", gvk.Kind, obj.GetName(), namespace)))
+		_, err = dynClient.Resource(mapping.Resource).Namespace(namespace).Patch(obj.GetName(), types.ApplyPatchType, data, metav1.PatchOptions{FieldManager: MANIFEST_FIELD_MANAGER, Force: &force})
+		if err != nil {
+			return fmt.Errorf("Error applying %v %v: %v", gvk.Kind, obj.GetName(), err)
+		}
+	}
+	glog.V(3).Infof(kwlog(fmt.Sprintf("all manifest objects applied in namespace %v", namespace)))
+
+	return nil
+}
+
+// UninstallManifests removes the objects of an agreement. The namespace of the agreement is removed with everything in
+// it. From a namespace that was not created for the agreement, the objects that were applied for it are removed.
+func (c KubeClient) UninstallManifests(archive string, agId string, namespace string) error {
+
+	if ns, err := c.Client.CoreV1().Namespaces().Get(namespace, metav1.GetOptions{}); err == nil && ns.ObjectMeta.Annotations[AGREEMENT_ANNOTATION] != agId {
+		if err := c.deleteManifestObjects(archive, agId, namespace); err != nil {
+			glog.Errorf(kwlog(fmt.Sprintf("unable to delete the manifest objects of %v: %v", agId, err)))
+		}
+	}
+
+	if err := c.removeNamespace(namespace, agId); err != nil {
+		return err
+	}
+	glog.V(3).Infof(kwlog(fmt.Sprintf("Completed removal of all manifest objects from the cluster.")))
+	return nil
+}
+
+// Delete the objects in the manifests that were applied for an agreement, which is known from their annotation.
+func (c KubeClient) deleteManifestObjects(archive string, agId string, namespace string) error {
+
+	objs, err := getManifestObjects(archive)
+	if err != nil {
+		return err
+	}
+	configMap, err := configMapObject(map[string]string{}, agId)
+	if err != nil {
+		return err
+	}
+	secret, err := secretObject(map[string]string{}, agId)
+	if err != nil {
+		return err
+	}
+
+	groupResources, err := restmapper.GetAPIGroupResources(c.Client.Discovery())
+	if err != nil {
+		return fmt.Errorf("Error discovering the resources of the cluster: %v", err)
+	}
+	mapper := restmapper.NewDiscoveryRESTMapper(groupResources)
+
+	dynClient, err := NewDynamicKubeClient()
+	if err != nil {
+		return err
+	}
+
+	for _, obj := range append(objs, configMap, secret) {
+		gvk := obj.GroupVersionKind()
+		mapping, err := mapper.RESTMapping(gvk.GroupKind(), gvk.Version)
+		if err != nil {
+			glog.Errorf(kwlog(fmt.Sprintf("unable to find the resource of %v %v. Error: %v", gvk.Kind, obj.GetName(), err)))
+			continue
+		}
+
+		resClient := dynClient.Resource(mapping.Resource).Namespace(namespace)
+		if current, err := resClient.Get(obj.GetName(), metav1.GetOptions{}); err != nil {
+			glog.V(3).Infof(kwlog(fmt.Sprintf("unable to get %v %v. Error: %v", gvk.Kind, obj.GetName(), err)))
+		} else if current.GetAnnotations()[AGREEMENT_ANNOTATION] != agId {
+			glog.Warningf(kwlog(fmt.Sprintf("not deleting %v %v that was not applied for agreement %v", gvk.Kind, obj.GetName(), agId)))
+		} else {
+			glog.V(3).Infof(kwlog(fmt.Sprintf("deleting %v %v", gvk.Kind, obj.GetName())))
+			propagation := metav1.DeletePropagationBackground
+			if err := resClient.Delete(obj.GetName(), &metav1.DeleteOptions{PropagationPolicy: &propagation}); err != nil {
+				glog.Errorf(kwlog(fmt.Sprintf("unable to delete %v %v. Error: %v", gvk.Kind, obj.GetName(), err)))
+			}
+		}
+	}
+	return nil
+}
+
+// ManifestStatus returns the status of the containers in the namespace of an agreement.
+func (c KubeClient) ManifestStatus(namespace string) ([]ContainerStatus, error) {
+	podList, err := c.Client.CoreV1().Pods(namespace).List(metav1.ListOptions{})
+	if err != nil {
+		return nil, err
+	}
+
+	containerStatuses := []ContainerStatus{}
+	for _, pod := range podList.Items {
+		for _, status := range pod.Status.ContainerStatuses {
+			containerStatuses = append(containerStatuses, getContainerStatus(status))
+		}
+	}
+	return containerStatuses, nil
+}
+
+// RolloutStatus returns an error if a deployment in the namespace of an agreement failed to roll out within its
+// progress deadline, or if a container keeps failing.
+func (c KubeClient) RolloutStatus(namespace string) error {
+
+	deployments, err := c.Client.AppsV1().Deployments(namespace).List(metav1.ListOptions{})
+	if err != nil {
+		return err
+	}
+	for _, dep := range deployments.Items {
+		for _, cond := range dep.Status.Conditions {
+			if cond.Type == "Progressing" && cond.Status == corev1.ConditionFalse {
+				return fmt.Errorf("Deployment %v failed to roll out: %v", dep.ObjectMeta.Name, cond.Message)
+			}
+		}
+	}
+
+	podList, err := c.Client.CoreV1().Pods(namespace).List(metav1.ListOptions{})
+	if err != nil {
+		return err
+	}
+	for _, pod := range podList.Items {
+		for _, status := range pod.Status.ContainerStatuses {
+			if status.State.Waiting != nil && isFailedWaitReason(status.State.Waiting.Reason) {
+				return fmt.Errorf("Container %s of pod %s is %s: %s", status.Name, pod.ObjectMeta.Name, status.State.Waiting.Reason, status.State.Waiting.Message)
+			}
+		}
+	}
+	return nil
+}
+
+func isFailedWaitReason(reason string) bool {
+	switch reason {
+	case "CrashLoopBackOff", "ImagePullBackOff", "ErrImagePull", "CreateContainerConfigError", "InvalidImageName":
+		return true
+	}
+	return false
+}
+
+func getContainerStatus(status corev1.ContainerStatus) ContainerStatus {
+	newStatus := ContainerStatus{Name: status.Name, Image: status.Image}
+	if status.State.Running != nil {
+		newStatus.State = "Running"
+		newStatus.CreatedTime = status.State.Running.StartedAt.Time.Unix()
+	} else if status.State.Terminated != nil {
+		newStatus.State = "Terminated"
+		newStatus.CreatedTime = status.State.Terminated.StartedAt.Time.Unix()
+	} else {
+		newStatus.State = "Waiting"
+	}
+	return newStatus
+}
+
+// Returns the config map with the environment variables of an agreement, named like the one created by CreateConfigMap.
+func configMapObject(envVars map[string]string, agId string) (*unstructured.Unstructured, error) {
+	hznEnvConfigMap := corev1.ConfigMap{
+		TypeMeta:   metav1.TypeMeta{APIVersion: "v1", Kind: "ConfigMap"},
+		ObjectMeta: metav1.ObjectMeta{Name: fmt.Sprintf("%s-%s", HZN_ENV_VARS, agId)},
+		Data:       envVars,
+	}
+	obj, err := runtimeToUnstructured(&hznEnvConfigMap)
+	if err != nil {
+		return nil, fmt.Errorf("Error: failed to create config map for %s: %v", agId, err)
+	}
+	return obj, nil
+}
+
+// Returns the secret with the secret user input of an agreement, named like the one created by CreateSecret.
+func secretObject(secrets map[string]string, agId string) (*unstructured.Unstructured, error) {
+	hznSecret := corev1.Secret{
+		TypeMeta:   metav1.TypeMeta{APIVersion: "v1", Kind: "Secret"},
+		ObjectMeta: metav1.ObjectMeta{Name: fmt.Sprintf("%s-%s", HZN_SECRETS, agId)},
+		Type:       corev1.SecretTypeOpaque,
+		StringData: secrets,
+	}
+	obj, err := runtimeToUnstructured(&hznSecret)
+	if err != nil {
+		return nil, fmt.Errorf("Error: failed to create secret for %s: %v", agId, err)
+	}
+	return obj, nil
+}
+
+func runtimeToUnstructured(obj interface{}) (*unstructured.Unstructured, error) {
+	u := make(map[string]interface{})
+	if data, err := json.Marshal(obj); err != nil {
+		return nil, err
+	} else if err := json.Unmarshal(data, &u); err != nil {
+		return nil, err
+	}
+	return &unstructured.Unstructured{Object: u}, nil
+}
+
+func setAgreementAnnotation(obj *unstructured.Unstructured, agId string) {
+	annotations := obj.GetAnnotations()
+	if annotations == nil {
+		annotations = make(map[string]string)
+	}
+	annotations[AGREEMENT_ANNOTATION] = agId
+	obj.SetAnnotations(annotations)
+}
+
+// Add the config map with the environment variables of the agreement to the containers of an object with a pod
+// template, along with the variable that names it, the same as for an operator. The secret with the secret user input
+// is added the same way when there is one.
+func addConfigMapEnvFrom(obj *unstructured.Unstructured, configMapName string, secretName string) error {
+	if !podTemplateKinds[obj.GetKind()] {
+		return nil
+	}
+
+	for _, field := range []string{"containers", "initContainers"} {
+		fields := []string{"spec", "template", "spec", field}
+		containers, found, err := unstructured.NestedSlice(obj.Object, fields...)
+		if err != nil {
+			return fmt.Errorf("Error reading the %v of %v %v: %v", field, obj.GetKind(), obj.GetName(), err)
+		} else if !found {
+			continue
+		}
+
+		for i, c := range containers {
+			container, ok := c.(map[string]interface{})
+			if !ok {
+				return fmt.Errorf("%v %v has an invalid container %v", obj.GetKind(), obj.GetName(), c)
+			}
+			envFrom, _ := container["envFrom"].([]interface{})
+			envFrom = append(envFrom, map[string]interface{}{
+				"configMapRef": map[string]interface{}{"name": configMapName},
+			})
+			env, _ := container["env"].([]interface{})
+			env = append(env, map[string]interface{}{"name": HZN_ENV_KEY, "value": configMapName})
+			if secretName != "" {
+				envFrom = append(envFrom, map[string]interface{}{
+					"secretRef": map[string]interface{}{"name": secretName},
+				})
+				env = append(env, map[string]interface{}{"name": HZN_SECRETS_KEY, "value": secretName})
+			}
+			container["envFrom"] = envFrom
+			container["env"] = env
+			containers[i] = container
+		}
+
+		if err := unstructured.SetNestedSlice(obj.Object, containers, fields...); err != nil {
+			return fmt.Errorf("Error setting the %v of %v %v: %v", field, obj.GetKind(), obj.GetName(), err)
+		}
+	}
+	return nil
+}
+
+// Returns the objects in a manifest archive. An archive with a kustomization file at its top is built with kustomize,
+// otherwise the objects are read from the yaml and json files in it.
+func getManifestObjects(archive string) ([]*unstructured.Unstructured, error) {
+	files, err := getYamlFromTarGz(archive)
+	if err != nil {
+		return nil, fmt.Errorf("Error reading the manifest archive: %v", err)
+	}
+
+	if isKustomization(files) {
+		out, err := buildKustomization(archive)
+		if err != nil {
+			return nil, err
+		}
+		return decodeManifests("kustomize output", out)
+	}
+
+	objs := []*unstructured.Unstructured{}
+	for _, file := range files {
+		switch strings.ToLower(path.Ext(file.Header.Name)) {
+		case ".yaml", ".yml", ".json":
+			fileObjs, err := decodeManifests(file.Header.Name, []byte(file.Body))
+			if err != nil {
+				return nil, err
+			}
+			objs = append(objs, fileObjs...)
+		default:
+			glog.V(5).Infof(kwlog(fmt.Sprintf("ignoring file %v in the manifest archive", file.Header.Name)))
+		}
+	}
+
+	if len(objs) == 0 {
+		return nil, fmt.Errorf("Error: the manifest archive has no objects")
+	}
+	return objs, nil
+}
+
+// Decode the objects in a yaml file, which can have more than one document, or a json file.
+func decodeManifests(name string, content []byte) ([]*unstructured.Unstructured, error) {
+	objs := []*unstructured.Unstructured{}
+	decoder := utilyaml.NewYAMLOrJSONDecoder(bytes.NewReader(content), 4096)
+	for {
+		obj := make(map[string]interface{})
+		if err := decoder.Decode(&obj); err == io.EOF {
+			break
+		} else if err != nil {
+			return nil, fmt.Errorf("Error decoding manifest %v: %v", name, err)
+		} else if len(obj) == 0 {
+			continue
+		}
+
+		u := &unstructured.Unstructured{Object: obj}
+		if u.GetAPIVersion() == "" || u.GetKind() == "" {
+			return nil, fmt.Errorf("Error: an object in manifest %v has no apiVersion or kind", name)
+		} else if u.GetName() == "" {
+			return nil, fmt.Errorf("Error: a %v in manifest %v has no name", u.GetKind(), name)
+		} else if u.GetKind() == K8S_NAMESPACE_TYPE {
+			return nil, fmt.Errorf("Error: manifest %v has a namespace, the objects are applied into the namespace of the agreement", name)
+		}
+		objs = append(objs, u)
+	}
+	return objs, nil
+}
+
+func isKustomization(files []YamlFile) bool {
+	for _, file := range files {
+		for _, k := range kustomizationFiles {
+			if path.Clean(file.Header.Name) == k {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// Extract the archive into a temporary directory and build it with kustomize.
+func buildKustomization(archive string) ([]byte, error) {
+	dir, err := ioutil.TempDir("", "anax-kustomize-")
+	if err != nil {
+		return nil, err
+	}
+	defer os.RemoveAll(dir)
+
+	if err := extractTarGz(archive, dir); err != nil {
+		return nil, fmt.Errorf("Error extracting the manifest archive: %v", err)
+	}
+
+	args := strings.Fields(fmt.Sprintf(KUSTOMIZE_COMMAND, dir))
+	glog.V(3).Infof(kwlog(fmt.Sprintf("building kustomization: %v", args)))
+	out, err := exec.Command(args[0], args[1:]...).Output()
+	if err != nil {
+		errMsg := ""
+		if exErr, ok := err.(*exec.ExitError); ok {
+			errMsg = string(exErr.Stderr)
+		}
+		return nil, fmt.Errorf("Error building kustomization: %v %v", err, errMsg)
+	}
+	return out, nil
+}
+
+// Extract the files of a base64 encoded tar.gz archive into a directory. Files outside of the directory are rejected.
+func extractTarGz(archive string, dir string) error {
+	archiveData, err := base64.StdEncoding.DecodeString(archive)
+	if err != nil {
+		return err
+	}
+	zipReader, err := gzip.NewReader(bytes.NewReader(archiveData))
+	if err != nil {
+		return err
+	}
+	tarReader := tar.NewReader(zipReader)
+
+	for {
+		header, err := tarReader.Next()
+		if err == io.EOF {
+			return nil
+		} else if err != nil {
+			return err
+		}
+
+		target := filepath.Join(dir, filepath.FromSlash(header.Name))
+		if target != dir && !strings.HasPrefix(target, dir+string(filepath.Separator)) {
+			return fmt.Errorf("file %v is outside of the archive", header.Name)
+		}
+
+		switch header.Typeflag {
+		case tar.TypeDir:
+			if err := os.MkdirAll(target, 0700); err != nil {
+				return err
+			}
+		case tar.TypeReg:
+			if err := os.MkdirAll(filepath.Dir(target), 0700); err != nil {
+				return err
+			}
+			f, err := os.OpenFile(target, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0600)
+			if err != nil {
+				return err
+			}
+			_, err = io.Copy(f, tarReader)
+			f.Close()
+			if err != nil {
+				return err
+			}
+		default:
+			return fmt.Errorf("file %v in the archive is not a regular file or directory", header.Name)
+		}
+	}
+}
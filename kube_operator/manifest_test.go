@@ -0,0 +1,157 @@
+// +build unit
+
+package kube_operator
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"encoding/base64"
+	"io/ioutil"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"os"
+	"strings"
+	"testing"
+)
+
+const testDeployment = `apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: web
+  namespace: other
+spec:
+  template:
+    spec:
+      containers:
+      - name: web
+        image: nginx
+        env:
+        - name: MODE
+          value: fast
+`
+
+const testService = `apiVersion: v1
+kind: Service
+metadata:
+  name: web
+spec:
+  ports:
+  - port: 80
+`
+
+func Test_AgreementNamespace(t *testing.T) {
+	agId := strings.Repeat("0123456789abcdef", 4)
+	if ns := AgreementNamespace(agId); len(ns) != 63 || !strings.HasPrefix(ns, AGREEMENT_NAMESPACE_PREFIX+"0123") {
+		t.Errorf("expected a 63 character namespace for %v, got %v", agId, ns)
+	} else if ns := AgreementNamespace("Ag_1"); ns != "hzn-ag-1" {
+		t.Errorf("expected namespace hzn-ag-1, got %v", ns)
+	}
+}
+
+func Test_getManifestObjects(t *testing.T) {
+
+	archive := testArchive(t, map[string]string{
+		"app/web.yaml": testDeployment + "---\n" + testService,
+		"README.md":    "not a manifest",
+	})
+
+	objs, err := getManifestObjects(archive)
+	if err != nil {
+		t.Fatalf("unexpected error reading manifests, %v", err)
+	} else if len(objs) != 2 || objs[0].GetKind() != "Deployment" || objs[1].GetKind() != "Service" {
+		t.Fatalf("expected a deployment and a service, got %v", objs)
+	}
+
+	if err := addConfigMapEnvFrom(objs[0], "hzn-env-vars-ag1", ""); err != nil {
+		t.Fatalf("unexpected error adding the config map, %v", err)
+	}
+	containers, _, _ := unstructured.NestedSlice(objs[0].Object, "spec", "template", "spec", "containers")
+	container := containers[0].(map[string]interface{})
+	if envFrom := container["envFrom"].([]interface{}); len(envFrom) != 1 {
+		t.Errorf("expected the config map in the environment, got %v", envFrom)
+	} else if env := container["env"].([]interface{}); len(env) != 2 || env[1].(map[string]interface{})["name"] != HZN_ENV_KEY {
+		t.Errorf("expected the config map variable to be added, got %v", env)
+	}
+
+	// the secret user input comes from a secret
+	objs, _ = getManifestObjects(archive)
+	if err := addConfigMapEnvFrom(objs[0], "hzn-env-vars-ag1", "hzn-secrets-ag1"); err != nil {
+		t.Fatalf("unexpected error adding the secret, %v", err)
+	}
+	containers, _, _ = unstructured.NestedSlice(objs[0].Object, "spec", "template", "spec", "containers")
+	container = containers[0].(map[string]interface{})
+	if envFrom := container["envFrom"].([]interface{}); len(envFrom) != 2 || envFrom[1].(map[string]interface{})["secretRef"] == nil {
+		t.Errorf("expected the secret in the environment, got %v", envFrom)
+	} else if env := container["env"].([]interface{}); len(env) != 3 || env[2].(map[string]interface{})["name"] != HZN_SECRETS_KEY {
+		t.Errorf("expected the secret variable to be added, got %v", env)
+	}
+
+	if secret, err := secretObject(map[string]string{"PASSWORD": "s3cret"}, "ag1"); err != nil {
+		t.Errorf("unexpected error creating the secret, %v", err)
+	} else if secret.GetKind() != "Secret" || secret.GetName() != "hzn-secrets-ag1" {
+		t.Errorf("unexpected secret %v", secret)
+	} else if value, _, _ := unstructured.NestedString(secret.Object, "stringData", "PASSWORD"); value != "s3cret" {
+		t.Errorf("expected the secret user input in the secret, got %v", secret)
+	}
+
+	// the service has no pod template
+	if err := addConfigMapEnvFrom(objs[1], "hzn-env-vars-ag1", ""); err != nil {
+		t.Errorf("unexpected error adding the config map, %v", err)
+	} else if _, found, _ := unstructured.NestedSlice(objs[1].Object, "spec", "template", "spec", "containers"); found {
+		t.Errorf("expected the service not to be changed, got %v", objs[1])
+	}
+}
+
+func Test_getManifestObjects_invalid(t *testing.T) {
+
+	if _, err := getManifestObjects(testArchive(t, map[string]string{"README.md": "nothing"})); err == nil {
+		t.Errorf("expected an error for an archive without manifests")
+	} else if _, err := getManifestObjects(testArchive(t, map[string]string{"ns.yaml": "apiVersion: v1\nkind: Namespace\nmetadata:\n  name: mine\n"})); err == nil {
+		t.Errorf("expected an error for a manifest with a namespace")
+	} else if _, err := getManifestObjects(testArchive(t, map[string]string{"cm.yaml": "apiVersion: v1\nkind: ConfigMap\n"})); err == nil {
+		t.Errorf("expected an error for an object without a name")
+	}
+}
+
+func Test_isKustomization(t *testing.T) {
+	if !isKustomization([]YamlFile{{Header: tar.Header{Name: "./kustomization.yaml"}}}) {
+		t.Errorf("expected the archive to be a kustomization")
+	} else if isKustomization([]YamlFile{{Header: tar.Header{Name: "base/kustomization.yaml"}}}) {
+		t.Errorf("expected a kustomization below the top not to count")
+	}
+}
+
+func Test_extractTarGz(t *testing.T) {
+
+	dir, err := ioutil.TempDir("", "manifest-test-")
+	if err != nil {
+		t.Fatalf("unable to create directory, %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	if err := extractTarGz(testArchive(t, map[string]string{"base/web.yaml": testDeployment}), dir); err != nil {
+		t.Errorf("unexpected error extracting the archive, %v", err)
+	} else if content, err := ioutil.ReadFile(dir + "/base/web.yaml"); err != nil || string(content) != testDeployment {
+		t.Errorf("expected the file to be extracted, got %v %v", string(content), err)
+	}
+
+	if err := extractTarGz(testArchive(t, map[string]string{"../escape.yaml": testService}), dir); err == nil {
+		t.Errorf("expected an error for a file outside of the archive")
+	}
+}
+
+func testArchive(t *testing.T, files map[string]string) string {
+	var buf bytes.Buffer
+	zw := gzip.NewWriter(&buf)
+	tw := tar.NewWriter(zw)
+	for name, content := range files {
+		if err := tw.WriteHeader(&tar.Header{Name: name, Mode: 0600, Size: int64(len(content)), Typeflag: tar.TypeReg}); err != nil {
+			t.Fatalf("unable to write archive, %v", err)
+		} else if _, err := tw.Write([]byte(content)); err != nil {
+			t.Fatalf("unable to write archive, %v", err)
+		}
+	}
+	tw.Close()
+	zw.Close()
+	return base64.StdEncoding.EncodeToString(buf.Bytes())
+}
@@ -0,0 +1,215 @@
+package kube_operator
+
+import (
+	"fmt"
+	"github.com/golang/glog"
+	"github.com/open-horizon/anax/persistence"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/validation"
+	"regexp"
+	"strings"
+)
+
+const (
+	// The prefixes of the names of the objects that limit the resources of the workloads of an agreement in a
+	// namespace. The id of the agreement is added to them, so that agreements can share a namespace.
+	RESOURCE_QUOTA_NAME = "openhorizon-agent-quota"
+	LIMIT_RANGE_NAME    = "openhorizon-agent-limits"
+	// The default resource limit of a container is this fraction of the resources of the namespace.
+	DEFAULT_CONTAINER_LIMIT_DIVISOR = 4
+)
+
+var invalidNamespaceChars = regexp.MustCompile("[^a-z0-9-]")
+
+// Returns the name of the namespace of an agreement, which has to be a DNS label.
+func AgreementNamespace(agId string) string {
+	ns := AGREEMENT_NAMESPACE_PREFIX + invalidNamespaceChars.ReplaceAllString(strings.ToLower(agId), "-")
+	if len(ns) > 63 {
+		ns = ns[:63]
+	}
+	return strings.TrimRight(ns, "-")
+}
+
+// Returns the name of an object of an agreement, which has to be a DNS subdomain.
+func agreementObjectName(prefix string, agId string) string {
+	name := prefix + "-" + invalidNamespaceChars.ReplaceAllString(strings.ToLower(agId), "-")
+	if len(name) > 253 {
+		name = name[:253]
+	}
+	return strings.TrimRight(name, "-")
+}
+
+// Returns the namespace that the workload of a cluster agreement is deployed into, which is the namespace configured
+// by its deployment policy, or else a namespace of its own.
+func ResolveNamespace(configured string, agId string) (string, error) {
+	if configured == "" {
+		return AgreementNamespace(agId), nil
+	} else if errs := validation.IsDNS1123Label(configured); len(errs) != 0 {
+		return "", fmt.Errorf("the configured namespace %v is not valid: %v", configured, strings.Join(errs, ", "))
+	}
+	return configured, nil
+}
+
+// Returns the namespace that the manifests of an agreement were applied into. The agreements that were made before the
+// namespace was recorded in the deployment config have a namespace of their own.
+func ManifestNamespace(md *persistence.ManifestDeploymentConfig, agId string) string {
+	if md.Namespace != "" {
+		return md.Namespace
+	}
+	return AgreementNamespace(agId)
+}
+
+// Create the namespace of an agreement, and limit the resources of the workloads of the agreement in it. A configured
+// namespace might exist already, in which case it is used as is. It is not annotated with the agreement, because other
+// agreements can use it too. The namespace of an agreement might also exist already when the agreement is installed
+// again, e.g. after the agent was restarted, but it must not belong to anything else.
+func (c KubeClient) prepareNamespace(namespace string, agId string, resources *persistence.ClusterResources) error {
+
+	nsObj := &corev1.Namespace{
+		TypeMeta: metav1.TypeMeta{Kind: K8S_NAMESPACE_TYPE},
+		ObjectMeta: metav1.ObjectMeta{
+			Name:   namespace,
+			Labels: map[string]string{MANAGED_BY_LABEL: MANAGED_BY_VALUE},
+		},
+	}
+	if namespace == AgreementNamespace(agId) {
+		nsObj.ObjectMeta.Annotations = map[string]string{AGREEMENT_ANNOTATION: agId}
+	}
+
+	glog.V(3).Infof(kwlog(fmt.Sprintf("creating namespace %v", namespace)))
+	if _, err := c.Client.CoreV1().Namespaces().Create(nsObj); errors.IsAlreadyExists(err) {
+		if namespace == AgreementNamespace(agId) {
+			if ns, err := c.Client.CoreV1().Namespaces().Get(namespace, metav1.GetOptions{}); err != nil {
+				return fmt.Errorf("Error getting namespace %v: %v", namespace, err)
+			} else if ns.ObjectMeta.Annotations[AGREEMENT_ANNOTATION] != agId {
+				return fmt.Errorf("namespace %v already exists and does not belong to agreement %v", namespace, agId)
+			}
+		}
+		glog.V(3).Infof(kwlog(fmt.Sprintf("using existing namespace %v", namespace)))
+	} else if err != nil {
+		return fmt.Errorf("Error creating namespace %v: %v", namespace, err)
+	}
+
+	if resources == nil {
+		return nil
+	}
+
+	quota, limits := resourceObjects(agId, resources)
+	glog.V(3).Infof(kwlog(fmt.Sprintf("limiting the resources of namespace %v to %v", namespace, resources)))
+	if _, err := c.Client.CoreV1().ResourceQuotas(namespace).Create(quota); errors.IsAlreadyExists(err) {
+		if _, err := c.Client.CoreV1().ResourceQuotas(namespace).Update(quota); err != nil {
+			return fmt.Errorf("Error updating resource quota in namespace %v: %v", namespace, err)
+		}
+	} else if err != nil {
+		return fmt.Errorf("Error creating resource quota in namespace %v: %v", namespace, err)
+	}
+
+	if limits == nil {
+		return nil
+	} else if _, err := c.Client.CoreV1().LimitRanges(namespace).Create(limits); errors.IsAlreadyExists(err) {
+		if _, err := c.Client.CoreV1().LimitRanges(namespace).Update(limits); err != nil {
+			return fmt.Errorf("Error updating limit range in namespace %v: %v", namespace, err)
+		}
+	} else if err != nil {
+		return fmt.Errorf("Error creating limit range in namespace %v: %v", namespace, err)
+	}
+	return nil
+}
+
+// Remove the namespace of an agreement with everything in it, if it was created for the agreement. From a configured
+// namespace, only the resource limits of the agreement are removed.
+func (c KubeClient) removeNamespace(namespace string, agId string) error {
+
+	ns, err := c.Client.CoreV1().Namespaces().Get(namespace, metav1.GetOptions{})
+	if errors.IsNotFound(err) {
+		glog.V(3).Infof(kwlog(fmt.Sprintf("namespace %v has already been deleted", namespace)))
+		return nil
+	} else if err != nil {
+		return fmt.Errorf("Error getting namespace %v: %v", namespace, err)
+	}
+
+	if namespace == AgreementNamespace(agId) && ns.ObjectMeta.Annotations[AGREEMENT_ANNOTATION] == agId {
+		glog.V(3).Infof(kwlog(fmt.Sprintf("deleting namespace %v", namespace)))
+		propagation := metav1.DeletePropagationForeground
+		if err := c.Client.CoreV1().Namespaces().Delete(namespace, &metav1.DeleteOptions{PropagationPolicy: &propagation}); err != nil && !errors.IsNotFound(err) {
+			return fmt.Errorf("Error deleting namespace %v: %v", namespace, err)
+		}
+		return nil
+	}
+
+	glog.V(3).Infof(kwlog(fmt.Sprintf("keeping namespace %v that does not belong to agreement %v", namespace, agId)))
+	quotaName := agreementObjectName(RESOURCE_QUOTA_NAME, agId)
+	if quota, err := c.Client.CoreV1().ResourceQuotas(namespace).Get(quotaName, metav1.GetOptions{}); err == nil && quota.ObjectMeta.Annotations[AGREEMENT_ANNOTATION] == agId {
+		if err := c.Client.CoreV1().ResourceQuotas(namespace).Delete(quotaName, &metav1.DeleteOptions{}); err != nil {
+			glog.Errorf(kwlog(fmt.Sprintf("unable to delete resource quota in namespace %s. Error: %v", namespace, err)))
+		}
+	}
+	limitsName := agreementObjectName(LIMIT_RANGE_NAME, agId)
+	if limits, err := c.Client.CoreV1().LimitRanges(namespace).Get(limitsName, metav1.GetOptions{}); err == nil && limits.ObjectMeta.Annotations[AGREEMENT_ANNOTATION] == agId {
+		if err := c.Client.CoreV1().LimitRanges(namespace).Delete(limitsName, &metav1.DeleteOptions{}); err != nil {
+			glog.Errorf(kwlog(fmt.Sprintf("unable to delete limit range in namespace %s. Error: %v", namespace, err)))
+		}
+	}
+	return nil
+}
+
+// Returns the ResourceQuota that limits the resources of the workloads in a namespace, and the LimitRange that gives the
+// containers without limits a share of them. There is no LimitRange when neither the CPU nor the memory is limited.
+func resourceObjects(agId string, resources *persistence.ClusterResources) (*corev1.ResourceQuota, *corev1.LimitRange) {
+
+	meta := func(name string) metav1.ObjectMeta {
+		return metav1.ObjectMeta{
+			Name:        name,
+			Labels:      map[string]string{MANAGED_BY_LABEL: MANAGED_BY_VALUE},
+			Annotations: map[string]string{AGREEMENT_ANNOTATION: agId},
+		}
+	}
+
+	hard := corev1.ResourceList{}
+	max := corev1.ResourceList{}
+	defaults := corev1.ResourceList{}
+	if resources.CPUMillicores != 0 {
+		hard[corev1.ResourceLimitsCPU] = *resource.NewMilliQuantity(resources.CPUMillicores, resource.DecimalSI)
+		max[corev1.ResourceCPU] = *resource.NewMilliQuantity(resources.CPUMillicores, resource.DecimalSI)
+		defaults[corev1.ResourceCPU] = *resource.NewMilliQuantity(divide(resources.CPUMillicores), resource.DecimalSI)
+	}
+	if resources.MemoryMB != 0 {
+		hard[corev1.ResourceLimitsMemory] = *resource.NewQuantity(resources.MemoryMB*1024*1024, resource.BinarySI)
+		max[corev1.ResourceMemory] = *resource.NewQuantity(resources.MemoryMB*1024*1024, resource.BinarySI)
+		defaults[corev1.ResourceMemory] = *resource.NewQuantity(divide(resources.MemoryMB)*1024*1024, resource.BinarySI)
+	}
+	if resources.Pods != 0 {
+		hard[corev1.ResourcePods] = *resource.NewQuantity(resources.Pods, resource.DecimalSI)
+	}
+
+	quota := &corev1.ResourceQuota{
+		ObjectMeta: meta(agreementObjectName(RESOURCE_QUOTA_NAME, agId)),
+		Spec:       corev1.ResourceQuotaSpec{Hard: hard},
+	}
+	if len(defaults) == 0 {
+		return quota, nil
+	}
+
+	limits := &corev1.LimitRange{
+		ObjectMeta: meta(agreementObjectName(LIMIT_RANGE_NAME, agId)),
+		Spec: corev1.LimitRangeSpec{
+			Limits: []corev1.LimitRangeItem{{
+				Type:           corev1.LimitTypeContainer,
+				Max:            max,
+				Default:        defaults,
+				DefaultRequest: defaults,
+			}},
+		},
+	}
+	return quota, limits
+}
+
+func divide(total int64) int64 {
+	if share := total / DEFAULT_CONTAINER_LIMIT_DIVISOR; share > 0 {
+		return share
+	}
+	return total
+}
@@ -0,0 +1,92 @@
+// +build unit
+
+package kube_operator
+
+import (
+	"github.com/open-horizon/anax/persistence"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	rbacv1 "k8s.io/api/rbac/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"strings"
+	"testing"
+)
+
+func Test_ResolveNamespace(t *testing.T) {
+
+	agId := "7C6F2A1B9d0e"
+	if ns, err := ResolveNamespace("", agId); err != nil {
+		t.Errorf("unexpected error %v", err)
+	} else if ns != "hzn-7c6f2a1b9d0e" {
+		t.Errorf("expected the namespace of the agreement, got %v", ns)
+	}
+
+	if ns, err := ResolveNamespace("shared", agId); err != nil {
+		t.Errorf("unexpected error %v", err)
+	} else if ns != "shared" {
+		t.Errorf("expected the configured namespace, got %v", ns)
+	}
+
+	if _, err := ResolveNamespace("Not_A_Label", agId); err == nil {
+		t.Errorf("expected an error for an invalid namespace")
+	}
+
+	if ns := AgreementNamespace(strings.Repeat("a", 64) + "-"); len(ns) > 63 || strings.HasSuffix(ns, "-") {
+		t.Errorf("namespace %v is not a DNS label", ns)
+	}
+}
+
+func Test_resourceObjects(t *testing.T) {
+
+	quota, limits := resourceObjects("ag1", &persistence.ClusterResources{CPUMillicores: 2000, MemoryMB: 512, Pods: 3})
+	if quota.ObjectMeta.Name != RESOURCE_QUOTA_NAME+"-ag1" || quota.ObjectMeta.Annotations[AGREEMENT_ANNOTATION] != "ag1" {
+		t.Errorf("unexpected quota metadata %v", quota.ObjectMeta)
+	} else if cpu := quota.Spec.Hard[corev1.ResourceLimitsCPU]; cpu.String() != "2" {
+		t.Errorf("unexpected cpu quota %v", cpu.String())
+	} else if mem := quota.Spec.Hard[corev1.ResourceLimitsMemory]; mem.String() != "512Mi" {
+		t.Errorf("unexpected memory quota %v", mem.String())
+	} else if pods := quota.Spec.Hard[corev1.ResourcePods]; pods.String() != "3" {
+		t.Errorf("unexpected pods quota %v", pods.String())
+	}
+
+	if limits == nil || len(limits.Spec.Limits) != 1 || limits.ObjectMeta.Name != LIMIT_RANGE_NAME+"-ag1" {
+		t.Errorf("expected a limit range of the agreement, got %v", limits)
+	} else if cpu := limits.Spec.Limits[0].Default[corev1.ResourceCPU]; cpu.String() != "500m" {
+		t.Errorf("unexpected default cpu limit %v", cpu.String())
+	} else if mem := limits.Spec.Limits[0].DefaultRequest[corev1.ResourceMemory]; mem.String() != "128Mi" {
+		t.Errorf("unexpected default memory request %v", mem.String())
+	}
+
+	// only the number of pods is limited
+	if quota, limits := resourceObjects("ag1", &persistence.ClusterResources{Pods: 1}); limits != nil {
+		t.Errorf("expected no limit range, got %v", limits)
+	} else if len(quota.Spec.Hard) != 1 {
+		t.Errorf("unexpected quota %v", quota.Spec.Hard)
+	}
+}
+
+func Test_moveToNamespace(t *testing.T) {
+
+	roleBinding := &rbacv1.RoleBinding{
+		ObjectMeta: metav1.ObjectMeta{Name: "rb", Namespace: "operator"},
+		Subjects: []rbacv1.Subject{
+			{Kind: rbacv1.ServiceAccountKind, Name: "op", Namespace: "operator"},
+			{Kind: rbacv1.ServiceAccountKind, Name: "other", Namespace: "kube-system"},
+			{Kind: rbacv1.UserKind, Name: "someone"},
+		},
+	}
+	deployment := &appsv1.Deployment{ObjectMeta: metav1.ObjectMeta{Name: "op", Namespace: "operator"}}
+	objs := map[string][]APIObjects{
+		K8S_ROLEBINDING_TYPE: {{Object: roleBinding}},
+		K8S_DEPLOYMENT_TYPE:  {{Object: deployment}},
+	}
+
+	moveToNamespace(objs, "operator", "hzn-ag1")
+	if roleBinding.ObjectMeta.Namespace != "" || deployment.ObjectMeta.Namespace != "" {
+		t.Errorf("expected the namespaces of the objects to be cleared")
+	} else if roleBinding.Subjects[0].Namespace != "hzn-ag1" {
+		t.Errorf("expected the service account to move, got %v", roleBinding.Subjects[0])
+	} else if roleBinding.Subjects[1].Namespace != "kube-system" || roleBinding.Subjects[2].Namespace != "" {
+		t.Errorf("expected the other subjects to stay, got %v", roleBinding.Subjects)
+	}
+}
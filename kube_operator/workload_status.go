@@ -0,0 +1,145 @@
+package kube_operator
+
+import (
+	"fmt"
+	"github.com/open-horizon/anax/persistence"
+	corev1 "k8s.io/api/core/v1"
+	"strings"
+	"time"
+)
+
+// The condition types that mean that the workload of an operator has failed when their status is True. Operators do
+// not agree on the names, so the common ones are all accepted.
+var failureConditionTypes = []string{"Failed", "Failure", "Degraded", "Error", "ReconcileError", "ReleaseFailed"}
+
+// The phases of a custom resource that mean that the workload of an operator has failed.
+var failurePhases = []string{"failed", "failure", "error", "degraded"}
+
+// WorkloadStatus returns the status of the workload of an operator, taken from the status of its custom resource and
+// from the pods of the operator.
+func (c KubeClient) WorkloadStatus(tar string, namespace string) (*persistence.ClusterWorkloadStatus, error) {
+	res, err := c.operatorResource(tar, namespace)
+	if err != nil {
+		return nil, err
+	}
+	pods, err := c.operatorPods(tar, namespace)
+	if err != nil {
+		return nil, err
+	}
+	return clusterWorkloadStatus(res.Object["status"], pods), nil
+}
+
+// Map the status of a custom resource and the pods of the operator into the status of the workload. A custom resource
+// status is free-form, so only the fields that most operators use are understood: phase or state, conditions, the
+// replica counts and an error message. When the custom resource does not count its replicas, the pods are counted.
+func clusterWorkloadStatus(crStatus interface{}, pods []corev1.Pod) *persistence.ClusterWorkloadStatus {
+	status := &persistence.ClusterWorkloadStatus{UpdateTime: uint64(time.Now().Unix())}
+
+	crReplicas := false
+	if crMap, ok := crStatus.(map[string]interface{}); ok {
+		status.Phase = stringField(crMap, "phase", "state")
+
+		if conds, ok := crMap["conditions"].([]interface{}); ok {
+			for _, c := range conds {
+				if condMap, ok := c.(map[string]interface{}); ok {
+					status.Conditions = append(status.Conditions, persistence.ClusterCondition{
+						Type:               stringField(condMap, "type"),
+						Status:             stringField(condMap, "status"),
+						Reason:             stringField(condMap, "reason"),
+						Message:            stringField(condMap, "message"),
+						LastTransitionTime: stringField(condMap, "lastTransitionTime"),
+					})
+				}
+			}
+		}
+
+		if desired, ok := intField(crMap, "replicas", "desiredReplicas"); ok {
+			status.DesiredReplicas = desired
+			status.ReadyReplicas, _ = intField(crMap, "readyReplicas", "availableReplicas")
+			crReplicas = true
+		}
+
+		if msg := stringField(crMap, "error", "lastError", "errorMessage"); msg != "" {
+			status.LastError = msg
+			status.Failed = true
+		}
+		for _, phase := range failurePhases {
+			if strings.ToLower(status.Phase) == phase {
+				status.Failed = true
+				if status.LastError == "" {
+					status.LastError = fmt.Sprintf("custom resource is in phase %v: %v", status.Phase, stringField(crMap, "message", "reason"))
+				}
+			}
+		}
+		for _, cond := range status.Conditions {
+			if cond.Status == string(corev1.ConditionTrue) && isFailureCondition(cond.Type) {
+				status.Failed = true
+				if status.LastError == "" {
+					status.LastError = fmt.Sprintf("custom resource has condition %v: %v %v", cond.Type, cond.Reason, cond.Message)
+				}
+			}
+		}
+	}
+
+	for _, pod := range pods {
+		if !crReplicas {
+			status.DesiredReplicas++
+			if isPodReady(pod) {
+				status.ReadyReplicas++
+			}
+		}
+		for _, cs := range pod.Status.ContainerStatuses {
+			if cs.State.Waiting != nil && isFailedWaitReason(cs.State.Waiting.Reason) {
+				status.Failed = true
+				if status.LastError == "" {
+					status.LastError = fmt.Sprintf("Container %s of pod %s is %s: %s", cs.Name, pod.ObjectMeta.Name, cs.State.Waiting.Reason, cs.State.Waiting.Message)
+				}
+			}
+		}
+	}
+
+	return status
+}
+
+func isFailureCondition(condType string) bool {
+	for _, t := range failureConditionTypes {
+		if t == condType {
+			return true
+		}
+	}
+	return false
+}
+
+func isPodReady(pod corev1.Pod) bool {
+	for _, cond := range pod.Status.Conditions {
+		if cond.Type == corev1.PodReady {
+			return cond.Status == corev1.ConditionTrue
+		}
+	}
+	return false
+}
+
+// Returns the first of the fields that is a non-empty string.
+func stringField(m map[string]interface{}, names ...string) string {
+	for _, name := range names {
+		if s, ok := m[name].(string); ok && s != "" {
+			return s
+		}
+	}
+	return ""
+}
+
+// Returns the first of the fields that is a number. Unstructured objects hold int64, json decoding gives float64.
+func intField(m map[string]interface{}, names ...string) (int64, bool) {
+	for _, name := range names {
+		switch v := m[name].(type) {
+		case int64:
+			return v, true
+		case int:
+			return int64(v), true
+		case float64:
+			return int64(v), true
+		}
+	}
+	return 0, false
+}
@@ -0,0 +1,92 @@
+// +build unit
+
+package kube_operator
+
+import (
+	"encoding/json"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"strings"
+	"testing"
+)
+
+func testPod(name string, ready bool, waitReason string) corev1.Pod {
+	pod := corev1.Pod{ObjectMeta: metav1.ObjectMeta{Name: name}}
+	readyStatus := corev1.ConditionFalse
+	if ready {
+		readyStatus = corev1.ConditionTrue
+	}
+	pod.Status.Conditions = []corev1.PodCondition{{Type: corev1.PodReady, Status: readyStatus}}
+	cs := corev1.ContainerStatus{Name: "operator"}
+	if waitReason != "" {
+		cs.State.Waiting = &corev1.ContainerStateWaiting{Reason: waitReason, Message: "back-off restarting"}
+	}
+	pod.Status.ContainerStatuses = []corev1.ContainerStatus{cs}
+	return pod
+}
+
+func Test_clusterWorkloadStatus_healthy(t *testing.T) {
+
+	crStatus := map[string]interface{}{}
+	json.Unmarshal([]byte(`{"phase":"Running","replicas":3,"readyReplicas":2,"conditions":[{"type":"Ready","status":"True","reason":"Reconciled"}]}`), &crStatus)
+
+	status := clusterWorkloadStatus(crStatus, []corev1.Pod{testPod("op-1", true, "")})
+	if status.Failed || status.LastError != "" {
+		t.Errorf("expected a healthy status, got %v", status)
+	} else if status.Phase != "Running" || status.DesiredReplicas != 3 || status.ReadyReplicas != 2 {
+		t.Errorf("expected the replicas of the custom resource, got %v", status)
+	} else if len(status.Conditions) != 1 || status.Conditions[0].Reason != "Reconciled" {
+		t.Errorf("unexpected conditions %v", status.Conditions)
+	} else if status.IsReady() {
+		t.Errorf("expected the workload not to be ready with 2 of 3 replicas")
+	} else if status.UpdateTime == 0 {
+		t.Errorf("expected an update time")
+	}
+}
+
+func Test_clusterWorkloadStatus_failed(t *testing.T) {
+
+	crStatus := map[string]interface{}{}
+	json.Unmarshal([]byte(`{"conditions":[{"type":"Degraded","status":"True","reason":"InstallFailed","message":"image not found"}]}`), &crStatus)
+
+	if status := clusterWorkloadStatus(crStatus, nil); !status.Failed {
+		t.Errorf("expected a failed status for a degraded condition, got %v", status)
+	} else if !strings.Contains(status.LastError, "image not found") {
+		t.Errorf("expected the message of the condition in the error, got %v", status.LastError)
+	}
+
+	crStatus = map[string]interface{}{"state": "Error", "message": "bad spec"}
+	if status := clusterWorkloadStatus(crStatus, nil); !status.Failed || !strings.Contains(status.LastError, "bad spec") {
+		t.Errorf("expected a failed status for an error state, got %v", status)
+	}
+
+	crStatus = map[string]interface{}{"lastError": "cannot reach the database"}
+	if status := clusterWorkloadStatus(crStatus, nil); !status.Failed || status.LastError != "cannot reach the database" {
+		t.Errorf("expected the error of the custom resource, got %v", status)
+	}
+
+	// a degraded condition that is not true is not a failure
+	crStatus = map[string]interface{}{}
+	json.Unmarshal([]byte(`{"conditions":[{"type":"Degraded","status":"False"}]}`), &crStatus)
+	if status := clusterWorkloadStatus(crStatus, nil); status.Failed {
+		t.Errorf("expected a healthy status, got %v", status)
+	}
+}
+
+func Test_clusterWorkloadStatus_pods(t *testing.T) {
+
+	// without a status, the replicas are counted from the pods
+	pods := []corev1.Pod{testPod("op-1", true, ""), testPod("op-2", false, "ContainerCreating")}
+	if status := clusterWorkloadStatus(nil, pods); status.Failed {
+		t.Errorf("expected a starting pod not to fail, got %v", status)
+	} else if status.DesiredReplicas != 2 || status.ReadyReplicas != 1 {
+		t.Errorf("expected the pods to be counted, got %v", status)
+	}
+
+	pods = append(pods, testPod("op-3", false, "CrashLoopBackOff"))
+	if status := clusterWorkloadStatus(nil, pods); !status.Failed {
+		t.Errorf("expected a crashing pod to fail, got %v", status)
+	} else if !strings.Contains(status.LastError, "op-3") {
+		t.Errorf("expected the crashing pod in the error, got %v", status.LastError)
+	}
+}
@@ -15,15 +15,19 @@ import (
 	"github.com/open-horizon/anax/config"
 	"github.com/open-horizon/anax/container"
 	"github.com/open-horizon/anax/exchange"
+	"github.com/open-horizon/anax/externalpolicy"
 	_ "github.com/open-horizon/anax/externalpolicy/text_language"
 	"github.com/open-horizon/anax/governance"
+	"github.com/open-horizon/anax/helm"
 	"github.com/open-horizon/anax/i18n"
 	_ "github.com/open-horizon/anax/i18n_messages"
 	"github.com/open-horizon/anax/imagefetch"
 	"github.com/open-horizon/anax/kube_operator"
 	"github.com/open-horizon/anax/persistence"
 	"github.com/open-horizon/anax/policy"
+	"github.com/open-horizon/anax/process"
 	"github.com/open-horizon/anax/resource"
+	"github.com/open-horizon/anax/wasm"
 	"github.com/open-horizon/anax/worker"
 	"os"
 	"os/signal"
@@ -80,6 +84,14 @@ func main() {
 			panic(err)
 		}
 		db = edgeDB
+
+		// secret node user input is encrypted in the local database
+		if err := persistence.InitUserInputSecretKey(cfg.GetUserInputSecretKeyFile()); err != nil {
+			panic(err)
+		}
+
+		// the free disk space and docker version node properties are read from the configured storage and docker
+		externalpolicy.SetBuiltInPropertySources(cfg.Edge.ServiceStorage, cfg.Edge.DockerEndpoint)
 	}
 
 	// open Agreement Bot DB if necessary
@@ -173,6 +185,9 @@ func main() {
 			workers.Add(imageWorker)
 		}
 		workers.Add(kube_operator.NewKubeWorker("Kube", cfg, db))
+		workers.Add(helm.NewHelmWorker("Helm", cfg, db))
+		workers.Add(process.NewProcessWorker("Process", cfg, db))
+		workers.Add(wasm.NewWasmWorker("Wasm", cfg, db))
 		workers.Add(resource.NewResourceWorker("Resource", cfg, db, authm))
 		workers.Add(changes.NewChangesWorker("ExchangeChanges", cfg, db))
 	}
@@ -79,7 +79,7 @@ func ConvertServiceToPersistent(es *exchange.ServiceDefinition, org string) (*pe
 
 	user_inputs := make([]persistence.UserInput, 0)
 	for _, ui := range es.UserInputs {
-		new_ui := persistence.NewUserInput(ui.Name, ui.Label, ui.Type, ui.DefaultValue)
+		new_ui := persistence.NewUserInput(ui.Name, ui.Label, ui.Type, ui.DefaultValue, ui.InputConstraints)
 		user_inputs = append(user_inputs, *new_ui)
 	}
 	pms.UserInputs = user_inputs
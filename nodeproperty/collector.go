@@ -0,0 +1,101 @@
+package nodeproperty
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/golang/glog"
+	"github.com/open-horizon/anax/config"
+	"github.com/open-horizon/anax/externalpolicy"
+	"time"
+)
+
+// A provider together with its schedule and the properties that it returned last.
+type scheduledProvider struct {
+	provider Provider
+	interval time.Duration
+	nextPoll time.Time
+	props    externalpolicy.PropertyList
+}
+
+// The collector polls each of the configured providers on its own interval and merges their properties.
+type Collector struct {
+	providers []*scheduledProvider
+}
+
+// Create a collector for the configured providers. A provider that cannot be created is logged and left out, so
+// that one broken provider does not stop the others.
+func NewCollector(cfgs []config.NodePropertyProvider) *Collector {
+	c := &Collector{providers: make([]*scheduledProvider, 0, len(cfgs))}
+	for i := range cfgs {
+		if p, err := NewProvider(&cfgs[i]); err != nil {
+			glog.Errorf(logString(fmt.Sprintf("unable to create node property provider %v, error: %v", cfgs[i].Name, err)))
+		} else {
+			c.providers = append(c.providers, &scheduledProvider{provider: p, interval: time.Duration(cfgs[i].GetInterval()) * time.Second})
+		}
+	}
+	return c
+}
+
+// Returns true when there are no providers to poll.
+func (c *Collector) IsEmpty() bool {
+	return len(c.providers) == 0
+}
+
+// Returns the shortest of the provider intervals in seconds, which is how often Collect should be called.
+func (c *Collector) MinInterval() int {
+	min := 0
+	for _, sp := range c.providers {
+		if s := int(sp.interval / time.Second); min == 0 || s < min {
+			min = s
+		}
+	}
+	return min
+}
+
+// Poll the providers that are due and return the merged properties of all the providers. When a provider fails, the
+// properties that it returned last are kept, so that a transient error does not change the node policy. When two
+// providers return the same property, the provider that is configured first wins.
+func (c *Collector) Collect(now time.Time) externalpolicy.PropertyList {
+	for _, sp := range c.providers {
+		if now.Before(sp.nextPoll) {
+			continue
+		}
+		sp.nextPoll = now.Add(sp.interval)
+
+		if props, err := sp.provider.Properties(); err != nil {
+			glog.Errorf(logString(err.Error()))
+		} else if normalized, err := normalize(props); err != nil {
+			glog.Errorf(logString(fmt.Sprintf("provider %v returned properties that cannot be serialized, error: %v", sp.provider.Name(), err)))
+		} else {
+			sp.props = normalized
+		}
+	}
+
+	merged := externalpolicy.PropertyList{}
+	for _, sp := range c.providers {
+		for _, prop := range sp.props {
+			if merged.HasProperty(prop.Name) {
+				glog.Warningf(logString(fmt.Sprintf("ignoring property %v from provider %v, it is already set by another provider", prop.Name, sp.provider.Name())))
+				continue
+			}
+			merged = append(merged, prop)
+		}
+	}
+	return merged
+}
+
+// Providers that are written in Go can return values of any numeric type, the node policy holds the values that
+// json gives. Round trip the properties so that they compare equal to the ones that are saved in the database.
+func normalize(props externalpolicy.PropertyList) (externalpolicy.PropertyList, error) {
+	normalized := externalpolicy.PropertyList{}
+	if b, err := json.Marshal(props); err != nil {
+		return nil, err
+	} else if err := json.Unmarshal(b, &normalized); err != nil {
+		return nil, err
+	}
+	return normalized, nil
+}
+
+var logString = func(v interface{}) string {
+	return fmt.Sprintf("NodeProperty: %v", v)
+}
@@ -0,0 +1,51 @@
+package nodeproperty
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"github.com/open-horizon/anax/config"
+	"github.com/open-horizon/anax/externalpolicy"
+	"os/exec"
+	"time"
+)
+
+// The exec provider runs a command and parses the properties that it prints on stdout.
+type ExecProvider struct {
+	name    string
+	command string
+	args    []string
+	timeout time.Duration
+}
+
+func NewExecProvider(cfg *config.NodePropertyProvider) (Provider, error) {
+	if cfg.Command == "" {
+		return nil, fmt.Errorf("node property provider %v has no command", cfg.Name)
+	}
+	return &ExecProvider{
+		name:    cfg.Name,
+		command: cfg.Command,
+		args:    cfg.Args,
+		timeout: time.Duration(cfg.GetTimeout()) * time.Second,
+	}, nil
+}
+
+func (p *ExecProvider) Name() string {
+	return p.name
+}
+
+func (p *ExecProvider) Properties() (externalpolicy.PropertyList, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), p.timeout)
+	defer cancel()
+
+	var stderr bytes.Buffer
+	cmd := exec.CommandContext(ctx, p.command, p.args...)
+	cmd.Stderr = &stderr
+	out, err := cmd.Output()
+	if ctx.Err() == context.DeadlineExceeded {
+		return nil, fmt.Errorf("command %v of provider %v did not finish within %v", p.command, p.name, p.timeout)
+	} else if err != nil {
+		return nil, fmt.Errorf("command %v of provider %v failed, error: %v, stderr: %v", p.command, p.name, err, stderr.String())
+	}
+	return parseProperties(p.name, out)
+}
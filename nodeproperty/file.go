@@ -0,0 +1,34 @@
+package nodeproperty
+
+import (
+	"fmt"
+	"github.com/open-horizon/anax/config"
+	"github.com/open-horizon/anax/externalpolicy"
+	"io/ioutil"
+)
+
+// The file provider reads the properties from a local file, e.g. a site id that is written when the node is
+// installed. The file is read every time the properties are collected, so it can be changed while the agent runs.
+type FileProvider struct {
+	name string
+	path string
+}
+
+func NewFileProvider(cfg *config.NodePropertyProvider) (Provider, error) {
+	if cfg.Path == "" {
+		return nil, fmt.Errorf("node property provider %v has no path", cfg.Name)
+	}
+	return &FileProvider{name: cfg.Name, path: cfg.Path}, nil
+}
+
+func (p *FileProvider) Name() string {
+	return p.name
+}
+
+func (p *FileProvider) Properties() (externalpolicy.PropertyList, error) {
+	out, err := ioutil.ReadFile(p.path)
+	if err != nil {
+		return nil, fmt.Errorf("unable to read the properties file %v of provider %v, error: %v", p.path, p.name, err)
+	}
+	return parseProperties(p.name, out)
+}
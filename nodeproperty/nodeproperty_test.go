@@ -0,0 +1,121 @@
+// +build unit
+
+package nodeproperty
+
+import (
+	"errors"
+	"github.com/open-horizon/anax/config"
+	"github.com/open-horizon/anax/externalpolicy"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func Test_ExecProvider(t *testing.T) {
+
+	cfg := &config.NodePropertyProvider{Name: "site", Type: config.PROPERTY_PROVIDER_EXEC, Command: "/bin/sh",
+		Args: []string{"-c", `echo '[{"name":"siteId","value":"store-42"},{"name":"sensors","value":3}]'`}}
+
+	if p, err := NewProvider(cfg); err != nil {
+		t.Errorf("unexpected error creating the provider: %v", err)
+	} else if props, err := p.Properties(); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	} else if len(props) != 2 || props[0].Name != "siteId" || props[0].Value != "store-42" {
+		t.Errorf("unexpected properties %v", props)
+	}
+
+	cfg.Args = []string{"-c", "echo not json"}
+	if p, _ := NewProvider(cfg); p != nil {
+		if _, err := p.Properties(); err == nil {
+			t.Errorf("expected an error for output that is not a property list")
+		}
+	}
+
+	cfg.Args = []string{"-c", "exec sleep 5"}
+	cfg.TimeoutS = 1
+	if p, _ := NewProvider(cfg); p != nil {
+		if _, err := p.Properties(); err == nil {
+			t.Errorf("expected an error for a command that times out")
+		}
+	}
+}
+
+func Test_FileProvider(t *testing.T) {
+
+	dir, err := ioutil.TempDir("", "nodeproperty")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "props.json")
+	p, err := NewProvider(&config.NodePropertyProvider{Name: "file", Type: config.PROPERTY_PROVIDER_FILE, Path: path})
+	if err != nil {
+		t.Fatalf("unexpected error creating the provider: %v", err)
+	}
+
+	if _, err := p.Properties(); err == nil {
+		t.Errorf("expected an error for a missing file")
+	}
+
+	ioutil.WriteFile(path, []byte(`[{"name":"firmware","value":"1.2.3","type":"version"}]`), 0600)
+	if props, err := p.Properties(); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	} else if len(props) != 1 || props[0].Name != "firmware" || props[0].Type != externalpolicy.VERSION_TYPE {
+		t.Errorf("unexpected properties %v", props)
+	}
+}
+
+type testProvider struct {
+	name  string
+	props externalpolicy.PropertyList
+	err   error
+	calls int
+}
+
+func (p *testProvider) Name() string {
+	return p.name
+}
+
+func (p *testProvider) Properties() (externalpolicy.PropertyList, error) {
+	p.calls++
+	return p.props, p.err
+}
+
+func Test_Collector(t *testing.T) {
+
+	first := &testProvider{name: "first", props: externalpolicy.PropertyList{*externalpolicy.Property_Factory("sensors", 2)}}
+	second := &testProvider{name: "second", props: externalpolicy.PropertyList{*externalpolicy.Property_Factory("sensors", 5), *externalpolicy.Property_Factory("siteId", "s1")}}
+	RegisterProvider("test-first", func(cfg *config.NodePropertyProvider) (Provider, error) { return first, nil })
+	RegisterProvider("test-second", func(cfg *config.NodePropertyProvider) (Provider, error) { return second, nil })
+
+	c := NewCollector([]config.NodePropertyProvider{
+		{Name: "first", Type: "test-first", IntervalS: 10},
+		{Name: "second", Type: "test-second", IntervalS: 60},
+		{Name: "unknown", Type: "no-such-type"},
+	})
+	if len(c.providers) != 2 {
+		t.Fatalf("expected the unknown provider to be left out, got %v providers", len(c.providers))
+	} else if c.MinInterval() != 10 {
+		t.Errorf("expected the shortest interval, got %v", c.MinInterval())
+	}
+
+	now := time.Now()
+	props := c.Collect(now)
+	if len(props) != 2 {
+		t.Errorf("expected 2 properties, got %v", props)
+	} else if prop, _ := props.GetProperty("sensors"); prop.Value != float64(2) {
+		t.Errorf("expected the first provider to win with a normalized value, got %v", prop)
+	}
+
+	// only the first provider is due, and its error keeps the old properties
+	first.err = errors.New("sensor bus unavailable")
+	props = c.Collect(now.Add(15 * time.Second))
+	if first.calls != 2 || second.calls != 1 {
+		t.Errorf("expected only the first provider to be polled, calls %v %v", first.calls, second.calls)
+	} else if prop, _ := props.GetProperty("sensors"); prop.Value != float64(2) {
+		t.Errorf("expected the old properties to be kept on error, got %v", props)
+	}
+}
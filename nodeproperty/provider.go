@@ -0,0 +1,55 @@
+package nodeproperty
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/open-horizon/anax/config"
+	"github.com/open-horizon/anax/externalpolicy"
+	"sync"
+)
+
+// A node property provider returns properties that are added to the node policy as read-only properties.
+type Provider interface {
+	Name() string
+	Properties() (externalpolicy.PropertyList, error)
+}
+
+// Creates a provider from its configuration.
+type ProviderFactory func(cfg *config.NodePropertyProvider) (Provider, error)
+
+var factoryLock sync.Mutex
+var factories = map[string]ProviderFactory{
+	config.PROPERTY_PROVIDER_EXEC: NewExecProvider,
+	config.PROPERTY_PROVIDER_FILE: NewFileProvider,
+}
+
+// Register a provider type that is implemented in Go. The type can then be used in the NodePropertyProviders of the
+// anax configuration. Registering a type again replaces the factory.
+func RegisterProvider(providerType string, factory ProviderFactory) {
+	factoryLock.Lock()
+	defer factoryLock.Unlock()
+	factories[providerType] = factory
+}
+
+// Create the provider for a configured provider type.
+func NewProvider(cfg *config.NodePropertyProvider) (Provider, error) {
+	factoryLock.Lock()
+	factory, ok := factories[cfg.Type]
+	factoryLock.Unlock()
+
+	if !ok {
+		return nil, fmt.Errorf("unknown node property provider type %v for provider %v", cfg.Type, cfg.Name)
+	}
+	return factory(cfg)
+}
+
+// Parse the JSON output of a provider. The output is a list of properties in the node policy format.
+func parseProperties(providerName string, output []byte) (externalpolicy.PropertyList, error) {
+	props := externalpolicy.PropertyList{}
+	if err := json.Unmarshal(output, &props); err != nil {
+		return nil, fmt.Errorf("unable to parse the properties of provider %v, error: %v, output: %v", providerName, err, string(output))
+	} else if err := props.Validate(); err != nil {
+		return nil, fmt.Errorf("provider %v returned invalid properties, error: %v", providerName, err)
+	}
+	return props, nil
+}
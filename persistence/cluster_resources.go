@@ -0,0 +1,27 @@
+package persistence
+
+import (
+	"fmt"
+)
+
+// The resources that the workloads of a cluster service can use in the namespace that they are deployed into. They
+// become the ResourceQuota of the namespace, and the LimitRange that gives containers without resource limits their
+// defaults.
+type ClusterResources struct {
+	CPUMillicores int64 `json:"cpu_millicores,omitempty"` // The total CPU limit of the containers, in thousandths of a CPU.
+	MemoryMB      int64 `json:"memory_mb,omitempty"`      // The total memory limit of the containers.
+	Pods          int64 `json:"pods,omitempty"`           // The largest number of pods.
+}
+
+func (r ClusterResources) String() string {
+	return fmt.Sprintf("CPUMillicores: %v, MemoryMB: %v, Pods: %v", r.CPUMillicores, r.MemoryMB, r.Pods)
+}
+
+func (r *ClusterResources) Validate() error {
+	if r.CPUMillicores < 0 || r.MemoryMB < 0 || r.Pods < 0 {
+		return fmt.Errorf("resources %v cannot be negative", r)
+	} else if r.CPUMillicores == 0 && r.MemoryMB == 0 && r.Pods == 0 {
+		return fmt.Errorf("resources must have a cpu_millicores, memory_mb or pods limit")
+	}
+	return nil
+}
@@ -0,0 +1,49 @@
+package persistence
+
+import (
+	"fmt"
+)
+
+// A condition from the status of a custom resource, in the form that Kubernetes uses for the conditions of its own
+// objects.
+type ClusterCondition struct {
+	Type               string `json:"type"`
+	Status             string `json:"status"`
+	Reason             string `json:"reason,omitempty"`
+	Message            string `json:"message,omitempty"`
+	LastTransitionTime string `json:"last_transition_time,omitempty"`
+}
+
+func (c ClusterCondition) String() string {
+	return fmt.Sprintf("Type: %v, Status: %v, Reason: %v, Message: %v", c.Type, c.Status, c.Reason, c.Message)
+}
+
+// The status of the workload of a cluster agreement, taken from the status of the custom resource of the operator and
+// from the pods that run the operator.
+type ClusterWorkloadStatus struct {
+	Phase           string             `json:"phase,omitempty"`      // The phase or state that the custom resource reports.
+	Conditions      []ClusterCondition `json:"conditions,omitempty"` // The conditions that the custom resource reports.
+	ReadyReplicas   int64              `json:"ready_replicas"`
+	DesiredReplicas int64              `json:"desired_replicas"`
+	LastError       string             `json:"last_error,omitempty"` // The most recent error of the custom resource or of the pods.
+	Failed          bool               `json:"failed"`               // True when the workload has failed and the agreement should end.
+	UpdateTime      uint64             `json:"update_time"`          // The time that the status was taken from the cluster.
+}
+
+func (s ClusterWorkloadStatus) String() string {
+	return fmt.Sprintf("Phase: %v, Conditions: %v, ReadyReplicas: %v, DesiredReplicas: %v, LastError: %v, Failed: %v, UpdateTime: %v",
+		s.Phase, s.Conditions, s.ReadyReplicas, s.DesiredReplicas, s.LastError, s.Failed, s.UpdateTime)
+}
+
+// Returns true when the workload has not failed and all of its replicas are ready.
+func (s *ClusterWorkloadStatus) IsReady() bool {
+	if s.Failed || s.ReadyReplicas < s.DesiredReplicas {
+		return false
+	}
+	for _, cond := range s.Conditions {
+		if cond.Type == "Ready" && cond.Status == "False" {
+			return false
+		}
+	}
+	return true
+}
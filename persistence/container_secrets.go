@@ -0,0 +1,94 @@
+package persistence
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/boltdb/bolt"
+)
+
+// container secrets table name
+const CONTAINER_SECRETS = "container_secrets"
+
+// The secrets of the containers of an agreement or a service instance. The secret files are written on a tmpfs file
+// system, so they are written again from this record when the agent starts after the node was rebooted.
+type ContainerSecrets struct {
+	ServiceURL string            `json:"service_url"`
+	Version    string            `json:"version"`
+	Secrets    map[string]string `json:"secrets"` // The secrets that are given as they are, encrypted in the local db.
+	Refs       map[string]string `json:"refs"`    // The secrets that are resolved from a secret provider.
+}
+
+func (c ContainerSecrets) String() string {
+	return fmt.Sprintf("ServiceURL: %v, Version: %v, Secrets: %v, Refs: %v", c.ServiceURL, c.Version, len(c.Secrets), c.Refs)
+}
+
+// FindContainerSecrets returns the secrets of the containers of every agreement and service instance, with the values
+// of the secrets decrypted.
+func FindContainerSecrets(db *bolt.DB) (map[string]ContainerSecrets, error) {
+	all := make(map[string]ContainerSecrets)
+
+	readErr := db.View(func(tx *bolt.Tx) error {
+		if b := tx.Bucket([]byte(CONTAINER_SECRETS)); b != nil {
+			return b.ForEach(func(k, v []byte) error {
+
+				var cs ContainerSecrets
+				if err := json.Unmarshal(v, &cs); err != nil {
+					return fmt.Errorf("Unable to deserialize container secrets record %v: %v", string(k), err)
+				}
+				all[string(k)] = cs
+				return nil
+			})
+		}
+
+		return nil // end transaction
+	})
+
+	if readErr != nil {
+		return nil, readErr
+	}
+
+	for key, cs := range all {
+		if secrets, err := decryptSecretMap(cs.Secrets); err != nil {
+			return nil, fmt.Errorf("Unable to decrypt the container secrets of %v: %v", key, err)
+		} else {
+			cs.Secrets = secrets
+			all[key] = cs
+		}
+	}
+	return all, nil
+}
+
+// SaveContainerSecrets saves the secrets of the containers of an agreement or a service instance to the local db. The
+// values of the secrets are encrypted.
+func SaveContainerSecrets(db *bolt.DB, key string, cs ContainerSecrets) error {
+	encrypted, err := encryptSecretMap(cs.Secrets)
+	if err != nil {
+		return err
+	}
+	cs.Secrets = encrypted
+
+	return db.Update(func(tx *bolt.Tx) error {
+		b, err := tx.CreateBucketIfNotExists([]byte(CONTAINER_SECRETS))
+		if err != nil {
+			return err
+		}
+
+		if serial, err := json.Marshal(cs); err != nil {
+			return fmt.Errorf("Failed to serialize container secrets of %v. Error: %v", key, err)
+		} else {
+			return b.Put([]byte(key), serial)
+		}
+	})
+}
+
+// DeleteContainerSecrets removes the secrets of the containers of an agreement or a service instance from the local db
+func DeleteContainerSecrets(db *bolt.DB, key string) error {
+	return db.Update(func(tx *bolt.Tx) error {
+		if b := tx.Bucket([]byte(CONTAINER_SECRETS)); b == nil {
+			return nil
+		} else if err := b.Delete([]byte(key)); err != nil {
+			return fmt.Errorf("Unable to delete container secrets of %v: %v", key, err)
+		}
+		return nil
+	})
+}
@@ -17,6 +17,15 @@ type ContainerVolume struct {
 	Name         string `json:"name"`
 	CreationTime uint64 `json:"creation_time"`
 	ArchiveTime  uint64 `json:"archive_time"`
+
+	// The fields below are only set for the volumes that are declared in the deployment string of a service.
+	Retention      string `json:"retention,omitempty"`       // delete, keep or keep-<N>-days
+	Service        string `json:"service,omitempty"`         // The org/url of the service that last used the volume.
+	ServiceVersion string `json:"service_version,omitempty"` // The version of the service that last used the volume.
+	Image          string `json:"image,omitempty"`           // An image of the service, which the agent uses to read and write the volume.
+	InstanceKey    string `json:"instance_key,omitempty"`    // The agreement or service instance that last used the volume.
+	ReleaseTime    uint64 `json:"release_time,omitempty"`    // When the containers that used the volume were removed, 0 while they run.
+	RestoreBackup  string `json:"restore_backup,omitempty"`  // The record id of a backup to restore before the volume is used again.
 }
 
 func NewContainerVolume(name string) *ContainerVolume {
@@ -31,8 +40,20 @@ func (w ContainerVolume) String() string {
 	return fmt.Sprintf("RecordId: %v, "+
 		"Name: %v, "+
 		"CreationTime: %v, "+
-		"ArchiveTime: %v",
-		w.RecordId, w.Name, w.CreationTime, w.ArchiveTime)
+		"ArchiveTime: %v, "+
+		"Retention: %v, "+
+		"Service: %v, "+
+		"ServiceVersion: %v, "+
+		"Image: %v, "+
+		"InstanceKey: %v, "+
+		"ReleaseTime: %v, "+
+		"RestoreBackup: %v",
+		w.RecordId, w.Name, w.CreationTime, w.ArchiveTime, w.Retention, w.Service, w.ServiceVersion, w.Image, w.InstanceKey, w.ReleaseTime, w.RestoreBackup)
+}
+
+// Returns true when the volume is declared in the deployment string of a service, so the agent manages its lifecycle.
+func (w ContainerVolume) IsDeclared() bool {
+	return w.Retention != ""
 }
 
 func (w ContainerVolume) ShortString() string {
@@ -103,6 +124,32 @@ func NameCVFilter(name string) ContainerVolumeFilter {
 	return func(c ContainerVolume) bool { return c.Name == name }
 }
 
+// filter on the volumes that are declared in the deployment string of a service
+func DeclaredCVFilter() ContainerVolumeFilter {
+	return func(c ContainerVolume) bool { return c.IsDeclared() }
+}
+
+// filter on the org/url of the service that last used the volume
+func ServiceCVFilter(service string) ContainerVolumeFilter {
+	return func(c ContainerVolume) bool { return c.Service == service }
+}
+
+// filter on the agreement or service instance that last used the volume
+func InstanceKeyCVFilter(key string) ContainerVolumeFilter {
+	return func(c ContainerVolume) bool { return c.InstanceKey == key }
+}
+
+// Find the volume with the given name that is not deleted yet. It returns nil if there is none.
+func FindUndeletedContainerVolume(db *bolt.DB, name string) (*ContainerVolume, error) {
+	if cvs, err := FindContainerVolumes(db, []ContainerVolumeFilter{UnarchivedCVFilter(), NameCVFilter(name)}); err != nil {
+		return nil, err
+	} else if len(cvs) == 0 {
+		return nil, nil
+	} else {
+		return &cvs[len(cvs)-1], nil
+	}
+}
+
 // find container volumes from the db for the given filters
 func FindContainerVolumes(db *bolt.DB, filters []ContainerVolumeFilter) ([]ContainerVolume, error) {
 	cvs := make([]ContainerVolume, 0)
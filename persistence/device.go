@@ -59,6 +59,7 @@ type ExchangeDevice struct {
 	TokenValid         bool        `json:"token_valid"`
 	HA                 bool        `json:"ha"`
 	Config             Configstate `json:"configstate"`
+	PendingToken       string      `json:"pending_token,omitempty"` // A new token that is being rotated in, but might not yet be set in the exchange.
 }
 
 func (e ExchangeDevice) String() string {
@@ -69,7 +70,14 @@ func (e ExchangeDevice) String() string {
 		tokenShadow = "unset"
 	}
 
-	return fmt.Sprintf("Org: %v, Token: <%s>, Name: %v, NodeType: %v, TokenLastValidTime: %v, TokenValid: %v, Pattern: %v, %v", e.Org, tokenShadow, e.Name, e.NodeType, e.TokenLastValidTime, e.TokenValid, e.Pattern, e.Config)
+	var pendingShadow string
+	if e.PendingToken != "" {
+		pendingShadow = "set"
+	} else {
+		pendingShadow = "unset"
+	}
+
+	return fmt.Sprintf("Org: %v, Token: <%s>, PendingToken: <%s>, Name: %v, NodeType: %v, TokenLastValidTime: %v, TokenValid: %v, Pattern: %v, %v", e.Org, tokenShadow, pendingShadow, e.Name, e.NodeType, e.TokenLastValidTime, e.TokenValid, e.Pattern, e.Config)
 }
 
 func (e ExchangeDevice) GetId() string {
@@ -142,6 +150,35 @@ func (e *ExchangeDevice) SetExchangeDeviceToken(db *bolt.DB, deviceId string, to
 	})
 }
 
+// Record the token that is about to replace the current token. The pending token is saved before the exchange is
+// updated, so that the agent can find out which of the 2 tokens is valid if it stops in the middle of a rotation.
+// Setting an empty pending token abandons the rotation.
+func (e *ExchangeDevice) SetPendingToken(db *bolt.DB, deviceId string, token string) (*ExchangeDevice, error) {
+	if deviceId == "" {
+		return nil, errors.New("Argument null and mustn't be")
+	}
+
+	return updateExchangeDevice(db, e, deviceId, false, func(d ExchangeDevice) *ExchangeDevice {
+		d.PendingToken = token
+		return &d
+	})
+}
+
+// Make the pending token the current token, once the exchange has accepted it.
+func (e *ExchangeDevice) CommitPendingToken(db *bolt.DB, deviceId string) (*ExchangeDevice, error) {
+	if deviceId == "" {
+		return nil, errors.New("Argument null and mustn't be")
+	} else if e.PendingToken == "" {
+		return nil, errors.New("There is no pending token to commit")
+	}
+
+	return updateExchangeDevice(db, e, deviceId, false, func(d ExchangeDevice) *ExchangeDevice {
+		d.Token = d.PendingToken
+		d.PendingToken = ""
+		return &d
+	})
+}
+
 func (e *ExchangeDevice) SetConfigstate(db *bolt.DB, deviceId string, state string) (*ExchangeDevice, error) {
 	if deviceId == "" || state == "" {
 		return nil, errors.New("Argument null and mustn't be")
@@ -209,12 +246,14 @@ func updateExchangeDevice(db *bolt.DB, self *ExchangeDevice, deviceId string, in
 				return fmt.Errorf("No device with given device id to update: %v", deviceId)
 			}
 
-			// Differentiate token invalidation from updating a token.
+			// Differentiate token invalidation from updating a token. The token is only updated when the caller
+			// changed it, so that a stale device object cannot write back the token from before a rotation.
 			if invalidateToken {
 				mod.Token = ""
 				mod.TokenValid = false
+				mod.PendingToken = ""
 
-			} else if update.Token != mod.Token && update.Token != "" {
+			} else if update.Token != self.Token && update.Token != mod.Token && update.Token != "" {
 				mod.Token = update.Token
 				mod.TokenValid = true
 				mod.TokenLastValidTime = uint64(time.Now().Unix())
@@ -236,6 +275,12 @@ func updateExchangeDevice(db *bolt.DB, self *ExchangeDevice, deviceId string, in
 				mod.Pattern = update.Pattern
 			}
 
+			// Update the token that is being rotated in, only when the caller changed it so that a stale
+			// device object cannot abandon a rotation that is in progress.
+			if update.PendingToken != self.PendingToken {
+				mod.PendingToken = update.PendingToken
+			}
+
 			// note: DEVICES is used as the key b/c we only want to store one value in this bucket
 
 			if serialized, err := json.Marshal(mod); err != nil {
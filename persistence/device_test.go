@@ -36,3 +36,45 @@ func Test_GetFormatedPatternString(t *testing.T) {
 	assert.Equal(t, "pattern1", name, "No org string found")
 	assert.Equal(t, "pattern1", pattern, "No org string found")
 }
+
+// Verify that a pending token survives updates made with a stale device object and becomes the token when committed.
+func Test_PendingToken(t *testing.T) {
+
+	dir, db, err := utsetup()
+	if err != nil {
+		t.Error(err)
+	}
+	defer cleanTestDir(dir)
+
+	dev, err := SaveNewExchangeDevice(db, "node1", "token1", "node1", DEVICE_TYPE_DEVICE, false, "org1", "", CONFIGSTATE_CONFIGURED)
+	assert.Nil(t, err, "Saving the device should not fail.")
+
+	_, err = dev.SetPendingToken(db, "node1", "token2")
+	assert.Nil(t, err, "Setting the pending token should not fail.")
+
+	// dev does not know about the pending token
+	_, err = dev.SetConfigstate(db, "node1", CONFIGSTATE_CONFIGURED)
+	assert.Nil(t, err, "Setting the config state should not fail.")
+
+	fdev, err := FindExchangeDevice(db)
+	assert.Nil(t, err, "Finding the device should not fail.")
+	assert.Equal(t, "token1", fdev.Token, "The token should not change until the pending token is committed.")
+	assert.Equal(t, "token2", fdev.PendingToken, "The pending token should not be removed by a stale device object.")
+
+	_, err = dev.CommitPendingToken(db, "node1")
+	assert.NotNil(t, err, "A device object without a pending token cannot commit it.")
+
+	cdev, err := fdev.CommitPendingToken(db, "node1")
+	assert.Nil(t, err, "Committing the pending token should not fail.")
+	assert.Equal(t, "token2", cdev.Token, "The pending token should be the token.")
+	assert.Equal(t, "", cdev.PendingToken, "The pending token should be removed.")
+	assert.True(t, cdev.TokenValid, "The token should be valid.")
+
+	// dev still has the token from before the rotation
+	_, err = dev.SetConfigstate(db, "node1", CONFIGSTATE_CONFIGURED)
+	assert.Nil(t, err, "Setting the config state should not fail.")
+
+	fdev, err = FindExchangeDevice(db)
+	assert.Nil(t, err, "Finding the device should not fail.")
+	assert.Equal(t, "token2", fdev.Token, "The rotated token should not be replaced by a stale device object.")
+}
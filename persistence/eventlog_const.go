@@ -62,6 +62,10 @@ const (
 	EC_NODE_HEARTBEAT_FAILED   = "node_heartbeat_failed"
 	EC_NODE_HEARTBEAT_RESTORED = "node_heartbeat_restored"
 
+	// node token rotation
+	EC_NODE_TOKEN_ROTATED        = "node_token_rotated"
+	EC_ERROR_NODE_TOKEN_ROTATION = "error_node_token_rotation"
+
 	// service configuration
 	EC_START_SERVICE_CONFIG                = "start_service_configuration"
 	EC_SERVICE_CONFIG_COMPLETE             = "service_configuration_complete"
@@ -80,6 +84,7 @@ const (
 	EC_REJECT_PROPOSAL           = "reject_proposal"
 	EC_ERROR_IN_PROPOSAL         = "error_in_proposal"
 	EC_ERROR_PROCESSING_PROPOSAL = "error_processing_proposal"
+	EC_ERROR_ADMISSION_POLICY    = "error_admission_policy"
 
 	EC_RECEIVED_REPLYACK_MESSAGE         = "received_replyack_message"
 	EC_IGNORE_REPLYACK_MESSAGE           = "ignore_replyack_message"
@@ -121,6 +126,17 @@ const (
 	EC_CONTAINER_STOPPED          = "container_stopped"
 	EC_ERROR_IN_DEPLOYMENT_CONFIG = "error_in_deployment_configuration"
 	EC_ERROR_START_CONTAINER      = "error_start_container"
+	EC_SECRET_READ                = "secret_read"
+	EC_SECRET_ROTATED             = "secret_rotated"
+	EC_ERROR_SECRET_READ          = "error_secret_read"
+	EC_MMS_OBJECT_DELIVERED       = "mms_object_delivered"
+	EC_MMS_OBJECT_REMOVED         = "mms_object_removed"
+	EC_ERROR_MMS_OBJECT_DELIVERY  = "error_mms_object_delivery"
+	EC_VOLUME_BACKED_UP           = "volume_backed_up"
+	EC_ERROR_VOLUME_BACKUP        = "error_volume_backup"
+	EC_VOLUME_RESTORED            = "volume_restored"
+	EC_ERROR_VOLUME_RESTORE       = "error_volume_restore"
+	EC_VOLUME_REMOVED             = "volume_removed"
 
 	EC_IMAGE_LOADED                       = "image_loaded"
 	EC_ERROR_IMAGE_LOADE                  = "error_image_load"
@@ -135,6 +151,7 @@ const (
 	EC_START_DEPENDENT_SERVICE             = "start_dependent_service"
 	EC_ERROR_START_DEPENDENT_SERVICE       = "error_start_dependent_service"
 	EC_DEPENDENT_SERVICE_FAILED            = "dependent_service_failed"
+	EC_DEPENDENT_SERVICE_NOT_READY         = "dependent_service_not_ready"
 	EC_COMPLETE_DEPENDENT_SERVICE          = "complete_dependent_service"
 	EC_REMOVE_OLD_DEPENDENT_SERVICE_FAILED = "remove_old_dependent_service_failed"
 
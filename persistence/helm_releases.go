@@ -0,0 +1,65 @@
+package persistence
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/boltdb/bolt"
+)
+
+// pending helm release table name
+const PENDING_HELM_RELEASES = "pending_helm_releases"
+
+// FindPendingHelmReleases returns the Helm releases that are kept running for the agreement that replaces theirs, and
+// the time, in seconds since the epoch, when each of them stops waiting.
+func FindPendingHelmReleases(db *bolt.DB) (map[string]int64, error) {
+	pending := make(map[string]int64)
+
+	readErr := db.View(func(tx *bolt.Tx) error {
+		if b := tx.Bucket([]byte(PENDING_HELM_RELEASES)); b != nil {
+			return b.ForEach(func(k, v []byte) error {
+
+				var expires int64
+				if err := json.Unmarshal(v, &expires); err != nil {
+					return fmt.Errorf("Unable to deserialize pending helm release record %v: %v", string(k), v)
+				}
+				pending[string(k)] = expires
+				return nil
+			})
+		}
+
+		return nil // end transaction
+	})
+
+	if readErr != nil {
+		return nil, readErr
+	}
+	return pending, nil
+}
+
+// SavePendingHelmRelease saves a Helm release that is kept running, and when it stops waiting, to the local db
+func SavePendingHelmRelease(db *bolt.DB, releaseName string, expires int64) error {
+	return db.Update(func(tx *bolt.Tx) error {
+		b, err := tx.CreateBucketIfNotExists([]byte(PENDING_HELM_RELEASES))
+		if err != nil {
+			return err
+		}
+
+		if serial, err := json.Marshal(expires); err != nil {
+			return fmt.Errorf("Failed to serialize pending helm release %v: %v. Error: %v", releaseName, expires, err)
+		} else {
+			return b.Put([]byte(releaseName), serial)
+		}
+	})
+}
+
+// DeletePendingHelmRelease removes a Helm release that no longer waits from the local db
+func DeletePendingHelmRelease(db *bolt.DB, releaseName string) error {
+	return db.Update(func(tx *bolt.Tx) error {
+		if b := tx.Bucket([]byte(PENDING_HELM_RELEASES)); b == nil {
+			return nil
+		} else if err := b.Delete([]byte(releaseName)); err != nil {
+			return fmt.Errorf("Unable to delete pending helm release %v: %v", releaseName, err)
+		}
+		return nil
+	})
+}
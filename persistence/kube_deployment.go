@@ -6,12 +6,14 @@ import (
 )
 
 type KubeDeploymentConfig struct {
-	OperatorYamlArchive string `json:"operatorYamlArchive"`
+	OperatorYamlArchive string            `json:"operatorYamlArchive"`
+	Namespace           string            `json:"namespace,omitempty"` // The namespace that the operator was installed into. It is set by the agent.
+	Resources           *ClusterResources `json:"resources,omitempty"`
 }
 
 func (k *KubeDeploymentConfig) ToString() string {
 	if k != nil {
-		return fmt.Sprintf("OperatorYamlArchive: %v", k.OperatorYamlArchive)
+		return fmt.Sprintf("OperatorYamlArchive: %v, Namespace: %v, Resources: %v", k.OperatorYamlArchive, k.Namespace, k.Resources)
 	}
 	return ""
 }
@@ -23,6 +25,10 @@ func GetKubeDeployment(deployStr string) (*KubeDeploymentConfig, error) {
 		return nil, fmt.Errorf("error unmarshaling deployment config as KubeDeployment: %v", err)
 	} else if kd.OperatorYamlArchive == "" {
 		return nil, fmt.Errorf("required field 'operatorYamlArchive' is missing in the deployment string.")
+	} else if kd.Resources != nil {
+		if err := kd.Resources.Validate(); err != nil {
+			return nil, fmt.Errorf("kube deployment config is not valid: %v", err)
+		}
 	}
 	return kd, nil
 }
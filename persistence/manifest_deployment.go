@@ -0,0 +1,99 @@
+package persistence
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+)
+
+// The structure of the json string in the clusterDeployment field of a service definition when the service is a set of
+// plain Kubernetes manifests. The manifests are applied into a namespace of their own for each agreement. When the
+// archive has a kustomization file at its top, the manifests are built with kustomize before they are applied.
+
+type ManifestDeploymentConfig struct {
+	ManifestArchive string            `json:"manifestArchive"`     // base64 encoded, tarred and gzipped yaml files
+	Namespace       string            `json:"namespace,omitempty"` // The namespace that the manifests were applied into. It is set by the agent.
+	Resources       *ClusterResources `json:"resources,omitempty"`
+}
+
+func (m ManifestDeploymentConfig) String() string {
+	maxArchiveLength := 25
+	if len(m.ManifestArchive) < maxArchiveLength {
+		maxArchiveLength = len(m.ManifestArchive)
+	}
+	return fmt.Sprintf("ManifestArchive: %v, Namespace: %v, Resources: %v", m.ManifestArchive[:maxArchiveLength], m.Namespace, m.Resources)
+}
+
+func IsManifest(dep map[string]interface{}) bool {
+	if _, ok := dep["manifestArchive"]; ok {
+		return true
+	}
+	return false
+}
+
+// Functions that allow ManifestDeploymentConfig to support the DeploymentConfig interface.
+
+func (m *ManifestDeploymentConfig) IsNative() bool {
+	return false
+}
+
+func (m *ManifestDeploymentConfig) ToPersistentForm() (map[string]interface{}, error) {
+	ret := make(map[string]interface{})
+
+	// Marshal to JSON form so that we can unmarshal as a map[string]interface{}.
+	if jBytes, err := json.Marshal(m); err != nil {
+		return ret, errors.New(fmt.Sprintf("error marshalling manifest deployment: %v, error: %v", m, err))
+	} else if err := json.Unmarshal(jBytes, &ret); err != nil {
+		return ret, errors.New(fmt.Sprintf("error unmarshalling manifest deployment: %v, error: %v", string(jBytes), err))
+	}
+
+	return ret, nil
+}
+
+func (m *ManifestDeploymentConfig) FromPersistentForm(pf map[string]interface{}) error {
+
+	// Marshal to JSON form so that we can unmarshal as a ManifestDeploymentConfig.
+	if jBytes, err := json.Marshal(pf); err != nil {
+		return errors.New(fmt.Sprintf("error marshalling manifest persistent deployment: %v, error: %v", m, err))
+	} else if err := json.Unmarshal(jBytes, m); err != nil {
+		return errors.New(fmt.Sprintf("error unmarshalling manifest persistent deployment: %v, error: %v", string(jBytes), err))
+	}
+
+	return nil
+}
+
+func (m *ManifestDeploymentConfig) ToString() string {
+	if m != nil {
+		return m.String()
+	} else {
+		return ""
+	}
+}
+
+// Given a deployment string, unmarshal it as a ManifestDeployment object. It might not be a ManifestDeployment, so we
+// have to verify what was just unmarshalled.
+func GetManifestDeployment(depStr string) (*ManifestDeploymentConfig, error) {
+
+	pf := make(map[string]interface{})
+	if err := json.Unmarshal([]byte(depStr), &pf); err != nil {
+		return nil, errors.New(fmt.Sprintf("error unmarshalling deployment config as ManifestDeployment: %v", err))
+	} else if !IsManifest(pf) {
+		return nil, errors.New(fmt.Sprintf("deployment config is not a ManifestDeployment"))
+	}
+
+	md := new(ManifestDeploymentConfig)
+	if err := json.Unmarshal([]byte(depStr), md); err != nil {
+		return nil, errors.New(fmt.Sprintf("error unmarshalling deployment config as ManifestDeployment: %v", err))
+	} else if md.ManifestArchive == "" {
+		return nil, errors.New(fmt.Sprintf("required field 'manifestArchive' is missing in the deployment string."))
+	} else if _, err := base64.StdEncoding.DecodeString(md.ManifestArchive); err != nil {
+		return nil, errors.New(fmt.Sprintf("manifestArchive is not base64 encoded: %v", err))
+	} else if md.Resources != nil {
+		if err := md.Resources.Validate(); err != nil {
+			return nil, errors.New(fmt.Sprintf("manifest deployment config is not valid: %v", err))
+		}
+	}
+
+	return md, nil
+}
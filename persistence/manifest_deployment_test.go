@@ -0,0 +1,59 @@
+// +build unit
+
+package persistence
+
+import (
+	"testing"
+)
+
+func Test_DecodeManifestDeployment(t *testing.T) {
+
+	depStr := `{"manifestArchive":"H4sIAAAAAAAAA+3BAQ0AAADCoPdPbQ8HFAAAAAAAAAAAAAAAAAAAAADwbxkGcgAoAAA="}`
+
+	md, err := GetManifestDeployment(depStr)
+	if err != nil {
+		t.Errorf("Error extracting manifest deployment %v, error: %v", depStr, err)
+	}
+
+	// round trip through the persistent form
+	if pf, err := md.ToPersistentForm(); err != nil {
+		t.Errorf("Error converting %v to persistent form, error: %v", md, err)
+	} else if !IsManifest(pf) {
+		t.Errorf("Persistent form %v is not recognized as a manifest deployment", pf)
+	} else if IsHelm(pf) || IsKube(pf) || IsProcess(pf) || IsWasm(pf) {
+		t.Errorf("Persistent form %v is recognized as another deployment type", pf)
+	} else {
+		newMD := new(ManifestDeploymentConfig)
+		if err := newMD.FromPersistentForm(pf); err != nil {
+			t.Errorf("Error converting %v from persistent form, error: %v", pf, err)
+		} else if newMD.ManifestArchive != md.ManifestArchive {
+			t.Errorf("Converted manifest deployment %v does not match original %v", newMD, md)
+		}
+	}
+
+	// a kube deployment is not a manifest deployment
+	if _, err := GetManifestDeployment(`{"operatorYamlArchive":"abc"}`); err == nil {
+		t.Errorf("Expected an operator deployment not to be a manifest deployment")
+	} else if _, err := GetManifestDeployment(`{"manifestArchive":""}`); err == nil {
+		t.Errorf("Expected an error for an empty manifest archive")
+	} else if _, err := GetManifestDeployment(`{"manifestArchive":"not base64!"}`); err == nil {
+		t.Errorf("Expected an error for a manifest archive that is not base64 encoded")
+	}
+}
+
+func Test_ManifestDeploymentResources(t *testing.T) {
+
+	depStr := `{"manifestArchive":"H4sIAAAAAAAAA+3BAQ0AAADCoPdPbQ8HFAAAAAAAAAAAAAAAAAAAAADwbxkGcgAoAAA=","resources":{"cpu_millicores":500,"memory_mb":256}}`
+
+	if md, err := GetManifestDeployment(depStr); err != nil {
+		t.Errorf("Error extracting manifest deployment %v, error: %v", depStr, err)
+	} else if md.Resources == nil || md.Resources.CPUMillicores != 500 || md.Resources.MemoryMB != 256 || md.Resources.Pods != 0 {
+		t.Errorf("Unexpected resources %v", md.Resources)
+	}
+
+	if _, err := GetManifestDeployment(`{"manifestArchive":"YWJj","resources":{"pods":-1}}`); err == nil {
+		t.Errorf("Expected an error for negative resources")
+	} else if _, err := GetManifestDeployment(`{"manifestArchive":"YWJj","resources":{}}`); err == nil {
+		t.Errorf("Expected an error for resources without a limit")
+	}
+}
@@ -7,6 +7,7 @@ import (
 	"github.com/boltdb/bolt"
 	"github.com/golang/glog"
 	"github.com/open-horizon/anax/cutil"
+	"github.com/open-horizon/anax/policy"
 	"github.com/satori/go.uuid"
 	"strconv"
 	"time"
@@ -21,17 +22,34 @@ type UserInput struct {
 	Label        string `json:"label"`
 	Type         string `json:"type"`
 	DefaultValue string `json:"defaultValue"`
+	policy.InputConstraints
 }
 
-func NewUserInput(name string, label string, stype string, default_value string) *UserInput {
+func NewUserInput(name string, label string, stype string, default_value string, constraints policy.InputConstraints) *UserInput {
 	return &UserInput{
-		Name:         name,
-		Label:        label,
-		Type:         stype,
-		DefaultValue: default_value,
+		Name:             name,
+		Label:            label,
+		Type:             stype,
+		DefaultValue:     default_value,
+		InputConstraints: constraints,
 	}
 }
 
+// Returns true when the variable holds a secret.
+func (ui UserInput) IsSecret() bool {
+	return ui.Type == policy.USERINPUT_TYPE_SECRET
+}
+
+// Returns true when the value of the variable must not be shown. Secrets are always sensitive.
+func (ui UserInput) IsSensitive() bool {
+	return ui.Sensitive || ui.IsSecret()
+}
+
+// Verify that a value of the variable has the declared type and satisfies the declared constraints.
+func (ui UserInput) VerifyValue(value interface{}) error {
+	return policy.VerifyInputValue(value, ui.Type, ui.InputConstraints)
+}
+
 type WorkloadDeployment struct {
 	Deployment          string `json:"deployment"`
 	DeploymentSignature string `json:"deployment_signature"`
@@ -538,6 +556,7 @@ type MicroserviceInstance struct {
 	CurrentRetryCount    uint                           `json:"current_retry_count"`
 	RetryStartTime       uint64                         `json:"retry_start_time"`
 	EnvVars              map[string]string              `json:"env_vars"`
+	Secrets              map[string]string              `json:"secrets,omitempty"` // The secret user input, encrypted. Use GetSecrets to read them.
 }
 
 func (w MicroserviceInstance) String() string {
@@ -874,13 +893,24 @@ func ArchiveMicroserviceInstance(db *bolt.DB, key string) (*MicroserviceInstance
 	})
 }
 
-func UpdateMSInstanceEnvVars(db *bolt.DB, key string, env_vars map[string]string) (*MicroserviceInstance, error) {
+// Save the environment variables and the secrets of a service instance. The secrets are encrypted.
+func UpdateMSInstanceEnvVars(db *bolt.DB, key string, env_vars map[string]string, secrets map[string]string) (*MicroserviceInstance, error) {
+	encrypted, err := encryptSecretMap(secrets)
+	if err != nil {
+		return nil, err
+	}
 	return microserviceInstanceStateUpdate(db, key, func(c MicroserviceInstance) *MicroserviceInstance {
 		c.EnvVars = env_vars
+		c.Secrets = encrypted
 		return &c
 	})
 }
 
+// Returns the decrypted secrets of the service instance.
+func (m MicroserviceInstance) GetSecrets() (map[string]string, error) {
+	return decryptSecretMap(m.Secrets)
+}
+
 func MicroserviceInstanceCleanupStarted(db *bolt.DB, key string) (*MicroserviceInstance, error) {
 	return microserviceInstanceStateUpdate(db, key, func(c MicroserviceInstance) *MicroserviceInstance {
 		c.CleanupStartTime = uint64(time.Now().Unix())
@@ -1031,6 +1061,7 @@ func persistUpdatedMicroserviceInstance(db *bolt.DB, key string, update *Microse
 				mod.MaxRetryDuration = update.MaxRetryDuration
 				mod.CurrentRetryCount = update.CurrentRetryCount
 				mod.EnvVars = update.EnvVars
+				mod.Secrets = update.Secrets
 
 				if len(mod.ParentPath) != len(update.ParentPath) {
 					mod.ParentPath = update.ParentPath
@@ -0,0 +1,229 @@
+package persistence
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/boltdb/bolt"
+	"github.com/open-horizon/anax/externalpolicy"
+	"github.com/open-horizon/anax/policy"
+	"sort"
+	"strconv"
+	"time"
+)
+
+// The buckets that hold the previous versions of the node user input and of the node policy.
+const NODE_USERINPUT_HISTORY = "nodeuserinputhistory"
+const NODE_POLICY_HISTORY = "nodepolicyhistory"
+
+// The number of versions that are kept for each of the node user input and the node policy. The oldest
+// versions are removed when a new version is added.
+const MAX_NODE_CONFIG_VERSIONS = 20
+
+// A version of the node user input or of the node policy. Only one of UserInput and Policy is set, depending on the
+// history that the version belongs to. A version that records the removal of the node policy has Deleted set.
+type NodeConfigVersion struct {
+	Version      uint64                         `json:"version"`
+	UserInput    []policy.UserInput             `json:"userInput,omitempty"`
+	Policy       *externalpolicy.ExternalPolicy `json:"policy,omitempty"`
+	Deleted      bool                           `json:"deleted,omitempty"`
+	RolledBackTo uint64                         `json:"rolledBackTo,omitempty"` // The version that this version restored, 0 when it is not a rollback.
+	Automatic    bool                           `json:"automatic,omitempty"`    // True when the agent did the rollback because services failed to start.
+	Time         uint64                         `json:"time"`
+}
+
+func (v NodeConfigVersion) String() string {
+	return fmt.Sprintf("Version: %v, UserInput: %v, Policy: %v, Deleted: %v, RolledBackTo: %v, Automatic: %v, Time: %v",
+		v.Version, v.UserInput, v.Policy, v.Deleted, v.RolledBackTo, v.Automatic, v.Time)
+}
+
+// Returns true when the two versions hold the same content.
+func (v NodeConfigVersion) SameContent(other NodeConfigVersion) bool {
+	if v.Deleted || other.Deleted {
+		return v.Deleted == other.Deleted
+	}
+	b1, err1 := json.Marshal(NodeConfigVersion{UserInput: v.UserInput, Policy: v.Policy})
+	b2, err2 := json.Marshal(NodeConfigVersion{UserInput: other.UserInput, Policy: other.Policy})
+	return err1 == nil && err2 == nil && string(b1) == string(b2)
+}
+
+// Add a version of the node user input to the history. Deleting the node user input saves an empty user input, so
+// it is recorded as an empty version. Nothing is added when the latest version has the same content. It returns the
+// number of the latest version.
+func AddNodeUserInputVersion(db *bolt.DB, userInput []policy.UserInput) (uint64, error) {
+	if userInput == nil {
+		userInput = []policy.UserInput{}
+	}
+	return addNodeConfigVersion(db, NODE_USERINPUT_HISTORY, NodeConfigVersion{UserInput: userInput})
+}
+
+// Add a version of the node policy to the history. A nil policy records that the node policy was deleted. Nothing is
+// added when the latest version has the same content. It returns the number of the latest version.
+func AddNodePolicyVersion(db *bolt.DB, nodePolicy *externalpolicy.ExternalPolicy) (uint64, error) {
+	return addNodeConfigVersion(db, NODE_POLICY_HISTORY, NodeConfigVersion{Policy: nodePolicy, Deleted: nodePolicy == nil})
+}
+
+// Returns the versions of the node user input, the oldest first.
+func FindNodeUserInputHistory(db *bolt.DB) ([]NodeConfigVersion, error) {
+	return findNodeConfigHistory(db, NODE_USERINPUT_HISTORY)
+}
+
+// Returns the versions of the node policy, the oldest first.
+func FindNodePolicyHistory(db *bolt.DB) ([]NodeConfigVersion, error) {
+	return findNodeConfigHistory(db, NODE_POLICY_HISTORY)
+}
+
+// Record that the latest version of the node user input restored the given version.
+func MarkNodeUserInputRollback(db *bolt.DB, rolledBackTo uint64, automatic bool) error {
+	return markNodeConfigRollback(db, NODE_USERINPUT_HISTORY, rolledBackTo, automatic)
+}
+
+// Record that the latest version of the node policy restored the given version.
+func MarkNodePolicyRollback(db *bolt.DB, rolledBackTo uint64, automatic bool) error {
+	return markNodeConfigRollback(db, NODE_POLICY_HISTORY, rolledBackTo, automatic)
+}
+
+// Remove the node user input and node policy history, e.g. when the node is unregistered.
+func DeleteNodeConfigHistory(db *bolt.DB) error {
+	return db.Update(func(tx *bolt.Tx) error {
+		for _, bucket := range []string{NODE_USERINPUT_HISTORY, NODE_POLICY_HISTORY} {
+			if tx.Bucket([]byte(bucket)) != nil {
+				if err := tx.DeleteBucket([]byte(bucket)); err != nil {
+					return fmt.Errorf("Unable to delete node config history %v: %v", bucket, err)
+				}
+			}
+		}
+		return nil
+	})
+}
+
+func addNodeConfigVersion(db *bolt.DB, bucketName string, version NodeConfigVersion) (uint64, error) {
+
+	var latest uint64
+
+	writeErr := db.Update(func(tx *bolt.Tx) error {
+		bucket, err := tx.CreateBucketIfNotExists([]byte(bucketName))
+		if err != nil {
+			return err
+		}
+
+		versions, err := readNodeConfigVersions(bucket)
+		if err != nil {
+			return err
+		}
+		if len(versions) != 0 && versions[len(versions)-1].SameContent(version) {
+			latest = versions[len(versions)-1].Version
+			return nil
+		}
+
+		nextKey, err := bucket.NextSequence()
+		if err != nil {
+			return fmt.Errorf("Unable to get sequence key for new node config version %v. Error: %v", version, err)
+		}
+		version.Version = nextKey
+		version.Time = uint64(time.Now().Unix())
+
+		if err := putNodeConfigVersion(bucket, version); err != nil {
+			return err
+		}
+		latest = nextKey
+
+		// remove the oldest versions
+		for i := 0; i < len(versions)+1-MAX_NODE_CONFIG_VERSIONS; i++ {
+			if err := bucket.Delete([]byte(strconv.FormatUint(versions[i].Version, 10))); err != nil {
+				return fmt.Errorf("Unable to delete node config version %v: %v", versions[i].Version, err)
+			}
+		}
+		return nil
+	})
+
+	return latest, writeErr
+}
+
+func findNodeConfigHistory(db *bolt.DB, bucketName string) ([]NodeConfigVersion, error) {
+
+	versions := make([]NodeConfigVersion, 0)
+
+	readErr := db.View(func(tx *bolt.Tx) error {
+		if bucket := tx.Bucket([]byte(bucketName)); bucket != nil {
+			var err error
+			versions, err = readNodeConfigVersions(bucket)
+			return err
+		}
+		return nil // end transaction
+	})
+
+	if readErr != nil {
+		return nil, readErr
+	}
+	return versions, nil
+}
+
+func markNodeConfigRollback(db *bolt.DB, bucketName string, rolledBackTo uint64, automatic bool) error {
+	return db.Update(func(tx *bolt.Tx) error {
+		bucket := tx.Bucket([]byte(bucketName))
+		if bucket == nil {
+			return fmt.Errorf("No node config history in %v", bucketName)
+		}
+
+		versions, err := readNodeConfigVersions(bucket)
+		if err != nil {
+			return err
+		} else if len(versions) == 0 {
+			return fmt.Errorf("No node config history in %v", bucketName)
+		}
+
+		latest := versions[len(versions)-1]
+		latest.RolledBackTo = rolledBackTo
+		latest.Automatic = automatic
+		return putNodeConfigVersion(bucket, latest)
+	})
+}
+
+// The values of the secret user input variables are encrypted before the version is written.
+func putNodeConfigVersion(bucket *bolt.Bucket, version NodeConfigVersion) error {
+	stored := version
+	if encrypted, err := encryptSecretUserInput(version.UserInput); err != nil {
+		return err
+	} else {
+		stored.UserInput = encrypted
+	}
+
+	if serial, err := json.Marshal(stored); err != nil {
+		return fmt.Errorf("Failed to serialize node config version: %v. Error: %v", version, err)
+	} else {
+		return bucket.Put([]byte(strconv.FormatUint(version.Version, 10)), serial)
+	}
+}
+
+// The keys are not stored in numeric order, so the versions are sorted after they are read. The values of the secret
+// user input variables are decrypted.
+func readNodeConfigVersions(bucket *bolt.Bucket) ([]NodeConfigVersion, error) {
+	versions := make([]NodeConfigVersion, 0)
+	err := bucket.ForEach(func(k, v []byte) error {
+		var version NodeConfigVersion
+		if err := json.Unmarshal(v, &version); err != nil {
+			return fmt.Errorf("Unable to deserialize node config version record: %v", v)
+		} else if decrypted, err := decryptSecretUserInput(version.UserInput); err != nil {
+			return err
+		} else {
+			version.UserInput = decrypted
+		}
+		versions = append(versions, version)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	sort.Slice(versions, func(i, j int) bool { return versions[i].Version < versions[j].Version })
+	return versions, nil
+}
+
+// Returns the given version from a history, nil when the history does not hold it.
+func FindNodeConfigVersion(versions []NodeConfigVersion, version uint64) *NodeConfigVersion {
+	for i := range versions {
+		if versions[i].Version == version {
+			return &versions[i]
+		}
+	}
+	return nil
+}
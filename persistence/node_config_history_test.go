@@ -0,0 +1,112 @@
+// +build unit
+
+package persistence
+
+import (
+	"github.com/open-horizon/anax/externalpolicy"
+	"github.com/open-horizon/anax/policy"
+	"testing"
+)
+
+// Verify that node user input versions are added, that unchanged content is not added again and that the oldest
+// versions are removed.
+func Test_NodeUserInputHistory(t *testing.T) {
+
+	dir, db, err := utsetup()
+	if err != nil {
+		t.Error(err)
+	}
+	defer cleanTestDir(dir)
+
+	ui := []policy.UserInput{{ServiceOrgid: "myorg", ServiceUrl: "svc1", Inputs: []policy.Input{{Name: "var1", Value: "a"}}}}
+	if v, err := AddNodeUserInputVersion(db, ui); err != nil {
+		t.Errorf("Unexpected error: %v", err)
+	} else if v != 1 {
+		t.Errorf("expected version 1, got %v", v)
+	}
+
+	// the same content does not add a version
+	if v, err := AddNodeUserInputVersion(db, ui); err != nil {
+		t.Errorf("Unexpected error: %v", err)
+	} else if v != 1 {
+		t.Errorf("expected version 1, got %v", v)
+	}
+
+	ui2 := []policy.UserInput{{ServiceOrgid: "myorg", ServiceUrl: "svc1", Inputs: []policy.Input{{Name: "var1", Value: "b"}}}}
+	if v, err := AddNodeUserInputVersion(db, ui2); err != nil {
+		t.Errorf("Unexpected error: %v", err)
+	} else if v != 2 {
+		t.Errorf("expected version 2, got %v", v)
+	}
+
+	if err := MarkNodeUserInputRollback(db, 1, true); err != nil {
+		t.Errorf("Unexpected error: %v", err)
+	}
+
+	if history, err := FindNodeUserInputHistory(db); err != nil {
+		t.Errorf("Unexpected error: %v", err)
+	} else if len(history) != 2 || history[0].Version != 1 || history[1].Version != 2 {
+		t.Errorf("expected versions 1 and 2, got %v", history)
+	} else if history[1].UserInput[0].Inputs[0].Value != "b" {
+		t.Errorf("expected the user input of version 2, got %v", history[1])
+	} else if history[1].RolledBackTo != 1 || !history[1].Automatic {
+		t.Errorf("expected version 2 to be an automatic rollback to version 1, got %v", history[1])
+	} else if FindNodeConfigVersion(history, 1) == nil || FindNodeConfigVersion(history, 3) != nil {
+		t.Errorf("FindNodeConfigVersion returned the wrong versions for %v", history)
+	}
+
+	// add enough versions to remove the oldest ones
+	for i := 0; i < MAX_NODE_CONFIG_VERSIONS+5; i++ {
+		ui := []policy.UserInput{{ServiceOrgid: "myorg", ServiceUrl: "svc1", Inputs: []policy.Input{{Name: "var1", Value: float64(i)}}}}
+		if _, err := AddNodeUserInputVersion(db, ui); err != nil {
+			t.Errorf("Unexpected error: %v", err)
+		}
+	}
+	if history, err := FindNodeUserInputHistory(db); err != nil {
+		t.Errorf("Unexpected error: %v", err)
+	} else if len(history) != MAX_NODE_CONFIG_VERSIONS {
+		t.Errorf("expected %v versions, got %v", MAX_NODE_CONFIG_VERSIONS, len(history))
+	} else if history[len(history)-1].Version != MAX_NODE_CONFIG_VERSIONS+7 {
+		t.Errorf("expected the latest version to be %v, got %v", MAX_NODE_CONFIG_VERSIONS+7, history[len(history)-1].Version)
+	}
+
+	if err := DeleteNodeConfigHistory(db); err != nil {
+		t.Errorf("Unexpected error: %v", err)
+	} else if history, err := FindNodeUserInputHistory(db); err != nil {
+		t.Errorf("Unexpected error: %v", err)
+	} else if len(history) != 0 {
+		t.Errorf("expected no history after the delete, got %v", history)
+	}
+}
+
+// Verify that node policy versions record deleted policies.
+func Test_NodePolicyHistory(t *testing.T) {
+
+	dir, db, err := utsetup()
+	if err != nil {
+		t.Error(err)
+	}
+	defer cleanTestDir(dir)
+
+	pol := &externalpolicy.ExternalPolicy{
+		Properties:  externalpolicy.PropertyList{*externalpolicy.Property_Factory("prop1", "val1")},
+		Constraints: []string{`prop3 == "some value"`},
+	}
+	if _, err := AddNodePolicyVersion(db, pol); err != nil {
+		t.Errorf("Unexpected error: %v", err)
+	} else if _, err := AddNodePolicyVersion(db, nil); err != nil {
+		t.Errorf("Unexpected error: %v", err)
+	} else if _, err := AddNodePolicyVersion(db, nil); err != nil {
+		t.Errorf("Unexpected error: %v", err)
+	}
+
+	if history, err := FindNodePolicyHistory(db); err != nil {
+		t.Errorf("Unexpected error: %v", err)
+	} else if len(history) != 2 {
+		t.Errorf("expected 2 versions, got %v", history)
+	} else if history[0].Deleted || history[0].Policy == nil || history[0].Policy.Properties[0].Name != "prop1" {
+		t.Errorf("expected the policy in version 1, got %v", history[0])
+	} else if !history[1].Deleted || history[1].Policy != nil {
+		t.Errorf("expected version 2 to be a deleted policy, got %v", history[1])
+	}
+}
@@ -10,6 +10,7 @@ import (
 // Constants used throughout the code.
 const NODE_POLICY = "nodepolicy"                                   // The bucket name in the bolt DB.
 const EXCHANGE_NP_LAST_UPDATED = "exchange_nodepolicy_lastupdated" // The buucket for the exchange last updated string
+const NODE_PROVIDER_PROPERTIES = "nodeproviderproperties"          // The bucket for the properties from the node property providers
 
 // Retrieve the node policy object from the database. The bolt APIs assume there is more than 1 object in a bucket,
 // so this function has to be prepared for that case, even though there should only ever be 1.
@@ -147,3 +148,47 @@ func DeleteNodePolicyLastUpdated_Exch(db *bolt.DB) error {
 		})
 	}
 }
+
+// Retrieve the properties that the node property providers last reported. They are added to the node policy as
+// read-only properties.
+func FindNodeProviderProperties(db *bolt.DB) (externalpolicy.PropertyList, error) {
+
+	props := externalpolicy.PropertyList{}
+
+	readErr := db.View(func(tx *bolt.Tx) error {
+		if b := tx.Bucket([]byte(NODE_PROVIDER_PROPERTIES)); b != nil {
+			if v := b.Get([]byte(NODE_PROVIDER_PROPERTIES)); v != nil {
+				if err := json.Unmarshal(v, &props); err != nil {
+					return fmt.Errorf("Unable to deserialize node provider properties: %v", v)
+				}
+			}
+		}
+
+		return nil // end transaction
+	})
+
+	if readErr != nil {
+		return nil, readErr
+	}
+
+	return props, nil
+}
+
+// Save the properties that the node property providers reported, replacing the previous ones.
+func SaveNodeProviderProperties(db *bolt.DB, props externalpolicy.PropertyList) error {
+
+	writeErr := db.Update(func(tx *bolt.Tx) error {
+		b, err := tx.CreateBucketIfNotExists([]byte(NODE_PROVIDER_PROPERTIES))
+		if err != nil {
+			return err
+		}
+
+		if serial, err := json.Marshal(props); err != nil {
+			return fmt.Errorf("Failed to serialize node provider properties: %v. Error: %v", props, err)
+		} else {
+			return b.Put([]byte(NODE_PROVIDER_PROPERTIES), serial)
+		}
+	})
+
+	return writeErr
+}
@@ -223,3 +223,36 @@ func Test_DeleteNodePolicyLastUpdated_Exch(t *testing.T) {
 		t.Errorf("Failed to delete saved lastUpdated value from the local db, error %v", err)
 	}
 }
+
+// Verify that the node provider properties can be saved and replaced.
+func Test_SaveNodeProviderProperties(t *testing.T) {
+
+	dir, db, err := utsetup()
+	if err != nil {
+		t.Error(err)
+	}
+	defer cleanTestDir(dir)
+
+	if props, err := FindNodeProviderProperties(db); err != nil {
+		t.Errorf("failed to find node provider properties in db, error %v", err)
+	} else if len(props) != 0 {
+		t.Errorf("incorrect result, there should not be any provider properties: %v", props)
+	}
+
+	props := externalpolicy.PropertyList{*externalpolicy.Property_Factory("siteId", "store-42"), *externalpolicy.Property_Factory("sensors", 3)}
+	if err := SaveNodeProviderProperties(db, props); err != nil {
+		t.Errorf("Unexpected error: %v", err)
+	} else if props1, err := FindNodeProviderProperties(db); err != nil {
+		t.Errorf("failed to find node provider properties in db, error %v", err)
+	} else if len(props1) != 2 || props1[0].Name != "siteId" || props1[0].Value != "store-42" {
+		t.Errorf("incorrect provider properties saved, expecting %v found: %v", props, props1)
+	}
+
+	if err := SaveNodeProviderProperties(db, externalpolicy.PropertyList{}); err != nil {
+		t.Errorf("Unexpected error: %v", err)
+	} else if props2, err := FindNodeProviderProperties(db); err != nil {
+		t.Errorf("failed to find node provider properties in db, error %v", err)
+	} else if len(props2) != 0 {
+		t.Errorf("incorrect result, expecting the provider properties to be replaced, found: %v", props2)
+	}
+}
@@ -7,6 +7,7 @@ import (
 	"github.com/boltdb/bolt"
 	"github.com/golang/glog"
 	"github.com/open-horizon/anax/cutil"
+	"reflect"
 	"time"
 )
 
@@ -83,6 +84,48 @@ type EstablishedAgreement struct {
 	BlockchainName                  string                   `json:"blockchain_name,omitempty"`       // the name of the blockchain instance
 	BlockchainOrg                   string                   `json:"blockchain_org,omitempty"`        // the org of the blockchain instance
 	RunningWorkload                 WorkloadInfo             `json:"workload_to_run,omitempty"`       // For display purposes, a copy of the workload info that this agreement is managing. It should be the same info that is buried inside the proposal.
+	ClusterStatus                   *ClusterWorkloadStatus   `json:"cluster_status,omitempty"`        // The most recent status of the workload of a cluster agreement.
+	TemplateProperties              *TemplateProperties      `json:"template_properties,omitempty"`   // The node policy properties that the templates of the service refer to.
+}
+
+// The node policy properties that the templates in the deployment string and in the default user input of the service
+// of an agreement refer to, with the values that the templates were expanded with. A property that was not set has a
+// nil value. All is true when the templates depend on all the properties.
+type TemplateProperties struct {
+	Values map[string]interface{} `json:"values"`
+	All    bool                   `json:"all"`
+}
+
+func (t TemplateProperties) String() string {
+	return fmt.Sprintf("Values: %v, All: %v", t.Values, t.All)
+}
+
+// Returns true when the given node policy properties give the templates other values than they were expanded with.
+func (t TemplateProperties) Changed(props map[string]interface{}) bool {
+	if t.All && len(props) != len(t.Values) {
+		return true
+	}
+	for name, value := range t.Values {
+		if newValue, ok := props[name]; ok != (value != nil) || !reflect.DeepEqual(normalizePropertyValue(newValue), normalizePropertyValue(value)) {
+			return true
+		}
+	}
+	return false
+}
+
+// The values read back from the database are decoded from json, so the numbers are compared as json numbers.
+func normalizePropertyValue(v interface{}) interface{} {
+	if v == nil {
+		return nil
+	} else if b, err := json.Marshal(v); err != nil {
+		return v
+	} else {
+		var n interface{}
+		if err := json.Unmarshal(b, &n); err != nil {
+			return v
+		}
+		return n
+	}
 }
 
 func (c EstablishedAgreement) String() string {
@@ -114,13 +157,15 @@ func (c EstablishedAgreement) String() string {
 		"BlockchainType: %v, "+
 		"BlockchainName: %v, "+
 		"BlockchainOrg: %v, "+
-		"RunningWorkload: %v",
+		"RunningWorkload: %v, "+
+		"ClusterStatus: %v, "+
+		"TemplateProperties: %v",
 		c.Name, c.DependentServices, c.Archived, c.CurrentAgreementId, c.ConsumerId, c.CounterPartyAddress, ServiceConfigNames(&c.CurrentDeployment),
 		"********", c.ProposalSig,
 		c.AgreementCreationTime, c.AgreementExecutionStartTime, c.AgreementAcceptedTime, c.AgreementBCUpdateAckTime, c.AgreementFinalizedTime,
 		c.AgreementDataReceivedTime, c.AgreementTerminatedTime, c.AgreementForceTerminatedTime, c.TerminatedReason, c.TerminatedDescription,
 		c.AgreementProtocol, c.ProtocolVersion, c.AgreementProtocolTerminatedTime, c.WorkloadTerminatedTime,
-		c.MeteringNotificationMsg, c.BlockchainType, c.BlockchainName, c.BlockchainOrg, c.RunningWorkload)
+		c.MeteringNotificationMsg, c.BlockchainType, c.BlockchainName, c.BlockchainOrg, c.RunningWorkload, c.ClusterStatus, c.TemplateProperties)
 
 }
 
@@ -172,11 +217,22 @@ func NewEstablishedAgreement(db *bolt.DB, name string, agreementId string, consu
 		RunningWorkload:                 *wi,
 	}
 
+	// The proposal holds the user input of the deployment policy, which can have secrets, so it is encrypted in the
+	// database. It is decrypted when the agreement is read.
+	stored := *newAg
+	if err := initUserInputSecretKeyFor(db); err != nil {
+		return nil, fmt.Errorf("Unable to encrypt the proposal of agreement %v: %v", agreementId, err)
+	} else if encrypted, err := encryptSecretValue([]byte(proposal)); err != nil {
+		return nil, fmt.Errorf("Unable to encrypt the proposal of agreement %v: %v", agreementId, err)
+	} else {
+		stored.Proposal = encrypted
+	}
+
 	return newAg, db.Update(func(tx *bolt.Tx) error {
 
 		if b, err := tx.CreateBucketIfNotExists([]byte(E_AGREEMENTS + "-" + protocol)); err != nil {
 			return err
-		} else if bytes, err := json.Marshal(newAg); err != nil {
+		} else if bytes, err := json.Marshal(stored); err != nil {
 			return fmt.Errorf("Unable to marshal new record: %v", err)
 		} else if err := b.Put([]byte(agreementId), []byte(bytes)); err != nil {
 			return fmt.Errorf("Unable to persist agreement: %v", err)
@@ -197,19 +253,38 @@ func (a *EstablishedAgreement) GetDeploymentConfig() DeploymentConfig {
 		nd.Services = a.CurrentDeployment
 		return nd
 
-		// The extended deployment config must be in use, so return it. It could be kube or helm.
+		// The extended deployment config must be in use, so return it. It could be kube, kubernetes manifests, helm, a host
+		// process or a wasm module.
 	} else if IsKube(a.ExtendedDeployment) {
 		cd := new(KubeDeploymentConfig)
 		if err := cd.FromPersistentForm(a.ExtendedDeployment); err != nil {
 			glog.Errorf("Unable to convert kube deployment %v to persistent form, error %v", a.ExtendedDeployment, err)
 		}
 		return cd
+	} else if IsManifest(a.ExtendedDeployment) {
+		md := new(ManifestDeploymentConfig)
+		if err := md.FromPersistentForm(a.ExtendedDeployment); err != nil {
+			glog.Errorf("Unable to convert manifest deployment %v to persistent form, error %v", a.ExtendedDeployment, err)
+		}
+		return md
 	} else if IsHelm(a.ExtendedDeployment) {
 		hd := new(HelmDeploymentConfig)
 		if err := hd.FromPersistentForm(a.ExtendedDeployment); err != nil {
 			glog.Errorf("Unable to convert helm deployment %v to persistent form, error %v", a.ExtendedDeployment, err)
 		}
 		return hd
+	} else if IsProcess(a.ExtendedDeployment) {
+		pd := new(ProcessDeploymentConfig)
+		if err := pd.FromPersistentForm(a.ExtendedDeployment); err != nil {
+			glog.Errorf("Unable to convert process deployment %v to persistent form, error %v", a.ExtendedDeployment, err)
+		}
+		return pd
+	} else if IsWasm(a.ExtendedDeployment) {
+		wd := new(WasmDeploymentConfig)
+		if err := wd.FromPersistentForm(a.ExtendedDeployment); err != nil {
+			glog.Errorf("Unable to convert wasm deployment %v to persistent form, error %v", a.ExtendedDeployment, err)
+		}
+		return wd
 	}
 
 	return nil
@@ -337,6 +412,22 @@ func MeteringNotificationReceived(db *bolt.DB, dbAgreementId string, mn Metering
 	})
 }
 
+// save the most recent status of the workload of a cluster agreement
+func AgreementClusterStatusUpdated(db *bolt.DB, dbAgreementId string, protocol string, status *ClusterWorkloadStatus) (*EstablishedAgreement, error) {
+	return agreementStateUpdate(db, dbAgreementId, protocol, func(c EstablishedAgreement) *EstablishedAgreement {
+		c.ClusterStatus = status
+		return &c
+	})
+}
+
+// save the node policy properties that the templates of the service of the agreement were expanded with
+func AgreementTemplatePropertiesUsed(db *bolt.DB, dbAgreementId string, protocol string, tp *TemplateProperties) (*EstablishedAgreement, error) {
+	return agreementStateUpdate(db, dbAgreementId, protocol, func(c EstablishedAgreement) *EstablishedAgreement {
+		c.TemplateProperties = tp
+		return &c
+	})
+}
+
 func DeleteEstablishedAgreement(db *bolt.DB, agreementId string, protocol string) error {
 
 	if agreementId == "" {
@@ -464,6 +555,12 @@ func persistUpdatedAgreement(db *bolt.DB, dbAgreementId string, protocol string,
 				if mod.ProposalSig == "" { // 1 transition from empty to non-empty
 					mod.ProposalSig = update.ProposalSig
 				}
+				if update.ClusterStatus != nil && (mod.ClusterStatus == nil || mod.ClusterStatus.UpdateTime <= update.ClusterStatus.UpdateTime) { // always moves forward
+					mod.ClusterStatus = update.ClusterStatus
+				}
+				if update.TemplateProperties != nil { // the values the service was last started with
+					mod.TemplateProperties = update.TemplateProperties
+				}
 
 				if serialized, err := json.Marshal(mod); err != nil {
 					return fmt.Errorf("Failed to serialize contract record: %v. Error: %v", mod, err)
@@ -508,6 +605,14 @@ func FindEstablishedAgreements(db *bolt.DB, protocol string, filters []EAFilter)
 				if err := json.Unmarshal(v, &e); err != nil {
 					glog.Errorf("Unable to deserialize db record to EstablishedAgreement: %v", v)
 				} else {
+					if isEncryptedValue(e.Proposal) {
+						if proposal, err := decryptSecretValue(e.Proposal); err != nil {
+							glog.Errorf("Unable to decrypt the proposal of agreement %v: %v", e.CurrentAgreementId, err)
+						} else {
+							e.Proposal = string(proposal)
+						}
+					}
+
 					// this might be agreement from the old EstablishedAgreement structure where SensorUrl was used.
 					// will convert it to new using DependentServices
 					if e.DependentServices == nil {
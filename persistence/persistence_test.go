@@ -208,3 +208,34 @@ func Test_Backward_Compitibility_EstablishedAgreement(t *testing.T) {
 		}
 	}
 }
+
+func Test_TemplateProperties_Changed(t *testing.T) {
+	var saved TemplateProperties
+	if err := json.Unmarshal([]byte(`{"values":{"site_id":"s42","cores":4,"zone":null},"all":false}`), &saved); err != nil {
+		t.Fatalf("unable to unmarshal template properties: %v", err)
+	}
+
+	tests := []struct {
+		props   map[string]interface{}
+		changed bool
+	}{
+		{map[string]interface{}{"site_id": "s42", "cores": 4}, false},
+		{map[string]interface{}{"site_id": "s42", "cores": 4, "other": "x"}, false},
+		{map[string]interface{}{"site_id": "s43", "cores": 4}, true},
+		{map[string]interface{}{"site_id": "s42", "cores": 8}, true},
+		{map[string]interface{}{"site_id": "s42"}, true},
+		{map[string]interface{}{"site_id": "s42", "cores": 4, "zone": "z1"}, true},
+	}
+	for _, test := range tests {
+		if changed := saved.Changed(test.props); changed != test.changed {
+			t.Errorf("Changed(%v) returned %v, expected %v", test.props, changed, test.changed)
+		}
+	}
+
+	all := TemplateProperties{Values: map[string]interface{}{"site_id": "s42"}, All: true}
+	if all.Changed(map[string]interface{}{"site_id": "s42"}) {
+		t.Errorf("template properties should not have changed")
+	} else if !all.Changed(map[string]interface{}{"site_id": "s42", "other": "x"}) {
+		t.Errorf("template properties that refer to all the properties should have changed")
+	}
+}
@@ -0,0 +1,172 @@
+package persistence
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// The structure of the json string in the deployment field of a service definition when the service is an
+// executable that runs directly on the host, without a container runtime. The deployment string is signed, and
+// because it carries the hash of the executable, the signature covers the executable as well.
+
+type ProcessDeploymentConfig struct {
+	Executable   ProcessArtifact      `json:"executable"`
+	Args         []string             `json:"args,omitempty"`
+	Env          []string             `json:"env,omitempty"`           // NAME=value pairs added to the environment of the process
+	User         string               `json:"user,omitempty"`          // user[:group] that the process runs as
+	MaxMemoryMB  int64                `json:"max_memory_mb,omitempty"` // limit on the address space of the process
+	MaxOpenFiles uint64               `json:"max_open_files,omitempty"`
+	Nice         int                  `json:"nice,omitempty"`
+	Restart      ProcessRestartPolicy `json:"restart,omitempty"`
+}
+
+// The executable is downloaded from a URL or from an object in the model management system.
+type ProcessArtifact struct {
+	URL        string `json:"url,omitempty"`
+	ObjectType string `json:"object_type,omitempty"`
+	ObjectID   string `json:"object_id,omitempty"`
+	SHA256     string `json:"sha256"`
+}
+
+func (a ProcessArtifact) String() string {
+	if a.URL != "" {
+		return fmt.Sprintf("URL: %v, SHA256: %v", a.URL, a.SHA256)
+	}
+	return fmt.Sprintf("Object: %v/%v, SHA256: %v", a.ObjectType, a.ObjectID, a.SHA256)
+}
+
+// How the process is restarted when it exits. Restarts are delayed by a backoff that starts at InitialBackoffS
+// seconds and doubles on each consecutive failure, up to MaxBackoffS. A MaxRetries of zero restarts forever.
+type ProcessRestartPolicy struct {
+	MaxRetries      int `json:"max_retries,omitempty"`
+	InitialBackoffS int `json:"initial_backoff,omitempty"`
+	MaxBackoffS     int `json:"max_backoff,omitempty"`
+}
+
+const (
+	DEFAULT_PROCESS_INITIAL_BACKOFF = 1
+	DEFAULT_PROCESS_MAX_BACKOFF     = 300
+)
+
+func (r ProcessRestartPolicy) GetInitialBackoffS() int {
+	if r.InitialBackoffS <= 0 {
+		return DEFAULT_PROCESS_INITIAL_BACKOFF
+	}
+	return r.InitialBackoffS
+}
+
+func (r ProcessRestartPolicy) GetMaxBackoffS() int {
+	if r.MaxBackoffS <= 0 {
+		return DEFAULT_PROCESS_MAX_BACKOFF
+	} else if r.MaxBackoffS < r.GetInitialBackoffS() {
+		return r.GetInitialBackoffS()
+	}
+	return r.MaxBackoffS
+}
+
+func (p ProcessDeploymentConfig) String() string {
+	return fmt.Sprintf("Executable: {%v}, Args: %v, User: %v, MaxMemoryMB: %v, MaxOpenFiles: %v, Nice: %v, Restart: %v", p.Executable, p.Args, p.User, p.MaxMemoryMB, p.MaxOpenFiles, p.Nice, p.Restart)
+}
+
+func IsProcess(dep map[string]interface{}) bool {
+	if _, ok := dep["executable"]; ok {
+		return true
+	}
+	return false
+}
+
+var sha256Regex = regexp.MustCompile(`^[0-9a-fA-F]{64}$`)
+var envNameRegex = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+// Verify that the process deployment is complete and coherent.
+func (p *ProcessDeploymentConfig) Validate() error {
+	if p.Executable.URL == "" && p.Executable.ObjectID == "" {
+		return errors.New("executable must have a url or an object_id")
+	} else if p.Executable.URL != "" && p.Executable.ObjectID != "" {
+		return errors.New("executable cannot have both a url and an object_id")
+	} else if p.Executable.ObjectID != "" && p.Executable.ObjectType == "" {
+		return errors.New("executable object_id requires an object_type")
+	} else if p.Executable.URL != "" && !strings.HasPrefix(p.Executable.URL, "https://") && !strings.HasPrefix(p.Executable.URL, "http://") {
+		return fmt.Errorf("executable url %v must be an http or https url", p.Executable.URL)
+	} else if !sha256Regex.MatchString(p.Executable.SHA256) {
+		return fmt.Errorf("executable sha256 %v must be a hex encoded sha256 hash", p.Executable.SHA256)
+	}
+
+	for _, e := range p.Env {
+		if parts := strings.SplitN(e, "=", 2); len(parts) != 2 || !envNameRegex.MatchString(parts[0]) {
+			return fmt.Errorf("env %v must be in the form NAME=value", e)
+		}
+	}
+
+	if p.MaxMemoryMB < 0 {
+		return fmt.Errorf("max_memory_mb %v cannot be negative", p.MaxMemoryMB)
+	} else if p.Nice < -20 || p.Nice > 19 {
+		return fmt.Errorf("nice %v must be between -20 and 19", p.Nice)
+	} else if p.Restart.MaxRetries < 0 || p.Restart.InitialBackoffS < 0 || p.Restart.MaxBackoffS < 0 {
+		return fmt.Errorf("restart values %v cannot be negative", p.Restart)
+	}
+	return nil
+}
+
+// Functions that allow ProcessDeploymentConfig to support the DeploymentConfig interface.
+
+func (p *ProcessDeploymentConfig) IsNative() bool {
+	return false
+}
+
+func (p *ProcessDeploymentConfig) ToPersistentForm() (map[string]interface{}, error) {
+	ret := make(map[string]interface{})
+
+	// Marshal to JSON form so that we can unmarshal as a map[string]interface{}.
+	if jBytes, err := json.Marshal(p); err != nil {
+		return ret, errors.New(fmt.Sprintf("error marshalling process deployment: %v, error: %v", p, err))
+	} else if err := json.Unmarshal(jBytes, &ret); err != nil {
+		return ret, errors.New(fmt.Sprintf("error unmarshalling process deployment: %v, error: %v", string(jBytes), err))
+	}
+
+	return ret, nil
+}
+
+func (p *ProcessDeploymentConfig) FromPersistentForm(pf map[string]interface{}) error {
+
+	// Marshal to JSON form so that we can unmarshal as a ProcessDeploymentConfig.
+	if jBytes, err := json.Marshal(pf); err != nil {
+		return errors.New(fmt.Sprintf("error marshalling process persistent deployment: %v, error: %v", p, err))
+	} else if err := json.Unmarshal(jBytes, p); err != nil {
+		return errors.New(fmt.Sprintf("error unmarshalling process persistent deployment: %v, error: %v", string(jBytes), err))
+	}
+
+	return nil
+}
+
+func (p *ProcessDeploymentConfig) ToString() string {
+	if p != nil {
+		return p.String()
+	} else {
+		return ""
+	}
+}
+
+// Given a deployment string, unmarshal it as a ProcessDeployment object. It might not be a ProcessDeployment, so
+// we have to verify what was just unmarshalled.
+func GetProcessDeployment(depStr string) (*ProcessDeploymentConfig, error) {
+
+	pf := make(map[string]interface{})
+	if err := json.Unmarshal([]byte(depStr), &pf); err != nil {
+		return nil, errors.New(fmt.Sprintf("error unmarshalling deployment config as ProcessDeployment: %v", err))
+	} else if !IsProcess(pf) {
+		return nil, errors.New(fmt.Sprintf("deployment config is not a ProcessDeployment"))
+	}
+
+	pd := new(ProcessDeploymentConfig)
+	if err := json.Unmarshal([]byte(depStr), pd); err != nil {
+		return nil, errors.New(fmt.Sprintf("error unmarshalling deployment config as ProcessDeployment: %v", err))
+	} else if err := pd.Validate(); err != nil {
+		return nil, errors.New(fmt.Sprintf("process deployment config is not valid: %v", err))
+	}
+
+	return pd, nil
+}
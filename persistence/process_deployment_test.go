@@ -0,0 +1,81 @@
+// +build unit
+
+package persistence
+
+import (
+	"testing"
+)
+
+const testProcessHash = "9f86d081884c7d659a2feaa0c55ad015a3bf4f1b2b0b822cd15d6c15b0f00a08"
+
+func Test_DecodeProcessDeployment(t *testing.T) {
+
+	depStr := `{"executable":{"url":"https://example.com/bin/app","sha256":"` + testProcessHash + `"},"args":["-v"],"env":["MODE=fast"],"max_memory_mb":64,"restart":{"max_retries":3}}`
+
+	pd, err := GetProcessDeployment(depStr)
+	if err != nil {
+		t.Errorf("Error extracting process deployment %v, error: %v", depStr, err)
+	} else if pd.Executable.URL != "https://example.com/bin/app" || len(pd.Args) != 1 || pd.MaxMemoryMB != 64 || pd.Restart.MaxRetries != 3 {
+		t.Errorf("Extracted process deployment %v does not match %v", pd, depStr)
+	} else if pd.Restart.GetInitialBackoffS() != DEFAULT_PROCESS_INITIAL_BACKOFF || pd.Restart.GetMaxBackoffS() != DEFAULT_PROCESS_MAX_BACKOFF {
+		t.Errorf("Expected the default backoff, got %v", pd.Restart)
+	}
+
+	// round trip through the persistent form
+	if pf, err := pd.ToPersistentForm(); err != nil {
+		t.Errorf("Error converting %v to persistent form, error: %v", pd, err)
+	} else if !IsProcess(pf) {
+		t.Errorf("Persistent form %v is not recognized as a process deployment", pf)
+	} else if IsHelm(pf) || IsKube(pf) {
+		t.Errorf("Persistent form %v is recognized as another deployment type", pf)
+	} else {
+		newPD := new(ProcessDeploymentConfig)
+		if err := newPD.FromPersistentForm(pf); err != nil {
+			t.Errorf("Error converting %v from persistent form, error: %v", pf, err)
+		} else if newPD.String() != pd.String() {
+			t.Errorf("Converted process deployment %v does not match original %v", newPD, pd)
+		}
+	}
+
+}
+
+func Test_DecodeProcessDeployment_invalid(t *testing.T) {
+
+	invalid := []string{
+		`{"test":"nope"}`,
+		`{"chart_archive":"1234","release_name":"rel"}`,
+		`{"executable":{"sha256":"` + testProcessHash + `"}}`,
+		`{"executable":{"url":"https://example.com/bin/app","sha256":"abc"}}`,
+		`{"executable":{"url":"ftp://example.com/bin/app","sha256":"` + testProcessHash + `"}}`,
+		`{"executable":{"object_id":"app","sha256":"` + testProcessHash + `"}}`,
+		`{"executable":{"url":"https://example.com/bin/app","sha256":"` + testProcessHash + `"},"env":["NOVALUE"]}`,
+		`{"executable":{"url":"https://example.com/bin/app","sha256":"` + testProcessHash + `"},"nice":40}`,
+	}
+
+	for _, depStr := range invalid {
+		if pd, err := GetProcessDeployment(depStr); err == nil {
+			t.Errorf("Should be an error returned for %v", depStr)
+		} else if pd != nil {
+			t.Errorf("Should not return an object %v", pd)
+		}
+	}
+
+}
+
+func Test_ProcessDeploymentConfig(t *testing.T) {
+
+	ag := &EstablishedAgreement{
+		ExtendedDeployment: map[string]interface{}{
+			"executable": map[string]interface{}{"object_type": "binaries", "object_id": "app", "sha256": testProcessHash},
+		},
+	}
+
+	if dc := ag.GetDeploymentConfig(); dc == nil {
+		t.Errorf("Expected a deployment config")
+	} else if pd, ok := dc.(*ProcessDeploymentConfig); !ok {
+		t.Errorf("Expected a process deployment config, got %T", dc)
+	} else if pd.Executable.ObjectID != "app" || pd.IsNative() {
+		t.Errorf("Unexpected process deployment config %v", pd)
+	}
+
+}
@@ -127,6 +127,8 @@ func getErrorTypeList() []string {
 		EC_ERROR_START_SERVICE,
 		EC_ERROR_START_DEPENDENT_SERVICE,
 		EC_DEPENDENT_SERVICE_FAILED,
+		EC_DEPENDENT_SERVICE_NOT_READY,
+		EC_ERROR_ADMISSION_POLICY,
 	}
 
 }
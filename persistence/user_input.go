@@ -35,19 +35,25 @@ func FindNodeUserInput(db *bolt.DB) ([]policy.UserInput, error) {
 		return nil, readErr
 	}
 
-	return userInput, nil
+	return decryptSecretUserInput(userInput)
 }
 
 // There is only 1 object in the bucket so we can use the bucket name as the object key.
+// The values of the secret variables are encrypted.
 func SaveNodeUserInput(db *bolt.DB, userInput []policy.UserInput) error {
 
+	encrypted, err := encryptSecretUserInput(userInput)
+	if err != nil {
+		return err
+	}
+
 	writeErr := db.Update(func(tx *bolt.Tx) error {
 		b, err := tx.CreateBucketIfNotExists([]byte(NODE_USERINPUT))
 		if err != nil {
 			return err
 		}
 
-		if serial, err := json.Marshal(userInput); err != nil {
+		if serial, err := json.Marshal(encrypted); err != nil {
 			return fmt.Errorf("Failed to serialize node user input: %v. Error: %v", userInput, err)
 		} else {
 			return b.Put([]byte(NODE_USERINPUT), serial)
@@ -0,0 +1,211 @@
+package persistence
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/boltdb/bolt"
+	"github.com/open-horizon/anax/policy"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+)
+
+// The values of secret node user input variables are encrypted with AES-GCM before they are written to the local
+// database. The encrypted value is a string with this prefix followed by the base64 encoding of the nonce and the
+// sealed JSON encoding of the value.
+const ENCRYPTED_VALUE_PREFIX = "encrypted:aesgcm:"
+
+// The length in bytes of the key that encrypts secret node user input, which selects AES-256.
+const USERINPUT_SECRET_KEY_LENGTH = 32
+
+var userInputSecretKey []byte
+var userInputSecretKeyLock sync.Mutex
+
+// Read the key that encrypts secret node user input from the given file, creating the file with a new random key
+// when it does not exist. The file is only readable by the agent.
+func InitUserInputSecretKey(keyFile string) error {
+
+	key, err := ioutil.ReadFile(keyFile)
+	if err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("unable to read user input secret key file %v, error: %v", keyFile, err)
+	} else if os.IsNotExist(err) {
+		key = make([]byte, USERINPUT_SECRET_KEY_LENGTH)
+		if _, err := io.ReadFull(rand.Reader, key); err != nil {
+			return fmt.Errorf("unable to generate user input secret key, error: %v", err)
+		} else if err := os.MkdirAll(filepath.Dir(keyFile), 0700); err != nil {
+			return fmt.Errorf("unable to create directory for user input secret key file %v, error: %v", keyFile, err)
+		} else if err := ioutil.WriteFile(keyFile, key, 0600); err != nil {
+			return fmt.Errorf("unable to write user input secret key file %v, error: %v", keyFile, err)
+		}
+	} else if len(key) != USERINPUT_SECRET_KEY_LENGTH {
+		return fmt.Errorf("user input secret key file %v must hold %v bytes, it holds %v", keyFile, USERINPUT_SECRET_KEY_LENGTH, len(key))
+	}
+
+	userInputSecretKeyLock.Lock()
+	defer userInputSecretKeyLock.Unlock()
+	userInputSecretKey = key
+	return nil
+}
+
+// The file next to the database that holds the key when the key was not initialized before the database was written.
+const USERINPUT_SECRET_KEY_FILE = "userinput.key"
+
+// Make sure that there is a key to encrypt the values that are always encrypted in the database, like the proposals of
+// agreements. The agent initializes the key from its configured key file when it opens the database. When the database
+// is opened without that, e.g. by a tool or a test, the key is kept in a file next to the database file.
+func initUserInputSecretKeyFor(db *bolt.DB) error {
+	userInputSecretKeyLock.Lock()
+	initialized := userInputSecretKey != nil
+	userInputSecretKeyLock.Unlock()
+
+	if initialized {
+		return nil
+	}
+	return InitUserInputSecretKey(filepath.Join(filepath.Dir(db.Path()), USERINPUT_SECRET_KEY_FILE))
+}
+
+func getUserInputSecretCipher() (cipher.AEAD, error) {
+	userInputSecretKeyLock.Lock()
+	defer userInputSecretKeyLock.Unlock()
+
+	if userInputSecretKey == nil {
+		return nil, errors.New("the user input secret key is not initialized")
+	} else if block, err := aes.NewCipher(userInputSecretKey); err != nil {
+		return nil, err
+	} else {
+		return cipher.NewGCM(block)
+	}
+}
+
+// Encrypt a value with the key that encrypts secret node user input. The encrypted value is a string that starts with
+// ENCRYPTED_VALUE_PREFIX.
+func encryptSecretValue(plain []byte) (string, error) {
+	gcm, err := getUserInputSecretCipher()
+	if err != nil {
+		return "", err
+	}
+
+	nonce := make([]byte, gcm.NonceSize())
+	if _, err := io.ReadFull(rand.Reader, nonce); err != nil {
+		return "", fmt.Errorf("unable to generate nonce, error: %v", err)
+	}
+	sealed := gcm.Seal(nonce, nonce, plain, nil)
+	return ENCRYPTED_VALUE_PREFIX + base64.StdEncoding.EncodeToString(sealed), nil
+}
+
+// Returns true when the value was encrypted by encryptSecretValue.
+func isEncryptedValue(s string) bool {
+	return strings.HasPrefix(s, ENCRYPTED_VALUE_PREFIX)
+}
+
+// Decrypt a value that was encrypted by encryptSecretValue.
+func decryptSecretValue(s string) ([]byte, error) {
+	gcm, err := getUserInputSecretCipher()
+	if err != nil {
+		return nil, err
+	}
+
+	if sealed, err := base64.StdEncoding.DecodeString(strings.TrimPrefix(s, ENCRYPTED_VALUE_PREFIX)); err != nil {
+		return nil, fmt.Errorf("unable to decode the encrypted value, error: %v", err)
+	} else if len(sealed) < gcm.NonceSize() {
+		return nil, errors.New("the encrypted value is too short")
+	} else if plain, err := gcm.Open(nil, sealed[:gcm.NonceSize()], sealed[gcm.NonceSize():], nil); err != nil {
+		return nil, fmt.Errorf("unable to decrypt the value, error: %v", err)
+	} else {
+		return plain, nil
+	}
+}
+
+// Returns a copy of the user input with the values of the secret variables encrypted. The user input is returned as
+// is when it has no secret variables.
+func encryptSecretUserInput(userInput []policy.UserInput) ([]policy.UserInput, error) {
+	if !policy.HasSecretInput(userInput) {
+		return userInput, nil
+	}
+
+	encrypted := make([]policy.UserInput, 0, len(userInput))
+	for _, ui := range userInput {
+		c := ui.Copy()
+		for i, input := range c.Inputs {
+			if !input.IsSecret() {
+				continue
+			}
+			if plain, err := json.Marshal(input.Value); err != nil {
+				return nil, fmt.Errorf("unable to serialize the value of secret variable %v, error: %v", input.Name, err)
+			} else if value, err := encryptSecretValue(plain); err != nil {
+				return nil, fmt.Errorf("unable to encrypt secret variable %v, error: %v", input.Name, err)
+			} else {
+				c.Inputs[i].Value = value
+			}
+		}
+		encrypted = append(encrypted, c)
+	}
+	return encrypted, nil
+}
+
+// Returns a copy of the user input with the values of the secret variables decrypted. Values that are not encrypted
+// are returned as they are.
+func decryptSecretUserInput(userInput []policy.UserInput) ([]policy.UserInput, error) {
+	if !policy.HasSecretInput(userInput) {
+		return userInput, nil
+	}
+
+	decrypted := make([]policy.UserInput, 0, len(userInput))
+	for _, ui := range userInput {
+		c := ui.Copy()
+		for i, input := range c.Inputs {
+			s, ok := input.Value.(string)
+			if !input.IsSecret() || !ok || !isEncryptedValue(s) {
+				continue
+			}
+			var value interface{}
+			if plain, err := decryptSecretValue(s); err != nil {
+				return nil, fmt.Errorf("unable to decrypt secret variable %v, error: %v", input.Name, err)
+			} else if err := json.Unmarshal(plain, &value); err != nil {
+				return nil, fmt.Errorf("unable to deserialize secret variable %v, error: %v", input.Name, err)
+			}
+			c.Inputs[i].Value = value
+		}
+		decrypted = append(decrypted, c)
+	}
+	return decrypted, nil
+}
+
+// Returns a copy of the secrets with their values encrypted.
+func encryptSecretMap(secrets map[string]string) (map[string]string, error) {
+	if len(secrets) == 0 {
+		return nil, nil
+	}
+	encrypted := make(map[string]string, len(secrets))
+	for name, value := range secrets {
+		if e, err := encryptSecretValue([]byte(value)); err != nil {
+			return nil, fmt.Errorf("unable to encrypt secret %v, error: %v", name, err)
+		} else {
+			encrypted[name] = e
+		}
+	}
+	return encrypted, nil
+}
+
+// Returns a copy of the secrets with their values decrypted.
+func decryptSecretMap(secrets map[string]string) (map[string]string, error) {
+	decrypted := make(map[string]string, len(secrets))
+	for name, value := range secrets {
+		if !isEncryptedValue(value) {
+			decrypted[name] = value
+		} else if plain, err := decryptSecretValue(value); err != nil {
+			return nil, fmt.Errorf("unable to decrypt secret %v, error: %v", name, err)
+		} else {
+			decrypted[name] = string(plain)
+		}
+	}
+	return decrypted, nil
+}
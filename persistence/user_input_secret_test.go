@@ -0,0 +1,158 @@
+// +build unit
+
+package persistence
+
+import (
+	"github.com/boltdb/bolt"
+	"github.com/open-horizon/anax/policy"
+	"os"
+	"path"
+	"strings"
+	"testing"
+)
+
+// Verify that the values of secret node user input are encrypted in the database and in the history.
+func Test_NodeUserInputSecrets(t *testing.T) {
+
+	dir, db, err := utsetup()
+	if err != nil {
+		t.Error(err)
+	}
+	defer cleanTestDir(dir)
+
+	ui := []policy.UserInput{{ServiceOrgid: "myorg", ServiceUrl: "svc1", Inputs: []policy.Input{
+		{Name: "var1", Value: "plain"},
+		{Name: "password", Value: "s3cret", Type: policy.USERINPUT_TYPE_SECRET},
+	}}}
+
+	// there is no key to encrypt the secret
+	userInputSecretKey = nil
+	if err := SaveNodeUserInput(db, ui); err == nil {
+		t.Errorf("SaveNodeUserInput should have failed without a secret key")
+	}
+
+	keyFile := path.Join(dir, "keys", "userinput.key")
+	if err := InitUserInputSecretKey(keyFile); err != nil {
+		t.Errorf("Unexpected error: %v", err)
+	} else if info, err := os.Stat(keyFile); err != nil {
+		t.Errorf("the key file was not created: %v", err)
+	} else if info.Mode().Perm() != 0600 {
+		t.Errorf("the key file should only be readable by the agent, mode is %v", info.Mode())
+	}
+
+	if err := SaveNodeUserInput(db, ui); err != nil {
+		t.Errorf("Unexpected error: %v", err)
+	} else if _, err := AddNodeUserInputVersion(db, ui); err != nil {
+		t.Errorf("Unexpected error: %v", err)
+	}
+
+	// the secret is not stored in plain text
+	db.View(func(tx *bolt.Tx) error {
+		for _, bucket := range []string{NODE_USERINPUT, NODE_USERINPUT_HISTORY} {
+			tx.Bucket([]byte(bucket)).ForEach(func(k, v []byte) error {
+				if strings.Contains(string(v), "s3cret") {
+					t.Errorf("the secret is stored in plain text in %v: %v", bucket, string(v))
+				} else if !strings.Contains(string(v), ENCRYPTED_VALUE_PREFIX) || !strings.Contains(string(v), "plain") {
+					t.Errorf("only the secret should be encrypted in %v: %v", bucket, string(v))
+				}
+				return nil
+			})
+		}
+		return nil
+	})
+
+	// the key is read back from the file
+	if err := InitUserInputSecretKey(keyFile); err != nil {
+		t.Errorf("Unexpected error: %v", err)
+	}
+
+	if found, err := FindNodeUserInput(db); err != nil {
+		t.Errorf("Unexpected error: %v", err)
+	} else if found[0].Inputs[1].Value != "s3cret" {
+		t.Errorf("the secret should have been decrypted, got %v", found[0].Inputs[1].Value)
+	}
+
+	// the same content does not add a version even though the encrypted value changes
+	if v, err := AddNodeUserInputVersion(db, ui); err != nil {
+		t.Errorf("Unexpected error: %v", err)
+	} else if v != 1 {
+		t.Errorf("expected version 1, got %v", v)
+	}
+	if history, err := FindNodeUserInputHistory(db); err != nil {
+		t.Errorf("Unexpected error: %v", err)
+	} else if len(history) != 1 || history[0].UserInput[0].Inputs[1].Value != "s3cret" {
+		t.Errorf("the secret in the history should have been decrypted, got %v", history)
+	}
+}
+
+// Verify that the proposals of agreements and the secrets of service instances and containers are encrypted in the
+// database.
+func Test_SecretsEncrypted(t *testing.T) {
+
+	dir, db, err := utsetup()
+	if err != nil {
+		t.Error(err)
+	}
+	defer cleanTestDir(dir)
+
+	// without a key, the key for the proposal is kept next to the database
+	userInputSecretKey = nil
+
+	wi, _ := NewWorkloadInfo("myurl", "myorg", "1.0", "")
+	proposal := `{"tsandcs":"{\"userInput\":[{\"inputs\":[{\"name\":\"PASSWORD\",\"value\":\"s3cret\"}]}]}"}`
+	if _, err := NewEstablishedAgreement(db, "ag1", "ag1", "agbot", proposal, "Basic", 1, nil, "", "", "", "", "", wi); err != nil {
+		t.Errorf("Unexpected error: %v", err)
+	} else if _, err := AgreementStateAccepted(db, "ag1", "Basic"); err != nil {
+		t.Errorf("Unexpected error: %v", err)
+	} else if _, err := os.Stat(path.Join(dir, USERINPUT_SECRET_KEY_FILE)); err != nil {
+		t.Errorf("the key file was not created next to the database: %v", err)
+	}
+
+	msi, err := NewMicroserviceInstance(db, "svc1", "myorg", "1.0", "msdef1", nil)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	} else if _, err := UpdateMSInstanceEnvVars(db, msi.GetKey(), map[string]string{"MODE": "fast"}, map[string]string{"PASSWORD": "s3cret"}); err != nil {
+		t.Errorf("Unexpected error: %v", err)
+	}
+
+	cs := ContainerSecrets{ServiceURL: "svc1", Version: "1.0", Secrets: map[string]string{"PASSWORD": "s3cret"}, Refs: map[string]string{"TOKEN": "secret://vault/token"}}
+	if err := SaveContainerSecrets(db, "ag1", cs); err != nil {
+		t.Errorf("Unexpected error: %v", err)
+	}
+
+	db.View(func(tx *bolt.Tx) error {
+		for _, bucket := range []string{E_AGREEMENTS + "-Basic", MICROSERVICE_INSTANCES, CONTAINER_SECRETS} {
+			tx.Bucket([]byte(bucket)).ForEach(func(k, v []byte) error {
+				if strings.Contains(string(v), "s3cret") {
+					t.Errorf("the secret is stored in plain text in %v: %v", bucket, string(v))
+				}
+				return nil
+			})
+		}
+		return nil
+	})
+
+	if ags, err := FindEstablishedAgreements(db, "Basic", []EAFilter{IdEAFilter("ag1")}); err != nil {
+		t.Errorf("Unexpected error: %v", err)
+	} else if len(ags) != 1 || ags[0].Proposal != proposal {
+		t.Errorf("the proposal should have been decrypted, got %v", ags)
+	}
+
+	if found, err := FindMicroserviceInstanceWithKey(db, msi.GetKey()); err != nil {
+		t.Errorf("Unexpected error: %v", err)
+	} else if secrets, err := found.GetSecrets(); err != nil {
+		t.Errorf("Unexpected error: %v", err)
+	} else if secrets["PASSWORD"] != "s3cret" || found.EnvVars["MODE"] != "fast" {
+		t.Errorf("the secrets of the service instance should have been decrypted, got %v", secrets)
+	}
+
+	if all, err := FindContainerSecrets(db); err != nil {
+		t.Errorf("Unexpected error: %v", err)
+	} else if all["ag1"].Secrets["PASSWORD"] != "s3cret" || all["ag1"].Refs["TOKEN"] != cs.Refs["TOKEN"] {
+		t.Errorf("the container secrets should have been decrypted, got %v", all)
+	} else if err := DeleteContainerSecrets(db, "ag1"); err != nil {
+		t.Errorf("Unexpected error: %v", err)
+	} else if all, _ := FindContainerSecrets(db); len(all) != 0 {
+		t.Errorf("the container secrets were not deleted, got %v", all)
+	}
+}
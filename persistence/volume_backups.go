@@ -0,0 +1,166 @@
+package persistence
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/boltdb/bolt"
+	"github.com/golang/glog"
+	"sort"
+	"strconv"
+	"time"
+)
+
+// volume backup table name
+const VOLUME_BACKUPS = "volume_backups"
+
+// Why a backup of a volume was made.
+const (
+	VOLUME_BACKUP_UPGRADE = "upgrade" // before the service that uses the volume was upgraded
+	VOLUME_BACKUP_MANUAL  = "manual"  // on request of the user
+)
+
+// A snapshot of the content of a docker volume, kept in a gzipped tar file on the node.
+type VolumeBackup struct {
+	RecordId       string `json:"record_id"` // unique primary key for records
+	VolumeName     string `json:"volume_name"`
+	File           string `json:"file"`
+	Size           int64  `json:"size"`
+	Service        string `json:"service"`         // The org/url of the service that used the volume.
+	ServiceVersion string `json:"service_version"` // The version of the service that wrote the content.
+	Reason         string `json:"reason"`
+	CreationTime   uint64 `json:"creation_time"`
+}
+
+func NewVolumeBackup(volumeName string, file string, size int64, service string, serviceVersion string, reason string) *VolumeBackup {
+	return &VolumeBackup{
+		VolumeName:     volumeName,
+		File:           file,
+		Size:           size,
+		Service:        service,
+		ServiceVersion: serviceVersion,
+		Reason:         reason,
+		CreationTime:   uint64(time.Now().Unix()),
+	}
+}
+
+func (w VolumeBackup) String() string {
+	return fmt.Sprintf("RecordId: %v, "+
+		"VolumeName: %v, "+
+		"File: %v, "+
+		"Size: %v, "+
+		"Service: %v, "+
+		"ServiceVersion: %v, "+
+		"Reason: %v, "+
+		"CreationTime: %v",
+		w.RecordId, w.VolumeName, w.File, w.Size, w.Service, w.ServiceVersion, w.Reason, w.CreationTime)
+}
+
+func (w VolumeBackup) ShortString() string {
+	return w.String()
+}
+
+// save the VolumeBackup record into db.
+func SaveVolumeBackup(db *bolt.DB, backup *VolumeBackup) error {
+	writeErr := db.Update(func(tx *bolt.Tx) error {
+		if bucket, err := tx.CreateBucketIfNotExists([]byte(VOLUME_BACKUPS)); err != nil {
+			return err
+		} else {
+			// use the old key if it has one, otherwise generate one
+			key := backup.RecordId
+			if key == "" {
+				if nextKey, err := bucket.NextSequence(); err != nil {
+					return fmt.Errorf("Unable to get sequence key for saving new volume backup %v. Error: %v", backup, err)
+				} else {
+					key = strconv.FormatUint(nextKey, 10)
+					backup.RecordId = key
+				}
+			}
+
+			serial, err := json.Marshal(*backup)
+			if err != nil {
+				return fmt.Errorf("Failed to serialize the volume backup object: %v. Error: %v", *backup, err)
+			}
+			return bucket.Put([]byte(key), serial)
+		}
+	})
+
+	return writeErr
+}
+
+// delete the VolumeBackup record from db.
+func DeleteVolumeBackup(db *bolt.DB, recordId string) error {
+	return db.Update(func(tx *bolt.Tx) error {
+		if bucket := tx.Bucket([]byte(VOLUME_BACKUPS)); bucket != nil {
+			return bucket.Delete([]byte(recordId))
+		}
+		return nil
+	})
+}
+
+// filter on VolumeBackup
+type VolumeBackupFilter func(VolumeBackup) bool
+
+// filter on the volume name
+func VolumeNameVBFilter(name string) VolumeBackupFilter {
+	return func(b VolumeBackup) bool { return b.VolumeName == name }
+}
+
+// filter on the record id
+func RecordIdVBFilter(id string) VolumeBackupFilter {
+	return func(b VolumeBackup) bool { return b.RecordId == id }
+}
+
+// filter on the version of the service that wrote the content
+func ServiceVersionVBFilter(service string, version string) VolumeBackupFilter {
+	return func(b VolumeBackup) bool { return b.Service == service && b.ServiceVersion == version }
+}
+
+// filter on the reason of the backup
+func ReasonVBFilter(reason string) VolumeBackupFilter {
+	return func(b VolumeBackup) bool { return b.Reason == reason }
+}
+
+// find volume backups from the db for the given filters, the oldest first
+func FindVolumeBackups(db *bolt.DB, filters []VolumeBackupFilter) ([]VolumeBackup, error) {
+	backups := make([]VolumeBackup, 0)
+
+	// fetch volume backups
+	readErr := db.View(func(tx *bolt.Tx) error {
+
+		if b := tx.Bucket([]byte(VOLUME_BACKUPS)); b != nil {
+			b.ForEach(func(k, v []byte) error {
+
+				var vb VolumeBackup
+
+				if err := json.Unmarshal(v, &vb); err != nil {
+					glog.Errorf("Unable to deserialize VolumeBackup db record: %v. Error: %v", v, err)
+				} else {
+					exclude := false
+					for _, filterFn := range filters {
+						if !filterFn(vb) {
+							exclude = true
+						}
+					}
+					if !exclude {
+						backups = append(backups, vb)
+					}
+				}
+				return nil
+			})
+		}
+
+		return nil // end the transaction
+	})
+
+	if readErr != nil {
+		return nil, readErr
+	}
+
+	// the keys are sorted as strings, the sequence numbers are not
+	sort.SliceStable(backups, func(i, j int) bool {
+		ki, _ := strconv.ParseUint(backups[i].RecordId, 10, 64)
+		kj, _ := strconv.ParseUint(backups[j].RecordId, 10, 64)
+		return ki < kj
+	})
+	return backups, nil
+}
@@ -0,0 +1,49 @@
+// +build unit
+
+package persistence
+
+import (
+	"testing"
+)
+
+// Verify that volume backups are returned in the order they were made, even past 10 records, and that they can be
+// found by volume and by service version and deleted.
+func Test_VolumeBackups(t *testing.T) {
+
+	dir, db, err := utsetup()
+	if err != nil {
+		t.Error(err)
+	}
+	defer cleanTestDir(dir)
+
+	for i := 0; i < 12; i++ {
+		version := "1.0.0"
+		if i%2 == 1 {
+			version = "2.0.0"
+		}
+		if err := SaveVolumeBackup(db, NewVolumeBackup("data", "/backups/data.tar.gz", 10, "myorg/svc1", version, VOLUME_BACKUP_UPGRADE)); err != nil {
+			t.Errorf("Unexpected error: %v", err)
+		}
+	}
+	if err := SaveVolumeBackup(db, NewVolumeBackup("other", "/backups/other.tar.gz", 10, "myorg/svc2", "1.0.0", VOLUME_BACKUP_MANUAL)); err != nil {
+		t.Errorf("Unexpected error: %v", err)
+	}
+
+	if backups, err := FindVolumeBackups(db, []VolumeBackupFilter{VolumeNameVBFilter("data")}); err != nil {
+		t.Errorf("Unexpected error: %v", err)
+	} else if len(backups) != 12 || backups[0].RecordId != "1" || backups[11].RecordId != "12" {
+		t.Errorf("expected the 12 backups of the volume in order, got %v", backups)
+	}
+
+	if backups, err := FindVolumeBackups(db, []VolumeBackupFilter{ServiceVersionVBFilter("myorg/svc1", "2.0.0"), ReasonVBFilter(VOLUME_BACKUP_UPGRADE)}); err != nil {
+		t.Errorf("Unexpected error: %v", err)
+	} else if len(backups) != 6 || backups[5].RecordId != "12" {
+		t.Errorf("expected the 6 backups of version 2.0.0, got %v", backups)
+	}
+
+	if err := DeleteVolumeBackup(db, "13"); err != nil {
+		t.Errorf("Unexpected error: %v", err)
+	} else if backups, err := FindVolumeBackups(db, []VolumeBackupFilter{RecordIdVBFilter("13")}); err != nil || len(backups) != 0 {
+		t.Errorf("expected the backup to be deleted, got %v, error: %v", backups, err)
+	}
+}
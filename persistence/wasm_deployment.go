@@ -0,0 +1,173 @@
+package persistence
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"path"
+	"strings"
+)
+
+// The structure of the json string in the deployment field of a service definition when the service is a WebAssembly
+// module run by the agent's embedded WASI runtime. The deployment string is signed, and because it carries the digest
+// of the module, the signature covers the module as well.
+
+type WasmDeploymentConfig struct {
+	Module        WasmModule           `json:"wasm_module"`
+	Args          []string             `json:"args,omitempty"`
+	Env           []string             `json:"env,omitempty"` // NAME=value pairs added to the WASI environment of the module
+	Preopens      []WasmPreopen        `json:"preopens,omitempty"`
+	MemoryLimitMB int64                `json:"memory_limit_mb,omitempty"`
+	Restart       ProcessRestartPolicy `json:"restart,omitempty"`
+}
+
+// The module is downloaded from a URL or from an object in the model management system. The digest is of the form
+// sha256:<hex encoded hash>.
+type WasmModule struct {
+	URL        string `json:"url,omitempty"`
+	ObjectType string `json:"object_type,omitempty"`
+	ObjectID   string `json:"object_id,omitempty"`
+	Digest     string `json:"digest"`
+}
+
+func (m WasmModule) String() string {
+	if m.URL != "" {
+		return fmt.Sprintf("URL: %v, Digest: %v", m.URL, m.Digest)
+	}
+	return fmt.Sprintf("Object: %v/%v, Digest: %v", m.ObjectType, m.ObjectID, m.Digest)
+}
+
+// Returns the hex encoded sha256 hash in the digest of the module.
+func (m WasmModule) SHA256() string {
+	return strings.TrimPrefix(m.Digest, WASM_DIGEST_PREFIX)
+}
+
+// A directory of the node that the module can open. A relative host path is in the storage directory of the
+// agreement.
+type WasmPreopen struct {
+	HostPath  string `json:"host_path"`
+	GuestPath string `json:"guest_path"`
+	ReadOnly  bool   `json:"read_only,omitempty"`
+}
+
+func (p WasmPreopen) String() string {
+	return fmt.Sprintf("HostPath: %v, GuestPath: %v, ReadOnly: %v", p.HostPath, p.GuestPath, p.ReadOnly)
+}
+
+const WASM_DIGEST_PREFIX = "sha256:"
+
+// The largest memory a 32 bit WebAssembly module can address.
+const WASM_MAX_MEMORY_MB = 4096
+
+func (w WasmDeploymentConfig) String() string {
+	return fmt.Sprintf("Module: {%v}, Args: %v, Preopens: %v, MemoryLimitMB: %v, Restart: %v", w.Module, w.Args, w.Preopens, w.MemoryLimitMB, w.Restart)
+}
+
+func IsWasm(dep map[string]interface{}) bool {
+	if _, ok := dep["wasm_module"]; ok {
+		return true
+	}
+	return false
+}
+
+// Verify that the wasm deployment is complete and coherent.
+func (w *WasmDeploymentConfig) Validate() error {
+	if w.Module.URL == "" && w.Module.ObjectID == "" {
+		return errors.New("wasm_module must have a url or an object_id")
+	} else if w.Module.URL != "" && w.Module.ObjectID != "" {
+		return errors.New("wasm_module cannot have both a url and an object_id")
+	} else if w.Module.ObjectID != "" && w.Module.ObjectType == "" {
+		return errors.New("wasm_module object_id requires an object_type")
+	} else if w.Module.URL != "" && !strings.HasPrefix(w.Module.URL, "https://") && !strings.HasPrefix(w.Module.URL, "http://") {
+		return fmt.Errorf("wasm_module url %v must be an http or https url", w.Module.URL)
+	} else if !strings.HasPrefix(w.Module.Digest, WASM_DIGEST_PREFIX) || !sha256Regex.MatchString(w.Module.SHA256()) {
+		return fmt.Errorf("wasm_module digest %v must be of the form %v<hex encoded sha256 hash>", w.Module.Digest, WASM_DIGEST_PREFIX)
+	}
+
+	for _, e := range w.Env {
+		if parts := strings.SplitN(e, "=", 2); len(parts) != 2 || !envNameRegex.MatchString(parts[0]) {
+			return fmt.Errorf("env %v must be in the form NAME=value", e)
+		}
+	}
+
+	guestPaths := make(map[string]bool)
+	for _, p := range w.Preopens {
+		if p.HostPath == "" {
+			return fmt.Errorf("preopen %v must have a host_path", p)
+		} else if !path.IsAbs(p.HostPath) && (p.HostPath == ".." || strings.HasPrefix(path.Clean(p.HostPath), "../")) {
+			return fmt.Errorf("preopen host_path %v must be absolute or inside the storage directory of the service", p.HostPath)
+		} else if !path.IsAbs(p.GuestPath) {
+			return fmt.Errorf("preopen guest_path %v must be an absolute path", p.GuestPath)
+		} else if guestPaths[path.Clean(p.GuestPath)] {
+			return fmt.Errorf("preopen guest_path %v is used more than once", p.GuestPath)
+		}
+		guestPaths[path.Clean(p.GuestPath)] = true
+	}
+
+	if w.MemoryLimitMB < 0 || w.MemoryLimitMB > WASM_MAX_MEMORY_MB {
+		return fmt.Errorf("memory_limit_mb %v must be between 0 and %v", w.MemoryLimitMB, WASM_MAX_MEMORY_MB)
+	} else if w.Restart.MaxRetries < 0 || w.Restart.InitialBackoffS < 0 || w.Restart.MaxBackoffS < 0 {
+		return fmt.Errorf("restart values %v cannot be negative", w.Restart)
+	}
+	return nil
+}
+
+// Functions that allow WasmDeploymentConfig to support the DeploymentConfig interface.
+
+func (w *WasmDeploymentConfig) IsNative() bool {
+	return false
+}
+
+func (w *WasmDeploymentConfig) ToPersistentForm() (map[string]interface{}, error) {
+	ret := make(map[string]interface{})
+
+	// Marshal to JSON form so that we can unmarshal as a map[string]interface{}.
+	if jBytes, err := json.Marshal(w); err != nil {
+		return ret, errors.New(fmt.Sprintf("error marshalling wasm deployment: %v, error: %v", w, err))
+	} else if err := json.Unmarshal(jBytes, &ret); err != nil {
+		return ret, errors.New(fmt.Sprintf("error unmarshalling wasm deployment: %v, error: %v", string(jBytes), err))
+	}
+
+	return ret, nil
+}
+
+func (w *WasmDeploymentConfig) FromPersistentForm(pf map[string]interface{}) error {
+
+	// Marshal to JSON form so that we can unmarshal as a WasmDeploymentConfig.
+	if jBytes, err := json.Marshal(pf); err != nil {
+		return errors.New(fmt.Sprintf("error marshalling wasm persistent deployment: %v, error: %v", w, err))
+	} else if err := json.Unmarshal(jBytes, w); err != nil {
+		return errors.New(fmt.Sprintf("error unmarshalling wasm persistent deployment: %v, error: %v", string(jBytes), err))
+	}
+
+	return nil
+}
+
+func (w *WasmDeploymentConfig) ToString() string {
+	if w != nil {
+		return w.String()
+	} else {
+		return ""
+	}
+}
+
+// Given a deployment string, unmarshal it as a WasmDeployment object. It might not be a WasmDeployment, so we have to
+// verify what was just unmarshalled.
+func GetWasmDeployment(depStr string) (*WasmDeploymentConfig, error) {
+
+	pf := make(map[string]interface{})
+	if err := json.Unmarshal([]byte(depStr), &pf); err != nil {
+		return nil, errors.New(fmt.Sprintf("error unmarshalling deployment config as WasmDeployment: %v", err))
+	} else if !IsWasm(pf) {
+		return nil, errors.New(fmt.Sprintf("deployment config is not a WasmDeployment"))
+	}
+
+	wd := new(WasmDeploymentConfig)
+	if err := json.Unmarshal([]byte(depStr), wd); err != nil {
+		return nil, errors.New(fmt.Sprintf("error unmarshalling deployment config as WasmDeployment: %v", err))
+	} else if err := wd.Validate(); err != nil {
+		return nil, errors.New(fmt.Sprintf("wasm deployment config is not valid: %v", err))
+	}
+
+	return wd, nil
+}
@@ -0,0 +1,81 @@
+// +build unit
+
+package persistence
+
+import (
+	"testing"
+)
+
+const testWasmDigest = "sha256:" + testProcessHash
+
+func Test_DecodeWasmDeployment(t *testing.T) {
+
+	depStr := `{"wasm_module":{"url":"https://example.com/wasm/app.wasm","digest":"` + testWasmDigest + `"},"args":["-v"],"env":["MODE=fast"],"preopens":[{"host_path":"data","guest_path":"/data"},{"host_path":"/etc/app","guest_path":"/config","read_only":true}],"memory_limit_mb":16}`
+
+	wd, err := GetWasmDeployment(depStr)
+	if err != nil {
+		t.Errorf("Error extracting wasm deployment %v, error: %v", depStr, err)
+	} else if wd.Module.URL != "https://example.com/wasm/app.wasm" || wd.Module.SHA256() != testProcessHash || len(wd.Preopens) != 2 || !wd.Preopens[1].ReadOnly || wd.MemoryLimitMB != 16 {
+		t.Errorf("Extracted wasm deployment %v does not match %v", wd, depStr)
+	}
+
+	// round trip through the persistent form
+	if pf, err := wd.ToPersistentForm(); err != nil {
+		t.Errorf("Error converting %v to persistent form, error: %v", wd, err)
+	} else if !IsWasm(pf) {
+		t.Errorf("Persistent form %v is not recognized as a wasm deployment", pf)
+	} else if IsHelm(pf) || IsKube(pf) || IsProcess(pf) {
+		t.Errorf("Persistent form %v is recognized as another deployment type", pf)
+	} else {
+		newWD := new(WasmDeploymentConfig)
+		if err := newWD.FromPersistentForm(pf); err != nil {
+			t.Errorf("Error converting %v from persistent form, error: %v", pf, err)
+		} else if newWD.String() != wd.String() {
+			t.Errorf("Converted wasm deployment %v does not match original %v", newWD, wd)
+		}
+	}
+
+}
+
+func Test_DecodeWasmDeployment_invalid(t *testing.T) {
+
+	invalid := []string{
+		`{"executable":{"url":"https://example.com/bin/app","sha256":"` + testProcessHash + `"}}`,
+		`{"wasm_module":{"digest":"` + testWasmDigest + `"}}`,
+		`{"wasm_module":{"url":"https://example.com/app.wasm","digest":"` + testProcessHash + `"}}`,
+		`{"wasm_module":{"url":"https://example.com/app.wasm","digest":"sha256:abc"}}`,
+		`{"wasm_module":{"object_id":"app","digest":"` + testWasmDigest + `"}}`,
+		`{"wasm_module":{"url":"https://example.com/app.wasm","digest":"` + testWasmDigest + `"},"env":["NOVALUE"]}`,
+		`{"wasm_module":{"url":"https://example.com/app.wasm","digest":"` + testWasmDigest + `"},"preopens":[{"host_path":"../other","guest_path":"/data"}]}`,
+		`{"wasm_module":{"url":"https://example.com/app.wasm","digest":"` + testWasmDigest + `"},"preopens":[{"host_path":"data","guest_path":"data"}]}`,
+		`{"wasm_module":{"url":"https://example.com/app.wasm","digest":"` + testWasmDigest + `"},"preopens":[{"host_path":"a","guest_path":"/data"},{"host_path":"b","guest_path":"/data/"}]}`,
+		`{"wasm_module":{"url":"https://example.com/app.wasm","digest":"` + testWasmDigest + `"},"memory_limit_mb":8192}`,
+	}
+
+	for _, depStr := range invalid {
+		if wd, err := GetWasmDeployment(depStr); err == nil {
+			t.Errorf("Should be an error returned for %v", depStr)
+		} else if wd != nil {
+			t.Errorf("Should not return an object %v", wd)
+		}
+	}
+
+}
+
+func Test_WasmDeploymentConfig(t *testing.T) {
+
+	ag := &EstablishedAgreement{
+		ExtendedDeployment: map[string]interface{}{
+			"wasm_module": map[string]interface{}{"object_type": "wasm", "object_id": "app", "digest": testWasmDigest},
+		},
+	}
+
+	if dc := ag.GetDeploymentConfig(); dc == nil {
+		t.Errorf("Expected a deployment config")
+	} else if wd, ok := dc.(*WasmDeploymentConfig); !ok {
+		t.Errorf("Expected a wasm deployment config, got %T", dc)
+	} else if wd.Module.ObjectID != "app" || wd.IsNative() {
+		t.Errorf("Unexpected wasm deployment config %v", wd)
+	}
+
+}
@@ -0,0 +1,133 @@
+package policy
+
+import (
+	"fmt"
+	"sort"
+)
+
+// The layers that the user input of a service comes from. A service receives the value of a variable from the
+// layer with the highest precedence that sets it. From the lowest to the highest precedence, the layers are the
+// default values in the service definition, the pattern or the deployment policy, the node user input and the
+// legacy user input attributes of the node.
+const (
+	USERINPUT_SOURCE_SERVICE           = "service"
+	USERINPUT_SOURCE_PATTERN           = "pattern"
+	USERINPUT_SOURCE_DEPLOYMENT_POLICY = "deploymentPolicy"
+	USERINPUT_SOURCE_NODE              = "node"
+	USERINPUT_SOURCE_ATTRIBUTE         = "attribute"
+)
+
+// The user input variables that one layer sets for a service.
+type UserInputLayer struct {
+	Source string
+	Inputs []Input
+}
+
+func (l UserInputLayer) String() string {
+	return fmt.Sprintf("Source: %v, Inputs: %v", l.Source, l.Inputs)
+}
+
+// A value that is hidden by a layer with a higher precedence.
+type OverriddenInput struct {
+	Source string      `json:"source"`
+	Value  interface{} `json:"value"`
+}
+
+// The value that a service receives for a variable and the layer that it comes from.
+type EffectiveInput struct {
+	Name       string            `json:"name"`
+	Value      interface{}       `json:"value"`
+	Type       string            `json:"type,omitempty"`
+	Source     string            `json:"source"`
+	Overridden []OverriddenInput `json:"overridden,omitempty"` // the values from the lower layers, the highest precedence first
+}
+
+func (e EffectiveInput) String() string {
+	return fmt.Sprintf("Name: %v, Value: %v, Type: %v, Source: %v, Overridden: %v", e.Name, e.Value, e.Type, e.Source, e.Overridden)
+}
+
+// The merged user input of a service.
+type EffectiveUserInput struct {
+	ServiceOrgid   string           `json:"serviceOrgid"`
+	ServiceUrl     string           `json:"serviceUrl"`
+	ServiceVersion string           `json:"serviceVersion,omitempty"`
+	ServiceArch    string           `json:"serviceArch,omitempty"`
+	Inputs         []EffectiveInput `json:"inputs"`
+	Missing        []string         `json:"missing,omitempty"` // the variables without a default value that no layer sets
+}
+
+func (e EffectiveUserInput) String() string {
+	return fmt.Sprintf("ServiceOrgid: %v, ServiceUrl: %v, ServiceVersion: %v, ServiceArch: %v, Inputs: %v, Missing: %v",
+		e.ServiceOrgid, e.ServiceUrl, e.ServiceVersion, e.ServiceArch, e.Inputs, e.Missing)
+}
+
+// Returns the layer for the user input of the given service in a pattern, deployment policy or node user input.
+// The layer has no inputs when the user input does not have an element for the service.
+func NewUserInputLayer(source string, svcUrl string, svcOrg string, svcVersion string, svcArch string, userInput []UserInput) (*UserInputLayer, error) {
+	layer := &UserInputLayer{Source: source, Inputs: []Input{}}
+	if ui, _, err := FindUserInput(svcUrl, svcOrg, svcVersion, svcArch, userInput); err != nil {
+		return nil, fmt.Errorf("unable to find the %v user input for service %v/%v, error: %v", source, svcOrg, svcUrl, err)
+	} else if ui != nil {
+		layer.Inputs = ui.Inputs
+	}
+	return layer, nil
+}
+
+// Merge the layers of user input for a service. The layers are given from the lowest to the highest precedence.
+// The variables that are required by the service, i.e. the ones without a default value, and that no layer sets
+// are returned in Missing. The inputs are sorted by name.
+func NewEffectiveUserInput(svcUrl string, svcOrg string, svcVersion string, svcArch string, layers []UserInputLayer, required []string) *EffectiveUserInput {
+
+	effective := make(map[string]*EffectiveInput)
+	for _, layer := range layers {
+		for _, input := range layer.Inputs {
+			if e, ok := effective[input.Name]; !ok {
+				effective[input.Name] = &EffectiveInput{Name: input.Name, Value: input.Value, Type: input.Type, Source: layer.Source}
+			} else {
+				e.Overridden = append([]OverriddenInput{{Source: e.Source, Value: e.Value}}, e.Overridden...)
+				e.Value = input.Value
+				e.Source = layer.Source
+				if input.Type != "" {
+					e.Type = input.Type
+				}
+			}
+		}
+	}
+
+	out := &EffectiveUserInput{
+		ServiceOrgid:   svcOrg,
+		ServiceUrl:     svcUrl,
+		ServiceVersion: svcVersion,
+		ServiceArch:    svcArch,
+		Inputs:         make([]EffectiveInput, 0, len(effective)),
+	}
+	for _, e := range effective {
+		out.Inputs = append(out.Inputs, *e)
+	}
+	sort.Slice(out.Inputs, func(i, j int) bool { return out.Inputs[i].Name < out.Inputs[j].Name })
+
+	for _, name := range required {
+		if _, ok := effective[name]; !ok {
+			out.Missing = append(out.Missing, name)
+		}
+	}
+	return out
+}
+
+// The value that is shown in place of the value of a sensitive variable.
+const REDACTED_VALUE = "********"
+
+// Hide the values of the given sensitive variables, including the values that they override.
+func (e *EffectiveUserInput) Redact(sensitive []string) {
+	for i := range e.Inputs {
+		for _, name := range sensitive {
+			if e.Inputs[i].Name != name {
+				continue
+			}
+			e.Inputs[i].Value = REDACTED_VALUE
+			for j := range e.Inputs[i].Overridden {
+				e.Inputs[i].Overridden[j].Value = REDACTED_VALUE
+			}
+		}
+	}
+}
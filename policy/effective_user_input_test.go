@@ -0,0 +1,67 @@
+// +build unit
+
+package policy
+
+import (
+	"reflect"
+	"testing"
+)
+
+func Test_NewEffectiveUserInput(t *testing.T) {
+
+	userInput := []UserInput{
+		UserInput{
+			ServiceOrgid:        "mycomp",
+			ServiceUrl:          "cpu",
+			ServiceArch:         "amd64",
+			ServiceVersionRange: "[1.0.0,2.0.0)",
+			Inputs:              []Input{Input{Name: "var2", Value: "pattern2"}, Input{Name: "var3", Value: "pattern3"}},
+		},
+		UserInput{
+			ServiceOrgid: "mycomp",
+			ServiceUrl:   "gps",
+			Inputs:       []Input{Input{Name: "var1", Value: "gps1"}},
+		},
+	}
+
+	patternLayer, err := NewUserInputLayer(USERINPUT_SOURCE_PATTERN, "cpu", "mycomp", "1.2.0", "amd64", userInput)
+	if err != nil {
+		t.Errorf("NewUserInputLayer should not have returned error but got: %v", err)
+	} else if len(patternLayer.Inputs) != 2 {
+		t.Errorf("The pattern layer should have 2 inputs but got %v", patternLayer.Inputs)
+	}
+
+	// the version is out of the range
+	if layer, err := NewUserInputLayer(USERINPUT_SOURCE_PATTERN, "cpu", "mycomp", "2.1.0", "amd64", userInput); err != nil {
+		t.Errorf("NewUserInputLayer should not have returned error but got: %v", err)
+	} else if len(layer.Inputs) != 0 {
+		t.Errorf("The pattern layer should have no inputs but got %v", layer.Inputs)
+	}
+
+	layers := []UserInputLayer{
+		UserInputLayer{Source: USERINPUT_SOURCE_SERVICE, Inputs: []Input{Input{Name: "var1", Value: "default1", Type: "string"}, Input{Name: "var2", Value: "default2", Type: "string"}}},
+		*patternLayer,
+		UserInputLayer{Source: USERINPUT_SOURCE_NODE, Inputs: []Input{Input{Name: "var2", Value: "node2"}}},
+	}
+
+	e := NewEffectiveUserInput("cpu", "mycomp", "1.2.0", "amd64", layers, []string{"var3", "var4"})
+
+	expected := []EffectiveInput{
+		EffectiveInput{Name: "var1", Value: "default1", Type: "string", Source: USERINPUT_SOURCE_SERVICE},
+		EffectiveInput{Name: "var2", Value: "node2", Type: "string", Source: USERINPUT_SOURCE_NODE,
+			Overridden: []OverriddenInput{OverriddenInput{Source: USERINPUT_SOURCE_PATTERN, Value: "pattern2"}, OverriddenInput{Source: USERINPUT_SOURCE_SERVICE, Value: "default2"}}},
+		EffectiveInput{Name: "var3", Value: "pattern3", Source: USERINPUT_SOURCE_PATTERN},
+	}
+	if !reflect.DeepEqual(e.Inputs, expected) {
+		t.Errorf("Expected effective inputs %v but got %v", expected, e.Inputs)
+	}
+	if !reflect.DeepEqual(e.Missing, []string{"var4"}) {
+		t.Errorf("Expected var4 to be missing but got %v", e.Missing)
+	}
+
+	// no layers
+	e = NewEffectiveUserInput("cpu", "mycomp", "1.2.0", "amd64", nil, nil)
+	if len(e.Inputs) != 0 || len(e.Missing) != 0 {
+		t.Errorf("Expected an empty effective user input but got %v", e)
+	}
+}
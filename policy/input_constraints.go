@@ -0,0 +1,264 @@
+package policy
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/open-horizon/anax/cutil"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// The constraints that a service definition can declare on the value of a user input variable, in addition to its
+// type. Min and Max apply to int and float variables. Pattern is a regular expression that a string variable, or each
+// element of a list of strings variable, must match. AllowedValues lists the values that the variable can have, for a
+// list of strings it lists the values that each element can have. The value of a sensitive variable, and of a secret
+// variable, is never shown in messages or in the effective user input. A variable with RequiredIf only has to be given
+// a value when its condition holds.
+type InputConstraints struct {
+	Description   string        `json:"description,omitempty"`
+	Min           *float64      `json:"min,omitempty"`
+	Max           *float64      `json:"max,omitempty"`
+	Pattern       string        `json:"pattern,omitempty"`
+	AllowedValues []interface{} `json:"allowedValues,omitempty"`
+	Sensitive     bool          `json:"sensitive,omitempty"`
+	RequiredIf    *RequiredIf   `json:"requiredIf,omitempty"`
+}
+
+func (c InputConstraints) String() string {
+	min, max := "", ""
+	if c.Min != nil {
+		min = fmt.Sprintf("%v", *c.Min)
+	}
+	if c.Max != nil {
+		max = fmt.Sprintf("%v", *c.Max)
+	}
+	return fmt.Sprintf("Description: %v, Min: %v, Max: %v, Pattern: %v, AllowedValues: %v, Sensitive: %v, RequiredIf: %v",
+		c.Description, min, max, c.Pattern, c.AllowedValues, c.Sensitive, c.RequiredIf)
+}
+
+// Returns true when no constraint is declared.
+func (c InputConstraints) IsEmpty() bool {
+	return c.Min == nil && c.Max == nil && c.Pattern == "" && len(c.AllowedValues) == 0 && c.RequiredIf == nil
+}
+
+// The condition under which a variable without a default value is required. The variable is required when the other
+// variable Name of the service has a value, given or default, that is one of Values. When there are no Values, any
+// value of the other variable makes the variable required.
+type RequiredIf struct {
+	Name   string        `json:"name"`
+	Values []interface{} `json:"values,omitempty"`
+}
+
+func (r *RequiredIf) String() string {
+	if r == nil {
+		return ""
+	} else if len(r.Values) == 0 {
+		return fmt.Sprintf("%v is set", r.Name)
+	}
+	return fmt.Sprintf("%v is one of %v", r.Name, r.Values)
+}
+
+// Returns true when a variable that was not given a value has to be. The value function returns the value of another
+// variable of the service, which is the value that it was given or else its default value.
+func (c InputConstraints) IsRequired(defaultValue string, value func(name string) (interface{}, bool)) bool {
+	if defaultValue != "" {
+		return false
+	} else if c.RequiredIf == nil {
+		return true
+	}
+	v, ok := value(c.RequiredIf.Name)
+	return ok && (len(c.RequiredIf.Values) == 0 || isAllowedValue(v, c.RequiredIf.Values))
+}
+
+// Validate the constraints that a service definition declares for a variable of the given type. The default value,
+// when there is one, must satisfy the constraints.
+func (c InputConstraints) Validate(name string, varType string, defaultValue string) error {
+
+	numeric := varType == "int" || strings.Contains(varType, "float")
+	stringish := varType == "" || varType == "string" || varType == "list of strings" || varType == USERINPUT_TYPE_SECRET
+
+	if (c.Min != nil || c.Max != nil) && !numeric {
+		return fmt.Errorf("min and max are only supported for int and float variables, %v is of type %v", name, varType)
+	} else if c.Min != nil && c.Max != nil && *c.Min > *c.Max {
+		return fmt.Errorf("min %v is greater than max %v for variable %v", *c.Min, *c.Max, name)
+	}
+
+	if c.Pattern != "" {
+		if !stringish {
+			return fmt.Errorf("pattern is only supported for string and list of strings variables, %v is of type %v", name, varType)
+		} else if _, err := regexp.Compile(c.Pattern); err != nil {
+			return fmt.Errorf("pattern %v for variable %v is not a valid regular expression: %v", c.Pattern, name, err)
+		}
+	}
+
+	if c.RequiredIf != nil {
+		if c.RequiredIf.Name == "" {
+			return fmt.Errorf("requiredIf for variable %v must name a variable", name)
+		} else if c.RequiredIf.Name == name {
+			return fmt.Errorf("requiredIf for variable %v cannot name the variable itself", name)
+		} else if defaultValue != "" {
+			return fmt.Errorf("requiredIf is only supported for variables without a default value, %v has one", name)
+		}
+	}
+
+	elementType := varType
+	if varType == "list of strings" {
+		elementType = "string"
+	}
+	for _, v := range c.AllowedValues {
+		if err := cutil.VerifyWorkloadVarTypes(v, elementType); err != nil {
+			return fmt.Errorf("allowed value %v for variable %v has the wrong type: %v", v, name, err)
+		}
+	}
+
+	// the default value is a string in the service definition, convert it before checking it. The default value of
+	// a list of strings is the environment variable that the service receives, so it is not checked. A template
+	// default value is only known on the node, so only its syntax is checked.
+	if cutil.IsTemplate(defaultValue) {
+		if err := cutil.VerifyTemplate(defaultValue); err != nil {
+			return fmt.Errorf("default value for variable %v: %v", name, err)
+		}
+	} else if defaultValue != "" && varType != "list of strings" {
+		value, err := DefaultValueOfType(defaultValue, varType)
+		if err != nil {
+			return fmt.Errorf("default value for variable %v is not of type %v: %v", name, varType, err)
+		} else if err := c.Verify(value, varType); err != nil {
+			return fmt.Errorf("default value for variable %v does not satisfy its constraints: %v", name, err)
+		}
+	}
+	return nil
+}
+
+// Verify that a value of a variable satisfies the constraints. The type of the value must already be correct. The
+// error lists every violation.
+func (c InputConstraints) Verify(value interface{}, varType string) error {
+
+	// the secret that a reference refers to is only known on the node when the service is started
+	if IsSecretReference(value) {
+		_, _, err := ParseSecretReference(value.(string))
+		return err
+	}
+
+	// the value of a secret variable is sensitive whether or not it is declared to be
+	sensitive := c.Sensitive || varType == USERINPUT_TYPE_SECRET
+
+	violations := []string{}
+	shown := fmt.Sprintf("%v", value)
+	if sensitive {
+		shown = "the value"
+	}
+
+	if c.Min != nil || c.Max != nil {
+		if n, ok := numberValue(value); ok {
+			if c.Min != nil && n < *c.Min {
+				violations = append(violations, fmt.Sprintf("%v is less than the minimum %v", shown, *c.Min))
+			}
+			if c.Max != nil && n > *c.Max {
+				violations = append(violations, fmt.Sprintf("%v is greater than the maximum %v", shown, *c.Max))
+			}
+		}
+	}
+
+	// a list of strings is checked element by element
+	elements := []interface{}{value}
+	if list, ok := value.([]interface{}); ok {
+		elements = list
+	} else if list, ok := value.([]string); ok {
+		elements = make([]interface{}, 0, len(list))
+		for _, s := range list {
+			elements = append(elements, s)
+		}
+	}
+
+	if c.Pattern != "" {
+		if re, err := regexp.Compile(c.Pattern); err != nil {
+			violations = append(violations, fmt.Sprintf("pattern %v is not a valid regular expression: %v", c.Pattern, err))
+		} else {
+			for _, e := range elements {
+				if s, ok := e.(string); ok && !re.MatchString(s) {
+					if sensitive {
+						violations = append(violations, fmt.Sprintf("the value does not match the pattern %v", c.Pattern))
+					} else {
+						violations = append(violations, fmt.Sprintf("%v does not match the pattern %v", s, c.Pattern))
+					}
+				}
+			}
+		}
+	}
+
+	if len(c.AllowedValues) != 0 {
+		for _, e := range elements {
+			if !isAllowedValue(e, c.AllowedValues) {
+				if sensitive {
+					violations = append(violations, "the value is not one of the allowed values")
+				} else {
+					violations = append(violations, fmt.Sprintf("%v is not one of the allowed values %v", e, c.AllowedValues))
+				}
+			}
+		}
+	}
+
+	if len(violations) != 0 {
+		return errors.New(strings.Join(violations, "; "))
+	}
+	return nil
+}
+
+// Verify that a value of a variable has the declared type and satisfies the declared constraints.
+func VerifyInputValue(value interface{}, varType string, constraints InputConstraints) error {
+	if err := cutil.VerifyWorkloadVarTypes(value, varType); err != nil {
+		if constraints.Sensitive || varType == USERINPUT_TYPE_SECRET {
+			return fmt.Errorf("the value has the wrong type, expecting %v.", varType)
+		}
+		return err
+	}
+	return constraints.Verify(value, varType)
+}
+
+// Convert the string default value of a variable to the value that the variable has in user input.
+func DefaultValueOfType(defaultValue string, varType string) (interface{}, error) {
+	switch {
+	case varType == "int" || strings.Contains(varType, "float"):
+		return strconv.ParseFloat(defaultValue, 64)
+	case varType == "bool" || varType == "boolean":
+		return strconv.ParseBool(defaultValue)
+	default:
+		return defaultValue, nil
+	}
+}
+
+// Returns the value of a number in user input. Values that are read from JSON are float64 or json.Number.
+func numberValue(value interface{}) (float64, bool) {
+	switch v := value.(type) {
+	case float64:
+		return v, true
+	case float32:
+		return float64(v), true
+	case int:
+		return float64(v), true
+	case int64:
+		return float64(v), true
+	case json.Number:
+		if n, err := v.Float64(); err == nil {
+			return n, true
+		}
+	}
+	return 0, false
+}
+
+func isAllowedValue(value interface{}, allowed []interface{}) bool {
+	n, isNumber := numberValue(value)
+	for _, a := range allowed {
+		if isNumber {
+			if an, ok := numberValue(a); ok && an == n {
+				return true
+			}
+		} else if reflect.DeepEqual(value, a) {
+			return true
+		}
+	}
+	return false
+}
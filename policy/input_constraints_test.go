@@ -0,0 +1,130 @@
+// +build unit
+
+package policy
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+func floatPtr(f float64) *float64 {
+	return &f
+}
+
+func Test_InputConstraints_Validate(t *testing.T) {
+
+	// valid declarations
+	c := InputConstraints{Min: floatPtr(1), Max: floatPtr(10)}
+	if err := c.Validate("var1", "int", "5"); err != nil {
+		t.Errorf("Validate should not have returned error but got: %v", err)
+	}
+	c = InputConstraints{Pattern: "^[a-z]+$", AllowedValues: []interface{}{"abc", "def"}}
+	if err := c.Validate("var1", "string", "abc"); err != nil {
+		t.Errorf("Validate should not have returned error but got: %v", err)
+	}
+	c = InputConstraints{Pattern: "^[a-z]+$"}
+	if err := c.Validate("var1", "list of strings", "abc,def"); err != nil {
+		t.Errorf("Validate should not have returned error but got: %v", err)
+	}
+	c = InputConstraints{Max: floatPtr(10)}
+	if err := c.Validate("var1", "int", `{%property "count" "5"%}`); err != nil {
+		t.Errorf("Validate should not have returned error but got: %v", err)
+	}
+
+	// invalid declarations
+	tests := []struct {
+		c            InputConstraints
+		varType      string
+		defaultValue string
+	}{
+		{InputConstraints{Min: floatPtr(1)}, "string", ""},
+		{InputConstraints{Min: floatPtr(10), Max: floatPtr(1)}, "int", ""},
+		{InputConstraints{Pattern: "^[a-z]+$"}, "int", ""},
+		{InputConstraints{Pattern: "^[a-z+$"}, "string", ""},
+		{InputConstraints{AllowedValues: []interface{}{"abc", float64(1)}}, "string", ""},
+		{InputConstraints{Max: floatPtr(10)}, "int", "11"},
+		{InputConstraints{Max: floatPtr(10)}, "int", "abc"},
+		{InputConstraints{AllowedValues: []interface{}{"abc"}}, "string", "def"},
+		{InputConstraints{}, "string", "{%.NodeId"},
+		{InputConstraints{RequiredIf: &RequiredIf{}}, "string", ""},
+		{InputConstraints{RequiredIf: &RequiredIf{Name: "var1"}}, "string", ""},
+		{InputConstraints{RequiredIf: &RequiredIf{Name: "var2"}}, "string", "abc"},
+	}
+	for i, test := range tests {
+		if err := test.c.Validate("var1", test.varType, test.defaultValue); err == nil {
+			t.Errorf("Validate should have returned error for test %v", i)
+		}
+	}
+}
+
+func Test_InputConstraints_IsRequired(t *testing.T) {
+
+	values := map[string]interface{}{"mode": "remote", "count": float64(3)}
+	value := func(name string) (interface{}, bool) {
+		v, ok := values[name]
+		return v, ok
+	}
+
+	tests := []struct {
+		c            InputConstraints
+		defaultValue string
+		required     bool
+	}{
+		{InputConstraints{}, "", true},
+		{InputConstraints{}, "abc", false},
+		{InputConstraints{RequiredIf: &RequiredIf{Name: "mode"}}, "", true},
+		{InputConstraints{RequiredIf: &RequiredIf{Name: "other"}}, "", false},
+		{InputConstraints{RequiredIf: &RequiredIf{Name: "mode", Values: []interface{}{"local"}}}, "", false},
+		{InputConstraints{RequiredIf: &RequiredIf{Name: "mode", Values: []interface{}{"local", "remote"}}}, "", true},
+		{InputConstraints{RequiredIf: &RequiredIf{Name: "count", Values: []interface{}{3}}}, "", true},
+	}
+	for i, test := range tests {
+		if required := test.c.IsRequired(test.defaultValue, value); required != test.required {
+			t.Errorf("expected required %v for test %v, got %v", test.required, i, required)
+		}
+	}
+}
+
+func Test_InputConstraints_Verify(t *testing.T) {
+
+	c := InputConstraints{Min: floatPtr(1), Max: floatPtr(10), AllowedValues: []interface{}{float64(2), float64(4), float64(20)}}
+	if err := c.Verify(float64(4), "int"); err != nil {
+		t.Errorf("Verify should not have returned error but got: %v", err)
+	}
+	if err := c.Verify(json.Number("2"), "int"); err != nil {
+		t.Errorf("Verify should not have returned error but got: %v", err)
+	}
+	if err := c.Verify(float64(3), "int"); err == nil {
+		t.Errorf("Verify should have returned error for a value that is not allowed")
+	}
+
+	// every violation is reported
+	if err := c.Verify(float64(30), "int"); err == nil {
+		t.Errorf("Verify should have returned error for a value that is too large")
+	} else if !strings.Contains(err.Error(), "maximum") || !strings.Contains(err.Error(), "allowed values") {
+		t.Errorf("Verify should have reported 2 violations but got: %v", err)
+	}
+
+	// list of strings are checked element by element
+	c = InputConstraints{Pattern: "^[a-z]+$"}
+	if err := c.Verify([]interface{}{"abc", "def"}, "list of strings"); err != nil {
+		t.Errorf("Verify should not have returned error but got: %v", err)
+	}
+	if err := c.Verify([]interface{}{"abc", "DEF"}, "list of strings"); err == nil {
+		t.Errorf("Verify should have returned error for an element that does not match the pattern")
+	} else if !strings.Contains(err.Error(), "DEF") {
+		t.Errorf("Verify should have shown the element that does not match but got: %v", err)
+	}
+
+	// the value of a sensitive variable is not shown
+	c = InputConstraints{Pattern: "^[a-z]+$", Sensitive: true}
+	if err := c.Verify("Secret123", "string"); err == nil {
+		t.Errorf("Verify should have returned error for a value that does not match the pattern")
+	} else if strings.Contains(err.Error(), "Secret123") {
+		t.Errorf("Verify should not have shown the sensitive value but got: %v", err)
+	}
+	if err := VerifyInputValue(float64(1), "string", c); err == nil {
+		t.Errorf("VerifyInputValue should have returned error for a value of the wrong type")
+	}
+}
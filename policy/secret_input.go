@@ -0,0 +1,101 @@
+package policy
+
+import (
+	"fmt"
+	"strings"
+)
+
+// The type of a user input variable that holds a secret. The value of a secret variable is a string. It is encrypted
+// in the local database of the agent, it is replaced by REDACTED_VALUE in the output of the agent and in the copy of
+// the node user input in the exchange, and it is given to the service containers as a file instead of an environment
+// variable.
+const USERINPUT_TYPE_SECRET = "secret"
+
+// Returns true when the variable holds a secret.
+func (s Input) IsSecret() bool {
+	return s.Type == USERINPUT_TYPE_SECRET
+}
+
+// Returns true when the user input has a secret variable.
+func HasSecretInput(userInput []UserInput) bool {
+	for _, ui := range userInput {
+		for _, input := range ui.Inputs {
+			if input.IsSecret() {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// Returns a copy of the user input with the values of the secret variables replaced by REDACTED_VALUE.
+func RedactSecretUserInput(userInput []UserInput) []UserInput {
+	if userInput == nil {
+		return nil
+	}
+	redacted := make([]UserInput, 0, len(userInput))
+	for _, ui := range userInput {
+		c := ui.Copy()
+		for i := range c.Inputs {
+			if c.Inputs[i].IsSecret() {
+				c.Inputs[i].Value = REDACTED_VALUE
+			}
+		}
+		redacted = append(redacted, c)
+	}
+	return redacted
+}
+
+// Returns a copy of the user input where the secret variables that have the REDACTED_VALUE get the value that they have
+// in the existing user input. Redacted output can then be given back to the agent without resetting the secrets, and
+// the redacted copy of the node user input in the exchange does not replace the secrets that the agent keeps.
+func KeepSecretValues(userInput []UserInput, existing []UserInput) []UserInput {
+	if userInput == nil {
+		return nil
+	}
+	kept := make([]UserInput, 0, len(userInput))
+	for _, ui := range userInput {
+		c := ui.Copy()
+		for i := range c.Inputs {
+			if c.Inputs[i].Value != REDACTED_VALUE {
+				continue
+			}
+			for _, e := range existing {
+				if e.ServiceUrl != c.ServiceUrl || e.ServiceOrgid != c.ServiceOrgid {
+					continue
+				} else if !(e.ServiceArch == c.ServiceArch || e.ServiceArch == "" || c.ServiceArch == "") {
+					continue
+				}
+				if input := e.FindInput(c.Inputs[i].Name); input != nil && input.IsSecret() {
+					c.Inputs[i].Value = input.Value
+					c.Inputs[i].Type = USERINPUT_TYPE_SECRET
+				}
+			}
+		}
+		kept = append(kept, c)
+	}
+	return kept
+}
+
+// A user input value can refer to a secret that is kept by a secret provider on the node instead of holding the
+// secret itself, e.g. secret://vault/db/password. The reference is resolved by the agent when the service containers
+// are started, and the secret is given to the containers as a file.
+const SECRET_REFERENCE_PREFIX = "secret://"
+
+// Returns true when the value is a reference to a secret that is kept by a secret provider.
+func IsSecretReference(value interface{}) bool {
+	s, ok := value.(string)
+	return ok && strings.HasPrefix(s, SECRET_REFERENCE_PREFIX)
+}
+
+// Split a secret reference into the name of the secret provider and the path of the secret within the provider.
+func ParseSecretReference(ref string) (string, string, error) {
+	if !strings.HasPrefix(ref, SECRET_REFERENCE_PREFIX) {
+		return "", "", fmt.Errorf("%v is not a secret reference, it must start with %v", ref, SECRET_REFERENCE_PREFIX)
+	}
+	parts := strings.SplitN(strings.TrimPrefix(ref, SECRET_REFERENCE_PREFIX), "/", 2)
+	if len(parts) != 2 || parts[0] == "" || parts[1] == "" {
+		return "", "", fmt.Errorf("secret reference %v must have the form %vprovider/path", ref, SECRET_REFERENCE_PREFIX)
+	}
+	return parts[0], parts[1], nil
+}
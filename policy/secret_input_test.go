@@ -0,0 +1,78 @@
+// +build unit
+
+package policy
+
+import (
+	"strings"
+	"testing"
+)
+
+func Test_SecretUserInput(t *testing.T) {
+
+	existing := []UserInput{
+		UserInput{ServiceOrgid: "mycomp", ServiceUrl: "cpu", Inputs: []Input{
+			Input{Name: "var1", Value: "plain"},
+			Input{Name: "password", Value: "s3cret", Type: USERINPUT_TYPE_SECRET},
+		}},
+	}
+
+	if !HasSecretInput(existing) {
+		t.Errorf("HasSecretInput should have found the secret in %v", existing)
+	} else if strings.Contains(existing[0].String(), "s3cret") {
+		t.Errorf("the secret value should not be shown, got %v", existing[0].String())
+	}
+
+	redacted := RedactSecretUserInput(existing)
+	if redacted[0].Inputs[1].Value != REDACTED_VALUE || redacted[0].Inputs[0].Value != "plain" {
+		t.Errorf("only the secret should be redacted, got %v", redacted)
+	} else if existing[0].Inputs[1].Value != "s3cret" {
+		t.Errorf("RedactSecretUserInput should not have changed its input, got %v", existing)
+	}
+
+	// the redacted value keeps the existing secret, a new value replaces it
+	kept := KeepSecretValues(redacted, existing)
+	if kept[0].Inputs[1].Value != "s3cret" || !kept[0].Inputs[1].IsSecret() {
+		t.Errorf("the redacted secret should have kept its value, got %v", kept)
+	}
+	changed := []UserInput{
+		UserInput{ServiceOrgid: "mycomp", ServiceUrl: "cpu", ServiceArch: "amd64", Inputs: []Input{Input{Name: "password", Value: "new"}}},
+	}
+	if kept := KeepSecretValues(changed, existing); kept[0].Inputs[0].Value != "new" {
+		t.Errorf("the new secret value should have been kept, got %v", kept)
+	}
+
+	// there is no secret to keep for another service
+	other := []UserInput{
+		UserInput{ServiceOrgid: "mycomp", ServiceUrl: "gps", Inputs: []Input{Input{Name: "password", Value: REDACTED_VALUE}}},
+	}
+	if kept := KeepSecretValues(other, existing); kept[0].Inputs[0].Value != REDACTED_VALUE {
+		t.Errorf("there should be no secret for another service, got %v", kept)
+	}
+}
+
+func Test_SecretReference(t *testing.T) {
+
+	if provider, path, err := ParseSecretReference("secret://vault/db/password"); err != nil {
+		t.Errorf("unexpected error parsing the reference: %v", err)
+	} else if provider != "vault" || path != "db/password" {
+		t.Errorf("wrong provider %v or path %v", provider, path)
+	}
+
+	for _, ref := range []string{"secret://vault", "secret:///db", "secret://vault/", "vault/db"} {
+		if _, _, err := ParseSecretReference(ref); err == nil {
+			t.Errorf("%v should not be a valid secret reference", ref)
+		}
+	}
+
+	if !IsSecretReference("secret://vault/db") || IsSecretReference("plain") || IsSecretReference(42) {
+		t.Errorf("IsSecretReference returned the wrong result")
+	}
+
+	// a reference is not held to the constraints of the secret that it refers to
+	c := InputConstraints{Pattern: "^[0-9]+$"}
+	if err := c.Verify("secret://vault/pin", "string"); err != nil {
+		t.Errorf("a secret reference should not be verified against the constraints, error: %v", err)
+	} else if err := c.Verify("secret://vault", "string"); err == nil {
+		t.Errorf("a malformed secret reference should be rejected")
+	}
+}
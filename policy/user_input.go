@@ -14,9 +14,13 @@ type Input struct {
 }
 
 func (s Input) String() string {
+	value := s.Value
+	if s.IsSecret() {
+		value = REDACTED_VALUE
+	}
 	return fmt.Sprintf("Name: %v, "+
 		"Value: %v",
-		s.Name, s.Value)
+		s.Name, value)
 }
 
 // compare two Input's
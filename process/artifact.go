@@ -0,0 +1,103 @@
+package process
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"github.com/golang/glog"
+	"github.com/open-horizon/anax/config"
+	"github.com/open-horizon/anax/persistence"
+	"github.com/open-horizon/edge-sync-service/common"
+	"github.com/open-horizon/edge-sync-service/core/base"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path"
+	"strings"
+)
+
+// The name of the executable in the executable directory of the agreement.
+const EXECUTABLE_NAME = "executable"
+
+// The download of an artifact from a URL can take much longer than a call to the exchange.
+const DOWNLOAD_TIMEOUT_S = 600
+
+// Download the executable of the process deployment into the directory and verify that its hash is the one in the
+// deployment config. The deployment config is signed, so a matching hash means that the executable is the one that
+// was signed. Returns the path of the executable.
+func FetchExecutable(cfg *config.HorizonConfig, org string, pd *persistence.ProcessDeploymentConfig, dir string) (string, error) {
+
+	reader, err := OpenArtifact(cfg, org, "executable", pd.Executable.URL, pd.Executable.ObjectType, pd.Executable.ObjectID)
+	if err != nil {
+		return "", err
+	}
+	defer reader.Close()
+
+	return SaveExecutable(reader, pd.Executable.SHA256, dir)
+}
+
+// Open an artifact of a deployment, which is downloaded from a URL or read from an object in the model management
+// system of the node. The kind of artifact is only used in messages.
+func OpenArtifact(cfg *config.HorizonConfig, org string, kind string, url string, objectType string, objectID string) (io.ReadCloser, error) {
+
+	if url != "" {
+		glog.V(3).Infof(pwlog(fmt.Sprintf("downloading %v from %v", kind, url)))
+
+		timeout := uint(DOWNLOAD_TIMEOUT_S)
+		resp, err := cfg.Collaborators.HTTPClientFactory.NewHTTPClient(&timeout).Get(url)
+		if err != nil {
+			return nil, fmt.Errorf("unable to download %v from %v, error %v", kind, url, err)
+		}
+
+		if resp.StatusCode != http.StatusOK {
+			resp.Body.Close()
+			return nil, fmt.Errorf("unable to download %v from %v, HTTP status %v", kind, url, resp.Status)
+		}
+		return resp.Body, nil
+	}
+
+	glog.V(3).Infof(pwlog(fmt.Sprintf("reading %v from object %v/%v in org %v", kind, objectType, objectID, org)))
+
+	if !common.Running {
+		return nil, fmt.Errorf("unable to read %v from object %v/%v, the file sync service is not running", kind, objectType, objectID)
+	}
+	r, err := base.GetObjectData(org, objectType, objectID)
+	if err != nil {
+		return nil, fmt.Errorf("unable to read %v from object %v/%v, error %v", kind, objectType, objectID, err)
+	} else if r == nil {
+		return nil, fmt.Errorf("object %v/%v has not been received by the node", objectType, objectID)
+	}
+	return ioutil.NopCloser(r), nil
+}
+
+// Write the executable to a temporary file and move it into place only if its hash matches, so that an executable
+// that fails verification is never left where it could be run.
+func SaveExecutable(reader io.Reader, expectedHash string, dir string) (string, error) {
+
+	tmp, err := ioutil.TempFile(dir, EXECUTABLE_NAME+"-")
+	if err != nil {
+		return "", fmt.Errorf("unable to create file for the executable in %v, error %v", dir, err)
+	}
+	defer os.Remove(tmp.Name())
+
+	hash := sha256.New()
+	if _, err := io.Copy(io.MultiWriter(tmp, hash), reader); err != nil {
+		tmp.Close()
+		return "", fmt.Errorf("unable to save the executable, error %v", err)
+	} else if err := tmp.Close(); err != nil {
+		return "", fmt.Errorf("unable to save the executable, error %v", err)
+	}
+
+	if actual := hex.EncodeToString(hash.Sum(nil)); actual != strings.ToLower(expectedHash) {
+		return "", fmt.Errorf("the sha256 hash %v of the executable does not match the hash %v in the deployment config", actual, expectedHash)
+	}
+
+	execPath := path.Join(dir, EXECUTABLE_NAME)
+	if err := os.Chmod(tmp.Name(), 0755); err != nil {
+		return "", fmt.Errorf("unable to make %v executable, error %v", tmp.Name(), err)
+	} else if err := os.Rename(tmp.Name(), execPath); err != nil {
+		return "", fmt.Errorf("unable to move the executable to %v, error %v", execPath, err)
+	}
+	return execPath, nil
+}
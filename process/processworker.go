@@ -0,0 +1,431 @@
+package process
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/boltdb/bolt"
+	"github.com/golang/glog"
+	"github.com/open-horizon/anax/config"
+	"github.com/open-horizon/anax/containermessage"
+	"github.com/open-horizon/anax/events"
+	"github.com/open-horizon/anax/persistence"
+	"github.com/open-horizon/anax/policy"
+	"github.com/open-horizon/anax/worker"
+	"io/ioutil"
+	"net/url"
+	"os"
+	"path"
+	"sort"
+	"time"
+)
+
+// The PATH given to processes, since they do not inherit the environment of the agent.
+const PROCESS_DEFAULT_PATH = "/usr/local/sbin:/usr/local/bin:/usr/sbin:/usr/bin:/sbin:/bin"
+
+// The file in the executable directory of the agreement that records how the process was started, so that it can be
+// started again when the agent restarts. Only the agent can read it, because the environment holds the user input.
+const PROCESS_STATE_NAME = ".process.json"
+
+type processState struct {
+	Env []string `json:"env"`
+}
+
+// The process worker runs services whose deployment config is an executable, directly on the host. It is meant for
+// devices that are too small to run a container runtime.
+type ProcessWorker struct {
+	worker.BaseWorker // embedded field
+	db                *bolt.DB
+	org               string // the org of the node, which owns the model management objects that hold executables
+}
+
+func NewProcessWorker(name string, config *config.HorizonConfig, db *bolt.DB) *ProcessWorker {
+
+	worker := &ProcessWorker{
+		BaseWorker: worker.NewBaseWorker(name, config, nil),
+		db:         db,
+	}
+
+	glog.Info(pwlog(fmt.Sprintf("Starting Process worker")))
+	worker.Start(worker, 0)
+	return worker
+}
+
+// The processes are stopped when the agent exits, so the processes of the agreements that are still running are
+// started again.
+func (w *ProcessWorker) Initialize() bool {
+
+	if dev, err := persistence.FindExchangeDevice(w.db); err != nil {
+		glog.Errorf(pwlog(fmt.Sprintf("unable to read the node from the local database, error %v", err)))
+	} else if dev != nil {
+		w.org = dev.Org
+	}
+
+	agreements, err := persistence.FindEstablishedAgreementsAllProtocols(w.db, policy.AllAgreementProtocols(), []persistence.EAFilter{persistence.UnarchivedEAFilter()})
+	if err != nil {
+		glog.Errorf(pwlog(fmt.Sprintf("unable to read agreements from the local database, error %v", err)))
+		return true
+	}
+
+	for _, ag := range agreements {
+		pd, ok := ag.GetDeploymentConfig().(*persistence.ProcessDeploymentConfig)
+		if !ok || ag.AgreementTerminatedTime != 0 {
+			continue
+		}
+
+		glog.V(3).Infof(pwlog(fmt.Sprintf("restarting process for %v", ag.CurrentAgreementId)))
+		if err := w.restartProcess(ag.CurrentAgreementId, pd); err != nil {
+			glog.Errorf(pwlog(fmt.Sprintf("unable to restart process for %v, error %v", ag.CurrentAgreementId, err)))
+			w.Messages() <- events.NewWorkloadMessage(events.EXECUTION_FAILED, ag.AgreementProtocol, ag.CurrentAgreementId, pd)
+		}
+	}
+	return true
+}
+
+func (w *ProcessWorker) Messages() chan events.Message {
+	return w.BaseWorker.Manager.Messages
+}
+
+func (w *ProcessWorker) NewEvent(incoming events.Message) {
+
+	switch incoming.(type) {
+	case *events.EdgeRegisteredExchangeMessage:
+		msg, _ := incoming.(*events.EdgeRegisteredExchangeMessage)
+		w.Commands <- NewNodeRegisteredCommand(msg.Org())
+
+	case *events.AgreementReachedMessage:
+		msg, _ := incoming.(*events.AgreementReachedMessage)
+
+		fCmd := NewInstallCommand(msg.LaunchContext())
+		w.Commands <- fCmd
+
+	case *events.GovernanceWorkloadCancelationMessage:
+		msg, _ := incoming.(*events.GovernanceWorkloadCancelationMessage)
+
+		switch msg.Event().Id {
+		case events.AGREEMENT_ENDED:
+			cmd := NewUnInstallCommand(msg.AgreementProtocol, msg.AgreementId, msg.Deployment)
+			w.Commands <- cmd
+		}
+
+	case *events.GovernanceMaintenanceMessage:
+		msg, _ := incoming.(*events.GovernanceMaintenanceMessage)
+
+		switch msg.Event().Id {
+		case events.CONTAINER_MAINTAIN:
+			cmd := NewMaintenanceCommand(msg.AgreementProtocol, msg.AgreementId, msg.Deployment)
+			w.Commands <- cmd
+		}
+
+	case *events.NodeShutdownCompleteMessage:
+		msg, _ := incoming.(*events.NodeShutdownCompleteMessage)
+		switch msg.Event().Id {
+		case events.UNCONFIGURE_COMPLETE:
+			w.Commands <- worker.NewTerminateCommand("shutdown")
+		}
+
+	default: //nothing
+
+	}
+
+	return
+}
+
+func (w *ProcessWorker) CommandHandler(command worker.Command) bool {
+
+	switch command.(type) {
+	case *NodeRegisteredCommand:
+		cmd := command.(*NodeRegisteredCommand)
+		w.org = cmd.Org
+
+	case *InstallCommand:
+		cmd := command.(*InstallCommand)
+		if lc := w.getLaunchContext(cmd.LaunchContext); lc == nil {
+			glog.Errorf(pwlog(fmt.Sprintf("incoming event was not a known launch context %T", cmd.LaunchContext)))
+		} else {
+			glog.V(5).Infof(pwlog(fmt.Sprintf("LaunchContext(%T): %v", lc, lc)))
+
+			// Check the deployment to see if it is a process deployment. If not, ignore it.
+			deploymentConfig := lc.ContainerConfig().Deployment
+			if !isProcessDeployment(deploymentConfig) {
+				glog.V(5).Infof(pwlog(fmt.Sprintf("ignoring non-process deployment.")))
+				return true
+			}
+
+			if pd, err := persistence.GetProcessDeployment(deploymentConfig); err != nil {
+				glog.Errorf(pwlog(fmt.Sprintf("error getting process deployment configuration: %v", err)))
+				w.Messages() <- events.NewWorkloadMessage(events.EXECUTION_FAILED, lc.AgreementProtocol, lc.AgreementId, nil)
+			} else if len(lc.Microservices) != 0 {
+				// The required services run in containers on networks that a process on the host cannot join.
+				glog.Errorf(pwlog(fmt.Sprintf("process deployment for %v cannot use the required services %v", lc.AgreementId, lc.Microservices)))
+				w.Messages() <- events.NewWorkloadMessage(events.EXECUTION_FAILED, lc.AgreementProtocol, lc.AgreementId, pd)
+			} else if len(lc.Secrets) != 0 {
+				// Secrets are given to containers as files that are bound into them, a process has no such place.
+				glog.Errorf(pwlog(fmt.Sprintf("process deployment for %v cannot use the secret user inputs %v", lc.AgreementId, events.SecretNames(lc.Secrets))))
+				w.Messages() <- events.NewWorkloadMessage(events.EXECUTION_FAILED, lc.AgreementProtocol, lc.AgreementId, pd)
+			} else if _, err := persistence.AgreementDeploymentStarted(w.db, lc.AgreementId, lc.AgreementProtocol, pd); err != nil {
+				glog.Errorf(pwlog(fmt.Sprintf("received error updating database deployment state, %v", err)))
+				w.Messages() <- events.NewWorkloadMessage(events.EXECUTION_FAILED, lc.AgreementProtocol, lc.AgreementId, pd)
+			} else if err := CheckAdmission(&w.Config.Edge.AdmissionPolicy, lc.AgreementId, pd); err != nil {
+				glog.Errorf(pwlog(err))
+				w.Messages() <- events.NewWorkloadMessage(events.EXECUTION_FAILED, lc.AgreementProtocol, lc.AgreementId, pd)
+			} else if err := w.startProcess(lc, pd); err != nil {
+				glog.Errorf(pwlog(fmt.Sprintf("failed to start process after agreement negotiation: %v", err)))
+				w.Messages() <- events.NewWorkloadMessage(events.EXECUTION_FAILED, lc.AgreementProtocol, lc.AgreementId, pd)
+			} else {
+				w.Messages() <- events.NewWorkloadMessage(events.EXECUTION_BEGUN, lc.AgreementProtocol, lc.AgreementId, pd)
+			}
+		}
+
+	case *UnInstallCommand:
+		cmd := command.(*UnInstallCommand)
+
+		pd, ok := cmd.Deployment.(*persistence.ProcessDeploymentConfig)
+		if !ok {
+			glog.V(5).Infof(pwlog(fmt.Sprintf("ignoring non-process cancelation command %v", cmd)))
+			return true
+		}
+
+		glog.V(3).Infof(pwlog(fmt.Sprintf("stopping process for %v", cmd.CurrentAgreementId)))
+		w.stopProcess(cmd.CurrentAgreementId)
+
+		w.Messages() <- events.NewWorkloadMessage(events.WORKLOAD_DESTROYED, cmd.AgreementProtocol, cmd.CurrentAgreementId, pd)
+
+	case *MaintenanceCommand:
+		cmd := command.(*MaintenanceCommand)
+
+		pd, ok := cmd.Deployment.(*persistence.ProcessDeploymentConfig)
+		if !ok {
+			glog.V(5).Infof(pwlog(fmt.Sprintf("ignoring non-process maintenance command: %v", cmd)))
+			return true
+		}
+
+		glog.V(3).Infof(pwlog(fmt.Sprintf("received maintenance command %v", cmd)))
+
+		// A process that is not supervised, e.g. because the agent restarted, is as unhealthy as one that failed.
+		if s := getSupervisor(cmd.AgreementId); s == nil {
+			glog.Errorf(pwlog(fmt.Sprintf("no process is running for agreement %v", cmd.AgreementId)))
+			w.Messages() <- events.NewWorkloadMessage(events.EXECUTION_FAILED, cmd.AgreementProtocol, cmd.AgreementId, pd)
+		} else if !s.IsHealthy() {
+			glog.Errorf(pwlog(fmt.Sprintf("process for agreement %v has failed: %v", cmd.AgreementId, s.Status())))
+			w.Messages() <- events.NewWorkloadMessage(events.EXECUTION_FAILED, cmd.AgreementProtocol, cmd.AgreementId, pd)
+		}
+
+	default:
+		return false
+	}
+	return true
+}
+
+func (w *ProcessWorker) getLaunchContext(launchContext interface{}) *events.AgreementLaunchContext {
+	switch launchContext.(type) {
+	case *events.AgreementLaunchContext:
+		lc := launchContext.(*events.AgreementLaunchContext)
+		return lc
+	}
+	return nil
+}
+
+// Download and verify the executable into the executable directory of the agreement, and start supervising it in the
+// storage directory of the agreement.
+func (w *ProcessWorker) startProcess(lc *events.AgreementLaunchContext, pd *persistence.ProcessDeploymentConfig) error {
+	glog.V(3).Infof(pwlog(fmt.Sprintf("begin install of process deployment %v", lc.AgreementId)))
+
+	dir := path.Join(w.Config.Edge.ServiceStorage, lc.AgreementId)
+	if err := os.MkdirAll(dir, 0750); err != nil {
+		return fmt.Errorf("unable to create directory %v, error %v", dir, err)
+	}
+
+	// The process has to be able to write its log and any files of its own into the directory.
+	if cred, err := lookupCredential(processUser(pd)); err != nil {
+		os.RemoveAll(dir)
+		return err
+	} else if cred != nil {
+		if err := os.Chown(dir, int(cred.Uid), int(cred.Gid)); err != nil {
+			os.RemoveAll(dir)
+			return fmt.Errorf("unable to give user %v the directory %v, error %v", processUser(pd), dir, err)
+		}
+	}
+
+	execDir, err := w.createExecutableDir(lc.AgreementId)
+	if err != nil {
+		os.RemoveAll(dir)
+		return err
+	}
+
+	cleanup := func() {
+		os.RemoveAll(dir)
+		os.RemoveAll(execDir)
+	}
+
+	execPath, err := FetchExecutable(w.Config, w.org, pd, execDir)
+	if err != nil {
+		cleanup()
+		return err
+	}
+
+	env := map[string]string{}
+	if lc.EnvironmentAdditions != nil {
+		for k, v := range *lc.EnvironmentAdditions {
+			env[k] = v
+		}
+	}
+
+	state := processState{Env: ProcessEnvironment(pd, env)}
+	if stateBytes, err := json.Marshal(state); err != nil {
+		cleanup()
+		return fmt.Errorf("unable to marshal the process state, error %v", err)
+	} else if err := ioutil.WriteFile(path.Join(execDir, PROCESS_STATE_NAME), stateBytes, 0600); err != nil {
+		cleanup()
+		return fmt.Errorf("unable to save the process state, error %v", err)
+	}
+
+	s := NewSupervisor(lc.AgreementId, execPath, dir, state.Env, pd)
+	if err := s.Start(); err != nil {
+		cleanup()
+		return err
+	}
+
+	addSupervisor(lc.AgreementId, s)
+	return nil
+}
+
+// Start the process of an agreement again, with the executable and environment that it was first started with. The
+// executable is verified again, because it was kept on disk while the agent was not running. The directories are left
+// in place when the process cannot be started, they are removed when the agreement ends.
+func (w *ProcessWorker) restartProcess(agreementId string, pd *persistence.ProcessDeploymentConfig) error {
+	dir := path.Join(w.Config.Edge.ServiceStorage, agreementId)
+	execDir := w.executableDir(agreementId)
+
+	var state processState
+	if stateBytes, err := ioutil.ReadFile(path.Join(execDir, PROCESS_STATE_NAME)); err != nil {
+		return fmt.Errorf("unable to read the process state, error %v", err)
+	} else if err := json.Unmarshal(stateBytes, &state); err != nil {
+		return fmt.Errorf("unable to unmarshal the process state, error %v", err)
+	}
+
+	execPath := path.Join(execDir, EXECUTABLE_NAME)
+	if f, err := os.Open(execPath); err != nil {
+		return fmt.Errorf("unable to open the executable %v, error %v", execPath, err)
+	} else {
+		_, err = SaveExecutable(f, pd.Executable.SHA256, execDir)
+		f.Close()
+		if err != nil {
+			return err
+		}
+	}
+
+	s := NewSupervisor(agreementId, execPath, dir, state.Env, pd)
+	if err := s.Start(); err != nil {
+		return err
+	}
+
+	addSupervisor(agreementId, s)
+	return nil
+}
+
+func (w *ProcessWorker) stopProcess(agreementId string) {
+	if s := removeSupervisor(agreementId); s != nil {
+		s.Stop(PROCESS_STOP_TIMEOUT_S * time.Second)
+	}
+
+	for _, dir := range []string{path.Join(w.Config.Edge.ServiceStorage, agreementId), w.executableDir(agreementId)} {
+		if err := os.RemoveAll(dir); err != nil {
+			glog.Errorf(pwlog(fmt.Sprintf("unable to remove directory %v, error %v", dir, err)))
+		}
+	}
+}
+
+// Returns the directory that holds the executable and the state of the process of an agreement.
+func (w *ProcessWorker) executableDir(agreementId string) string {
+	return path.Join(w.Config.GetProcessExecutablePath(), agreementId)
+}
+
+// Create the executable directory of an agreement. The storage directory belongs to the process user, which is shared
+// by the process deployments, so the executable is kept where only the agent can write. The process user can only
+// run it, and the environment in the process state, which holds the user input, is only readable by the agent.
+func (w *ProcessWorker) createExecutableDir(agreementId string) (string, error) {
+	base := w.Config.GetProcessExecutablePath()
+	dir := w.executableDir(agreementId)
+	if err := os.MkdirAll(base, 0711); err != nil {
+		return "", fmt.Errorf("unable to create directory %v, error %v", base, err)
+	} else if err := os.RemoveAll(dir); err != nil {
+		return "", fmt.Errorf("unable to remove directory %v, error %v", dir, err)
+	} else if err := os.Mkdir(dir, 0711); err != nil {
+		return "", fmt.Errorf("unable to create directory %v, error %v", dir, err)
+	}
+	return dir, nil
+}
+
+// Verify that the process follows the node's admission policy. A process runs on the host, so it is not confined
+// to the allowed bind paths, it uses the host network and it has no seccomp or AppArmor profile of its own. A node
+// whose policy has one of these rules does not run processes. An executable from a URL must come from one of the
+// allowed registries, while an executable from the model management system comes from the node's own org.
+func CheckAdmission(ap *config.AdmissionPolicy, name string, pd *persistence.ProcessDeploymentConfig) error {
+	if ap == nil || !ap.IsConfigured() {
+		return nil
+	}
+
+	reject := func(rule string, reason string) error {
+		return &containermessage.AdmissionError{Service: name, Rule: rule, Reason: reason}
+	}
+
+	if pd.Executable.URL != "" {
+		if u, err := url.Parse(pd.Executable.URL); err != nil {
+			return reject(containermessage.ADMISSION_RULE_REGISTRY, fmt.Sprintf("executable url %v is not valid, error %v", pd.Executable.URL, err))
+		} else if err := containermessage.CheckRegistryAdmission(ap, name, "executable "+pd.Executable.URL, u.Host+u.Path); err != nil {
+			return err
+		}
+	}
+
+	if ap.ForbidPrivileged {
+		if cred, err := lookupCredential(processUser(pd)); err == nil && ((cred == nil && os.Geteuid() == 0) || (cred != nil && cred.Uid == 0)) {
+			return reject(containermessage.ADMISSION_RULE_PRIVILEGED, "the process runs as root")
+		}
+	}
+
+	if ap.ForbidHostNetwork {
+		return reject(containermessage.ADMISSION_RULE_HOST_NETWORK, "a process uses the host network")
+	} else if len(ap.AllowedBindPaths) != 0 {
+		return reject(containermessage.ADMISSION_RULE_BIND_PATHS, fmt.Sprintf("a process is not confined to the allowed bind paths %v", ap.AllowedBindPaths))
+	} else if ap.ForbidUnconfined {
+		return reject(containermessage.ADMISSION_RULE_UNCONFINED, "a process runs without seccomp or AppArmor confinement")
+	}
+
+	return containermessage.CheckMemoryAdmission(ap, name, "max_memory_mb", pd.MaxMemoryMB)
+}
+
+// Returns the environment of the process. The variables of the deployment config are overridden by the variables that
+// the agent sets for the service, e.g. the user inputs and the HZN_ platform variables.
+func ProcessEnvironment(pd *persistence.ProcessDeploymentConfig, additions map[string]string) []string {
+	env := map[string]string{"PATH": PROCESS_DEFAULT_PATH}
+	for _, e := range pd.Env {
+		for i := 0; i < len(e); i++ {
+			if e[i] == '=' {
+				env[e[:i]] = e[i+1:]
+				break
+			}
+		}
+	}
+	for k, v := range additions {
+		env[k] = v
+	}
+
+	res := make([]string, 0, len(env))
+	for k, v := range env {
+		res = append(res, k+"="+v)
+	}
+	sort.Strings(res)
+	return res
+}
+
+func isProcessDeployment(depStr string) bool {
+	dep := make(map[string]interface{})
+	if err := json.Unmarshal([]byte(depStr), &dep); err != nil {
+		return false
+	}
+	return persistence.IsProcess(dep)
+}
+
+var pwlog = func(v interface{}) string {
+	return fmt.Sprintf("Process Worker: %v", v)
+}
@@ -0,0 +1,356 @@
+package process
+
+import (
+	"errors"
+	"fmt"
+	"github.com/golang/glog"
+	"github.com/open-horizon/anax/persistence"
+	"os"
+	"os/exec"
+	"os/user"
+	"path"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+)
+
+// The states of a supervised process.
+const (
+	PROCESS_RUNNING    = "running"
+	PROCESS_RESTARTING = "restarting" // the process exited and is waiting out the restart backoff
+	PROCESS_FAILED     = "failed"     // the process exited more times than the restart policy allows
+	PROCESS_STOPPED    = "stopped"
+)
+
+// The file in the storage directory of the agreement that receives the output of the process.
+const PROCESS_LOG_NAME = "process.log"
+
+// The user that a process runs as when its deployment does not name one and the agent runs as root.
+const PROCESS_DEFAULT_USER = "nobody"
+
+// The time that a stopped process is given to exit before it is killed.
+const PROCESS_STOP_TIMEOUT_S = 10
+
+type ProcessStatus struct {
+	State     string `json:"state"`
+	Pid       int    `json:"pid"`
+	Restarts  int    `json:"restarts"`
+	Started   int64  `json:"started"`
+	LastError string `json:"last_error,omitempty"`
+}
+
+func (s ProcessStatus) String() string {
+	return fmt.Sprintf("State: %v, Pid: %v, Restarts: %v, Started: %v, LastError: %v", s.State, s.Pid, s.Restarts, s.Started, s.LastError)
+}
+
+var errStopping = errors.New("the process is being stopped")
+
+// A Supervisor runs the executable of an agreement and restarts it according to the restart policy of the deployment
+// config when it exits.
+type Supervisor struct {
+	name       string
+	path       string
+	dir        string
+	env        []string
+	deployment *persistence.ProcessDeploymentConfig
+	lock       sync.Mutex
+	cmd        *exec.Cmd
+	logFile    *os.File
+	startTime  time.Time
+	status     ProcessStatus
+	stopping   bool
+	stopCh     chan bool
+	doneCh     chan bool
+}
+
+func NewSupervisor(name string, execPath string, dir string, env []string, pd *persistence.ProcessDeploymentConfig) *Supervisor {
+	return &Supervisor{
+		name:       name,
+		path:       execPath,
+		dir:        dir,
+		env:        env,
+		deployment: pd,
+		stopCh:     make(chan bool),
+		doneCh:     make(chan bool),
+	}
+}
+
+func (s *Supervisor) String() string {
+	return fmt.Sprintf("Name: %v, Path: %v, Status: {%v}", s.name, s.path, s.Status())
+}
+
+// Start the process. An error starting the process the first time is returned rather than retried, because it means
+// that the deployment cannot run on this node.
+func (s *Supervisor) Start() error {
+	if err := s.launch(); err != nil {
+		s.setFailed(err.Error())
+		close(s.doneCh)
+		return err
+	}
+	go s.supervise()
+	return nil
+}
+
+// Stop the process and the supervision of it. The process group is sent SIGTERM and then SIGKILL if it has not exited
+// within the timeout.
+func (s *Supervisor) Stop(timeout time.Duration) {
+	s.lock.Lock()
+	if s.stopping {
+		s.lock.Unlock()
+		<-s.doneCh
+		return
+	}
+	s.stopping = true
+	pid := s.status.Pid
+	close(s.stopCh)
+	s.lock.Unlock()
+
+	if pid != 0 {
+		syscall.Kill(-pid, syscall.SIGTERM)
+	}
+
+	select {
+	case <-s.doneCh:
+	case <-time.After(timeout):
+		glog.Warningf(pwlog(fmt.Sprintf("process %v for %v did not exit within %v, killing it", pid, s.name, timeout)))
+		if pid != 0 {
+			syscall.Kill(-pid, syscall.SIGKILL)
+		}
+		<-s.doneCh
+	}
+
+	s.lock.Lock()
+	s.status.State = PROCESS_STOPPED
+	s.status.Pid = 0
+	s.lock.Unlock()
+}
+
+func (s *Supervisor) Status() ProcessStatus {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	return s.status
+}
+
+// The process is healthy unless it has used up its restarts. A process waiting to be restarted is still healthy.
+func (s *Supervisor) IsHealthy() bool {
+	return s.Status().State != PROCESS_FAILED
+}
+
+func (s *Supervisor) setFailed(reason string) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	s.status.State = PROCESS_FAILED
+	s.status.Pid = 0
+	s.status.LastError = reason
+}
+
+// Start a new instance of the process.
+func (s *Supervisor) launch() error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	if s.stopping {
+		return errStopping
+	}
+
+	cred, err := lookupCredential(processUser(s.deployment))
+	if err != nil {
+		return err
+	}
+
+	logFile, err := os.OpenFile(path.Join(s.dir, PROCESS_LOG_NAME), os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0640)
+	if err != nil {
+		return fmt.Errorf("unable to open the process log, error %v", err)
+	}
+
+	cmd := exec.Command(s.path, s.deployment.Args...)
+	cmd.Dir = s.dir
+	cmd.Env = s.env
+	cmd.Stdout = logFile
+	cmd.Stderr = logFile
+	cmd.SysProcAttr = sysProcAttr(cred)
+
+	if err := startWithLimits(cmd, s.deployment); err != nil {
+		logFile.Close()
+		return fmt.Errorf("unable to start %v, error %v", s.path, err)
+	}
+
+	glog.V(3).Infof(pwlog(fmt.Sprintf("started process %v for %v", cmd.Process.Pid, s.name)))
+
+	s.cmd = cmd
+	s.logFile = logFile
+	s.startTime = time.Now()
+	s.status.State = PROCESS_RUNNING
+	s.status.Pid = cmd.Process.Pid
+	s.status.Started = s.startTime.Unix()
+	return nil
+}
+
+// Wait for the current instance of the process to exit and restart it with an increasing delay between restarts. The
+// delay is reset when the process stays up for longer than the maximum delay.
+func (s *Supervisor) supervise() {
+	defer close(s.doneCh)
+
+	policy := s.deployment.Restart
+	failures := 0
+
+	for {
+		s.lock.Lock()
+		cmd, logFile, started := s.cmd, s.logFile, s.startTime
+		s.lock.Unlock()
+
+		exitErr := cmd.Wait()
+		logFile.Close()
+
+		reason := "exited"
+		if exitErr != nil {
+			reason = exitErr.Error()
+		}
+
+		s.lock.Lock()
+		if s.stopping {
+			s.lock.Unlock()
+			return
+		}
+		s.status.Pid = 0
+		s.status.LastError = reason
+		s.lock.Unlock()
+
+		if time.Since(started) > time.Duration(policy.GetMaxBackoffS())*time.Second {
+			failures = 0
+		}
+
+		// Keep trying to restart the process until it starts or the restart policy gives up.
+		for {
+			failures += 1
+			if policy.MaxRetries != 0 && failures > policy.MaxRetries {
+				glog.Errorf(pwlog(fmt.Sprintf("process for %v %v, giving up after %v restarts", s.name, reason, policy.MaxRetries)))
+				s.setFailed(reason)
+				return
+			}
+
+			delay := Backoff(policy, failures)
+			glog.Warningf(pwlog(fmt.Sprintf("process for %v %v, restarting in %v", s.name, reason, delay)))
+
+			s.lock.Lock()
+			s.status.State = PROCESS_RESTARTING
+			s.lock.Unlock()
+
+			select {
+			case <-s.stopCh:
+				return
+			case <-time.After(delay):
+			}
+
+			if err := s.launch(); err == errStopping {
+				return
+			} else if err != nil {
+				reason = err.Error()
+				s.lock.Lock()
+				s.status.LastError = reason
+				s.lock.Unlock()
+				continue
+			}
+
+			s.lock.Lock()
+			s.status.Restarts += 1
+			s.lock.Unlock()
+			break
+		}
+	}
+}
+
+// Returns the delay before the restart that follows the given number of consecutive failures. The delay doubles with
+// each failure, starting at the initial backoff, and never exceeds the maximum backoff.
+func Backoff(policy persistence.ProcessRestartPolicy, failures int) time.Duration {
+	delay := time.Duration(policy.GetInitialBackoffS()) * time.Second
+	max := time.Duration(policy.GetMaxBackoffS()) * time.Second
+	for i := 1; i < failures && delay < max; i++ {
+		delay *= 2
+	}
+	if delay > max {
+		delay = max
+	}
+	return delay
+}
+
+// Returns the user[:group] that the process runs as. When the deployment does not name one, an agent that runs as root
+// runs the process as an unprivileged user, any other agent runs it as its own user.
+func processUser(pd *persistence.ProcessDeploymentConfig) string {
+	if pd.User == "" && os.Geteuid() == 0 {
+		return PROCESS_DEFAULT_USER
+	}
+	return pd.User
+}
+
+// Returns the credential for a user[:group] spec, where the user and group are names or ids. An empty spec returns nil
+// so that the process runs as the agent's user.
+func lookupCredential(spec string) (*syscall.Credential, error) {
+	if spec == "" {
+		return nil, nil
+	}
+
+	parts := strings.SplitN(spec, ":", 2)
+	u, err := user.Lookup(parts[0])
+	if err != nil {
+		if u, err = user.LookupId(parts[0]); err != nil {
+			return nil, fmt.Errorf("unable to find user %v, error %v", parts[0], err)
+		}
+	}
+
+	gid := u.Gid
+	if len(parts) == 2 {
+		g, err := user.LookupGroup(parts[1])
+		if err != nil {
+			if g, err = user.LookupGroupId(parts[1]); err != nil {
+				return nil, fmt.Errorf("unable to find group %v, error %v", parts[1], err)
+			}
+		}
+		gid = g.Gid
+	}
+
+	uidNum, err := strconv.ParseUint(u.Uid, 10, 32)
+	if err != nil {
+		return nil, fmt.Errorf("user %v has a non-numeric id %v", parts[0], u.Uid)
+	}
+	gidNum, err := strconv.ParseUint(gid, 10, 32)
+	if err != nil {
+		return nil, fmt.Errorf("group of %v has a non-numeric id %v", spec, gid)
+	}
+	return &syscall.Credential{Uid: uint32(uidNum), Gid: uint32(gidNum)}, nil
+}
+
+// The supervisors of the running agreements, so that the status of the processes can be reported.
+var supervisors = make(map[string]*Supervisor)
+var supervisorsLock sync.Mutex
+
+func addSupervisor(agreementId string, s *Supervisor) {
+	supervisorsLock.Lock()
+	defer supervisorsLock.Unlock()
+	supervisors[agreementId] = s
+}
+
+func removeSupervisor(agreementId string) *Supervisor {
+	supervisorsLock.Lock()
+	defer supervisorsLock.Unlock()
+	s := supervisors[agreementId]
+	delete(supervisors, agreementId)
+	return s
+}
+
+func getSupervisor(agreementId string) *Supervisor {
+	supervisorsLock.Lock()
+	defer supervisorsLock.Unlock()
+	return supervisors[agreementId]
+}
+
+// Returns the status of the process for an agreement, or nil if no process is running for it.
+func GetStatus(agreementId string) *ProcessStatus {
+	if s := getSupervisor(agreementId); s != nil {
+		status := s.Status()
+		return &status
+	}
+	return nil
+}
@@ -0,0 +1,249 @@
+// +build unit
+
+package process
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"github.com/open-horizon/anax/config"
+	"github.com/open-horizon/anax/containermessage"
+	"github.com/open-horizon/anax/persistence"
+	"io/ioutil"
+	"os"
+	"path"
+	"runtime"
+	"testing"
+	"time"
+)
+
+func Test_Backoff(t *testing.T) {
+
+	policy := persistence.ProcessRestartPolicy{InitialBackoffS: 2, MaxBackoffS: 10}
+	expected := []time.Duration{2 * time.Second, 4 * time.Second, 8 * time.Second, 10 * time.Second, 10 * time.Second}
+	for i, e := range expected {
+		if d := Backoff(policy, i+1); d != e {
+			t.Errorf("expected a backoff of %v after %v failures, got %v", e, i+1, d)
+		}
+	}
+
+	// the defaults apply when the policy is empty
+	if d := Backoff(persistence.ProcessRestartPolicy{}, 1); d != persistence.DEFAULT_PROCESS_INITIAL_BACKOFF*time.Second {
+		t.Errorf("expected the default initial backoff, got %v", d)
+	} else if d := Backoff(persistence.ProcessRestartPolicy{}, 100); d != persistence.DEFAULT_PROCESS_MAX_BACKOFF*time.Second {
+		t.Errorf("expected the default max backoff, got %v", d)
+	}
+}
+
+func Test_ProcessEnvironment(t *testing.T) {
+
+	pd := &persistence.ProcessDeploymentConfig{Env: []string{"MODE=fast", "HZN_ORGID=wrong", "OPTS=a=b"}}
+	env := ProcessEnvironment(pd, map[string]string{"HZN_ORGID": "myorg"})
+
+	expected := []string{"HZN_ORGID=myorg", "MODE=fast", "OPTS=a=b", "PATH=" + PROCESS_DEFAULT_PATH}
+	if len(env) != len(expected) {
+		t.Fatalf("expected %v, got %v", expected, env)
+	}
+	for i := range expected {
+		if env[i] != expected[i] {
+			t.Errorf("expected %v, got %v", expected, env)
+		}
+	}
+}
+
+func Test_SaveExecutable(t *testing.T) {
+
+	dir, err := ioutil.TempDir("", "process-")
+	if err != nil {
+		t.Fatalf("unable to create temp dir, %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	content := []byte("#!/bin/sh\nexit 0\n")
+	hash := sha256.Sum256(content)
+
+	if _, err := SaveExecutable(bytes.NewReader(content), "0000000000000000000000000000000000000000000000000000000000000000", dir); err == nil {
+		t.Errorf("expected an error for the wrong hash")
+	} else if files, _ := ioutil.ReadDir(dir); len(files) != 0 {
+		t.Errorf("an executable that failed verification was left behind, %v", files)
+	}
+
+	if p, err := SaveExecutable(bytes.NewReader(content), hex.EncodeToString(hash[:]), dir); err != nil {
+		t.Errorf("unexpected error %v", err)
+	} else if info, err := os.Stat(p); err != nil {
+		t.Errorf("the executable was not saved, %v", err)
+	} else if info.Mode()&0100 == 0 {
+		t.Errorf("the executable is not executable, mode %v", info.Mode())
+	}
+}
+
+func Test_processUser(t *testing.T) {
+
+	if u := processUser(&persistence.ProcessDeploymentConfig{User: "1000:1000"}); u != "1000:1000" {
+		t.Errorf("expected the deployment user, got %v", u)
+	}
+
+	u := processUser(&persistence.ProcessDeploymentConfig{})
+	if os.Geteuid() == 0 && u != PROCESS_DEFAULT_USER {
+		t.Errorf("expected %v for an agent that runs as root, got %v", PROCESS_DEFAULT_USER, u)
+	} else if os.Geteuid() != 0 && u != "" {
+		t.Errorf("expected the agent's user, got %v", u)
+	}
+}
+
+func Test_Supervisor_restart(t *testing.T) {
+
+	dir, script := writeScript(t, "echo started\nexit 1\n")
+	defer os.RemoveAll(dir)
+
+	pd := &persistence.ProcessDeploymentConfig{Restart: persistence.ProcessRestartPolicy{MaxRetries: 2, InitialBackoffS: 1, MaxBackoffS: 1}}
+	s := NewSupervisor("ag1", script, dir, ProcessEnvironment(pd, nil), pd)
+	if err := s.Start(); err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+
+	// The process fails every time, so it is started 3 times and then given up on.
+	waitFor(t, 10*time.Second, func() bool { return s.Status().State == PROCESS_FAILED })
+
+	if status := s.Status(); status.Restarts != 2 {
+		t.Errorf("expected 2 restarts, got %v", status)
+	} else if s.IsHealthy() {
+		t.Errorf("a failed process should not be healthy")
+	}
+
+	if out, err := ioutil.ReadFile(path.Join(dir, PROCESS_LOG_NAME)); err != nil {
+		t.Errorf("unable to read the process log, %v", err)
+	} else if string(out) != "started\nstarted\nstarted\n" {
+		t.Errorf("unexpected process log %q", string(out))
+	}
+
+	s.Stop(time.Second)
+}
+
+func Test_Supervisor_stop(t *testing.T) {
+
+	dir, script := writeScript(t, "exec sleep 60\n")
+	defer os.RemoveAll(dir)
+
+	pd := &persistence.ProcessDeploymentConfig{}
+	s := NewSupervisor("ag1", script, dir, ProcessEnvironment(pd, nil), pd)
+	if err := s.Start(); err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+
+	status := s.Status()
+	if status.State != PROCESS_RUNNING || status.Pid == 0 || !s.IsHealthy() {
+		t.Errorf("expected a running process, got %v", status)
+	}
+
+	s.Stop(5 * time.Second)
+
+	if status := s.Status(); status.State != PROCESS_STOPPED || status.Restarts != 0 {
+		t.Errorf("expected a stopped process without restarts, got %v", status)
+	}
+}
+
+func Test_Supervisor_limits(t *testing.T) {
+
+	if runtime.GOOS != "linux" {
+		t.Skip("the open files limit can only be set on linux")
+	}
+
+	dir, script := writeScript(t, "ulimit -n\n")
+	defer os.RemoveAll(dir)
+
+	// The limit is in place before the script runs, so the script sees it. The script runs as the user of the test,
+	// whose limits can be set without privileges.
+	pd := &persistence.ProcessDeploymentConfig{User: fmt.Sprintf("%v:%v", os.Getuid(), os.Getgid()), MaxOpenFiles: 64, Restart: persistence.ProcessRestartPolicy{MaxRetries: 1, InitialBackoffS: 60}}
+	s := NewSupervisor("ag1", script, dir, ProcessEnvironment(pd, nil), pd)
+	if err := s.Start(); err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	defer s.Stop(time.Second)
+
+	waitFor(t, 5*time.Second, func() bool { return s.Status().State == PROCESS_RESTARTING })
+
+	if out, err := ioutil.ReadFile(path.Join(dir, PROCESS_LOG_NAME)); err != nil {
+		t.Errorf("unable to read the process log, %v", err)
+	} else if string(out) != "64\n" {
+		t.Errorf("expected the open files limit 64, got %q", string(out))
+	}
+}
+
+func Test_Supervisor_startError(t *testing.T) {
+
+	dir, err := ioutil.TempDir("", "process-")
+	if err != nil {
+		t.Fatalf("unable to create temp dir, %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	pd := &persistence.ProcessDeploymentConfig{}
+	s := NewSupervisor("ag1", path.Join(dir, "missing"), dir, nil, pd)
+	if err := s.Start(); err == nil {
+		t.Errorf("expected an error starting a missing executable")
+	} else if s.Status().State != PROCESS_FAILED {
+		t.Errorf("expected a failed process, got %v", s.Status())
+	}
+
+	// stopping a process that never started returns
+	s.Stop(time.Second)
+}
+
+func writeScript(t *testing.T, body string) (string, string) {
+	dir, err := ioutil.TempDir("", "process-")
+	if err != nil {
+		t.Fatalf("unable to create temp dir, %v", err)
+	}
+	// An agent that runs as root runs the script as an unprivileged user, which has to reach the directory.
+	if err := os.Chmod(dir, 0755); err != nil {
+		t.Fatalf("unable to open up temp dir, %v", err)
+	}
+	script := path.Join(dir, EXECUTABLE_NAME)
+	if err := ioutil.WriteFile(script, []byte("#!/bin/sh\n"+body), 0755); err != nil {
+		t.Fatalf("unable to write script, %v", err)
+	}
+	return dir, script
+}
+
+func waitFor(t *testing.T, timeout time.Duration, check func() bool) {
+	for end := time.Now().Add(timeout); time.Now().Before(end); time.Sleep(50 * time.Millisecond) {
+		if check() {
+			return
+		}
+	}
+	t.Fatalf("timed out after %v", timeout)
+}
+
+func Test_CheckAdmission(t *testing.T) {
+
+	ap := &config.AdmissionPolicy{AllowedRegistries: []string{"example.com/bin"}, MaxMemoryMB: 64}
+
+	ok := []*persistence.ProcessDeploymentConfig{
+		{Executable: persistence.ProcessArtifact{URL: "https://example.com/bin/myservice"}, MaxMemoryMB: 32},
+		{Executable: persistence.ProcessArtifact{ObjectType: "bin", ObjectID: "myservice"}, MaxMemoryMB: 64},
+	}
+	for _, pd := range ok {
+		if err := CheckAdmission(ap, "ag1", pd); err != nil {
+			t.Errorf("unexpected error for %v, %v", pd, err)
+		}
+	}
+
+	rejected := map[string]*config.AdmissionPolicy{
+		containermessage.ADMISSION_RULE_REGISTRY:     {AllowedRegistries: []string{"example.com/other"}},
+		containermessage.ADMISSION_RULE_MAX_MEMORY:   {MaxMemoryMB: 16},
+		containermessage.ADMISSION_RULE_HOST_NETWORK: {ForbidHostNetwork: true},
+		containermessage.ADMISSION_RULE_BIND_PATHS:   {AllowedBindPaths: []string{"/var/data"}},
+		containermessage.ADMISSION_RULE_UNCONFINED:   {ForbidUnconfined: true},
+		containermessage.ADMISSION_RULE_PRIVILEGED:   {ForbidPrivileged: true},
+	}
+	for rule, ap := range rejected {
+		pd := &persistence.ProcessDeploymentConfig{Executable: persistence.ProcessArtifact{URL: "https://example.com/bin/myservice"}, User: "0", MaxMemoryMB: 32}
+		if err := CheckAdmission(ap, "ag1", pd); err == nil {
+			t.Errorf("expected the process to be rejected by %v", rule)
+		} else if aerr, ok := err.(*containermessage.AdmissionError); !ok || aerr.Rule != rule {
+			t.Errorf("expected the process to be rejected by %v, got %v", rule, err)
+		}
+	}
+}
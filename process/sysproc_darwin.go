@@ -0,0 +1,40 @@
+// +build darwin
+
+package process
+
+import (
+	"fmt"
+	"github.com/open-horizon/anax/persistence"
+	"os/exec"
+	"syscall"
+)
+
+func sysProcAttr(cred *syscall.Credential) *syscall.SysProcAttr {
+	return &syscall.SysProcAttr{
+		Setpgid:    true,
+		Credential: cred,
+	}
+}
+
+// A process cannot be started stopped on macOS, so the priority is set as soon as the process exists.
+func startWithLimits(cmd *exec.Cmd, pd *persistence.ProcessDeploymentConfig) error {
+	if err := cmd.Start(); err != nil {
+		return err
+	} else if err := setLimits(cmd.Process.Pid, pd); err != nil {
+		syscall.Kill(-cmd.Process.Pid, syscall.SIGKILL)
+		cmd.Wait()
+		return fmt.Errorf("unable to set the resource limits, error %v", err)
+	}
+	return nil
+}
+
+// The limits of another process cannot be set on macOS.
+func setLimits(pid int, pd *persistence.ProcessDeploymentConfig) error {
+	if pd.MaxMemoryMB > 0 || pd.MaxOpenFiles > 0 {
+		return fmt.Errorf("max_memory_mb and max_open_files are not supported on this platform")
+	}
+	if pd.Nice != 0 {
+		return syscall.Setpriority(syscall.PRIO_PROCESS, pid, pd.Nice)
+	}
+	return nil
+}
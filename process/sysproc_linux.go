@@ -0,0 +1,81 @@
+// +build linux
+
+package process
+
+import (
+	"fmt"
+	"github.com/open-horizon/anax/persistence"
+	"golang.org/x/sys/unix"
+	"os/exec"
+	"runtime"
+	"syscall"
+	"unsafe"
+)
+
+// The process runs in its own process group so that it can be stopped together with its children, and it is sent
+// SIGTERM if the agent exits so that it is not left running without supervision.
+func sysProcAttr(cred *syscall.Credential) *syscall.SysProcAttr {
+	return &syscall.SysProcAttr{
+		Setpgid:    true,
+		Pdeathsig:  syscall.SIGTERM,
+		Credential: cred,
+	}
+}
+
+// Start the process stopped at the exec of the executable, so that the limits are in place before the executable runs
+// any code. The process is traced until then, and only the thread that started it can let it continue.
+func startWithLimits(cmd *exec.Cmd, pd *persistence.ProcessDeploymentConfig) error {
+	runtime.LockOSThread()
+	defer runtime.UnlockOSThread()
+
+	cmd.SysProcAttr.Ptrace = true
+	if err := cmd.Start(); err != nil {
+		return err
+	}
+	pid := cmd.Process.Pid
+
+	kill := func(err error) error {
+		syscall.Kill(-pid, syscall.SIGKILL)
+		cmd.Wait()
+		return err
+	}
+
+	var ws unix.WaitStatus
+	if _, err := unix.Wait4(pid, &ws, 0, nil); err != nil {
+		return kill(fmt.Errorf("unable to wait for the process to start, error %v", err))
+	} else if !ws.Stopped() {
+		return kill(fmt.Errorf("the process did not stop at its start, status %v", ws))
+	} else if err := setLimits(pid, pd); err != nil {
+		return kill(fmt.Errorf("unable to set the resource limits, error %v", err))
+	} else if err := unix.PtraceDetach(pid); err != nil {
+		return kill(fmt.Errorf("unable to continue the process, error %v", err))
+	}
+	return nil
+}
+
+func setLimits(pid int, pd *persistence.ProcessDeploymentConfig) error {
+	if pd.MaxMemoryMB > 0 {
+		limit := uint64(pd.MaxMemoryMB) * 1024 * 1024
+		if err := prlimit(pid, unix.RLIMIT_AS, limit); err != nil {
+			return err
+		}
+	}
+	if pd.MaxOpenFiles > 0 {
+		if err := prlimit(pid, unix.RLIMIT_NOFILE, pd.MaxOpenFiles); err != nil {
+			return err
+		}
+	}
+	if pd.Nice != 0 {
+		return syscall.Setpriority(syscall.PRIO_PROCESS, pid, pd.Nice)
+	}
+	return nil
+}
+
+// Set the soft and hard limit of a resource of another process.
+func prlimit(pid int, resource int, limit uint64) error {
+	rlimit := unix.Rlimit{Cur: limit, Max: limit}
+	if _, _, errno := unix.RawSyscall6(unix.SYS_PRLIMIT64, uintptr(pid), uintptr(resource), uintptr(unsafe.Pointer(&rlimit)), 0, 0, 0); errno != 0 {
+		return errno
+	}
+	return nil
+}
@@ -9,12 +9,15 @@ import (
 	"github.com/open-horizon/anax/abstractprotocol"
 	"github.com/open-horizon/anax/api"
 	"github.com/open-horizon/anax/config"
+	"github.com/open-horizon/anax/containermessage"
 	"github.com/open-horizon/anax/eventlog"
 	"github.com/open-horizon/anax/events"
 	"github.com/open-horizon/anax/exchange"
 	"github.com/open-horizon/anax/i18n"
 	"github.com/open-horizon/anax/persistence"
 	"github.com/open-horizon/anax/policy"
+	"github.com/open-horizon/anax/process"
+	"github.com/open-horizon/anax/wasm"
 	"github.com/open-horizon/anax/worker"
 	"strings"
 	"time"
@@ -26,6 +29,7 @@ const (
 	EL_PROD_NODE_REJECTED_PROPOSAL_MSG = "Node received Proposal message using agreement %v for service %v/%v from the agbot %v."
 	EL_PROD_NODE_REJECTED_PROPOSAL     = "Node rejected the proposal for service %v/%v."
 	EL_PROD_ERR_HANDLE_PROPOSAL        = "Error handling proposal for service %v/%v. Error: %v"
+	EL_PROD_NODE_ADMISSION_REJECTED    = "Node rejected the proposal for service %v/%v because of the node admission policy: %v"
 )
 
 // This is does nothing useful at run time.
@@ -42,6 +46,7 @@ func MarkI18nMessages() {
 	msgPrinter.Sprintf(EL_PROD_NODE_REJECTED_PROPOSAL_MSG)
 	msgPrinter.Sprintf(EL_PROD_NODE_REJECTED_PROPOSAL)
 	msgPrinter.Sprintf(EL_PROD_ERR_HANDLE_PROPOSAL)
+	msgPrinter.Sprintf(EL_PROD_NODE_ADMISSION_REJECTED)
 }
 
 func CreateProducerPH(name string, cfg *config.HorizonConfig, db *bolt.DB, pm *policy.PolicyManager, ec exchange.ExchangeContext) ProducerProtocolHandler {
@@ -278,6 +283,20 @@ func (w *BaseProducerProtocolHandler) HandleProposal(ph abstractprotocol.Protoco
 		} else if messageTarget, err := exchange.CreateMessageTarget(exchangeMsg.AgbotId, nil, exchangeMsg.AgbotPubKey, ""); err != nil {
 			glog.Errorf(BPPHlogString(w.Name(), fmt.Sprintf("error creating message target: %v", err)))
 			err_log_event = fmt.Sprintf("Error creating message target: %v", err)
+		} else if err := w.checkAdmission(tcPolicy); err != nil {
+			handled = true
+			glog.Errorf(BPPHlogString(w.Name(), fmt.Sprintf("rejecting proposal %v, %v", proposal.AgreementId(), err)))
+			w.rejectProposal(ph, proposal, messageTarget)
+			eventlog.LogAgreementEvent2(
+				w.db,
+				persistence.SEVERITY_ERROR,
+				persistence.NewMessageMeta(EL_PROD_NODE_ADMISSION_REJECTED, worg, wls, err.Error()),
+				persistence.EC_ERROR_ADMISSION_POLICY,
+				proposal.AgreementId(),
+				persistence.WorkloadInfo{URL: wls, Org: worg, Version: wversion, Arch: warch},
+				ConvertToServiceSpecs(tcPolicy.APISpecs),
+				proposal.ConsumerId(),
+				proposal.Protocol())
 		} else {
 			handled = true
 			producerPol, err := persistence.FindNodePolicy(w.db)
@@ -324,6 +343,47 @@ func (w *BaseProducerProtocolHandler) HandleProposal(ph abstractprotocol.Protoco
 	return handled, nil, nil
 }
 
+// Verify that the deployments in the terms and conditions follow the node admission policy. Native container,
+// process and wasm deployments are checked, other deployments, e.g. helm charts, are not covered by the admission
+// policy. A native deployment is checked with its deployment overrides applied, the way the container worker runs it.
+func (w *BaseProducerProtocolHandler) checkAdmission(tcPolicy *policy.Policy) error {
+	ap := &w.config.Edge.AdmissionPolicy
+	for _, wl := range tcPolicy.Workloads {
+		if wl.Deployment == "" {
+			continue
+		} else if dd, err := containermessage.GetNativeDeployment(wl.Deployment); err == nil {
+			if wl.DeploymentOverrides != "" {
+				overrideDD := new(containermessage.DeploymentDescription)
+				if err := json.Unmarshal([]byte(wl.DeploymentOverrides), overrideDD); err != nil {
+					return fmt.Errorf("unable to unmarshal the deployment overrides %v of service %v, error %v", wl.DeploymentOverrides, wl.WorkloadURL, err)
+				}
+				dd.Overrides = overrideDD.Services
+			}
+			if err := dd.CheckAdmission(ap, w.config.Edge.ServiceStorage); err != nil {
+				return err
+			}
+		} else if pd, err := persistence.GetProcessDeployment(wl.Deployment); err == nil {
+			if err := process.CheckAdmission(ap, wl.WorkloadURL, pd); err != nil {
+				return err
+			}
+		} else if wd, err := persistence.GetWasmDeployment(wl.Deployment); err == nil {
+			if err := wasm.CheckAdmission(ap, wl.WorkloadURL, wd, w.config.Edge.ServiceStorage); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// Tell the agbot that the proposal is rejected. The policy manager has not counted the agreement yet, so there is
+// nothing to undo.
+func (w *BaseProducerProtocolHandler) rejectProposal(ph abstractprotocol.ProtocolHandler, proposal abstractprotocol.Proposal, messageTarget interface{}) {
+	reply := abstractprotocol.NewProposalReply(ph.Name(), proposal.Version(), proposal.AgreementId(), w.ec.GetExchangeId())
+	if err := abstractprotocol.SendProtocolMessage(messageTarget, reply, w.sendMessage); err != nil {
+		glog.Errorf(BPPHlogString(w.Name(), fmt.Sprintf("error sending rejection of proposal %v, error %v", proposal.AgreementId(), err)))
+	}
+}
+
 // This function gets the pattern and workload's signing keys and save them to anax
 func (w *BaseProducerProtocolHandler) saveSigningKeys(pol *policy.Policy) error {
 	// do nothing if the config does not allow using the certs from the org on the exchange
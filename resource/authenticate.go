@@ -13,10 +13,9 @@ import (
 // It implements the security.Authentication interface. It is also called by the embedded ESS to
 // provide credentials for the node to access the CSS (over the internal SPI).
 type FSSAuthenticate struct {
-	nodeOrg   string
-	nodeID    string
-	nodeToken string
-	AuthMgr   *AuthenticationManager
+	nodeOrg string
+	nodeID  string
+	AuthMgr *AuthenticationManager
 }
 
 // Start initializes the HorizonAuthenticate plugin.
@@ -76,15 +75,17 @@ func (auth *FSSAuthenticate) Authenticate(request *http.Request) (int, string, s
 }
 
 // KeyandSecretForURL returns an app key and an app secret pair to be used by the ESS when communicating
-// with the specified URL. For ESS to CSS SPI communication, the node id and token is used.
+// with the specified URL. For ESS to CSS SPI communication, the node id and token is used. The token is obtained from
+// the authentication manager on every call so that a rotated node token is picked up without restarting the ESS.
 func (auth *FSSAuthenticate) KeyandSecretForURL(url string) (string, string) {
 
 	glog.V(6).Infof(essALS(fmt.Sprintf("received request for URL %v credentials", url)))
 
 	if strings.HasPrefix(url, common.HTTPCSSURL) {
 		id := common.Configuration.OrgID + "/" + common.Configuration.DestinationType + "/" + common.Configuration.DestinationID
-		glog.V(6).Infof(essALS(fmt.Sprintf("returning credentials %v %v", id, auth.nodeToken)))
-		return id, auth.nodeToken
+		nodeToken := auth.AuthMgr.GetNodeToken()
+		glog.V(6).Infof(essALS(fmt.Sprintf("returning credentials %v %v", id, nodeToken)))
+		return id, nodeToken
 	}
 
 	return "", ""
@@ -9,10 +9,13 @@ import (
 	"io/ioutil"
 	"os"
 	"path"
+	"sync"
 )
 
 type AuthenticationManager struct {
-	AuthPath string
+	AuthPath  string
+	nodeToken string     // The node's exchange token, used by the embedded ESS to login to the CSS.
+	tokenLock sync.Mutex // Protects the node token, which is changed when the token is rotated.
 }
 
 func NewAuthenticationManager(authPath string) *AuthenticationManager {
@@ -21,7 +24,7 @@ func NewAuthenticationManager(authPath string) *AuthenticationManager {
 	}
 }
 
-func (a AuthenticationManager) String() string {
+func (a *AuthenticationManager) String() string {
 	return fmt.Sprintf("Authentication Manager: "+
 		"AuthPath: %v", a.AuthPath)
 }
@@ -30,6 +33,19 @@ func (a *AuthenticationManager) GetCredentialPath(key string) string {
 	return path.Join(a.AuthPath, key)
 }
 
+// Set the node token that the embedded ESS uses for its CSS credentials.
+func (a *AuthenticationManager) SetNodeToken(token string) {
+	a.tokenLock.Lock()
+	defer a.tokenLock.Unlock()
+	a.nodeToken = token
+}
+
+func (a *AuthenticationManager) GetNodeToken() string {
+	a.tokenLock.Lock()
+	defer a.tokenLock.Unlock()
+	return a.nodeToken
+}
+
 // Create a new container authentication credential and write it into the Agent's host file system. The credential file
 // live in a directory named by the key input parameter.
 func (a *AuthenticationManager) CreateCredential(key string, id string, ver string) error {
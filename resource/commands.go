@@ -24,6 +24,25 @@ func NewNodeConfigCommand(msg *events.EdgeRegisteredExchangeMessage) *NodeConfig
 	}
 }
 
+// This worker command is used to tell the worker that the node has a new exchange token.
+type NodeTokenRotatedCommand struct {
+	msg *events.NodeTokenRotatedMessage
+}
+
+func (n NodeTokenRotatedCommand) String() string {
+	return n.ShortString()
+}
+
+func (n NodeTokenRotatedCommand) ShortString() string {
+	return fmt.Sprintf("NodeTokenRotated Command, Msg: %v", n.msg)
+}
+
+func NewNodeTokenRotatedCommand(msg *events.NodeTokenRotatedMessage) *NodeTokenRotatedCommand {
+	return &NodeTokenRotatedCommand{
+		msg: msg,
+	}
+}
+
 // This worker command is used to tell the worker than the node is done shutting down and so it can terminate itself.
 type NodeUnconfigCommand struct {
 	msg *events.NodeShutdownCompleteMessage
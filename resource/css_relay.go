@@ -0,0 +1,124 @@
+package resource
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"github.com/golang/glog"
+	"github.com/open-horizon/anax/config"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/http/httputil"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+// The embedded ESS builds its own HTTP transport, which cannot be given a proxy. When the CSS is reached through the
+// configured proxy, the ESS is pointed at this relay on the loopback interface instead, which forwards its requests
+// to the CSS through the proxy and verifies the CSS certificate. The ESS can only be given a URL, so the relay is
+// authenticated by a random key at the start of the URL path, which only the ESS is given. Other processes on the
+// node cannot use the relay to reach the CSS.
+var cssRelay struct {
+	lock   sync.Mutex
+	server *http.Server
+}
+
+// Start the relay to the CSS and return its URL. The cssCACert is the CA certificate that verifies the CSS, as a file
+// name or the PEM encoded certificate, the way the ESS takes it.
+func startCSSRelay(cssURL string, cssCACert string, proxy config.ProxyConfig) (string, error) {
+	target, err := url.Parse(cssURL)
+	if err != nil {
+		return "", errors.New(fmt.Sprintf("unable to parse CSS URL %v, error %v", cssURL, err))
+	}
+
+	proxyFunc, err := proxy.NewProxyFunc()
+	if err != nil {
+		return "", err
+	}
+
+	certPool, err := x509.SystemCertPool()
+	if err != nil {
+		certPool = x509.NewCertPool()
+	}
+	if cssCACert != "" {
+		certificate := []byte(cssCACert)
+		if strings.HasPrefix(cssCACert, "/") {
+			if certificate, err = ioutil.ReadFile(cssCACert); err != nil {
+				return "", errors.New(fmt.Sprintf("unable to read CSS SSL Certificate file %v, error %v", cssCACert, err))
+			}
+		}
+		certPool.AppendCertsFromPEM(certificate)
+	}
+
+	keyBytes := make([]byte, 32)
+	if _, err := rand.Read(keyBytes); err != nil {
+		return "", errors.New(fmt.Sprintf("unable to generate the CSS relay key, error %v", err))
+	}
+	key := hex.EncodeToString(keyBytes)
+
+	relay := httputil.NewSingleHostReverseProxy(target)
+	director := relay.Director
+	relay.Director = func(req *http.Request) {
+		req.URL.Path = strings.TrimPrefix(req.URL.Path, "/"+key)
+		req.URL.RawPath = ""
+		director(req)
+		req.Host = target.Host
+	}
+	relay.Transport = &http.Transport{
+		Proxy:               proxyFunc,
+		TLSClientConfig:     &tls.Config{RootCAs: certPool, MinVersion: tls.VersionTLS12},
+		TLSHandshakeTimeout: 20 * time.Second,
+	}
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		return "", errors.New(fmt.Sprintf("unable to listen for the CSS relay, error %v", err))
+	}
+
+	server := &http.Server{Handler: authenticateRelay(key, relay)}
+	go func() {
+		if err := server.Serve(listener); err != nil && err != http.ErrServerClosed {
+			glog.Errorf(rmLogString(fmt.Sprintf("CSS relay stopped, error %v", err)))
+		}
+	}()
+
+	cssRelay.lock.Lock()
+	defer cssRelay.lock.Unlock()
+	if cssRelay.server != nil {
+		cssRelay.server.Close()
+	}
+	cssRelay.server = server
+
+	relayURL := "http://" + listener.Addr().String() + "/" + key
+	glog.V(3).Infof(rmLogString(fmt.Sprintf("CSS relay at %v forwards to %v through the proxy %v", listener.Addr().String(), target.Host, proxy.URL)))
+	return relayURL, nil
+}
+
+// Only requests whose path starts with the key of the relay are forwarded to the CSS.
+func authenticateRelay(key string, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		parts := strings.SplitN(strings.TrimPrefix(req.URL.Path, "/"), "/", 2)
+		if subtle.ConstantTimeCompare([]byte(parts[0]), []byte(key)) != 1 {
+			http.Error(w, http.StatusText(http.StatusForbidden), http.StatusForbidden)
+			return
+		}
+		next.ServeHTTP(w, req)
+	})
+}
+
+// Stop the relay to the CSS, if it is running.
+func stopCSSRelay() {
+	cssRelay.lock.Lock()
+	defer cssRelay.lock.Unlock()
+	if cssRelay.server != nil {
+		cssRelay.server.Close()
+		cssRelay.server = nil
+	}
+}
@@ -13,6 +13,7 @@ import (
 	"github.com/open-horizon/edge-utilities/logger/log"
 	"github.com/open-horizon/edge-utilities/logger/trace"
 	"io/ioutil"
+	"net/url"
 	"os"
 	"path"
 	"time"
@@ -50,6 +51,10 @@ func (r *ResourceManager) NodeConfigUpdate(org string, pattern string, id string
 	r.token = token
 }
 
+func (r *ResourceManager) NodeTokenUpdate(token string) {
+	r.token = token
+}
+
 func (r ResourceManager) String() string {
 	return fmt.Sprintf("ResourceManager: Org %v"+
 		", Pattern: %v"+
@@ -69,6 +74,11 @@ func (r ResourceManager) StartFileSyncService(am *AuthenticationManager) error {
 		return errors.New(fmt.Sprintf("unable to create SSL certificate for ESS API, error %v", err))
 	}
 
+	cssCACert, err := r.getCSSCACertificate()
+	if err != nil {
+		return err
+	}
+
 	// In order to override the ESS SSL certificate and key that the ESS uses to listen on the ESS API,
 	// we have to pass our certificate and key into the ESS config by value, as a string of bytes.
 
@@ -99,7 +109,7 @@ func (r ResourceManager) StartFileSyncService(am *AuthenticationManager) error {
 		common.Configuration.HTTPPollingInterval = r.config.GetESSPollingRate()
 		common.Configuration.PersistenceRootPath = r.config.GetFileSyncServiceStoragePath()
 		common.Configuration.HTTPCSSUseSSL = true
-		common.Configuration.HTTPCSSCACertificate = r.config.GetCSSSSLCert()
+		common.Configuration.HTTPCSSCACertificate = cssCACert
 		common.Configuration.LogTraceDestination = "glog"
 	}
 
@@ -114,8 +124,17 @@ func (r ResourceManager) StartFileSyncService(am *AuthenticationManager) error {
 	// The embedded ESS will use a local bolt DB.
 	common.Configuration.StorageProvider = "bolt"
 
-	// Set the fully formed CSS API URL in the global configuration object.
+	// Set the fully formed CSS API URL in the global configuration object. When the CSS is reached through the proxy,
+	// the ESS sends its requests to the CSS relay over the loopback interface, and the relay verifies the CSS.
 	common.HTTPCSSURL = r.config.GetCSSURL()
+	if cssURL, err := url.Parse(common.HTTPCSSURL); err == nil && r.config.Edge.Proxy.UseProxy(cssURL.Host) {
+		if relayURL, err := startCSSRelay(common.HTTPCSSURL, cssCACert, r.config.Edge.Proxy); err != nil {
+			return errors.New(fmt.Sprintf("unable to start the CSS relay for proxy %v, error %v", r.config.Edge.Proxy.URL, err))
+		} else {
+			common.HTTPCSSURL = relayURL
+			common.Configuration.HTTPCSSUseSSL = false
+		}
+	}
 
 	// Init the sync service log and trace.
 	parameters := logger.Parameters{
@@ -142,7 +161,8 @@ func (r ResourceManager) StartFileSyncService(am *AuthenticationManager) error {
 	censorAndDumpConfig()
 
 	// Set the authenticator that we're going to use.
-	security.SetAuthentication(&FSSAuthenticate{nodeOrg: r.org, nodeID: r.id, nodeToken: r.token, AuthMgr: am})
+	am.SetNodeToken(r.token)
+	security.SetAuthentication(&FSSAuthenticate{nodeOrg: r.org, nodeID: r.id, AuthMgr: am})
 
 	// Start the embedded ESS.
 	if err := base.Start("", true); err != nil {
@@ -156,6 +176,33 @@ func (r ResourceManager) StartFileSyncService(am *AuthenticationManager) error {
 
 }
 
+// Returns the CA certificate that the embedded ESS uses to verify the CSS. The ESS accepts either a file name or
+// the PEM encoded certificate itself. When a proxy with its own CA is configured, the CSS cert and the proxy CA
+// are combined so that the ESS trusts both.
+func (r ResourceManager) getCSSCACertificate() (string, error) {
+	cssCert := r.config.GetCSSSSLCert()
+
+	proxyCaBytes, err := r.config.Edge.Proxy.ReadCACerts()
+	if err != nil {
+		return "", errors.New(fmt.Sprintf("unable to read proxy CA certificate for ESS, error %v", err))
+	} else if len(proxyCaBytes) == 0 {
+		return cssCert, nil
+	}
+
+	combined := make([]byte, 0)
+	if cssCert != "" {
+		if cssCertBytes, err := ioutil.ReadFile(cssCert); err != nil {
+			return "", errors.New(fmt.Sprintf("unable to read CSS SSL Certificate file %v, error %v", cssCert, err))
+		} else {
+			combined = append(combined, cssCertBytes...)
+			combined = append(combined, '\n')
+		}
+	}
+	combined = append(combined, proxyCaBytes...)
+
+	return string(combined), nil
+}
+
 func censorAndDumpConfig() {
 	toBeCensored := []*string{&common.Configuration.ServerCertificate, &common.Configuration.ServerKey,
 		&common.Configuration.HTTPCSSCACertificate,
@@ -208,6 +255,7 @@ func (r ResourceManager) StopFileSyncService() {
 		}
 
 		// Complete the final steps of cleanup.
+		stopCSSRelay()
 		r.RemovePersistencePath()
 		glog.Infof(rmLogString(fmt.Sprintf("ESS Stopped")))
 	}
@@ -77,6 +77,13 @@ func (w *ResourceWorker) NewEvent(incoming events.Message) {
 		w.EC = worker.NewExchangeContext(fmt.Sprintf("%v/%v", msg.Org(), msg.DeviceId()), msg.Token(), w.Config.Edge.ExchangeURL, w.Config.GetCSSURL(), w.Config.Collaborators.HTTPClientFactory)
 		w.Commands <- NewNodeConfigCommand(msg)
 
+	case *events.NodeTokenRotatedMessage:
+		msg, _ := incoming.(*events.NodeTokenRotatedMessage)
+		if w.EC != nil {
+			w.EC = w.EC.WithToken(msg.Token())
+		}
+		w.Commands <- NewNodeTokenRotatedCommand(msg)
+
 	case *events.NodeShutdownCompleteMessage:
 		msg, _ := incoming.(*events.NodeShutdownCompleteMessage)
 		switch msg.Event().Id {
@@ -101,6 +108,10 @@ func (w *ResourceWorker) CommandHandler(command worker.Command) bool {
 			glog.Errorf(reslog(fmt.Sprintf("Error handling node config command: %v", err)))
 		}
 
+	case *NodeTokenRotatedCommand:
+		cmd, _ := command.(*NodeTokenRotatedCommand)
+		w.handleNodeTokenRotatedCommand(cmd)
+
 	case *NodeUnconfigCommand:
 		cmd, _ := command.(*NodeUnconfigCommand)
 		if err := w.handleNodeUnconfigCommand(cmd); err != nil {
@@ -154,6 +165,15 @@ func (w *ResourceWorker) handleNodeConfigCommand(cmd *NodeConfigCommand) error {
 	return w.rm.StartFileSyncService(w.am)
 }
 
+// The node's exchange token has changed, so the embedded ESS has to use the new token to login to the CSS.
+func (w *ResourceWorker) handleNodeTokenRotatedCommand(cmd *NodeTokenRotatedCommand) {
+	if w.rm.Configured() {
+		w.rm.NodeTokenUpdate(cmd.msg.Token())
+	}
+	w.am.SetNodeToken(cmd.msg.Token())
+	glog.V(3).Infof(reslog(fmt.Sprintf("ESS will use the new node token to access the CSS")))
+}
+
 // The node has just been unconfigured so we can stop the file sync service.
 func (w *ResourceWorker) handleNodeUnconfigCommand(cmd *NodeUnconfigCommand) error {
 	w.rm.StopFileSyncService()
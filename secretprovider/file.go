@@ -0,0 +1,68 @@
+package secretprovider
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/open-horizon/anax/config"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// The file provider keeps the secrets on the node, so that secret references can be resolved without a network
+// connection. When the path of the provider is a directory, each secret is a file below the directory, e.g. the
+// reference secret://local/db/password is the file db/password. When the path is a file, it holds a JSON object with
+// the path and the secret of each secret, e.g. {"db/password":"s3cret"}. The secrets are read on every access, so
+// they can be rotated while the agent runs.
+type FileProvider struct {
+	name string
+	path string
+}
+
+func NewFileProvider(cfg *config.SecretProvider) (Provider, error) {
+	if cfg.Path == "" {
+		return nil, fmt.Errorf("secret provider %v has no path", cfg.Name)
+	}
+	return &FileProvider{name: cfg.Name, path: cfg.Path}, nil
+}
+
+func (p *FileProvider) Name() string {
+	return p.name
+}
+
+func (p *FileProvider) GetSecret(secretPath string) (string, error) {
+	info, err := os.Stat(p.path)
+	if err != nil {
+		return "", fmt.Errorf("unable to access the path %v of secret provider %v, error: %v", p.path, p.name, err)
+	} else if info.IsDir() {
+		return p.readSecretFile(secretPath)
+	}
+
+	secrets := make(map[string]string)
+	if out, err := ioutil.ReadFile(p.path); err != nil {
+		return "", fmt.Errorf("unable to read the secrets file %v of provider %v, error: %v", p.path, p.name, err)
+	} else if err := json.Unmarshal(out, &secrets); err != nil {
+		return "", fmt.Errorf("unable to parse the secrets file %v of provider %v, error: %v", p.path, p.name, err)
+	} else if secret, ok := secrets[secretPath]; !ok {
+		return "", fmt.Errorf("secret provider %v has no secret %v", p.name, secretPath)
+	} else {
+		return secret, nil
+	}
+}
+
+// Read a secret that is a file below the directory of the provider. The path of the secret cannot leave the directory.
+func (p *FileProvider) readSecretFile(secretPath string) (string, error) {
+	file := filepath.Join(p.path, filepath.FromSlash(secretPath))
+	if rel, err := filepath.Rel(p.path, file); err != nil || rel == "." || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return "", fmt.Errorf("secret path %v is not below the directory of secret provider %v", secretPath, p.name)
+	}
+
+	out, err := ioutil.ReadFile(file)
+	if os.IsNotExist(err) {
+		return "", fmt.Errorf("secret provider %v has no secret %v", p.name, secretPath)
+	} else if err != nil {
+		return "", fmt.Errorf("unable to read secret %v of provider %v, error: %v", secretPath, p.name, err)
+	}
+	return string(out), nil
+}
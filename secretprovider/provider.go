@@ -0,0 +1,41 @@
+package secretprovider
+
+import (
+	"fmt"
+	"github.com/open-horizon/anax/config"
+	"sync"
+)
+
+// A secret provider returns the secret that is kept at a path, e.g. the password that a vault keeps for a database.
+type Provider interface {
+	Name() string
+	GetSecret(path string) (string, error)
+}
+
+// Creates a provider from its configuration.
+type ProviderFactory func(cfg *config.SecretProvider) (Provider, error)
+
+var factoryLock sync.Mutex
+var factories = map[string]ProviderFactory{
+	config.SECRET_PROVIDER_FILE: NewFileProvider,
+}
+
+// Register a provider type that is implemented in Go, e.g. a client of a vault. The type can then be used in the
+// SecretProviders of the anax configuration. Registering a type again replaces the factory.
+func RegisterProvider(providerType string, factory ProviderFactory) {
+	factoryLock.Lock()
+	defer factoryLock.Unlock()
+	factories[providerType] = factory
+}
+
+// Create the provider for a configured provider type.
+func NewProvider(cfg *config.SecretProvider) (Provider, error) {
+	factoryLock.Lock()
+	factory, ok := factories[cfg.Type]
+	factoryLock.Unlock()
+
+	if !ok {
+		return nil, fmt.Errorf("unknown secret provider type %v for provider %v", cfg.Type, cfg.Name)
+	}
+	return factory(cfg)
+}
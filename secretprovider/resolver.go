@@ -0,0 +1,53 @@
+package secretprovider
+
+import (
+	"fmt"
+	"github.com/golang/glog"
+	"github.com/open-horizon/anax/config"
+	"github.com/open-horizon/anax/policy"
+)
+
+// The resolver finds the secrets that secret references refer to, using the configured providers.
+type Resolver struct {
+	providers map[string]Provider
+}
+
+// Create a resolver for the configured providers. A provider that cannot be created is logged and left out, so that
+// one broken provider does not stop the others. The references to it fail to resolve.
+func NewResolver(cfgs []config.SecretProvider) *Resolver {
+	r := &Resolver{providers: make(map[string]Provider)}
+	for i := range cfgs {
+		if p, err := NewProvider(&cfgs[i]); err != nil {
+			glog.Errorf(logString(fmt.Sprintf("unable to create secret provider %v, error: %v", cfgs[i].Name, err)))
+		} else {
+			r.providers[cfgs[i].Name] = p
+		}
+	}
+	return r
+}
+
+// Returns true when there are no providers to resolve references with.
+func (r *Resolver) IsEmpty() bool {
+	return r == nil || len(r.providers) == 0
+}
+
+// Returns the secret that a reference refers to.
+func (r *Resolver) Resolve(ref string) (string, error) {
+	name, secretPath, err := policy.ParseSecretReference(ref)
+	if err != nil {
+		return "", err
+	}
+
+	var p Provider
+	if r != nil {
+		p = r.providers[name]
+	}
+	if p == nil {
+		return "", fmt.Errorf("there is no secret provider %v for secret reference %v", name, ref)
+	}
+	return p.GetSecret(secretPath)
+}
+
+var logString = func(v interface{}) string {
+	return fmt.Sprintf("SecretProvider: %v", v)
+}
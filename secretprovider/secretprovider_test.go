@@ -0,0 +1,120 @@
+// +build unit
+
+package secretprovider
+
+import (
+	"errors"
+	"github.com/open-horizon/anax/config"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func Test_FileProvider_directory(t *testing.T) {
+
+	dir, err := ioutil.TempDir("", "secretprovider")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	secrets := filepath.Join(dir, "secrets")
+	if err := os.MkdirAll(filepath.Join(secrets, "db"), 0700); err != nil {
+		t.Fatal(err)
+	} else if err := ioutil.WriteFile(filepath.Join(secrets, "db", "password"), []byte("s3cret"), 0600); err != nil {
+		t.Fatal(err)
+	} else if err := ioutil.WriteFile(filepath.Join(dir, "outside"), []byte("other"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	r := NewResolver([]config.SecretProvider{config.SecretProvider{Name: "local", Type: config.SECRET_PROVIDER_FILE, Path: secrets}})
+	if r.IsEmpty() {
+		t.Fatalf("the resolver should have the file provider")
+	}
+
+	if s, err := r.Resolve("secret://local/db/password"); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	} else if s != "s3cret" {
+		t.Errorf("wrong secret %v", s)
+	}
+
+	// a rotated secret is read on the next access
+	if err := ioutil.WriteFile(filepath.Join(secrets, "db", "password"), []byte("rotated"), 0600); err != nil {
+		t.Fatal(err)
+	} else if s, err := r.Resolve("secret://local/db/password"); err != nil || s != "rotated" {
+		t.Errorf("expected the rotated secret, got %v, error: %v", s, err)
+	}
+
+	for _, ref := range []string{"secret://local/db/missing", "secret://local/../outside", "secret://local/db/../../outside", "secret://other/db/password", "local/db/password"} {
+		if _, err := r.Resolve(ref); err == nil {
+			t.Errorf("expected an error resolving %v", ref)
+		}
+	}
+}
+
+func Test_FileProvider_file(t *testing.T) {
+
+	dir, err := ioutil.TempDir("", "secretprovider")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "secrets.json")
+	p, err := NewProvider(&config.SecretProvider{Name: "local", Type: config.SECRET_PROVIDER_FILE, Path: path})
+	if err != nil {
+		t.Fatalf("unexpected error creating the provider: %v", err)
+	}
+
+	if _, err := p.GetSecret("db/password"); err == nil {
+		t.Errorf("expected an error for a missing file")
+	}
+
+	if err := ioutil.WriteFile(path, []byte(`{"db/password":"s3cret"}`), 0600); err != nil {
+		t.Fatal(err)
+	} else if s, err := p.GetSecret("db/password"); err != nil || s != "s3cret" {
+		t.Errorf("expected the secret, got %v, error: %v", s, err)
+	} else if _, err := p.GetSecret("db/user"); err == nil {
+		t.Errorf("expected an error for a missing secret")
+	}
+
+	if err := ioutil.WriteFile(path, []byte("not json"), 0600); err != nil {
+		t.Fatal(err)
+	} else if _, err := p.GetSecret("db/password"); err == nil {
+		t.Errorf("expected an error for a file that is not a JSON object")
+	}
+}
+
+type staticProvider struct {
+	name string
+}
+
+func (p *staticProvider) Name() string {
+	return p.name
+}
+
+func (p *staticProvider) GetSecret(path string) (string, error) {
+	if path == "fail" {
+		return "", errors.New("failed")
+	}
+	return "static:" + path, nil
+}
+
+func Test_RegisterProvider(t *testing.T) {
+
+	if _, err := NewProvider(&config.SecretProvider{Name: "vault", Type: "static"}); err == nil {
+		t.Errorf("expected an error for an unknown provider type")
+	}
+
+	RegisterProvider("static", func(cfg *config.SecretProvider) (Provider, error) {
+		return &staticProvider{name: cfg.Name}, nil
+	})
+
+	r := NewResolver([]config.SecretProvider{config.SecretProvider{Name: "vault", Type: "static"}})
+	if s, err := r.Resolve("secret://vault/db/password"); err != nil || s != "static:db/password" {
+		t.Errorf("expected the secret of the registered provider, got %v, error: %v", s, err)
+	} else if _, err := r.Resolve("secret://vault/fail"); err == nil {
+		t.Errorf("expected the error of the provider")
+	}
+}
@@ -0,0 +1,71 @@
+package wasm
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"github.com/golang/glog"
+	"github.com/open-horizon/anax/config"
+	"github.com/open-horizon/anax/persistence"
+	"github.com/open-horizon/anax/process"
+	"io"
+	"io/ioutil"
+	"log/syslog"
+	"os"
+	"path"
+	"strings"
+)
+
+// The file in the storage directory of the agreement that receives the output of the module when syslog is not
+// available.
+const WASM_LOG_NAME = "wasm.log"
+
+// Modules are read into memory to be compiled, so they are limited in size.
+const WASM_MAX_MODULE_SIZE = 64 * 1024 * 1024
+
+// Download the module of the wasm deployment and verify that its digest is the one in the deployment config. The
+// deployment config is signed, so a matching digest means that the module is the one that was signed.
+func FetchModule(cfg *config.HorizonConfig, org string, wd *persistence.WasmDeploymentConfig) ([]byte, error) {
+
+	reader, err := process.OpenArtifact(cfg, org, "module", wd.Module.URL, wd.Module.ObjectType, wd.Module.ObjectID)
+	if err != nil {
+		return nil, err
+	}
+	defer reader.Close()
+
+	return ReadModule(reader, wd.Module.Digest)
+}
+
+// Read a module and verify its digest.
+func ReadModule(reader io.Reader, digest string) ([]byte, error) {
+
+	content, err := ioutil.ReadAll(io.LimitReader(reader, WASM_MAX_MODULE_SIZE+1))
+	if err != nil {
+		return nil, fmt.Errorf("unable to read the module, error %v", err)
+	} else if len(content) > WASM_MAX_MODULE_SIZE {
+		return nil, fmt.Errorf("the module is larger than %v bytes", WASM_MAX_MODULE_SIZE)
+	}
+
+	hash := sha256.Sum256(content)
+	if actual := persistence.WASM_DIGEST_PREFIX + hex.EncodeToString(hash[:]); actual != strings.ToLower(digest) {
+		return nil, fmt.Errorf("the digest %v of the module does not match the digest %v in the deployment config", actual, digest)
+	}
+	return content, nil
+}
+
+// Open the log of the module for an agreement. The output goes to syslog with the same tag as the output of the
+// containers of an agreement, so that it is shown by 'hzn service log'.
+func OpenLog(agreementId string, dir string) (io.WriteCloser, error) {
+	tag := fmt.Sprintf("workload-%v_%v", strings.ToLower(agreementId), "wasm")
+	if w, err := syslog.New(syslog.LOG_INFO|syslog.LOG_DAEMON, tag); err == nil {
+		return w, nil
+	} else {
+		glog.V(3).Infof(wwlog(fmt.Sprintf("unable to log to syslog, logging to %v instead, error %v", WASM_LOG_NAME, err)))
+	}
+
+	logFile, err := os.OpenFile(path.Join(dir, WASM_LOG_NAME), os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0640)
+	if err != nil {
+		return nil, fmt.Errorf("unable to open the module log, error %v", err)
+	}
+	return logFile, nil
+}
@@ -0,0 +1,326 @@
+package wasm
+
+import (
+	"context"
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"github.com/golang/glog"
+	"github.com/open-horizon/anax/persistence"
+	"github.com/open-horizon/anax/process"
+	"github.com/tetratelabs/wazero"
+	"github.com/tetratelabs/wazero/imports/wasi_snapshot_preview1"
+	"github.com/tetratelabs/wazero/sys"
+	"io"
+	"os"
+	"path"
+	"strings"
+	"sync"
+	"time"
+)
+
+// The states of a module, which are the same as the states of a supervised process.
+const (
+	WASM_RUNNING    = process.PROCESS_RUNNING
+	WASM_RESTARTING = process.PROCESS_RESTARTING
+	WASM_FAILED     = process.PROCESS_FAILED
+	WASM_STOPPED    = process.PROCESS_STOPPED
+)
+
+// The time that a stopped module is given to exit.
+const WASM_STOP_TIMEOUT_S = 10
+
+// WebAssembly memory is allocated in pages of 64KiB.
+const WASM_PAGES_PER_MB = 16
+
+type WasmStatus struct {
+	State     string `json:"state"`
+	Restarts  int    `json:"restarts"`
+	Started   int64  `json:"started"`
+	ExitCode  uint32 `json:"exit_code"`
+	LastError string `json:"last_error,omitempty"`
+}
+
+func (s WasmStatus) String() string {
+	return fmt.Sprintf("State: %v, Restarts: %v, Started: %v, ExitCode: %v, LastError: %v", s.State, s.Restarts, s.Started, s.ExitCode, s.LastError)
+}
+
+// A Runner runs the module of an agreement in its own WASI runtime, and runs it again according to the restart
+// policy of the deployment config when it exits. The module can only reach the directories that are preopened for it.
+type Runner struct {
+	name       string
+	module     []byte
+	dir        string
+	env        []string
+	deployment *persistence.WasmDeploymentConfig
+	out        io.Writer
+	lock       sync.Mutex
+	runtime    wazero.Runtime
+	compiled   wazero.CompiledModule
+	ctx        context.Context
+	cancel     context.CancelFunc
+	status     WasmStatus
+	doneCh     chan bool
+}
+
+func NewRunner(name string, module []byte, dir string, env []string, wd *persistence.WasmDeploymentConfig, out io.Writer) *Runner {
+	ctx, cancel := context.WithCancel(context.Background())
+	return &Runner{
+		name:       name,
+		module:     module,
+		dir:        dir,
+		env:        env,
+		deployment: wd,
+		out:        out,
+		ctx:        ctx,
+		cancel:     cancel,
+		doneCh:     make(chan bool),
+	}
+}
+
+func (r *Runner) String() string {
+	return fmt.Sprintf("Name: %v, Status: {%v}", r.name, r.Status())
+}
+
+// Compile the module and start running it. An error compiling the module is returned rather than retried, because it
+// means that the deployment cannot run on this node.
+func (r *Runner) Start() error {
+	if err := r.compile(); err != nil {
+		r.setFailed(err.Error())
+		close(r.doneCh)
+		return err
+	}
+
+	r.lock.Lock()
+	r.status.State = WASM_RUNNING
+	r.status.Started = time.Now().Unix()
+	r.lock.Unlock()
+
+	go r.run()
+	return nil
+}
+
+// Stop the module and release its runtime. The module is interrupted at its next function call or loop iteration. The
+// runtime is only closed once the module is no longer run, so that it is not closed under a running module.
+func (r *Runner) Stop(timeout time.Duration) {
+	r.cancel()
+
+	select {
+	case <-r.doneCh:
+	case <-time.After(timeout):
+		glog.Warningf(wwlog(fmt.Sprintf("module for %v did not exit within %v, still waiting", r.name, timeout)))
+		<-r.doneCh
+	}
+
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	if r.runtime != nil {
+		if err := r.runtime.Close(context.Background()); err != nil {
+			glog.Errorf(wwlog(fmt.Sprintf("unable to close the runtime of %v, error %v", r.name, err)))
+		}
+		r.runtime = nil
+	}
+	r.status.State = WASM_STOPPED
+}
+
+// Returns a channel that is closed when the module is no longer run, because it was stopped or has failed.
+func (r *Runner) Done() <-chan bool {
+	return r.doneCh
+}
+
+func (r *Runner) Status() WasmStatus {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	return r.status
+}
+
+// The module is healthy unless it has used up its restarts. A module waiting to be restarted is still healthy.
+func (r *Runner) IsHealthy() bool {
+	return r.Status().State != WASM_FAILED
+}
+
+func (r *Runner) setFailed(reason string) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	r.status.State = WASM_FAILED
+	r.status.LastError = reason
+}
+
+// Create the runtime of the module, with the memory limit of the deployment config, and compile the module in it.
+func (r *Runner) compile() error {
+	rc := wazero.NewRuntimeConfig().WithCloseOnContextDone(true)
+	if r.deployment.MemoryLimitMB != 0 {
+		rc = rc.WithMemoryLimitPages(uint32(r.deployment.MemoryLimitMB * WASM_PAGES_PER_MB))
+	}
+
+	runtime := wazero.NewRuntimeWithConfig(r.ctx, rc)
+
+	r.lock.Lock()
+	r.runtime = runtime
+	r.lock.Unlock()
+
+	if _, err := wasi_snapshot_preview1.Instantiate(r.ctx, runtime); err != nil {
+		return fmt.Errorf("unable to create the WASI runtime, error %v", err)
+	}
+
+	compiled, err := runtime.CompileModule(r.ctx, r.module)
+	if err != nil {
+		return fmt.Errorf("unable to compile the module, error %v", err)
+	}
+	r.compiled = compiled
+	return nil
+}
+
+// Returns the configuration of an instance of the module.
+func (r *Runner) moduleConfig() (wazero.ModuleConfig, error) {
+	mc := wazero.NewModuleConfig().
+		WithName("").
+		WithArgs(append([]string{r.name}, r.deployment.Args...)...).
+		WithStdout(r.out).
+		WithStderr(r.out).
+		WithSysWalltime().
+		WithSysNanotime().
+		WithSysNanosleep().
+		WithRandSource(rand.Reader)
+
+	for _, e := range r.env {
+		if parts := strings.SplitN(e, "=", 2); len(parts) == 2 {
+			mc = mc.WithEnv(parts[0], parts[1])
+		}
+	}
+
+	fsc := wazero.NewFSConfig()
+	for _, p := range r.deployment.Preopens {
+		hostPath := PreopenHostPath(r.dir, p)
+		if !path.IsAbs(p.HostPath) {
+			if err := os.MkdirAll(hostPath, 0750); err != nil {
+				return nil, fmt.Errorf("unable to create directory %v, error %v", hostPath, err)
+			}
+		} else if info, err := os.Stat(hostPath); err != nil || !info.IsDir() {
+			return nil, fmt.Errorf("preopen %v is not a directory on the node", hostPath)
+		}
+
+		if p.ReadOnly {
+			fsc = fsc.WithReadOnlyDirMount(hostPath, p.GuestPath)
+		} else {
+			fsc = fsc.WithDirMount(hostPath, p.GuestPath)
+		}
+	}
+	return mc.WithFSConfig(fsc), nil
+}
+
+// Run the module until it exits, and run it again with an increasing delay between runs. The delay is reset when the
+// module runs for longer than the maximum delay.
+func (r *Runner) run() {
+	defer close(r.doneCh)
+
+	policy := r.deployment.Restart
+	failures := 0
+
+	for {
+		started := time.Now()
+		reason, exitCode := r.runOnce()
+
+		if r.ctx.Err() != nil {
+			return
+		}
+
+		r.lock.Lock()
+		r.status.ExitCode = exitCode
+		r.status.LastError = reason
+		r.lock.Unlock()
+
+		if time.Since(started) > time.Duration(policy.GetMaxBackoffS())*time.Second {
+			failures = 0
+		}
+
+		failures += 1
+		if policy.MaxRetries != 0 && failures > policy.MaxRetries {
+			glog.Errorf(wwlog(fmt.Sprintf("module for %v %v, giving up after %v restarts", r.name, reason, policy.MaxRetries)))
+			r.setFailed(reason)
+			return
+		}
+
+		delay := process.Backoff(policy, failures)
+		glog.Warningf(wwlog(fmt.Sprintf("module for %v %v, restarting in %v", r.name, reason, delay)))
+
+		r.lock.Lock()
+		r.status.State = WASM_RESTARTING
+		r.lock.Unlock()
+
+		select {
+		case <-r.ctx.Done():
+			return
+		case <-time.After(delay):
+		}
+
+		r.lock.Lock()
+		r.status.State = WASM_RUNNING
+		r.status.Started = time.Now().Unix()
+		r.status.Restarts += 1
+		r.lock.Unlock()
+	}
+}
+
+// Run an instance of the module. The module runs when it is instantiated, and returns when its _start function does.
+func (r *Runner) runOnce() (string, uint32) {
+	mc, err := r.moduleConfig()
+	if err != nil {
+		return err.Error(), 0
+	}
+
+	mod, err := r.runtime.InstantiateModule(r.ctx, r.compiled, mc)
+	if mod != nil {
+		mod.Close(context.Background())
+	}
+
+	var exitErr *sys.ExitError
+	if err == nil {
+		return "exited", 0
+	} else if errors.As(err, &exitErr) {
+		return fmt.Sprintf("exited with code %v", exitErr.ExitCode()), exitErr.ExitCode()
+	}
+	return err.Error(), 0
+}
+
+// Returns the host directory of a preopen. A relative host path is in the storage directory of the agreement.
+func PreopenHostPath(dir string, p persistence.WasmPreopen) string {
+	if path.IsAbs(p.HostPath) {
+		return path.Clean(p.HostPath)
+	}
+	return path.Join(dir, p.HostPath)
+}
+
+// The runners of the running agreements, so that the status of the modules can be reported.
+var runners = make(map[string]*Runner)
+var runnersLock sync.Mutex
+
+func addRunner(agreementId string, r *Runner) {
+	runnersLock.Lock()
+	defer runnersLock.Unlock()
+	runners[agreementId] = r
+}
+
+func removeRunner(agreementId string) *Runner {
+	runnersLock.Lock()
+	defer runnersLock.Unlock()
+	r := runners[agreementId]
+	delete(runners, agreementId)
+	return r
+}
+
+func getRunner(agreementId string) *Runner {
+	runnersLock.Lock()
+	defer runnersLock.Unlock()
+	return runners[agreementId]
+}
+
+// Returns the status of the module for an agreement, or nil if no module is running for it.
+func GetStatus(agreementId string) *WasmStatus {
+	if r := getRunner(agreementId); r != nil {
+		status := r.Status()
+		return &status
+	}
+	return nil
+}
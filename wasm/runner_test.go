@@ -0,0 +1,278 @@
+// +build unit
+
+package wasm
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"github.com/open-horizon/anax/config"
+	"github.com/open-horizon/anax/persistence"
+	"io/ioutil"
+	"os"
+	"path"
+	"sync"
+	"testing"
+	"time"
+)
+
+// The modules used by the tests are assembled by hand. Each one exports its memory and a _start function.
+
+// _start calls proc_exit(1).
+var exitModule = wasmModule(
+	section(1, vec(funcType([]byte{0x7f}, nil), funcType(nil, nil))),
+	section(2, vec(concat(name("wasi_snapshot_preview1"), name("proc_exit"), []byte{0x00, 0x00}))),
+	section(3, vec([]byte{0x01})),
+	section(5, vec([]byte{0x00, 0x01})),
+	section(7, vec(concat(name("_start"), []byte{0x00, 0x01}), concat(name("memory"), []byte{0x02, 0x00}))),
+	section(10, vec(code(0x41, 0x01, 0x10, 0x00))),
+)
+
+// _start writes "hello\n" to stdout with fd_write and returns.
+var helloModule = wasmModule(
+	section(1, vec(funcType([]byte{0x7f, 0x7f, 0x7f, 0x7f}, []byte{0x7f}), funcType(nil, nil))),
+	section(2, vec(concat(name("wasi_snapshot_preview1"), name("fd_write"), []byte{0x00, 0x00}))),
+	section(3, vec([]byte{0x01})),
+	section(5, vec([]byte{0x00, 0x01})),
+	section(7, vec(concat(name("_start"), []byte{0x00, 0x01}), concat(name("memory"), []byte{0x02, 0x00}))),
+	section(10, vec(code(0x41, 0x01, 0x41, 0x00, 0x41, 0x01, 0x41, 0x14, 0x10, 0x00, 0x1a))),
+	section(11, vec(data(0x00, concat([]byte{0x08, 0x00, 0x00, 0x00, 0x06, 0x00, 0x00, 0x00}, []byte("hello\n"))))),
+)
+
+// _start loops forever.
+var loopModule = wasmModule(
+	section(1, vec(funcType(nil, nil))),
+	section(3, vec([]byte{0x00})),
+	section(5, vec([]byte{0x00, 0x01})),
+	section(7, vec(concat(name("_start"), []byte{0x00, 0x00}), concat(name("memory"), []byte{0x02, 0x00}))),
+	section(10, vec(code(0x03, 0x40, 0x0c, 0x00, 0x0b))),
+)
+
+// Like loopModule, with a memory of 32 pages (2MB).
+var bigMemoryModule = wasmModule(
+	section(1, vec(funcType(nil, nil))),
+	section(3, vec([]byte{0x00})),
+	section(5, vec([]byte{0x00, 0x20})),
+	section(7, vec(concat(name("_start"), []byte{0x00, 0x00}), concat(name("memory"), []byte{0x02, 0x00}))),
+	section(10, vec(code(0x03, 0x40, 0x0c, 0x00, 0x0b))),
+)
+
+func Test_ReadModule(t *testing.T) {
+
+	hash := sha256.Sum256(exitModule)
+	digest := persistence.WASM_DIGEST_PREFIX + hex.EncodeToString(hash[:])
+
+	if _, err := ReadModule(bytes.NewReader(exitModule), persistence.WASM_DIGEST_PREFIX+"0000000000000000000000000000000000000000000000000000000000000000"); err == nil {
+		t.Errorf("expected an error for the wrong digest")
+	} else if m, err := ReadModule(bytes.NewReader(exitModule), digest); err != nil {
+		t.Errorf("unexpected error %v", err)
+	} else if !bytes.Equal(m, exitModule) {
+		t.Errorf("the module was not read")
+	}
+}
+
+func Test_WasmEnvironment(t *testing.T) {
+
+	wd := &persistence.WasmDeploymentConfig{Env: []string{"MODE=fast", "HZN_ORGID=wrong", "OPTS=a=b"}}
+	env := WasmEnvironment(wd, map[string]string{"HZN_ORGID": "myorg"})
+
+	expected := []string{"HZN_ORGID=myorg", "MODE=fast", "OPTS=a=b"}
+	if len(env) != len(expected) {
+		t.Fatalf("expected %v, got %v", expected, env)
+	}
+	for i := range expected {
+		if env[i] != expected[i] {
+			t.Errorf("expected %v, got %v", expected, env)
+		}
+	}
+}
+
+func Test_CheckAdmission(t *testing.T) {
+
+	ap := &config.AdmissionPolicy{AllowedBindPaths: []string{"/var/data"}, MaxMemoryMB: 64}
+
+	ok := &persistence.WasmDeploymentConfig{MemoryLimitMB: 32, Preopens: []persistence.WasmPreopen{{HostPath: "/var/data/app", GuestPath: "/data"}, {HostPath: "cache", GuestPath: "/cache"}}}
+	if err := CheckAdmission(ap, "ag1", ok); err != nil {
+		t.Errorf("unexpected error %v", err)
+	}
+
+	rejected := []*persistence.WasmDeploymentConfig{
+		{MemoryLimitMB: 32, Preopens: []persistence.WasmPreopen{{HostPath: "/etc", GuestPath: "/etc"}}},
+		{MemoryLimitMB: 128},
+		{},
+	}
+	for _, wd := range rejected {
+		if err := CheckAdmission(ap, "ag1", wd); err == nil {
+			t.Errorf("expected %v to be rejected", wd)
+		}
+	}
+}
+
+func Test_Runner_restart(t *testing.T) {
+
+	dir := tempDir(t)
+	defer os.RemoveAll(dir)
+
+	wd := &persistence.WasmDeploymentConfig{Restart: persistence.ProcessRestartPolicy{MaxRetries: 2, InitialBackoffS: 1, MaxBackoffS: 1}}
+	r := NewRunner("ag1", exitModule, dir, nil, wd, new(lockedBuffer))
+	if err := r.Start(); err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+
+	// The module exits every time, so it is run 3 times and then given up on.
+	waitFor(t, 10*time.Second, func() bool { return r.Status().State == WASM_FAILED })
+
+	if status := r.Status(); status.Restarts != 2 || status.ExitCode != 1 {
+		t.Errorf("expected 2 restarts and exit code 1, got %v", status)
+	} else if r.IsHealthy() {
+		t.Errorf("a failed module should not be healthy")
+	}
+
+	r.Stop(time.Second)
+}
+
+func Test_Runner_output(t *testing.T) {
+
+	dir := tempDir(t)
+	defer os.RemoveAll(dir)
+
+	out := new(lockedBuffer)
+	wd := &persistence.WasmDeploymentConfig{Restart: persistence.ProcessRestartPolicy{MaxRetries: 1, InitialBackoffS: 1, MaxBackoffS: 1}}
+	r := NewRunner("ag1", helloModule, dir, nil, wd, out)
+	if err := r.Start(); err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+
+	waitFor(t, 10*time.Second, func() bool { return r.Status().State == WASM_FAILED })
+
+	if s := out.String(); s != "hello\nhello\n" {
+		t.Errorf("unexpected module output %q", s)
+	}
+
+	r.Stop(time.Second)
+}
+
+func Test_Runner_stop(t *testing.T) {
+
+	dir := tempDir(t)
+	defer os.RemoveAll(dir)
+
+	wd := &persistence.WasmDeploymentConfig{Preopens: []persistence.WasmPreopen{{HostPath: "data", GuestPath: "/data"}}}
+	r := NewRunner("ag1", loopModule, dir, nil, wd, new(lockedBuffer))
+	if err := r.Start(); err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+
+	if status := r.Status(); status.State != WASM_RUNNING || !r.IsHealthy() {
+		t.Errorf("expected a running module, got %v", status)
+	}
+
+	// relative preopens are created in the storage directory
+	waitFor(t, 5*time.Second, func() bool {
+		info, err := os.Stat(path.Join(dir, "data"))
+		return err == nil && info.IsDir()
+	})
+
+	r.Stop(5 * time.Second)
+
+	select {
+	case <-r.doneCh:
+	default:
+		t.Errorf("the module did not exit when it was stopped")
+	}
+	if status := r.Status(); status.State != WASM_STOPPED || status.Restarts != 0 {
+		t.Errorf("expected a stopped module without restarts, got %v", status)
+	}
+}
+
+func Test_Runner_memoryLimit(t *testing.T) {
+
+	dir := tempDir(t)
+	defer os.RemoveAll(dir)
+
+	wd := &persistence.WasmDeploymentConfig{MemoryLimitMB: 1}
+	r := NewRunner("ag1", bigMemoryModule, dir, nil, wd, new(lockedBuffer))
+	if err := r.Start(); err == nil {
+		t.Errorf("expected an error starting a module that needs more memory than its limit")
+	} else if r.Status().State != WASM_FAILED {
+		t.Errorf("expected a failed module, got %v", r.Status())
+	}
+
+	r.Stop(time.Second)
+}
+
+// Helpers that assemble a wasm binary. All of the lengths in the test modules fit in one byte of LEB128.
+
+func wasmModule(sections ...[]byte) []byte {
+	return concat(append([][]byte{{0x00, 0x61, 0x73, 0x6d, 0x01, 0x00, 0x00, 0x00}}, sections...)...)
+}
+
+func section(id byte, content []byte) []byte {
+	return concat([]byte{id, byte(len(content))}, content)
+}
+
+func vec(items ...[]byte) []byte {
+	return concat(append([][]byte{{byte(len(items))}}, items...)...)
+}
+
+func name(s string) []byte {
+	return concat([]byte{byte(len(s))}, []byte(s))
+}
+
+func funcType(params []byte, results []byte) []byte {
+	return concat([]byte{0x60, byte(len(params))}, params, []byte{byte(len(results))}, results)
+}
+
+// A function body without locals.
+func code(instructions ...byte) []byte {
+	body := concat([]byte{0x00}, instructions, []byte{0x0b})
+	return concat([]byte{byte(len(body))}, body)
+}
+
+// An active data segment of memory 0.
+func data(offset byte, content []byte) []byte {
+	return concat([]byte{0x00, 0x41, offset, 0x0b, byte(len(content))}, content)
+}
+
+func concat(parts ...[]byte) []byte {
+	res := []byte{}
+	for _, p := range parts {
+		res = append(res, p...)
+	}
+	return res
+}
+
+// The output of a module is written from the goroutine that runs it.
+type lockedBuffer struct {
+	lock sync.Mutex
+	buf  bytes.Buffer
+}
+
+func (b *lockedBuffer) Write(p []byte) (int, error) {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+	return b.buf.Write(p)
+}
+
+func (b *lockedBuffer) String() string {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+	return b.buf.String()
+}
+
+func tempDir(t *testing.T) string {
+	dir, err := ioutil.TempDir("", "wasm-")
+	if err != nil {
+		t.Fatalf("unable to create temp dir, %v", err)
+	}
+	return dir
+}
+
+func waitFor(t *testing.T, timeout time.Duration, check func() bool) {
+	for end := time.Now().Add(timeout); time.Now().Before(end); time.Sleep(50 * time.Millisecond) {
+		if check() {
+			return
+		}
+	}
+	t.Fatalf("timed out after %v", timeout)
+}
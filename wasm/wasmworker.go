@@ -0,0 +1,286 @@
+package wasm
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/boltdb/bolt"
+	"github.com/golang/glog"
+	"github.com/open-horizon/anax/config"
+	"github.com/open-horizon/anax/containermessage"
+	"github.com/open-horizon/anax/events"
+	"github.com/open-horizon/anax/persistence"
+	"github.com/open-horizon/anax/worker"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// The wasm worker runs services whose deployment config is a WebAssembly module, in a WASI runtime that is embedded
+// in the agent. It is meant for small functions that do not justify a container image.
+type WasmWorker struct {
+	worker.BaseWorker // embedded field
+	db                *bolt.DB
+	org               string // the org of the node, which owns the model management objects that hold modules
+}
+
+func NewWasmWorker(name string, config *config.HorizonConfig, db *bolt.DB) *WasmWorker {
+
+	worker := &WasmWorker{
+		BaseWorker: worker.NewBaseWorker(name, config, nil),
+		db:         db,
+	}
+
+	glog.Info(wwlog(fmt.Sprintf("Starting Wasm worker")))
+	worker.Start(worker, 0)
+	return worker
+}
+
+func (w *WasmWorker) Messages() chan events.Message {
+	return w.BaseWorker.Manager.Messages
+}
+
+func (w *WasmWorker) NewEvent(incoming events.Message) {
+
+	switch incoming.(type) {
+	case *events.EdgeRegisteredExchangeMessage:
+		msg, _ := incoming.(*events.EdgeRegisteredExchangeMessage)
+		w.Commands <- NewNodeRegisteredCommand(msg.Org())
+
+	case *events.AgreementReachedMessage:
+		msg, _ := incoming.(*events.AgreementReachedMessage)
+
+		fCmd := NewInstallCommand(msg.LaunchContext())
+		w.Commands <- fCmd
+
+	case *events.GovernanceWorkloadCancelationMessage:
+		msg, _ := incoming.(*events.GovernanceWorkloadCancelationMessage)
+
+		switch msg.Event().Id {
+		case events.AGREEMENT_ENDED:
+			cmd := NewUnInstallCommand(msg.AgreementProtocol, msg.AgreementId, msg.Deployment)
+			w.Commands <- cmd
+		}
+
+	case *events.GovernanceMaintenanceMessage:
+		msg, _ := incoming.(*events.GovernanceMaintenanceMessage)
+
+		switch msg.Event().Id {
+		case events.CONTAINER_MAINTAIN:
+			cmd := NewMaintenanceCommand(msg.AgreementProtocol, msg.AgreementId, msg.Deployment)
+			w.Commands <- cmd
+		}
+
+	case *events.NodeShutdownCompleteMessage:
+		msg, _ := incoming.(*events.NodeShutdownCompleteMessage)
+		switch msg.Event().Id {
+		case events.UNCONFIGURE_COMPLETE:
+			w.Commands <- worker.NewTerminateCommand("shutdown")
+		}
+
+	default: //nothing
+
+	}
+
+	return
+}
+
+func (w *WasmWorker) CommandHandler(command worker.Command) bool {
+
+	switch command.(type) {
+	case *NodeRegisteredCommand:
+		cmd := command.(*NodeRegisteredCommand)
+		w.org = cmd.Org
+
+	case *InstallCommand:
+		cmd := command.(*InstallCommand)
+		if lc := w.getLaunchContext(cmd.LaunchContext); lc == nil {
+			glog.Errorf(wwlog(fmt.Sprintf("incoming event was not a known launch context %T", cmd.LaunchContext)))
+		} else {
+			glog.V(5).Infof(wwlog(fmt.Sprintf("LaunchContext(%T): %v", lc, lc)))
+
+			// Check the deployment to see if it is a wasm deployment. If not, ignore it.
+			deploymentConfig := lc.ContainerConfig().Deployment
+			if !isWasmDeployment(deploymentConfig) {
+				glog.V(5).Infof(wwlog(fmt.Sprintf("ignoring non-wasm deployment.")))
+				return true
+			}
+
+			if wd, err := persistence.GetWasmDeployment(deploymentConfig); err != nil {
+				glog.Errorf(wwlog(fmt.Sprintf("error getting wasm deployment configuration: %v", err)))
+				w.Messages() <- events.NewWorkloadMessage(events.EXECUTION_FAILED, lc.AgreementProtocol, lc.AgreementId, nil)
+			} else if len(lc.Secrets) != 0 {
+				// Secrets are given to containers as files that are bound into them, a module has no such place.
+				glog.Errorf(wwlog(fmt.Sprintf("wasm deployment for %v cannot use the secret user inputs %v", lc.AgreementId, events.SecretNames(lc.Secrets))))
+				w.Messages() <- events.NewWorkloadMessage(events.EXECUTION_FAILED, lc.AgreementProtocol, lc.AgreementId, wd)
+			} else if _, err := persistence.AgreementDeploymentStarted(w.db, lc.AgreementId, lc.AgreementProtocol, wd); err != nil {
+				glog.Errorf(wwlog(fmt.Sprintf("received error updating database deployment state, %v", err)))
+				w.Messages() <- events.NewWorkloadMessage(events.EXECUTION_FAILED, lc.AgreementProtocol, lc.AgreementId, wd)
+			} else if err := CheckAdmission(&w.Config.Edge.AdmissionPolicy, lc.AgreementId, wd, w.Config.Edge.ServiceStorage); err != nil {
+				glog.Errorf(wwlog(err))
+				w.Messages() <- events.NewWorkloadMessage(events.EXECUTION_FAILED, lc.AgreementProtocol, lc.AgreementId, wd)
+			} else if err := w.startModule(lc, wd); err != nil {
+				glog.Errorf(wwlog(fmt.Sprintf("failed to start module after agreement negotiation: %v", err)))
+				w.Messages() <- events.NewWorkloadMessage(events.EXECUTION_FAILED, lc.AgreementProtocol, lc.AgreementId, wd)
+			} else {
+				w.Messages() <- events.NewWorkloadMessage(events.EXECUTION_BEGUN, lc.AgreementProtocol, lc.AgreementId, wd)
+			}
+		}
+
+	case *UnInstallCommand:
+		cmd := command.(*UnInstallCommand)
+
+		wd, ok := cmd.Deployment.(*persistence.WasmDeploymentConfig)
+		if !ok {
+			glog.V(5).Infof(wwlog(fmt.Sprintf("ignoring non-wasm cancelation command %v", cmd)))
+			return true
+		}
+
+		glog.V(3).Infof(wwlog(fmt.Sprintf("stopping module for %v", cmd.CurrentAgreementId)))
+		w.stopModule(cmd.CurrentAgreementId)
+
+		w.Messages() <- events.NewWorkloadMessage(events.WORKLOAD_DESTROYED, cmd.AgreementProtocol, cmd.CurrentAgreementId, wd)
+
+	case *MaintenanceCommand:
+		cmd := command.(*MaintenanceCommand)
+
+		wd, ok := cmd.Deployment.(*persistence.WasmDeploymentConfig)
+		if !ok {
+			glog.V(5).Infof(wwlog(fmt.Sprintf("ignoring non-wasm maintenance command: %v", cmd)))
+			return true
+		}
+
+		glog.V(3).Infof(wwlog(fmt.Sprintf("received maintenance command %v", cmd)))
+
+		// A module that is not running, e.g. because the agent restarted, is as unhealthy as one that failed.
+		if r := getRunner(cmd.AgreementId); r == nil {
+			glog.Errorf(wwlog(fmt.Sprintf("no module is running for agreement %v", cmd.AgreementId)))
+			w.Messages() <- events.NewWorkloadMessage(events.EXECUTION_FAILED, cmd.AgreementProtocol, cmd.AgreementId, wd)
+		} else if !r.IsHealthy() {
+			glog.Errorf(wwlog(fmt.Sprintf("module for agreement %v has failed: %v", cmd.AgreementId, r.Status())))
+			w.Messages() <- events.NewWorkloadMessage(events.EXECUTION_FAILED, cmd.AgreementProtocol, cmd.AgreementId, wd)
+		}
+
+	default:
+		return false
+	}
+	return true
+}
+
+func (w *WasmWorker) getLaunchContext(launchContext interface{}) *events.AgreementLaunchContext {
+	switch launchContext.(type) {
+	case *events.AgreementLaunchContext:
+		lc := launchContext.(*events.AgreementLaunchContext)
+		return lc
+	}
+	return nil
+}
+
+// Download and verify the module, and start running it with the storage directory of the agreement as the directory
+// of its relative preopens.
+func (w *WasmWorker) startModule(lc *events.AgreementLaunchContext, wd *persistence.WasmDeploymentConfig) error {
+	glog.V(3).Infof(wwlog(fmt.Sprintf("begin install of wasm deployment %v", lc.AgreementId)))
+
+	module, err := FetchModule(w.Config, w.org, wd)
+	if err != nil {
+		return err
+	}
+
+	dir := path.Join(w.Config.Edge.ServiceStorage, lc.AgreementId)
+	if err := os.MkdirAll(dir, 0750); err != nil {
+		return fmt.Errorf("unable to create directory %v, error %v", dir, err)
+	}
+
+	out, err := OpenLog(lc.AgreementId, dir)
+	if err != nil {
+		os.RemoveAll(dir)
+		return err
+	}
+
+	env := map[string]string{}
+	if lc.EnvironmentAdditions != nil {
+		for k, v := range *lc.EnvironmentAdditions {
+			env[k] = v
+		}
+	}
+
+	r := NewRunner(lc.AgreementId, module, dir, WasmEnvironment(wd, env), wd, out)
+	if err := r.Start(); err != nil {
+		r.Stop(time.Second)
+		out.Close()
+		os.RemoveAll(dir)
+		return err
+	}
+
+	addRunner(lc.AgreementId, r)
+	return nil
+}
+
+func (w *WasmWorker) stopModule(agreementId string) {
+	if r := removeRunner(agreementId); r != nil {
+		r.Stop(WASM_STOP_TIMEOUT_S * time.Second)
+		if c, ok := r.out.(io.Closer); ok {
+			c.Close()
+		}
+	}
+
+	dir := path.Join(w.Config.Edge.ServiceStorage, agreementId)
+	if err := os.RemoveAll(dir); err != nil {
+		glog.Errorf(wwlog(fmt.Sprintf("unable to remove directory %v, error %v", dir, err)))
+	}
+}
+
+// Verify that the module follows the node's admission policy. The memory limit of the module is held to MaxMemoryMB,
+// and absolute preopens to AllowedBindPaths, in the same way as the containers of a native deployment. Relative
+// preopens are in the storage directory of the service, which the agent creates.
+func CheckAdmission(ap *config.AdmissionPolicy, name string, wd *persistence.WasmDeploymentConfig, agentPaths ...string) error {
+	if ap == nil || !ap.IsConfigured() {
+		return nil
+	}
+
+	for _, p := range wd.Preopens {
+		if !filepath.IsAbs(p.HostPath) {
+			continue
+		} else if err := containermessage.CheckHostPathAdmission(ap, name, p.HostPath, agentPaths...); err != nil {
+			return err
+		}
+	}
+
+	return containermessage.CheckMemoryAdmission(ap, name, "memory_limit_mb", wd.MemoryLimitMB)
+}
+
+// Returns the WASI environment of the module. The variables of the deployment config are overridden by the variables
+// that the agent sets for the service, e.g. the user inputs and the HZN_ platform variables.
+func WasmEnvironment(wd *persistence.WasmDeploymentConfig, additions map[string]string) []string {
+	env := map[string]string{}
+	for _, e := range wd.Env {
+		if parts := strings.SplitN(e, "=", 2); len(parts) == 2 {
+			env[parts[0]] = parts[1]
+		}
+	}
+	for k, v := range additions {
+		env[k] = v
+	}
+
+	res := make([]string, 0, len(env))
+	for k, v := range env {
+		res = append(res, k+"="+v)
+	}
+	sort.Strings(res)
+	return res
+}
+
+func isWasmDeployment(depStr string) bool {
+	dep := make(map[string]interface{})
+	if err := json.Unmarshal([]byte(depStr), &dep); err != nil {
+		return false
+	}
+	return persistence.IsWasm(dep)
+}
+
+var wwlog = func(v interface{}) string {
+	return fmt.Sprintf("Wasm Worker: %v", v)
+}
@@ -129,6 +129,12 @@ func NewExchangeContext(id string, token string, url string, cssurl string, http
 	}
 }
 
+// Returns a copy of the exchange context that uses a new token. Workers replace their context rather than changing
+// the token in place because the context is shared with the worker's subworkers.
+func (ec *BaseExchangeContext) WithToken(token string) *BaseExchangeContext {
+	return NewExchangeContext(ec.Id, token, ec.URL, ec.CSSURL, ec.HTTPFactory)
+}
+
 // This function should return the id in the form org/id.
 func (w *BaseWorker) GetExchangeId() string {
 	if w.EC != nil {